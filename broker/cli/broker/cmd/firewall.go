@@ -0,0 +1,176 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/client"
+	"github.com/urfave/cli"
+)
+
+// firewallRuleFlags are the flags describing a firewall rule
+var firewallRuleFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "table",
+		Value: "filter",
+		Usage: "Table of the rule (filter, nat, mangle, raw, security)",
+	},
+	cli.StringFlag{
+		Name:  "chain",
+		Usage: "Chain of the rule (INPUT, OUTPUT, FORWARD, ...)",
+	},
+	cli.StringFlag{
+		Name:  "action",
+		Value: "ACCEPT",
+		Usage: "Action of the rule (ACCEPT, DROP, REJECT, QUEUE, RETURN)",
+	},
+	cli.StringFlag{
+		Name:  "protocol",
+		Usage: "Protocol of the rule (tcp, udp, icmp, ...)",
+	},
+	cli.StringFlag{
+		Name:  "from",
+		Usage: "Source address or CIDR of the rule",
+	},
+	cli.StringFlag{
+		Name:  "from-port",
+		Usage: "Source port or port range (ex: 1000:2000) of the rule, needs tcp or udp protocol",
+	},
+	cli.StringFlag{
+		Name:  "to",
+		Usage: "Destination address or CIDR of the rule",
+	},
+	cli.StringFlag{
+		Name:  "to-port",
+		Usage: "Destination port or port range (ex: 1000:2000) of the rule, needs tcp or udp protocol",
+	},
+	cli.StringFlag{
+		Name:  "spec",
+		Usage: "Raw iptables options of the rule (ex: \"-i eth0 -j ACCEPT\"), other rule flags are then ignored",
+	},
+}
+
+// firewallRuleRequest builds the request for the rule described by the flags of c
+func firewallRuleRequest(c *cli.Context) (pb.FirewallRuleRequest, error) {
+	if c.NArg() != 1 {
+		fmt.Println("Missing mandatory argument <Host_name>")
+		cli.ShowSubcommandHelp(c)
+		return pb.FirewallRuleRequest{}, fmt.Errorf("host name or ID required")
+	}
+	if c.String("chain") == "" {
+		cli.ShowSubcommandHelp(c)
+		return pb.FirewallRuleRequest{}, fmt.Errorf("chain required")
+	}
+	return pb.FirewallRuleRequest{
+		Host: &pb.Reference{Name: c.Args().First()},
+		Rule: &pb.FirewallRule{
+			Table:    c.String("table"),
+			Chain:    c.String("chain"),
+			Action:   c.String("action"),
+			Protocol: c.String("protocol"),
+			From:     c.String("from"),
+			FromPort: c.String("from-port"),
+			To:       c.String("to"),
+			ToPort:   c.String("to-port"),
+			Spec:     c.String("spec"),
+		},
+	}, nil
+}
+
+var hostFirewall = cli.Command{
+	Name:  "firewall",
+	Usage: "firewall COMMAND",
+	Subcommands: []cli.Command{
+		hostFirewallList,
+		hostFirewallAdd,
+		hostFirewallRemove,
+	},
+}
+
+var hostFirewallList = cli.Command{
+	Name:      "list",
+	Usage:     "List the chains and rules of the firewall of a host",
+	ArgsUsage: "<Host_name|Host_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <Host_name>")
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("host name or ID required")
+		}
+		resp, err := client.New().Firewall.List(c.Args().First(), client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "list of firewall rules", false))
+		}
+		out, _ := json.Marshal(resp)
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
+var hostFirewallAdd = cli.Command{
+	Name:      "add",
+	Usage:     "Add a rule to the firewall of a host; the rules are restored if the host becomes unreachable",
+	ArgsUsage: "<Host_name|Host_ID>",
+	Flags: append([]cli.Flag{
+		cli.BoolFlag{
+			Name:  "insert",
+			Usage: "Insert the rule at the beginning of the chain instead of appending it",
+		},
+	}, firewallRuleFlags...),
+	Action: func(c *cli.Context) error {
+		req, err := firewallRuleRequest(c)
+		if err != nil {
+			return err
+		}
+		req.Insert = c.Bool("insert")
+		err = client.New().Firewall.Add(req, client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "addition of firewall rule", false))
+		}
+		fmt.Printf("Firewall rule added to host '%s'\n", c.Args().First())
+		return nil
+	},
+}
+
+var hostFirewallRemove = cli.Command{
+	Name:      "remove",
+	Usage:     "Remove a rule, given by its content or its index, from the firewall of a host",
+	ArgsUsage: "<Host_name|Host_ID>",
+	Flags: append([]cli.Flag{
+		cli.IntFlag{
+			Name:  "index",
+			Usage: "Index of the rule in the chain, starting at 1, as shown by 'broker host firewall list'",
+		},
+	}, firewallRuleFlags...),
+	Action: func(c *cli.Context) error {
+		req, err := firewallRuleRequest(c)
+		if err != nil {
+			return err
+		}
+		req.Index = int32(c.Int("index"))
+		err = client.New().Firewall.Remove(req, client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "removal of firewall rule", false))
+		}
+		fmt.Printf("Firewall rule removed from host '%s'\n", c.Args().First())
+		return nil
+	},
+}
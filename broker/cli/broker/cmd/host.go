@@ -19,6 +19,7 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 
 	pb "github.com/CS-SI/SafeScale/broker"
 	"github.com/CS-SI/SafeScale/broker/client"
@@ -38,6 +39,10 @@ var HostCmd = cli.Command{
 		hostReboot,
 		hostStart,
 		hostStop,
+		hostImport,
+		hostTag,
+		hostRekey,
+		hostFirewall,
 	},
 }
 
@@ -114,9 +119,21 @@ var hostList = cli.Command{
 		cli.BoolFlag{
 			Name:  "all",
 			Usage: "List all hosts on tenant (not only those created by SafeScale)",
-		}},
+		},
+		cli.StringFlag{
+			Name:  "net",
+			Usage: "List only the hosts attached to this network, including its gateway",
+		},
+		selectorFlag,
+	},
 	Action: func(c *cli.Context) error {
-		hosts, err := client.New().Host.List(c.Bool("all"), client.DefaultExecutionTimeout)
+		var hosts *pb.HostList
+		var err error
+		if c.String("net") != "" {
+			hosts, err = client.New().Host.ListOnNetwork(c.String("net"), c.String("selector"), client.DefaultExecutionTimeout)
+		} else {
+			hosts, err = client.New().Host.List(c.Bool("all"), c.String("selector"), client.DefaultExecutionTimeout)
+		}
 		if err != nil {
 			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "list of hosts", false))
 		}
@@ -188,6 +205,11 @@ var hostCreate = cli.Command{
 			Usage:  "With GPU",
 			Hidden: true,
 		},
+		tagFlag,
+		cli.StringSliceFlag{
+			Name:  "security-group",
+			Usage: "Name or id of a security group bound to the host (can be repeated); by default the host gets the default security group of the provider, opening all ports",
+		},
 	},
 	Action: func(c *cli.Context) error {
 		if c.NArg() != 1 {
@@ -195,21 +217,31 @@ var hostCreate = cli.Command{
 			cli.ShowSubcommandHelp(c)
 			return fmt.Errorf("host and network name are required")
 		}
+		tags, err := creationTags(c)
+		if err != nil {
+			return err
+		}
 		def := pb.HostDefinition{
-			Name:      c.Args().First(),
-			CPUNumber: int32(c.Int("cpu")),
-			Disk:      int32(c.Float64("disk")),
-			ImageID:   c.String("os"),
-			Network:   c.String("net"),
-			Public:    c.Bool("public"),
-			RAM:       float32(c.Float64("ram")),
+			Name:           c.Args().First(),
+			CPUNumber:      int32(c.Int("cpu")),
+			Disk:           int32(c.Float64("disk")),
+			ImageID:        c.String("os"),
+			Network:        c.String("net"),
+			Public:         c.Bool("public"),
+			RAM:            float32(c.Float64("ram")),
+			Tags:           tags,
+			SecurityGroups: c.StringSlice("security-group"),
 		}
-		resp, err := client.New().Host.Create(def, client.DefaultExecutionTimeout)
+		jobID, err := client.New().Host.CreateAsync(def)
 		if err != nil {
 			return fmt.Errorf("Error response from daemon: %v", client.DecorateError(err, "creation of host", true))
 		}
+		job, err := waitJobResult(jobID, "creation of host")
+		if err != nil {
+			return err
+		}
 
-		out, _ := json.Marshal(resp)
+		out, _ := json.Marshal(job.GetHost())
 		fmt.Println(string(out))
 
 		return nil
@@ -226,10 +258,14 @@ var hostDelete = cli.Command{
 			cli.ShowSubcommandHelp(c)
 			return fmt.Errorf("host name or ID required")
 		}
-		err := client.New().Host.Delete(c.Args().First(), client.DefaultExecutionTimeout)
+		jobID, err := client.New().Host.DeleteAsync(c.Args().First())
 		if err != nil {
 			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "deletion of host", true))
 		}
+		err = waitJob(jobID, "deletion of host")
+		if err != nil {
+			return err
+		}
 		fmt.Printf("Host '%s' deleted\n", c.Args().First())
 		return nil
 	},
@@ -254,3 +290,108 @@ var hostSsh = cli.Command{
 		return nil
 	},
 }
+
+var hostImport = cli.Command{
+	Name:      "import",
+	Usage:     "Import a host not created by SafeScale",
+	ArgsUsage: "<Host_name|Host_ID>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "net",
+			Value: "",
+			Usage: "network name or network id of the host; the host will be reached through the gateway of this network",
+		},
+		cli.StringFlag{
+			Name:  "key-file",
+			Value: "",
+			Usage: "file containing the private key used to connect to the host with SSH",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <Host_name>")
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("host name or ID required")
+		}
+		if c.String("key-file") == "" {
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("private key file required")
+		}
+		key, err := ioutil.ReadFile(c.String("key-file"))
+		if err != nil {
+			return fmt.Errorf("failed to read private key file: %v", err)
+		}
+		def := pb.HostImport{
+			Ref:        c.Args().First(),
+			Network:    c.String("net"),
+			PrivateKey: string(key),
+		}
+		resp, err := client.New().Host.Import(def, client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "import of host", false))
+		}
+
+		out, _ := json.Marshal(resp)
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
+var hostTag = cli.Command{
+	Name:      "tag",
+	Usage:     "Set or remove tags of a host",
+	ArgsUsage: "<Host_name|Host_ID> [key=value...]",
+	Flags: []cli.Flag{
+		removeTagFlag,
+	},
+	Action: func(c *cli.Context) error {
+		name, set, remove, err := tagArgs(c, "Host")
+		if err != nil {
+			return err
+		}
+		resp, err := client.New().Host.Tag(name, set, remove, client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "tagging of host", false))
+		}
+
+		out, _ := json.Marshal(resp)
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
+var hostRekey = cli.Command{
+	Name:      "rekey",
+	Usage:     "Accept the key currently presented by the SSH server of a host, when it has legitimately changed",
+	ArgsUsage: "<Host_name|Host_ID>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "fingerprint",
+			Value: "",
+			Usage: "mandatory, SHA256 fingerprint of the new key (as displayed by 'ssh-keygen -lf' on the host, read from the console of the provider); the key is refused if it differs",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <Host_name>")
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("host name or ID required")
+		}
+		if c.String("fingerprint") == "" {
+			fmt.Println("Missing mandatory option --fingerprint")
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("fingerprint of the new key required")
+		}
+		resp, err := client.New().Host.Rekey(c.Args().First(), c.String("fingerprint"), client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "rekey of host", false))
+		}
+
+		out, _ := json.Marshal(resp)
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
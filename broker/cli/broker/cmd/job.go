@@ -0,0 +1,155 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/client"
+	"github.com/urfave/cli"
+)
+
+// JobCmd command
+var JobCmd = cli.Command{
+	Name:  "job",
+	Usage: "job COMMAND",
+	Subcommands: []cli.Command{
+		jobList,
+		jobInspect,
+		jobWatch,
+		jobCancel,
+	},
+}
+
+var jobList = cli.Command{
+	Name:  "list",
+	Usage: "List jobs known by brokerd",
+	Action: func(c *cli.Context) error {
+		jobs, err := client.New().Job.List(client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "list of jobs", false))
+		}
+		out, _ := json.Marshal(jobs.GetJobs())
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
+var jobInspect = cli.Command{
+	Name:      "inspect",
+	Usage:     "inspect JOB",
+	ArgsUsage: "<job_id>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <job_id>")
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("Job ID required")
+		}
+		job, err := client.New().Job.Get(c.Args().First(), client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "inspection of job", false))
+		}
+		out, _ := json.Marshal(job)
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
+var jobWatch = cli.Command{
+	Name:      "watch",
+	Usage:     "Display progress of JOB until its end",
+	ArgsUsage: "<job_id>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <job_id>")
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("Job ID required")
+		}
+		return waitJob(c.Args().First(), "job")
+	},
+}
+
+var jobCancel = cli.Command{
+	Name:      "cancel",
+	Usage:     "cancel JOB",
+	ArgsUsage: "<job_id>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <job_id>")
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("Job ID required")
+		}
+		err := client.New().Job.Cancel(c.Args().First(), client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "cancellation of job", false))
+		}
+		fmt.Printf("Job '%s' canceled\n", c.Args().First())
+
+		return nil
+	},
+}
+
+// waitJob displays on stderr the progress of the job identified by id until its end
+// If the wait is interrupted by the user, the job is canceled
+func waitJob(id string, action string) error {
+	var last *pb.JobEvent
+	err := client.New().Job.Watch(id, client.DefaultExecutionTimeout, func(event *pb.JobEvent) {
+		last = event
+		fmt.Fprintf(os.Stderr, "[%s] %s\n", time.Unix(event.GetTime(), 0).Format("15:04:05"), event.GetStep())
+	})
+	if err == context.Canceled {
+		cerr := client.New().Job.Cancel(id, client.DefaultExecutionTimeout)
+		if cerr != nil {
+			return fmt.Errorf("Failed to cancel %s (job '%s') : %v", action, id, client.DecorateError(cerr, "cancellation of job", false))
+		}
+		return fmt.Errorf("%s canceled (job '%s')", action, id)
+	}
+	if err == context.DeadlineExceeded {
+		return fmt.Errorf("%s took too long (> %v), use 'broker job watch %s' to follow it", action, client.DefaultExecutionTimeout, id)
+	}
+	if err != nil {
+		return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, action, true))
+	}
+	if last == nil || last.GetState() != pb.JobState_JOB_SUCCEEDED {
+		msg := "unknown error"
+		if last != nil {
+			msg = last.GetError()
+		}
+		return fmt.Errorf("Error response from daemon : %s", msg)
+	}
+	return nil
+}
+
+// waitJobResult waits for the end of the job identified by id like waitJob, then returns the job with its result
+func waitJobResult(id string, action string) (*pb.Job, error) {
+	err := waitJob(id, action)
+	if err != nil {
+		return nil, err
+	}
+	job, err := client.New().Job.Get(id, client.DefaultExecutionTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "inspection of job", false))
+	}
+	return job, nil
+}
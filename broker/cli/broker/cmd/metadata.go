@@ -0,0 +1,135 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/CS-SI/SafeScale/broker/client"
+	"github.com/urfave/cli"
+)
+
+// MetadataCmd command
+var MetadataCmd = cli.Command{
+	Name:  "metadata",
+	Usage: "metadata COMMAND",
+	Subcommands: []cli.Command{
+		metadataLocks,
+		metadataReencrypt,
+		metadataMigrate,
+	},
+}
+
+var metadataLocks = cli.Command{
+	Name:  "locks",
+	Usage: "List the locks of the metadata bucket, or break one of them",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "break",
+			Value: "",
+			Usage: "Key of the lock to break (only if expired, unless --force is used)",
+		},
+		cli.BoolFlag{
+			Name:  "force",
+			Usage: "Break the lock even if it is not expired",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		key := c.String("break")
+		if key != "" {
+			err := client.New().Metadata.BreakLock(key, c.Bool("force"), client.DefaultExecutionTimeout)
+			if err != nil {
+				return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "break of metadata lock", false))
+			}
+			fmt.Printf("Metadata lock '%s' broken\n", key)
+			return nil
+		}
+
+		locks, err := client.New().Metadata.ListLocks(client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "list of metadata locks", false))
+		}
+		out, _ := json.Marshal(locks.GetLocks())
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
+var metadataReencrypt = cli.Command{
+	Name:  "reencrypt",
+	Usage: "Rewrite all the metadata with the current metadata key of the tenant",
+	Action: func(c *cli.Context) error {
+		result, err := client.New().Metadata.Reencrypt(client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "reencryption of metadata", false))
+		}
+		fmt.Printf("Metadata reencrypted, %d objects rewritten\n", result.GetCount())
+
+		return nil
+	},
+}
+
+var metadataMigrate = cli.Command{
+	Name:  "migrate",
+	Usage: "Upgrade all the metadata to the current version of their format",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "List the metadata to upgrade without modifying them",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		migrations, err := client.New().Metadata.Migrate(c.Bool("dry-run"), client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "migration of metadata", false))
+		}
+		out, _ := json.Marshal(migrations.GetMigrations())
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
+// DoctorCmd command
+var DoctorCmd = cli.Command{
+	Name:  "doctor",
+	Usage: "Check the consistency between the metadata and the resources of the tenant",
+	Description: "Hosts, networks, volumes and volume attachments are cross-checked with the provider; nas and clusters\n" +
+		"are only checked for references to hosts and networks which don't exist anymore",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "repair",
+			Usage: "Remove the metadata of the resources which don't exist anymore",
+		},
+		cli.BoolFlag{
+			Name:  "import",
+			Usage: "Create the metadata of the resources not tracked by SafeScale",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		report, err := client.New().Metadata.Doctor(c.Bool("repair"), c.Bool("import"), client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "check of metadata", false))
+		}
+		out, _ := json.Marshal(report.GetFindings())
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
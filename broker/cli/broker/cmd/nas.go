@@ -19,6 +19,8 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 
 	pb "github.com/CS-SI/SafeScale/broker"
 	"github.com/CS-SI/SafeScale/broker/client"
@@ -38,9 +40,17 @@ var NasCmd = cli.Command{
 		nasUnmount,
 		nasList,
 		nasInspect,
+		nasTag,
+		nasACL,
+		nasFailover,
 	},
 }
 
+var aclFlag = cli.StringSliceFlag{
+	Name:  "acl",
+	Usage: "Rule of access to the exported directory, in the syntax of /etc/exports: 'host_pattern(options)' with options among ro, rw, sec=sys|krb5|krb5i|krb5p[:...], root_squash, no_root_squash, secure, insecure, sync, async, subtree_check, no_subtree_check, anonuid=n, anongid=n (may be repeated; without ACL every host may mount the directory read-write)",
+}
+
 var nasCreate = cli.Command{
 	Name:      "create",
 	Usage:     "Create a nfs server on an host and expose a directory",
@@ -51,6 +61,17 @@ var nasCreate = cli.Command{
 			Value: api.DefaultNasExposedPath,
 			Usage: "Path to be exported",
 		},
+		cli.StringFlag{
+			Name:  "secondary",
+			Usage: "Host replicating the exported directory, taking over when the first host fails (the clients mount the nas through a virtual IP address; VRRP has to be allowed between both hosts)",
+		},
+		cli.IntFlag{
+			Name:  "volume-size",
+			Value: 100,
+			Usage: "Size in GB of the volume created on each host and replicated, with --secondary",
+		},
+		aclFlag,
+		tagFlag,
 	},
 	Action: func(c *cli.Context) error {
 		if c.NArg() != 2 {
@@ -58,12 +79,33 @@ var nasCreate = cli.Command{
 			cli.ShowSubcommandHelp(c)
 			return fmt.Errorf("Nas and Host name required")
 		}
+		tags, err := creationTags(c)
+		if err != nil {
+			return err
+		}
+		var acls []*pb.NasACL
+		for _, value := range c.StringSlice("acl") {
+			acl, err := parseNasACL(value)
+			if err != nil {
+				return err
+			}
+			acls = append(acls, acl)
+		}
 		def := pb.NasDefinition{
 			Nas:  &pb.NasName{Name: c.Args().Get(0)},
 			Host: &pb.Reference{Name: c.Args().Get(1)},
 			Path: c.String("path"),
+			Tags: tags,
+			ACLs: acls,
+		}
+		timeout := client.DefaultExecutionTimeout
+		if secondary := c.String("secondary"); secondary != "" {
+			def.Secondary = &pb.Reference{Name: secondary}
+			def.VolumeSize = int32(c.Int("volume-size"))
+			// Volumes have to be created and attached, then DRBD and keepalived installed on both hosts
+			timeout = 3 * client.DefaultExecutionTimeout
 		}
-		err := client.New().Nas.Create(def, client.DefaultExecutionTimeout)
+		err = client.New().Nas.Create(def, timeout)
 		if err != nil {
 			return fmt.Errorf("Error response from daemon: %v", client.DecorateError(err, "creation of nas", true))
 		}
@@ -94,8 +136,11 @@ var nasDelete = cli.Command{
 var nasList = cli.Command{
 	Name:  "list",
 	Usage: "List all created nas",
+	Flags: []cli.Flag{
+		selectorFlag,
+	},
 	Action: func(c *cli.Context) error {
-		nass, err := client.New().Nas.List(0)
+		nass, err := client.New().Nas.List(c.String("selector"), 0)
 		if err != nil {
 			return fmt.Errorf("Error response from daemon: %v", client.DecorateError(err, "list of nas", false))
 		}
@@ -116,6 +161,15 @@ var nasMount = cli.Command{
 			Value: api.DefaultNasMountPath,
 			Usage: "Path to be mounted",
 		},
+		cli.BoolFlag{
+			Name:  "ro",
+			Usage: "Mount the directory read-only",
+		},
+		cli.StringFlag{
+			Name:  "sec",
+			Value: "sys",
+			Usage: "Security flavor used to access the directory (sys, krb5, krb5i or krb5p)",
+		},
 	},
 	Action: func(c *cli.Context) error {
 		if c.NArg() != 2 {
@@ -123,7 +177,7 @@ var nasMount = cli.Command{
 			cli.ShowSubcommandHelp(c)
 			return fmt.Errorf("Nas and Host name required")
 		}
-		err := client.New().Nas.Mount(c.Args().Get(0), c.Args().Get(1), c.String("path"), client.DefaultExecutionTimeout)
+		err := client.New().Nas.Mount(c.Args().Get(0), c.Args().Get(1), c.String("path"), c.Bool("ro"), c.String("sec"), client.DefaultExecutionTimeout)
 		if err != nil {
 			return fmt.Errorf("Error response from daemon: %v", client.DecorateError(err, "mount of nas", true))
 		}
@@ -170,3 +224,162 @@ var nasInspect = cli.Command{
 		return nil
 	},
 }
+
+var nasTag = cli.Command{
+	Name:      "tag",
+	Usage:     "Set or remove tags of a nas",
+	ArgsUsage: "<Nas_name> [key=value...]",
+	Flags: []cli.Flag{
+		removeTagFlag,
+	},
+	Action: func(c *cli.Context) error {
+		name, set, remove, err := tagArgs(c, "Nas")
+		if err != nil {
+			return err
+		}
+		nas, err := client.New().Nas.Tag(name, set, remove, client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon: %v", client.DecorateError(err, "tagging of nas", false))
+		}
+		out, _ := json.Marshal(nas)
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
+var nasACL = cli.Command{
+	Name:  "acl",
+	Usage: "Manage the rules of access to the directory exported by a nas",
+	Subcommands: []cli.Command{
+		{
+			Name:      "add",
+			Usage:     "Add a rule of access, replacing the rule of the same host pattern if any",
+			ArgsUsage: "<Nas_name> <host_pattern(options)>",
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 2 {
+					fmt.Println("Missing mandatory argument <Nas_name> and/or <host_pattern(options)>")
+					cli.ShowSubcommandHelp(c)
+					return fmt.Errorf("Nas name and ACL required")
+				}
+				acl, err := parseNasACL(c.Args().Get(1))
+				if err != nil {
+					return err
+				}
+				nas, err := client.New().Nas.AddACL(c.Args().Get(0), acl, client.DefaultExecutionTimeout)
+				if err != nil {
+					return fmt.Errorf("Error response from daemon: %v", client.DecorateError(err, "addition of nas ACL", false))
+				}
+				out, _ := json.Marshal(nas)
+				fmt.Println(string(out))
+
+				return nil
+			},
+		},
+		{
+			Name:      "remove",
+			Usage:     "Remove the rule of access of a host pattern",
+			ArgsUsage: "<Nas_name> <host_pattern>",
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 2 {
+					fmt.Println("Missing mandatory argument <Nas_name> and/or <host_pattern>")
+					cli.ShowSubcommandHelp(c)
+					return fmt.Errorf("Nas name and host pattern required")
+				}
+				nas, err := client.New().Nas.RemoveACL(c.Args().Get(0), c.Args().Get(1), client.DefaultExecutionTimeout)
+				if err != nil {
+					return fmt.Errorf("Error response from daemon: %v", client.DecorateError(err, "removal of nas ACL", false))
+				}
+				out, _ := json.Marshal(nas)
+				fmt.Println(string(out))
+
+				return nil
+			},
+		},
+	},
+}
+
+// parseNasACL parses an ACL written in the syntax of /etc/exports, like '10.0.0.0/24(ro,sec=krb5:krb5i)'
+func parseNasACL(value string) (*pb.NasACL, error) {
+	value = strings.TrimSpace(value)
+	acl := &pb.NasACL{Host: value}
+	i := strings.Index(value, "(")
+	if i < 0 {
+		return acl, nil
+	}
+	if !strings.HasSuffix(value, ")") {
+		return nil, fmt.Errorf("invalid ACL '%s': missing closing parenthesis", value)
+	}
+	acl.Host = value[:i]
+	if acl.Host == "" {
+		acl.Host = "*"
+	}
+	for _, option := range strings.Split(value[i+1:len(value)-1], ",") {
+		option = strings.TrimSpace(option)
+		name, arg := option, ""
+		if j := strings.Index(option, "="); j >= 0 {
+			name, arg = option[:j], option[j+1:]
+		}
+		var err error
+		switch name {
+		case "":
+		case "ro":
+			acl.ReadOnly = true
+		case "rw":
+			acl.ReadOnly = false
+		case "sec":
+			acl.SecurityModes = strings.Split(arg, ":")
+		case "root_squash":
+			acl.NoRootSquash = false
+		case "no_root_squash":
+			acl.NoRootSquash = true
+		case "secure":
+			acl.Secure = true
+		case "insecure":
+			acl.Secure = false
+		case "sync":
+			acl.Async = false
+		case "async":
+			acl.Async = true
+		case "subtree_check":
+			acl.NoSubtreeCheck = false
+		case "no_subtree_check":
+			acl.NoSubtreeCheck = true
+		case "anonuid":
+			var uid int
+			uid, err = strconv.Atoi(arg)
+			acl.AnonUID = int32(uid)
+		case "anongid":
+			var gid int
+			gid, err = strconv.Atoi(arg)
+			acl.AnonGID = int32(gid)
+		default:
+			return nil, fmt.Errorf("invalid ACL '%s': unsupported option '%s'", value, option)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid ACL '%s': invalid value of option '%s'", value, name)
+		}
+	}
+	return acl, nil
+}
+
+var nasFailover = cli.Command{
+	Name:      "failover",
+	Usage:     "Make the secondary server of a highly available nas take over from the active one",
+	ArgsUsage: "<Nas_name>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <Nas_name>")
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("Nas name required")
+		}
+		nas, err := client.New().Nas.Failover(c.Args().Get(0), client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon: %v", client.DecorateError(err, "failover of nas", false))
+		}
+		out, _ := json.Marshal(nas)
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
@@ -19,6 +19,7 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 
 	pb "github.com/CS-SI/SafeScale/broker"
 	"github.com/CS-SI/SafeScale/broker/client"
@@ -34,6 +35,8 @@ var NetworkCmd = cli.Command{
 		networkDelete,
 		networkInspect,
 		networkList,
+		networkImport,
+		networkTag,
 	},
 }
 
@@ -44,9 +47,11 @@ var networkList = cli.Command{
 		cli.BoolFlag{
 			Name:  "all",
 			Usage: "List all Networks on tenant (not only those created by SafeScale)",
-		}},
+		},
+		selectorFlag,
+	},
 	Action: func(c *cli.Context) error {
-		networks, err := client.New().Network.List(c.Bool("all"), client.DefaultExecutionTimeout)
+		networks, err := client.New().Network.List(c.Bool("all"), c.String("selector"), client.DefaultExecutionTimeout)
 		if err != nil {
 			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "list of networks", false))
 		}
@@ -67,10 +72,14 @@ var networkDelete = cli.Command{
 			cli.ShowSubcommandHelp(c)
 			return fmt.Errorf("Network name required")
 		}
-		err := client.New().Network.Delete(c.Args().First(), client.DefaultExecutionTimeout)
+		jobID, err := client.New().Network.DeleteAsync(c.Args().First())
 		if err != nil {
 			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "deletion of network", true))
 		}
+		err = waitJob(jobID, "deletion of network")
+		if err != nil {
+			return err
+		}
 		fmt.Println(fmt.Sprintf("Network '%s' deleted", c.Args().First()))
 
 		return nil
@@ -133,6 +142,11 @@ var networkCreate = cli.Command{
 			Value: "",
 			Usage: "Name for the gateway. Default to 'gw-<network_name>'",
 		},
+		cli.StringSliceFlag{
+			Name:  "gw-security-group",
+			Usage: "Name or id of a security group bound to the gateway (can be repeated); by default the gateway gets the default security group of the provider, opening all ports",
+		},
+		tagFlag,
 	},
 	Action: func(c *cli.Context) error {
 		if c.NArg() != 1 {
@@ -140,6 +154,10 @@ var networkCreate = cli.Command{
 			cli.ShowSubcommandHelp(c)
 			return fmt.Errorf("Network name required")
 		}
+		tags, err := creationTags(c)
+		if err != nil {
+			return err
+		}
 		netdef := pb.NetworkDefinition{
 			CIDR: c.String("cidr"),
 			Name: c.Args().Get(0),
@@ -148,14 +166,91 @@ var networkCreate = cli.Command{
 				Disk: int32(c.Int("disk")),
 				RAM:  float32(c.Float64("ram")),
 				// CPUFrequency: ??,
-				ImageID: c.String("os"),
-				Name:    c.String("gwname"),
+				ImageID:        c.String("os"),
+				Name:           c.String("gwname"),
+				SecurityGroups: c.StringSlice("gw-security-group"),
 			},
+			Tags: tags,
 		}
-		network, err := client.New().Network.Create(netdef, client.DefaultExecutionTimeout)
+		jobID, err := client.New().Network.CreateAsync(netdef)
 		if err != nil {
 			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "creation of network", true))
 		}
+		job, err := waitJobResult(jobID, "creation of network")
+		if err != nil {
+			return err
+		}
+		out, _ := json.Marshal(job.GetNetwork())
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
+var networkImport = cli.Command{
+	Name:      "import",
+	Usage:     "Import a network not created by SafeScale",
+	ArgsUsage: "<network_name|network_ID>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "gateway",
+			Value: "",
+			Usage: "name or id of the host acting as gateway of the network",
+		},
+		cli.StringFlag{
+			Name:  "key-file",
+			Value: "",
+			Usage: "file containing the private key used to connect to the gateway with SSH",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <network_name>")
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("Network name required")
+		}
+		def := pb.NetworkImport{
+			Ref:     c.Args().First(),
+			Gateway: c.String("gateway"),
+		}
+		if def.Gateway != "" {
+			if c.String("key-file") == "" {
+				cli.ShowSubcommandHelp(c)
+				return fmt.Errorf("private key file of the gateway required")
+			}
+			key, err := ioutil.ReadFile(c.String("key-file"))
+			if err != nil {
+				return fmt.Errorf("failed to read private key file: %v", err)
+			}
+			def.PrivateKey = string(key)
+		}
+		network, err := client.New().Network.Import(def, client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "import of network", false))
+		}
+		out, _ := json.Marshal(network)
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
+var networkTag = cli.Command{
+	Name:      "tag",
+	Usage:     "Set or remove tags of a network",
+	ArgsUsage: "<network_name|network_ID> [key=value...]",
+	Flags: []cli.Flag{
+		removeTagFlag,
+	},
+	Action: func(c *cli.Context) error {
+		name, set, remove, err := tagArgs(c, "network")
+		if err != nil {
+			return err
+		}
+		network, err := client.New().Network.Tag(name, set, remove, client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "tagging of network", false))
+		}
 		out, _ := json.Marshal(network)
 		fmt.Println(string(out))
 
@@ -0,0 +1,223 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/client"
+	"github.com/urfave/cli"
+)
+
+//SecurityGroupCmd security group command
+var SecurityGroupCmd = cli.Command{
+	Name:  "securitygroup",
+	Usage: "securitygroup COMMAND",
+	Subcommands: []cli.Command{
+		securityGroupCreate,
+		securityGroupList,
+		securityGroupInspect,
+		securityGroupDelete,
+		securityGroupRule,
+	},
+}
+
+var securityGroupCreate = cli.Command{
+	Name:      "create",
+	Usage:     "Create a security group without rule, allowing only outgoing traffic",
+	ArgsUsage: "<SecurityGroup_name>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "description",
+			Value: "",
+			Usage: "Description of the security group",
+		},
+		cli.StringFlag{
+			Name:  "net",
+			Value: "",
+			Usage: "Name or id of the network of the security group, for the providers binding security groups to networks (aws)",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <SecurityGroup_name>")
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("security group name required")
+		}
+		sg, err := client.New().SecurityGroup.Create(pb.SecurityGroupDefinition{
+			Name:        c.Args().First(),
+			Description: c.String("description"),
+			Network:     c.String("net"),
+		}, client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "creation of security group", true))
+		}
+		out, _ := json.Marshal(sg)
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
+var securityGroupList = cli.Command{
+	Name:  "list",
+	Usage: "List available security groups",
+	Action: func(c *cli.Context) error {
+		resp, err := client.New().SecurityGroup.List(client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "list of security groups", false))
+		}
+		out, _ := json.Marshal(resp.GetSecurityGroups())
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
+var securityGroupInspect = cli.Command{
+	Name:      "inspect",
+	Usage:     "Inspect security group and its rules",
+	ArgsUsage: "<SecurityGroup_name|SecurityGroup_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <SecurityGroup_name>")
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("security group name or ID required")
+		}
+		sg, err := client.New().SecurityGroup.Inspect(c.Args().First(), client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "inspection of security group", false))
+		}
+		out, _ := json.Marshal(sg)
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
+var securityGroupDelete = cli.Command{
+	Name:      "delete",
+	Usage:     "Delete security group",
+	ArgsUsage: "<SecurityGroup_name|SecurityGroup_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <SecurityGroup_name>")
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("security group name or ID required")
+		}
+		err := client.New().SecurityGroup.Delete(c.Args().First(), client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "deletion of security group", false))
+		}
+		fmt.Printf("Security group '%s' deleted\n", c.Args().First())
+
+		return nil
+	},
+}
+
+var securityGroupRule = cli.Command{
+	Name:  "rule",
+	Usage: "rule COMMAND",
+	Subcommands: []cli.Command{
+		securityGroupRuleAdd,
+		securityGroupRuleDelete,
+	},
+}
+
+var securityGroupRuleAdd = cli.Command{
+	Name:      "add",
+	Usage:     "Add a rule, allowing a kind of traffic, to a security group",
+	ArgsUsage: "<SecurityGroup_name|SecurityGroup_ID>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "direction",
+			Value: "ingress",
+			Usage: "Direction of the traffic allowed: ingress or egress",
+		},
+		cli.StringFlag{
+			Name:  "protocol",
+			Value: "",
+			Usage: "Protocol allowed: tcp, udp or icmp (default: all protocols)",
+		},
+		cli.IntFlag{
+			Name:  "port-from",
+			Usage: "First port of the range allowed, needs tcp or udp protocol (default: all ports)",
+		},
+		cli.IntFlag{
+			Name:  "port-to",
+			Usage: "Last port of the range allowed (default: port-from)",
+		},
+		cli.StringFlag{
+			Name:  "cidr",
+			Value: "",
+			Usage: "Remote addresses allowed, in CIDR notation (default: all IPv4 addresses)",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <SecurityGroup_name>")
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("security group name or ID required")
+		}
+		var direction pb.RuleDirection
+		switch strings.ToLower(c.String("direction")) {
+		case "ingress":
+			direction = pb.RuleDirection_INGRESS
+		case "egress":
+			direction = pb.RuleDirection_EGRESS
+		default:
+			return fmt.Errorf("invalid direction '%s': must be ingress or egress", c.String("direction"))
+		}
+		rule, err := client.New().SecurityGroup.AddRule(c.Args().First(), pb.SecurityGroupRule{
+			Direction: direction,
+			Protocol:  c.String("protocol"),
+			PortFrom:  int32(c.Int("port-from")),
+			PortTo:    int32(c.Int("port-to")),
+			CIDR:      c.String("cidr"),
+		}, client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "addition of security group rule", false))
+		}
+		out, _ := json.Marshal(rule)
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
+var securityGroupRuleDelete = cli.Command{
+	Name:      "delete",
+	Usage:     "Delete a rule, given by its ID, from a security group",
+	ArgsUsage: "<SecurityGroup_name|SecurityGroup_ID> <Rule_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 2 {
+			fmt.Println("Missing mandatory argument <SecurityGroup_name> or <Rule_ID>")
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("security group and rule ID required")
+		}
+		err := client.New().SecurityGroup.DeleteRule(c.Args().Get(0), c.Args().Get(1), client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "deletion of security group rule", false))
+		}
+		fmt.Printf("Rule '%s' deleted from security group '%s'\n", c.Args().Get(1), c.Args().Get(0))
+
+		return nil
+	},
+}
@@ -17,13 +17,18 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"text/tabwriter"
 	"time"
 
+	pb "github.com/CS-SI/SafeScale/broker"
 	"github.com/CS-SI/SafeScale/broker/client"
 	utils "github.com/CS-SI/SafeScale/broker/utils"
+	"github.com/CS-SI/SafeScale/deploy/cluster"
 
 	"github.com/urfave/cli"
 )
@@ -36,13 +41,16 @@ var SSHCmd = cli.Command{
 		sshRun,
 		sshCopy,
 		sshConnect,
+		sshTunnel,
+		sshSocks,
+		sshTunnels,
 	},
 }
 
 var sshRun = cli.Command{
 	Name:      "run",
-	Usage:     "Run a command on the host",
-	ArgsUsage: "<Host_name|Host_ID>",
+	Usage:     "Run a command on one or several hosts",
+	ArgsUsage: "[Host_name|Host_ID...]",
 	Flags: []cli.Flag{
 		cli.StringFlag{
 			Name:  "c",
@@ -52,30 +60,134 @@ var sshRun = cli.Command{
 			Name:  "timeout",
 			Value: "5",
 			Usage: "timeout in minutes",
+		},
+		cli.StringFlag{
+			Name:  "net",
+			Usage: "Run the command on the hosts attached to this network, including its gateway",
+		},
+		cli.StringFlag{
+			Name:  "cluster",
+			Usage: "Run the command on the masters and nodes of this cluster",
+		},
+		cli.StringFlag{
+			Name:  "selector",
+			Usage: "Run the command on the hosts whose tags satisfy these comma separated conditions ('key=value', 'key!=value', 'key', '!key'); restricts --net and --cluster if set",
+		},
+		cli.IntFlag{
+			Name:  "fanout",
+			Value: 10,
+			Usage: "Maximum number of hosts running the command at the same time",
 		}},
 	Action: func(c *cli.Context) error {
-		if c.NArg() != 1 {
-			fmt.Println("Missing mandatory argument <Host_name>")
-			cli.ShowSubcommandHelp(c)
-			return fmt.Errorf("host name required")
-		}
 		timeout := utils.TimeoutCtxHost
 		if c.IsSet("timeout") {
 			timeout = time.Duration(c.Float64("timeout")) * time.Minute
 		}
-		retcode, stdout, stderr, err := client.New().Ssh.Run(c.Args().Get(0), c.String("c"), client.DefaultConnectionTimeout, timeout)
+		if c.NArg() == 1 && c.String("net") == "" && c.String("cluster") == "" && c.String("selector") == "" {
+			// The standard input is sent to the command only if it's redirected
+			var stdin io.Reader
+			if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice == 0 {
+				stdin = os.Stdin
+			}
+			retcode, err := client.New().Ssh.Execute(c.Args().Get(0), c.String("c"), stdin, os.Stdout, os.Stderr, timeout)
+			if err != nil {
+				return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "ssh run", false))
+			}
+
+			os.Exit(retcode)
+			return nil
+		}
+
+		hosts, err := fleetHosts(c)
 		if err != nil {
-			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "ssh run", false))
+			return err
+		}
+		if len(hosts) == 0 {
+			fmt.Println("Missing mandatory argument <Host_name>, or --net, --cluster or --selector option")
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("no host to run the command on")
 		}
+		results := client.New().Ssh.RunOnHosts(hosts, c.String("c"), c.Int("fanout"), client.DefaultConnectionTimeout, timeout, func(host string, line string, stderr bool) {
+			if stderr {
+				fmt.Fprintf(os.Stderr, "[%s] %s\n", host, line)
+			} else {
+				fmt.Printf("[%s] %s\n", host, line)
+			}
+		})
 
-		fmt.Println(stdout)
-		fmt.Fprintln(os.Stderr, stderr)
+		// The exit code is the highest exit status of the hosts
+		retcode := 0
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "HOST\tSTATUS\tERROR")
+		for _, r := range results {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", r.Host, r.Status, r.Error)
+			if r.Status > retcode {
+				retcode = r.Status
+			}
+		}
+		w.Flush()
 
 		os.Exit(retcode)
 		return nil
 	},
 }
 
+// fleetHosts returns the names of the hosts targeted by 'broker ssh run', without duplicates
+func fleetHosts(c *cli.Context) ([]string, error) {
+	var names []string
+	seen := map[string]bool{}
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, name := range c.Args() {
+		add(name)
+	}
+
+	broker := client.New()
+	selector := c.String("selector")
+	if net := c.String("net"); net != "" {
+		hosts, err := broker.Host.ListOnNetwork(net, selector, client.DefaultExecutionTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "list of hosts of network", false))
+		}
+		for _, host := range hosts.GetHosts() {
+			add(host.GetName())
+		}
+	}
+	if c.String("cluster") != "" || (selector != "" && c.String("net") == "") {
+		hosts, err := broker.Host.List(false, selector, client.DefaultExecutionTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "list of hosts", false))
+		}
+		var members map[string]bool
+		if name := c.String("cluster"); name != "" {
+			instance, err := cluster.Get(name)
+			if err != nil {
+				return nil, err
+			}
+			if instance == nil {
+				return nil, fmt.Errorf("cluster '%s' not found", name)
+			}
+			members = map[string]bool{}
+			for _, ids := range [][]string{instance.ListMasterIDs(), instance.ListNodeIDs(false), instance.ListNodeIDs(true)} {
+				for _, id := range ids {
+					members[id] = true
+				}
+			}
+		}
+		for _, host := range hosts.GetHosts() {
+			if members == nil || members[host.GetID()] {
+				add(host.GetName())
+			}
+		}
+	}
+	return names, nil
+}
+
 func normalizeFileName(fileName string) string {
 	absPath, _ := filepath.Abs(fileName)
 	if _, err := os.Stat(absPath); err != nil {
@@ -130,3 +242,111 @@ var sshConnect = cli.Command{
 		return err
 	},
 }
+
+var sshTunnel = cli.Command{
+	Name:      "tunnel",
+	Usage:     "Forward a local port to a port reached from the host, until interrupted",
+	ArgsUsage: "<Host_name|Host_ID>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "local",
+			Usage: "local port or address listened by brokerd (port alone = port of localhost, 0 = any free port)",
+		},
+		cli.StringFlag{
+			Name:  "remote",
+			Usage: "port or address connected from the host (port alone = port of the host itself)",
+		}},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <Host_name>")
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("host name required")
+		}
+		if c.String("local") == "" || c.String("remote") == "" {
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("local and remote ports required")
+		}
+		err := client.New().Ssh.Tunnel(c.Args().First(), c.String("local"), c.String("remote"), printTunnel)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "ssh tunnel", false))
+		}
+		fmt.Fprintln(os.Stderr, "Tunnel closed")
+		return nil
+	},
+}
+
+var sshSocks = cli.Command{
+	Name:      "socks",
+	Usage:     "Run a SOCKS5 proxy opening its connections from the gateway of the network, until interrupted",
+	ArgsUsage: "<Network_name|Network_ID>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "local",
+			Value: "1080",
+			Usage: "local port or address listened by brokerd (port alone = port of localhost, 0 = any free port)",
+		}},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <Network_name>")
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("network name required")
+		}
+		err := client.New().Ssh.Socks(c.Args().First(), c.String("local"), printTunnel)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "ssh socks", false))
+		}
+		fmt.Fprintln(os.Stderr, "SOCKS proxy closed")
+		return nil
+	},
+}
+
+// printTunnel displays the tunnel opened by brokerd
+func printTunnel(tunnel *pb.SshTunnel) {
+	out, _ := json.Marshal(tunnel)
+	fmt.Println(string(out))
+	fmt.Fprintf(os.Stderr, "Listening on %s, press Ctrl-C to close\n", tunnel.GetLocal())
+}
+
+var sshTunnels = cli.Command{
+	Name:  "tunnels",
+	Usage: "tunnels COMMAND",
+	Subcommands: []cli.Command{
+		sshTunnelsList,
+		sshTunnelsKill,
+	},
+}
+
+var sshTunnelsList = cli.Command{
+	Name:  "list",
+	Usage: "List the tunnels and SOCKS proxies opened by brokerd",
+	Action: func(c *cli.Context) error {
+		tunnels, err := client.New().Ssh.ListTunnels(client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "list of tunnels", false))
+		}
+		out, _ := json.Marshal(tunnels.GetTunnels())
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
+var sshTunnelsKill = cli.Command{
+	Name:      "kill",
+	Usage:     "Close a tunnel or a SOCKS proxy opened by brokerd",
+	ArgsUsage: "<tunnel_id>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <tunnel_id>")
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("tunnel ID required")
+		}
+		err := client.New().Ssh.KillTunnel(c.Args().First(), client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "kill of tunnel", false))
+		}
+		fmt.Printf("Tunnel '%s' closed\n", c.Args().First())
+
+		return nil
+	},
+}
@@ -0,0 +1,394 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/client"
+	"github.com/CS-SI/SafeScale/broker/stack"
+	"github.com/urfave/cli"
+)
+
+// StackCmd command
+var StackCmd = cli.Command{
+	Name:  "stack",
+	Usage: "stack COMMAND",
+	Subcommands: []cli.Command{
+		stackPlan,
+		stackApply,
+		stackDestroy,
+	},
+}
+
+var stackPlan = cli.Command{
+	Name:      "plan",
+	Usage:     "List the operations needed to create the resources of a stack",
+	ArgsUsage: "<stack_file>",
+	Action: func(c *cli.Context) error {
+		s, err := loadStack(c)
+		if err != nil {
+			return err
+		}
+		state, err := brokerBackend{}.State()
+		if err != nil {
+			return err
+		}
+		plan, err := stack.Diff(s, state)
+		if err != nil {
+			return err
+		}
+		out, _ := json.Marshal(plan)
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+var stackApply = cli.Command{
+	Name:      "apply",
+	Usage:     "Create the resources of a stack which don't exist yet",
+	ArgsUsage: "<stack_file>",
+	Action: func(c *cli.Context) error {
+		s, err := loadStack(c)
+		if err != nil {
+			return err
+		}
+		b := brokerBackend{}
+		state, err := b.State()
+		if err != nil {
+			return err
+		}
+		plan, err := stack.Diff(s, state)
+		if err != nil {
+			return err
+		}
+		for _, w := range plan.Warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+		}
+		err = stack.Apply(b, s, plan, printAction)
+		if err != nil {
+			return err
+		}
+		out, _ := json.Marshal(plan)
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+var stackDestroy = cli.Command{
+	Name:      "destroy",
+	Usage:     "Delete the resources created by a stack",
+	ArgsUsage: "<stack_file>",
+	Action: func(c *cli.Context) error {
+		s, err := loadStack(c)
+		if err != nil {
+			return err
+		}
+		b := brokerBackend{}
+		state, err := b.State()
+		if err != nil {
+			return err
+		}
+		plan := stack.Destroy(s, state)
+		for _, w := range plan.Warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+		}
+		err = stack.Apply(b, s, plan, printAction)
+		if err != nil {
+			return err
+		}
+		out, _ := json.Marshal(plan)
+		fmt.Println(string(out))
+		return nil
+	},
+}
+
+// loadStack reads the stack file given as argument of the command
+func loadStack(c *cli.Context) (*stack.Stack, error) {
+	if c.NArg() != 1 {
+		fmt.Println("Missing mandatory argument <stack_file>")
+		cli.ShowSubcommandHelp(c)
+		return nil, fmt.Errorf("stack file required")
+	}
+	return stack.Load(c.Args().First())
+}
+
+// printAction displays the progress of a stack on the standard error
+func printAction(a stack.Action) {
+	fmt.Fprintf(os.Stderr, "[%s] %s\n", time.Now().Format("15:04:05"), a.String())
+}
+
+// brokerBackend runs the operations of a stack through brokerd
+type brokerBackend struct{}
+
+// State returns the resources managed by brokerd on the current tenant
+func (b brokerBackend) State() (*stack.State, error) {
+	broker := client.New()
+	state := stack.NewState()
+
+	networks, err := broker.Network.List(false, "", client.DefaultExecutionTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "list of networks", false))
+	}
+	for _, n := range networks.GetNetworks() {
+		state.Networks[n.GetName()] = n.GetCIDR()
+		state.SetStack("network", n.GetName(), n.GetTags())
+	}
+
+	hosts, err := broker.Host.List(false, "", client.DefaultExecutionTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "list of hosts", false))
+	}
+	hostNames := map[string]string{}
+	for _, h := range hosts.GetHosts() {
+		state.Hosts[h.GetName()] = true
+		state.SetStack("host", h.GetName(), h.GetTags())
+		hostNames[h.GetID()] = h.GetName()
+	}
+
+	volumes, err := broker.Volume.List(false, "", client.DefaultExecutionTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "list of volumes", false))
+	}
+	for _, v := range volumes.GetVolumes() {
+		info, err := broker.Volume.Inspect(v.GetName(), client.DefaultExecutionTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "inspection of volume", false))
+		}
+		state.Volumes[v.GetName()] = hostNames[info.GetHost().GetID()]
+		state.SetStack("volume", v.GetName(), v.GetTags())
+	}
+
+	nass, err := broker.Nas.List("", client.DefaultExecutionTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "list of nas", false))
+	}
+	for _, n := range nass.GetNasList() {
+		name := n.GetNas().GetName()
+		exports, err := broker.Nas.Inspect(name, client.DefaultExecutionTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "inspection of nas", false))
+		}
+		ns := stack.NasState{Clients: map[string]bool{}}
+		for _, e := range exports.GetNasList() {
+			if e.GetIsServer() {
+				ns.Host = e.GetHost().GetName()
+				ns.Path = e.GetPath()
+			} else {
+				ns.Clients[e.GetHost().GetName()] = true
+			}
+		}
+		state.Nas[name] = ns
+		state.SetStack("nas", name, n.GetTags())
+	}
+
+	containers, err := broker.Container.List(client.DefaultExecutionTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "list of containers", false))
+	}
+	for _, c := range containers.GetContainers() {
+		mp, err := broker.Container.Inspect(c.GetName(), client.DefaultExecutionTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "inspection of container", false))
+		}
+		state.Containers[c.GetName()] = mp.GetHost().GetName()
+	}
+	return state, nil
+}
+
+// CreateNetwork creates a network and its gateway
+func (b brokerBackend) CreateNetwork(n stack.Network) error {
+	jobID, err := client.New().Network.CreateAsync(pb.NetworkDefinition{
+		Name: n.Name,
+		CIDR: n.CIDR,
+		Gateway: &pb.GatewayDefinition{
+			CPU:     int32(n.Gateway.CPU),
+			RAM:     n.Gateway.RAM,
+			Disk:    int32(n.Gateway.Disk),
+			ImageID: n.Gateway.OS,
+			Name:    n.Gateway.Name,
+		},
+		Tags: n.Tags,
+	})
+	if err != nil {
+		return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "creation of network", true))
+	}
+	return waitJob(jobID, "creation of network")
+}
+
+// DeleteNetwork deletes a network and its gateway
+func (b brokerBackend) DeleteNetwork(name string) error {
+	jobID, err := client.New().Network.DeleteAsync(name)
+	if err != nil {
+		return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "deletion of network", true))
+	}
+	return waitJob(jobID, "deletion of network")
+}
+
+// CreateHost creates a host
+func (b brokerBackend) CreateHost(h stack.Host) error {
+	jobID, err := client.New().Host.CreateAsync(pb.HostDefinition{
+		Name:      h.Name,
+		Network:   h.Network,
+		CPUNumber: int32(h.CPU),
+		RAM:       h.RAM,
+		Disk:      int32(h.Disk),
+		ImageID:   h.OS,
+		Public:    h.Public,
+		Tags:      h.Tags,
+	})
+	if err != nil {
+		return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "creation of host", true))
+	}
+	return waitJob(jobID, "creation of host")
+}
+
+// DeleteHost deletes a host
+func (b brokerBackend) DeleteHost(name string) error {
+	jobID, err := client.New().Host.DeleteAsync(name)
+	if err != nil {
+		return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "deletion of host", true))
+	}
+	return waitJob(jobID, "deletion of host")
+}
+
+// CreateVolume creates a volume
+func (b brokerBackend) CreateVolume(v stack.Volume) error {
+	jobID, err := client.New().Volume.CreateAsync(pb.VolumeDefinition{
+		Name:  v.Name,
+		Size:  int32(v.Size),
+		Speed: pb.VolumeSpeed(pb.VolumeSpeed_value[v.Speed]),
+		Tags:  v.Tags,
+	})
+	if err != nil {
+		return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "creation of volume", true))
+	}
+	return waitJob(jobID, "creation of volume")
+}
+
+// DeleteVolume deletes a volume
+func (b brokerBackend) DeleteVolume(name string) error {
+	jobID, err := client.New().Volume.DeleteAsync(name)
+	if err != nil {
+		return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "deletion of volume", true))
+	}
+	return waitJob(jobID, "deletion of volume")
+}
+
+// AttachVolume attaches a volume to a host
+func (b brokerBackend) AttachVolume(volume string, a stack.Attachment) error {
+	err := client.New().Volume.Attach(pb.VolumeAttachment{
+		Volume:    &pb.Reference{Name: volume},
+		Host:      &pb.Reference{Name: a.Host},
+		MountPath: a.Path,
+		Format:    a.Format,
+	}, client.DefaultExecutionTimeout)
+	if err != nil {
+		return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "attach of volume", true))
+	}
+	return nil
+}
+
+// DetachVolume detaches a volume from a host
+func (b brokerBackend) DetachVolume(volume string, host string) error {
+	err := client.New().Volume.Detach(volume, host, client.DefaultExecutionTimeout)
+	if err != nil {
+		return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "unattach of volume", true))
+	}
+	return nil
+}
+
+// CreateNas creates a nfs server
+func (b brokerBackend) CreateNas(n stack.Nas) error {
+	err := client.New().Nas.Create(pb.NasDefinition{
+		Nas:  &pb.NasName{Name: n.Name},
+		Host: &pb.Reference{Name: n.Host},
+		Path: n.Path,
+		Tags: n.Tags,
+	}, client.DefaultExecutionTimeout)
+	if err != nil {
+		return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "creation of nas", true))
+	}
+	return nil
+}
+
+// DeleteNas deletes a nfs server
+func (b brokerBackend) DeleteNas(name string) error {
+	err := client.New().Nas.Delete(name, client.DefaultExecutionTimeout)
+	if err != nil {
+		return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "deletion of nas", true))
+	}
+	return nil
+}
+
+// MountNas mounts a nfs export on a host
+func (b brokerBackend) MountNas(nas string, c stack.NasClient) error {
+	err := client.New().Nas.Mount(nas, c.Host, c.Path, false, "", client.DefaultExecutionTimeout)
+	if err != nil {
+		return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "mount of nas", true))
+	}
+	return nil
+}
+
+// UmountNas unmounts a nfs export from a host
+func (b brokerBackend) UmountNas(nas string, host string) error {
+	err := client.New().Nas.Unmount(nas, host, client.DefaultExecutionTimeout)
+	if err != nil {
+		return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "unmount of nas", true))
+	}
+	return nil
+}
+
+// CreateContainer creates a container
+func (b brokerBackend) CreateContainer(name string) error {
+	err := client.New().Container.Create(name, client.DefaultExecutionTimeout)
+	if err != nil {
+		return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "creation of container", true))
+	}
+	return nil
+}
+
+// DeleteContainer deletes a container
+func (b brokerBackend) DeleteContainer(name string) error {
+	err := client.New().Container.Delete(name, client.DefaultExecutionTimeout)
+	if err != nil {
+		return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "deletion of container", true))
+	}
+	return nil
+}
+
+// MountContainer mounts a container on a host
+func (b brokerBackend) MountContainer(container string, m stack.ContainerMount) error {
+	err := client.New().Container.Mount(container, m.Host, m.Path, client.DefaultExecutionTimeout)
+	if err != nil {
+		return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "mount of container", true))
+	}
+	return nil
+}
+
+// UmountContainer unmounts a container from a host
+func (b brokerBackend) UmountContainer(container string, host string) error {
+	err := client.New().Container.Unmount(container, host, client.DefaultExecutionTimeout)
+	if err != nil {
+		return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "unmount of container", true))
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/CS-SI/SafeScale/providers/filters/tags"
+	"github.com/urfave/cli"
+)
+
+// tagFlag sets tags on a resource at creation
+var tagFlag = cli.StringSliceFlag{
+	Name:  "tag",
+	Usage: "Tag of the resource, as key=value (can be repeated)",
+}
+
+// selectorFlag filters the resources listed on their tags
+var selectorFlag = cli.StringFlag{
+	Name:  "selector",
+	Value: "",
+	Usage: "Comma separated conditions on tags ('key=value', 'key!=value', 'key', '!key') the listed resources must satisfy",
+}
+
+// removeTagFlag removes tags of a resource
+var removeTagFlag = cli.StringSliceFlag{
+	Name:  "remove",
+	Usage: "Key of a tag to remove (can be repeated)",
+}
+
+// creationTags returns the tags given with --tag
+func creationTags(c *cli.Context) (map[string]string, error) {
+	return tags.Parse(c.StringSlice("tag"))
+}
+
+// tagArgs returns the name of the resource, the tags to set and the tags to remove given to a 'tag' command
+func tagArgs(c *cli.Context, kind string) (string, map[string]string, []string, error) {
+	if c.NArg() < 1 {
+		fmt.Printf("Missing mandatory argument <%s_name>\n", kind)
+		cli.ShowSubcommandHelp(c)
+		return "", nil, nil, fmt.Errorf("%s name required", kind)
+	}
+	set, err := tags.Parse(c.Args().Tail())
+	if err != nil {
+		return "", nil, nil, err
+	}
+	remove := c.StringSlice("remove")
+	if len(set) == 0 && len(remove) == 0 {
+		cli.ShowSubcommandHelp(c)
+		return "", nil, nil, fmt.Errorf("no tag to set or remove")
+	}
+	return c.Args().First(), set, remove, nil
+}
@@ -38,6 +38,9 @@ var VolumeCmd = cli.Command{
 		volumeCreate,
 		volumeAttach,
 		volumeDetach,
+		volumeImport,
+		volumeTag,
+		volumeSnapshot,
 	},
 }
 
@@ -48,9 +51,11 @@ var volumeList = cli.Command{
 		cli.BoolFlag{
 			Name:  "all",
 			Usage: "List all Volumes on tenant (not only those created by SafeScale)",
-		}},
+		},
+		selectorFlag,
+	},
 	Action: func(c *cli.Context) error {
-		resp, err := client.New().Volume.List(c.Bool("all"), client.DefaultExecutionTimeout)
+		resp, err := client.New().Volume.List(c.Bool("all"), c.String("selector"), client.DefaultExecutionTimeout)
 		if err != nil {
 			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "list of volumes", false))
 		}
@@ -87,6 +92,52 @@ var volumeInspect = cli.Command{
 	},
 }
 
+var volumeImport = cli.Command{
+	Name:      "import",
+	Usage:     "Import a volume not created by SafeScale",
+	ArgsUsage: "<Volume_name|Volume_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			fmt.Println("Missing mandatory argument <Volume_name|Volume_ID>")
+			cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("Volume name or ID required")
+		}
+		volume, err := client.New().Volume.Import(c.Args().First(), client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "import of volume", false))
+		}
+
+		out, _ := json.Marshal(toDisplaybleVolume(volume))
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
+var volumeTag = cli.Command{
+	Name:      "tag",
+	Usage:     "Set or remove tags of a volume",
+	ArgsUsage: "<Volume_name|Volume_ID> [key=value...]",
+	Flags: []cli.Flag{
+		removeTagFlag,
+	},
+	Action: func(c *cli.Context) error {
+		name, set, remove, err := tagArgs(c, "Volume")
+		if err != nil {
+			return err
+		}
+		volume, err := client.New().Volume.Tag(name, set, remove, client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "tagging of volume", false))
+		}
+
+		out, _ := json.Marshal(toDisplaybleVolume(volume))
+		fmt.Println(string(out))
+
+		return nil
+	},
+}
+
 var volumeDelete = cli.Command{
 	Name:      "delete",
 	Usage:     "Delete volume",
@@ -97,10 +148,14 @@ var volumeDelete = cli.Command{
 			cli.ShowSubcommandHelp(c)
 			return fmt.Errorf("Volume name or ID required")
 		}
-		err := client.New().Volume.Delete(c.Args().First(), client.DefaultExecutionTimeout)
+		jobID, err := client.New().Volume.DeleteAsync(c.Args().First())
 		if err != nil {
 			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "deletion of volume", true))
 		}
+		err = waitJob(jobID, "deletion of volume")
+		if err != nil {
+			return err
+		}
 		fmt.Printf("Volume '%s' deleted\n", c.Args().First())
 
 		return nil
@@ -122,6 +177,7 @@ var volumeCreate = cli.Command{
 			Value: "HDD",
 			Usage: fmt.Sprintf("Allowed values: %s", getAllowedSpeeds()),
 		},
+		tagFlag,
 	},
 	Action: func(c *cli.Context) error {
 		if c.NArg() != 1 {
@@ -135,16 +191,36 @@ var volumeCreate = cli.Command{
 		if !ok {
 			return fmt.Errorf("Invalid speed '%s'", speed)
 		}
+		tags, err := creationTags(c)
+		if err != nil {
+			return err
+		}
 		def := pb.VolumeDefinition{
 			Name:  c.Args().First(),
 			Size:  int32(c.Int("size")),
 			Speed: pb.VolumeSpeed(volSpeed),
+			Tags:  tags,
 		}
 
-		volume, err := client.New().Volume.Create(def, client.DefaultExecutionTimeout)
+		jobID, err := client.New().Volume.CreateAsync(def)
 		if err != nil {
 			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "creation of volume", true))
 		}
+		err = waitJob(jobID, "creation of volume")
+		if err != nil {
+			return err
+		}
+		info, err := client.New().Volume.Inspect(def.GetName(), client.DefaultExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "inspection of volume", false))
+		}
+		volume := &pb.Volume{
+			ID:    info.GetID(),
+			Name:  info.GetName(),
+			Speed: info.GetSpeed(),
+			Size:  info.GetSize(),
+			Tags:  info.GetTags(),
+		}
 		out, _ := json.Marshal(toDisplaybleVolume(volume))
 		fmt.Println(string(out))
 
@@ -219,6 +295,7 @@ type volumeInfoDisplayable struct {
 	MountPath string
 	Format    string
 	Device    string
+	Tags      map[string]string
 }
 
 type volumeDisplayable struct {
@@ -226,6 +303,7 @@ type volumeDisplayable struct {
 	Name  string
 	Speed string
 	Size  int32
+	Tags  map[string]string
 }
 
 func toDisplaybleVolumeInfo(volumeInfo *pb.VolumeInfo) *volumeInfoDisplayable {
@@ -238,6 +316,7 @@ func toDisplaybleVolumeInfo(volumeInfo *pb.VolumeInfo) *volumeInfoDisplayable {
 		volumeInfo.GetMountPath(),
 		volumeInfo.GetFormat(),
 		volumeInfo.GetDevice(),
+		volumeInfo.GetTags(),
 	}
 }
 
@@ -247,6 +326,7 @@ func toDisplaybleVolume(volumeInfo *pb.Volume) *volumeDisplayable {
 		volumeInfo.GetName(),
 		pb.VolumeSpeed_name[int32(volumeInfo.GetSpeed())],
 		volumeInfo.GetSize(),
+		volumeInfo.GetTags(),
 	}
 }
 
@@ -263,3 +343,108 @@ func getAllowedSpeeds() string {
 	}
 	return speeds
 }
+
+var volumeSnapshot = cli.Command{
+	Name:  "snapshot",
+	Usage: "Manage the snapshots of volumes",
+	Subcommands: []cli.Command{
+		{
+			Name:      "create",
+			Usage:     "Create a snapshot of a volume",
+			ArgsUsage: "<Volume_name|Volume_ID>",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "name",
+					Usage: "Name of the snapshot (default: <Volume_name>-<date>-<time>)",
+				},
+				cli.BoolFlag{
+					Name:  "consistent",
+					Usage: "Freeze the filesystem of the volume while the snapshot is taken, if the volume is attached",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					fmt.Println("Missing mandatory argument <Volume_name|Volume_ID>")
+					cli.ShowSubcommandHelp(c)
+					return fmt.Errorf("Volume name or ID required")
+				}
+				snapshot, err := client.New().Volume.CreateSnapshot(c.Args().First(), c.String("name"), c.Bool("consistent"), client.DefaultExecutionTimeout)
+				if err != nil {
+					return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "creation of snapshot", false))
+				}
+				out, _ := json.Marshal(snapshot)
+				fmt.Println(string(out))
+				return nil
+			},
+		},
+		{
+			Name:      "list",
+			Usage:     "List the snapshots of a volume, or all the snapshots",
+			ArgsUsage: "[Volume_name|Volume_ID]",
+			Action: func(c *cli.Context) error {
+				resp, err := client.New().Volume.ListSnapshots(c.Args().First(), client.DefaultExecutionTimeout)
+				if err != nil {
+					return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "list of snapshots", false))
+				}
+				out, _ := json.Marshal(resp.GetSnapshots())
+				fmt.Println(string(out))
+				return nil
+			},
+		},
+		{
+			Name:      "delete",
+			Usage:     "Delete a snapshot",
+			ArgsUsage: "<Snapshot_name|Snapshot_ID>",
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					fmt.Println("Missing mandatory argument <Snapshot_name|Snapshot_ID>")
+					cli.ShowSubcommandHelp(c)
+					return fmt.Errorf("Snapshot name or ID required")
+				}
+				err := client.New().Volume.DeleteSnapshot(c.Args().First(), client.DefaultExecutionTimeout)
+				if err != nil {
+					return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "deletion of snapshot", false))
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "restore",
+			Usage:     "Create a new volume with the content of a snapshot",
+			ArgsUsage: "<Snapshot_name|Snapshot_ID> <Volume_name>",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "size",
+					Usage: "Size of the volume (in Go), at least the size of the snapshot (default: size of the snapshot)",
+				},
+				cli.StringFlag{
+					Name:  "speed",
+					Value: "HDD",
+					Usage: fmt.Sprintf("Allowed values: %s", getAllowedSpeeds()),
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 2 {
+					fmt.Println("Missing mandatory argument <Snapshot_name|Snapshot_ID> and/or <Volume_name>")
+					cli.ShowSubcommandHelp(c)
+					return fmt.Errorf("Snapshot and volume names required")
+				}
+				volSpeed, ok := pb.VolumeSpeed_value[c.String("speed")]
+				if !ok {
+					return fmt.Errorf("Invalid speed '%s'", c.String("speed"))
+				}
+				volume, err := client.New().Volume.RestoreSnapshot(c.Args().Get(0), pb.VolumeDefinition{
+					Name:  c.Args().Get(1),
+					Size:  int32(c.Int("size")),
+					Speed: pb.VolumeSpeed(volSpeed),
+				}, client.DefaultExecutionTimeout)
+				if err != nil {
+					return fmt.Errorf("Error response from daemon : %v", client.DecorateError(err, "restoration of snapshot", false))
+				}
+				out, _ := json.Marshal(toDisplaybleVolume(volume))
+				fmt.Println(string(out))
+				return nil
+			},
+		},
+	},
+}
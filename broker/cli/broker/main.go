@@ -92,6 +92,20 @@ func main() {
 	app.Commands = append(app.Commands, cmd.TemplateCmd)
 	sort.Sort(cli.CommandsByName(cmd.TemplateCmd.Subcommands))
 
+	app.Commands = append(app.Commands, cmd.JobCmd)
+	sort.Sort(cli.CommandsByName(cmd.JobCmd.Subcommands))
+
+	app.Commands = append(app.Commands, cmd.MetadataCmd)
+	sort.Sort(cli.CommandsByName(cmd.MetadataCmd.Subcommands))
+
+	app.Commands = append(app.Commands, cmd.DoctorCmd)
+
+	app.Commands = append(app.Commands, cmd.StackCmd)
+	sort.Sort(cli.CommandsByName(cmd.StackCmd.Subcommands))
+
+	app.Commands = append(app.Commands, cmd.SecurityGroupCmd)
+	sort.Sort(cli.CommandsByName(cmd.SecurityGroupCmd.Subcommands))
+
 	sort.Sort(cli.CommandsByName(app.Commands))
 	err := app.Run(os.Args)
 	if err != nil {
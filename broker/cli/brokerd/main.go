@@ -117,12 +117,16 @@ func main() {
 	pb.RegisterTenantServiceServer(s, &commands.TenantServiceServer{})
 	pb.RegisterNetworkServiceServer(s, &commands.NetworkServiceServer{})
 	pb.RegisterHostServiceServer(s, &commands.HostServiceServer{})
+	pb.RegisterFirewallServiceServer(s, &commands.FirewallServiceServer{})
+	pb.RegisterSecurityGroupServiceServer(s, &commands.SecurityGroupServiceServer{})
 	pb.RegisterVolumeServiceServer(s, &commands.VolumeServiceServer{})
 	pb.RegisterSshServiceServer(s, &commands.SSHServiceServer{})
 	pb.RegisterContainerServiceServer(s, &commands.ContainerServiceServer{})
 	pb.RegisterNasServiceServer(s, &commands.NasServiceServer{})
 	pb.RegisterImageServiceServer(s, &commands.ImageServiceServer{})
 	pb.RegisterTemplateServiceServer(s, &commands.TemplateServiceServer{})
+	pb.RegisterJobServiceServer(s, &commands.JobServiceServer{})
+	pb.RegisterMetadataServiceServer(s, &commands.MetadataServiceServer{})
 
 	// log.Println("Initializing service factory")
 	// commands.InitServiceFactory()
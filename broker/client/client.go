@@ -29,15 +29,19 @@ import (
 
 // Session units the different resources proposed by brokerd as broker client
 type Session struct {
-	Container *container
-	Host      *host
-	Nas       *nas
-	Network   *network
-	Ssh       *ssh
-	Tenant    *tenant
-	Volume    *volume
-	Template  *template
-	Image     *image
+	Container     *container
+	Firewall      *firewall
+	Host          *host
+	Nas           *nas
+	Network       *network
+	SecurityGroup *securityGroup
+	Ssh           *ssh
+	Tenant        *tenant
+	Volume        *volume
+	Template      *template
+	Image         *image
+	Job           *job
+	Metadata      *metadata
 
 	// For future use...
 	brokerdAddress string
@@ -58,14 +62,18 @@ const (
 func New() Client {
 	s := &Session{}
 	s.Container = &container{session: s}
+	s.Firewall = &firewall{session: s}
 	s.Host = &host{session: s}
 	s.Nas = &nas{session: s}
 	s.Network = &network{session: s}
+	s.SecurityGroup = &securityGroup{session: s}
 	s.Ssh = &ssh{session: s}
 	s.Tenant = &tenant{session: s}
 	s.Volume = &volume{session: s}
 	s.Template = &template{session: s}
 	s.Image = &image{session: s}
+	s.Job = &job{session: s}
+	s.Metadata = &metadata{session: s}
 	return s
 }
 
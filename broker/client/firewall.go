@@ -0,0 +1,71 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"time"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/utils"
+)
+
+// firewall is the part of broker client handling the firewall of the hosts
+type firewall struct {
+	// session is not used currently
+	session *Session
+}
+
+// List returns the chains and rules of the firewall of an host
+func (f *firewall) List(host string, timeout time.Duration) (*pb.FirewallRuleList, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewFirewallServiceClient(conn)
+	return service.List(ctx, &pb.Reference{Name: host})
+}
+
+// Add adds a rule to the firewall of an host
+func (f *firewall) Add(req pb.FirewallRuleRequest, timeout time.Duration) error {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewFirewallServiceClient(conn)
+	_, err := service.Add(ctx, &req)
+	return err
+}
+
+// Remove removes a rule from the firewall of an host
+func (f *firewall) Remove(req pb.FirewallRuleRequest, timeout time.Duration) error {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewFirewallServiceClient(conn)
+	_, err := service.Remove(ctx, &req)
+	return err
+}
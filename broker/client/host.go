@@ -34,7 +34,7 @@ type host struct {
 }
 
 // List ...
-func (h *host) List(all bool, timeout time.Duration) (*pb.HostList, error) {
+func (h *host) List(all bool, selector string, timeout time.Duration) (*pb.HostList, error) {
 	conn := utils.GetConnection()
 	defer conn.Close()
 	if timeout < utils.TimeoutCtxHost {
@@ -43,7 +43,20 @@ func (h *host) List(all bool, timeout time.Duration) (*pb.HostList, error) {
 	ctx, cancel := utils.GetContext(timeout)
 	defer cancel()
 	service := pb.NewHostServiceClient(conn)
-	return service.List(ctx, &pb.HostListRequest{All: all})
+	return service.List(ctx, &pb.HostListRequest{All: all, Selector: selector})
+}
+
+// ListOnNetwork lists the hosts attached to a network, including its gateway
+func (h *host) ListOnNetwork(network string, selector string, timeout time.Duration) (*pb.HostList, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxHost {
+		timeout = utils.TimeoutCtxHost
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewHostServiceClient(conn)
+	return service.List(ctx, &pb.HostListRequest{Network: network, Selector: selector})
 }
 
 // Inspect ...
@@ -140,3 +153,77 @@ func (h *host) SSHConfig(name string) (*pb.SshConfig, error) {
 	}
 	return sshCfg, err
 }
+
+// CreateAsync starts the creation of a host and returns the ID of the job
+func (h *host) CreateAsync(def pb.HostDefinition) (string, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	ctx, cancel := utils.GetContext(utils.TimeoutCtxDefault)
+	defer cancel()
+	service := pb.NewHostServiceClient(conn)
+	job, err := service.CreateAsync(ctx, &def)
+	if err != nil {
+		return "", err
+	}
+	return job.GetID(), nil
+}
+
+// DeleteAsync starts the deletion of a host and returns the ID of the job
+func (h *host) DeleteAsync(name string) (string, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	ctx, cancel := utils.GetContext(utils.TimeoutCtxDefault)
+	defer cancel()
+	service := pb.NewHostServiceClient(conn)
+	job, err := service.DeleteAsync(ctx, &pb.Reference{Name: name})
+	if err != nil {
+		return "", err
+	}
+	return job.GetID(), nil
+}
+
+// Import creates the metadata of a host not managed by SafeScale
+func (h *host) Import(def pb.HostImport, timeout time.Duration) (*pb.Host, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewHostServiceClient(conn)
+	return service.Import(ctx, &def)
+}
+
+// Tag adds or replaces the tags set and removes the tags remove of an host
+func (h *host) Tag(name string, set map[string]string, remove []string, timeout time.Duration) (*pb.Host, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewHostServiceClient(conn)
+	return service.Tag(ctx, &pb.TagUpdate{
+		Resource: &pb.Reference{Name: name},
+		Set:      set,
+		Remove:   remove,
+	})
+}
+
+// Rekey pins the key currently presented by the SSH server of an host, which must have the SHA256 fingerprint fingerprint
+func (h *host) Rekey(name string, fingerprint string, timeout time.Duration) (*pb.Host, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewHostServiceClient(conn)
+	return service.Rekey(ctx, &pb.HostRekey{
+		Ref:         name,
+		Fingerprint: fingerprint,
+	})
+}
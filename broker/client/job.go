@@ -0,0 +1,102 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"io"
+	"time"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/utils"
+	google_protobuf "github.com/golang/protobuf/ptypes/empty"
+)
+
+// job is the part of broker client handling jobs
+type job struct {
+	// session is not used currently
+	session *Session
+}
+
+// List ...
+func (j *job) List(timeout time.Duration) (*pb.JobList, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewJobServiceClient(conn)
+	return service.List(ctx, &google_protobuf.Empty{})
+}
+
+// Get ...
+func (j *job) Get(id string, timeout time.Duration) (*pb.Job, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewJobServiceClient(conn)
+	return service.Get(ctx, &pb.JobID{ID: id})
+}
+
+// Cancel ...
+func (j *job) Cancel(id string, timeout time.Duration) error {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewJobServiceClient(conn)
+	_, err := service.Cancel(ctx, &pb.JobID{ID: id})
+	return err
+}
+
+// Watch calls fn for each progress event of the job identified by id, until the end of the job
+// If the watch is interrupted (timeout or signal), the context error is returned
+func (j *job) Watch(id string, timeout time.Duration, fn func(*pb.JobEvent)) error {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxHost {
+		timeout = utils.TimeoutCtxHost
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewJobServiceClient(conn)
+	stream, err := service.Watch(ctx, &pb.JobID{ID: id})
+	if err != nil {
+		return err
+	}
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		fn(event)
+	}
+}
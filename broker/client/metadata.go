@@ -0,0 +1,97 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"time"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/utils"
+	google_protobuf "github.com/golang/protobuf/ptypes/empty"
+)
+
+// metadata is the part of broker client handling the metadata of the tenant
+type metadata struct {
+	// session is not used currently
+	session *Session
+}
+
+// ListLocks ...
+func (m *metadata) ListLocks(timeout time.Duration) (*pb.MetadataLockList, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewMetadataServiceClient(conn)
+	return service.ListLocks(ctx, &google_protobuf.Empty{})
+}
+
+// BreakLock ...
+func (m *metadata) BreakLock(key string, force bool, timeout time.Duration) error {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewMetadataServiceClient(conn)
+	_, err := service.BreakLock(ctx, &pb.MetadataLockBreak{Key: key, Force: force})
+	return err
+}
+
+// Reencrypt ...
+func (m *metadata) Reencrypt(timeout time.Duration) (*pb.MetadataReencryption, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewMetadataServiceClient(conn)
+	return service.Reencrypt(ctx, &google_protobuf.Empty{})
+}
+
+// Migrate ...
+func (m *metadata) Migrate(dryRun bool, timeout time.Duration) (*pb.MetadataMigrationList, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewMetadataServiceClient(conn)
+	return service.Migrate(ctx, &pb.MetadataMigrate{DryRun: dryRun})
+}
+
+// Doctor ...
+func (m *metadata) Doctor(repair bool, doImport bool, timeout time.Duration) (*pb.DoctorReport, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewMetadataServiceClient(conn)
+	return service.Doctor(ctx, &pb.DoctorRequest{Repair: repair, Import: doImport})
+}
@@ -21,8 +21,6 @@ import (
 
 	pb "github.com/CS-SI/SafeScale/broker"
 	utils "github.com/CS-SI/SafeScale/broker/utils"
-
-	google_protobuf "github.com/golang/protobuf/ptypes/empty"
 )
 
 // nas is the part of the broker client handing Nas
@@ -63,7 +61,7 @@ func (n *nas) Delete(name string, timeout time.Duration) error {
 }
 
 // List ...
-func (n *nas) List(timeout time.Duration) (*pb.NasList, error) {
+func (n *nas) List(selector string, timeout time.Duration) (*pb.NasList, error) {
 	conn := utils.GetConnection()
 	defer conn.Close()
 	if timeout < utils.TimeoutCtxDefault {
@@ -72,11 +70,11 @@ func (n *nas) List(timeout time.Duration) (*pb.NasList, error) {
 	ctx, cancel := utils.GetContext(timeout)
 	defer cancel()
 	service := pb.NewNasServiceClient(conn)
-	return service.List(ctx, &google_protobuf.Empty{})
+	return service.List(ctx, &pb.NasListRequest{Selector: selector})
 }
 
 // Mount ...
-func (n *nas) Mount(nasName, hostName, mountPoint string, timeout time.Duration) error {
+func (n *nas) Mount(nasName, hostName, mountPoint string, readOnly bool, securityMode string, timeout time.Duration) error {
 	conn := utils.GetConnection()
 	defer conn.Close()
 	if timeout < utils.TimeoutCtxDefault {
@@ -86,9 +84,11 @@ func (n *nas) Mount(nasName, hostName, mountPoint string, timeout time.Duration)
 	defer cancel()
 	service := pb.NewNasServiceClient(conn)
 	def := pb.NasDefinition{
-		Nas:  &pb.NasName{Name: nasName},
-		Host: &pb.Reference{Name: hostName},
-		Path: mountPoint,
+		Nas:          &pb.NasName{Name: nasName},
+		Host:         &pb.Reference{Name: hostName},
+		Path:         mountPoint,
+		ReadOnly:     readOnly,
+		SecurityMode: securityMode,
 	}
 
 	_, err := service.Mount(ctx, &def)
@@ -126,3 +126,65 @@ func (n *nas) Inspect(name string, timeout time.Duration) (*pb.NasList, error) {
 		Name: name,
 	})
 }
+
+// Tag adds or replaces the tags set and removes the tags remove of a nas
+func (n *nas) Tag(name string, set map[string]string, remove []string, timeout time.Duration) (*pb.NasDefinition, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewNasServiceClient(conn)
+	return service.Tag(ctx, &pb.TagUpdate{
+		Resource: &pb.Reference{Name: name},
+		Set:      set,
+		Remove:   remove,
+	})
+}
+
+// AddACL adds an ACL to the export of a nas, or replaces the ACL of the same host pattern
+func (n *nas) AddACL(name string, acl *pb.NasACL, timeout time.Duration) (*pb.NasDefinition, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxHost {
+		timeout = utils.TimeoutCtxHost
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewNasServiceClient(conn)
+	return service.AddACL(ctx, &pb.NasACLUpdate{
+		Nas: &pb.NasName{Name: name},
+		ACL: acl,
+	})
+}
+
+// RemoveACL removes the ACL of the host pattern from the export of a nas
+func (n *nas) RemoveACL(name, host string, timeout time.Duration) (*pb.NasDefinition, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxHost {
+		timeout = utils.TimeoutCtxHost
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewNasServiceClient(conn)
+	return service.RemoveACL(ctx, &pb.NasACLUpdate{
+		Nas: &pb.NasName{Name: name},
+		ACL: &pb.NasACL{Host: host},
+	})
+}
+
+// Failover makes the secondary server of a highly available nas take over from the active one
+func (n *nas) Failover(name string, timeout time.Duration) (*pb.NasDefinition, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxHost {
+		timeout = utils.TimeoutCtxHost
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewNasServiceClient(conn)
+	return service.Failover(ctx, &pb.NasName{Name: name})
+}
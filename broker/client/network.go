@@ -30,7 +30,7 @@ type network struct {
 }
 
 // List ...
-func (n *network) List(all bool, timeout time.Duration) (*pb.NetworkList, error) {
+func (n *network) List(all bool, selector string, timeout time.Duration) (*pb.NetworkList, error) {
 	conn := utils.GetConnection()
 	defer conn.Close()
 	if timeout < utils.TimeoutCtxDefault {
@@ -40,7 +40,8 @@ func (n *network) List(all bool, timeout time.Duration) (*pb.NetworkList, error)
 	defer cancel()
 	networkService := pb.NewNetworkServiceClient(conn)
 	return networkService.List(ctx, &pb.NWListRequest{
-		All: all,
+		All:      all,
+		Selector: selector,
 	})
 }
 
@@ -83,3 +84,61 @@ func (n *network) Create(def pb.NetworkDefinition, timeout time.Duration) (*pb.N
 	networkService := pb.NewNetworkServiceClient(conn)
 	return networkService.Create(ctx, &def)
 }
+
+// CreateAsync starts the creation of a network and returns the ID of the job
+func (n *network) CreateAsync(def pb.NetworkDefinition) (string, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	ctx, cancel := utils.GetContext(utils.TimeoutCtxDefault)
+	defer cancel()
+	networkService := pb.NewNetworkServiceClient(conn)
+	job, err := networkService.CreateAsync(ctx, &def)
+	if err != nil {
+		return "", err
+	}
+	return job.GetID(), nil
+}
+
+// DeleteAsync starts the deletion of a network and returns the ID of the job
+func (n *network) DeleteAsync(name string) (string, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	ctx, cancel := utils.GetContext(utils.TimeoutCtxDefault)
+	defer cancel()
+	networkService := pb.NewNetworkServiceClient(conn)
+	job, err := networkService.DeleteAsync(ctx, &pb.Reference{Name: name})
+	if err != nil {
+		return "", err
+	}
+	return job.GetID(), nil
+}
+
+// Import creates the metadata of a network not managed by SafeScale
+func (n *network) Import(def pb.NetworkImport, timeout time.Duration) (*pb.Network, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewNetworkServiceClient(conn)
+	return service.Import(ctx, &def)
+}
+
+// Tag adds or replaces the tags set and removes the tags remove of a network
+func (n *network) Tag(name string, set map[string]string, remove []string, timeout time.Duration) (*pb.Network, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewNetworkServiceClient(conn)
+	return service.Tag(ctx, &pb.TagUpdate{
+		Resource: &pb.Reference{Name: name},
+		Set:      set,
+		Remove:   remove,
+	})
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"time"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/utils"
+	google_protobuf "github.com/golang/protobuf/ptypes/empty"
+)
+
+// securityGroup is the part of broker client handling security groups
+type securityGroup struct {
+	// session is not used currently
+	session *Session
+}
+
+// Create ...
+func (sg *securityGroup) Create(def pb.SecurityGroupDefinition, timeout time.Duration) (*pb.SecurityGroup, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewSecurityGroupServiceClient(conn)
+	return service.Create(ctx, &def)
+}
+
+// List ...
+func (sg *securityGroup) List(timeout time.Duration) (*pb.SecurityGroupList, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewSecurityGroupServiceClient(conn)
+	return service.List(ctx, &google_protobuf.Empty{})
+}
+
+// Inspect ...
+func (sg *securityGroup) Inspect(name string, timeout time.Duration) (*pb.SecurityGroup, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewSecurityGroupServiceClient(conn)
+	return service.Inspect(ctx, &pb.Reference{Name: name})
+}
+
+// Delete ...
+func (sg *securityGroup) Delete(name string, timeout time.Duration) error {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewSecurityGroupServiceClient(conn)
+	_, err := service.Delete(ctx, &pb.Reference{Name: name})
+	return err
+}
+
+// AddRule ...
+func (sg *securityGroup) AddRule(name string, rule pb.SecurityGroupRule, timeout time.Duration) (*pb.SecurityGroupRule, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewSecurityGroupServiceClient(conn)
+	return service.AddRule(ctx, &pb.SecurityGroupRuleRequest{
+		Group: &pb.Reference{Name: name},
+		Rule:  &rule,
+	})
+}
+
+// DeleteRule ...
+func (sg *securityGroup) DeleteRule(name string, ruleID string, timeout time.Duration) error {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewSecurityGroupServiceClient(conn)
+	_, err := service.DeleteRule(ctx, &pb.SecurityGroupRuleRequest{
+		Group: &pb.Reference{Name: name},
+		Rule:  &pb.SecurityGroupRule{ID: ruleID},
+	})
+	return err
+}
@@ -17,9 +17,13 @@
 package client
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
 	pb "github.com/CS-SI/SafeScale/broker"
@@ -28,6 +32,7 @@ import (
 	"github.com/CS-SI/SafeScale/system"
 	"github.com/CS-SI/SafeScale/utils/retry"
 	"github.com/CS-SI/SafeScale/utils/retry/Verdict"
+	google_protobuf "github.com/golang/protobuf/ptypes/empty"
 )
 
 // ssh is the part of the broker client that handles SSH stuff
@@ -117,6 +122,73 @@ func (s *ssh) Run(hostName, command string, connectionTimeout, executionTimeout
 	return retcode, stdout, stderr, err
 }
 
+// Execute runs the command on the host through brokerd, writing its outputs to stdout and stderr as they are
+// produced. If stdin is not nil, it is sent to the command until its end; otherwise the command reads nothing.
+// Returns the exit status of the command
+func (s *ssh) Execute(hostName, command string, stdin io.Reader, stdout, stderr io.Writer, timeout time.Duration) (int, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxHost {
+		timeout = utils.TimeoutCtxHost
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewSshServiceClient(conn)
+	stream, err := service.Execute(ctx)
+	if err != nil {
+		return 0, err
+	}
+	err = stream.Send(&pb.SshInput{
+		Host:       &pb.Reference{Name: hostName},
+		Command:    command,
+		CloseStdin: stdin == nil,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if stdin != nil {
+		go sendStdin(stdin, stream)
+	}
+
+	for {
+		out, err := stream.Recv()
+		if err == io.EOF {
+			return 0, fmt.Errorf("no exit status received")
+		}
+		if err != nil {
+			return 0, err
+		}
+		if len(out.GetStdout()) > 0 {
+			stdout.Write(out.GetStdout())
+		}
+		if len(out.GetStderr()) > 0 {
+			stderr.Write(out.GetStderr())
+		}
+		if out.GetExited() {
+			return int(out.GetStatus()), nil
+		}
+	}
+}
+
+// sendStdin sends stdin to the command run by Execute, then closes it
+func sendStdin(stdin io.Reader, stream pb.SshService_ExecuteClient) {
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := stdin.Read(buffer)
+		if n > 0 {
+			chunk := make([]byte, n)
+			copy(chunk, buffer[:n])
+			if stream.Send(&pb.SshInput{Stdin: chunk}) != nil {
+				return
+			}
+		}
+		if err != nil {
+			stream.Send(&pb.SshInput{CloseStdin: true})
+			return
+		}
+	}
+}
+
 const protocolSeparator = ":"
 
 func extracthostName(in string) (string, error) {
@@ -289,3 +361,220 @@ func (s *ssh) WaitReady(hostName string, timeout time.Duration) error {
 	}
 	return conv.ToSystemSshConfig(cfg).WaitServerReady(timeout)
 }
+
+// Tunnel forwards the local port to the remote port reached from the host, until the process is interrupted or
+// the tunnel is killed; fn is called once the tunnel is opened
+func (s *ssh) Tunnel(hostName, local, remote string, fn func(*pb.SshTunnel)) error {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	ctx, cancel := utils.GetInterruptibleContext()
+	defer cancel()
+	service := pb.NewSshServiceClient(conn)
+	stream, err := service.Tunnel(ctx, &pb.SshTunnelDefinition{
+		Host:   &pb.Reference{Name: hostName},
+		Local:  local,
+		Remote: remote,
+	})
+	if err != nil {
+		return err
+	}
+	return receiveTunnel(ctx, stream, fn)
+}
+
+// Socks runs on the local port a SOCKS proxy opening its connections from the gateway of the network, until the
+// process is interrupted or the proxy is killed; fn is called once the proxy is opened
+func (s *ssh) Socks(networkName, local string, fn func(*pb.SshTunnel)) error {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	ctx, cancel := utils.GetInterruptibleContext()
+	defer cancel()
+	service := pb.NewSshServiceClient(conn)
+	stream, err := service.Socks(ctx, &pb.SshSocksDefinition{
+		Network: &pb.Reference{Name: networkName},
+		Local:   local,
+	})
+	if err != nil {
+		return err
+	}
+	return receiveTunnel(ctx, stream, fn)
+}
+
+// receiveTunnel calls fn with the tunnel sent by brokerd, and waits for the end of the call
+// An interruption isn't an error
+func receiveTunnel(ctx context.Context, stream interface {
+	Recv() (*pb.SshTunnel, error)
+}, fn func(*pb.SshTunnel)) error {
+	for {
+		tunnel, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		fn(tunnel)
+	}
+}
+
+// ListTunnels ...
+func (s *ssh) ListTunnels(timeout time.Duration) (*pb.SshTunnelList, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewSshServiceClient(conn)
+	return service.ListTunnels(ctx, &google_protobuf.Empty{})
+}
+
+// KillTunnel ...
+func (s *ssh) KillTunnel(id string, timeout time.Duration) error {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewSshServiceClient(conn)
+	_, err := service.KillTunnel(ctx, &pb.SshTunnelID{ID: id})
+	return err
+}
+
+// HostRunResult is the result of a command run on one of the hosts given to RunOnHosts
+type HostRunResult struct {
+	Host   string `json:"host"`
+	Status int    `json:"status"`
+	// Error explains why the command couldn't be run, the status is then 255
+	Error string `json:"error,omitempty"`
+}
+
+// RunOnHosts runs command on the hosts, at most fanout at a time, and returns the results in the order of hosts
+// Each line written by the commands is passed to output as soon as produced; the calls to output are serialized
+// The connections failing with status 255 are retried during connectionTimeout
+func (s *ssh) RunOnHosts(hostNames []string, command string, fanout int, connectionTimeout, executionTimeout time.Duration, output func(host string, line string, stderr bool)) []HostRunResult {
+	if executionTimeout < utils.TimeoutCtxHost {
+		executionTimeout = utils.TimeoutCtxHost
+	}
+	if connectionTimeout < DefaultConnectionTimeout {
+		connectionTimeout = DefaultConnectionTimeout
+	}
+	if fanout < 1 {
+		fanout = 1
+	}
+	ctx, cancel := utils.GetContext(executionTimeout)
+	defer cancel()
+
+	var outputLock sync.Mutex
+	serialized := func(host string, line string, stderr bool) {
+		outputLock.Lock()
+		defer outputLock.Unlock()
+		output(host, line, stderr)
+	}
+
+	results := make([]HostRunResult, len(hostNames))
+	slots := make(chan struct{}, fanout)
+	var wg sync.WaitGroup
+	for i, hostName := range hostNames {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, hostName string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = s.runOnHost(ctx, hostName, command, connectionTimeout, serialized)
+		}(i, hostName)
+	}
+	wg.Wait()
+	return results
+}
+
+// runOnHost runs command on an host for RunOnHosts
+func (s *ssh) runOnHost(ctx context.Context, hostName, command string, connectionTimeout time.Duration, output func(host string, line string, stderr bool)) HostRunResult {
+	result := HostRunResult{Host: hostName, Status: 255}
+	if ctx.Err() != nil {
+		result.Error = ctx.Err().Error()
+		return result
+	}
+
+	host := &host{session: s.session}
+	cfg, err := host.SSHConfig(hostName)
+	if err != nil {
+		result.Error = DecorateError(err, "ssh config of host", false).Error()
+		return result
+	}
+	ssh := conv.ToSystemSshConfig(cfg)
+
+	stdout := newLineWriter(func(line string) { output(hostName, line, false) })
+	stderr := newLineWriter(func(line string) { output(hostName, line, true) })
+	// Only the connection is retried: a command interrupted once started may already have had effects and written
+	// outputs, it's not run again
+	var sshCmd *system.SSHCommand
+	err = retry.WhileUnsuccessful255WithNotify(
+		func() error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			var err error
+			sshCmd, err = ssh.CommandContext(ctx, command)
+			if err != nil {
+				return err
+			}
+			return sshCmd.Connect()
+		},
+		time.Second,
+		connectionTimeout,
+		func(t retry.Try, v Verdict.Enum) {
+			if v == Verdict.Retry {
+				log.Printf("Remote SSH service on host '%s' isn't ready, retrying...\n", hostName)
+			}
+		},
+	)
+	if err == nil {
+		result.Status, err = sshCmd.Stream(nil, stdout, stderr)
+	}
+	stdout.Close()
+	stderr.Close()
+	if err != nil {
+		result.Status = 255
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// lineWriter calls a function for each line written
+type lineWriter struct {
+	buffer []byte
+	fn     func(line string)
+}
+
+func newLineWriter(fn func(line string)) *lineWriter {
+	return &lineWriter{fn: fn}
+}
+
+// Write implements io.Writer
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buffer = append(w.buffer, p...)
+	for {
+		i := bytes.IndexByte(w.buffer, '\n')
+		if i < 0 {
+			break
+		}
+		w.fn(string(w.buffer[:i]))
+		w.buffer = w.buffer[i+1:]
+	}
+	return len(p), nil
+}
+
+// Close passes the last line, if not terminated by a newline
+func (w *lineWriter) Close() error {
+	if len(w.buffer) > 0 {
+		w.fn(string(w.buffer))
+		w.buffer = nil
+	}
+	return nil
+}
@@ -30,7 +30,7 @@ type volume struct {
 }
 
 // List ...
-func (v *volume) List(all bool, timeout time.Duration) (*pb.VolumeList, error) {
+func (v *volume) List(all bool, selector string, timeout time.Duration) (*pb.VolumeList, error) {
 	conn := utils.GetConnection()
 	defer conn.Close()
 	if timeout < utils.TimeoutCtxDefault {
@@ -40,7 +40,8 @@ func (v *volume) List(all bool, timeout time.Duration) (*pb.VolumeList, error) {
 	defer cancel()
 	service := pb.NewVolumeServiceClient(conn)
 	return service.List(ctx, &pb.VolumeListRequest{
-		All: all,
+		All:      all,
+		Selector: selector,
 	})
 }
 
@@ -114,3 +115,121 @@ func (v *volume) Detach(volumeName string, hostName string, timeout time.Duratio
 	})
 	return err
 }
+
+// CreateAsync starts the creation of a volume and returns the ID of the job
+func (v *volume) CreateAsync(def pb.VolumeDefinition) (string, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	ctx, cancel := utils.GetContext(utils.TimeoutCtxDefault)
+	defer cancel()
+	service := pb.NewVolumeServiceClient(conn)
+	job, err := service.CreateAsync(ctx, &def)
+	if err != nil {
+		return "", err
+	}
+	return job.GetID(), nil
+}
+
+// DeleteAsync starts the deletion of a volume and returns the ID of the job
+func (v *volume) DeleteAsync(name string) (string, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	ctx, cancel := utils.GetContext(utils.TimeoutCtxDefault)
+	defer cancel()
+	service := pb.NewVolumeServiceClient(conn)
+	job, err := service.DeleteAsync(ctx, &pb.Reference{Name: name})
+	if err != nil {
+		return "", err
+	}
+	return job.GetID(), nil
+}
+
+// Import creates the metadata of a volume not managed by SafeScale
+func (v *volume) Import(name string, timeout time.Duration) (*pb.Volume, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewVolumeServiceClient(conn)
+	return service.Import(ctx, &pb.Reference{Name: name})
+}
+
+// Tag adds or replaces the tags set and removes the tags remove of a volume
+func (v *volume) Tag(name string, set map[string]string, remove []string, timeout time.Duration) (*pb.Volume, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewVolumeServiceClient(conn)
+	return service.Tag(ctx, &pb.TagUpdate{
+		Resource: &pb.Reference{Name: name},
+		Set:      set,
+		Remove:   remove,
+	})
+}
+
+// CreateSnapshot creates a snapshot of a volume, freezing its filesystem during the snapshot if consistent
+func (v *volume) CreateSnapshot(volumeName, name string, consistent bool, timeout time.Duration) (*pb.Snapshot, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewVolumeServiceClient(conn)
+	return service.CreateSnapshot(ctx, &pb.SnapshotDefinition{
+		Volume:     &pb.Reference{Name: volumeName},
+		Name:       name,
+		Consistent: consistent,
+	})
+}
+
+// ListSnapshots lists the snapshots of a volume, or all the snapshots if volumeName is empty
+func (v *volume) ListSnapshots(volumeName string, timeout time.Duration) (*pb.SnapshotList, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewVolumeServiceClient(conn)
+	return service.ListSnapshots(ctx, &pb.Reference{Name: volumeName})
+}
+
+// DeleteSnapshot deletes a snapshot
+func (v *volume) DeleteSnapshot(name string, timeout time.Duration) error {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewVolumeServiceClient(conn)
+	_, err := service.DeleteSnapshot(ctx, &pb.Reference{Name: name})
+	return err
+}
+
+// RestoreSnapshot creates the volume def with the content of a snapshot
+func (v *volume) RestoreSnapshot(name string, def pb.VolumeDefinition, timeout time.Duration) (*pb.Volume, error) {
+	conn := utils.GetConnection()
+	defer conn.Close()
+	if timeout < utils.TimeoutCtxDefault {
+		timeout = utils.TimeoutCtxDefault
+	}
+	ctx, cancel := utils.GetContext(timeout)
+	defer cancel()
+	service := pb.NewVolumeServiceClient(conn)
+	return service.RestoreSnapshot(ctx, &pb.SnapshotRestore{
+		Snapshot: &pb.Reference{Name: name},
+		Volume:   &def,
+	})
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/daemon/services"
+	"github.com/CS-SI/SafeScale/broker/utils"
+	conv "github.com/CS-SI/SafeScale/broker/utils"
+	"github.com/CS-SI/SafeScale/system/firewall"
+	google_protobuf "github.com/golang/protobuf/ptypes/empty"
+	log "github.com/sirupsen/logrus"
+)
+
+// broker host firewall list gw-net1
+// broker host firewall add gw-net1 --chain INPUT --protocol tcp --to-port 22 --action ACCEPT
+// broker host firewall remove gw-net1 --chain INPUT --index 3
+
+// FirewallServiceServer firewall service server grpc
+type FirewallServiceServer struct{}
+
+// List returns the chains and rules of the firewall of an host
+func (s *FirewallServiceServer) List(ctx context.Context, in *pb.Reference) (*pb.FirewallRuleList, error) {
+	log.Printf("List firewall rules called '%s'", in.GetName())
+
+	ref := utils.GetReference(in)
+	if ref == "" {
+		return nil, fmt.Errorf("Cannot list firewall rules : Neither name nor id given as reference")
+	}
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot list firewall rules : No tenant set")
+	}
+
+	service := services.NewFirewallService(currentTenant.Client)
+	fw, err := service.List(ref)
+	if err != nil {
+		return nil, err
+	}
+	return conv.ToPBFirewallRuleList(fw), nil
+}
+
+// Add adds a rule to the firewall of an host
+func (s *FirewallServiceServer) Add(ctx context.Context, in *pb.FirewallRuleRequest) (*google_protobuf.Empty, error) {
+	log.Printf("Add firewall rule called '%s'", in.GetHost().GetName())
+
+	ref := utils.GetReference(in.GetHost())
+	if ref == "" {
+		return nil, fmt.Errorf("Cannot add firewall rule : Neither name nor id given as reference")
+	}
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot add firewall rule : No tenant set")
+	}
+
+	rule, err := conv.ToFirewallRule(in.GetRule())
+	if err != nil {
+		return nil, fmt.Errorf("Cannot add firewall rule : %s", err.Error())
+	}
+	service := services.NewFirewallService(currentTenant.Client)
+	err = service.Add(ref, in.GetRule().GetTable(), in.GetRule().GetChain(), rule, in.GetInsert())
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Firewall rule '%s' added to host '%s'", rule.String(), ref)
+	return &google_protobuf.Empty{}, nil
+}
+
+// Remove removes a rule from the firewall of an host
+func (s *FirewallServiceServer) Remove(ctx context.Context, in *pb.FirewallRuleRequest) (*google_protobuf.Empty, error) {
+	log.Printf("Remove firewall rule called '%s'", in.GetHost().GetName())
+
+	ref := utils.GetReference(in.GetHost())
+	if ref == "" {
+		return nil, fmt.Errorf("Cannot remove firewall rule : Neither name nor id given as reference")
+	}
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot remove firewall rule : No tenant set")
+	}
+
+	service := services.NewFirewallService(currentTenant.Client)
+	if in.GetIndex() > 0 {
+		err := service.Remove(ref, in.GetRule().GetTable(), in.GetRule().GetChain(), firewall.Rule{}, int(in.GetIndex()))
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Firewall rule %d removed from host '%s'", in.GetIndex(), ref)
+		return &google_protobuf.Empty{}, nil
+	}
+
+	rule, err := conv.ToFirewallRule(in.GetRule())
+	if err != nil {
+		return nil, fmt.Errorf("Cannot remove firewall rule : %s", err.Error())
+	}
+	err = service.Remove(ref, in.GetRule().GetTable(), in.GetRule().GetChain(), rule, 0)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Firewall rule '%s' removed from host '%s'", rule.String(), ref)
+	return &google_protobuf.Empty{}, nil
+}
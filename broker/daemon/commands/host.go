@@ -20,15 +20,19 @@ import (
 	"context"
 	"fmt"
 	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/daemon/jobs"
 	"github.com/CS-SI/SafeScale/broker/daemon/services"
 	"github.com/CS-SI/SafeScale/broker/utils"
 	conv "github.com/CS-SI/SafeScale/broker/utils"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/filters/tags"
 	google_protobuf "github.com/golang/protobuf/ptypes/empty"
 	log "github.com/sirupsen/logrus"
 )
 
 // broker host create host1 --net="net1" --cpu=2 --ram=7 --disk=100 --os="Ubuntu 16.04" --public=true
 // broker host list --all=false
+// broker host list --net net1
 // broker host inspect host1
 // broker host create host2 --net="net1" --cpu=2 --ram=7 --disk=100 --os="Ubuntu 16.04" --public=false
 
@@ -101,9 +105,19 @@ func (s *HostServiceServer) List(ctx context.Context, in *pb.HostListRequest) (*
 		return nil, fmt.Errorf("Cannot list hosts : No tenant set")
 	}
 
+	selector, err := tags.ParseSelector(in.GetSelector())
+	if err != nil {
+		return nil, fmt.Errorf("Cannot list hosts : %s", err.Error())
+	}
+
 	hostAPI := services.NewHostService(currentTenant.Client)
 
-	hosts, err := hostAPI.List(in.GetAll())
+	var hosts []api.Host
+	if in.GetNetwork() != "" {
+		hosts, err = hostAPI.ListOnNetwork(in.GetNetwork())
+	} else {
+		hosts, err = hostAPI.List(in.GetAll())
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -112,6 +126,9 @@ func (s *HostServiceServer) List(ctx context.Context, in *pb.HostListRequest) (*
 
 	// Map api.Host to pb.Host
 	for _, host := range hosts {
+		if !selector.Matches(host.Tags) {
+			continue
+		}
 		pbhost = append(pbhost, conv.ToPBHost(&host))
 	}
 	rv := &pb.HostList{Hosts: pbhost}
@@ -125,16 +142,48 @@ func (s *HostServiceServer) Create(ctx context.Context, in *pb.HostDefinition) (
 		return nil, fmt.Errorf("Cannot create host : No tenant set")
 	}
 
-	hostService := services.NewHostService(currentTenant.Client)
-	host, err := hostService.Create(in.GetName(), in.GetNetwork(),
-		int(in.GetCPUNumber()), in.GetRAM(), int(in.GetDisk()), in.GetImageID(), in.GetPublic())
-
+	var host *pb.Host
+	err := jobRegistry.Run(ctx, "host creation", in.GetName(), createHost(currentTenant, in, &host))
 	if err != nil {
 		return nil, err
 	}
 
 	log.Printf("Host '%s' created", in.GetName())
-	return conv.ToPBHost(host), nil
+	return host, nil
+}
+
+// CreateAsync starts the creation of a new host and returns the ID of the job
+func (s *HostServiceServer) CreateAsync(ctx context.Context, in *pb.HostDefinition) (*pb.JobID, error) {
+	log.Printf("Create host (async) called '%s'", in.Name)
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot create host : No tenant set")
+	}
+
+	var host *pb.Host
+	job := jobRegistry.Start("host creation", in.GetName(), createHost(currentTenant, in, &host))
+	return &pb.JobID{ID: job.ID}, nil
+}
+
+// createHost returns the job creating the host defined by in on tenant, the host is stored in out
+func createHost(tenant *Tenant, in *pb.HostDefinition, out **pb.Host) func(context.Context) error {
+	hostService := services.NewHostService(tenant.Client)
+	return func(ctx context.Context) error {
+		host, err := hostService.Create(ctx, in.GetName(), in.GetNetwork(),
+			int(in.GetCPUNumber()), in.GetRAM(), int(in.GetDisk()), in.GetImageID(), in.GetPublic(), in.GetSecurityGroups())
+		if err != nil {
+			return err
+		}
+		if len(in.GetTags()) > 0 {
+			jobs.Step(ctx, "Tagging host '%s'", host.Name)
+			host, err = hostService.SetTags(host.ID, in.GetTags(), nil)
+			if err != nil {
+				return err
+			}
+		}
+		*out = conv.ToPBHost(host)
+		jobs.SetResult(ctx, *out)
+		return nil
+	}
 }
 
 // Inspect an host
@@ -174,8 +223,7 @@ func (s *HostServiceServer) Delete(ctx context.Context, in *pb.Reference) (*goog
 	if GetCurrentTenant() == nil {
 		return nil, fmt.Errorf("Cannot delete host : No tenant set")
 	}
-	hostService := services.NewHostService(currentTenant.Client)
-	err := hostService.Delete(ref)
+	err := jobRegistry.Run(ctx, "host deletion", ref, deleteHost(currentTenant, ref))
 	if err != nil {
 		return nil, err
 	}
@@ -183,6 +231,31 @@ func (s *HostServiceServer) Delete(ctx context.Context, in *pb.Reference) (*goog
 	return &google_protobuf.Empty{}, nil
 }
 
+// DeleteAsync starts the deletion of an host and returns the ID of the job
+func (s *HostServiceServer) DeleteAsync(ctx context.Context, in *pb.Reference) (*pb.JobID, error) {
+	log.Printf("Delete Host (async) called '%s'", in.Name)
+
+	ref := utils.GetReference(in)
+	if ref == "" {
+		return nil, fmt.Errorf("Cannot delete host : Neither name nor id given as reference")
+	}
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot delete host : No tenant set")
+	}
+	job := jobRegistry.Start("host deletion", ref, deleteHost(currentTenant, ref))
+	return &pb.JobID{ID: job.ID}, nil
+}
+
+// deleteHost returns the job deleting the host referenced by ref on tenant
+func deleteHost(tenant *Tenant, ref string) func(context.Context) error {
+	hostService := services.NewHostService(tenant.Client)
+	return func(ctx context.Context) error {
+		jobs.Step(ctx, "Deleting host '%s'", ref)
+		return hostService.Delete(ref)
+	}
+}
+
 // SSH returns ssh parameters to access an host
 func (s *HostServiceServer) SSH(ctx context.Context, in *pb.Reference) (*pb.SshConfig, error) {
 	log.Printf("Ssh Host called '%s'", in.Name)
@@ -203,3 +276,68 @@ func (s *HostServiceServer) SSH(ctx context.Context, in *pb.Reference) (*pb.SshC
 	log.Printf("Got Ssh config for host '%s'", ref)
 	return conv.ToPBSshConfig(sshConfig), nil
 }
+
+// Import creates the metadata of a host not managed by SafeScale
+func (s *HostServiceServer) Import(ctx context.Context, in *pb.HostImport) (*pb.Host, error) {
+	log.Printf("Import Host called '%s'", in.GetRef())
+
+	if in.GetRef() == "" {
+		return nil, fmt.Errorf("Cannot import host : Neither name nor id given as reference")
+	}
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot import host : No tenant set")
+	}
+
+	hostService := services.NewHostService(currentTenant.Client)
+	host, err := hostService.Import(in.GetRef(), in.GetNetwork(), in.GetPrivateKey())
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Host '%s' imported", host.Name)
+	return conv.ToPBHost(host), nil
+}
+
+// Tag adds, replaces or removes tags of an host
+func (s *HostServiceServer) Tag(ctx context.Context, in *pb.TagUpdate) (*pb.Host, error) {
+	log.Printf("Tag Host called '%s'", in.GetResource().GetName())
+
+	ref := utils.GetReference(in.GetResource())
+	if ref == "" {
+		return nil, fmt.Errorf("Cannot tag host : Neither name nor id given as reference")
+	}
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot tag host : No tenant set")
+	}
+
+	hostService := services.NewHostService(currentTenant.Client)
+	host, err := hostService.SetTags(ref, in.GetSet(), in.GetRemove())
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Host '%s' tagged", host.Name)
+	return conv.ToPBHost(host), nil
+}
+
+// Rekey pins the key currently presented by the SSH server of an host
+func (s *HostServiceServer) Rekey(ctx context.Context, in *pb.HostRekey) (*pb.Host, error) {
+	log.Printf("Rekey Host called '%s'", in.GetRef())
+
+	ref := in.GetRef()
+	if ref == "" {
+		return nil, fmt.Errorf("Cannot rekey host : Neither name nor id given as reference")
+	}
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot rekey host : No tenant set")
+	}
+
+	hostService := services.NewHostService(currentTenant.Client)
+	host, err := hostService.Rekey(ref, in.GetFingerprint())
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Host key of '%s' pinned", host.Name)
+	return conv.ToPBHost(host), nil
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/daemon/jobs"
+	conv "github.com/CS-SI/SafeScale/broker/utils"
+	google_protobuf "github.com/golang/protobuf/ptypes/empty"
+	log "github.com/sirupsen/logrus"
+)
+
+// broker job list
+// broker job inspect <job_id>
+// broker job watch <job_id>
+// broker job cancel <job_id>
+
+// jobRegistry keeps track of the create/delete operations run by brokerd
+var jobRegistry = jobs.NewRegistry()
+
+// JobServiceServer job service server grpc
+type JobServiceServer struct{}
+
+// Get returns the job identified by its ID
+func (s *JobServiceServer) Get(ctx context.Context, in *pb.JobID) (*pb.Job, error) {
+	log.Printf("Get Job called '%s'", in.GetID())
+
+	job, err := jobRegistry.Get(in.GetID())
+	if err != nil {
+		return nil, err
+	}
+	return conv.ToPBJob(job), nil
+}
+
+// List returns the jobs known by brokerd
+func (s *JobServiceServer) List(ctx context.Context, in *google_protobuf.Empty) (*pb.JobList, error) {
+	log.Printf("List Job called")
+
+	var pbjobs []*pb.Job
+	for _, job := range jobRegistry.List() {
+		pbjobs = append(pbjobs, conv.ToPBJob(&job))
+	}
+	return &pb.JobList{Jobs: pbjobs}, nil
+}
+
+// Cancel cancels a running job
+func (s *JobServiceServer) Cancel(ctx context.Context, in *pb.JobID) (*google_protobuf.Empty, error) {
+	log.Printf("Cancel Job called '%s'", in.GetID())
+
+	err := jobRegistry.Cancel(in.GetID())
+	if err != nil {
+		return nil, fmt.Errorf("Cannot cancel job : %s", err.Error())
+	}
+	return &google_protobuf.Empty{}, nil
+}
+
+// Watch streams the progress of a job until its end
+func (s *JobServiceServer) Watch(in *pb.JobID, stream pb.JobService_WatchServer) error {
+	log.Printf("Watch Job called '%s'", in.GetID())
+
+	return jobRegistry.Watch(stream.Context(), in.GetID(), func(e jobs.Event) error {
+		return stream.Send(conv.ToPBJobEvent(&e))
+	})
+}
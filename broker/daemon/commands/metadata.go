@@ -0,0 +1,136 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/daemon/services"
+	conv "github.com/CS-SI/SafeScale/broker/utils"
+	google_protobuf "github.com/golang/protobuf/ptypes/empty"
+	log "github.com/sirupsen/logrus"
+)
+
+// broker metadata locks
+// broker metadata locks --break hosts
+// broker metadata reencrypt
+// broker metadata migrate --dry-run
+// broker doctor --repair --import
+
+// MetadataServiceServer metadata service server grpc
+type MetadataServiceServer struct{}
+
+// ListLocks lists the locks stored in the metadata bucket
+func (s *MetadataServiceServer) ListLocks(ctx context.Context, in *google_protobuf.Empty) (*pb.MetadataLockList, error) {
+	log.Printf("Metadata ListLocks called")
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot list metadata locks : No tenant set")
+	}
+
+	service := services.NewMetadataService(currentTenant.Client)
+	leases, err := service.ListLocks()
+	if err != nil {
+		return nil, err
+	}
+
+	var pbLocks []*pb.MetadataLock
+	for _, lease := range leases {
+		pbLocks = append(pbLocks, conv.ToPBMetadataLock(&lease))
+	}
+	return &pb.MetadataLockList{Locks: pbLocks}, nil
+}
+
+// BreakLock removes a lock from the metadata bucket
+func (s *MetadataServiceServer) BreakLock(ctx context.Context, in *pb.MetadataLockBreak) (*google_protobuf.Empty, error) {
+	log.Printf("Metadata BreakLock called '%s'", in.GetKey())
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot break metadata lock : No tenant set")
+	}
+
+	service := services.NewMetadataService(currentTenant.Client)
+	err := service.BreakLock(in.GetKey(), in.GetForce())
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Metadata lock '%s' broken", in.GetKey())
+	return &google_protobuf.Empty{}, nil
+}
+
+// Reencrypt rewrites all the metadata with the current key of the tenant
+func (s *MetadataServiceServer) Reencrypt(ctx context.Context, in *google_protobuf.Empty) (*pb.MetadataReencryption, error) {
+	log.Printf("Metadata Reencrypt called")
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot reencrypt metadata : No tenant set")
+	}
+
+	service := services.NewMetadataService(currentTenant.Client)
+	count, err := service.Reencrypt()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot reencrypt metadata : %d objects rewritten before failure : %s", count, err.Error())
+	}
+	log.Printf("Metadata reencrypted, %d objects rewritten", count)
+	return &pb.MetadataReencryption{Count: int32(count)}, nil
+}
+
+// Migrate upgrades all the metadata to the current version of their format
+func (s *MetadataServiceServer) Migrate(ctx context.Context, in *pb.MetadataMigrate) (*pb.MetadataMigrationList, error) {
+	log.Printf("Metadata Migrate called (dry run: %v)", in.GetDryRun())
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot migrate metadata : No tenant set")
+	}
+
+	service := services.NewMetadataService(currentTenant.Client)
+	migrations, err := service.Migrate(in.GetDryRun())
+	if err != nil {
+		return nil, fmt.Errorf("Cannot migrate metadata : %s", err.Error())
+	}
+
+	var pbMigrations []*pb.MetadataMigration
+	for _, migration := range migrations {
+		pbMigrations = append(pbMigrations, conv.ToPBMetadataMigration(&migration))
+	}
+	log.Printf("Metadata migrated, %d objects upgraded", len(migrations))
+	return &pb.MetadataMigrationList{Migrations: pbMigrations}, nil
+}
+
+// Doctor cross-checks the metadata against the resources of the provider
+func (s *MetadataServiceServer) Doctor(ctx context.Context, in *pb.DoctorRequest) (*pb.DoctorReport, error) {
+	log.Printf("Metadata Doctor called (repair: %v, import: %v)", in.GetRepair(), in.GetImport())
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot check metadata : No tenant set")
+	}
+
+	service := services.NewMetadataService(currentTenant.Client)
+	findings, err := service.Doctor(in.GetRepair(), in.GetImport())
+	if err != nil {
+		return nil, fmt.Errorf("Cannot check metadata : %s", err.Error())
+	}
+
+	var pbFindings []*pb.DoctorFinding
+	for _, finding := range findings {
+		pbFindings = append(pbFindings, conv.ToPBDoctorFinding(&finding))
+	}
+	log.Printf("Metadata checked, %d inconsistencies found", len(findings))
+	return &pb.DoctorReport{Findings: pbFindings}, nil
+}
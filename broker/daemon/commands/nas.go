@@ -21,20 +21,25 @@ import (
 	"fmt"
 
 	"github.com/CS-SI/SafeScale/broker/daemon/services"
-	google_protobuf "github.com/golang/protobuf/ptypes/empty"
+	"github.com/CS-SI/SafeScale/providers/api"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
 	pb "github.com/CS-SI/SafeScale/broker"
 	convert "github.com/CS-SI/SafeScale/broker/utils"
+	"github.com/CS-SI/SafeScale/providers/filters/tags"
 )
 
 // broker nas create nas1 host1 --path="/shared/data"
+// broker nas create nas1 host1 --secondary host2 --volume-size 100
 // broker nas delete nas1
 // broker nas mount nas1 host2 --path="/data"
 // broker nas umount nas1 host2
 // broker nas list
 // broker nas inspect nas1
+// broker nas acl add nas1 "10.0.0.12(rw,no_root_squash)"
+// broker nas acl remove nas1 10.0.0.12
+// broker nas failover nas1
 
 //NasServiceServer NAS service server grpc
 type NasServiceServer struct{}
@@ -47,13 +52,31 @@ func (s *NasServiceServer) Create(ctx context.Context, in *pb.NasDefinition) (*p
 	}
 
 	nasService := services.NewNasService(currentTenant.Client)
-	nas, err := nasService.Create(in.GetNas().GetName(), in.GetHost().GetName(), in.GetPath())
+	var acls []api.NasACL
+	for _, acl := range in.GetACLs() {
+		acls = append(acls, convert.ToAPINasACL(acl))
+	}
+	var nas *api.Nas
+	var err error
+	if in.GetSecondary().GetName() != "" {
+		nas, err = nasService.CreateHA(in.GetNas().GetName(), in.GetHost().GetName(), in.GetSecondary().GetName(), in.GetPath(), int(in.GetVolumeSize()), acls)
+	} else {
+		nas, err = nasService.Create(in.GetNas().GetName(), in.GetHost().GetName(), in.GetPath(), acls)
+	}
 
 	if err != nil {
 		tbr := errors.Wrap(err, "Cannot create NAS")
 		return nil, tbr
 	}
 
+	if len(in.GetTags()) > 0 {
+		nas, err = nasService.SetTags(nas.Name, in.GetTags(), nil)
+		if err != nil {
+			tbr := errors.Wrap(err, "Cannot tag NAS")
+			return nil, tbr
+		}
+	}
+
 	return convert.ToPBNas(nas), err
 }
 
@@ -80,12 +103,17 @@ func (s *NasServiceServer) Delete(ctx context.Context, in *pb.NasName) (*pb.NasD
 }
 
 //List return the list of all available nas
-func (s *NasServiceServer) List(ctx context.Context, in *google_protobuf.Empty) (*pb.NasList, error) {
+func (s *NasServiceServer) List(ctx context.Context, in *pb.NasListRequest) (*pb.NasList, error) {
 	log.Printf("List NAS called")
 	if GetCurrentTenant() == nil {
 		return nil, fmt.Errorf("Cannot list NAS : No tenant set")
 	}
 
+	selector, err := tags.ParseSelector(in.GetSelector())
+	if err != nil {
+		return nil, fmt.Errorf("Cannot list NAS : %s", err.Error())
+	}
+
 	nasService := services.NewNasService(currentTenant.Client)
 	nass, err := nasService.List()
 
@@ -98,6 +126,9 @@ func (s *NasServiceServer) List(ctx context.Context, in *google_protobuf.Empty)
 
 	// Map api.Network to pb.Network
 	for _, nas := range nass {
+		if !selector.Matches(nas.Tags) {
+			continue
+		}
 		pbnass = append(pbnass, convert.ToPBNas(&nas))
 	}
 	rv := &pb.NasList{NasList: pbnass}
@@ -112,7 +143,7 @@ func (s *NasServiceServer) Mount(ctx context.Context, in *pb.NasDefinition) (*pb
 	}
 
 	nasService := services.NewNasService(currentTenant.Client)
-	nas, err := nasService.Mount(in.GetNas().GetName(), in.GetHost().GetName(), in.GetPath())
+	nas, err := nasService.Mount(in.GetNas().GetName(), in.GetHost().GetName(), in.GetPath(), in.GetReadOnly(), in.GetSecurityMode())
 
 	if err != nil {
 		tbr := errors.Wrap(err, "Cannot mount NAS")
@@ -161,3 +192,70 @@ func (s *NasServiceServer) Inspect(ctx context.Context, in *pb.NasName) (*pb.Nas
 	rv := &pb.NasList{NasList: pbnass}
 	return rv, nil
 }
+
+//Tag adds, replaces or removes tags of a nas
+func (s *NasServiceServer) Tag(ctx context.Context, in *pb.TagUpdate) (*pb.NasDefinition, error) {
+	log.Printf("Tag NAS called, name '%s'", in.GetResource().GetName())
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot tag NAS : No tenant set")
+	}
+
+	nasService := services.NewNasService(currentTenant.Client)
+	nas, err := nasService.SetTags(in.GetResource().GetName(), in.GetSet(), in.GetRemove())
+	if err != nil {
+		tbr := errors.Wrap(err, "Cannot tag NAS")
+		return nil, tbr
+	}
+	return convert.ToPBNas(nas), nil
+}
+
+//AddACL adds an ACL to the export of a nas, or replaces the ACL of the same host pattern
+func (s *NasServiceServer) AddACL(ctx context.Context, in *pb.NasACLUpdate) (*pb.NasDefinition, error) {
+	log.Printf("Add NAS ACL called, name '%s', host '%s'", in.GetNas().GetName(), in.GetACL().GetHost())
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot add NAS ACL : No tenant set")
+	}
+	if in.GetACL() == nil {
+		return nil, fmt.Errorf("Cannot add NAS ACL : No ACL given")
+	}
+
+	nasService := services.NewNasService(currentTenant.Client)
+	nas, err := nasService.AddACL(in.GetNas().GetName(), convert.ToAPINasACL(in.GetACL()))
+	if err != nil {
+		tbr := errors.Wrap(err, "Cannot add NAS ACL")
+		return nil, tbr
+	}
+	return convert.ToPBNas(nas), nil
+}
+
+//RemoveACL removes the ACL of a host pattern from the export of a nas
+func (s *NasServiceServer) RemoveACL(ctx context.Context, in *pb.NasACLUpdate) (*pb.NasDefinition, error) {
+	log.Printf("Remove NAS ACL called, name '%s', host '%s'", in.GetNas().GetName(), in.GetACL().GetHost())
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot remove NAS ACL : No tenant set")
+	}
+
+	nasService := services.NewNasService(currentTenant.Client)
+	nas, err := nasService.RemoveACL(in.GetNas().GetName(), in.GetACL().GetHost())
+	if err != nil {
+		tbr := errors.Wrap(err, "Cannot remove NAS ACL")
+		return nil, tbr
+	}
+	return convert.ToPBNas(nas), nil
+}
+
+//Failover makes the secondary server of a highly available nas take over from the active one
+func (s *NasServiceServer) Failover(ctx context.Context, in *pb.NasName) (*pb.NasDefinition, error) {
+	log.Printf("Failover NAS called, name '%s'", in.GetName())
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot fail over NAS : No tenant set")
+	}
+
+	nasService := services.NewNasService(currentTenant.Client)
+	nas, err := nasService.Failover(in.GetName())
+	if err != nil {
+		tbr := errors.Wrap(err, "Cannot fail over NAS")
+		return nil, tbr
+	}
+	return convert.ToPBNas(nas), nil
+}
@@ -20,12 +20,14 @@ import (
 	"context"
 	"fmt"
 	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/daemon/jobs"
 	"github.com/CS-SI/SafeScale/broker/daemon/services"
 	"github.com/CS-SI/SafeScale/broker/utils"
 	conv "github.com/CS-SI/SafeScale/broker/utils"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/CS-SI/SafeScale/providers/enums/IPVersion"
+	"github.com/CS-SI/SafeScale/providers/filters/tags"
 
 	google_protobuf "github.com/golang/protobuf/ptypes/empty"
 )
@@ -46,16 +48,50 @@ func (s *NetworkServiceServer) Create(ctx context.Context, in *pb.NetworkDefinit
 		return nil, fmt.Errorf("Cannot create network : No tenant set")
 	}
 
-	networkAPI := services.NewNetworkService(currentTenant.Client)
-	network, err := networkAPI.Create(in.GetName(), in.GetCIDR(), IPVersion.IPv4,
-		int(in.Gateway.GetCPU()), in.GetGateway().GetRAM(), int(in.GetGateway().GetDisk()), in.GetGateway().GetImageID(), in.GetGateway().GetName())
-
+	var network *pb.Network
+	err := jobRegistry.Run(ctx, "network creation", in.GetName(), createNetwork(currentTenant, in, &network))
 	if err != nil {
 		return nil, err
 	}
 
 	log.Println("Network created")
-	return conv.ToPBNetwork(network), nil
+	return network, nil
+}
+
+// CreateAsync starts the creation of a new network and returns the ID of the job
+func (s *NetworkServiceServer) CreateAsync(ctx context.Context, in *pb.NetworkDefinition) (*pb.JobID, error) {
+	log.Printf("Create Network (async) called '%s'", in.Name)
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot create network : No tenant set")
+	}
+
+	var network *pb.Network
+	job := jobRegistry.Start("network creation", in.GetName(), createNetwork(currentTenant, in, &network))
+	return &pb.JobID{ID: job.ID}, nil
+}
+
+// createNetwork returns the job creating the network defined by in on tenant, the network is stored in out
+func createNetwork(tenant *Tenant, in *pb.NetworkDefinition, out **pb.Network) func(context.Context) error {
+	networkAPI := services.NewNetworkService(tenant.Client)
+	return func(ctx context.Context) error {
+		network, err := networkAPI.Create(ctx, in.GetName(), in.GetCIDR(), IPVersion.IPv4,
+			int(in.Gateway.GetCPU()), in.GetGateway().GetRAM(), int(in.GetGateway().GetDisk()), in.GetGateway().GetImageID(), in.GetGateway().GetName(),
+			in.GetGateway().GetSecurityGroups())
+		if err != nil {
+			return err
+		}
+		if len(in.GetTags()) > 0 {
+			jobs.Step(ctx, "Tagging network '%s'", network.Name)
+			network, err = networkAPI.SetTags(network.ID, in.GetTags(), nil)
+			if err != nil {
+				return err
+			}
+		}
+		*out = conv.ToPBNetwork(network)
+		jobs.SetResult(ctx, *out)
+		return nil
+	}
 }
 
 // List existing networks
@@ -66,6 +102,11 @@ func (s *NetworkServiceServer) List(ctx context.Context, in *pb.NWListRequest) (
 		return nil, fmt.Errorf("Cannot list networks : No tenant set")
 	}
 
+	selector, err := tags.ParseSelector(in.GetSelector())
+	if err != nil {
+		return nil, fmt.Errorf("Cannot list networks : %s", err.Error())
+	}
+
 	networkAPI := services.NewNetworkService(currentTenant.Client)
 
 	networks, err := networkAPI.List(in.GetAll())
@@ -77,6 +118,9 @@ func (s *NetworkServiceServer) List(ctx context.Context, in *pb.NWListRequest) (
 
 	// Map api.Network to pb.Network
 	for _, network := range networks {
+		if !selector.Matches(network.Tags) {
+			continue
+		}
 		pbnetworks = append(pbnetworks, conv.ToPBNetwork(&network))
 	}
 	rv := &pb.NetworkList{Networks: pbnetworks}
@@ -121,8 +165,7 @@ func (s *NetworkServiceServer) Delete(ctx context.Context, in *pb.Reference) (*g
 		return nil, fmt.Errorf("Cannot delete network : No tenant set")
 	}
 
-	networkAPI := services.NewNetworkService(currentTenant.Client)
-	err := networkAPI.Delete(ref)
+	err := jobRegistry.Run(ctx, "network deletion", ref, deleteNetwork(currentTenant, ref))
 	if err != nil {
 		return nil, err
 	}
@@ -130,3 +173,72 @@ func (s *NetworkServiceServer) Delete(ctx context.Context, in *pb.Reference) (*g
 	log.Printf("Network '%s' deleted", ref)
 	return &google_protobuf.Empty{}, nil
 }
+
+// DeleteAsync starts the deletion of a network and returns the ID of the job
+func (s *NetworkServiceServer) DeleteAsync(ctx context.Context, in *pb.Reference) (*pb.JobID, error) {
+	log.Printf("Delete Network (async) called for network '%s'", in.GetName())
+
+	ref := utils.GetReference(in)
+	if ref == "" {
+		return nil, fmt.Errorf("Cannot delete network : Neither name nor id given as reference")
+	}
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot delete network : No tenant set")
+	}
+
+	job := jobRegistry.Start("network deletion", ref, deleteNetwork(currentTenant, ref))
+	return &pb.JobID{ID: job.ID}, nil
+}
+
+// deleteNetwork returns the job deleting the network referenced by ref on tenant
+func deleteNetwork(tenant *Tenant, ref string) func(context.Context) error {
+	networkAPI := services.NewNetworkService(tenant.Client)
+	return func(ctx context.Context) error {
+		jobs.Step(ctx, "Deleting network '%s' and its gateway", ref)
+		return networkAPI.Delete(ref)
+	}
+}
+
+// Import creates the metadata of a network not managed by SafeScale
+func (s *NetworkServiceServer) Import(ctx context.Context, in *pb.NetworkImport) (*pb.Network, error) {
+	log.Printf("Import Network called '%s'", in.GetRef())
+
+	if in.GetRef() == "" {
+		return nil, fmt.Errorf("Cannot import network : Neither name nor id given as reference")
+	}
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot import network : No tenant set")
+	}
+
+	networkAPI := services.NewNetworkService(currentTenant.Client)
+	network, err := networkAPI.Import(in.GetRef(), in.GetGateway(), in.GetPrivateKey())
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Network '%s' imported", network.Name)
+	return conv.ToPBNetwork(network), nil
+}
+
+// Tag adds, replaces or removes tags of a network
+func (s *NetworkServiceServer) Tag(ctx context.Context, in *pb.TagUpdate) (*pb.Network, error) {
+	log.Printf("Tag Network called '%s'", in.GetResource().GetName())
+
+	ref := utils.GetReference(in.GetResource())
+	if ref == "" {
+		return nil, fmt.Errorf("Cannot tag network : Neither name nor id given as reference")
+	}
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot tag network : No tenant set")
+	}
+
+	networkAPI := services.NewNetworkService(currentTenant.Client)
+	network, err := networkAPI.SetTags(ref, in.GetSet(), in.GetRemove())
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Network '%s' tagged", network.Name)
+	return conv.ToPBNetwork(network), nil
+}
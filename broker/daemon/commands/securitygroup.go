@@ -0,0 +1,167 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/daemon/services"
+	"github.com/CS-SI/SafeScale/broker/utils"
+	conv "github.com/CS-SI/SafeScale/broker/utils"
+	google_protobuf "github.com/golang/protobuf/ptypes/empty"
+	log "github.com/sirupsen/logrus"
+)
+
+// broker securitygroup create --net net1 --description "Web servers" web
+// broker securitygroup list
+// broker securitygroup inspect web
+// broker securitygroup delete web
+// broker securitygroup rule add web --direction ingress --protocol tcp --port-from 443
+// broker securitygroup rule delete web <rule_ID>
+
+// SecurityGroupServiceServer security group service server grpc
+type SecurityGroupServiceServer struct{}
+
+// Create creates a security group
+func (s *SecurityGroupServiceServer) Create(ctx context.Context, in *pb.SecurityGroupDefinition) (*pb.SecurityGroup, error) {
+	log.Printf("Create security group called '%s'", in.GetName())
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot create security group : No tenant set")
+	}
+
+	service := services.NewSecurityGroupService(currentTenant.Client)
+	sg, err := service.Create(in.GetName(), in.GetDescription(), in.GetNetwork())
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Security group '%s' created", in.GetName())
+	return conv.ToPBSecurityGroup(sg), nil
+}
+
+// List lists the security groups of the tenant
+func (s *SecurityGroupServiceServer) List(ctx context.Context, in *google_protobuf.Empty) (*pb.SecurityGroupList, error) {
+	log.Printf("List security groups called")
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot list security groups : No tenant set")
+	}
+
+	service := services.NewSecurityGroupService(currentTenant.Client)
+	list, err := service.List()
+	if err != nil {
+		return nil, err
+	}
+	var pbgroups []*pb.SecurityGroup
+	for _, sg := range list {
+		pbgroups = append(pbgroups, conv.ToPBSecurityGroup(&sg))
+	}
+	return &pb.SecurityGroupList{SecurityGroups: pbgroups}, nil
+}
+
+// Inspect returns the security group referenced by in
+func (s *SecurityGroupServiceServer) Inspect(ctx context.Context, in *pb.Reference) (*pb.SecurityGroup, error) {
+	log.Printf("Inspect security group called '%s'", in.GetName())
+
+	ref := utils.GetReference(in)
+	if ref == "" {
+		return nil, fmt.Errorf("Cannot inspect security group : Neither name nor id given as reference")
+	}
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot inspect security group : No tenant set")
+	}
+
+	service := services.NewSecurityGroupService(currentTenant.Client)
+	sg, err := service.Get(ref)
+	if err != nil {
+		return nil, err
+	}
+	if sg == nil {
+		return nil, fmt.Errorf("Cannot inspect security group : security group '%s' not found", ref)
+	}
+	return conv.ToPBSecurityGroup(sg), nil
+}
+
+// Delete deletes the security group referenced by in
+func (s *SecurityGroupServiceServer) Delete(ctx context.Context, in *pb.Reference) (*google_protobuf.Empty, error) {
+	log.Printf("Delete security group called '%s'", in.GetName())
+
+	ref := utils.GetReference(in)
+	if ref == "" {
+		return nil, fmt.Errorf("Cannot delete security group : Neither name nor id given as reference")
+	}
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot delete security group : No tenant set")
+	}
+
+	service := services.NewSecurityGroupService(currentTenant.Client)
+	err := service.Delete(ref)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Security group '%s' deleted", ref)
+	return &google_protobuf.Empty{}, nil
+}
+
+// AddRule adds a rule to a security group
+func (s *SecurityGroupServiceServer) AddRule(ctx context.Context, in *pb.SecurityGroupRuleRequest) (*pb.SecurityGroupRule, error) {
+	log.Printf("Add security group rule called '%s'", in.GetGroup().GetName())
+
+	ref := utils.GetReference(in.GetGroup())
+	if ref == "" {
+		return nil, fmt.Errorf("Cannot add security group rule : Neither name nor id given as reference")
+	}
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot add security group rule : No tenant set")
+	}
+
+	service := services.NewSecurityGroupService(currentTenant.Client)
+	rule, err := service.AddRule(ref, conv.ToAPISecurityGroupRule(in.GetRule()))
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Rule '%s' added to security group '%s'", rule.ID, ref)
+	return conv.ToPBSecurityGroupRule(rule), nil
+}
+
+// DeleteRule deletes a rule, identified by its ID, from a security group
+func (s *SecurityGroupServiceServer) DeleteRule(ctx context.Context, in *pb.SecurityGroupRuleRequest) (*google_protobuf.Empty, error) {
+	log.Printf("Delete security group rule called '%s'", in.GetGroup().GetName())
+
+	ref := utils.GetReference(in.GetGroup())
+	if ref == "" {
+		return nil, fmt.Errorf("Cannot delete security group rule : Neither name nor id given as reference")
+	}
+	if in.GetRule().GetID() == "" {
+		return nil, fmt.Errorf("Cannot delete security group rule : rule ID required")
+	}
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot delete security group rule : No tenant set")
+	}
+
+	service := services.NewSecurityGroupService(currentTenant.Client)
+	err := service.DeleteRule(ref, in.GetRule().GetID())
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Rule '%s' deleted from security group '%s'", in.GetRule().GetID(), ref)
+	return &google_protobuf.Empty{}, nil
+}
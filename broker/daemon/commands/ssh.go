@@ -19,17 +19,27 @@ package commands
 import (
 	"context"
 	"fmt"
+	"io"
+	"sync"
+
 	"github.com/CS-SI/SafeScale/system"
 	log "github.com/sirupsen/logrus"
 
 	pb "github.com/CS-SI/SafeScale/broker"
 	"github.com/CS-SI/SafeScale/broker/daemon/services"
+	"github.com/CS-SI/SafeScale/broker/utils"
+	google_protobuf "github.com/golang/protobuf/ptypes/empty"
 )
 
 // broker ssh connect host2
 // broker ssh run host2 -c "uname -a"
+// cat script.sh | broker ssh run host2 -c "bash -s"
 // broker ssh copy /file/test.txt host1://tmp
 // broker ssh copy host1:/file/test.txt /tmp
+// broker ssh tunnel host1 --local 8080 --remote 80
+// broker ssh socks net1 --local 1080
+// broker ssh tunnels list
+// broker ssh tunnels kill 3
 
 // SSHServiceServer SSH service server grpc
 type SSHServiceServer struct{}
@@ -51,6 +61,83 @@ func (s *SSHServiceServer) Run(ctx context.Context, in *pb.SshCommand) (*pb.SshR
 	}, err
 }
 
+// Execute executes an ssh command on an host, sending its outputs as they are produced
+// The first message received gives the host and the command, the next ones the standard input of the command
+func (s *SSHServiceServer) Execute(stream pb.SshService_ExecuteServer) error {
+	in, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	log.Printf("Ssh execute called '%s', '%s'", in.GetHost().GetName(), in.GetCommand())
+	ref := utils.GetReference(in.GetHost())
+	if ref == "" {
+		return fmt.Errorf("Cannot execute ssh command : Neither name nor id given as reference")
+	}
+	if GetCurrentTenant() == nil {
+		return fmt.Errorf("Cannot execute ssh command : No tenant set")
+	}
+
+	stdin, stdinWriter := io.Pipe()
+	go receiveStdin(in, stream, stdinWriter)
+	defer stdin.Close()
+
+	var lock sync.Mutex
+	send := func(chunk *pb.SshOutput) error {
+		lock.Lock()
+		defer lock.Unlock()
+		return stream.Send(chunk)
+	}
+	stdout := chunkWriter(func(p []byte) error { return send(&pb.SshOutput{Stdout: p}) })
+	stderr := chunkWriter(func(p []byte) error { return send(&pb.SshOutput{Stderr: p}) })
+
+	service := services.NewSSHService(currentTenant.Client)
+	retcode, err := service.Execute(stream.Context(), ref, in.GetCommand(), stdin, stdout, stderr)
+	if err != nil {
+		return err
+	}
+	return send(&pb.SshOutput{Exited: true, Status: int32(retcode)})
+}
+
+// receiveStdin writes to w the standard input sent to Execute, starting with the first message
+func receiveStdin(in *pb.SshInput, stream pb.SshService_ExecuteServer, w *io.PipeWriter) {
+	for {
+		if len(in.GetStdin()) > 0 {
+			_, err := w.Write(in.GetStdin())
+			if err != nil {
+				return
+			}
+		}
+		if in.GetCloseStdin() {
+			w.Close()
+			return
+		}
+		var err error
+		in, err = stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			w.CloseWithError(err)
+			return
+		}
+	}
+}
+
+// chunkWriter is an io.Writer sending each chunk written with a function
+type chunkWriter func(p []byte) error
+
+// Write implements io.Writer
+func (w chunkWriter) Write(p []byte) (int, error) {
+	// the chunk is sent asynchronously, so it must not be modified by the next writes
+	chunk := make([]byte, len(p))
+	copy(chunk, p)
+	err := w(chunk)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
 // Copy copy file from/to an host
 func (s *SSHServiceServer) Copy(ctx context.Context, in *pb.SshCopyCommand) (*pb.SshResponse, error) {
 	log.Printf("Ssh copy called '%s', '%s'", in.Source, in.Destination)
@@ -76,3 +163,99 @@ func (s *SSHServiceServer) Copy(ctx context.Context, in *pb.SshCopyCommand) (*pb
 		OutputErr: stderr,
 	}, err
 }
+
+// Tunnel forwards a local port to a port reached from an host, until the call ends
+func (s *SSHServiceServer) Tunnel(in *pb.SshTunnelDefinition, stream pb.SshService_TunnelServer) error {
+	log.Printf("Ssh tunnel called '%s', '%s', '%s'", in.GetHost().GetName(), in.GetLocal(), in.GetRemote())
+	ref := utils.GetReference(in.GetHost())
+	if ref == "" {
+		return fmt.Errorf("Cannot open tunnel : Neither name nor id given as reference")
+	}
+	if GetCurrentTenant() == nil {
+		return fmt.Errorf("Cannot open tunnel : No tenant set")
+	}
+
+	service := services.NewTunnelService(currentTenant.Client)
+	tunnel, err := service.Forward(ref, in.GetLocal(), in.GetRemote())
+	if err != nil {
+		return err
+	}
+	return serveTunnel(service, tunnel, stream)
+}
+
+// Socks runs a SOCKS proxy opening its connections from the gateway of a network, until the call ends
+func (s *SSHServiceServer) Socks(in *pb.SshSocksDefinition, stream pb.SshService_SocksServer) error {
+	log.Printf("Ssh socks called '%s', '%s'", in.GetNetwork().GetName(), in.GetLocal())
+	ref := utils.GetReference(in.GetNetwork())
+	if ref == "" {
+		return fmt.Errorf("Cannot open SOCKS proxy : Neither name nor id given as reference")
+	}
+	if GetCurrentTenant() == nil {
+		return fmt.Errorf("Cannot open SOCKS proxy : No tenant set")
+	}
+
+	service := services.NewTunnelService(currentTenant.Client)
+	tunnel, err := service.SOCKS(ref, in.GetLocal())
+	if err != nil {
+		return err
+	}
+	return serveTunnel(service, tunnel, stream)
+}
+
+// tunnelStream is the stream of the calls opening a tunnel
+type tunnelStream interface {
+	Send(*pb.SshTunnel) error
+	Context() context.Context
+}
+
+// serveTunnel sends the tunnel opened, and keeps it opened until the call ends or the tunnel is killed
+func serveTunnel(service services.TunnelAPI, tunnel *services.Tunnel, stream tunnelStream) error {
+	err := stream.Send(toPBSshTunnel(tunnel))
+	if err != nil {
+		service.Close(tunnel.ID)
+		return err
+	}
+	return service.Wait(stream.Context(), tunnel.ID)
+}
+
+// ListTunnels lists the tunnels opened by brokerd
+func (s *SSHServiceServer) ListTunnels(ctx context.Context, in *google_protobuf.Empty) (*pb.SshTunnelList, error) {
+	log.Printf("Ssh list tunnels called")
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot list tunnels : No tenant set")
+	}
+
+	var list []*pb.SshTunnel
+	for _, tunnel := range services.NewTunnelService(currentTenant.Client).List() {
+		list = append(list, toPBSshTunnel(&tunnel))
+	}
+	return &pb.SshTunnelList{Tunnels: list}, nil
+}
+
+// KillTunnel closes a tunnel opened by brokerd
+func (s *SSHServiceServer) KillTunnel(ctx context.Context, in *pb.SshTunnelID) (*google_protobuf.Empty, error) {
+	log.Printf("Ssh kill tunnel called '%s'", in.GetID())
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot kill tunnel : No tenant set")
+	}
+
+	err := services.NewTunnelService(currentTenant.Client).Close(in.GetID())
+	if err != nil {
+		return nil, fmt.Errorf("Cannot kill tunnel : %s", err.Error())
+	}
+	return &google_protobuf.Empty{}, nil
+}
+
+// toPBSshTunnel converts a tunnel opened by brokerd to protocolbuffer format
+func toPBSshTunnel(in *services.Tunnel) *pb.SshTunnel {
+	return &pb.SshTunnel{
+		ID:          in.ID,
+		Kind:        in.Kind,
+		Target:      in.Target,
+		Local:       in.Local,
+		Remote:      in.Remote,
+		OpenedAt:    in.OpenedAt.Unix(),
+		Connections: int32(in.Connections),
+		LastError:   in.LastError,
+	}
+}
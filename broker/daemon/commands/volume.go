@@ -20,12 +20,14 @@ import (
 	"context"
 	"fmt"
 	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/daemon/jobs"
 	"github.com/CS-SI/SafeScale/broker/daemon/services"
 	"github.com/CS-SI/SafeScale/broker/utils"
 	conv "github.com/CS-SI/SafeScale/broker/utils"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/CS-SI/SafeScale/providers/enums/VolumeSpeed"
+	"github.com/CS-SI/SafeScale/providers/filters/tags"
 
 	google_protobuf "github.com/golang/protobuf/ptypes/empty"
 )
@@ -36,6 +38,10 @@ import (
 // broker volume delete v1
 // broker volume inspect v1
 // broker volume update v1 --speed="HDD" --size=1000
+// broker volume snapshot create v1 --name="before-upgrade" --consistent
+// broker volume snapshot list v1
+// broker volume snapshot restore before-upgrade v3
+// broker volume snapshot delete before-upgrade
 
 //VolumeServiceServer is the volume service grps server
 type VolumeServiceServer struct{}
@@ -50,6 +56,10 @@ func (s *VolumeServiceServer) List(ctx context.Context, in *pb.VolumeListRequest
 	if tenant == nil {
 		return nil, fmt.Errorf("Cannot list volumes : No tenant set")
 	}
+	selector, err := tags.ParseSelector(in.GetSelector())
+	if err != nil {
+		return nil, fmt.Errorf("Cannot list volumes : %s", err.Error())
+	}
 	service := VolumeServiceCreator(tenant.Client)
 	volumes, err := service.List(in.GetAll())
 	if err != nil {
@@ -59,6 +69,9 @@ func (s *VolumeServiceServer) List(ctx context.Context, in *pb.VolumeListRequest
 
 	// Map api.Volume to pb.Volume
 	for _, volume := range volumes {
+		if !selector.Matches(volume.Tags) {
+			continue
+		}
 		pbvolumes = append(pbvolumes, conv.ToPBVolume(&volume))
 	}
 	rv := &pb.VolumeList{Volumes: pbvolumes}
@@ -73,14 +86,48 @@ func (s *VolumeServiceServer) Create(ctx context.Context, in *pb.VolumeDefinitio
 		return nil, fmt.Errorf("Cannot create volume : No tenant set")
 	}
 
-	service := VolumeServiceCreator(tenant.Client)
-	vol, err := service.Create(in.GetName(), int(in.GetSize()), VolumeSpeed.Enum(in.GetSpeed()))
+	var vol *pb.Volume
+	err := jobRegistry.Run(ctx, "volume creation", in.GetName(), createVolume(tenant, in, &vol))
 	if err != nil {
 		return nil, err
 	}
 
 	log.Printf("Volume '%s' created: %v", in.GetName(), vol)
-	return conv.ToPBVolume(vol), nil
+	return vol, nil
+}
+
+//CreateAsync starts the creation of a new volume and returns the ID of the job
+func (s *VolumeServiceServer) CreateAsync(ctx context.Context, in *pb.VolumeDefinition) (*pb.JobID, error) {
+	log.Printf("Create Volume (async) called '%s'", in.Name)
+	tenant := GetCurrentTenant()
+	if tenant == nil {
+		return nil, fmt.Errorf("Cannot create volume : No tenant set")
+	}
+
+	var vol *pb.Volume
+	job := jobRegistry.Start("volume creation", in.GetName(), createVolume(tenant, in, &vol))
+	return &pb.JobID{ID: job.ID}, nil
+}
+
+//createVolume returns the job creating the volume defined by in on tenant, the volume is stored in out
+func createVolume(tenant *Tenant, in *pb.VolumeDefinition, out **pb.Volume) func(context.Context) error {
+	service := VolumeServiceCreator(tenant.Client)
+	return func(ctx context.Context) error {
+		vol, err := service.Create(ctx, in.GetName(), int(in.GetSize()), VolumeSpeed.Enum(in.GetSpeed()))
+		if err != nil {
+			return err
+		}
+		if len(in.GetTags()) > 0 {
+			jobs.Step(ctx, "Tagging volume '%s'", vol.Name)
+			vol, err = service.SetTags(vol.ID, in.GetTags(), nil)
+			if err != nil {
+				return err
+			}
+		}
+		*out = conv.ToPBVolume(vol)
+		jobs.SetResult(ctx, *out)
+		return nil
+	}
 }
 
 //Attach a volume to an host and create a mount point
@@ -135,29 +182,58 @@ func (s *VolumeServiceServer) Delete(ctx context.Context, in *pb.Reference) (*go
 	if tenant == nil {
 		return nil, fmt.Errorf("Cannot delete volume : No tenant set")
 	}
-	service := services.NewVolumeService(currentTenant.Client)
-	err := service.Delete(ref)
+	err := jobRegistry.Run(ctx, "volume deletion", ref, s.deleteVolume(tenant, ref, in))
 	if err != nil {
+		return nil, err
+	}
+	log.Printf("Volume '%s' deleted", ref)
+	return &google_protobuf.Empty{}, nil
+}
+
+//DeleteAsync starts the deletion of a volume and returns the ID of the job
+func (s *VolumeServiceServer) DeleteAsync(ctx context.Context, in *pb.Reference) (*pb.JobID, error) {
+	log.Printf("Volume delete (async) called '%s'", in.Name)
+
+	ref := utils.GetReference(in)
+	if ref == "" {
+		return nil, fmt.Errorf("Cannot delete volume : Neither name nor id given as reference")
+	}
+
+	tenant := GetCurrentTenant()
+	if tenant == nil {
+		return nil, fmt.Errorf("Cannot delete volume : No tenant set")
+	}
+	job := jobRegistry.Start("volume deletion", ref, s.deleteVolume(tenant, ref, in))
+	return &pb.JobID{ID: job.ID}, nil
+}
+
+//deleteVolume returns the job deleting the volume referenced by ref on tenant
+func (s *VolumeServiceServer) deleteVolume(tenant *Tenant, ref string, in *pb.Reference) func(context.Context) error {
+	service := services.NewVolumeService(tenant.Client)
+	return func(ctx context.Context) error {
+		jobs.Step(ctx, "Deleting volume '%s'", ref)
+		err := service.Delete(ref)
+		if err == nil {
+			return nil
+		}
 		vin, nerr := s.Inspect(ctx, in)
 		if nerr == nil {
 			if vin.Host != nil {
 				hostName := utils.GetReference(vin.Host)
 
-				hostService := services.NewHostService(currentTenant.Client)
+				hostService := services.NewHostService(tenant.Client)
 				hap, ign := hostService.Get(hostName)
 
 				if ign == nil {
-					return nil, fmt.Errorf("Cannot delete volume '%s' because it's mounted on VM '%s'\nDetails: %s", in.Name, hap.Name, err)
+					return fmt.Errorf("Cannot delete volume '%s' because it's mounted on VM '%s'\nDetails: %s", in.Name, hap.Name, err)
 				}
 			}
 		} else {
 			log.Warnf("Error inspecting volume after delete failure: %v", nerr)
 		}
 
-		return nil, err
+		return err
 	}
-	log.Printf("Volume '%s' deleted", ref)
-	return &google_protobuf.Empty{}, nil
 }
 
 //Inspect a volume
@@ -185,3 +261,141 @@ func (s *VolumeServiceServer) Inspect(ctx context.Context, in *pb.Reference) (*p
 
 	return conv.ToPBVolumeInfo(volume, volattach), nil
 }
+
+//Import creates the metadata of a volume not managed by SafeScale
+func (s *VolumeServiceServer) Import(ctx context.Context, in *pb.Reference) (*pb.Volume, error) {
+	log.Printf("Import Volume called '%s'", in.Name)
+
+	ref := utils.GetReference(in)
+	if ref == "" {
+		return nil, fmt.Errorf("Cannot import volume : Neither name nor id given as reference")
+	}
+
+	if GetCurrentTenant() == nil {
+		return nil, fmt.Errorf("Cannot import volume : No tenant set")
+	}
+
+	service := services.NewVolumeService(currentTenant.Client)
+	volume, err := service.Import(ref)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Volume '%s' imported", volume.Name)
+	return conv.ToPBVolume(volume), nil
+}
+
+//Tag adds, replaces or removes tags of a volume
+func (s *VolumeServiceServer) Tag(ctx context.Context, in *pb.TagUpdate) (*pb.Volume, error) {
+	log.Printf("Tag volume called '%s'", in.GetResource().GetName())
+
+	ref := utils.GetReference(in.GetResource())
+	if ref == "" {
+		return nil, fmt.Errorf("Cannot tag volume : Neither name nor id given as reference")
+	}
+
+	tenant := GetCurrentTenant()
+	if tenant == nil {
+		return nil, fmt.Errorf("Cannot tag volume : No tenant set")
+	}
+
+	service := VolumeServiceCreator(tenant.Client)
+	volume, err := service.SetTags(ref, in.GetSet(), in.GetRemove())
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Volume '%s' tagged", volume.Name)
+	return conv.ToPBVolume(volume), nil
+}
+
+//CreateSnapshot creates a snapshot of a volume
+func (s *VolumeServiceServer) CreateSnapshot(ctx context.Context, in *pb.SnapshotDefinition) (*pb.Snapshot, error) {
+	log.Printf("Create snapshot called '%s'", in.GetName())
+
+	ref := utils.GetReference(in.GetVolume())
+	if ref == "" {
+		return nil, fmt.Errorf("Cannot create snapshot : Neither name nor id given as reference of the volume")
+	}
+
+	tenant := GetCurrentTenant()
+	if tenant == nil {
+		return nil, fmt.Errorf("Cannot create snapshot : No tenant set")
+	}
+
+	service := VolumeServiceCreator(tenant.Client)
+	snapshot, err := service.CreateSnapshot(ref, in.GetName(), in.GetConsistent())
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Snapshot '%s' of volume '%s' created", snapshot.Name, ref)
+	return conv.ToPBSnapshot(snapshot), nil
+}
+
+//ListSnapshots lists the snapshots of a volume, or all the snapshots if no volume is given
+func (s *VolumeServiceServer) ListSnapshots(ctx context.Context, in *pb.Reference) (*pb.SnapshotList, error) {
+	log.Printf("List snapshots called '%s'", utils.GetReference(in))
+
+	tenant := GetCurrentTenant()
+	if tenant == nil {
+		return nil, fmt.Errorf("Cannot list snapshots : No tenant set")
+	}
+
+	service := VolumeServiceCreator(tenant.Client)
+	snapshots, err := service.ListSnapshots(utils.GetReference(in))
+	if err != nil {
+		return nil, err
+	}
+	var pbsnapshots []*pb.Snapshot
+	for i := range snapshots {
+		pbsnapshots = append(pbsnapshots, conv.ToPBSnapshot(&snapshots[i]))
+	}
+	return &pb.SnapshotList{Snapshots: pbsnapshots}, nil
+}
+
+//DeleteSnapshot deletes a snapshot
+func (s *VolumeServiceServer) DeleteSnapshot(ctx context.Context, in *pb.Reference) (*google_protobuf.Empty, error) {
+	log.Printf("Delete snapshot called '%s'", utils.GetReference(in))
+
+	ref := utils.GetReference(in)
+	if ref == "" {
+		return &google_protobuf.Empty{}, fmt.Errorf("Cannot delete snapshot : Neither name nor id given as reference")
+	}
+
+	tenant := GetCurrentTenant()
+	if tenant == nil {
+		return &google_protobuf.Empty{}, fmt.Errorf("Cannot delete snapshot : No tenant set")
+	}
+
+	service := VolumeServiceCreator(tenant.Client)
+	err := service.DeleteSnapshot(ref)
+	if err != nil {
+		return &google_protobuf.Empty{}, err
+	}
+	log.Printf("Snapshot '%s' deleted", ref)
+	return &google_protobuf.Empty{}, nil
+}
+
+//RestoreSnapshot creates a new volume with the content of a snapshot
+func (s *VolumeServiceServer) RestoreSnapshot(ctx context.Context, in *pb.SnapshotRestore) (*pb.Volume, error) {
+	log.Printf("Restore snapshot called '%s'", utils.GetReference(in.GetSnapshot()))
+
+	ref := utils.GetReference(in.GetSnapshot())
+	if ref == "" {
+		return nil, fmt.Errorf("Cannot restore snapshot : Neither name nor id given as reference")
+	}
+	if in.GetVolume().GetName() == "" {
+		return nil, fmt.Errorf("Cannot restore snapshot : No name given for the new volume")
+	}
+
+	tenant := GetCurrentTenant()
+	if tenant == nil {
+		return nil, fmt.Errorf("Cannot restore snapshot : No tenant set")
+	}
+
+	service := VolumeServiceCreator(tenant.Client)
+	volume, err := service.RestoreSnapshot(ref, in.GetVolume().GetName(), int(in.GetVolume().GetSize()), VolumeSpeed.Enum(in.GetVolume().GetSpeed()))
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Snapshot '%s' restored on volume '%s'", ref, volume.Name)
+	return conv.ToPBVolume(volume), nil
+}
@@ -16,6 +16,7 @@
 package commands_test
 
 import (
+	"context"
 	"errors"
 	"github.com/CS-SI/SafeScale/providers/enums/VolumeSpeed"
 	"testing"
@@ -35,7 +36,7 @@ type MyMockedVolService struct {
 	err error
 }
 
-func (m *MyMockedVolService) Create(name string, size int, speed VolumeSpeed.Enum) (*api.Volume, error) {
+func (m *MyMockedVolService) Create(ctx context.Context, name string, size int, speed VolumeSpeed.Enum) (*api.Volume, error) {
 	m.Called(name, size, speed)
 
 	return &api.Volume{Name: name,
@@ -57,6 +58,12 @@ func (m *MyMockedVolService) Detach(volume string, host string) error {
 func (m *MyMockedVolService) Get(ref string) (*api.Volume, error) {
 	return &api.Volume{}, nil
 }
+func (m *MyMockedVolService) Import(ref string) (*api.Volume, error) {
+	return &api.Volume{}, nil
+}
+func (m *MyMockedVolService) SetTags(ref string, set map[string]string, remove []string) (*api.Volume, error) {
+	return &api.Volume{Tags: set}, nil
+}
 
 func TestCreate(t *testing.T) {
 	// ARRANGE
@@ -82,17 +89,17 @@ func TestCreate(t *testing.T) {
 	underTest := &commands.VolumeServiceServer{}
 
 	// ACT
-	underTest.Create(nil, &pb.VolumeDefinition{
+	underTest.Create(context.Background(), &pb.VolumeDefinition{
 		Speed: pb.VolumeSpeed_SSD,
 	})
 	// ASSERT
 	myMockedVolService.AssertCalled(t, "Create", mock.Anything, mock.Anything, VolumeSpeed.SSD)
 
-	underTest.Create(nil, &pb.VolumeDefinition{
+	underTest.Create(context.Background(), &pb.VolumeDefinition{
 		Speed: pb.VolumeSpeed_HDD,
 	})
 	myMockedVolService.AssertCalled(t, "Create", mock.Anything, mock.Anything, VolumeSpeed.HDD)
-	underTest.Create(nil, &pb.VolumeDefinition{
+	underTest.Create(context.Background(), &pb.VolumeDefinition{
 		Speed: pb.VolumeSpeed_COLD,
 	})
 	myMockedVolService.AssertCalled(t, "Create", mock.Anything, mock.Anything, VolumeSpeed.COLD)
@@ -122,7 +129,7 @@ func TestCreate_Err(t *testing.T) {
 	underTest := &commands.VolumeServiceServer{}
 
 	// ACT
-	_, err := underTest.Create(nil, &pb.VolumeDefinition{
+	_, err := underTest.Create(context.Background(), &pb.VolumeDefinition{
 		Speed: pb.VolumeSpeed_SSD,
 	})
 	// ASSERT
@@ -142,7 +149,7 @@ func TestCreate_Err_NoTenantSet(t *testing.T) {
 	underTest := &commands.VolumeServiceServer{}
 
 	// ACT
-	_, err := underTest.Create(nil, &pb.VolumeDefinition{
+	_, err := underTest.Create(context.Background(), &pb.VolumeDefinition{
 		Speed: pb.VolumeSpeed_SSD,
 	})
 	// ASSERT
@@ -0,0 +1,318 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultRetention is the time a finished job is kept in a registry
+const DefaultRetention = 1 * time.Hour
+
+// State represents the state of a job
+type State int
+
+const (
+	// PENDING when job is registered but not started yet
+	PENDING State = iota
+	// RUNNING when job is running
+	RUNNING
+	// SUCCEEDED when job ended successfully
+	SUCCEEDED
+	// FAILED when job ended with an error
+	FAILED
+	// CANCELED when job has been canceled
+	CANCELED
+)
+
+// Ended tells if the state is a final one
+func (s State) Ended() bool {
+	return s == SUCCEEDED || s == FAILED || s == CANCELED
+}
+
+// Job describes an operation run by brokerd
+type Job struct {
+	ID        string
+	Operation string
+	Target    string
+	State     State
+	// Step is the last step reached by the job
+	Step      string
+	Error     string
+	StartedAt time.Time
+	EndedAt   time.Time
+	// Result is the resource created by the job, set by the job with SetResult
+	Result interface{}
+}
+
+// Event is a change in the progress of a job
+type Event struct {
+	JobID string
+	State State
+	Step  string
+	Error string
+	Time  time.Time
+}
+
+// entry is a job known by the registry
+type entry struct {
+	job    Job
+	events []Event
+	cancel context.CancelFunc
+	// changed is closed (and replaced) each time an event is added
+	changed chan struct{}
+}
+
+// Registry keeps track of the jobs run by brokerd
+type Registry struct {
+	// Retention is the time a finished job is kept in the registry
+	Retention time.Duration
+
+	lock    sync.Mutex
+	entries map[string]*entry
+}
+
+// NewRegistry creates an empty job registry
+func NewRegistry() *Registry {
+	return &Registry{
+		Retention: DefaultRetention,
+		entries:   map[string]*entry{},
+	}
+}
+
+// tracker is stored in the context of a running job to report its steps
+type tracker struct {
+	registry *Registry
+	id       string
+}
+
+type contextKey struct{}
+
+// Step records a step of the job running with ctx
+// Does nothing if ctx doesn't belong to a job
+func Step(ctx context.Context, format string, args ...interface{}) {
+	t, ok := ctx.Value(contextKey{}).(*tracker)
+	if !ok {
+		return
+	}
+	t.registry.publish(t.id, RUNNING, fmt.Sprintf(format, args...), "")
+}
+
+// SetResult records the resource created by the job running with ctx
+// Does nothing if ctx doesn't belong to a job
+func SetResult(ctx context.Context, result interface{}) {
+	t, ok := ctx.Value(contextKey{}).(*tracker)
+	if !ok {
+		return
+	}
+	t.registry.lock.Lock()
+	defer t.registry.lock.Unlock()
+	if e, ok := t.registry.entries[t.id]; ok {
+		e.job.Result = result
+	}
+}
+
+// register creates a new running job
+func (r *Registry) register(ctx context.Context, operation, target string) (context.Context, *Job) {
+	id, _ := uuid.NewV4()
+	ctx, cancel := context.WithCancel(ctx)
+	e := &entry{
+		job: Job{
+			ID:        id.String(),
+			Operation: operation,
+			Target:    target,
+			State:     PENDING,
+			StartedAt: time.Now(),
+		},
+		cancel:  cancel,
+		changed: make(chan struct{}),
+	}
+
+	r.lock.Lock()
+	r.purge()
+	r.entries[e.job.ID] = e
+	r.lock.Unlock()
+
+	r.publish(e.job.ID, RUNNING, fmt.Sprintf("Starting %s of '%s'", operation, target), "")
+	job := e.job
+	return context.WithValue(ctx, contextKey{}, &tracker{registry: r, id: job.ID}), &job
+}
+
+// execute runs the job and records its result
+func (r *Registry) execute(ctx context.Context, id string, run func(context.Context) error) error {
+	err := run(ctx)
+	switch {
+	case err == nil:
+		r.publish(id, SUCCEEDED, "Done", "")
+	case ctx.Err() == context.Canceled:
+		r.publish(id, CANCELED, "Canceled", err.Error())
+	default:
+		r.publish(id, FAILED, "Failed", err.Error())
+	}
+
+	r.lock.Lock()
+	if e, ok := r.entries[id]; ok {
+		e.cancel()
+	}
+	r.lock.Unlock()
+	return err
+}
+
+// Start runs asynchronously a job executing run, and returns it
+func (r *Registry) Start(operation, target string, run func(context.Context) error) *Job {
+	ctx, job := r.register(context.Background(), operation, target)
+	go func() {
+		err := r.execute(ctx, job.ID, run)
+		if err != nil {
+			log.Errorf("Job %s (%s of '%s') failed: %v", job.ID, operation, target, err)
+		} else {
+			log.Printf("Job %s (%s of '%s') succeeded", job.ID, operation, target)
+		}
+	}()
+	return job
+}
+
+// Run registers a job executing run and waits for its end
+// The job is canceled if ctx is done
+func (r *Registry) Run(ctx context.Context, operation, target string, run func(context.Context) error) error {
+	ctx, job := r.register(ctx, operation, target)
+	return r.execute(ctx, job.ID, run)
+}
+
+// publish updates the job and notifies the watchers
+func (r *Registry) publish(id string, state State, step string, errMsg string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	e, ok := r.entries[id]
+	if !ok || e.job.State.Ended() {
+		return
+	}
+	now := time.Now()
+	e.job.State = state
+	e.job.Step = step
+	e.job.Error = errMsg
+	if state.Ended() {
+		e.job.EndedAt = now
+	}
+	e.events = append(e.events, Event{
+		JobID: id,
+		State: state,
+		Step:  step,
+		Error: errMsg,
+		Time:  now,
+	})
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
+
+// purge removes the jobs ended for more than the retention time
+// r.lock must be held by the caller
+func (r *Registry) purge() {
+	limit := time.Now().Add(-r.Retention)
+	for id, e := range r.entries {
+		if e.job.State.Ended() && e.job.EndedAt.Before(limit) {
+			delete(r.entries, id)
+		}
+	}
+}
+
+// Get returns the job identified by id
+func (r *Registry) Get(id string) (*Job, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	e, ok := r.entries[id]
+	if !ok {
+		return nil, fmt.Errorf("job '%s' not found", id)
+	}
+	job := e.job
+	return &job, nil
+}
+
+// List returns the jobs of the registry, ordered by start date
+func (r *Registry) List() []Job {
+	r.lock.Lock()
+	var list []Job
+	for _, e := range r.entries {
+		list = append(list, e.job)
+	}
+	r.lock.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartedAt.Before(list[j].StartedAt)
+	})
+	return list
+}
+
+// Cancel cancels the job identified by id
+func (r *Registry) Cancel(id string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	e, ok := r.entries[id]
+	if !ok {
+		return fmt.Errorf("job '%s' not found", id)
+	}
+	if e.job.State.Ended() {
+		return fmt.Errorf("job '%s' has already ended", id)
+	}
+	e.cancel()
+	return nil
+}
+
+// Watch calls fn for each event of the job identified by id, starting from the first one
+// Returns when the job has ended, when ctx is done or when fn returns an error
+func (r *Registry) Watch(ctx context.Context, id string, fn func(Event) error) error {
+	next := 0
+	for {
+		r.lock.Lock()
+		e, ok := r.entries[id]
+		if !ok {
+			r.lock.Unlock()
+			return fmt.Errorf("job '%s' not found", id)
+		}
+		events := append([]Event{}, e.events[next:]...)
+		ended := e.job.State.Ended()
+		changed := e.changed
+		r.lock.Unlock()
+
+		for _, event := range events {
+			err := fn(event)
+			if err != nil {
+				return err
+			}
+		}
+		next += len(events)
+		if ended {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
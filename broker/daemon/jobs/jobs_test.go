@@ -0,0 +1,102 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Watch(t *testing.T) {
+	r := NewRegistry()
+	job := r.Start("creation", "host1", func(ctx context.Context) error {
+		Step(ctx, "step %d", 1)
+		Step(ctx, "step %d", 2)
+		return nil
+	})
+
+	var steps []string
+	err := r.Watch(context.Background(), job.ID, func(e Event) error {
+		steps = append(steps, e.Step)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Starting creation of 'host1'", "step 1", "step 2", "Done"}, steps)
+
+	j, err := r.Get(job.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, SUCCEEDED, j.State)
+	assert.Equal(t, 1, len(r.List()))
+}
+
+func TestRegistry_Result(t *testing.T) {
+	r := NewRegistry()
+	job := r.Start("creation", "host1", func(ctx context.Context) error {
+		SetResult(ctx, "host1 created")
+		return nil
+	})
+	assert.Nil(t, job.Result)
+
+	err := r.Watch(context.Background(), job.ID, func(e Event) error { return nil })
+	assert.Nil(t, err)
+	j, err := r.Get(job.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "host1 created", j.Result)
+
+	// Outside of a job, nothing is recorded
+	SetResult(context.Background(), "ignored")
+}
+
+func TestRegistry_Cancel(t *testing.T) {
+	r := NewRegistry()
+	job := r.Start("deletion", "net1", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	assert.Nil(t, r.Cancel(job.ID))
+	err := r.Watch(context.Background(), job.ID, func(e Event) error { return nil })
+	assert.Nil(t, err)
+	j, err := r.Get(job.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, CANCELED, j.State)
+	assert.NotNil(t, r.Cancel(job.ID))
+	assert.NotNil(t, r.Cancel("unknown"))
+}
+
+func TestRegistry_Run(t *testing.T) {
+	r := NewRegistry()
+	r.Retention = 0
+
+	err := r.Run(context.Background(), "creation", "vol1", func(ctx context.Context) error {
+		return fmt.Errorf("no space left")
+	})
+	assert.NotNil(t, err)
+	list := r.List()
+	assert.Equal(t, 1, len(list))
+	assert.Equal(t, FAILED, list[0].State)
+	assert.Equal(t, "no space left", list[0].Error)
+
+	// Finished jobs are purged when new jobs are registered
+	time.Sleep(time.Millisecond)
+	r.Run(context.Background(), "creation", "vol2", func(ctx context.Context) error { return nil })
+	assert.Equal(t, 1, len(r.List()))
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/metadata"
+	"github.com/CS-SI/SafeScale/system/firewall"
+)
+
+//go:generate mockgen -destination=../mocks/mock_firewallapi.go -package=mocks github.com/CS-SI/SafeScale/broker/daemon/services FirewallAPI
+
+//FirewallAPI defines API to manage the firewall of hosts
+type FirewallAPI interface {
+	List(host string) (*firewall.Firewall, error)
+	Add(host string, table string, chain string, rule firewall.Rule, insert bool) error
+	Remove(host string, table string, chain string, rule firewall.Rule, index int) error
+}
+
+//NewFirewallService creates a firewall service
+func NewFirewallService(api api.ClientAPI) FirewallAPI {
+	return &FirewallService{
+		provider:    providers.FromClient(api),
+		hostService: NewHostService(api),
+	}
+}
+
+//FirewallService firewall service
+type FirewallService struct {
+	provider    *providers.Service
+	hostService HostAPI
+}
+
+//List returns the firewall configuration of the host
+func (svc *FirewallService) List(host string) (*firewall.Firewall, error) {
+	ssh, err := svc.hostService.SSH(host)
+	if err != nil {
+		return nil, err
+	}
+	fw := firewall.NewFirewall(ssh)
+	err = fw.FromSystem()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read firewall of host '%s'", host)
+	}
+	return fw, nil
+}
+
+//Add appends rule to the chain of the firewall of the host, or inserts it at the beginning of the chain if insert is true
+func (svc *FirewallService) Add(host string, table string, chain string, rule firewall.Rule, insert bool) error {
+	err := rule.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid rule: %s", err.Error())
+	}
+	return svc.update(host, table, chain, func(c *firewall.Chain) error {
+		if insert {
+			c.Insert(rule)
+		} else {
+			c.Add(rule)
+		}
+		return nil
+	})
+}
+
+//Remove removes a rule from the chain of the firewall of the host; the rule is designated by its index (starting at 1)
+//in the chain if index is not 0, by its content otherwise
+func (svc *FirewallService) Remove(host string, table string, chain string, rule firewall.Rule, index int) error {
+	return svc.update(host, table, chain, func(c *firewall.Chain) error {
+		if index == 0 {
+			index = c.Index(rule) + 1
+			if index == 0 {
+				return fmt.Errorf("rule '%s' not found in chain '%s'", rule.String(), chain)
+			}
+		}
+		if index < 0 || index > len(c.Rules) {
+			return fmt.Errorf("chain '%s' has no rule %d", chain, index)
+		}
+		c.RemoveIndex(uint(index - 1))
+		return nil
+	})
+}
+
+// update reads the firewall of the host, modifies the chain with change then applies the result
+// The metadata of the host are locked meanwhile, so concurrent updates of the same firewall don't overwrite each other
+func (svc *FirewallService) update(host string, table string, chain string, change func(*firewall.Chain) error) error {
+	if table == "" {
+		table = "filter"
+	}
+	if chain == "" {
+		return fmt.Errorf("chain is required")
+	}
+	m, err := metadata.LoadHost(svc.provider, host)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to read metadata of host '%s'", host)
+	}
+	if m == nil {
+		return errors.Wrap(providers.ResourceNotFoundError("host", host), "Cannot update firewall")
+	}
+	err = m.Acquire()
+	if err != nil {
+		return err
+	}
+	defer m.Release()
+
+	fw, err := svc.List(host)
+	if err != nil {
+		return err
+	}
+	t, err := fw.Table(table)
+	if err != nil {
+		return err
+	}
+	c, err := t.Chain(chain)
+	if err != nil {
+		return err
+	}
+	err = change(c)
+	if err != nil {
+		return err
+	}
+	err = fw.ToSystem()
+	if err != nil {
+		return errors.Wrapf(err, "Failed to update firewall of host '%s'", host)
+	}
+	return nil
+}
@@ -17,13 +17,17 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/CS-SI/SafeScale/broker/client"
+	"github.com/CS-SI/SafeScale/broker/daemon/jobs"
 	"github.com/CS-SI/SafeScale/broker/utils"
 	"github.com/CS-SI/SafeScale/providers"
 	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/filters/tags"
+	"github.com/CS-SI/SafeScale/providers/metadata"
 	"github.com/CS-SI/SafeScale/system"
 )
 
@@ -32,14 +36,18 @@ import (
 
 //HostAPI defines API to manipulate hosts
 type HostAPI interface {
-	Create(name string, net string, cpu int, ram float32, disk int, os string, public bool) (*api.Host, error)
+	Create(ctx context.Context, name string, net string, cpu int, ram float32, disk int, os string, public bool, securityGroups []string) (*api.Host, error)
 	List(all bool) ([]api.Host, error)
+	ListOnNetwork(ref string) ([]api.Host, error)
 	Get(ref string) (*api.Host, error)
 	Delete(ref string) error
 	SSH(ref string) (*system.SSHConfig, error)
+	Rekey(ref string, fingerprint string) (*api.Host, error)
 	Reboot(ref string) error
 	Start(ref string) error
 	Stop(ref string) error
+	Import(ref string, net string, privateKey string) (*api.Host, error)
+	SetTags(ref string, set map[string]string, remove []string) (*api.Host, error)
 }
 
 // NewHostService creates an host service
@@ -71,8 +79,10 @@ func (svc *HostService) Reboot(ref string) error {
 	return svc.provider.RebootHost(ref)
 }
 
-// Create creates a host
-func (svc *HostService) Create(name string, net string, cpu int, ram float32, disk int, os string, public bool) (*api.Host, error) {
+// Create creates a host, the creation is rolled back if ctx is done before the host is ready
+// securityGroups are the names or ids of the security groups bound to the host; if empty the default security group
+// of the provider is used
+func (svc *HostService) Create(ctx context.Context, name string, net string, cpu int, ram float32, disk int, os string, public bool, securityGroups []string) (*api.Host, error) {
 	log.Printf("Creating compute resource '%s' ...", name)
 	networks := []string{}
 	if len(net) != 0 {
@@ -88,6 +98,12 @@ func (svc *HostService) Create(name string, net string, cpu int, ram float32, di
 		networks = append(networks, n.ID)
 	}
 
+	sgIDs, err := securityGroupIDs(svc.provider, securityGroups)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs.Step(ctx, "Selecting template and image of host '%s'", name)
 	tpls, err := svc.provider.SelectTemplatesBySize(api.SizingRequirements{
 		MinCores:    cpu,
 		MinRAMSize:  ram,
@@ -104,10 +120,12 @@ func (svc *HostService) Create(name string, net string, cpu int, ram float32, di
 		Name:       name,
 		TemplateID: tpls[0].ID,
 		// IsGateway:  false,
-		PublicIP:   public,
-		NetworkIDs: networks,
+		PublicIP:       public,
+		NetworkIDs:     networks,
+		SecurityGroups: sgIDs,
 	}
-	host, err := svc.provider.CreateHost(hostRequest)
+	jobs.Step(ctx, "Creating host '%s'", name)
+	host, err := svc.provider.CreateHostWithContext(ctx, hostRequest)
 	if err != nil {
 		tbr := errors.Wrapf(err, "Compute resource creation failed: '%s'.", hostRequest.Name)
 		log.Errorf("%+v", tbr)
@@ -119,6 +137,7 @@ func (svc *HostService) Create(name string, net string, cpu int, ram float32, di
 	// to be used until ssh service is up and running. So we wait for it before
 	// claiming host is created
 	log.Printf("Waiting start of SSH service on remote host '%s' ...", host.Name)
+	jobs.Step(ctx, "Waiting start of SSH service on host '%s'", host.Name)
 	ssh, err := svc.provider.GetSSHConfig(host.ID)
 	if err != nil {
 		derr := svc.provider.DeleteHost(host.ID)
@@ -132,7 +151,16 @@ func (svc *HostService) Create(name string, net string, cpu int, ram float32, di
 		log.Errorf("%+v", tbr)
 		return nil, tbr
 	}
-	err = ssh.WaitServerReady(utils.TimeoutCtxHost)
+	err = ssh.WaitServerReadyWithContext(ctx, utils.TimeoutCtxHost)
+	if err != nil && err == ctx.Err() {
+		derr := svc.provider.DeleteHost(host.ID)
+		if derr != nil {
+			log.Warnf("Error deleting host after cancellation: %v", derr)
+		}
+		tbr := errors.Wrapf(err, "Creation of host '%s' canceled", host.Name)
+		log.Errorf("%+v", tbr)
+		return nil, tbr
+	}
 	if err != nil {
 		tbr := errors.Wrap(err, "")
 		log.Errorf("%+v", tbr)
@@ -152,6 +180,12 @@ func (svc *HostService) Create(name string, net string, cpu int, ram float32, di
 	}
 
 	log.Printf("SSH service started on host '%s'.", host.Name)
+	pinned, err := pinHostKey(svc.provider, host.ID, ssh)
+	if err != nil {
+		log.Warnf("Failed to pin host key of host '%s': %v", host.Name, err)
+	} else {
+		host = pinned
+	}
 	return host, nil
 }
 
@@ -160,6 +194,41 @@ func (svc *HostService) List(all bool) ([]api.Host, error) {
 	return svc.provider.ListHosts(all)
 }
 
+// ListOnNetwork returns the hosts attached to the network identified by ref, including its gateway
+func (svc *HostService) ListOnNetwork(ref string) ([]api.Host, error) {
+	m, err := metadata.LoadNetwork(svc.provider, ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read metadata of network '%s'", ref)
+	}
+	if m == nil {
+		return nil, errors.Wrap(providers.ResourceNotFoundError("network", ref), "Cannot list hosts")
+	}
+	attached, err := m.ListHosts()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to list hosts of network '%s'", ref)
+	}
+	ids := map[string]bool{}
+	for _, host := range attached {
+		ids[host.ID] = true
+	}
+	if gw := m.Get().GatewayID; gw != "" {
+		ids[gw] = true
+	}
+
+	// The hosts attached are read again, their copy in the metadata of the network may be outdated
+	hosts, err := svc.List(false)
+	if err != nil {
+		return nil, err
+	}
+	var list []api.Host
+	for _, host := range hosts {
+		if ids[host.ID] {
+			list = append(list, host)
+		}
+	}
+	return list, nil
+}
+
 // Get returns the host identified by ref, ref can be the name or the id
 func (svc *HostService) Get(ref string) (*api.Host, error) {
 	return svc.provider.GetHost(ref)
@@ -167,7 +236,18 @@ func (svc *HostService) Get(ref string) (*api.Host, error) {
 
 // Delete deletes host referenced by ref
 func (svc *HostService) Delete(ref string) error {
-	return svc.provider.DeleteHost(ref)
+	// The SSH config is read before the deletion, to forget the host key of the host once deleted
+	ssh, _ := svc.SSH(ref)
+	err := svc.provider.DeleteHost(ref)
+	if err != nil {
+		return err
+	}
+	if ssh != nil {
+		if err := system.ForgetHostKey(ssh); err != nil {
+			log.Warnf("Failed to forget host key of host '%s': %v", ref, err)
+		}
+	}
+	return nil
 }
 
 // SSH returns ssh parameters to access the host referenced by ref
@@ -182,3 +262,187 @@ func (svc *HostService) SSH(ref string) (*system.SSHConfig, error) {
 
 	return svc.provider.GetSSHConfig(host.ID)
 }
+
+// Import creates the metadata of a host existing on the provider but not managed by SafeScale
+// If net is set, the host is attached to this network and reached through its gateway; privateKey is the key used to
+// connect to the host with SSH
+func (svc *HostService) Import(ref string, net string, privateKey string) (*api.Host, error) {
+	log.Printf("Importing host '%s'...", ref)
+	m, err := metadata.LoadHost(svc.provider, ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read metadata of host '%s'", ref)
+	}
+	if m != nil {
+		return nil, fmt.Errorf("host '%s' is already managed by SafeScale", ref)
+	}
+
+	host, err := svc.provider.GetHost(ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get host '%s'", ref)
+	}
+	if host == nil {
+		return nil, fmt.Errorf("host '%s' not found", ref)
+	}
+	m, err = metadata.LoadHostByName(svc.provider, host.Name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read metadata of host '%s'", host.Name)
+	}
+	if m != nil {
+		return nil, fmt.Errorf("a host named '%s' is already managed by SafeScale", host.Name)
+	}
+	host.PrivateKey = privateKey
+
+	netID := ""
+	if net != "" {
+		mn, err := metadata.LoadNetwork(svc.provider, net)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read metadata of network '%s'", net)
+		}
+		if mn == nil {
+			return nil, fmt.Errorf("network '%s' is not managed by SafeScale, import it first", net)
+		}
+		network := mn.Get()
+		netID = network.ID
+		if network.GatewayID != host.ID {
+			mgw, err := metadata.LoadGateway(svc.provider, network.ID)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Failed to read gateway metadata of network '%s'", network.Name)
+			}
+			if mgw != nil {
+				host.GatewayID = mgw.Get().ID
+			}
+		}
+	}
+
+	err = metadata.SaveHost(svc.provider, host, netID)
+	if err != nil {
+		tbr := errors.Wrapf(err, "Failed to save metadata of host '%s'", host.Name)
+		log.Errorf("%+v", tbr)
+		return nil, tbr
+	}
+	log.Printf("Host '%s' imported.", host.Name)
+	return svc.Get(host.ID)
+}
+
+// Rekey replaces the host key pinned for the host identified by ref by the key it currently presents, when this key
+// has legitimately changed (host reinstalled, SSH server reconfigured, ...)
+// The key presented must have the SHA256 fingerprint fingerprint, obtained from the host by another channel than SSH
+// (console of the provider, ...), so that the key of a man in the middle is never pinned
+func (svc *HostService) Rekey(ref string, fingerprint string) (*api.Host, error) {
+	if fingerprint == "" {
+		return nil, fmt.Errorf("the fingerprint of the new key of host '%s' is required", ref)
+	}
+	ssh, err := svc.SSH(ref)
+	if err != nil {
+		return nil, err
+	}
+	scanned := *ssh
+	scanned.HostKey = ""
+	key, err := scanned.ScanHostKey()
+	if err != nil {
+		return nil, err
+	}
+	presented, err := system.HostKeyFingerprint(key)
+	if err != nil {
+		return nil, err
+	}
+	if presented != fingerprint {
+		return nil, fmt.Errorf("host '%s' presents a key with fingerprint '%s' instead of '%s'", ref, presented, fingerprint)
+	}
+	scanned.HostKey = key
+	host, err := pinHostKey(svc.provider, ref, &scanned)
+	if err != nil {
+		return nil, err
+	}
+	// The key may also have been pinned in the known hosts before being stored in metadata
+	err = system.ForgetHostKey(ssh)
+	if err != nil {
+		log.Warnf("Failed to forget host key of host '%s' in known hosts: %v", ref, err)
+	}
+	return host, nil
+}
+
+// pinHostKey stores in the metadata of the host identified by ref the key of its SSH server, which is checked by all
+// the following connections
+// If ssh.HostKey is not set, the key pinned is the one accepted by the connection to the host (checked against the
+// known hosts), not a key scanned again which may come from another server
+func pinHostKey(provider *providers.Service, ref string, ssh *system.SSHConfig) (*api.Host, error) {
+	key := ssh.HostKey
+	if key == "" {
+		var err error
+		key, err = ssh.AcceptedHostKey()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m, err := metadata.LoadHost(provider, ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read metadata of host '%s'", ref)
+	}
+	if m == nil {
+		return nil, errors.Wrap(providers.ResourceNotFoundError("host", ref), "Cannot pin host key")
+	}
+	err = m.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer m.Release()
+
+	// Reads again the metadata, they may have changed while waiting for the lock
+	host := m.Get()
+	found, err := m.ReadByID(host.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read metadata of host '%s'", ref)
+	}
+	if !found {
+		return nil, fmt.Errorf("metadata of host '%s' vanished", ref)
+	}
+	host = m.Get()
+	host.HostKey = key
+	err = m.Write()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to save metadata of host '%s'", host.Name)
+	}
+	return host, nil
+}
+
+// SetTags adds or replaces the tags 'set' and removes the tags 'remove' of the host identified by ref
+// Tags are stored in metadata and pushed to the provider if it supports them
+func (svc *HostService) SetTags(ref string, set map[string]string, remove []string) (*api.Host, error) {
+	m, err := metadata.LoadHost(svc.provider, ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read metadata of host '%s'", ref)
+	}
+	if m == nil {
+		return nil, errors.Wrap(providers.ResourceNotFoundError("host", ref), "Cannot tag host")
+	}
+	err = m.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer m.Release()
+
+	// Reads again the metadata, they may have changed while waiting for the lock
+	host := m.Get()
+	found, err := m.ReadByID(host.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read metadata of host '%s'", ref)
+	}
+	if !found {
+		return nil, fmt.Errorf("metadata of host '%s' vanished", ref)
+	}
+	host = m.Get()
+	previous := host.Tags
+	host.Tags = tags.Update(host.Tags, set, remove)
+
+	err = svc.provider.SetHostTags(host.ID, host.Tags, tags.Removed(previous, host.Tags))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to set tags of host '%s' on provider", host.Name)
+	}
+	err = m.Write()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to save metadata of host '%s'", host.Name)
+	}
+	return host, nil
+}
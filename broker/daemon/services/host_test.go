@@ -0,0 +1,133 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/enums/IPVersion"
+	"github.com/CS-SI/SafeScale/providers/local"
+	"github.com/CS-SI/SafeScale/providers/metadata"
+	"github.com/CS-SI/SafeScale/system"
+)
+
+func TestPinHostKey(t *testing.T) {
+	clt, err := (&local.Client{}).Build(map[string]interface{}{"name": t.Name(), "TransitionDelay": "50ms"})
+	require.Nil(t, err)
+	provider := providers.FromClient(clt)
+
+	network, err := provider.CreateNetwork(api.NetworkRequest{Name: "net1", IPVersion: IPVersion.IPv4, CIDR: "192.168.1.0/24"})
+	require.Nil(t, err)
+	gw, err := provider.CreateGateway(api.GWRequest{ImageID: "local-ubuntu-1604", NetworkID: network.ID, TemplateID: "local-tiny"})
+	require.Nil(t, err)
+
+	key := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"
+	host, err := pinHostKey(provider, gw.Name, &system.SSHConfig{HostKey: key})
+	require.Nil(t, err)
+	assert.Equal(t, key, host.HostKey)
+
+	got, err := provider.GetHost(gw.ID)
+	require.Nil(t, err)
+	assert.Equal(t, key, got.HostKey)
+	ssh, err := provider.GetSSHConfig(gw.ID)
+	require.Nil(t, err)
+	assert.Equal(t, key, ssh.HostKey)
+
+	_, err = pinHostKey(provider, "unknown", &system.SSHConfig{HostKey: key})
+	assert.NotNil(t, err)
+}
+
+func TestListOnNetwork(t *testing.T) {
+	clt, err := (&local.Client{}).Build(map[string]interface{}{"name": t.Name(), "TransitionDelay": "50ms"})
+	require.Nil(t, err)
+	provider := providers.FromClient(clt)
+
+	net1, err := provider.CreateNetwork(api.NetworkRequest{Name: "net1", IPVersion: IPVersion.IPv4, CIDR: "192.168.1.0/24"})
+	require.Nil(t, err)
+	gw, err := provider.CreateGateway(api.GWRequest{ImageID: "local-ubuntu-1604", NetworkID: net1.ID, TemplateID: "local-tiny"})
+	require.Nil(t, err)
+	host, err := provider.CreateHost(api.HostRequest{Name: "host1", ImageID: "local-ubuntu-1604", TemplateID: "local-small", NetworkIDs: []string{net1.ID}})
+	require.Nil(t, err)
+	net2, err := provider.CreateNetwork(api.NetworkRequest{Name: "net2", IPVersion: IPVersion.IPv4, CIDR: "192.168.2.0/24"})
+	require.Nil(t, err)
+	_, err = provider.CreateGateway(api.GWRequest{ImageID: "local-ubuntu-1604", NetworkID: net2.ID, TemplateID: "local-tiny"})
+	require.Nil(t, err)
+
+	svc := NewHostService(clt)
+	hosts, err := svc.ListOnNetwork("net1")
+	require.Nil(t, err)
+	var ids []string
+	for _, h := range hosts {
+		ids = append(ids, h.ID)
+	}
+	expected := []string{gw.ID, host.ID}
+	sort.Strings(expected)
+	sort.Strings(ids)
+	assert.Equal(t, expected, ids)
+
+	_, err = svc.ListOnNetwork("unknown")
+	assert.NotNil(t, err)
+}
+
+func TestHostService_Import(t *testing.T) {
+	clt, err := (&local.Client{}).Build(map[string]interface{}{"name": t.Name(), "TransitionDelay": "50ms"})
+	require.Nil(t, err)
+	svc := NewHostService(clt).(*HostService)
+
+	network, err := svc.provider.CreateNetwork(api.NetworkRequest{Name: "net1", IPVersion: IPVersion.IPv4, CIDR: "192.168.1.0/24"})
+	require.Nil(t, err)
+	gw, err := svc.provider.CreateGateway(api.GWRequest{ImageID: "local-ubuntu-1604", NetworkID: network.ID, TemplateID: "local-tiny"})
+	require.Nil(t, err)
+	host, err := svc.provider.CreateHost(api.HostRequest{Name: "host1", ImageID: "local-ubuntu-1604", TemplateID: "local-small", NetworkIDs: []string{network.ID}})
+	require.Nil(t, err)
+	// Host created behind SafeScale's back
+	require.Nil(t, metadata.RemoveHost(svc.provider, host))
+	m, err := metadata.LoadHost(svc.provider, "host1")
+	require.Nil(t, err)
+	assert.Nil(t, m)
+
+	_, err = svc.Import("host1", "unknown", "private key")
+	assert.NotNil(t, err)
+	_, err = svc.Import("unknown", "net1", "private key")
+	assert.NotNil(t, err)
+
+	imported, err := svc.Import("host1", "net1", "private key")
+	require.Nil(t, err)
+	assert.Equal(t, host.ID, imported.ID)
+	m, err = metadata.LoadHost(svc.provider, "host1")
+	require.Nil(t, err)
+	require.NotNil(t, m)
+	// The host reaches the outside through the gateway of its network, with the key given
+	assert.Equal(t, gw.ID, m.Get().GatewayID)
+	assert.Equal(t, "private key", m.Get().PrivateKey)
+	hosts, err := svc.ListOnNetwork("net1")
+	require.Nil(t, err)
+	var ids []string
+	for _, h := range hosts {
+		ids = append(ids, h.ID)
+	}
+	assert.Contains(t, ids, host.ID)
+
+	_, err = svc.Import("host1", "net1", "private key")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	providermetadata "github.com/CS-SI/SafeScale/providers/metadata"
+	"github.com/CS-SI/SafeScale/utils/metadata"
+)
+
+//go:generate mockgen -destination=../mocks/mock_metadataapi.go -package=mocks github.com/CS-SI/SafeScale/broker/daemon/services MetadataAPI
+
+//MetadataAPI defines API to manage the metadata bucket of the tenant
+type MetadataAPI interface {
+	ListLocks() ([]metadata.Lease, error)
+	BreakLock(key string, force bool) error
+	Reencrypt() (int, error)
+	Migrate(dryRun bool) ([]metadata.Migration, error)
+	Doctor(repair bool, doImport bool) ([]providermetadata.Finding, error)
+}
+
+//NewMetadataService creates a metadata service
+func NewMetadataService(api api.ClientAPI) MetadataAPI {
+	return &MetadataService{
+		provider: providers.FromClient(api),
+	}
+}
+
+// MetadataService metadata service
+type MetadataService struct {
+	provider *providers.Service
+}
+
+// ListLocks returns the locks stored in the metadata bucket
+func (srv *MetadataService) ListLocks() ([]metadata.Lease, error) {
+	return metadata.ListLocks(srv.provider)
+}
+
+// BreakLock removes the lock 'key' from the metadata bucket
+func (srv *MetadataService) BreakLock(key string, force bool) error {
+	return metadata.BreakLock(srv.provider, key, force)
+}
+
+// Reencrypt rewrites all the metadata with the current key of the tenant
+func (srv *MetadataService) Reencrypt() (int, error) {
+	return metadata.Reencrypt(srv.provider)
+}
+
+// Migrate upgrades all the metadata to the current version of their format
+func (srv *MetadataService) Migrate(dryRun bool) ([]metadata.Migration, error) {
+	return metadata.Migrate(srv.provider, dryRun)
+}
+
+// Doctor cross-checks the metadata against the resources of the provider
+// If repair is true, metadata of resources which don't exist anymore are removed; if doImport is true, metadata are
+// created for the resources not tracked by SafeScale
+func (srv *MetadataService) Doctor(repair bool, doImport bool) ([]providermetadata.Finding, error) {
+	doctor := providermetadata.NewDoctor(srv.provider)
+	doctor.Repair = repair
+	doctor.Import = doImport
+	return doctor.Check()
+}
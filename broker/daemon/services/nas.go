@@ -20,14 +20,20 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"net"
 	"path"
 	"strings"
+	"sync"
 
 	"github.com/CS-SI/SafeScale/providers"
 	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/enums/VolumeSpeed"
+	"github.com/CS-SI/SafeScale/providers/filters/tags"
 	"github.com/CS-SI/SafeScale/providers/metadata"
 
+	"github.com/CS-SI/SafeScale/system"
 	"github.com/CS-SI/SafeScale/system/nfs"
+	"github.com/CS-SI/SafeScale/system/nfs/SecurityFlavor"
 
 	"github.com/satori/go.uuid"
 )
@@ -36,12 +42,17 @@ import (
 
 //NasAPI defines API to manipulate NAS
 type NasAPI interface {
-	Create(name, host, path string) (*api.Nas, error)
+	Create(name, host, path string, acls []api.NasACL) (*api.Nas, error)
+	CreateHA(name, host, secondary, path string, volumeSize int, acls []api.NasACL) (*api.Nas, error)
+	Failover(name string) (*api.Nas, error)
 	Delete(name string) (*api.Nas, error)
 	List() ([]api.Nas, error)
-	Mount(name, host, path string) (*api.Nas, error)
+	Mount(name, host, path string, readOnly bool, securityMode string) (*api.Nas, error)
 	UMount(name, host string) (*api.Nas, error)
 	Inspect(name string) ([]*api.Nas, error)
+	SetTags(name string, set map[string]string, remove []string) (*api.Nas, error)
+	AddACL(name string, acl api.NasACL) (*api.Nas, error)
+	RemoveACL(name, host string) (*api.Nas, error)
 }
 
 // NewNasService creates a NAS service
@@ -66,8 +77,41 @@ func sanitize(in string) (string, error) {
 	return sanitized, nil
 }
 
+//toExportAcls checks the ACLs of a nas and converts them to the ACLs of its NFS export
+func toExportAcls(acls []api.NasACL) ([]nfs.ExportAcl, error) {
+	var exportAcls []nfs.ExportAcl
+	for _, acl := range acls {
+		err := nfs.ValidateHostPattern(acl.Host)
+		if err != nil {
+			return nil, err
+		}
+		var modes []SecurityFlavor.Enum
+		for _, mode := range acl.SecurityModes {
+			flavor, err := SecurityFlavor.Parse(mode)
+			if err != nil {
+				return nil, fmt.Errorf("invalid security mode '%s' in ACL of '%s'", mode, acl.Host)
+			}
+			modes = append(modes, flavor)
+		}
+		exportAcls = append(exportAcls, nfs.ExportAcl{
+			Host:          acl.Host,
+			SecurityModes: modes,
+			Options: nfs.ExportOptions{
+				ReadOnly:       acl.ReadOnly,
+				NoRootSquash:   acl.NoRootSquash,
+				Secure:         acl.Secure,
+				Async:          acl.Async,
+				NoSubtreeCheck: acl.NoSubtreeCheck,
+				AnonUID:        acl.AnonUID,
+				AnonGID:        acl.AnonGID,
+			},
+		})
+	}
+	return exportAcls, nil
+}
+
 //Create a nas
-func (srv *NasService) Create(name, hostName, path string) (*api.Nas, error) {
+func (srv *NasService) Create(name, hostName, path string, acls []api.NasACL) (*api.Nas, error) {
 
 	// Check if a nas already exist with the same name
 	nas, err := srv.findNas(name)
@@ -88,6 +132,11 @@ func (srv *NasService) Create(name, hostName, path string) (*api.Nas, error) {
 		return nil, tbr
 	}
 
+	exportAcls, err := toExportAcls(acls)
+	if err != nil {
+		return nil, err
+	}
+
 	host, err := srv.hostService.Get(hostName)
 	if err != nil || host == nil {
 		tbr := errors.Wrap(err, "")
@@ -115,7 +164,7 @@ func (srv *NasService) Create(name, hostName, path string) (*api.Nas, error) {
 		return nil, tbr
 	}
 
-	err = server.AddShare(exportedPath, "")
+	err = server.AddShare(exportedPath, exportAcls)
 	if err != nil {
 		tbr := errors.Wrap(err, "")
 		log.Errorf("%+v", tbr)
@@ -129,6 +178,7 @@ func (srv *NasService) Create(name, hostName, path string) (*api.Nas, error) {
 		Host:     host.Name,
 		Path:     exportedPath,
 		IsServer: true,
+		ACLs:     acls,
 	}
 
 	// TODO OPP Check this
@@ -167,6 +217,22 @@ func (srv *NasService) Delete(name string) (*api.Nas, error) {
 
 	nas := nass[0]
 
+	if nas.VIP != nil {
+		err = srv.deleteHA(nas)
+		if err != nil {
+			tbr := errors.Wrap(err, "")
+			log.Errorf("%+v", tbr)
+			return nil, tbr
+		}
+		err = srv.removeNASDefinition(*nas)
+		if err != nil {
+			tbr := errors.Wrap(err, "")
+			log.Errorf("%+v", tbr)
+			return nas, tbr
+		}
+		return nas, nil
+	}
+
 	host, err := srv.hostService.Get(nas.Host)
 	if err != nil {
 		tbr := errors.Wrap(err, "")
@@ -223,13 +289,22 @@ func (srv *NasService) List() ([]api.Nas, error) {
 }
 
 //Mount a directory exported by a nas on a local directory of an host
-func (srv *NasService) Mount(name, hostName, path string) (*api.Nas, error) {
+//securityMode is the security flavor used to access the directory, 'sys' if empty
+func (srv *NasService) Mount(name, hostName, path string, readOnly bool, securityMode string) (*api.Nas, error) {
 	// Sanitize path
 	mountPath, err := sanitize(path)
 	if err != nil {
 		return nil, fmt.Errorf("Invalid path to be mounted: '%s' : '%s'", path, err)
 	}
 
+	flavor := SecurityFlavor.Sys
+	if securityMode != "" {
+		flavor, err = SecurityFlavor.Parse(securityMode)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid security mode '%s'", securityMode)
+		}
+	}
+
 	nas, err := srv.findNas(name)
 	if err != nil {
 		tbr := errors.Wrap(err, "")
@@ -273,7 +348,10 @@ func (srv *NasService) Mount(name, hostName, path string) (*api.Nas, error) {
 		return nil, tbr
 	}
 
-	err = nsfclient.Mount(nfsServer.GetAccessIP(), nas.Path, mountPath)
+	err = nsfclient.Mount(serverAddress(nas, nfsServer), nas.Path, mountPath, nfs.MountOptions{
+		ReadOnly:     readOnly,
+		SecurityMode: flavor,
+	})
 	if err != nil {
 		tbr := errors.Wrap(err, "")
 		log.Errorf("%+v", tbr)
@@ -285,8 +363,10 @@ func (srv *NasService) Mount(name, hostName, path string) (*api.Nas, error) {
 		ID:       nasid.String(),
 		Name:     name,
 		Host:     host.Name,
-		Path:     mountPath,
-		IsServer: false,
+		Path:         mountPath,
+		IsServer:     false,
+		ReadOnly:     readOnly,
+		SecurityMode: strings.ToLower(flavor.String()),
 	}
 	err = metadata.MountNas(srv.provider, client, nas)
 	if err != nil {
@@ -342,7 +422,7 @@ func (srv *NasService) UMount(name, hostName string) (*api.Nas, error) {
 			return nil, tbr
 		}
 
-		err = nsfclient.Unmount(nfsServer.GetAccessIP(), nas.Path)
+		err = nsfclient.Unmount(serverAddress(nas, nfsServer), nas.Path)
 		if err != nil {
 			tbr := errors.Wrap(err, "")
 			log.Errorf("%+v", tbr)
@@ -426,3 +506,599 @@ func (srv *NasService) findClient(nasName, hostName string) (*api.Nas, error) {
 
 	return client, err
 }
+
+// SetTags adds or replaces the tags 'set' and removes the tags 'remove' of the nas 'name'
+func (srv *NasService) SetTags(name string, set map[string]string, remove []string) (*api.Nas, error) {
+	m, err := metadata.LoadNas(srv.provider, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read metadata of nas '%s'", name)
+	}
+	if m == nil {
+		return nil, errors.Wrap(providers.ResourceNotFoundError("NAS", name), "Cannot tag NAS")
+	}
+	err = m.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer m.Release()
+
+	// Reads again the metadata, they may have changed while waiting for the lock
+	nas := m.Get()
+	found, err := m.ReadByID(nas.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read metadata of nas '%s'", name)
+	}
+	if !found {
+		return nil, fmt.Errorf("metadata of nas '%s' vanished", name)
+	}
+	nas = m.Get()
+	nas.Tags = tags.Update(nas.Tags, set, remove)
+	err = m.Write()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to save metadata of nas '%s'", nas.Name)
+	}
+	return nas, nil
+}
+
+// AddACL adds an ACL to the export of the nas 'name', replacing the ACL of the same host pattern if any
+func (srv *NasService) AddACL(name string, acl api.NasACL) (*api.Nas, error) {
+	return srv.updateACLs(name, func(acls []api.NasACL) ([]api.NasACL, error) {
+		for i, a := range acls {
+			if a.Host == acl.Host {
+				acls[i] = acl
+				return acls, nil
+			}
+		}
+		return append(acls, acl), nil
+	})
+}
+
+// RemoveACL removes the ACL of the host pattern 'host' from the export of the nas 'name'
+func (srv *NasService) RemoveACL(name, host string) (*api.Nas, error) {
+	return srv.updateACLs(name, func(acls []api.NasACL) ([]api.NasACL, error) {
+		for i, a := range acls {
+			if a.Host == host {
+				return append(acls[:i], acls[i+1:]...), nil
+			}
+		}
+		return nil, fmt.Errorf("no ACL for '%s' in nas '%s'", host, name)
+	})
+}
+
+// updateACLs exports again the directory of the nas 'name' with the ACLs returned by update, and saves them
+func (srv *NasService) updateACLs(name string, update func(acls []api.NasACL) ([]api.NasACL, error)) (*api.Nas, error) {
+	m, err := metadata.LoadNas(srv.provider, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read metadata of nas '%s'", name)
+	}
+	if m == nil {
+		return nil, errors.Wrap(providers.ResourceNotFoundError("NAS", name), "Cannot update ACLs of NAS")
+	}
+	err = m.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer m.Release()
+
+	// Reads again the metadata, they may have changed while waiting for the lock
+	nas := m.Get()
+	found, err := m.ReadByID(nas.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read metadata of nas '%s'", name)
+	}
+	if !found {
+		return nil, fmt.Errorf("metadata of nas '%s' vanished", name)
+	}
+	nas = m.Get()
+
+	acls, err := update(append([]api.NasACL{}, nas.ACLs...))
+	if err != nil {
+		return nil, err
+	}
+	exportAcls, err := toExportAcls(acls)
+	if err != nil {
+		return nil, err
+	}
+
+	if nas.VIP != nil {
+		members, err := srv.haMembers(nas)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			err = m.server.AddHAShare(m.config, exportAcls)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Failed to export again the directory of nas '%s' on host '%s'", name, m.host.Name)
+			}
+		}
+	} else {
+		host, err := srv.hostService.Get(nas.Host)
+		if err != nil {
+			return nil, errors.Wrap(providers.ResourceNotFoundError("host", nas.Host), "Cannot update ACLs of NAS")
+		}
+		sshConfig, err := srv.provider.GetSSHConfig(host.ID)
+		if err != nil {
+			return nil, err
+		}
+		server, err := nfs.NewServer(sshConfig)
+		if err != nil {
+			return nil, err
+		}
+		err = server.AddShare(nas.Path, exportAcls)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to export again the directory of nas '%s'", name)
+		}
+	}
+
+	nas.ACLs = acls
+	err = m.Write()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to save metadata of nas '%s'", nas.Name)
+	}
+	return nas, nil
+}
+
+//haMember is one of the two servers of a highly available nas
+type haMember struct {
+	host   *api.Host
+	server *nfs.Server
+	config nfs.HAConfig
+}
+
+//CreateHA creates a highly available nas: the storage of 'hostName' is replicated on a volume of 'secondaryName',
+//and the clients mount it through a virtual IP address held by the active server
+func (srv *NasService) CreateHA(name, hostName, secondaryName, path string, volumeSize int, acls []api.NasACL) (nas *api.Nas, err error) {
+	nas, err = srv.findNas(name)
+	if err != nil {
+		tbr := errors.Wrap(err, "")
+		log.Errorf("%+v", tbr)
+		return nil, tbr
+	}
+	if nas != nil {
+		return nil, providers.ResourceAlreadyExistsError("NAS", name)
+	}
+	if volumeSize <= 0 {
+		return nil, fmt.Errorf("Invalid size of the replicated volumes: %d GB", volumeSize)
+	}
+	if hostName == secondaryName {
+		return nil, fmt.Errorf("The secondary server of a NAS has to be another host than '%s'", hostName)
+	}
+
+	exportedPath, err := sanitize(path)
+	if err != nil {
+		tbr := errors.Wrap(err, "")
+		log.Errorf("%+v", tbr)
+		return nil, tbr
+	}
+	exportAcls, err := toExportAcls(acls)
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []*api.Host
+	for _, ref := range []string{hostName, secondaryName} {
+		host, err := srv.hostService.Get(ref)
+		if err != nil || host == nil {
+			return nil, errors.Wrap(providers.ResourceNotFoundError("host", ref), "Cannot create NAS")
+		}
+		hosts = append(hosts, host)
+	}
+	network, err := srv.findCommonNetwork(hosts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot create NAS")
+	}
+
+	// Undoes what has been done if the creation fails
+	var undo []func() error
+	defer func() {
+		if err != nil {
+			for i := len(undo) - 1; i >= 0; i-- {
+				if derr := undo[i](); derr != nil {
+					log.Warnf("Failed to clean up after the failed creation of NAS '%s': %v", name, derr)
+				}
+			}
+		}
+	}()
+
+	nasid, _ := uuid.NewV4()
+	nas = &api.Nas{
+		ID:        nasid.String(),
+		Name:      name,
+		Host:      hosts[0].Name,
+		Path:      exportedPath,
+		IsServer:  true,
+		ACLs:      acls,
+		Secondary: hosts[1].Name,
+	}
+	err = srv.reserveHAID(nas)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot create NAS '%s'", name)
+	}
+	reserved := *nas
+	undo = append(undo, func() error { return srv.removeNASDefinition(reserved) })
+
+	vip, err := srv.provider.CreateVIP(network.ID, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create the virtual IP of NAS '%s'", name)
+	}
+	undo = append(undo, func() error { return srv.provider.DeleteVIP(vip) })
+	for _, host := range hosts {
+		err = srv.provider.BindHostToVIP(vip, host.ID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to bind host '%s' to the virtual IP of NAS '%s'", host.Name, name)
+		}
+	}
+
+	volumes := &VolumeService{provider: srv.provider}
+	var volumeNames []string
+	for _, host := range hosts {
+		volume, err := srv.provider.CreateVolume(api.VolumeRequest{
+			Name:  fmt.Sprintf("%s-%s", name, host.Name),
+			Size:  volumeSize,
+			Speed: VolumeSpeed.HDD,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to create the volume of NAS '%s' for host '%s'", name, host.Name)
+		}
+		volumeNames = append(volumeNames, volume.Name)
+		undo = append(undo, func() error { return srv.provider.DeleteVolume(volume.ID) })
+
+		volatt, err := volumes.attachDevice(volume, host)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to attach volume '%s' to host '%s'", volume.Name, host.Name)
+		}
+		hostID := host.ID
+		undo = append(undo, func() error { return srv.detachVolume(volume.Name, hostID) })
+		mtdVol, err := metadata.LoadVolume(srv.provider, volume.ID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to load metadata of volume '%s'", volume.Name)
+		}
+		if mtdVol == nil {
+			return nil, errors.Wrap(providers.ResourceNotFoundError("volume", volume.Name), "Cannot create NAS")
+		}
+		err = mtdVol.Attach(volatt)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to save metadata of volume '%s'", volume.Name)
+		}
+	}
+
+	nas.VIP = vip
+	nas.Volumes = volumeNames
+	members, err := srv.haMembers(nas)
+	if err != nil {
+		return nil, err
+	}
+	gateway, err := srv.provider.GetSSHConfig(network.GatewayID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get the SSH configuration of the gateway of NAS '%s'", name)
+	}
+	err = srv.setHAFencing(network, gateway, members)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to configure the fencing of NAS '%s'", name)
+	}
+	servers := []system.SSHConfig{members[0].config.Fencing.Peer, members[1].config.Fencing.Peer}
+	err = nfs.AllowHAFencing(gateway, nas.Name, members[0].config.Fencing.PublicKey, servers)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to allow the fencing of NAS '%s' through the gateway", name)
+	}
+	undo = append(undo, func() error { return nfs.RevokeHAFencing(gateway, nas.Name) })
+	for _, m := range members {
+		err = m.server.Install()
+		if err == nil {
+			err = m.server.InstallHA(m.config)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to install NFS server on host '%s'", m.host.Name)
+		}
+	}
+	// The primary server has to format the storage and hold the VIP before the secondary one starts
+	for _, m := range members {
+		err = m.server.StartHA(m.config)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to start NFS high availability on host '%s'", m.host.Name)
+		}
+	}
+	for _, m := range members {
+		err = m.server.AddHAShare(m.config, exportAcls)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to export the directory of NAS '%s' on host '%s'", name, m.host.Name)
+		}
+	}
+
+	err = srv.saveNASDefinition(*nas)
+	if err != nil {
+		tbr := errors.Wrap(err, "Error saving NAS definition")
+		log.Errorf("%+v", tbr)
+		return nil, tbr
+	}
+	return nas, nil
+}
+
+//Failover makes the secondary server of a highly available nas take over from the active one
+func (srv *NasService) Failover(name string) (*api.Nas, error) {
+	nas, err := srv.findNas(name)
+	if err != nil {
+		tbr := errors.Wrap(err, "")
+		log.Errorf("%+v", tbr)
+		return nil, tbr
+	}
+	if nas == nil {
+		return nil, errors.Wrap(providers.ResourceNotFoundError("NAS", name), "Cannot fail over NAS")
+	}
+	if nas.VIP == nil {
+		return nil, fmt.Errorf("NAS '%s' is not highly available", name)
+	}
+
+	members, err := srv.haMembers(nas)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		active, err := m.server.IsActive(m.config)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get the state of NAS '%s' on host '%s'", name, m.host.Name)
+		}
+		if active {
+			err = m.server.Failover(m.config)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Failed to fail over NAS '%s' from host '%s'", name, m.host.Name)
+			}
+			return nas, nil
+		}
+	}
+	return nil, fmt.Errorf("No server of NAS '%s' holds its virtual IP %s", name, nas.VIP.PrivateIP)
+}
+
+//deleteHA stops the replication and the failover of a highly available nas, then deletes its VIP and volumes
+func (srv *NasService) deleteHA(nas *api.Nas) error {
+	members, err := srv.haMembers(nas)
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		err = m.server.UninstallHA(m.config)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to stop NAS '%s' on host '%s'", nas.Name, m.host.Name)
+		}
+	}
+	for _, m := range members {
+		err = srv.provider.UnbindHostFromVIP(nas.VIP, m.host.ID)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to unbind host '%s' from the virtual IP of NAS '%s'", m.host.Name, nas.Name)
+		}
+	}
+	network, err := srv.provider.GetNetwork(nas.VIP.NetworkID)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to get the network of NAS '%s'", nas.Name)
+	}
+	gateway, err := srv.provider.GetSSHConfig(network.GatewayID)
+	if err == nil {
+		err = nfs.RevokeHAFencing(gateway, nas.Name)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Failed to revoke the fencing of NAS '%s' from the gateway", nas.Name)
+	}
+	err = srv.provider.DeleteVIP(nas.VIP)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to delete the virtual IP of NAS '%s'", nas.Name)
+	}
+	for i, m := range members {
+		err = srv.detachVolume(nas.Volumes[i], m.host.ID)
+		if err != nil {
+			return err
+		}
+		mtdVol, err := metadata.LoadVolume(srv.provider, nas.Volumes[i])
+		if err != nil {
+			return errors.Wrapf(err, "Failed to load metadata of volume '%s'", nas.Volumes[i])
+		}
+		if mtdVol == nil {
+			return errors.Wrap(providers.ResourceNotFoundError("volume", nas.Volumes[i]), "Cannot delete NAS")
+		}
+		err = srv.provider.DeleteVolume(mtdVol.Get().ID)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to delete volume '%s'", nas.Volumes[i])
+		}
+	}
+	return nil
+}
+
+//detachVolume detaches the volume 'volumeName' from the host 'hostID' and removes the attachment from metadata
+func (srv *NasService) detachVolume(volumeName, hostID string) error {
+	mtdVol, err := metadata.LoadVolume(srv.provider, volumeName)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to load metadata of volume '%s'", volumeName)
+	}
+	if mtdVol == nil {
+		return errors.Wrap(providers.ResourceNotFoundError("volume", volumeName), "Cannot detach volume")
+	}
+	volume := mtdVol.Get()
+	err = srv.provider.DeleteVolumeAttachment(hostID, volume.ID)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to detach volume '%s'", volumeName)
+	}
+	volatt := &api.VolumeAttachment{ServerID: hostID, VolumeID: volume.ID}
+	err = metadata.RemoveVolumeAttachment(srv.provider, hostID, volume.ID)
+	if err != nil {
+		log.Warnf("Failed to remove metadata of the attachment of volume '%s': %v", volumeName, err)
+	}
+	return mtdVol.Detach(volatt)
+}
+
+//findCommonNetwork returns the network containing a private IP address of each host
+func (srv *NasService) findCommonNetwork(hosts []*api.Host) (*api.Network, error) {
+	networks, err := srv.provider.ListNetworks(false)
+	if err != nil {
+		return nil, err
+	}
+	for i := range networks {
+		inAll := true
+		for _, host := range hosts {
+			if privateIPIn(host, networks[i].CIDR) == "" {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			return &networks[i], nil
+		}
+	}
+	return nil, fmt.Errorf("the hosts do not share a network")
+}
+
+//privateIPIn returns the private IP address of the host within cidr, or an empty string
+func privateIPIn(host *api.Host, cidr string) string {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return ""
+	}
+	for _, ip := range host.PrivateIPsV4 {
+		if ipNet.Contains(net.ParseIP(ip)) {
+			return ip
+		}
+	}
+	return ""
+}
+
+//haIDLock serializes the allocation of the identifiers of highly available nas inside the process, the lock of the
+//metadata of the nas serializes it with the other processes
+var haIDLock sync.Mutex
+
+//reserveHAID allocates to nas the smallest identifier not used by another highly available nas, then saves its
+//definition, which reserves the identifier and the name of the nas until the end of its creation
+func (srv *NasService) reserveHAID(nas *api.Nas) error {
+	haIDLock.Lock()
+	defer haIDLock.Unlock()
+	m := metadata.NewNas(srv.provider)
+	err := m.Acquire()
+	if err != nil {
+		return err
+	}
+	defer m.Release()
+
+	list, err := srv.List()
+	if err != nil {
+		return err
+	}
+	used := map[int]bool{}
+	for i := range list {
+		if list[i].Name == nas.Name {
+			return providers.ResourceAlreadyExistsError("NAS", nas.Name)
+		}
+		used[list[i].HAID] = true
+	}
+	for id := 1; id <= 255; id++ {
+		if !used[id] {
+			nas.HAID = id
+			return srv.saveNASDefinition(*nas)
+		}
+	}
+	return fmt.Errorf("no identifier left for a highly available NAS, 255 of them exist")
+}
+
+//haMembers returns the primary then the secondary server of a highly available nas, with their HA configuration
+func (srv *NasService) haMembers(nas *api.Nas) ([]haMember, error) {
+	network, err := srv.provider.GetNetwork(nas.VIP.NetworkID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get the network of NAS '%s'", nas.Name)
+	}
+	_, ipNet, err := net.ParseCIDR(network.CIDR)
+	if err != nil {
+		return nil, err
+	}
+	prefix, _ := ipNet.Mask.Size()
+
+	var members []haMember
+	for i, ref := range []string{nas.Host, nas.Secondary} {
+		host, err := srv.hostService.Get(ref)
+		if err != nil || host == nil {
+			return nil, errors.Wrap(providers.ResourceNotFoundError("host", ref), "Cannot get servers of NAS")
+		}
+		mtdVol, err := metadata.LoadVolume(srv.provider, nas.Volumes[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to load metadata of volume '%s'", nas.Volumes[i])
+		}
+		if mtdVol == nil {
+			return nil, errors.Wrap(providers.ResourceNotFoundError("volume", nas.Volumes[i]), "Cannot get servers of NAS")
+		}
+		mtdVA, err := metadata.LoadVolumeAttachment(srv.provider, host.ID, mtdVol.Get().ID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to load the attachment of volume '%s'", nas.Volumes[i])
+		}
+		if mtdVA == nil {
+			return nil, fmt.Errorf("volume '%s' is not attached to host '%s'", nas.Volumes[i], host.Name)
+		}
+		sshConfig, err := srv.provider.GetSSHConfig(host.ID)
+		if err != nil {
+			return nil, err
+		}
+		server, err := nfs.NewServer(sshConfig)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, haMember{
+			host:   host,
+			server: server,
+			config: nfs.HAConfig{
+				Resource:    nas.Name,
+				ID:          nas.HAID,
+				LocalIP:     privateIPIn(host, network.CIDR),
+				LocalDevice: mtdVA.Get().Device,
+				VirtualIP:   fmt.Sprintf("%s/%d", nas.VIP.PrivateIP, prefix),
+				Path:        nas.Path,
+				Primary:     i == 0,
+			},
+		})
+	}
+	// Each server is the peer of the other
+	for i := range members {
+		peer := members[1-i]
+		members[i].config.PeerIP = peer.config.LocalIP
+		members[i].config.PeerDevice = peer.config.LocalDevice
+	}
+	return members, nil
+}
+
+//setHAFencing configures the members of a highly available nas to outdate the storage of each other through the
+//gateway of the network, with a key pair generated for them
+func (srv *NasService) setHAFencing(network *api.Network, gateway *system.SSHConfig, members []haMember) error {
+	gw, err := srv.provider.GetHost(network.GatewayID)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to get the gateway of network '%s'", network.Name)
+	}
+	gatewayIP := privateIPIn(gw, network.CIDR)
+	if gatewayIP == "" {
+		return fmt.Errorf("the gateway of network '%s' has no address in it", network.Name)
+	}
+	publicKey, privateKey, err := system.CreateKeyPair()
+	if err != nil {
+		return err
+	}
+	for i := range members {
+		peer := members[1-i]
+		members[i].config.Fencing = &nfs.HAFencing{
+			PrivateKey: string(privateKey),
+			PublicKey:  string(publicKey),
+			Peer: system.SSHConfig{
+				User:    peer.server.SshConfig.User,
+				Host:    peer.config.LocalIP,
+				Port:    peer.server.SshConfig.Port,
+				HostKey: peer.server.SshConfig.HostKey,
+			},
+			Gateway: system.SSHConfig{
+				User:    gateway.User,
+				Host:    gatewayIP,
+				Port:    gateway.Port,
+				HostKey: gateway.HostKey,
+			},
+		}
+	}
+	return nil
+}
+
+//serverAddress returns the address the clients of a nas mount it through
+func serverAddress(nas *api.Nas, server *api.Host) string {
+	if nas.VIP != nil {
+		return nas.VIP.PrivateIP
+	}
+	return server.GetAccessIP()
+}
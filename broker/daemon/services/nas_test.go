@@ -0,0 +1,149 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/enums/IPVersion"
+	"github.com/CS-SI/SafeScale/providers/local"
+	"github.com/CS-SI/SafeScale/providers/metadata"
+	"github.com/CS-SI/SafeScale/system"
+	"github.com/CS-SI/SafeScale/system/nfs"
+	"github.com/CS-SI/SafeScale/system/nfs/SecurityFlavor"
+)
+
+func TestToExportAcls(t *testing.T) {
+	acls, err := toExportAcls(nil)
+	require.Nil(t, err)
+	assert.Empty(t, acls)
+
+	acls, err = toExportAcls([]api.NasACL{
+		{Host: "10.0.0.0/24", SecurityModes: []string{"krb5", "KRB5p"}, ReadOnly: true, AnonUID: 1000},
+		{Host: "*"},
+	})
+	require.Nil(t, err)
+	require.Len(t, acls, 2)
+	assert.Equal(t, "10.0.0.0/24", acls[0].Host)
+	assert.Equal(t, []SecurityFlavor.Enum{SecurityFlavor.Krb5, SecurityFlavor.Krb5p}, acls[0].SecurityModes)
+	assert.True(t, acls[0].Options.ReadOnly)
+	assert.Equal(t, 1000, acls[0].Options.AnonUID)
+	assert.Equal(t, "*", acls[1].Host)
+	assert.Empty(t, acls[1].SecurityModes)
+
+	_, err = toExportAcls([]api.NasACL{{Host: "10.0.0.1", SecurityModes: []string{"krb4"}}})
+	assert.NotNil(t, err)
+	_, err = toExportAcls([]api.NasACL{{Host: ""}})
+	assert.NotNil(t, err)
+	_, err = toExportAcls([]api.NasACL{{Host: "10.0.0.1(rw)"}})
+	assert.NotNil(t, err)
+
+	// Host patterns are written in a script run as root
+	for _, host := range []string{`*"; reboot; "`, "$(reboot)", "`reboot`", "10.0.0.1;reboot", "10.0.0.1\nreboot", "-rw", "a b", "host@domain"} {
+		_, err = toExportAcls([]api.NasACL{{Host: host}})
+		assert.NotNil(t, err, host)
+	}
+	for _, host := range []string{"192.168.0.0/255.255.255.0", "fd00::/64", "node-?.example.com", "*.example.com"} {
+		_, err = toExportAcls([]api.NasACL{{Host: host}})
+		assert.Nil(t, err, host)
+	}
+}
+
+func TestPrivateIPIn(t *testing.T) {
+	host := &api.Host{PrivateIPsV4: []string{"172.16.0.5", "192.168.1.12"}}
+	assert.Equal(t, "192.168.1.12", privateIPIn(host, "192.168.1.0/24"))
+	assert.Equal(t, "172.16.0.5", privateIPIn(host, "172.16.0.0/16"))
+	assert.Equal(t, "", privateIPIn(host, "10.0.0.0/8"))
+	assert.Equal(t, "", privateIPIn(host, "invalid"))
+}
+
+func TestServerAddress(t *testing.T) {
+	server := &api.Host{AccessIPv4: "80.1.2.3"}
+	assert.Equal(t, "80.1.2.3", serverAddress(&api.Nas{}, server))
+	assert.Equal(t, "192.168.1.250", serverAddress(&api.Nas{VIP: &api.VIP{PrivateIP: "192.168.1.250"}}, server))
+}
+
+func TestReserveHAID(t *testing.T) {
+	clt, err := (&local.Client{}).Build(map[string]interface{}{"name": t.Name()})
+	require.Nil(t, err)
+	srv := &NasService{provider: providers.FromClient(clt)}
+
+	used := api.Nas{ID: "used", Name: "used", IsServer: true, HAID: 2, VIP: &api.VIP{PrivateIP: "192.168.1.2"}}
+	require.Nil(t, metadata.SaveNas(srv.provider, &used))
+	plain := api.Nas{ID: "plain", Name: "plain", IsServer: true}
+	require.Nil(t, metadata.SaveNas(srv.provider, &plain))
+
+	var ids []int
+	for _, name := range []string{"nas1", "nas2", "nas3"} {
+		nas := api.Nas{ID: name, Name: name, IsServer: true}
+		require.Nil(t, srv.reserveHAID(&nas))
+		ids = append(ids, nas.HAID)
+		found, err := srv.findNas(name)
+		require.Nil(t, err)
+		require.NotNil(t, found)
+		assert.Equal(t, nas.HAID, found.HAID)
+	}
+	assert.Equal(t, []int{1, 3, 4}, ids)
+
+	// Released identifiers are reused
+	require.Nil(t, srv.removeNASDefinition(api.Nas{ID: "nas2", Name: "nas2"}))
+	nas := api.Nas{ID: "nas4", Name: "nas4", IsServer: true}
+	require.Nil(t, srv.reserveHAID(&nas))
+	assert.Equal(t, 3, nas.HAID)
+
+	// The name is reserved too
+	assert.NotNil(t, srv.reserveHAID(&api.Nas{ID: "other", Name: "nas1", IsServer: true}))
+}
+
+func TestSetHAFencing(t *testing.T) {
+	clt, err := (&local.Client{}).Build(map[string]interface{}{"name": t.Name(), "TransitionDelay": "50ms"})
+	require.Nil(t, err)
+	srv := &NasService{provider: providers.FromClient(clt)}
+
+	network, err := srv.provider.CreateNetwork(api.NetworkRequest{Name: "net1", IPVersion: IPVersion.IPv4, CIDR: "192.168.1.0/24"})
+	require.Nil(t, err)
+	gw, err := srv.provider.CreateGateway(api.GWRequest{ImageID: "local-ubuntu-1604", NetworkID: network.ID, TemplateID: "local-tiny"})
+	require.Nil(t, err)
+	network, err = srv.provider.GetNetwork(network.ID)
+	require.Nil(t, err)
+
+	hostKey := "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"
+	members := []haMember{
+		{server: &nfs.Server{SshConfig: &system.SSHConfig{User: "gpac", Host: "203.0.113.1", HostKey: "key1"}}, config: nfs.HAConfig{LocalIP: "192.168.1.11"}},
+		{server: &nfs.Server{SshConfig: &system.SSHConfig{User: "gpac", Host: "192.168.1.12", Port: 2222, HostKey: "key2"}}, config: nfs.HAConfig{LocalIP: "192.168.1.12"}},
+	}
+	gateway := &system.SSHConfig{User: "gw", Host: "203.0.113.254", HostKey: hostKey}
+	require.Nil(t, srv.setHAFencing(network, gateway, members))
+
+	first, second := members[0].config.Fencing, members[1].config.Fencing
+	require.NotNil(t, first)
+	require.NotNil(t, second)
+	// Both servers share the key pair, and reach each other by private IP through the gateway
+	assert.Equal(t, first.PrivateKey, second.PrivateKey)
+	assert.Equal(t, first.PublicKey, second.PublicKey)
+	assert.Contains(t, first.PrivateKey, "PRIVATE KEY")
+	assert.Equal(t, system.SSHConfig{User: "gpac", Host: "192.168.1.12", Port: 2222, HostKey: "key2"}, first.Peer)
+	assert.Equal(t, system.SSHConfig{User: "gpac", Host: "192.168.1.11", HostKey: "key1"}, second.Peer)
+	expected := system.SSHConfig{User: "gw", Host: gw.PrivateIPsV4[0], HostKey: hostKey}
+	assert.Equal(t, expected, first.Gateway)
+	assert.Equal(t, expected, second.Gateway)
+}
@@ -17,13 +17,17 @@
 package services
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/CS-SI/SafeScale/broker/daemon/jobs"
 	"github.com/CS-SI/SafeScale/broker/utils"
 	"github.com/CS-SI/SafeScale/providers"
 	"github.com/CS-SI/SafeScale/providers/api"
 	"github.com/CS-SI/SafeScale/providers/enums/IPVersion"
+	"github.com/CS-SI/SafeScale/providers/filters/tags"
 	"github.com/CS-SI/SafeScale/providers/metadata"
+	"github.com/CS-SI/SafeScale/system"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
@@ -32,10 +36,12 @@ import (
 
 //NetworkAPI defines API to manage networks
 type NetworkAPI interface {
-	Create(net string, cidr string, ipVersion IPVersion.Enum, cpu int, ram float32, disk int, os string, gwname string) (*api.Network, error)
+	Create(ctx context.Context, net string, cidr string, ipVersion IPVersion.Enum, cpu int, ram float32, disk int, os string, gwname string, gwSecurityGroups []string) (*api.Network, error)
 	List(all bool) ([]api.Network, error)
 	Get(ref string) (*api.Network, error)
 	Delete(ref string) error
+	Import(ref string, gateway string, privateKey string) (*api.Network, error)
+	SetTags(ref string, set map[string]string, remove []string) (*api.Network, error)
 }
 
 // NetworkService an instance of NetworkAPI
@@ -51,10 +57,18 @@ func NewNetworkService(api api.ClientAPI) NetworkAPI {
 	}
 }
 
-// Create creates a network
-func (svc *NetworkService) Create(net string, cidr string, ipVersion IPVersion.Enum, cpu int, ram float32, disk int, os string, gwname string) (apinetwork *api.Network, err error) {
+// Create creates a network and its gateway, the creation is rolled back if ctx is done before the gateway is ready
+// gwSecurityGroups are the names or ids of the security groups bound to the gateway; if empty the default security
+// group of the provider is used
+func (svc *NetworkService) Create(ctx context.Context, net string, cidr string, ipVersion IPVersion.Enum, cpu int, ram float32, disk int, os string, gwname string, gwSecurityGroups []string) (apinetwork *api.Network, err error) {
+	sgIDs, err := securityGroupIDs(svc.provider, gwSecurityGroups)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create the network
-	network, err := svc.provider.CreateNetwork(api.NetworkRequest{
+	jobs.Step(ctx, "Creating network '%s'", net)
+	network, err := svc.provider.CreateNetworkWithContext(ctx, api.NetworkRequest{
 		Name:      net,
 		IPVersion: ipVersion,
 		CIDR:      cidr,
@@ -121,11 +135,12 @@ func (svc *NetworkService) Create(net string, cidr string, ipVersion IPVersion.E
 	}
 
 	gwRequest := api.GWRequest{
-		ImageID:    img.ID,
-		NetworkID:  network.ID,
-		KeyPair:    keypair,
-		TemplateID: tpls[0].ID,
-		GWName:     gwname,
+		ImageID:        img.ID,
+		NetworkID:      network.ID,
+		KeyPair:        keypair,
+		TemplateID:     tpls[0].ID,
+		GWName:         gwname,
+		SecurityGroups: sgIDs,
 	}
 
 	if gwname == "" {
@@ -133,7 +148,8 @@ func (svc *NetworkService) Create(net string, cidr string, ipVersion IPVersion.E
 	}
 	log.Printf("Waiting until gateway '%s' is finished provisioning and is available through SSH ...", gwname)
 
-	gw, err := svc.provider.CreateGateway(gwRequest)
+	jobs.Step(ctx, "Creating gateway '%s'", gwname)
+	gw, err := svc.provider.CreateGatewayWithContext(ctx, gwRequest)
 	if err != nil {
 		defer svc.provider.DeleteNetwork(network.ID)
 		tbr := errors.Wrapf(err, "Gateway creation with name '%s' failed", gwname)
@@ -153,15 +169,29 @@ func (svc *NetworkService) Create(net string, cidr string, ipVersion IPVersion.E
 		return nil, tbr
 	}
 
+	jobs.Step(ctx, "Waiting start of SSH service on gateway '%s'", gw.Name)
 	// TODO Test for failure with 15s !!!
-	err = ssh.WaitServerReady(utils.TimeoutCtxHost)
+	err = ssh.WaitServerReadyWithContext(ctx, utils.TimeoutCtxHost)
 	// err = ssh.WaitServerReady(time.Second * 15)
+	if err != nil && err == ctx.Err() {
+		derr := svc.provider.DeleteNetwork(network.ID)
+		if derr != nil {
+			log.Warnf("Error deleting network after cancellation: %v", derr)
+		}
+		tbr := errors.Wrapf(err, "Creation of network '%s' canceled", network.Name)
+		log.Errorf("%+v", tbr)
+		return nil, tbr
+	}
 	if err != nil {
 		tbr := errors.Wrapf(err, "Failure waiting for gateway '%s' to finish provisioning and being accessible through SSH", gw.Name)
 		log.Errorf("%+v", tbr)
 		return nil, tbr
 	}
 	log.Printf("SSH service of gateway '%s' started.", gw.Name)
+	_, err = pinHostKey(svc.provider, gw.ID, ssh)
+	if err != nil {
+		log.Warnf("Failed to pin host key of gateway '%s': %v", gw.Name, err)
+	}
 
 	// Gateway is ready to work, update Network metadata
 	rv, err := svc.Get(net)
@@ -190,5 +220,117 @@ func (svc *NetworkService) Get(ref string) (*api.Network, error) {
 
 // Delete deletes network referenced by ref
 func (svc *NetworkService) Delete(ref string) error {
-	return svc.provider.DeleteNetwork(ref)
+	// The SSH config of the gateway is read before the deletion, to forget its host key once deleted
+	var gwSSH *system.SSHConfig
+	if network, err := svc.Get(ref); err == nil && network != nil && network.GatewayID != "" {
+		gwSSH, _ = svc.provider.GetSSHConfig(network.GatewayID)
+	}
+	err := svc.provider.DeleteNetwork(ref)
+	if err != nil {
+		return err
+	}
+	if gwSSH != nil {
+		if err := system.ForgetHostKey(gwSSH); err != nil {
+			log.Warnf("Failed to forget host key of gateway of network '%s': %v", ref, err)
+		}
+	}
+	return nil
+}
+
+// Import creates the metadata of a network existing on the provider but not managed by SafeScale
+// If gateway is set, the host is declared as the gateway of the network; privateKey is the key used to connect to the
+// gateway with SSH
+func (svc *NetworkService) Import(ref string, gateway string, privateKey string) (*api.Network, error) {
+	log.Printf("Importing network '%s'...", ref)
+	m, err := metadata.LoadNetwork(svc.provider, ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read metadata of network '%s'", ref)
+	}
+	if m != nil {
+		return nil, fmt.Errorf("network '%s' is already managed by SafeScale", ref)
+	}
+
+	list, err := svc.provider.ListNetworks(true)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list networks")
+	}
+	var network *api.Network
+	for _, n := range list {
+		if n.ID == ref || n.Name == ref {
+			network = &n
+			break
+		}
+	}
+	if network == nil {
+		return nil, fmt.Errorf("network '%s' not found", ref)
+	}
+
+	var gw *api.Host
+	if gateway != "" {
+		gw, err = svc.provider.GetHost(gateway)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get host '%s'", gateway)
+		}
+		if gw == nil {
+			return nil, fmt.Errorf("host '%s' not found", gateway)
+		}
+		mh, err := metadata.LoadHost(svc.provider, gw.ID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read metadata of host '%s'", gateway)
+		}
+		if mh != nil {
+			return nil, fmt.Errorf("host '%s' is already managed by SafeScale and cannot be used as gateway", gateway)
+		}
+		gw.PrivateKey = privateKey
+		network.GatewayID = gw.ID
+	}
+
+	err = metadata.SaveNetwork(svc.provider, network)
+	if err != nil {
+		tbr := errors.Wrapf(err, "Failed to save metadata of network '%s'", network.Name)
+		log.Errorf("%+v", tbr)
+		return nil, tbr
+	}
+	if gw != nil {
+		err = metadata.NewHost(svc.provider).Carry(gw).Write()
+		if err == nil {
+			err = metadata.SaveGateway(svc.provider, gw, network.ID)
+		}
+		if err != nil {
+			tbr := errors.Wrapf(err, "Failed to save metadata of gateway '%s'", gw.Name)
+			log.Errorf("%+v", tbr)
+			return nil, tbr
+		}
+	}
+	log.Printf("Network '%s' imported.", network.Name)
+	return svc.Get(network.ID)
+}
+
+// SetTags adds or replaces the tags 'set' and removes the tags 'remove' of the network identified by ref
+func (svc *NetworkService) SetTags(ref string, set map[string]string, remove []string) (*api.Network, error) {
+	m, err := metadata.LoadNetwork(svc.provider, ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read metadata of network '%s'", ref)
+	}
+	if m == nil {
+		return nil, errors.Wrap(providers.ResourceNotFoundError("network", ref), "Cannot tag network")
+	}
+	err = m.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer m.Release()
+
+	// Reads again the metadata, they may have changed while waiting for the lock
+	err = m.Reload()
+	if err != nil {
+		return nil, err
+	}
+	network := m.Get()
+	network.Tags = tags.Update(network.Tags, set, remove)
+	err = m.Write()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to save metadata of network '%s'", network.Name)
+	}
+	return network, nil
 }
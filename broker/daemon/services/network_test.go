@@ -18,10 +18,15 @@ package services
 
 import (
 	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/enums/IPVersion"
+	"github.com/CS-SI/SafeScale/providers/local"
+	"github.com/CS-SI/SafeScale/providers/metadata"
 	"github.com/CS-SI/SafeScale/providers/mocks"
 	"github.com/golang/mock/gomock"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -67,3 +72,45 @@ func TestNetworkService_List_with_NO_brokerd_running(t *testing.T) {
 
 	assert.Nil(t, result)
 }
+
+func TestNetworkService_Import(t *testing.T) {
+	clt, err := (&local.Client{}).Build(map[string]interface{}{"name": t.Name(), "TransitionDelay": "50ms"})
+	require.Nil(t, err)
+	svc := NewNetworkService(clt).(*NetworkService)
+
+	network, err := svc.provider.CreateNetwork(api.NetworkRequest{Name: "net1", IPVersion: IPVersion.IPv4, CIDR: "192.168.1.0/24"})
+	require.Nil(t, err)
+	gw, err := svc.provider.CreateGateway(api.GWRequest{ImageID: "local-ubuntu-1604", NetworkID: network.ID, TemplateID: "local-tiny"})
+	require.Nil(t, err)
+	// Network and gateway created behind SafeScale's back
+	mgw, err := metadata.LoadGateway(svc.provider, network.ID)
+	require.Nil(t, err)
+	require.NotNil(t, mgw)
+	require.Nil(t, mgw.Delete())
+	require.Nil(t, metadata.RemoveNetwork(svc.provider, network))
+
+	_, err = svc.Import("net1", "unknown", "private key")
+	assert.NotNil(t, err)
+	_, err = svc.Import("unknown", "", "")
+	assert.NotNil(t, err)
+
+	imported, err := svc.Import("net1", gw.Name, "private key")
+	require.Nil(t, err)
+	assert.Equal(t, network.ID, imported.ID)
+	assert.Equal(t, gw.ID, imported.GatewayID)
+	mgw, err = metadata.LoadGateway(svc.provider, network.ID)
+	require.Nil(t, err)
+	require.NotNil(t, mgw)
+	assert.Equal(t, gw.ID, mgw.Get().ID)
+	assert.Equal(t, "private key", mgw.Get().PrivateKey)
+
+	_, err = svc.Import("net1", "", "")
+	assert.NotNil(t, err)
+
+	// An host already managed can't become the gateway of an imported network
+	net2, err := svc.provider.CreateNetwork(api.NetworkRequest{Name: "net2", IPVersion: IPVersion.IPv4, CIDR: "192.168.2.0/24"})
+	require.Nil(t, err)
+	require.Nil(t, metadata.RemoveNetwork(svc.provider, net2))
+	_, err = svc.Import("net2", gw.Name, "private key")
+	assert.NotNil(t, err)
+}
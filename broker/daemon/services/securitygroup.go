@@ -0,0 +1,198 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/enums/IPVersion"
+	"github.com/pkg/errors"
+)
+
+//go:generate mockgen -destination=../mocks/mock_securitygroupapi.go -package=mocks github.com/CS-SI/SafeScale/broker/daemon/services SecurityGroupAPI
+
+//SecurityGroupAPI defines API to manage security groups
+type SecurityGroupAPI interface {
+	Create(name string, description string, net string) (*api.SecurityGroup, error)
+	List() ([]api.SecurityGroup, error)
+	Get(ref string) (*api.SecurityGroup, error)
+	Delete(ref string) error
+	AddRule(ref string, rule api.SecurityGroupRule) (*api.SecurityGroupRule, error)
+	DeleteRule(ref string, ruleID string) error
+}
+
+// NewSecurityGroupService creates a security group service
+func NewSecurityGroupService(api api.ClientAPI) SecurityGroupAPI {
+	return &SecurityGroupService{
+		provider: providers.FromClient(api),
+		network:  NewNetworkService(api),
+	}
+}
+
+// SecurityGroupService security group service
+type SecurityGroupService struct {
+	provider *providers.Service
+	network  NetworkAPI
+}
+
+// Create creates a security group without rule; net is only needed by the providers binding security groups to networks
+func (svc *SecurityGroupService) Create(name string, description string, net string) (*api.SecurityGroup, error) {
+	sg, err := svc.provider.GetSecurityGroup(name)
+	if err != nil {
+		return nil, err
+	}
+	if sg != nil {
+		return nil, providers.ResourceAlreadyExistsError("security group", name)
+	}
+	request := api.SecurityGroupRequest{
+		Name:        name,
+		Description: description,
+	}
+	if net != "" {
+		n, err := svc.network.Get(net)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get network '%s'", net)
+		}
+		if n == nil {
+			return nil, providers.ResourceNotFoundError("network", net)
+		}
+		request.NetworkID = n.ID
+	}
+	return svc.provider.CreateSecurityGroup(request)
+}
+
+// List returns the security groups of the tenant
+func (svc *SecurityGroupService) List() ([]api.SecurityGroup, error) {
+	return svc.provider.ListSecurityGroups()
+}
+
+// Get returns the security group identified by ref, ref can be the name or the id
+func (svc *SecurityGroupService) Get(ref string) (*api.SecurityGroup, error) {
+	return svc.provider.GetSecurityGroup(ref)
+}
+
+// Delete deletes the security group referenced by ref
+func (svc *SecurityGroupService) Delete(ref string) error {
+	sg, err := svc.find(ref)
+	if err != nil {
+		return err
+	}
+	return svc.provider.DeleteSecurityGroup(sg.ID)
+}
+
+// AddRule adds a rule to the security group referenced by ref
+func (svc *SecurityGroupService) AddRule(ref string, rule api.SecurityGroupRule) (*api.SecurityGroupRule, error) {
+	sg, err := svc.find(ref)
+	if err != nil {
+		return nil, err
+	}
+	rule, err = normalizeSecurityGroupRule(rule)
+	if err != nil {
+		return nil, err
+	}
+	return svc.provider.AddSecurityGroupRule(sg.ID, rule)
+}
+
+// DeleteRule deletes the rule identified by ruleID from the security group referenced by ref
+func (svc *SecurityGroupService) DeleteRule(ref string, ruleID string) error {
+	sg, err := svc.find(ref)
+	if err != nil {
+		return err
+	}
+	return svc.provider.DeleteSecurityGroupRule(sg.ID, ruleID)
+}
+
+// find returns the security group referenced by ref, or an error if it doesn't exist
+func (svc *SecurityGroupService) find(ref string) (*api.SecurityGroup, error) {
+	sg, err := svc.provider.GetSecurityGroup(ref)
+	if err != nil {
+		return nil, err
+	}
+	if sg == nil {
+		return nil, providers.ResourceNotFoundError("security group", ref)
+	}
+	return sg, nil
+}
+
+// securityGroupIDs returns the IDs of the security groups referenced by refs (names or ids)
+func securityGroupIDs(provider *providers.Service, refs []string) ([]string, error) {
+	var ids []string
+	for _, ref := range refs {
+		sg, err := provider.GetSecurityGroup(ref)
+		if err != nil {
+			return nil, err
+		}
+		if sg == nil {
+			return nil, providers.ResourceNotFoundError("security group", ref)
+		}
+		ids = append(ids, sg.ID)
+	}
+	return ids, nil
+}
+
+// normalizeSecurityGroupRule checks the consistency of a rule and fills the fields left empty
+// - a rule without CIDR concerns all addresses of its IP version (IPv4 by default)
+// - the IP version of a rule with CIDR is the one of the CIDR
+// - a rule with PortFrom only concerns this port
+func normalizeSecurityGroupRule(rule api.SecurityGroupRule) (api.SecurityGroupRule, error) {
+	rule.Protocol = strings.ToLower(rule.Protocol)
+	switch rule.Protocol {
+	case "", "tcp", "udp", "icmp":
+	default:
+		return rule, fmt.Errorf("invalid protocol '%s': must be tcp, udp or icmp", rule.Protocol)
+	}
+
+	if rule.PortFrom != 0 || rule.PortTo != 0 {
+		if rule.Protocol != "tcp" && rule.Protocol != "udp" {
+			return rule, fmt.Errorf("ports can only be set with tcp or udp protocol")
+		}
+		if rule.PortTo == 0 {
+			rule.PortTo = rule.PortFrom
+		}
+		if rule.PortFrom < 1 || rule.PortTo > 65535 || rule.PortFrom > rule.PortTo {
+			return rule, fmt.Errorf("invalid port range %d-%d", rule.PortFrom, rule.PortTo)
+		}
+	}
+
+	if rule.CIDR == "" {
+		if rule.IPVersion == IPVersion.IPv6 {
+			rule.CIDR = "::/0"
+		} else {
+			rule.IPVersion = IPVersion.IPv4
+			rule.CIDR = "0.0.0.0/0"
+		}
+		return rule, nil
+	}
+	ip, ipnet, err := net.ParseCIDR(rule.CIDR)
+	if err != nil {
+		return rule, fmt.Errorf("invalid CIDR '%s'", rule.CIDR)
+	}
+	version := IPVersion.IPv4
+	if ip.To4() == nil {
+		version = IPVersion.IPv6
+	}
+	if rule.IPVersion != 0 && rule.IPVersion != version {
+		return rule, fmt.Errorf("CIDR '%s' is not an IPv%d range", rule.CIDR, rule.IPVersion)
+	}
+	rule.IPVersion = version
+	rule.CIDR = ipnet.String()
+	return rule, nil
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/enums/IPVersion"
+	"github.com/CS-SI/SafeScale/providers/enums/RuleDirection"
+	"github.com/CS-SI/SafeScale/providers/local"
+)
+
+func TestNormalizeSecurityGroupRule(t *testing.T) {
+	rule, err := normalizeSecurityGroupRule(api.SecurityGroupRule{Protocol: "TCP", PortFrom: 22})
+	require.Nil(t, err)
+	assert.Equal(t, "tcp", rule.Protocol)
+	assert.Equal(t, 22, rule.PortTo)
+	assert.Equal(t, IPVersion.IPv4, rule.IPVersion)
+	assert.Equal(t, "0.0.0.0/0", rule.CIDR)
+
+	rule, err = normalizeSecurityGroupRule(api.SecurityGroupRule{IPVersion: IPVersion.IPv6})
+	require.Nil(t, err)
+	assert.Equal(t, "::/0", rule.CIDR)
+
+	rule, err = normalizeSecurityGroupRule(api.SecurityGroupRule{Protocol: "udp", PortFrom: 53, CIDR: "10.0.1.12/16"})
+	require.Nil(t, err)
+	assert.Equal(t, "10.0.0.0/16", rule.CIDR)
+
+	rule, err = normalizeSecurityGroupRule(api.SecurityGroupRule{CIDR: "fd00::/8"})
+	require.Nil(t, err)
+	assert.Equal(t, IPVersion.IPv6, rule.IPVersion)
+
+	_, err = normalizeSecurityGroupRule(api.SecurityGroupRule{Protocol: "sctp"})
+	assert.NotNil(t, err)
+	_, err = normalizeSecurityGroupRule(api.SecurityGroupRule{PortFrom: 22})
+	assert.NotNil(t, err)
+	_, err = normalizeSecurityGroupRule(api.SecurityGroupRule{Protocol: "tcp", PortFrom: 443, PortTo: 80})
+	assert.NotNil(t, err)
+	_, err = normalizeSecurityGroupRule(api.SecurityGroupRule{CIDR: "10.0.0.0/33"})
+	assert.NotNil(t, err)
+	_, err = normalizeSecurityGroupRule(api.SecurityGroupRule{IPVersion: IPVersion.IPv6, CIDR: "10.0.0.0/8"})
+	assert.NotNil(t, err)
+}
+
+func TestSecurityGroupService(t *testing.T) {
+	clt, err := (&local.Client{}).Build(map[string]interface{}{"name": t.Name(), "TransitionDelay": "50ms"})
+	require.Nil(t, err)
+	svc := NewSecurityGroupService(clt).(*SecurityGroupService)
+
+	sg, err := svc.Create("web", "", "")
+	require.Nil(t, err)
+	_, err = svc.Create("web", "", "")
+	assert.NotNil(t, err)
+	_, err = svc.Create("db", "", "unknown")
+	assert.NotNil(t, err)
+
+	rule, err := svc.AddRule("web", api.SecurityGroupRule{Direction: RuleDirection.INGRESS, Protocol: "tcp", PortFrom: 443})
+	require.Nil(t, err)
+	assert.Equal(t, "0.0.0.0/0", rule.CIDR)
+	_, err = svc.AddRule("unknown", api.SecurityGroupRule{Protocol: "tcp", PortFrom: 443})
+	assert.NotNil(t, err)
+
+	got, err := svc.Get(sg.ID)
+	require.Nil(t, err)
+	require.NotNil(t, got)
+	found := false
+	for _, r := range got.Rules {
+		if r.ID == rule.ID {
+			found = true
+		}
+	}
+	assert.True(t, found)
+
+	ids, err := securityGroupIDs(svc.provider, []string{"web"})
+	require.Nil(t, err)
+	assert.Equal(t, []string{sg.ID}, ids)
+	_, err = securityGroupIDs(svc.provider, []string{"web", "unknown"})
+	assert.NotNil(t, err)
+
+	require.Nil(t, svc.DeleteRule("web", rule.ID))
+	require.Nil(t, svc.Delete("web"))
+	got, err = svc.Get("web")
+	require.Nil(t, err)
+	assert.Nil(t, got)
+}
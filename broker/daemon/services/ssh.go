@@ -17,8 +17,10 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	log "github.com/sirupsen/logrus"
+	"io"
 	"strings"
 	"time"
 
@@ -39,6 +41,7 @@ const protocolSeparator = ":"
 type SSHAPI interface {
 	Connect(name string) error
 	Run(cmd string) (string, string, int, error)
+	Execute(ctx context.Context, hostName, cmd string, stdin io.Reader, stdout, stderr io.Writer) (int, error)
 	Copy(from string, to string)
 }
 
@@ -90,6 +93,50 @@ func (svc *SSHService) Run(hostName, cmd string) (int, string, string, error) {
 	return retCode, stdOut, stdErr, err
 }
 
+// Execute executes command 'cmd' on the host, writing its outputs to stdout and stderr as they are produced
+// If stdin is not nil, it is sent to the command. The command is stopped when ctx is done.
+// Returns the exit status of the command
+func (svc *SSHService) Execute(ctx context.Context, hostName, cmd string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	host, err := svc.hostService.Get(hostName)
+	if err != nil {
+		return 0, fmt.Errorf("no host found with name or id '%s'", hostName)
+	}
+
+	// retrieve ssh config to perform some commands
+	ssh, err := svc.provider.GetSSHConfig(host.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Only the connection is retried: once the command is started, stdin may have been partly consumed and the
+	// command may have had effects, it's not run again
+	var sshCmd *system.SSHCommand
+	err = retry.WhileUnsuccessful255WithNotify(
+		func() error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			var err error
+			sshCmd, err = ssh.CommandContext(ctx, cmd)
+			if err != nil {
+				return err
+			}
+			return sshCmd.Connect()
+		},
+		time.Second,
+		2*time.Minute,
+		func(t retry.Try, v Verdict.Enum) {
+			if v == Verdict.Retry {
+				log.Printf("Remote SSH service on host '%s' isn't ready, retrying...\n", hostName)
+			}
+		},
+	)
+	if err != nil {
+		return 255, err
+	}
+	return sshCmd.Stream(stdin, stdout, stderr)
+}
+
 // run executes command on the host
 func (svc *SSHService) run(ssh *system.SSHConfig, cmd string) (int, string, string, error) {
 	// Create the command
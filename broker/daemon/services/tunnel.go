@@ -0,0 +1,255 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/system"
+)
+
+//go:generate mockgen -destination=../mocks/mock_tunnelapi.go -package=mocks github.com/CS-SI/SafeScale/broker/daemon/services TunnelAPI
+
+// TunnelAPI defines API to manage the tunnels opened by brokerd
+type TunnelAPI interface {
+	Forward(host string, local string, remote string) (*Tunnel, error)
+	SOCKS(net string, local string) (*Tunnel, error)
+	List() []Tunnel
+	Wait(ctx context.Context, id string) error
+	Close(id string) error
+}
+
+// Tunnel kinds
+const (
+	// TunnelForward forwards a local port to a port reached from an host
+	TunnelForward = "forward"
+	// TunnelSOCKS runs a SOCKS proxy opening its connections from the gateway of a network
+	TunnelSOCKS = "socks"
+)
+
+// Tunnel describes a tunnel opened by brokerd
+type Tunnel struct {
+	ID string
+	// Kind is TunnelForward or TunnelSOCKS
+	Kind string
+	// Target is the host or the network the tunnel goes to
+	Target string
+	// Local is the address listened by brokerd
+	Local string
+	// Remote is the address connected from the host, for TunnelForward
+	Remote      string
+	OpenedAt    time.Time
+	Connections int
+	// LastError is the error of the last connection which couldn't be forwarded
+	LastError string
+}
+
+// tunnelEntry is a tunnel opened by brokerd
+type tunnelEntry struct {
+	info   Tunnel
+	tunnel *system.SSHTunnel
+}
+
+var (
+	// tunnels are the tunnels currently opened by brokerd, indexed by ID
+	tunnels      = map[string]*tunnelEntry{}
+	tunnelsLock  sync.Mutex
+	lastTunnelID int
+)
+
+// NewTunnelService creates a tunnel service
+func NewTunnelService(api api.ClientAPI) TunnelAPI {
+	return &TunnelService{
+		provider: providers.FromClient(api),
+		host:     NewHostService(api),
+		network:  NewNetworkService(api),
+	}
+}
+
+// TunnelService tunnel service
+type TunnelService struct {
+	provider *providers.Service
+	host     HostAPI
+	network  NetworkAPI
+}
+
+// Forward forwards the local address to the remote address, reached from the host identified by ref
+// Ports alone designate ports of localhost, the local one on the machine running brokerd and the remote one on the
+// host
+func (svc *TunnelService) Forward(ref string, local string, remote string) (*Tunnel, error) {
+	local, err := normalizeTunnelAddress(local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid local address: %s", err.Error())
+	}
+	remote, err = normalizeTunnelAddress(remote)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote address: %s", err.Error())
+	}
+	ssh, err := svc.host.SSH(ref)
+	if err != nil {
+		return nil, err
+	}
+	tunnel, err := ssh.Forward(local, remote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tunnel to host '%s': %s", ref, err.Error())
+	}
+	return registerTunnel(TunnelForward, ref, remote, tunnel), nil
+}
+
+// SOCKS runs on the local address a SOCKS proxy opening its connections from the gateway of the network identified
+// by ref
+func (svc *TunnelService) SOCKS(ref string, local string) (*Tunnel, error) {
+	local, err := normalizeTunnelAddress(local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid local address: %s", err.Error())
+	}
+	network, err := svc.network.Get(ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query network '%s'", ref)
+	}
+	if network == nil {
+		return nil, fmt.Errorf("network '%s' not found", ref)
+	}
+	if network.GatewayID == "" {
+		return nil, fmt.Errorf("network '%s' has no gateway", ref)
+	}
+	ssh, err := svc.provider.GetSSHConfig(network.GatewayID)
+	if err != nil {
+		return nil, err
+	}
+	tunnel, err := ssh.SOCKS(local)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SOCKS proxy to network '%s': %s", ref, err.Error())
+	}
+	return registerTunnel(TunnelSOCKS, ref, "", tunnel), nil
+}
+
+// List returns the tunnels currently opened, sorted by ID
+func (svc *TunnelService) List() []Tunnel {
+	tunnelsLock.Lock()
+	defer tunnelsLock.Unlock()
+	var list []Tunnel
+	for _, e := range tunnels {
+		list = append(list, e.state())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, _ := strconv.Atoi(list[i].ID)
+		b, _ := strconv.Atoi(list[j].ID)
+		return a < b
+	})
+	return list
+}
+
+// Wait waits for the end of the tunnel identified by id, closing it if ctx is done first
+func (svc *TunnelService) Wait(ctx context.Context, id string) error {
+	tunnelsLock.Lock()
+	e, ok := tunnels[id]
+	tunnelsLock.Unlock()
+	if !ok {
+		return fmt.Errorf("tunnel '%s' not found", id)
+	}
+	select {
+	case <-e.tunnel.Done():
+	case <-ctx.Done():
+	}
+	// The tunnel may have been closed meanwhile by Close
+	closeTunnel(id)
+	return nil
+}
+
+// Close closes the tunnel identified by id
+func (svc *TunnelService) Close(id string) error {
+	found, err := closeTunnel(id)
+	if !found {
+		return fmt.Errorf("tunnel '%s' not found", id)
+	}
+	return err
+}
+
+// closeTunnel closes the tunnel identified by id and forgets it, if still opened
+func closeTunnel(id string) (bool, error) {
+	tunnelsLock.Lock()
+	e, ok := tunnels[id]
+	delete(tunnels, id)
+	tunnelsLock.Unlock()
+	if !ok {
+		return false, nil
+	}
+	err := e.tunnel.Close()
+	log.Printf("Tunnel %s to '%s' on '%s' closed", id, e.info.Target, e.info.Local)
+	return true, err
+}
+
+// registerTunnel records a tunnel just opened
+func registerTunnel(kind string, target string, remote string, tunnel *system.SSHTunnel) *Tunnel {
+	tunnelsLock.Lock()
+	defer tunnelsLock.Unlock()
+	lastTunnelID++
+	e := &tunnelEntry{
+		info: Tunnel{
+			ID:       strconv.Itoa(lastTunnelID),
+			Kind:     kind,
+			Target:   target,
+			Local:    tunnel.Addr(),
+			Remote:   remote,
+			OpenedAt: time.Now(),
+		},
+		tunnel: tunnel,
+	}
+	tunnels[e.info.ID] = e
+	log.Printf("Tunnel %s to '%s' opened on '%s'", e.info.ID, target, e.info.Local)
+	info := e.state()
+	return &info
+}
+
+// state returns the description of the tunnel with its current connections
+func (e *tunnelEntry) state() Tunnel {
+	info := e.info
+	info.Connections = e.tunnel.Connections()
+	if err := e.tunnel.LastError(); err != nil {
+		info.LastError = err.Error()
+	}
+	return info
+}
+
+// normalizeTunnelAddress completes an address made of a port alone with localhost
+func normalizeTunnelAddress(address string) (string, error) {
+	if _, err := strconv.ParseUint(address, 10, 16); err == nil {
+		return net.JoinHostPort("127.0.0.1", address), nil
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", err
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return "", fmt.Errorf("invalid port '%s'", port)
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port), nil
+}
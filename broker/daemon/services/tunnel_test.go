@@ -0,0 +1,49 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeTunnelAddress(t *testing.T) {
+	address, err := normalizeTunnelAddress("8080")
+	require.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:8080", address)
+
+	address, err = normalizeTunnelAddress(":80")
+	require.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:80", address)
+
+	address, err = normalizeTunnelAddress("10.0.0.3:5432")
+	require.Nil(t, err)
+	assert.Equal(t, "10.0.0.3:5432", address)
+
+	address, err = normalizeTunnelAddress("[::1]:80")
+	require.Nil(t, err)
+	assert.Equal(t, "[::1]:80", address)
+
+	_, err = normalizeTunnelAddress("70000")
+	assert.NotNil(t, err)
+	_, err = normalizeTunnelAddress("db:http")
+	assert.NotNil(t, err)
+	_, err = normalizeTunnelAddress("db")
+	assert.NotNil(t, err)
+}
@@ -17,15 +17,21 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"path"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/CS-SI/SafeScale/broker/daemon/jobs"
 	"github.com/CS-SI/SafeScale/providers"
 	"github.com/CS-SI/SafeScale/providers/api"
 	"github.com/CS-SI/SafeScale/providers/enums/VolumeSpeed"
+	"github.com/CS-SI/SafeScale/providers/enums/VolumeState"
+	"github.com/CS-SI/SafeScale/providers/filters/tags"
 	"github.com/CS-SI/SafeScale/providers/metadata"
 
 	"github.com/CS-SI/SafeScale/system/nfs"
@@ -43,9 +49,15 @@ type VolumeAPI interface {
 	Get(ref string) (*api.Volume, error)
 	Inspect(ref string) (*api.Volume, *api.VolumeAttachment, error)
 	List(all bool) ([]api.Volume, error)
-	Create(name string, size int, speed VolumeSpeed.Enum) (*api.Volume, error)
+	Create(ctx context.Context, name string, size int, speed VolumeSpeed.Enum) (*api.Volume, error)
 	Attach(volume string, host string, path string, format string) error
 	Detach(volume string, host string) error
+	Import(ref string) (*api.Volume, error)
+	SetTags(ref string, set map[string]string, remove []string) (*api.Volume, error)
+	CreateSnapshot(ref string, name string, consistent bool) (*api.Snapshot, error)
+	ListSnapshots(ref string) ([]api.Snapshot, error)
+	DeleteSnapshot(ref string) error
+	RestoreSnapshot(snapshotRef string, name string, size int, speed VolumeSpeed.Enum) (*api.Volume, error)
 }
 
 //NewVolumeService creates a Volume service
@@ -113,8 +125,9 @@ func (svc *VolumeService) Inspect(ref string) (*api.Volume, *api.VolumeAttachmen
 }
 
 // Create a volume
-func (svc *VolumeService) Create(name string, size int, speed VolumeSpeed.Enum) (*api.Volume, error) {
-	return svc.provider.CreateVolume(api.VolumeRequest{
+func (svc *VolumeService) Create(ctx context.Context, name string, size int, speed VolumeSpeed.Enum) (*api.Volume, error) {
+	jobs.Step(ctx, "Creating volume '%s'", name)
+	return svc.provider.CreateVolumeWithContext(ctx, api.VolumeRequest{
 		Name:  name,
 		Size:  size,
 		Speed: speed,
@@ -146,55 +159,9 @@ func (svc *VolumeService) Attach(volumename, hostName, path, format string) erro
 		return errors.Wrap(providers.ResourceNotFoundError("host", hostName), "Cannot attach volume")
 	}
 
-	// Note: most providers are not able to tell the real device name the volume
-	//       will have on the host, so we have to use a way that can work everywhere
-	// Get list of disks before attachment
-	oldDiskSet, err := svc.listAttachedDevices(host)
-	if err != nil {
-		return fmt.Errorf("failed to get list of connected disks: %s", err)
-	}
-
-	volatt, err := svc.provider.CreateVolumeAttachment(api.VolumeAttachmentRequest{
-		Name:     fmt.Sprintf("%s-%s", volume.Name, host.Name),
-		ServerID: host.ID,
-		VolumeID: volume.ID,
-	})
+	volatt, err := svc.attachDevice(volume, host)
 	if err != nil {
-		return fmt.Errorf("failed to create host-volume attachment: %v", err)
-	}
-
-	// Waits to acknowledge the volume is really attached to host
-	var newDisk mapset.Set
-	retryErr := retry.WhileUnsuccessfulDelay1Second(
-		func() error {
-			// Get new of disk after attachment
-			newDiskSet, err := svc.listAttachedDevices(host)
-			if err != nil {
-				return fmt.Errorf("failed to get list of connected disks: %s", err)
-			}
-			// Isolate the new device
-			newDisk = newDiskSet.Difference(oldDiskSet)
-			if newDisk.Cardinality() == 0 {
-				return fmt.Errorf("disk not yet attached, retrying")
-			}
-			return nil
-		},
-		2*time.Minute,
-	)
-	if retryErr != nil {
-		return fmt.Errorf("failed to acknowledge the disk attachment after %s", 2*time.Minute)
-	}
-
-	// Updates volume attachment metadata
-	deviceName := newDisk.ToSlice()[0].(string)
-	volatt.Device = "/dev/" + deviceName
-	err = metadata.SaveVolumeAttachment(svc.provider, volatt)
-	if err != nil {
-		derr := svc.Detach(volumename, hostName)
-		if derr != nil {
-			log.Warnf("Failure trying to detach volume: %v", derr)
-		}
-		return fmt.Errorf("failed to update volume attachment: %s", err.Error())
+		return err
 	}
 
 	// Create mount point
@@ -247,6 +214,63 @@ func (svc *VolumeService) Attach(volumename, hostName, path, format string) erro
 	return nil
 }
 
+// attachDevice attaches a volume to an host, without formatting nor mounting it
+// Returns the attachment, its Device being the block device of the volume on the host
+func (svc *VolumeService) attachDevice(volume *api.Volume, host *api.Host) (*api.VolumeAttachment, error) {
+	// Note: most providers are not able to tell the real device name the volume
+	//       will have on the host, so we have to use a way that can work everywhere
+	// Get list of disks before attachment
+	oldDiskSet, err := svc.listAttachedDevices(host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get list of connected disks: %s", err)
+	}
+
+	volatt, err := svc.provider.CreateVolumeAttachment(api.VolumeAttachmentRequest{
+		Name:     fmt.Sprintf("%s-%s", volume.Name, host.Name),
+		ServerID: host.ID,
+		VolumeID: volume.ID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create host-volume attachment: %v", err)
+	}
+
+	// Waits to acknowledge the volume is really attached to host
+	var newDisk mapset.Set
+	retryErr := retry.WhileUnsuccessfulDelay1Second(
+		func() error {
+			// Get new of disk after attachment
+			newDiskSet, err := svc.listAttachedDevices(host)
+			if err != nil {
+				return fmt.Errorf("failed to get list of connected disks: %s", err)
+			}
+			// Isolate the new device
+			newDisk = newDiskSet.Difference(oldDiskSet)
+			if newDisk.Cardinality() == 0 {
+				return fmt.Errorf("disk not yet attached, retrying")
+			}
+			return nil
+		},
+		2*time.Minute,
+	)
+	if retryErr != nil {
+		return nil, fmt.Errorf("failed to acknowledge the disk attachment after %s", 2*time.Minute)
+	}
+
+	// Updates volume attachment metadata
+	deviceName := newDisk.ToSlice()[0].(string)
+	volatt.Device = "/dev/" + deviceName
+	err = metadata.SaveVolumeAttachment(svc.provider, volatt)
+	if err != nil {
+		derr := svc.provider.DeleteVolumeAttachment(host.ID, volume.ID)
+		if derr != nil {
+			log.Warnf("Failure trying to detach volume: %v", derr)
+		}
+		return nil, fmt.Errorf("failed to update volume attachment: %s", err.Error())
+	}
+
+	return volatt, nil
+}
+
 func (svc *VolumeService) listAttachedDevices(host *api.Host) (mapset.Set, error) {
 	var (
 		retcode        int
@@ -331,3 +355,315 @@ func (svc *VolumeService) Detach(volumename string, hostName string) error {
 	// Finaly delete the attachment
 	return svc.provider.DeleteVolumeAttachment(host.ID, vol.ID)
 }
+
+//Import creates the metadata of a volume existing on the provider but not managed by SafeScale
+//The attachments of the volume to the hosts managed by SafeScale are imported too
+func (svc *VolumeService) Import(ref string) (*api.Volume, error) {
+	log.Printf("Importing volume '%s'...", ref)
+	m, err := metadata.LoadVolume(svc.provider, ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read metadata of volume '%s'", ref)
+	}
+	if m != nil {
+		return nil, fmt.Errorf("volume '%s' is already managed by SafeScale", ref)
+	}
+
+	list, err := svc.provider.ListVolumes(true)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list volumes")
+	}
+	var volume *api.Volume
+	for _, v := range list {
+		if v.ID == ref || v.Name == ref {
+			volume = &v
+			break
+		}
+	}
+	if volume == nil {
+		return nil, fmt.Errorf("volume '%s' not found", ref)
+	}
+
+	err = metadata.SaveVolume(svc.provider, volume)
+	if err != nil {
+		tbr := errors.Wrapf(err, "Failed to save metadata of volume '%s'", volume.Name)
+		log.Errorf("%+v", tbr)
+		return nil, tbr
+	}
+
+	hosts, err := svc.provider.ListHosts(false)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list hosts")
+	}
+	for _, h := range hosts {
+		vas, err := svc.provider.ListVolumeAttachments(h.ID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to list volume attachments of host '%s'", h.Name)
+		}
+		for _, va := range vas {
+			if va.VolumeID != volume.ID {
+				continue
+			}
+			err = metadata.SaveVolumeAttachment(svc.provider, &va)
+			if err != nil {
+				tbr := errors.Wrapf(err, "Failed to save metadata of attachment of volume '%s' to host '%s'", volume.Name, h.Name)
+				log.Errorf("%+v", tbr)
+				return nil, tbr
+			}
+		}
+	}
+	log.Printf("Volume '%s' imported.", volume.Name)
+	return volume, nil
+}
+
+// SetTags adds or replaces the tags 'set' and removes the tags 'remove' of the volume identified by ref
+// Tags are stored in metadata and pushed to the provider if it supports them
+func (svc *VolumeService) SetTags(ref string, set map[string]string, remove []string) (*api.Volume, error) {
+	m, err := metadata.LoadVolume(svc.provider, ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read metadata of volume '%s'", ref)
+	}
+	if m == nil {
+		return nil, errors.Wrap(providers.ResourceNotFoundError("volume", ref), "Cannot tag volume")
+	}
+	err = m.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer m.Release()
+
+	// Reads again the metadata, they may have changed while waiting for the lock
+	err = m.Reload()
+	if err != nil {
+		return nil, err
+	}
+	volume := m.Get()
+	previous := volume.Tags
+	volume.Tags = tags.Update(volume.Tags, set, remove)
+
+	err = svc.provider.SetVolumeTags(volume.ID, volume.Tags, tags.Removed(previous, volume.Tags))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to set tags of volume '%s' on provider", volume.Name)
+	}
+	err = m.Write()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to save metadata of volume '%s'", volume.Name)
+	}
+	return volume, nil
+}
+
+// CreateSnapshot creates a snapshot of the volume identified by ref
+// In consistent mode, the filesystem of an attached volume is frozen over SSH while the snapshot is taken
+func (svc *VolumeService) CreateSnapshot(ref string, name string, consistent bool) (*api.Snapshot, error) {
+	volume, va, err := svc.Inspect(ref)
+	if err != nil {
+		tbr := errors.Wrap(err, "")
+		log.Errorf("%+v", tbr)
+		return nil, tbr
+	}
+	if volume == nil {
+		return nil, errors.Wrap(providers.ResourceNotFoundError("volume", ref), "Cannot snapshot volume")
+	}
+	if name == "" {
+		name = fmt.Sprintf("%s-%s", volume.Name, time.Now().Format("20060102-150405"))
+	}
+
+	var unfreeze func()
+	// The host unfreezes the filesystem by itself at the latest at thawed
+	thawed := time.Now().Add(freezeTimeout)
+	if consistent && va != nil && va.ServerID != "" {
+		if va.MountPoint == "" {
+			return nil, fmt.Errorf("Cannot freeze the filesystem of volume '%s': it is not mounted by SafeScale", volume.Name)
+		}
+		unfreeze, err = svc.freeze(va.ServerID, va.MountPoint)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to freeze the filesystem of volume '%s'", volume.Name)
+		}
+	}
+
+	snapshot, err := svc.provider.CreateVolumeSnapshot(api.SnapshotRequest{
+		Name:     name,
+		VolumeID: volume.ID,
+	})
+	if err == nil && unfreeze != nil {
+		// The content of the snapshot is fixed once it leaves the state CREATING, it must do so while still frozen
+		snapshot, err = svc.waitSnapshot(snapshot, time.Until(thawed))
+	}
+	if unfreeze != nil {
+		unfreeze()
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create snapshot of volume '%s'", volume.Name)
+	}
+	return snapshot, nil
+}
+
+// waitSnapshot waits for snapshot to leave the state CREATING for at most timeout
+// The snapshot is deleted if it doesn't, or ends in state ERROR
+func (svc *VolumeService) waitSnapshot(snapshot *api.Snapshot, timeout time.Duration) (*api.Snapshot, error) {
+	created, err := svc.provider.GetVolumeSnapshot(snapshot.ID)
+	if err == nil && created.State == VolumeState.CREATING {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		ticker := time.NewTicker(providers.StatePollDelay)
+		defer ticker.Stop()
+		for err == nil && created.State == VolumeState.CREATING {
+			select {
+			case <-timer.C:
+				err = fmt.Errorf("it is still being created after %s", timeout)
+			case <-ticker.C:
+				created, err = svc.provider.GetVolumeSnapshot(snapshot.ID)
+			}
+		}
+	}
+	if err == nil && created.State == VolumeState.ERROR {
+		err = fmt.Errorf("it is in state '%s'", created.State.String())
+	}
+	if err != nil {
+		derr := svc.provider.DeleteVolumeSnapshot(snapshot.ID)
+		if derr != nil {
+			log.Warnf("Failed to delete snapshot '%s': %v", snapshot.Name, derr)
+		}
+		return nil, fmt.Errorf("snapshot '%s' was not created while the filesystem was frozen: %v", snapshot.Name, err)
+	}
+	return created, nil
+}
+
+// freezeTimeout is the time after which the host unfreezes by itself a filesystem frozen for a snapshot, even if the
+// broker or the SSH connection died meanwhile
+var freezeTimeout = 30 * time.Second
+
+// freezeScript schedules the unfreeze of a filesystem after a timeout, then freezes it
+// Parameters are the mount point, the file containing the PID of the watchdog and the timeout in seconds
+const freezeScript = `set -e
+sync
+setsid nohup sh -c 'sleep %[3]d; fsfreeze -u %[1]s' </dev/null >/dev/null 2>&1 &
+echo $! > %[2]s
+timeout %[3]d fsfreeze -f %[1]s`
+
+// unfreezeScript unfreezes a filesystem and cancels the watchdog scheduled by freezeScript
+const unfreezeScript = `fsfreeze -u %[1]s
+rc=$?
+kill $(cat %[2]s) 2>/dev/null
+rm -f %[2]s
+exit $rc`
+
+// mountPointPattern matches the mount points which can be written as is in the freeze scripts
+var mountPointPattern = regexp.MustCompile(`^/[A-Za-z0-9._/-]+$`)
+
+// freezeScripts returns the scripts freezing and unfreezing the filesystem mounted on mountPoint
+// The root filesystem is never frozen: nothing, SSH included, could write on the host anymore
+func freezeScripts(mountPoint string) (string, string, error) {
+	mountPoint = path.Clean(mountPoint)
+	if mountPoint == "/" || !mountPointPattern.MatchString(mountPoint) {
+		return "", "", fmt.Errorf("refusing to freeze the filesystem mounted on '%s'", mountPoint)
+	}
+	watchdog := fmt.Sprintf("/var/tmp/safescale-unfreeze-%d.pid", time.Now().UnixNano())
+	freeze := fmt.Sprintf(freezeScript, mountPoint, watchdog, int(freezeTimeout/time.Second))
+	unfreeze := fmt.Sprintf(unfreezeScript, mountPoint, watchdog)
+	return freeze, unfreeze, nil
+}
+
+// freeze freezes the filesystem mounted on mountPoint on the host identified by hostID, and returns the function
+// unfreezing it; the host unfreezes it by itself after freezeTimeout anyway
+func (svc *VolumeService) freeze(hostID, mountPoint string) (func(), error) {
+	freeze, unfreeze, err := freezeScripts(mountPoint)
+	if err != nil {
+		return nil, err
+	}
+	ssh, err := svc.provider.GetSSHConfig(hostID)
+	if err != nil {
+		return nil, err
+	}
+	cmd, err := ssh.SudoCommand(freeze)
+	if err != nil {
+		return nil, err
+	}
+	retcode, _, stderr, err := cmd.Run()
+	if err == nil && retcode != 0 {
+		err = fmt.Errorf("fsfreeze -f %s failed: %s", mountPoint, stderr)
+	}
+	if err != nil {
+		// The freeze may have succeeded before the connection was lost, the watchdog unfreezes it anyway
+		return nil, err
+	}
+	return func() {
+		cmd, err := ssh.SudoCommand(unfreeze)
+		if err == nil {
+			var retcode int
+			var stderr string
+			retcode, _, stderr, err = cmd.Run()
+			if err == nil && retcode != 0 {
+				err = fmt.Errorf("%s", stderr)
+			}
+		}
+		if err != nil {
+			log.Errorf("Failed to unfreeze the filesystem mounted on '%s', it will be unfrozen after %s: %v", mountPoint, freezeTimeout, err)
+		}
+	}, nil
+}
+
+// ListSnapshots lists the snapshots of the volume identified by ref, or all the snapshots if ref is empty
+func (svc *VolumeService) ListSnapshots(ref string) ([]api.Snapshot, error) {
+	volumeID := ""
+	if ref != "" {
+		volume, err := svc.Get(ref)
+		if err != nil {
+			return nil, err
+		}
+		if volume == nil {
+			return nil, errors.Wrap(providers.ResourceNotFoundError("volume", ref), "Cannot list snapshots")
+		}
+		volumeID = volume.ID
+	}
+	return svc.provider.ListVolumeSnapshots(volumeID)
+}
+
+// getSnapshot returns the snapshot identified by ref, ref can be the name or the id
+func (svc *VolumeService) getSnapshot(ref string) (*api.Snapshot, error) {
+	snapshots, err := svc.provider.ListVolumeSnapshots("")
+	if err != nil {
+		return nil, err
+	}
+	var found *api.Snapshot
+	for i, s := range snapshots {
+		if s.ID == ref {
+			return &snapshots[i], nil
+		}
+		if s.Name == ref {
+			if found != nil {
+				return nil, fmt.Errorf("several snapshots are named '%s', use the id", ref)
+			}
+			found = &snapshots[i]
+		}
+	}
+	if found == nil {
+		return nil, providers.ResourceNotFoundError("snapshot", ref)
+	}
+	return found, nil
+}
+
+// DeleteSnapshot deletes the snapshot identified by ref, ref can be the name or the id
+func (svc *VolumeService) DeleteSnapshot(ref string) error {
+	snapshot, err := svc.getSnapshot(ref)
+	if err != nil {
+		return errors.Wrap(err, "Cannot delete snapshot")
+	}
+	return svc.provider.DeleteVolumeSnapshot(snapshot.ID)
+}
+
+// RestoreSnapshot creates the volume 'name' with the content of the snapshot identified by snapshotRef
+// size may be 0 to use the size of the snapshot
+func (svc *VolumeService) RestoreSnapshot(snapshotRef string, name string, size int, speed VolumeSpeed.Enum) (*api.Volume, error) {
+	snapshot, err := svc.getSnapshot(snapshotRef)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot restore snapshot")
+	}
+	if size != 0 && size < snapshot.Size {
+		return nil, fmt.Errorf("Cannot restore snapshot '%s' of %d GB on a volume of %d GB", snapshotRef, snapshot.Size, size)
+	}
+	return svc.provider.CreateVolumeFromSnapshot(snapshot.ID, api.VolumeRequest{
+		Name:  name,
+		Size:  size,
+		Speed: speed,
+	})
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/enums/VolumeSpeed"
+	"github.com/CS-SI/SafeScale/providers/enums/VolumeState"
+	"github.com/CS-SI/SafeScale/providers/local"
+	"github.com/CS-SI/SafeScale/providers/metadata"
+)
+
+func TestVolumeService_Import(t *testing.T) {
+	clt, err := (&local.Client{}).Build(map[string]interface{}{"name": t.Name(), "TransitionDelay": "50ms"})
+	require.Nil(t, err)
+	svc := NewVolumeService(clt).(*VolumeService)
+
+	volume, err := svc.provider.CreateVolume(api.VolumeRequest{Name: "vol1", Size: 10, Speed: VolumeSpeed.HDD})
+	require.Nil(t, err)
+	// Volume created behind SafeScale's back
+	require.Nil(t, metadata.RemoveVolume(svc.provider, volume.ID))
+	got, err := svc.Get("vol1")
+	require.Nil(t, err)
+	assert.Nil(t, got)
+
+	imported, err := svc.Import("vol1")
+	require.Nil(t, err)
+	assert.Equal(t, volume.ID, imported.ID)
+	got, err = svc.Get("vol1")
+	require.Nil(t, err)
+	assert.Equal(t, volume.ID, got.ID)
+
+	_, err = svc.Import("vol1")
+	assert.NotNil(t, err)
+	_, err = svc.Import("unknown")
+	assert.NotNil(t, err)
+}
+
+func TestVolumeService_Snapshots(t *testing.T) {
+	clt, err := (&local.Client{}).Build(map[string]interface{}{"name": t.Name(), "TransitionDelay": "50ms"})
+	require.Nil(t, err)
+	svc := NewVolumeService(clt).(*VolumeService)
+
+	volume, err := svc.provider.CreateVolume(api.VolumeRequest{Name: "vol1", Size: 10, Speed: VolumeSpeed.HDD})
+	require.Nil(t, err)
+	_, err = svc.provider.WaitVolumeState(volume.ID, VolumeState.AVAILABLE, 5*time.Second)
+	require.Nil(t, err)
+
+	// Consistent mode has nothing to freeze on a volume not attached
+	snapshot, err := svc.CreateSnapshot("vol1", "", true)
+	require.Nil(t, err)
+	assert.Contains(t, snapshot.Name, "vol1-")
+	_, err = svc.CreateSnapshot("vol1", "before-upgrade", false)
+	require.Nil(t, err)
+	_, err = svc.CreateSnapshot("unknown", "snap", false)
+	assert.NotNil(t, err)
+
+	snapshots, err := svc.ListSnapshots("vol1")
+	require.Nil(t, err)
+	assert.Len(t, snapshots, 2)
+
+	_, err = svc.RestoreSnapshot("before-upgrade", "vol2", 5, VolumeSpeed.HDD)
+	assert.NotNil(t, err)
+	restored, err := svc.RestoreSnapshot("before-upgrade", "vol2", 0, VolumeSpeed.HDD)
+	require.Nil(t, err)
+	assert.Equal(t, 10, restored.Size)
+	got, err := svc.Get("vol2")
+	require.Nil(t, err)
+	assert.Equal(t, restored.ID, got.ID)
+
+	require.Nil(t, svc.DeleteSnapshot("before-upgrade"))
+	assert.NotNil(t, svc.DeleteSnapshot("before-upgrade"))
+	require.Nil(t, svc.DeleteSnapshot(snapshot.ID))
+	snapshots, err = svc.ListSnapshots("")
+	require.Nil(t, err)
+	assert.Empty(t, snapshots)
+}
+
+func TestFreezeScripts(t *testing.T) {
+	for _, mountPoint := range []string{"/", "//", "/data/..", "data", "", "/data/it's", "/data/$(reboot)", "/data dir"} {
+		_, _, err := freezeScripts(mountPoint)
+		assert.NotNil(t, err, mountPoint)
+	}
+
+	freeze, unfreeze, err := freezeScripts("/data/vol-1/")
+	require.Nil(t, err)
+	// The unfreeze is scheduled on the host before the freeze, which is bounded too
+	watchdog := strings.Index(freeze, "sleep 30; fsfreeze -u /data/vol-1'")
+	frozen := strings.Index(freeze, "timeout 30 fsfreeze -f /data/vol-1")
+	assert.True(t, watchdog >= 0 && frozen > watchdog, freeze)
+	assert.Contains(t, unfreeze, "fsfreeze -u /data/vol-1")
+	assert.Contains(t, unfreeze, "kill $(cat /var/tmp/safescale-unfreeze-")
+}
+
+// creatingSnapshots is a provider whose snapshots never leave the state CREATING
+type creatingSnapshots struct {
+	api.ClientAPI
+}
+
+func (c creatingSnapshots) GetVolumeSnapshot(id string) (*api.Snapshot, error) {
+	snapshot, err := c.ClientAPI.GetVolumeSnapshot(id)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.State = VolumeState.CREATING
+	return snapshot, nil
+}
+
+func TestVolumeService_waitSnapshot(t *testing.T) {
+	clt, err := (&local.Client{}).Build(map[string]interface{}{"name": t.Name(), "TransitionDelay": "0s"})
+	require.Nil(t, err)
+	svc := NewVolumeService(clt).(*VolumeService)
+	volume, err := svc.provider.CreateVolume(api.VolumeRequest{Name: "vol1", Size: 10, Speed: VolumeSpeed.HDD})
+	require.Nil(t, err)
+
+	snapshot, err := svc.provider.CreateVolumeSnapshot(api.SnapshotRequest{Name: "snap1", VolumeID: volume.ID})
+	require.Nil(t, err)
+	created, err := svc.waitSnapshot(snapshot, 0)
+	require.Nil(t, err)
+	assert.Equal(t, VolumeState.AVAILABLE, created.State)
+
+	// A snapshot not created in time is deleted
+	snapshot, err = svc.provider.CreateVolumeSnapshot(api.SnapshotRequest{Name: "snap2", VolumeID: volume.ID})
+	require.Nil(t, err)
+	creating := &VolumeService{provider: providers.FromClient(creatingSnapshots{clt})}
+	_, err = creating.waitSnapshot(snapshot, 50*time.Millisecond)
+	assert.NotNil(t, err)
+	_, err = svc.provider.GetVolumeSnapshot(snapshot.ID)
+	assert.NotNil(t, err)
+}
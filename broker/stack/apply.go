@@ -0,0 +1,149 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stack
+
+import (
+	"fmt"
+)
+
+// Backend runs the operations on the resources of the tenant
+type Backend interface {
+	// State returns the resources managed by SafeScale on the tenant
+	State() (*State, error)
+
+	CreateNetwork(network Network) error
+	DeleteNetwork(name string) error
+	CreateHost(host Host) error
+	DeleteHost(name string) error
+	CreateVolume(volume Volume) error
+	DeleteVolume(name string) error
+	AttachVolume(volume string, attachment Attachment) error
+	DetachVolume(volume string, host string) error
+	CreateNas(nas Nas) error
+	DeleteNas(name string) error
+	MountNas(nas string, client NasClient) error
+	UmountNas(nas string, host string) error
+	CreateContainer(name string) error
+	DeleteContainer(name string) error
+	MountContainer(container string, mount ContainerMount) error
+	UmountContainer(container string, host string) error
+}
+
+// Apply runs the actions of the plan in order, using the definitions of the stack
+// progress, if not nil, is called before each action
+// Apply stops at the first failure; as the plan only contains the missing operations, it can be computed and applied
+// again once the problem is fixed
+func Apply(b Backend, s *Stack, p *Plan, progress func(Action)) error {
+	for _, a := range p.Actions {
+		if progress != nil {
+			progress(a)
+		}
+		err := run(b, s, a)
+		if err != nil {
+			return fmt.Errorf("%s failed: %s", a.String(), err.Error())
+		}
+	}
+	return nil
+}
+
+// run runs a single action
+func run(b Backend, s *Stack, a Action) error {
+	switch a.ResourceType + "/" + a.Operation {
+	case "network/" + Create:
+		for _, n := range s.Networks {
+			if n.Name == a.Name {
+				n.Tags = s.tags(n.Tags)
+				return b.CreateNetwork(n)
+			}
+		}
+	case "network/" + Delete:
+		return b.DeleteNetwork(a.Name)
+	case "host/" + Create:
+		for _, h := range s.Hosts {
+			if h.Name == a.Name {
+				h.Tags = s.tags(h.Tags)
+				return b.CreateHost(h)
+			}
+		}
+	case "host/" + Delete:
+		return b.DeleteHost(a.Name)
+	case "volume/" + Create:
+		for _, v := range s.Volumes {
+			if v.Name == a.Name {
+				v.Tags = s.tags(v.Tags)
+				return b.CreateVolume(v)
+			}
+		}
+	case "volume/" + Delete:
+		return b.DeleteVolume(a.Name)
+	case "volume/" + Attach:
+		for _, v := range s.Volumes {
+			if v.Name == a.Name && v.Attach != nil {
+				return b.AttachVolume(v.Name, *v.Attach)
+			}
+		}
+	case "volume/" + Detach:
+		return b.DetachVolume(a.Name, a.Host)
+	case "nas/" + Create:
+		for _, n := range s.Nas {
+			if n.Name == a.Name {
+				n.Tags = s.tags(n.Tags)
+				return b.CreateNas(n)
+			}
+		}
+	case "nas/" + Delete:
+		return b.DeleteNas(a.Name)
+	case "nas/" + Mount:
+		for _, n := range s.Nas {
+			if n.Name != a.Name {
+				continue
+			}
+			for _, c := range n.Clients {
+				if c.Host == a.Host {
+					return b.MountNas(n.Name, c)
+				}
+			}
+		}
+	case "nas/" + Umount:
+		return b.UmountNas(a.Name, a.Host)
+	case "container/" + Create:
+		return b.CreateContainer(a.Name)
+	case "container/" + Delete:
+		return b.DeleteContainer(a.Name)
+	case "container/" + Mount:
+		for _, c := range s.Containers {
+			if c.Name == a.Name && c.Mount != nil {
+				return b.MountContainer(c.Name, *c.Mount)
+			}
+		}
+	case "container/" + Umount:
+		return b.UmountContainer(a.Name, a.Host)
+	default:
+		return fmt.Errorf("unknown operation")
+	}
+	return fmt.Errorf("%s '%s' is not declared in stack '%s'", a.ResourceType, a.Name, s.Name)
+}
+
+// tags returns the tags of a resource created by the stack: the tags declared in the stack, and StackTag
+func (s *Stack) tags(declared map[string]string) map[string]string {
+	tags := map[string]string{}
+	for k, v := range declared {
+		tags[k] = v
+	}
+	tags[StackTag] = s.Name
+	return tags
+}
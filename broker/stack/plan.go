@@ -0,0 +1,337 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stack
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Operations of the actions of a plan
+const (
+	Create = "create"
+	Delete = "delete"
+	Attach = "attach"
+	Detach = "detach"
+	Mount  = "mount"
+	Umount = "umount"
+)
+
+// StackTag is the tag recording the name of the stack on the resources it creates, destroying a stack deletes only
+// these resources
+const StackTag = "safescale.stack"
+
+// State describes the resources managed by SafeScale on the tenant
+type State struct {
+	// Networks contains the CIDR of the networks, indexed by name
+	Networks map[string]string
+	// Hosts contains the names of the hosts
+	Hosts map[string]bool
+	// Volumes contains the host each volume is attached to (empty if not attached), indexed by volume name
+	Volumes map[string]string
+	// Nas contains the NAS, indexed by name
+	Nas map[string]NasState
+	// Containers contains the host each container is mounted on (empty if not mounted), indexed by container name
+	Containers map[string]string
+	// Stacks contains the stack which created each resource, indexed by resource type and name ("host/host1")
+	Stacks map[string]string
+}
+
+// NasState describes an existing NAS
+type NasState struct {
+	Host string
+	Path string
+	// Clients contains the hosts mounting the NAS
+	Clients map[string]bool
+}
+
+// NewState returns an empty state
+func NewState() *State {
+	return &State{
+		Networks:   map[string]string{},
+		Hosts:      map[string]bool{},
+		Volumes:    map[string]string{},
+		Nas:        map[string]NasState{},
+		Containers: map[string]string{},
+		Stacks:     map[string]string{},
+	}
+}
+
+// SetStack records the stack which created a resource, read from its tags
+func (s *State) SetStack(resourceType, name string, tags map[string]string) {
+	if stack, ok := tags[StackTag]; ok {
+		s.Stacks[resourceType+"/"+name] = stack
+	}
+}
+
+// created tells if the resource has been created by the stack
+func (s *State) created(stack, resourceType, name string) bool {
+	return s.Stacks[resourceType+"/"+name] == stack
+}
+
+// Action is an operation on a resource
+type Action struct {
+	Operation    string
+	ResourceType string
+	Name         string
+	// Host is the host concerned by an attach, detach, mount or umount
+	Host string `json:",omitempty"`
+}
+
+// String returns a readable description of the action
+func (a Action) String() string {
+	switch a.Operation {
+	case Attach:
+		return fmt.Sprintf("Attaching %s '%s' to host '%s'", a.ResourceType, a.Name, a.Host)
+	case Detach:
+		return fmt.Sprintf("Detaching %s '%s' from host '%s'", a.ResourceType, a.Name, a.Host)
+	case Mount:
+		return fmt.Sprintf("Mounting %s '%s' on host '%s'", a.ResourceType, a.Name, a.Host)
+	case Umount:
+		return fmt.Sprintf("Unmounting %s '%s' from host '%s'", a.ResourceType, a.Name, a.Host)
+	case Create:
+		return fmt.Sprintf("Creating %s '%s'", a.ResourceType, a.Name)
+	case Delete:
+		return fmt.Sprintf("Deleting %s '%s'", a.ResourceType, a.Name)
+	}
+	return fmt.Sprintf("%s %s '%s'", a.Operation, a.ResourceType, a.Name)
+}
+
+// Plan is the ordered list of actions to run to reach the state described by a stack
+type Plan struct {
+	Stack   string
+	Actions []Action
+	// Warnings lists the differences between the stack and the tenant that won't be fixed by the plan
+	Warnings []string `json:",omitempty"`
+}
+
+// add appends an action to the plan
+func (p *Plan) add(operation, resourceType, name, host string) {
+	p.Actions = append(p.Actions, Action{Operation: operation, ResourceType: resourceType, Name: name, Host: host})
+}
+
+// warn appends a warning to the plan
+func (p *Plan) warn(format string, args ...interface{}) {
+	p.Warnings = append(p.Warnings, fmt.Sprintf(format, args...))
+}
+
+// warnExisting warns that an existing resource used by the stack won't be deleted with it, if not created by it
+func (p *Plan) warnExisting(s *Stack, state *State, resourceType, name string) {
+	if !state.created(s.Name, resourceType, name) {
+		p.warn("%s '%s' exists and was not created by the stack, it won't be deleted with it", resourceType, name)
+	}
+}
+
+// Diff computes the actions creating the resources of the stack missing in state, in dependency order
+// Existing resources are never modified nor replaced: differences of sizing are reported as warnings, like the
+// resources used by the stack but not created by it, which won't be deleted with the stack
+func Diff(s *Stack, state *State) (*Plan, error) {
+	p := &Plan{Stack: s.Name}
+	networks := map[string]bool{}
+	hosts := map[string]bool{}
+	for name := range state.Networks {
+		networks[name] = true
+	}
+	for name := range state.Hosts {
+		hosts[name] = true
+	}
+
+	for _, n := range s.Networks {
+		if cidr, ok := state.Networks[n.Name]; ok {
+			if cidr != n.CIDR {
+				p.warn("network '%s' exists with CIDR '%s' instead of '%s'", n.Name, cidr, n.CIDR)
+			}
+			p.warnExisting(s, state, "network", n.Name)
+			continue
+		}
+		p.add(Create, "network", n.Name, "")
+		networks[n.Name] = true
+	}
+	for _, h := range s.Hosts {
+		if h.Network != "" && !networks[h.Network] {
+			return nil, fmt.Errorf("network '%s' of host '%s' is neither declared in the stack nor existing", h.Network, h.Name)
+		}
+		if state.Hosts[h.Name] {
+			p.warnExisting(s, state, "host", h.Name)
+			continue
+		}
+		p.add(Create, "host", h.Name, "")
+		hosts[h.Name] = true
+	}
+
+	// Detachments first, a volume may move between hosts
+	var attachments []Action
+	for _, v := range s.Volumes {
+		attached, exists := state.Volumes[v.Name]
+		if exists {
+			p.warnExisting(s, state, "volume", v.Name)
+		} else {
+			p.add(Create, "volume", v.Name, "")
+		}
+		if v.Attach == nil {
+			if attached != "" {
+				p.warn("volume '%s' is attached to host '%s' but no attachment is declared", v.Name, attached)
+			}
+			continue
+		}
+		if !hosts[v.Attach.Host] {
+			return nil, fmt.Errorf("host '%s' of volume '%s' is neither declared in the stack nor existing", v.Attach.Host, v.Name)
+		}
+		if attached == v.Attach.Host {
+			continue
+		}
+		if attached != "" {
+			p.add(Detach, "volume", v.Name, attached)
+		}
+		attachments = append(attachments, Action{Operation: Attach, ResourceType: "volume", Name: v.Name, Host: v.Attach.Host})
+	}
+	p.Actions = append(p.Actions, attachments...)
+
+	for _, n := range s.Nas {
+		if !hosts[n.Host] {
+			return nil, fmt.Errorf("host '%s' of nas '%s' is neither declared in the stack nor existing", n.Host, n.Name)
+		}
+		existing, exists := state.Nas[n.Name]
+		if exists {
+			if existing.Host != n.Host || existing.Path != n.Path {
+				p.warn("nas '%s' exists on '%s:%s' instead of '%s:%s'", n.Name, existing.Host, existing.Path, n.Host, n.Path)
+			}
+			p.warnExisting(s, state, "nas", n.Name)
+		} else {
+			p.add(Create, "nas", n.Name, "")
+		}
+		for _, c := range n.Clients {
+			if !hosts[c.Host] {
+				return nil, fmt.Errorf("client '%s' of nas '%s' is neither declared in the stack nor existing", c.Host, n.Name)
+			}
+			if exists && existing.Clients[c.Host] {
+				continue
+			}
+			p.add(Mount, "nas", n.Name, c.Host)
+		}
+	}
+
+	for _, c := range s.Containers {
+		mounted, exists := state.Containers[c.Name]
+		if !exists {
+			p.add(Create, "container", c.Name, "")
+		}
+		if c.Mount == nil {
+			continue
+		}
+		if !hosts[c.Mount.Host] {
+			return nil, fmt.Errorf("host '%s' of container '%s' is neither declared in the stack nor existing", c.Mount.Host, c.Name)
+		}
+		if mounted == c.Mount.Host {
+			continue
+		}
+		if mounted != "" {
+			p.add(Umount, "container", c.Name, mounted)
+		}
+		p.add(Mount, "container", c.Name, c.Mount.Host)
+	}
+	return p, nil
+}
+
+// Destroy computes the actions deleting the existing resources created by the stack, in reverse dependency order
+// The resources declared in the stack but not created by it (existing when the stack was applied) are left, only
+// the attachments and mounts declared in the stack are undone; containers can't record their stack and are left too
+func Destroy(s *Stack, state *State) *Plan {
+	p := &Plan{Stack: s.Name}
+	for i := len(s.Containers) - 1; i >= 0; i-- {
+		c := s.Containers[i]
+		mounted, exists := state.Containers[c.Name]
+		if !exists {
+			continue
+		}
+		if c.Mount != nil && mounted == c.Mount.Host {
+			p.add(Umount, "container", c.Name, mounted)
+		}
+		p.warn("container '%s' is left, delete it with 'broker container delete' if it was created by the stack", c.Name)
+	}
+	for i := len(s.Nas) - 1; i >= 0; i-- {
+		n := s.Nas[i]
+		existing, exists := state.Nas[n.Name]
+		if !exists {
+			continue
+		}
+		if !state.created(s.Name, "nas", n.Name) {
+			for _, c := range n.Clients {
+				if existing.Clients[c.Host] {
+					p.add(Umount, "nas", n.Name, c.Host)
+				}
+			}
+			p.warn("nas '%s' was not created by the stack, it is left", n.Name)
+			continue
+		}
+		for _, c := range sortedKeys(existing.Clients) {
+			p.add(Umount, "nas", n.Name, c)
+		}
+		p.add(Delete, "nas", n.Name, "")
+	}
+	for i := len(s.Volumes) - 1; i >= 0; i-- {
+		v := s.Volumes[i]
+		attached, exists := state.Volumes[v.Name]
+		if !exists {
+			continue
+		}
+		if !state.created(s.Name, "volume", v.Name) {
+			if v.Attach != nil && attached == v.Attach.Host {
+				p.add(Detach, "volume", v.Name, attached)
+			}
+			p.warn("volume '%s' was not created by the stack, it is left", v.Name)
+			continue
+		}
+		if attached != "" {
+			p.add(Detach, "volume", v.Name, attached)
+		}
+		p.add(Delete, "volume", v.Name, "")
+	}
+	for i := len(s.Hosts) - 1; i >= 0; i-- {
+		name := s.Hosts[i].Name
+		if !state.Hosts[name] {
+			continue
+		}
+		if !state.created(s.Name, "host", name) {
+			p.warn("host '%s' was not created by the stack, it is left", name)
+			continue
+		}
+		p.add(Delete, "host", name, "")
+	}
+	for i := len(s.Networks) - 1; i >= 0; i-- {
+		name := s.Networks[i].Name
+		if _, ok := state.Networks[name]; !ok {
+			continue
+		}
+		if !state.created(s.Name, "network", name) {
+			p.warn("network '%s' was not created by the stack, it is left", name)
+			continue
+		}
+		p.add(Delete, "network", name, "")
+	}
+	return p
+}
+
+// sortedKeys returns the keys of m in alphabetical order
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
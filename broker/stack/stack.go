@@ -0,0 +1,289 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stack
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/CS-SI/SafeScale/providers/api"
+)
+
+// Speeds contains the allowed speeds of a volume
+var Speeds = []string{"COLD", "HDD", "SSD"}
+
+// Stack describes the resources wanted on the tenant
+type Stack struct {
+	Name       string
+	Networks   []Network
+	Hosts      []Host
+	Volumes    []Volume
+	Nas        []Nas
+	Containers []Container
+}
+
+// Network describes a network and its gateway
+type Network struct {
+	Name    string
+	CIDR    string
+	Gateway Gateway
+	Tags    map[string]string
+}
+
+// Gateway describes the gateway of a network
+type Gateway struct {
+	Name string
+	CPU  int
+	RAM  float32
+	Disk int
+	OS   string
+}
+
+// Host describes a host
+type Host struct {
+	Name    string
+	Network string
+	CPU     int
+	RAM     float32
+	Disk    int
+	OS      string
+	Public  bool
+	Tags    map[string]string
+}
+
+// Volume describes a volume and the host it is attached to
+type Volume struct {
+	Name   string
+	Size   int
+	Speed  string
+	Attach *Attachment
+	Tags   map[string]string
+}
+
+// Attachment describes the attachment of a volume to a host
+type Attachment struct {
+	Host   string
+	Path   string
+	Format string
+}
+
+// Nas describes a NAS, the host exporting it and the hosts mounting it
+type Nas struct {
+	Name    string
+	Host    string
+	Path    string
+	Clients []NasClient
+	Tags    map[string]string
+}
+
+// NasClient describes a host mounting a NAS
+type NasClient struct {
+	Host string
+	Path string
+}
+
+// Container describes an object storage container and the host it is mounted on
+type Container struct {
+	Name  string
+	Mount *ContainerMount
+}
+
+// ContainerMount describes the mount of a container on a host
+type ContainerMount struct {
+	Host string
+	Path string
+}
+
+// Load reads the stack described in the YAML file 'path'
+func Load(path string) (*Stack, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stack file '%s': %s", path, err.Error())
+	}
+	s, err := Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stack file '%s': %s", path, err.Error())
+	}
+	return s, nil
+}
+
+// Parse decodes a stack from its YAML description, fills the missing values with the defaults of the broker commands
+// and validates it
+func Parse(content []byte) (*Stack, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	err := v.ReadConfig(bytes.NewBuffer(content))
+	if err != nil {
+		return nil, fmt.Errorf("syntax error: %s", err.Error())
+	}
+	if !v.IsSet("stack") {
+		return nil, fmt.Errorf("stack description must begin with 'stack:'")
+	}
+	var s Stack
+	err = v.UnmarshalKey("stack", &s)
+	if err != nil {
+		return nil, err
+	}
+	s.setDefaults()
+	err = s.validate()
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// setDefaults sets the values not given in the description to the defaults used by the broker commands
+func (s *Stack) setDefaults() {
+	for i := range s.Networks {
+		n := &s.Networks[i]
+		if n.CIDR == "" {
+			n.CIDR = "192.168.0.0/24"
+		}
+		setSizingDefaults(&n.Gateway.CPU, &n.Gateway.RAM, &n.Gateway.Disk, &n.Gateway.OS)
+	}
+	for i := range s.Hosts {
+		h := &s.Hosts[i]
+		setSizingDefaults(&h.CPU, &h.RAM, &h.Disk, &h.OS)
+	}
+	for i := range s.Volumes {
+		v := &s.Volumes[i]
+		if v.Size == 0 {
+			v.Size = 10
+		}
+		if v.Speed == "" {
+			v.Speed = "HDD"
+		}
+		v.Speed = strings.ToUpper(v.Speed)
+		if v.Attach != nil {
+			if v.Attach.Path == "" {
+				v.Attach.Path = api.DefaultVolumeMountPoint + v.Name
+			}
+			if v.Attach.Format == "" {
+				v.Attach.Format = "ext4"
+			}
+		}
+	}
+	for i := range s.Nas {
+		n := &s.Nas[i]
+		if n.Path == "" {
+			n.Path = api.DefaultNasExposedPath
+		}
+		for j := range n.Clients {
+			if n.Clients[j].Path == "" {
+				n.Clients[j].Path = api.DefaultNasMountPath
+			}
+		}
+	}
+	for i := range s.Containers {
+		c := &s.Containers[i]
+		if c.Mount != nil && c.Mount.Path == "" {
+			c.Mount.Path = api.DefaultContainerMountPoint + c.Name
+		}
+	}
+}
+
+// setSizingDefaults sets the sizing of a host to the defaults used by the broker commands
+func setSizingDefaults(cpu *int, ram *float32, disk *int, os *string) {
+	if *cpu == 0 {
+		*cpu = 1
+	}
+	if *ram == 0 {
+		*ram = 1
+	}
+	if *disk == 0 {
+		*disk = 100
+	}
+	if *os == "" {
+		*os = "Ubuntu 16.04"
+	}
+}
+
+// validate checks the stack is consistent
+// References to resources not declared in the stack are allowed, their existence is checked when planning
+func (s *Stack) validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("missing 'name'")
+	}
+	names := map[string]bool{}
+	unique := func(kind, name string) error {
+		if name == "" {
+			return fmt.Errorf("a %s has no name", kind)
+		}
+		if names[kind+"/"+name] {
+			return fmt.Errorf("%s '%s' is declared several times", kind, name)
+		}
+		names[kind+"/"+name] = true
+		return nil
+	}
+	for _, n := range s.Networks {
+		if err := unique("network", n.Name); err != nil {
+			return err
+		}
+	}
+	for _, h := range s.Hosts {
+		if err := unique("host", h.Name); err != nil {
+			return err
+		}
+	}
+	for _, v := range s.Volumes {
+		if err := unique("volume", v.Name); err != nil {
+			return err
+		}
+		if !isSpeed(v.Speed) {
+			return fmt.Errorf("volume '%s' has an invalid speed '%s' (allowed values: %s)", v.Name, v.Speed, strings.Join(Speeds, ", "))
+		}
+		if v.Attach != nil && v.Attach.Host == "" {
+			return fmt.Errorf("attachment of volume '%s' has no host", v.Name)
+		}
+	}
+	for _, n := range s.Nas {
+		if err := unique("nas", n.Name); err != nil {
+			return err
+		}
+		if n.Host == "" {
+			return fmt.Errorf("nas '%s' has no host", n.Name)
+		}
+		for _, c := range n.Clients {
+			if c.Host == "" {
+				return fmt.Errorf("a client of nas '%s' has no host", n.Name)
+			}
+		}
+	}
+	for _, c := range s.Containers {
+		if err := unique("container", c.Name); err != nil {
+			return err
+		}
+		if c.Mount != nil && c.Mount.Host == "" {
+			return fmt.Errorf("mount of container '%s' has no host", c.Name)
+		}
+	}
+	return nil
+}
+
+// isSpeed tells if speed is an allowed volume speed
+func isSpeed(speed string) bool {
+	for _, s := range Speeds {
+		if s == speed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,242 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stack_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/broker/stack"
+)
+
+const description = `
+stack:
+  name: demo
+  networks:
+    - name: net1
+      cidr: 192.168.1.0/24
+  hosts:
+    - name: host1
+      network: net1
+      cpu: 2
+    - name: host2
+      network: net1
+  volumes:
+    - name: vol1
+      size: 100
+      speed: ssd
+      attach:
+        host: host1
+  nas:
+    - name: nas1
+      host: host1
+      clients:
+        - host: host2
+`
+
+// fakeBackend records the operations and maintains the state accordingly
+type fakeBackend struct {
+	state *stack.State
+	calls []string
+}
+
+func (b *fakeBackend) State() (*stack.State, error) { return b.state, nil }
+func (b *fakeBackend) record(format string, args ...interface{}) error {
+	b.calls = append(b.calls, fmt.Sprintf(format, args...))
+	return nil
+}
+func (b *fakeBackend) CreateNetwork(n stack.Network) error {
+	b.state.Networks[n.Name] = n.CIDR
+	b.state.SetStack("network", n.Name, n.Tags)
+	return b.record("create network %s", n.Name)
+}
+func (b *fakeBackend) DeleteNetwork(name string) error {
+	delete(b.state.Networks, name)
+	return b.record("delete network %s", name)
+}
+func (b *fakeBackend) CreateHost(h stack.Host) error {
+	b.state.Hosts[h.Name] = true
+	b.state.SetStack("host", h.Name, h.Tags)
+	return b.record("create host %s", h.Name)
+}
+func (b *fakeBackend) DeleteHost(name string) error {
+	delete(b.state.Hosts, name)
+	return b.record("delete host %s", name)
+}
+func (b *fakeBackend) CreateVolume(v stack.Volume) error {
+	b.state.Volumes[v.Name] = ""
+	b.state.SetStack("volume", v.Name, v.Tags)
+	return b.record("create volume %s %d %s", v.Name, v.Size, v.Speed)
+}
+func (b *fakeBackend) DeleteVolume(name string) error {
+	delete(b.state.Volumes, name)
+	return b.record("delete volume %s", name)
+}
+func (b *fakeBackend) AttachVolume(volume string, a stack.Attachment) error {
+	b.state.Volumes[volume] = a.Host
+	return b.record("attach volume %s %s %s", volume, a.Host, a.Path)
+}
+func (b *fakeBackend) DetachVolume(volume string, host string) error {
+	b.state.Volumes[volume] = ""
+	return b.record("detach volume %s %s", volume, host)
+}
+func (b *fakeBackend) CreateNas(n stack.Nas) error {
+	b.state.Nas[n.Name] = stack.NasState{Host: n.Host, Path: n.Path, Clients: map[string]bool{}}
+	b.state.SetStack("nas", n.Name, n.Tags)
+	return b.record("create nas %s %s", n.Name, n.Path)
+}
+func (b *fakeBackend) DeleteNas(name string) error {
+	delete(b.state.Nas, name)
+	return b.record("delete nas %s", name)
+}
+func (b *fakeBackend) MountNas(nas string, c stack.NasClient) error {
+	b.state.Nas[nas].Clients[c.Host] = true
+	return b.record("mount nas %s %s %s", nas, c.Host, c.Path)
+}
+func (b *fakeBackend) UmountNas(nas string, host string) error {
+	delete(b.state.Nas[nas].Clients, host)
+	return b.record("umount nas %s %s", nas, host)
+}
+func (b *fakeBackend) CreateContainer(name string) error {
+	b.state.Containers[name] = ""
+	return b.record("create container %s", name)
+}
+func (b *fakeBackend) DeleteContainer(name string) error {
+	delete(b.state.Containers, name)
+	return b.record("delete container %s", name)
+}
+func (b *fakeBackend) MountContainer(container string, m stack.ContainerMount) error {
+	b.state.Containers[container] = m.Host
+	return b.record("mount container %s %s", container, m.Host)
+}
+func (b *fakeBackend) UmountContainer(container string, host string) error {
+	b.state.Containers[container] = ""
+	return b.record("umount container %s %s", container, host)
+}
+
+func TestParse(t *testing.T) {
+	s, err := stack.Parse([]byte(description))
+	require.Nil(t, err)
+	assert.Equal(t, "demo", s.Name)
+	require.Equal(t, 2, len(s.Hosts))
+	assert.Equal(t, 2, s.Hosts[0].CPU)
+	assert.Equal(t, 1, s.Hosts[1].CPU)
+	assert.Equal(t, "Ubuntu 16.04", s.Hosts[1].OS)
+	assert.Equal(t, "SSD", s.Volumes[0].Speed)
+	assert.Equal(t, "/shared/vol1", s.Volumes[0].Attach.Path)
+	assert.Equal(t, "/shared/data", s.Nas[0].Path)
+	assert.Equal(t, "/data", s.Nas[0].Clients[0].Path)
+
+	_, err = stack.Parse([]byte("stack:\n  name: demo\n  hosts:\n    - name: h\n    - name: h\n"))
+	assert.NotNil(t, err)
+	_, err = stack.Parse([]byte("stack:\n  name: demo\n  volumes:\n    - name: v\n      speed: fast\n"))
+	assert.NotNil(t, err)
+	_, err = stack.Parse([]byte("hosts:\n  - name: h\n"))
+	assert.NotNil(t, err)
+}
+
+func TestApplyDestroy(t *testing.T) {
+	s, err := stack.Parse([]byte(description))
+	require.Nil(t, err)
+	b := &fakeBackend{state: stack.NewState()}
+	b.state.Hosts["other"] = true
+
+	p, err := stack.Diff(s, b.state)
+	require.Nil(t, err)
+	require.Nil(t, stack.Apply(b, s, p, nil))
+	assert.Equal(t, []string{
+		"create network net1",
+		"create host host1",
+		"create host host2",
+		"create volume vol1 100 SSD",
+		"attach volume vol1 host1 /shared/vol1",
+		"create nas nas1 /shared/data",
+		"mount nas nas1 host2 /data",
+	}, b.calls)
+
+	// Once applied, there is nothing left to do
+	p, err = stack.Diff(s, b.state)
+	require.Nil(t, err)
+	assert.Empty(t, p.Actions)
+
+	// Moves the volume to another host
+	s.Volumes[0].Attach.Host = "host2"
+	p, err = stack.Diff(s, b.state)
+	require.Nil(t, err)
+	assert.Equal(t, []stack.Action{
+		{Operation: stack.Detach, ResourceType: "volume", Name: "vol1", Host: "host1"},
+		{Operation: stack.Attach, ResourceType: "volume", Name: "vol1", Host: "host2"},
+	}, p.Actions)
+
+	b.calls = nil
+	require.Nil(t, stack.Apply(b, s, stack.Destroy(s, b.state), nil))
+	assert.Equal(t, []string{
+		"umount nas nas1 host2",
+		"delete nas nas1",
+		"detach volume vol1 host1",
+		"delete volume vol1",
+		"delete host host2",
+		"delete host host1",
+		"delete network net1",
+	}, b.calls)
+	assert.Equal(t, map[string]bool{"other": true}, b.state.Hosts)
+
+	// Hosts must be on a known network
+	s.Networks = nil
+	_, err = stack.Diff(s, b.state)
+	assert.NotNil(t, err)
+}
+
+func TestDestroy_NotCreated(t *testing.T) {
+	s, err := stack.Parse([]byte(description))
+	require.Nil(t, err)
+	s.Containers = []stack.Container{{Name: "c1", Mount: &stack.ContainerMount{Host: "host2", Path: "/c1"}}}
+	b := &fakeBackend{state: stack.NewState()}
+	// The network, the volume and the container exist before the stack, host1 has been created by another stack
+	b.state.Networks["net1"] = "192.168.1.0/24"
+	b.state.Volumes["vol1"] = ""
+	b.state.Containers["c1"] = ""
+	b.state.Hosts["host1"] = true
+	b.state.SetStack("host", "host1", map[string]string{stack.StackTag: "other"})
+
+	p, err := stack.Diff(s, b.state)
+	require.Nil(t, err)
+	assert.Len(t, p.Warnings, 3)
+	require.Nil(t, stack.Apply(b, s, p, nil))
+	assert.Equal(t, "demo", b.state.Stacks["host/host2"])
+	assert.Equal(t, "demo", b.state.Stacks["nas/nas1"])
+
+	// Only the resources created by the stack are deleted, the attachments and mounts it made are undone
+	p = stack.Destroy(s, b.state)
+	assert.Len(t, p.Warnings, 4)
+	b.calls = nil
+	require.Nil(t, stack.Apply(b, s, p, nil))
+	assert.Equal(t, []string{
+		"umount container c1 host2",
+		"umount nas nas1 host2",
+		"delete nas nas1",
+		"detach volume vol1 host1",
+		"delete host host2",
+	}, b.calls)
+	assert.Equal(t, map[string]bool{"host1": true}, b.state.Hosts)
+	assert.Contains(t, b.state.Networks, "net1")
+	assert.Contains(t, b.state.Volumes, "vol1")
+	assert.Contains(t, b.state.Containers, "c1")
+}
@@ -17,9 +17,17 @@
 package utils
 
 import (
+	"time"
+
 	pb "github.com/CS-SI/SafeScale/broker"
+	"github.com/CS-SI/SafeScale/broker/daemon/jobs"
 	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/enums/IPVersion"
+	"github.com/CS-SI/SafeScale/providers/enums/RuleDirection"
+	providermetadata "github.com/CS-SI/SafeScale/providers/metadata"
 	"github.com/CS-SI/SafeScale/system"
+	"github.com/CS-SI/SafeScale/system/firewall"
+	"github.com/CS-SI/SafeScale/utils/metadata"
 )
 
 // ToPBSshConfig converts a system.SSHConfig into a SshConfig
@@ -31,6 +39,7 @@ func ToPBSshConfig(from *system.SSHConfig) *pb.SshConfig {
 	return &pb.SshConfig{
 		Gateway:    gw,
 		Host:       from.Host,
+		HostKey:    from.HostKey,
 		Port:       int32(from.Port),
 		PrivateKey: from.PrivateKey,
 		User:       from.User,
@@ -47,6 +56,7 @@ func ToSystemSshConfig(from *pb.SshConfig) *system.SSHConfig {
 		User:          from.User,
 		Host:          from.Host,
 		PrivateKey:    from.PrivateKey,
+		HostKey:       from.HostKey,
 		Port:          int(from.Port),
 		GatewayConfig: gw,
 	}
@@ -59,6 +69,19 @@ func ToPBVolume(in *api.Volume) *pb.Volume {
 		Name:  in.Name,
 		Size:  int32(in.Size),
 		Speed: pb.VolumeSpeed(in.Speed),
+		Tags:  in.Tags,
+	}
+}
+
+// ToPBSnapshot converts an api.Snapshot to a *Snapshot
+func ToPBSnapshot(in *api.Snapshot) *pb.Snapshot {
+	return &pb.Snapshot{
+		ID:        in.ID,
+		Name:      in.Name,
+		Volume:    &pb.Reference{ID: in.VolumeID},
+		Size:      int32(in.Size),
+		State:     in.State.String(),
+		CreatedAt: in.CreatedAt.Format(time.RFC3339),
 	}
 }
 
@@ -84,6 +107,7 @@ func ToPBVolumeInfo(volume *api.Volume, volumeAttch *api.VolumeAttachment) *pb.V
 			MountPath: volumeAttch.MountPoint,
 			Device:    volumeAttch.Device,
 			Format:    volumeAttch.Format,
+			Tags:      volume.Tags,
 		}
 	}
 	return &pb.VolumeInfo{
@@ -91,6 +115,7 @@ func ToPBVolumeInfo(volume *api.Volume, volumeAttch *api.VolumeAttachment) *pb.V
 		Name:  volume.Name,
 		Size:  int32(volume.Size),
 		Speed: pb.VolumeSpeed(volume.Speed),
+		Tags:  volume.Tags,
 	}
 }
 
@@ -117,12 +142,57 @@ func ToPBContainerMountPoint(in *api.ContainerInfo) *pb.ContainerMountingPoint {
 
 // ToPBNas convert a Nas from api to protocolbuffer format
 func ToPBNas(in *api.Nas) *pb.NasDefinition {
-	return &pb.NasDefinition{
-		ID:       in.ID,
-		Nas:      &pb.NasName{Name: in.Name},
-		Host:     &pb.Reference{Name: in.Host},
-		Path:     in.Path,
-		IsServer: in.IsServer,
+	out := &pb.NasDefinition{
+		ID:           in.ID,
+		Nas:          &pb.NasName{Name: in.Name},
+		Host:         &pb.Reference{Name: in.Host},
+		Path:         in.Path,
+		IsServer:     in.IsServer,
+		Tags:         in.Tags,
+		ACLs:         ToPBNasACLs(in.ACLs),
+		ReadOnly:     in.ReadOnly,
+		SecurityMode: in.SecurityMode,
+	}
+	if in.Secondary != "" {
+		out.Secondary = &pb.Reference{Name: in.Secondary}
+	}
+	if in.VIP != nil {
+		out.VirtualIP = in.VIP.PrivateIP
+	}
+	return out
+}
+
+// ToPBNasACLs convert the ACLs of a Nas from api to protocolbuffer format
+func ToPBNasACLs(in []api.NasACL) []*pb.NasACL {
+	var acls []*pb.NasACL
+	for _, acl := range in {
+		acls = append(acls, &pb.NasACL{
+			Host:           acl.Host,
+			SecurityModes:  acl.SecurityModes,
+			ReadOnly:       acl.ReadOnly,
+			NoRootSquash:   acl.NoRootSquash,
+			Secure:         acl.Secure,
+			Async:          acl.Async,
+			NoSubtreeCheck: acl.NoSubtreeCheck,
+			AnonUID:        int32(acl.AnonUID),
+			AnonGID:        int32(acl.AnonGID),
+		})
+	}
+	return acls
+}
+
+// ToAPINasACL convert an ACL of a Nas from protocolbuffer to api format
+func ToAPINasACL(in *pb.NasACL) api.NasACL {
+	return api.NasACL{
+		Host:           in.GetHost(),
+		SecurityModes:  in.GetSecurityModes(),
+		ReadOnly:       in.GetReadOnly(),
+		NoRootSquash:   in.GetNoRootSquash(),
+		Secure:         in.GetSecure(),
+		Async:          in.GetAsync(),
+		NoSubtreeCheck: in.GetNoSubtreeCheck(),
+		AnonUID:        int(in.GetAnonUID()),
+		AnonGID:        int(in.GetAnonGID()),
 	}
 }
 
@@ -137,8 +207,10 @@ func ToPBHost(in *api.Host) *pb.Host {
 		PRIVATE_IP: in.GetPrivateIP(),
 		Name:       in.Name,
 		PrivateKey: in.PrivateKey,
+		HostKey:    in.HostKey,
 		RAM:        in.Size.RAMSize,
 		State:      pb.HostState(in.State),
+		Tags:       in.Tags,
 	}
 }
 
@@ -170,5 +242,165 @@ func ToPBNetwork(in *api.Network) *pb.Network {
 		Name:      in.Name,
 		CIDR:      in.CIDR,
 		GatewayID: in.GatewayID,
+		Tags:      in.Tags,
+	}
+}
+
+//ToPBJob convert a job from jobs to protocolbuffer format
+func ToPBJob(in *jobs.Job) *pb.Job {
+	job := &pb.Job{
+		ID:        in.ID,
+		Operation: in.Operation,
+		Target:    in.Target,
+		State:     pb.JobState(in.State),
+		Step:      in.Step,
+		Error:     in.Error,
+		StartedAt: in.StartedAt.Unix(),
+	}
+	if !in.EndedAt.IsZero() {
+		job.EndedAt = in.EndedAt.Unix()
+	}
+	switch result := in.Result.(type) {
+	case *pb.Host:
+		job.Host = result
+	case *pb.Network:
+		job.Network = result
+	case *pb.Volume:
+		job.Volume = result
+	}
+	return job
+}
+
+//ToPBJobEvent convert a job event from jobs to protocolbuffer format
+func ToPBJobEvent(in *jobs.Event) *pb.JobEvent {
+	return &pb.JobEvent{
+		JobID: in.JobID,
+		State: pb.JobState(in.State),
+		Step:  in.Step,
+		Error: in.Error,
+		Time:  in.Time.Unix(),
+	}
+}
+
+//ToPBMetadataLock convert a metadata lease to protocolbuffer format
+func ToPBMetadataLock(in *metadata.Lease) *pb.MetadataLock {
+	return &pb.MetadataLock{
+		Key:        in.Key,
+		Owner:      in.Owner,
+		AcquiredAt: in.AcquiredAt.Unix(),
+		ExpiresAt:  in.ExpiresAt.Unix(),
+		Expired:    in.Expired(),
+	}
+}
+
+//ToPBMetadataMigration convert a metadata migration to protocolbuffer format
+func ToPBMetadataMigration(in *metadata.Migration) *pb.MetadataMigration {
+	return &pb.MetadataMigration{
+		Name: in.Name,
+		Kind: in.Kind,
+		From: int32(in.From),
+		To:   int32(in.To),
+	}
+}
+
+//ToPBDoctorFinding convert a metadata inconsistency to protocolbuffer format
+func ToPBDoctorFinding(in *providermetadata.Finding) *pb.DoctorFinding {
+	return &pb.DoctorFinding{
+		ResourceType: in.ResourceType,
+		ID:           in.ID,
+		Name:         in.Name,
+		Problem:      in.Problem,
+		Details:      in.Details,
+		Fixed:        in.Fixed,
+		Error:        in.Error,
+	}
+}
+
+// ToPBFirewallRuleList converts the chains and rules of a firewall to a *FirewallRuleList
+func ToPBFirewallRuleList(in *firewall.Firewall) *pb.FirewallRuleList {
+	list := &pb.FirewallRuleList{}
+	for _, t := range in.TableNames() {
+		table := in.Tables[t]
+		for _, c := range table.ChainNames() {
+			chain := table.Chains[c]
+			list.Chains = append(list.Chains, &pb.FirewallChain{
+				Table:  table.Name,
+				Name:   chain.Name,
+				Policy: firewall.PolicyString(chain.Policy),
+			})
+			for _, rule := range chain.Rules {
+				list.Rules = append(list.Rules, &pb.FirewallRule{
+					Table:    table.Name,
+					Chain:    chain.Name,
+					Action:   firewall.ActionString(rule.Action),
+					Protocol: rule.Protocol,
+					From:     rule.From,
+					FromPort: rule.FromPort,
+					To:       rule.To,
+					ToPort:   rule.ToPort,
+					Spec:     rule.Spec,
+				})
+			}
+		}
+	}
+	return list
+}
+
+// ToFirewallRule converts a *FirewallRule to a firewall.Rule
+func ToFirewallRule(in *pb.FirewallRule) (firewall.Rule, error) {
+	if in.GetSpec() != "" {
+		return firewall.ParseRule(in.GetSpec())
+	}
+	action, err := firewall.ParseAction(in.GetAction())
+	if err != nil {
+		return firewall.Rule{}, err
+	}
+	return firewall.Rule{
+		Action:   action,
+		Protocol: in.GetProtocol(),
+		From:     in.GetFrom(),
+		FromPort: in.GetFromPort(),
+		To:       in.GetTo(),
+		ToPort:   in.GetToPort(),
+	}, nil
+}
+
+// ToPBSecurityGroup converts an api.SecurityGroup to a *SecurityGroup
+func ToPBSecurityGroup(in *api.SecurityGroup) *pb.SecurityGroup {
+	sg := &pb.SecurityGroup{
+		ID:          in.ID,
+		Name:        in.Name,
+		Description: in.Description,
+	}
+	for _, r := range in.Rules {
+		rule := r
+		sg.Rules = append(sg.Rules, ToPBSecurityGroupRule(&rule))
+	}
+	return sg
+}
+
+// ToPBSecurityGroupRule converts an api.SecurityGroupRule to a *SecurityGroupRule
+func ToPBSecurityGroupRule(in *api.SecurityGroupRule) *pb.SecurityGroupRule {
+	return &pb.SecurityGroupRule{
+		ID:        in.ID,
+		Direction: pb.RuleDirection(in.Direction),
+		IPVersion: int32(in.IPVersion),
+		Protocol:  in.Protocol,
+		PortFrom:  int32(in.PortFrom),
+		PortTo:    int32(in.PortTo),
+		CIDR:      in.CIDR,
+	}
+}
+
+// ToAPISecurityGroupRule converts a *SecurityGroupRule to an api.SecurityGroupRule
+func ToAPISecurityGroupRule(in *pb.SecurityGroupRule) api.SecurityGroupRule {
+	return api.SecurityGroupRule{
+		ID:        in.GetID(),
+		Direction: RuleDirection.Enum(in.GetDirection()),
+		IPVersion: IPVersion.Enum(in.GetIPVersion()),
+		Protocol:  in.GetProtocol(),
+		PortFrom:  int(in.GetPortFrom()),
+		PortTo:    int(in.GetPortTo()),
+		CIDR:      in.GetCIDR(),
 	}
 }
@@ -19,7 +19,11 @@ package utils
 import (
 	"context"
 	"log"
+	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	pb "github.com/CS-SI/SafeScale/broker"
@@ -34,6 +38,15 @@ const (
 	TimeoutCtxHost = 5 * time.Minute
 )
 
+var (
+	// interruptibles contains the cancel functions of the contexts to cancel when the process is interrupted
+	interruptibles     = map[int]context.CancelFunc{}
+	interruptiblesNext int
+	interruptiblesLock sync.Mutex
+	// interruptOnce ensures the signal handler is registered only once
+	interruptOnce sync.Once
+)
+
 // GetConnection returns a connection to GRPC server
 func GetConnection() *grpc.ClientConn {
 	// Set up a connection to the server.
@@ -45,9 +58,56 @@ func GetConnection() *grpc.ClientConn {
 }
 
 // GetContext return a context for grpc commands
+// The context is canceled if the process receives SIGINT or SIGTERM, so brokerd can roll back the running command
 func GetContext(timeout time.Duration) (context.Context, context.CancelFunc) {
 	// Contact the server and print out its response.
-	return context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	return ctx, cancelOnInterrupt(cancel)
+}
+
+// GetInterruptibleContext returns a context for grpc commands running until the process receives SIGINT or SIGTERM
+func GetInterruptibleContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	return ctx, cancelOnInterrupt(cancel)
+}
+
+// cancelOnInterrupt registers cancel to be called when the process receives SIGINT or SIGTERM.
+// The function returned cancels the context and unregisters it; it must be called once the command is over
+func cancelOnInterrupt(cancel context.CancelFunc) context.CancelFunc {
+	interruptOnce.Do(func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		go handleInterrupts(sigs)
+	})
+
+	interruptiblesLock.Lock()
+	id := interruptiblesNext
+	interruptiblesNext++
+	interruptibles[id] = cancel
+	interruptiblesLock.Unlock()
+
+	return func() {
+		interruptiblesLock.Lock()
+		delete(interruptibles, id)
+		interruptiblesLock.Unlock()
+		cancel()
+	}
+}
+
+// handleInterrupts cancels the running commands when a signal is received, so brokerd can roll them back;
+// if no command is running, the process exits as it would without handler
+func handleInterrupts(sigs chan os.Signal) {
+	for range sigs {
+		interruptiblesLock.Lock()
+		if len(interruptibles) == 0 {
+			os.Exit(130)
+		}
+		log.Println("Interrupted, canceling command...")
+		for _, cancel := range interruptibles {
+			cancel()
+		}
+		interruptiblesLock.Unlock()
+	}
 }
 
 // GetReference return a reference from the name or id given in the pb.Reference
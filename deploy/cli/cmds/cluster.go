@@ -55,8 +55,9 @@ var ClusterCommand = &cli.Command{
 	Aliases: []string{"datacenter", "dc"},
 
 	Commands: []*cli.Command{
-		clusterListCommand,
+		// 'feature' first, to not take 'cluster <clustername> feature list' for 'cluster list'
 		clusterFeatureCommand,
+		clusterListCommand,
 		clusterNodeCommand,
 		clusterCreateCommand,
 		clusterInspectCommand,
@@ -69,7 +70,7 @@ var ClusterCommand = &cli.Command{
 	},
 
 	Before: func(c *cli.Command) {
-		if !c.IsKeywordSet("list,ls") {
+		if !c.IsKeywordSet("list,ls") || c.IsKeywordSet("feature") {
 			clusterName = c.StringArgument("<clustername>", "")
 			if clusterName == "" {
 				fmt.Println("Invalid argument <clustername>")
@@ -825,9 +826,15 @@ var clusterFeatureCommand = &cli.Command{
 		clusterFeatureCheckCommand,
 		clusterFeatureAddCommand,
 		clusterFeatureDeleteCommand,
+		clusterFeatureUpgradeCommand,
+		clusterFeatureRollbackCommand,
+		clusterFeatureListCommand,
 	},
 
 	Before: func(c *cli.Command) {
+		if c.IsKeywordSet("list,ls") {
+			return
+		}
 		featureName = c.StringArgument("<pkgname>", "")
 		if featureName == "" {
 			fmt.Fprintln(os.Stderr, "Invalid argument <pkgname>")
@@ -838,11 +845,15 @@ var clusterFeatureCommand = &cli.Command{
 
 	Help: &cli.HelpContent{
 		Usage: `
-Usage: {{.ProgName}} [options] cluster <host name or id> feature,package,pkg <pkgname> COMMAND`,
+Usage: {{.ProgName}} [options] cluster <host name or id> feature,package,pkg <pkgname> COMMAND
+       {{.ProgName}} [options] cluster <host name or id> feature,package,pkg list,ls [--refresh]`,
 		Commands: `
   add,install                         Installs the package on the host
   check                               Tells if the package is installed
-  delete,destroy,remove,rm,uninstall  Uninstall the package of the host`,
+  delete,destroy,remove,rm,uninstall  Uninstall the package of the host
+  upgrade                             Upgrades the package installed on the cluster
+  rollback                            Rolls back the package installed on the cluster to a previous revision
+  list,ls                             Lists the packages installed on the cluster`,
 		Description: `
 Manages features (SafeScale packages) on a cluster.`,
 	},
@@ -978,6 +989,7 @@ var clusterFeatureDeleteCommand = &cli.Command{
 		// TODO: Reverse proxy rules are not yet purged when feature is removed, but current code
 		// will try to apply them... Quick fix: Setting SkipProxy to true prevent this
 		settings.SkipProxy = true
+		settings.SkipDependents = c.Flag("-f,--force", false)
 
 		target := install.NewClusterTarget(clusterInstance)
 		results, err := feature.Remove(target, values, settings)
@@ -999,3 +1011,129 @@ var clusterFeatureDeleteCommand = &cli.Command{
 
 	Help: &cli.HelpContent{},
 }
+
+// clusterFeatureUpgradeCommand handles 'deploy cluster <cluster name> package <pkgname> upgrade'
+var clusterFeatureUpgradeCommand = &cli.Command{
+	Keyword: "upgrade",
+
+	Process: func(c *cli.Command) {
+		feature, err := install.NewFeature(featureName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(int(ExitCode.Run))
+		}
+		if feature == nil {
+			fmt.Fprintf(os.Stderr, "Failed to find a feature named '%s'.\n", featureName)
+			os.Exit(int(ExitCode.NotFound))
+		}
+
+		values := install.Variables{}
+		anon := c.Option("--param", "<param>")
+		if anon != nil {
+			params := anon.([]string)
+			for _, k := range params {
+				res := strings.Split(k, "=")
+				if len(res[0]) > 0 {
+					values[res[0]] = strings.Join(res[1:], "=")
+				}
+			}
+		}
+
+		settings := install.Settings{}
+
+		target := install.NewClusterTarget(clusterInstance)
+		results, err := feature.Upgrade(target, values, settings)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error upgrading feature '%s' on cluster '%s': %s\n", featureName, clusterName, err.Error())
+			os.Exit(int(ExitCode.RPC))
+		}
+		if results.Successful() {
+			fmt.Printf("Feature '%s' upgraded successfully on cluster '%s'\n", featureName, clusterName)
+			os.Exit(int(ExitCode.OK))
+		}
+
+		fmt.Printf("Failed to upgrade feature '%s' on cluster '%s'\n", featureName, clusterName)
+		fmt.Println(results.AllErrorMessages())
+		os.Exit(int(ExitCode.Run))
+	},
+
+	Help: &cli.HelpContent{},
+}
+
+// clusterFeatureRollbackCommand handles 'deploy cluster <cluster name> package <pkgname> rollback'
+var clusterFeatureRollbackCommand = &cli.Command{
+	Keyword: "rollback",
+
+	Process: func(c *cli.Command) {
+		feature, err := install.NewFeature(featureName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(int(ExitCode.Run))
+		}
+		if feature == nil {
+			fmt.Fprintf(os.Stderr, "Failed to find a feature named '%s'.\n", featureName)
+			os.Exit(int(ExitCode.NotFound))
+		}
+
+		values := install.Variables{}
+		anon := c.Option("--param", "<param>")
+		if anon != nil {
+			params := anon.([]string)
+			for _, k := range params {
+				res := strings.Split(k, "=")
+				if len(res[0]) > 0 {
+					values[res[0]] = strings.Join(res[1:], "=")
+				}
+			}
+		}
+		revision := c.StringOption("--revision", "<revision>", "")
+		if revision != "" {
+			values["Revision"] = revision
+		}
+
+		settings := install.Settings{}
+
+		target := install.NewClusterTarget(clusterInstance)
+		results, err := feature.Rollback(target, values, settings)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error rolling back feature '%s' on cluster '%s': %s\n", featureName, clusterName, err.Error())
+			os.Exit(int(ExitCode.RPC))
+		}
+		if results.Successful() {
+			fmt.Printf("Feature '%s' rolled back successfully on cluster '%s'\n", featureName, clusterName)
+			os.Exit(int(ExitCode.OK))
+		}
+
+		fmt.Printf("Failed to roll back feature '%s' on cluster '%s'\n", featureName, clusterName)
+		fmt.Println(results.AllErrorMessages())
+		os.Exit(int(ExitCode.Run))
+	},
+
+	Help: &cli.HelpContent{},
+}
+
+// clusterFeatureListCommand handles 'deploy cluster <cluster name> feature list'
+var clusterFeatureListCommand = &cli.Command{
+	Keyword: "list",
+	Aliases: []string{"ls"},
+
+	Process: func(c *cli.Command) {
+		refresh := c.Flag("--refresh", false)
+
+		target := install.NewClusterTarget(clusterInstance)
+		list, err := install.ListInstalled(target, refresh)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing features installed on cluster '%s': %s\n", clusterName, err.Error())
+			os.Exit(int(ExitCode.RPC))
+		}
+		jsoned, err := json.Marshal(list)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(int(ExitCode.Run))
+		}
+		fmt.Println(string(jsoned))
+		os.Exit(int(ExitCode.OK))
+	},
+
+	Help: &cli.HelpContent{},
+}
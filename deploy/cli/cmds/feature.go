@@ -0,0 +1,249 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmds
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	cli "github.com/CS-SI/SafeScale/utils/cli"
+	"github.com/CS-SI/SafeScale/utils/cli/ExitCode"
+
+	"github.com/CS-SI/SafeScale/deploy/install"
+)
+
+// FeatureCommand handles 'deploy feature'
+var FeatureCommand = &cli.Command{
+	Keyword: "feature",
+
+	Commands: []*cli.Command{
+		featureGraphCommand,
+		featureSearchCommand,
+		featureRepoCommand,
+	},
+
+	Help: &cli.HelpContent{
+		Usage: `
+Usage: {{.ProgName}} [options] feature COMMAND`,
+		Commands: `
+  graph   Displays the dependency graph of a feature
+  search  Searches the features available
+  repo    Manages the repositories of features`,
+		Description: `
+Inspects features (SafeScale packages), embedded, in specification files or in feature repositories.`,
+	},
+}
+
+// featureGraphCommand handles 'deploy feature graph <pkgname>'
+var featureGraphCommand = &cli.Command{
+	Keyword: "graph",
+
+	Process: func(c *cli.Command) {
+		name := c.StringArgument("<pkgname>", "")
+		if name == "" {
+			fmt.Fprintln(os.Stderr, "Invalid argument <pkgname>")
+			os.Exit(int(ExitCode.InvalidArgument))
+		}
+
+		graph, err := install.NewGraph()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to build dependency graph of features: %s\n", err.Error())
+			os.Exit(int(ExitCode.Run))
+		}
+		output, err := graph.Display(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(int(ExitCode.Run))
+		}
+		fmt.Print(output)
+		os.Exit(int(ExitCode.OK))
+	},
+
+	Help: &cli.HelpContent{
+		Usage: `
+Usage: {{.ProgName}} [options] feature graph <pkgname>`,
+		Description: `
+Displays the features required by the feature, as a tree, and the features requiring it.`,
+	},
+}
+
+// featureSearchCommand handles 'deploy feature search [<pattern>]'
+var featureSearchCommand = &cli.Command{
+	Keyword: "search",
+
+	Process: func(c *cli.Command) {
+		list, err := install.SearchFeatures(c.StringArgument("<pattern>", ""))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to search features: %s\n", err.Error())
+			os.Exit(int(ExitCode.Run))
+		}
+		type result struct {
+			Name        string `json:"name"`
+			DisplayName string `json:"display_name"`
+			Origin      string `json:"origin"`
+		}
+		results := []result{}
+		for _, f := range list {
+			results = append(results, result{
+				Name:        f.BaseFilename(),
+				DisplayName: f.DisplayName(),
+				Origin:      f.Origin(),
+			})
+		}
+		jsoned, err := json.Marshal(results)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(int(ExitCode.Run))
+		}
+		fmt.Println(string(jsoned))
+		os.Exit(int(ExitCode.OK))
+	},
+
+	Help: &cli.HelpContent{
+		Usage: `
+Usage: {{.ProgName}} [options] feature search [<pattern>]`,
+		Description: `
+Lists the features available whose name contains <pattern> (all of them if <pattern> is omitted),
+with where their specification comes from.`,
+	},
+}
+
+// featureRepoCommand handles 'deploy feature repo'
+var featureRepoCommand = &cli.Command{
+	Keyword: "repo",
+
+	Commands: []*cli.Command{
+		featureRepoAddCommand,
+		featureRepoListCommand,
+		featureRepoUpdateCommand,
+	},
+
+	Help: &cli.HelpContent{
+		Usage: `
+Usage: {{.ProgName}} [options] feature repo COMMAND`,
+		Commands: `
+  add      Adds a repository of features
+  list,ls  Lists the repositories of features
+  update   Updates the local cache of repositories of features`,
+		Description: `
+Manages the repositories of features, searched after the local folders and before the embedded features.
+A repository is either a git repository, or an index file in JSON served over HTTP listing the
+specification files with their SHA256 checksums.`,
+	},
+}
+
+// featureRepoAddCommand handles 'deploy feature repo add <repo name> <url>'
+var featureRepoAddCommand = &cli.Command{
+	Keyword: "add",
+
+	Process: func(c *cli.Command) {
+		name := c.StringArgument("<repo name>", "")
+		if name == "" {
+			fmt.Fprintln(os.Stderr, "Invalid argument <repo name>")
+			os.Exit(int(ExitCode.InvalidArgument))
+		}
+		url := c.StringArgument("<url>", "")
+		if url == "" {
+			fmt.Fprintln(os.Stderr, "Invalid argument <url>")
+			os.Exit(int(ExitCode.InvalidArgument))
+		}
+
+		repo, err := install.AddRepository(name, url, c.StringOption("--revision", "<revision>", ""), c.StringOption("--path", "<path>", ""))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to add feature repository '%s': %s\n", name, err.Error())
+			os.Exit(int(ExitCode.Run))
+		}
+		jsoned, err := json.Marshal(repo)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(int(ExitCode.Run))
+		}
+		fmt.Println(string(jsoned))
+		os.Exit(int(ExitCode.OK))
+	},
+
+	Help: &cli.HelpContent{
+		Usage: `
+Usage: {{.ProgName}} [options] feature repo add <repo name> <url> [--revision <revision>][--path <path>]`,
+		Description: `
+Adds a repository of features and fills its local cache.
+<url> is the URL of a git repository, or of the index file (ending with .json) of an HTTP repository.
+--revision pins the git commit, tag or branch, or the revision of the HTTP index, to use.
+--path designates the folder of the git repository containing the specification files.`,
+	},
+}
+
+// featureRepoListCommand handles 'deploy feature repo list'
+var featureRepoListCommand = &cli.Command{
+	Keyword: "list",
+	Aliases: []string{"ls"},
+
+	Process: func(c *cli.Command) {
+		list, err := install.ListRepositories()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(int(ExitCode.Run))
+		}
+		jsoned, err := json.Marshal(list)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(int(ExitCode.Run))
+		}
+		fmt.Println(string(jsoned))
+		os.Exit(int(ExitCode.OK))
+	},
+
+	Help: &cli.HelpContent{
+		Usage: `
+Usage: {{.ProgName}} [options] feature repo (list|ls)`,
+		Description: `
+Lists the repositories of features, in the order they are searched.`,
+	},
+}
+
+// featureRepoUpdateCommand handles 'deploy feature repo update [<repo name>]'
+var featureRepoUpdateCommand = &cli.Command{
+	Keyword: "update",
+
+	Process: func(c *cli.Command) {
+		names := []string{}
+		if name := c.StringArgument("<repo name>", ""); name != "" {
+			names = append(names, name)
+		}
+		list, err := install.UpdateRepositories(names...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(int(ExitCode.Run))
+		}
+		jsoned, err := json.Marshal(list)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(int(ExitCode.Run))
+		}
+		fmt.Println(string(jsoned))
+		os.Exit(int(ExitCode.OK))
+	},
+
+	Help: &cli.HelpContent{
+		Usage: `
+Usage: {{.ProgName}} [options] feature repo update [<repo name>]`,
+		Description: `
+Updates the local cache of the repository of features, or of all of them if <repo name> is omitted.
+Checksums are verified before the cache is replaced.`,
+	},
+}
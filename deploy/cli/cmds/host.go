@@ -17,6 +17,7 @@
 package cmds
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
@@ -47,7 +48,7 @@ var HostCommand = &cli.Command{
 	},
 
 	Before: func(c *cli.Command) {
-		if !c.IsKeywordSet("list,ls") {
+		if !c.IsKeywordSet("list,ls") || c.IsKeywordSet("feature") {
 			hostName = c.StringArgument("<host name or id>", "")
 			if hostName == "" {
 				fmt.Fprintln(os.Stderr, "Invalid argument <host name or id>")
@@ -86,9 +87,13 @@ var hostFeatureCommand = &cli.Command{
 		hostFeatureCheckCommand,
 		hostFeatureAddCommand,
 		hostFeatureDeleteCommand,
+		hostFeatureListCommand,
 	},
 
 	Before: func(c *cli.Command) {
+		if c.IsKeywordSet("list,ls") {
+			return
+		}
 		featureName = c.StringArgument("<pkgname>", "")
 		if featureName == "" {
 			fmt.Fprintln(os.Stderr, "Invalid argument <pkgname>")
@@ -99,11 +104,13 @@ var hostFeatureCommand = &cli.Command{
 
 	Help: &cli.HelpContent{
 		Usage: `
-Usage: {{.ProgName}} [options] host <host name or id> feature,package,pkg <pkgname> COMMAND`,
+Usage: {{.ProgName}} [options] host <host name or id> feature,package,pkg <pkgname> COMMAND
+       {{.ProgName}} [options] host <host name or id> feature,package,pkg list,ls [--refresh]`,
 		Commands: `
   add,install                         Installs the package on the host
   check                               Tells if the package is installed
-  delete,destroy,remove,rm,uninstall  Uninstall the package of the host`,
+  delete,destroy,remove,rm,uninstall  Uninstall the package of the host
+  list,ls                             Lists the packages installed on the host`,
 		Description: `
 Manages features (SafeScale packages) on a single host.`,
 	},
@@ -256,8 +263,11 @@ var hostFeatureDeleteCommand = &cli.Command{
 			os.Exit(int(ExitCode.RPC))
 		}
 
+		settings := install.Settings{}
+		settings.SkipDependents = c.Flag("-f,--force", false)
+
 		target := install.NewHostTarget(hostInstance)
-		results, err := feature.Remove(target, values, install.Settings{})
+		results, err := feature.Remove(target, values, settings)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error uninstalling feature '%s' on '%s': %s\n", featureName, hostName, err.Error())
 			os.Exit(int(ExitCode.RPC))
@@ -420,3 +430,29 @@ var hostDockerCommand = &cli.Command{
 
 	Help: &cli.HelpContent{},
 }
+
+// hostFeatureListCommand handles 'deploy host <host name or id> feature list'
+var hostFeatureListCommand = &cli.Command{
+	Keyword: "list",
+	Aliases: []string{"ls"},
+
+	Process: func(c *cli.Command) {
+		refresh := c.Flag("--refresh", false)
+
+		target := install.NewHostTarget(hostInstance)
+		list, err := install.ListInstalled(target, refresh)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing features installed on host '%s': %s\n", hostName, err.Error())
+			os.Exit(int(ExitCode.RPC))
+		}
+		jsoned, err := json.Marshal(list)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(int(ExitCode.Run))
+		}
+		fmt.Println(string(jsoned))
+		os.Exit(int(ExitCode.OK))
+	},
+
+	Help: &cli.HelpContent{},
+}
@@ -17,14 +17,17 @@
 package main
 
 import (
+	"os"
 	"time"
 
 	"github.com/CS-SI/SafeScale/deploy/cli/cmds"
+	"github.com/CS-SI/SafeScale/deploy/install"
 
 	"github.com/CS-SI/SafeScale/utils/cli"
 
 	_ "github.com/CS-SI/SafeScale/providers/cloudwatt"      // Imported to initialise provider cloudwatt
 	_ "github.com/CS-SI/SafeScale/providers/flexibleengine" // Imported to initialise provider flexibleengine
+	_ "github.com/CS-SI/SafeScale/providers/local"          // Imported to initialise provider local
 	_ "github.com/CS-SI/SafeScale/providers/opentelekom"    // Imported to initialise provider opentelekom
 	_ "github.com/CS-SI/SafeScale/providers/ovh"            // Imported to initialise provider ovh
 )
@@ -42,7 +45,7 @@ Global options:
 
 	completeUsage string = `
 Usage: deploy version
-       deploy [-vd] help (cluster|host)
+       deploy [-vd] help (cluster|host|feature)
        deploy [-vd] (cluster|datacenter|dc|host) help <command>
        deploy [-vd] (cluster|datacenter|dc|host) (list|ls)
        deploy [-vd] (cluster|datacenter|dc) help <command>
@@ -54,6 +57,9 @@ Usage: deploy version
        deploy [-vd] (cluster|datacenter|dc) <clustername> feature <pkgname> (add|install) [-f][--skip-proxy][--no-master][--no-node][(--param <param>)...]
        deploy [-vd] (cluster|datacenter|dc) <clustername> feature <pkgname> check [(--param <param>)...]
        deploy [-vd] (cluster|datacenter|dc) <clustername> feature <pkgname> (delete|destroy|remove|rm|uninstall) [-f][(--param <param>)...]
+       deploy [-vd] (cluster|datacenter|dc) <clustername> feature (list|ls) [--refresh]
+       deploy [-vd] (cluster|datacenter|dc) <clustername> feature <pkgname> upgrade [(--param <param>)...]
+       deploy [-vd] (cluster|datacenter|dc) <clustername> feature <pkgname> rollback [--revision <revision>][(--param <param>)...]
        deploy [-vd] (cluster|datacenter|dc) <clustername> (service|svc) <pkgname> (check|start|state|stop|pause|resume)
        deploy [-vd] (cluster|datacenter|dc) <clustername> (dcos|marathon|kubectl) [-- <arg>...]
        deploy [-vd] (cluster|datacenter|dc) <clustername> nas <nasname> create [-u <storage unit size>][-n <count>][--host <nas host>]
@@ -65,9 +71,15 @@ Usage: deploy version
        deploy [-vd] (cluster|datacenter|dc) <clustername> nas <nasname> share <sharename> (umount|unmount)
        deploy [-vd] host help <command>
        deploy [-vd] host <host name or id> feature <pkgname> (add|install) [(--param <param>)...]
+       deploy [-vd] host <host name or id> feature (list|ls) [--refresh]
        deploy [-vd] host <host name or id> feature <pkgname> check
-       deploy [-vd] host <host name or id> feature <pkgname> (delete|destroy|remove|rm|uninstall)
+       deploy [-vd] host <host name or id> feature <pkgname> (delete|destroy|remove|rm|uninstall) [-f]
        deploy [-vd] host <host name or id> (service|svc) <pkgname> (check|start|state|stop|pause|resume)
+       deploy [-vd] feature graph <pkgname>
+       deploy [-vd] feature search [<pattern>]
+       deploy [-vd] feature repo add <repo name> <url> [--revision <revision>][--path <path>]
+       deploy [-vd] feature repo (list|ls)
+       deploy [-vd] feature repo update [<repo name>]
 
 Options:
   -C <complexity>,--complexity <complexity>               Defines complexity
@@ -86,7 +98,10 @@ Options:
   --cpu <cpu>                                             Defines number of CPU of host
   --disk <disk>                                           Defines system disk size
   --os <os>                                               Defines Linux Operating System
+  --path <path>                                           Defines the folder of a git feature repository containing the features
   --ram <ram>                                             Defines ram size
+  --refresh                                               Checks again the features recorded as installed
+  --revision <revision>                                   Defines the revision to roll back to (previous one by default), or to pin for a feature repository
   --skip-proxy                                            Disables reverse proxy configuration
   --no-check                                              Disables feature check before add or remove
   --no-master                                             Disables feature installation on master(s)
@@ -101,10 +116,14 @@ func main() {
 		Commands: []*cli.Command{
 			cmds.ClusterCommand,
 			cmds.HostCommand,
+			cmds.FeatureCommand,
 		},
 
 		Before: func(c *cli.Command) {
 			cmds.Verbose = c.Flag("-v,--verbose", false)
+			if cmds.Verbose {
+				install.ScriptOutput = os.Stdout
+			}
 			cmds.Debug = c.Flag("-d,--debug", false)
 		},
 
@@ -115,7 +134,8 @@ Usage: {{.ProgName}} [options] <command>
             `,
 			Commands: `
   host     Deploy on host
-  cluster  Deploy on cluster`,
+  cluster  Deploy on cluster
+  feature  Inspects features and manages feature repositories`,
 			Options: []string{
 				globalOptions,
 			},
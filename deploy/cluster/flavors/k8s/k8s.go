@@ -1347,9 +1347,15 @@ func (c *Cluster) updateMetadata(updatefn func() error) error {
 		}
 		m.Carry(c.Core)
 		c.metadata = m
-		c.metadata.Acquire()
+		err = c.metadata.Acquire()
+		if err != nil {
+			return err
+		}
 	} else {
-		c.metadata.Acquire()
+		err := c.metadata.Acquire()
+		if err != nil {
+			return err
+		}
 		c.Reload()
 	}
 	if updatefn != nil {
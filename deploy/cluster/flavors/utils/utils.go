@@ -67,7 +67,7 @@ func ExecuteScript(
 	} else {
 		cmd = fmt.Sprintf("sudo bash %s; rc=$?; rm %s; exit $rc", path, path)
 	}
-	return brokerclient.New().Ssh.Run(hostID, cmd, brokerclient.DefaultConnectionTimeout, time.Duration(20)*time.Minute)
+	return install.ExecuteScriptOnHost(hostID, cmd, time.Duration(20)*time.Minute)
 }
 
 // UploadTemplateToFile uploads a template named 'tmplName' coming from rice 'box' in a file to a remote host
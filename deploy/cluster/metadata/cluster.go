@@ -152,8 +152,8 @@ func (m *Cluster) Browse(callback func(*Cluster) error) error {
 }
 
 // Acquire waits until the write lock is available, then locks the metadata
-func (m *Cluster) Acquire() {
-	m.item.Acquire()
+func (m *Cluster) Acquire() error {
+	return m.item.Acquire()
 }
 
 // Release unlocks the metadata
@@ -32,6 +32,10 @@ const (
 	Add
 	// Remove ...
 	Remove
+	// Upgrade ...
+	Upgrade
+	// Rollback ...
+	Rollback
 
 	// NextEnum marks the next value (or the max, depending the use)
 	NextEnum
@@ -39,15 +43,19 @@ const (
 
 var (
 	stringMap = map[string]Enum{
-		"check":  Check,
-		"add":    Add,
-		"remove": Remove,
+		"check":    Check,
+		"add":      Add,
+		"remove":   Remove,
+		"upgrade":  Upgrade,
+		"rollback": Rollback,
 	}
 
 	enumMap = map[Enum]string{
-		Check:  "Check",
-		Add:    "Add",
-		Remove: "Remove",
+		Check:    "Check",
+		Add:      "Add",
+		Remove:   "Remove",
+		Upgrade:  "Upgrade",
+		Rollback: "Rollback",
 	}
 )
 
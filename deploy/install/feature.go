@@ -19,6 +19,8 @@ package install
 import (
 	"fmt"
 	"log"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/CS-SI/SafeScale/deploy/install/enums/Method"
@@ -47,6 +49,8 @@ type Settings struct {
 	SkipFeatureRequirements bool
 	// SkipSizingRequirements tells not to check sizing requirements
 	SkipSizingRequirements bool
+	// SkipDependents tells not to check if installed features depend on the feature to remove
+	SkipDependents bool
 }
 
 // Feature contains the information about an installable feature
@@ -57,7 +61,7 @@ type Feature struct {
 	fileName string
 	// embedded tells if the feature is embedded in deploy
 	embedded bool
-	// Installers defines the installers available for the feature
+	// Installers defines the installers of the feature replacing the default ones of their methods
 	installers map[Method.Enum]Installer
 	// Dependencies lists other feature(s) (by name) needed by this one
 	//dependencies []string
@@ -76,10 +80,9 @@ func NewFeature(name string) (*Feature, error) {
 	}
 
 	v := viper.New()
-	v.AddConfigPath(".")
-	v.AddConfigPath("$HOME/.safescale/features")
-	v.AddConfigPath("$HOME/.config/safescale/features")
-	v.AddConfigPath("/etc/safescale/features")
+	for _, path := range searchPaths() {
+		v.AddConfigPath(path)
+	}
 	v.SetConfigName(name)
 
 	var feature *Feature
@@ -111,8 +114,12 @@ func NewFeature(name string) (*Feature, error) {
 	return feature, err
 }
 
-// installerOfMethod instanciates the right installer corresponding to the method
+// installerOfMethod returns the installer of the feature corresponding to the method, instanciating the
+// right one if none is defined by the feature
 func (f *Feature) installerOfMethod(method Method.Enum) Installer {
+	if installer, ok := f.installers[method]; ok {
+		return installer
+	}
 	var installer Installer
 	switch method {
 	case Method.Bash:
@@ -125,10 +132,10 @@ func (f *Feature) installerOfMethod(method Method.Enum) Installer {
 		installer = NewDnfInstaller()
 	case Method.DCOS:
 		installer = NewDcosInstaller()
-		//	case Method.Ansible:
-		//		installer = NewAnsibleInstaller()
-		//	case Method.Helm:
-		//		installer = NewHelmInstaller()
+	case Method.Ansible:
+		installer = NewAnsibleInstaller()
+	case Method.Helm:
+		installer = NewHelmInstaller()
 	}
 	return installer
 }
@@ -153,6 +160,22 @@ func (f *Feature) DisplayFilename() string {
 	return filename
 }
 
+// Origin returns where the specification of the feature comes from: "embedded", "repository <name>"
+// if it's in the cache of a feature repository, or the path of the specification file
+func (f *Feature) Origin() string {
+	if f.specs == nil || f.specs.ConfigFileUsed() == "" {
+		return "embedded"
+	}
+	file, err := filepath.Abs(f.specs.ConfigFileUsed())
+	if err != nil {
+		return f.specs.ConfigFileUsed()
+	}
+	if name := repositoryOf(file); name != "" {
+		return "repository " + name
+	}
+	return file
+}
+
 // Specs returns the data from the spec file
 func (f *Feature) Specs() *viper.Viper {
 	return f.specs
@@ -264,6 +287,7 @@ func (f *Feature) Add(t Target, v Variables, s Settings) (Results, error) {
 	}
 	if results.Successful() {
 		log.Printf("Feature '%s' is already installed.", f.DisplayName())
+		f.recordInstalled(t, v, results)
 		return results, nil
 	}
 
@@ -276,6 +300,7 @@ func (f *Feature) Add(t Target, v Variables, s Settings) (Results, error) {
 	results, err = installer.Add(f, t, myV, s)
 	if err == nil {
 		checkCache.ForceSet(f.DisplayName()+"@"+t.Name(), results)
+		f.recordInstalled(t, v, results)
 	}
 	return results, err
 }
@@ -295,6 +320,12 @@ func (f *Feature) Remove(t Target, v Variables, s Settings) (Results, error) {
 	if installer == nil {
 		return nil, fmt.Errorf("failed to find a way to uninstall '%s'", f.DisplayName())
 	}
+	if !s.SkipDependents {
+		err := f.checkDependents(t, v, s)
+		if err != nil {
+			return nil, err
+		}
+	}
 	//if debug
 	if false {
 		log.Printf("Removing feature '%s' from %s '%s'...\n", f.DisplayName(), t.Type(), t.Name())
@@ -317,48 +348,164 @@ func (f *Feature) Remove(t Target, v Variables, s Settings) (Results, error) {
 
 	results, err := installer.Remove(f, t, myV, s)
 	checkCache.Reset(f.DisplayName() + "@" + t.Name())
+	if err == nil && results.Successful() {
+		f.forgetInstalled(t)
+	}
 	return results, err
 }
 
-// installRequirements walks through requirements and installs them if needed
-func (f *Feature) installRequirements(t Target, v Variables, s Settings) error {
-	specs := f.Specs()
-	yamlKey := "feature.requirements.features"
-	if specs.IsSet(yamlKey) {
-		// if debug {
-		//	hostInstance, clusterInstance, nodeInstance := determineContext(t)
-		//	msgHead := fmt.Sprintf("Checking requirements of feature '%s'", c.DisplayName())
-		//	var msgTail string
-		//	if hostInstance != nil {
-		//		msgTail = fmt.Sprintf("on host '%s'", hostInstance.host.Name)
-		//	}
-		//	if nodeInstance != nil {
-		//		msgTail = fmt.Sprintf("on cluster node '%s'", nodeInstance.host.Name)
-		//	}
-		//	if clusterInstance != nil {
-		//		msgTail = fmt.Sprintf("on cluster '%s'", clusterInstance.cluster.GetName())
-		//	}
-		//	log.Printf("%s %s...\n", msgHead, msgTail)
-		// }
-		for _, requirement := range specs.GetStringSlice(yamlKey) {
-			needed, err := NewFeature(requirement)
-			if err != nil {
-				return fmt.Errorf("failed to find required feature '%s': %s", requirement, err.Error())
+// Upgrade upgrades the feature on the target, if the installer of the feature allows it
+func (f *Feature) Upgrade(t Target, v Variables, s Settings) (Results, error) {
+	upgrader, err := f.upgraderOf(t)
+	if err != nil {
+		return nil, err
+	}
+
+	// 'v' may be updated by parallel tasks, so use copy of it
+	myV := make(Variables)
+	for key, value := range v {
+		myV[key] = value
+	}
+
+	// Inits implicit parameters
+	setImplicitParameters(t, myV)
+
+	// Checks required parameters have value
+	err = checkParameters(f, myV)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := upgrader.Upgrade(f, t, myV, s)
+	checkCache.Reset(f.DisplayName() + "@" + t.Name())
+	if err == nil {
+		f.recordInstalled(t, v, results)
+	}
+	return results, err
+}
+
+// Rollback rolls back the feature on the target to a previous revision, if the installer of the
+// feature allows it
+func (f *Feature) Rollback(t Target, v Variables, s Settings) (Results, error) {
+	upgrader, err := f.upgraderOf(t)
+	if err != nil {
+		return nil, err
+	}
+
+	// 'v' may be updated by parallel tasks, so use copy of it
+	myV := make(Variables)
+	for key, value := range v {
+		myV[key] = value
+	}
+
+	revision, err := revisionOf(myV)
+	if err != nil {
+		return nil, err
+	}
+	if revision > 0 {
+		myV["Revision"] = revision
+	}
+
+	// Inits implicit parameters
+	setImplicitParameters(t, myV)
+
+	results, err := upgrader.Rollback(f, t, myV, s)
+	checkCache.Reset(f.DisplayName() + "@" + t.Name())
+	return results, err
+}
+
+// revisionOf returns the revision to roll back to, in variable 'Revision', or 0 if not set
+// The revision is given by the user and used in the commands run on the target, it must be a positive number
+func revisionOf(v Variables) (int, error) {
+	anon, ok := v["Revision"]
+	if !ok {
+		return 0, nil
+	}
+	revision, err := strconv.Atoi(fmt.Sprintf("%v", anon))
+	if err != nil || revision <= 0 {
+		return 0, fmt.Errorf("invalid revision '%v': must be a positive number", anon)
+	}
+	return revision, nil
+}
+
+// installedDependents returns the display names of the dependents installed on the target, according to
+// the features recorded as installed or, for the ones not recorded, to the checks already done
+func installedDependents(dependents []*Feature, recorded []string, t Target) []string {
+	known := map[string]bool{}
+	for _, name := range recorded {
+		known[name] = true
+	}
+	installed := []string{}
+	for _, d := range dependents {
+		if known[featureKey(d)] {
+			installed = append(installed, d.DisplayName())
+			continue
+		}
+		if anon, ok := checkCache.Get(d.DisplayName() + "@" + t.Name()); ok {
+			results := anon.(Results)
+			if len(results) > 0 && results.Successful() {
+				installed = append(installed, d.DisplayName())
 			}
-			results, err := needed.Check(t, v, s)
-			if err != nil {
-				return fmt.Errorf("failed to check required feature '%s' for feature '%s': %s", requirement, f.DisplayName(), err.Error())
+		}
+	}
+	return installed
+}
+
+// upgraderOf returns the first installer of the feature useable on the target able to upgrade and rollback,
+// following the priority of the methods of the target
+func (f *Feature) upgraderOf(t Target) (Upgrader, error) {
+	methods := t.Methods()
+	var i uint8
+	for i = 1; i <= uint8(len(methods)); i++ {
+		method := methods[i]
+		if f.specs.IsSet(fmt.Sprintf("feature.install.%s", strings.ToLower(method.String()))) {
+			installer := f.installerOfMethod(method)
+			if installer == nil {
+				continue
 			}
-			if !results.Successful() {
-				results, err := needed.Add(t, v, s)
-				if err != nil {
-					return fmt.Errorf("failed to install required feature '%s': %s", requirement, err.Error())
-				}
-				if !results.Successful() {
-					return fmt.Errorf("failed to install required feature '%s':\n%s", requirement, results.AllErrorMessages())
-				}
+			if upgrader, ok := installer.(Upgrader); ok {
+				return upgrader, nil
 			}
 		}
 	}
+	return nil, fmt.Errorf("feature '%s' can't be upgraded or rolled back on %s '%s': none of its installation methods allows it", f.DisplayName(), t.Type(), t.Name())
+}
+
+// installRequirements installs the features required, following the dependency graph
+func (f *Feature) installRequirements(t Target, v Variables, s Settings) error {
+	specs := f.Specs()
+	yamlKey := "feature.requirements.features"
+	if !specs.IsSet(yamlKey) || len(specs.GetStringSlice(yamlKey)) <= 0 {
+		return nil
+	}
+
+	g, err := NewGraph()
+	if err != nil {
+		return fmt.Errorf("failed to build dependency graph of features: %s", err.Error())
+	}
+	levels, err := g.Resolve(featureKey(f))
+	if err != nil {
+		return err
+	}
+	// The feature itself is alone on the last level
+	_, err = addLevels(levels[:len(levels)-1], t.Installed(), t, v, s)
+	return err
+}
+
+// checkDependents returns an error if features requiring this one are installed on the target
+func (f *Feature) checkDependents(t Target, v Variables, s Settings) error {
+	g, err := NewGraph()
+	if err != nil {
+		return fmt.Errorf("failed to build dependency graph of features: %s", err.Error())
+	}
+	dependents, err := g.Dependents(featureKey(f))
+	if err != nil {
+		// A feature unknown of the graph can't be required by others
+		return nil
+	}
+	installed := installedDependents(dependents, t.Installed(), t)
+	if len(installed) > 0 {
+		return fmt.Errorf("feature '%s' is required by installed feature(s) %s", f.DisplayName(), strings.Join(installed, ", "))
+	}
 	return nil
 }
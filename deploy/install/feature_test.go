@@ -0,0 +1,160 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+
+	"github.com/CS-SI/SafeScale/deploy/install/enums/Method"
+)
+
+// testInstaller is an installer recording the variables it was called with
+type testInstaller struct {
+	name      string
+	installed bool
+	calls     []string
+	vars      Variables
+	err       error
+}
+
+func (i *testInstaller) call(action string, v Variables) (Results, error) {
+	i.calls = append(i.calls, action)
+	i.vars = v
+	if i.err != nil {
+		return nil, i.err
+	}
+	return Results{i.name: stepResults{"host-1": stepResult{success: true}}}, nil
+}
+
+func (i *testInstaller) Check(c *Feature, t Target, v Variables, s Settings) (Results, error) {
+	i.calls = append(i.calls, "check")
+	return Results{i.name: stepResults{"host-1": stepResult{success: i.installed}}}, nil
+}
+
+func (i *testInstaller) Add(c *Feature, t Target, v Variables, s Settings) (Results, error) {
+	return i.call("add", v)
+}
+
+func (i *testInstaller) Remove(c *Feature, t Target, v Variables, s Settings) (Results, error) {
+	return i.call("remove", v)
+}
+
+// testUpgrader is an installer able to upgrade and rollback
+type testUpgrader struct {
+	testInstaller
+}
+
+func (i *testUpgrader) Upgrade(c *Feature, t Target, v Variables, s Settings) (Results, error) {
+	return i.call("upgrade", v)
+}
+
+func (i *testUpgrader) Rollback(c *Feature, t Target, v Variables, s Settings) (Results, error) {
+	return i.call("rollback", v)
+}
+
+func testFeature(t *testing.T, name string, specs string, installers map[Method.Enum]Installer) *Feature {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	require.Nil(t, v.ReadConfig(bytes.NewBufferString(specs)))
+	return &Feature{
+		displayName: name,
+		fileName:    name,
+		specs:       v,
+		installers:  installers,
+	}
+}
+
+func testHostTarget(methods ...Method.Enum) *HostTarget {
+	target := &HostTarget{
+		host:    &pb.Host{ID: "id-1", Name: "host-1", PRIVATE_IP: "10.0.0.1"},
+		methods: map[uint8]Method.Enum{},
+	}
+	for i, m := range methods {
+		target.methods[uint8(i+1)] = m
+	}
+	return target
+}
+
+const testUpgradableSpecs = `
+feature:
+  parameters:
+    - Version
+  install:
+    dcos:
+      check: {}
+    helm:
+      check: {}
+`
+
+func TestFeature_Upgrade(t *testing.T) {
+	dcos := &testInstaller{name: "dcos"}
+	helm := &testUpgrader{testInstaller{name: "helm", err: fmt.Errorf("upgrade refused")}}
+	f := testFeature(t, "upgraded", testUpgradableSpecs, map[Method.Enum]Installer{Method.DCOS: dcos, Method.Helm: helm})
+
+	// DCOS comes first but can't upgrade, helm is used
+	_, err := f.Upgrade(testHostTarget(Method.DCOS, Method.Helm), Variables{"Version": "2.0"}, Settings{})
+	assert.EqualError(t, err, "upgrade refused")
+	assert.Empty(t, dcos.calls)
+	assert.Equal(t, []string{"upgrade"}, helm.calls)
+	assert.Equal(t, "2.0", helm.vars["Version"])
+	assert.Equal(t, "host-1", helm.vars["Hostname"])
+
+	// The required parameters are checked
+	_, err = f.Upgrade(testHostTarget(Method.Helm), Variables{}, Settings{})
+	assert.EqualError(t, err, "missing value for parameter 'Version'")
+	assert.Equal(t, []string{"upgrade"}, helm.calls)
+
+	// No method of the target can upgrade
+	_, err = f.Upgrade(testHostTarget(Method.DCOS, Method.Bash), Variables{"Version": "2.0"}, Settings{})
+	assert.NotNil(t, err)
+	assert.Empty(t, dcos.calls)
+}
+
+func TestFeature_Rollback(t *testing.T) {
+	dcos := &testInstaller{name: "dcos"}
+	helm := &testUpgrader{testInstaller{name: "helm"}}
+	f := testFeature(t, "rolledback", testUpgradableSpecs, map[Method.Enum]Installer{Method.DCOS: dcos, Method.Helm: helm})
+
+	// The parameters needed to install aren't needed to roll back
+	results, err := f.Rollback(testHostTarget(Method.DCOS, Method.Helm), Variables{"Revision": 3}, Settings{})
+	require.Nil(t, err)
+	assert.True(t, results.Successful())
+	assert.Empty(t, dcos.calls)
+	assert.Equal(t, []string{"rollback"}, helm.calls)
+	assert.Equal(t, 3, helm.vars["Revision"])
+
+	// The revision given on the command line is a string, it must be a positive number
+	_, err = f.Rollback(testHostTarget(Method.Helm), Variables{"Revision": "2"}, Settings{})
+	require.Nil(t, err)
+	assert.Equal(t, 2, helm.vars["Revision"])
+	_, err = f.Rollback(testHostTarget(Method.Helm), Variables{"Revision": "2; reboot"}, Settings{})
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"rollback", "rollback"}, helm.calls)
+
+	// A method not declared in the specification isn't used
+	_, err = f.Rollback(testHostTarget(Method.Ansible), Variables{}, Settings{})
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"rollback", "rollback"}, helm.calls)
+}
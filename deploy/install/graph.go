@@ -0,0 +1,371 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	// featureSearchPaths lists the folders where specification files of features are searched, in priority order
+	featureSearchPaths = []string{
+		".",
+		"$HOME/.safescale/features",
+		"$HOME/.config/safescale/features",
+		"/etc/safescale/features",
+	}
+)
+
+// searchPaths returns the local search paths followed by the caches of the feature repositories
+func searchPaths() []string {
+	paths := append([]string{}, featureSearchPaths...)
+	return append(paths, repositorySearchPaths()...)
+}
+
+// featureKey returns the name identifying the feature in the graph, which is the name of its specification
+// file without extension
+func featureKey(f *Feature) string {
+	return strings.TrimSuffix(f.BaseFilename(), ".yml")
+}
+
+// ListFeatures returns all the features available, embedded or in specification files on disk; a
+// specification file on disk replaces the embedded feature of the same name
+func ListFeatures() ([]*Feature, error) {
+	features := map[string]*Feature{}
+	for _, f := range allEmbedded {
+		features[featureKey(f)] = f
+	}
+
+	// Search paths are walked from the lowest priority, to keep the file NewFeature would load
+	paths := searchPaths()
+	for i := len(paths) - 1; i >= 0; i-- {
+		files, err := filepath.Glob(filepath.Join(os.ExpandEnv(paths[i]), "*.yml"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			name := strings.TrimSuffix(filepath.Base(file), ".yml")
+			// Files that aren't valid feature specifications are not part of the graph
+			f, err := NewFeature(name)
+			if err == nil && f != nil {
+				features[name] = f
+			}
+		}
+	}
+
+	list := []*Feature{}
+	for _, f := range features {
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return featureKey(list[i]) < featureKey(list[j])
+	})
+	return list, nil
+}
+
+// SearchFeatures returns the features available whose name or display name contains the pattern,
+// case insensitively
+func SearchFeatures(pattern string) ([]*Feature, error) {
+	list, err := ListFeatures()
+	if err != nil {
+		return nil, err
+	}
+	pattern = strings.ToLower(pattern)
+	found := []*Feature{}
+	for _, f := range list {
+		if strings.Contains(strings.ToLower(featureKey(f)), pattern) ||
+			strings.Contains(strings.ToLower(f.DisplayName()), pattern) {
+			found = append(found, f)
+		}
+	}
+	return found, nil
+}
+
+// Graph is the dependency graph of features, built from key 'feature.requirements.features' of their
+// specification files
+type Graph struct {
+	// features contains the features by key
+	features map[string]*Feature
+	// names maps the names a feature can be required with (key or display name, in lowercase) to its key
+	names map[string]string
+	// requires contains the names of the features required by each feature, as written in specification file
+	requires map[string][]string
+	// requiredBy contains the keys of the features requiring each feature
+	requiredBy map[string][]string
+}
+
+// NewGraph builds the dependency graph of all the features available
+func NewGraph() (*Graph, error) {
+	features, err := ListFeatures()
+	if err != nil {
+		return nil, err
+	}
+	g := Graph{
+		features:   map[string]*Feature{},
+		names:      map[string]string{},
+		requires:   map[string][]string{},
+		requiredBy: map[string][]string{},
+	}
+	for _, f := range features {
+		key := featureKey(f)
+		g.features[key] = f
+		g.names[strings.ToLower(f.DisplayName())] = key
+	}
+	// Keys have precedence over display names
+	for key := range g.features {
+		g.names[strings.ToLower(key)] = key
+	}
+	for key, f := range g.features {
+		g.requires[key] = f.Specs().GetStringSlice("feature.requirements.features")
+		for _, r := range g.requires[key] {
+			if rk, ok := g.lookup(r); ok {
+				g.requiredBy[rk] = append(g.requiredBy[rk], key)
+			}
+		}
+	}
+	for _, list := range g.requiredBy {
+		sort.Strings(list)
+	}
+	return &g, nil
+}
+
+// lookup returns the key of the feature named 'name'
+func (g *Graph) lookup(name string) (string, bool) {
+	key, ok := g.names[strings.ToLower(strings.TrimSpace(name))]
+	return key, ok
+}
+
+// Feature returns the feature named 'name'
+func (g *Graph) Feature(name string) (*Feature, error) {
+	key, ok := g.lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("failed to find a feature named '%s'", name)
+	}
+	return g.features[key], nil
+}
+
+// Requirements returns the features directly required by the feature named 'name'
+func (g *Graph) Requirements(name string) ([]*Feature, error) {
+	key, ok := g.lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("failed to find a feature named '%s'", name)
+	}
+	list := []*Feature{}
+	for _, r := range g.requires[key] {
+		rk, ok := g.lookup(r)
+		if !ok {
+			return nil, fmt.Errorf("feature '%s' requires unknown feature '%s'", key, r)
+		}
+		list = append(list, g.features[rk])
+	}
+	return list, nil
+}
+
+// Dependents returns the features requiring directly the feature named 'name'
+func (g *Graph) Dependents(name string) ([]*Feature, error) {
+	key, ok := g.lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("failed to find a feature named '%s'", name)
+	}
+	list := []*Feature{}
+	for _, d := range g.requiredBy[key] {
+		list = append(list, g.features[d])
+	}
+	return list, nil
+}
+
+// Resolve returns the features named and all their requirements, grouped in levels to apply in order:
+// a feature only depends on features of previous levels, so the features of a same level can be
+// installed in parallel.
+// Returns an error if a requirement is unknown or if requirements are cyclic
+func (g *Graph) Resolve(names ...string) ([][]*Feature, error) {
+	levels := map[string]int{}
+	for _, name := range names {
+		key, ok := g.lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("failed to find a feature named '%s'", name)
+		}
+		_, err := g.level(key, levels, []string{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	max := -1
+	for _, l := range levels {
+		if l > max {
+			max = l
+		}
+	}
+	result := make([][]*Feature, max+1)
+	for key, l := range levels {
+		result[l] = append(result[l], g.features[key])
+	}
+	for _, level := range result {
+		list := level
+		sort.Slice(list, func(i, j int) bool {
+			return featureKey(list[i]) < featureKey(list[j])
+		})
+	}
+	return result, nil
+}
+
+// level computes the level of the feature: 0 without requirements, 1 more than the highest level of
+// its requirements otherwise. 'path' contains the features being resolved, to detect cycles
+func (g *Graph) level(key string, levels map[string]int, path []string) (int, error) {
+	for i, p := range path {
+		if p == key {
+			cycle := append([]string{}, path[i:]...)
+			cycle = append(cycle, key)
+			return 0, fmt.Errorf("dependency cycle between features: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	if l, ok := levels[key]; ok {
+		return l, nil
+	}
+
+	path = append(path, key)
+	l := 0
+	for _, r := range g.requires[key] {
+		rk, ok := g.lookup(r)
+		if !ok {
+			return 0, fmt.Errorf("feature '%s' requires unknown feature '%s'", key, r)
+		}
+		rl, err := g.level(rk, levels, path)
+		if err != nil {
+			return 0, err
+		}
+		if rl+1 > l {
+			l = rl + 1
+		}
+	}
+	levels[key] = l
+	return l, nil
+}
+
+// Display returns a textual representation of the requirements of the feature named 'name', as a tree,
+// followed by the features requiring it
+func (g *Graph) Display(name string) (string, error) {
+	key, ok := g.lookup(name)
+	if !ok {
+		return "", fmt.Errorf("failed to find a feature named '%s'", name)
+	}
+	// Resolves first, to fail on cycles instead of looping forever
+	_, err := g.Resolve(key)
+	if err != nil {
+		return "", err
+	}
+
+	output := fmt.Sprintf("%s (%s)\n", key, g.features[key].DisplayName())
+	output += g.displayRequirements(key, "")
+
+	dependents := g.requiredBy[key]
+	if len(dependents) > 0 {
+		output += fmt.Sprintf("\nRequired by: %s\n", strings.Join(dependents, ", "))
+	}
+	return output, nil
+}
+
+// displayRequirements returns the lines of the tree of requirements of the feature, each one prefixed
+// by 'indent'
+func (g *Graph) displayRequirements(key string, indent string) string {
+	output := ""
+	requirements := g.requires[key]
+	for i, r := range requirements {
+		rk, _ := g.lookup(r)
+		branch, next := "├── ", "│   "
+		if i == len(requirements)-1 {
+			branch, next = "└── ", "    "
+		}
+		output += indent + branch + rk + "\n"
+		output += g.displayRequirements(rk, indent+next)
+	}
+	return output
+}
+
+// AddFeatures installs the features named and their requirements on the target, following the dependency
+// graph: the features not depending on each others are installed in parallel.
+// Returns the results of the installation of each feature, by key
+func AddFeatures(names []string, t Target, v Variables, s Settings) (map[string]Results, error) {
+	g, err := NewGraph()
+	if err != nil {
+		return nil, err
+	}
+	levels, err := g.Resolve(names...)
+	if err != nil {
+		return nil, err
+	}
+	return addLevels(levels, t.Installed(), t, v, s)
+}
+
+// addLevels installs the features level after level, the features of a level in parallel.
+// The features in 'installed', recorded as installed on the target, are skipped without being checked
+// again. Stops after the first level where an installation failed
+func addLevels(levels [][]*Feature, installed []string, t Target, v Variables, s Settings) (map[string]Results, error) {
+	type addResult struct {
+		key     string
+		results Results
+		err     error
+	}
+
+	// Requirements are already handled by the levels
+	s.SkipFeatureRequirements = true
+
+	skip := map[string]bool{}
+	for _, name := range installed {
+		skip[name] = true
+	}
+
+	all := map[string]Results{}
+	for _, level := range levels {
+		pending := []*Feature{}
+		for _, f := range level {
+			if skip[featureKey(f)] {
+				log.Printf("Feature '%s' is already installed.", f.DisplayName())
+				continue
+			}
+			pending = append(pending, f)
+		}
+		done := make(chan addResult, len(pending))
+		for _, f := range pending {
+			go func(f *Feature) {
+				results, err := f.Add(t, v, s)
+				done <- addResult{key: featureKey(f), results: results, err: err}
+			}(f)
+		}
+		errors := []string{}
+		for range pending {
+			r := <-done
+			all[r.key] = r.results
+			if r.err != nil {
+				errors = append(errors, fmt.Sprintf("failed to install feature '%s': %s", r.key, r.err.Error()))
+			} else if !r.results.Successful() {
+				errors = append(errors, fmt.Sprintf("failed to install feature '%s':\n%s", r.key, r.results.AllErrorMessages()))
+			}
+		}
+		if len(errors) > 0 {
+			sort.Strings(errors)
+			return all, fmt.Errorf("%s", strings.Join(errors, "\n"))
+		}
+	}
+	return all, nil
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/deploy/install/enums/Method"
+)
+
+const testBashSpecs = `
+feature:
+  install:
+    bash:
+      check: {}
+`
+
+// newTestGraph returns the graph of features named by the keys of 'requires', each one requiring the
+// features listed
+func newTestGraph(t *testing.T, requires map[string][]string) *Graph {
+	g := &Graph{
+		features:   map[string]*Feature{},
+		names:      map[string]string{},
+		requires:   map[string][]string{},
+		requiredBy: map[string][]string{},
+	}
+	for key, list := range requires {
+		g.features[key] = testFeature(t, key, testBashSpecs, nil)
+		g.names[key] = key
+		g.requires[key] = list
+	}
+	for key, list := range requires {
+		for _, r := range list {
+			if rk, ok := g.lookup(r); ok {
+				g.requiredBy[rk] = append(g.requiredBy[rk], key)
+			}
+		}
+	}
+	return g
+}
+
+// levelKeys returns the keys of the features of each level
+func levelKeys(levels [][]*Feature) [][]string {
+	keys := [][]string{}
+	for _, level := range levels {
+		list := []string{}
+		for _, f := range level {
+			list = append(list, featureKey(f))
+		}
+		keys = append(keys, list)
+	}
+	return keys
+}
+
+func TestGraph_Resolve(t *testing.T) {
+	tests := []struct {
+		name     string
+		requires map[string][]string
+		resolve  []string
+		levels   [][]string
+		err      string
+	}{
+		{
+			name:     "no requirement",
+			requires: map[string][]string{"a": {}},
+			resolve:  []string{"a"},
+			levels:   [][]string{{"a"}},
+		},
+		{
+			name:     "features of a level are sorted",
+			requires: map[string][]string{"a": {}, "b": {"a"}, "c": {"a"}, "d": {"c", "b"}},
+			resolve:  []string{"d"},
+			levels:   [][]string{{"a"}, {"b", "c"}, {"d"}},
+		},
+		{
+			name:     "level follows the deepest requirement",
+			requires: map[string][]string{"a": {}, "b": {"a"}, "c": {"a", "b"}},
+			resolve:  []string{"c"},
+			levels:   [][]string{{"a"}, {"b"}, {"c"}},
+		},
+		{
+			name:     "several features",
+			requires: map[string][]string{"a": {}, "b": {"a"}, "e": {}, "f": {}},
+			resolve:  []string{"b", "e"},
+			levels:   [][]string{{"a", "e"}, {"b"}},
+		},
+		{
+			name:     "requirements are case insensitive",
+			requires: map[string][]string{"a": {}, "b": {" A "}},
+			resolve:  []string{"B"},
+			levels:   [][]string{{"a"}, {"b"}},
+		},
+		{
+			name:     "unknown feature",
+			requires: map[string][]string{"a": {}},
+			resolve:  []string{"z"},
+			err:      "failed to find a feature named 'z'",
+		},
+		{
+			name:     "missing requirement",
+			requires: map[string][]string{"a": {}, "b": {"a", "z"}, "c": {"b"}},
+			resolve:  []string{"c"},
+			err:      "feature 'b' requires unknown feature 'z'",
+		},
+		{
+			name:     "cycle",
+			requires: map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}, "d": {"a"}},
+			resolve:  []string{"d"},
+			err:      "dependency cycle between features: a -> b -> c -> a",
+		},
+		{
+			name:     "feature requiring itself",
+			requires: map[string][]string{"a": {"a"}},
+			resolve:  []string{"a"},
+			err:      "dependency cycle between features: a -> a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			levels, err := newTestGraph(t, tt.requires).Resolve(tt.resolve...)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, tt.levels, levelKeys(levels))
+		})
+	}
+}
+
+func TestGraph_Display_Cycle(t *testing.T) {
+	_, err := newTestGraph(t, map[string][]string{"a": {"b"}, "b": {"a"}}).Display("a")
+	assert.EqualError(t, err, "dependency cycle between features: a -> b -> a")
+}
+
+func TestAddLevels(t *testing.T) {
+	installers := map[string]*testInstaller{}
+	feature := func(name string, err error) *Feature {
+		installers[name] = &testInstaller{name: name, err: err}
+		return testFeature(t, "addlevels-"+name, testBashSpecs, map[Method.Enum]Installer{Method.Bash: installers[name]})
+	}
+	levels := [][]*Feature{
+		{feature("a", nil), feature("b", fmt.Errorf("no space left"))},
+		{feature("c", nil)},
+	}
+
+	// 'a' is recorded as installed, it isn't checked again
+	all, err := addLevels(levels, []string{"addlevels-a"}, testHostTarget(Method.Bash), Variables{}, Settings{})
+	require.NotNil(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "failed to install feature 'addlevels-b'"), err.Error())
+	assert.Empty(t, installers["a"].calls)
+	assert.Equal(t, []string{"check", "add"}, installers["b"].calls)
+	// The level after the failure isn't installed
+	assert.Empty(t, installers["c"].calls)
+	_, ok := all["addlevels-a"]
+	assert.False(t, ok)
+}
+
+func TestInstalledDependents(t *testing.T) {
+	target := testHostTarget(Method.Bash)
+	dependents := []*Feature{
+		testFeature(t, "dependent-recorded", testBashSpecs, nil),
+		testFeature(t, "dependent-checked", testBashSpecs, nil),
+		testFeature(t, "dependent-failed", testBashSpecs, nil),
+		testFeature(t, "dependent-unknown", testBashSpecs, nil),
+	}
+	checked := "dependent-checked@" + target.Name()
+	failed := "dependent-failed@" + target.Name()
+	checkCache.ForceSet(checked, Results{"check": stepResults{"host-1": stepResult{success: true}}})
+	checkCache.ForceSet(failed, Results{"check": stepResults{"host-1": stepResult{success: false}}})
+	defer checkCache.Reset(checked)
+	defer checkCache.Reset(failed)
+
+	installed := installedDependents(dependents, []string{"dependent-recorded", "other"}, target)
+	assert.Equal(t, []string{"dependent-recorded", "dependent-checked"}, installed)
+}
@@ -0,0 +1,467 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	brokerclient "github.com/CS-SI/SafeScale/broker/client"
+
+	"github.com/CS-SI/SafeScale/deploy/install/enums/Action"
+	"github.com/CS-SI/SafeScale/deploy/install/enums/Method"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	ansiblePlaybookCommand = "ansible-playbook"
+
+	ansibleGroupHosts        = "hosts"
+	ansibleGroupMasters      = "masters"
+	ansibleGroupPublicNodes  = "public_nodes"
+	ansibleGroupPrivateNodes = "private_nodes"
+)
+
+// ansibleInstaller is an installer using ansible playbooks, built from the tasks of the steps
+// in specification file
+type ansibleInstaller struct{}
+
+// Check checks if the feature is installed, using the check tasks in Specs
+func (i *ansibleInstaller) Check(c *Feature, t Target, v Variables, s Settings) (Results, error) {
+	specs := c.Specs()
+	yamlKey := "feature.install.ansible.check"
+	if !specs.IsSet(yamlKey) {
+		msg := `syntax error in feature '%s' specification file (%s): no key '%s' found`
+		return nil, fmt.Errorf(msg, c.DisplayName(), c.DisplayFilename(), yamlKey)
+	}
+
+	worker, err := newWorker(c, t, Method.Ansible, Action.Check, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = worker.CanProceed(s)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+	return worker.Proceed(v, s)
+}
+
+// Add installs the feature using the add tasks in Specs
+func (i *ansibleInstaller) Add(c *Feature, t Target, v Variables, s Settings) (Results, error) {
+	specs := c.Specs()
+	yamlKey := "feature.install.ansible.add"
+	if !specs.IsSet(yamlKey) {
+		msg := `syntax error in feature '%s' specification file (%s): no key '%s' found`
+		return nil, fmt.Errorf(msg, c.DisplayName(), c.DisplayFilename(), yamlKey)
+	}
+
+	worker, err := newWorker(c, t, Method.Ansible, Action.Add, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = worker.CanProceed(s)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+	return worker.Proceed(v, s)
+}
+
+// Remove uninstalls the feature using the remove tasks in Specs
+func (i *ansibleInstaller) Remove(c *Feature, t Target, v Variables, s Settings) (Results, error) {
+	specs := c.Specs()
+	yamlKey := "feature.install.ansible.remove"
+	if !specs.IsSet(yamlKey) {
+		msg := `syntax error in feature '%s' specification file (%s): no key '%s' found`
+		return nil, fmt.Errorf(msg, c.DisplayName(), c.DisplayFilename(), yamlKey)
+	}
+
+	worker, err := newWorker(c, t, Method.Ansible, Action.Remove, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = worker.CanProceed(s)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+	return worker.Proceed(v, s)
+}
+
+// NewAnsibleInstaller creates a new instance of Installer using ansible
+func NewAnsibleInstaller() Installer {
+	return &ansibleInstaller{}
+}
+
+// runWithAnsible executes the tasks of the step on all the hosts with a single run of ansible-playbook.
+// The variables are given to ansible as extra vars, so the tasks use them the ansible way ({{ Hostname }})
+func (is *step) runWithAnsible(hosts []*pb.Host, v Variables, s Settings) (stepResults, error) {
+	dir, err := ioutil.TempDir("", "safescale-ansible-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ansible working directory: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	inventory, err := is.Worker.ansibleInventory(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build ansible inventory for step '%s': %s", is.Name, err.Error())
+	}
+
+	vars, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert variables for step '%s': %s", is.Name, err.Error())
+	}
+	varsFile := filepath.Join(dir, "vars.json")
+	err = ioutil.WriteFile(varsFile, vars, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, h := range hosts {
+		names = append(names, h.Name)
+	}
+	playbook := filepath.Join(dir, fmt.Sprintf("%s.feature.%s_%s.yml", is.Worker.feature.BaseFilename(), strings.ToLower(is.Action.String()), is.Name))
+	content := ansiblePlaybook(fmt.Sprintf("%s %s: %s", strings.ToLower(is.Action.String()), is.Worker.feature.DisplayName(), is.Name), names, is.Serial || s.Serialize, is.Script)
+	err = ioutil.WriteFile(playbook, []byte(content), 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), is.WallTime)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := ansibleCommand(ctx, inventory, varsFile, playbook)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return nil, fmt.Errorf("failed to run %s: %s", ansiblePlaybookCommand, err.Error())
+		}
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return failedStepResults(hosts, fmt.Errorf("step '%s' timed out after %s", is.Name, is.WallTime)), nil
+	}
+
+	output := ansibleOutput{}
+	err = json.Unmarshal(stdout.Bytes(), &output)
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return failedStepResults(hosts, fmt.Errorf("step '%s' failed: %s", is.Name, msg)), nil
+	}
+	return output.results(is.Name, hosts), nil
+}
+
+// ansibleCommand returns the command running the playbook on the hosts of the inventory.
+// The host keys are checked against the known_hosts file written with the inventory
+func ansibleCommand(ctx context.Context, inventory, varsFile, playbook string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, ansiblePlaybookCommand, "-i", inventory, "-e", "@"+varsFile, playbook)
+	cmd.Env = append(os.Environ(),
+		"ANSIBLE_STDOUT_CALLBACK=json",
+		"ANSIBLE_HOST_KEY_CHECKING=True",
+		"ANSIBLE_RETRY_FILES_ENABLED=False",
+	)
+	return cmd
+}
+
+// ansiblePlaybook returns the content of a playbook applying the tasks on the hosts
+func ansiblePlaybook(name string, hosts []string, serial bool, tasks string) string {
+	playbook := "---\n"
+	playbook += fmt.Sprintf("- name: %q\n", name)
+	playbook += fmt.Sprintf("  hosts: %q\n", strings.Join(hosts, ":"))
+	playbook += "  become: yes\n"
+	if serial {
+		playbook += "  serial: 1\n"
+	}
+	playbook += "  tasks:\n"
+	for _, line := range strings.Split(strings.TrimRight(tasks, "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			playbook += "\n"
+			continue
+		}
+		playbook += "    " + line + "\n"
+	}
+	return playbook
+}
+
+// ansibleInventory writes in dir the inventory of the hosts of the target, the private keys to use
+// to reach them and their host keys, and returns the path of the inventory.
+// The hosts of a cluster are in groups masters, public_nodes and private_nodes, the host of any other
+// target in group hosts. The gateway is used as SSH jump host to reach the hosts without public IP
+func (w *worker) ansibleInventory(dir string) (string, error) {
+	groups := map[string][]*pb.Host{}
+	order := []string{}
+	if w.cluster != nil {
+		masters, err := w.identifyAllMasters()
+		if err != nil {
+			return "", err
+		}
+		publicNodes, err := w.identifyAllNodes(true)
+		if err != nil {
+			return "", err
+		}
+		privateNodes, err := w.identifyAllNodes(false)
+		if err != nil {
+			return "", err
+		}
+		groups[ansibleGroupMasters] = masters
+		groups[ansibleGroupPublicNodes] = publicNodes
+		groups[ansibleGroupPrivateNodes] = privateNodes
+		order = append(order, ansibleGroupMasters, ansibleGroupPublicNodes, ansibleGroupPrivateNodes)
+	} else {
+		groups[ansibleGroupHosts] = []*pb.Host{w.host}
+		order = append(order, ansibleGroupHosts)
+	}
+	return writeAnsibleInventory(dir, order, groups, brokerclient.New().Host.SSHConfig)
+}
+
+// writeAnsibleInventory writes in dir the inventory of the groups of hosts, in order, with the files
+// it refers to, using sshConfig to know how to reach each host
+func writeAnsibleInventory(dir string, order []string, groups map[string][]*pb.Host, sshConfig func(string) (*pb.SshConfig, error)) (string, error) {
+	access := newAnsibleAccess(dir)
+	inventory := ""
+	for _, group := range order {
+		inventory += fmt.Sprintf("[%s]\n", group)
+		for _, h := range groups[group] {
+			cfg, err := sshConfig(h.Name)
+			if err != nil {
+				return "", fmt.Errorf("failed to get SSH configuration of host '%s': %s", h.Name, err.Error())
+			}
+			line, err := access.inventoryLine(h.Name, cfg)
+			if err != nil {
+				return "", err
+			}
+			inventory += line + "\n"
+		}
+		inventory += "\n"
+	}
+
+	err := access.writeKnownHosts()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "inventory")
+	err = ioutil.WriteFile(path, []byte(inventory), 0600)
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// ansibleAccess collects in a directory the private keys and the host keys used by ansible to reach the hosts
+type ansibleAccess struct {
+	dir        string
+	keys       map[string]string
+	knownHosts map[string]bool
+	lines      []string
+}
+
+// newAnsibleAccess creates an ansibleAccess writing its files in dir
+func newAnsibleAccess(dir string) *ansibleAccess {
+	return &ansibleAccess{
+		dir:        dir,
+		keys:       map[string]string{},
+		knownHosts: map[string]bool{},
+	}
+}
+
+// keyFile returns the path of the file containing the private key, written once whatever the number of
+// hosts using it
+func (a *ansibleAccess) keyFile(key string) (string, error) {
+	if path, ok := a.keys[key]; ok {
+		return path, nil
+	}
+	path := filepath.Join(a.dir, fmt.Sprintf("key%d", len(a.keys)))
+	err := ioutil.WriteFile(path, []byte(key), 0600)
+	if err != nil {
+		return "", err
+	}
+	a.keys[key] = path
+	return path, nil
+}
+
+// knownHostsFile returns the path of the known_hosts file used by ansible
+func (a *ansibleAccess) knownHostsFile() string {
+	return filepath.Join(a.dir, "known_hosts")
+}
+
+// trust adds to the known_hosts file the host key pinned in the metadata of the host reached with cfg.
+// A host without pinned key is refused: its identity could not be checked
+func (a *ansibleAccess) trust(name string, cfg *pb.SshConfig, port int32) error {
+	if cfg.HostKey == "" {
+		return fmt.Errorf("host key of '%s' is unknown, cannot check its identity", name)
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cfg.HostKey))
+	if err != nil {
+		return fmt.Errorf("invalid host key of '%s': %s", name, err.Error())
+	}
+	line := knownhosts.Line([]string{knownhosts.Normalize(fmt.Sprintf("%s:%d", cfg.Host, port))}, key)
+	if !a.knownHosts[line] {
+		a.knownHosts[line] = true
+		a.lines = append(a.lines, line)
+	}
+	return nil
+}
+
+// writeKnownHosts writes the known_hosts file with the host keys of all the hosts of the inventory
+func (a *ansibleAccess) writeKnownHosts() error {
+	content := ""
+	for _, line := range a.lines {
+		content += line + "\n"
+	}
+	return ioutil.WriteFile(a.knownHostsFile(), []byte(content), 0600)
+}
+
+// inventoryLine returns the line of the inventory describing how to reach the host
+func (a *ansibleAccess) inventoryLine(name string, cfg *pb.SshConfig) (string, error) {
+	key, err := a.keyFile(cfg.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = 22
+	}
+	err = a.trust(name, cfg, port)
+	if err != nil {
+		return "", err
+	}
+	checking := fmt.Sprintf("-o StrictHostKeyChecking=yes -o UserKnownHostsFile=%s", a.knownHostsFile())
+	line := fmt.Sprintf("%s ansible_host=%s ansible_port=%d ansible_user=%s ansible_ssh_private_key_file=%s",
+		name, cfg.Host, port, cfg.User, key)
+	args := checking
+	if gw := cfg.GetGateway(); gw != nil {
+		gwKey, err := a.keyFile(gw.PrivateKey)
+		if err != nil {
+			return "", err
+		}
+		gwPort := gw.Port
+		if gwPort == 0 {
+			gwPort = 22
+		}
+		err = a.trust(fmt.Sprintf("gateway of %s", name), gw, gwPort)
+		if err != nil {
+			return "", err
+		}
+		proxy := fmt.Sprintf("ssh -i %s %s -W %%h:%%p -p %d %s@%s", gwKey, checking, gwPort, gw.User, gw.Host)
+		args += fmt.Sprintf(" -o ProxyCommand=\"%s\"", proxy)
+	}
+	line += fmt.Sprintf(" ansible_ssh_common_args='%s'", args)
+	return line, nil
+}
+
+// ansibleOutput is the output of ansible-playbook using the json stdout callback
+type ansibleOutput struct {
+	Plays []struct {
+		Tasks []struct {
+			Task struct {
+				Name string `json:"name"`
+			} `json:"task"`
+			Hosts map[string]ansibleTaskResult `json:"hosts"`
+		} `json:"tasks"`
+	} `json:"plays"`
+	Stats map[string]ansibleHostStats `json:"stats"`
+}
+
+// ansibleTaskResult is the result of a task on a host
+type ansibleTaskResult struct {
+	Failed      bool        `json:"failed"`
+	Unreachable bool        `json:"unreachable"`
+	Msg         interface{} `json:"msg"`
+	Stderr      string      `json:"stderr"`
+}
+
+// ansibleHostStats is the summary of the playbook run on a host
+type ansibleHostStats struct {
+	Ok          int `json:"ok"`
+	Changed     int `json:"changed"`
+	Failures    int `json:"failures"`
+	Unreachable int `json:"unreachable"`
+	Skipped     int `json:"skipped"`
+}
+
+// results maps the outcome of the playbook to the results of the step for each host.
+// A host fails if one of the tasks failed on it or if it was unreachable; the message of the first
+// failing task is kept as error
+func (o *ansibleOutput) results(stepName string, hosts []*pb.Host) stepResults {
+	messages := map[string]string{}
+	for _, play := range o.Plays {
+		for _, task := range play.Tasks {
+			for h, r := range task.Hosts {
+				if !r.Failed && !r.Unreachable {
+					continue
+				}
+				if _, ok := messages[h]; ok {
+					continue
+				}
+				msg := strings.TrimSpace(r.Stderr)
+				if msg == "" && r.Msg != nil {
+					msg = strings.TrimSpace(fmt.Sprintf("%v", r.Msg))
+				}
+				if r.Unreachable {
+					messages[h] = fmt.Sprintf("step '%s' failed: host unreachable: %s", stepName, msg)
+				} else {
+					messages[h] = fmt.Sprintf("step '%s' failed on task '%s': %s", stepName, task.Task.Name, msg)
+				}
+			}
+		}
+	}
+
+	results := stepResults{}
+	for _, h := range hosts {
+		stats, ok := o.Stats[h.Name]
+		switch {
+		case !ok:
+			results[h.Name] = stepResult{success: false, err: fmt.Errorf("step '%s' failed: host not played", stepName)}
+		case stats.Failures > 0 || stats.Unreachable > 0:
+			msg, ok := messages[h.Name]
+			if !ok {
+				msg = fmt.Sprintf("step '%s' failed (failures=%d, unreachable=%d)", stepName, stats.Failures, stats.Unreachable)
+			}
+			results[h.Name] = stepResult{success: false, err: fmt.Errorf("%s", msg)}
+		default:
+			results[h.Name] = stepResult{success: true}
+		}
+	}
+	return results
+}
+
+// failedStepResults returns results of a step failed on all the hosts with the same error
+func failedStepResults(hosts []*pb.Host, err error) stepResults {
+	results := stepResults{}
+	for _, h := range hosts {
+		results[h.Name] = stepResult{success: false, err: err}
+	}
+	return results
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+)
+
+const (
+	testHostKey    = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIEk2kDGMAw+DsJtLJrW9V7Vhzlw3LLoEE+78Tl98RgXq"
+	testGatewayKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIJZznNVQedWoevWAbiY38KtwEBpvw1hqNPY7lz6akGyK"
+)
+
+func testAnsibleSSHConfigs(configs map[string]*pb.SshConfig) func(string) (*pb.SshConfig, error) {
+	return func(name string) (*pb.SshConfig, error) {
+		cfg, ok := configs[name]
+		if !ok {
+			return nil, fmt.Errorf("host '%s' not found", name)
+		}
+		return cfg, nil
+	}
+}
+
+func TestWriteAnsibleInventory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safescale-ansible-test-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	gateway := &pb.SshConfig{User: "gw", Host: "203.0.113.1", PrivateKey: "gateway key", HostKey: testGatewayKey}
+	configs := map[string]*pb.SshConfig{
+		"master-1": {User: "cladm", Host: "203.0.113.10", Port: 2222, PrivateKey: "master key", HostKey: testHostKey},
+		"node-1":   {User: "cladm", Host: "10.0.0.11", PrivateKey: "node key", HostKey: testHostKey, Gateway: gateway},
+		"node-2":   {User: "cladm", Host: "10.0.0.12", PrivateKey: "node key", HostKey: testHostKey, Gateway: gateway},
+	}
+	groups := map[string][]*pb.Host{
+		ansibleGroupMasters:      {{Name: "master-1"}},
+		ansibleGroupPublicNodes:  {},
+		ansibleGroupPrivateNodes: {{Name: "node-1"}, {Name: "node-2"}},
+	}
+	order := []string{ansibleGroupMasters, ansibleGroupPublicNodes, ansibleGroupPrivateNodes}
+
+	path, err := writeAnsibleInventory(dir, order, groups, testAnsibleSSHConfigs(configs))
+	require.Nil(t, err)
+	content, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+	lines := strings.Split(string(content), "\n")
+
+	knownHosts := filepath.Join(dir, "known_hosts")
+	checking := "-o StrictHostKeyChecking=yes -o UserKnownHostsFile=" + knownHosts
+	assert.Equal(t, "[masters]", lines[0])
+	assert.Equal(t, fmt.Sprintf("master-1 ansible_host=203.0.113.10 ansible_port=2222 ansible_user=cladm ansible_ssh_private_key_file=%s ansible_ssh_common_args='%s'",
+		filepath.Join(dir, "key0"), checking), lines[1])
+	assert.Equal(t, "[public_nodes]", lines[3])
+	assert.Equal(t, "[private_nodes]", lines[5])
+	proxy := fmt.Sprintf("ssh -i %s %s -W %%h:%%p -p 22 gw@203.0.113.1", filepath.Join(dir, "key2"), checking)
+	assert.Equal(t, fmt.Sprintf("node-1 ansible_host=10.0.0.11 ansible_port=22 ansible_user=cladm ansible_ssh_private_key_file=%s ansible_ssh_common_args='%s -o ProxyCommand=\"%s\"'",
+		filepath.Join(dir, "key1"), checking, proxy), lines[6])
+	assert.True(t, strings.HasPrefix(lines[7], "node-2 ansible_host=10.0.0.12 "), lines[7])
+
+	// Each private key is written once
+	for i, key := range []string{"master key", "node key", "gateway key"} {
+		content, err := ioutil.ReadFile(filepath.Join(dir, fmt.Sprintf("key%d", i)))
+		require.Nil(t, err)
+		assert.Equal(t, key, string(content))
+	}
+	_, err = os.Stat(filepath.Join(dir, "key3"))
+	assert.True(t, os.IsNotExist(err))
+
+	// The known_hosts file pins the host keys from the metadata, once per address
+	content, err = ioutil.ReadFile(knownHosts)
+	require.Nil(t, err)
+	assert.Equal(t, "[203.0.113.10]:2222 "+testHostKey+"\n"+
+		"10.0.0.11 "+testHostKey+"\n"+
+		"203.0.113.1 "+testGatewayKey+"\n"+
+		"10.0.0.12 "+testHostKey+"\n", string(content))
+}
+
+func TestWriteAnsibleInventory_UnknownHostKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safescale-ansible-test-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	groups := map[string][]*pb.Host{ansibleGroupHosts: {{Name: "host-1"}}}
+	order := []string{ansibleGroupHosts}
+
+	configs := map[string]*pb.SshConfig{
+		"host-1": {User: "safescale", Host: "203.0.113.10", PrivateKey: "key"},
+	}
+	_, err = writeAnsibleInventory(dir, order, groups, testAnsibleSSHConfigs(configs))
+	assert.NotNil(t, err)
+
+	configs["host-1"].HostKey = testHostKey
+	configs["host-1"].Gateway = &pb.SshConfig{User: "gw", Host: "203.0.113.1", PrivateKey: "gateway key"}
+	_, err = writeAnsibleInventory(dir, order, groups, testAnsibleSSHConfigs(configs))
+	assert.NotNil(t, err)
+
+	_, err = writeAnsibleInventory(dir, order, groups, testAnsibleSSHConfigs(map[string]*pb.SshConfig{}))
+	assert.NotNil(t, err)
+}
+
+func TestAnsibleCommand(t *testing.T) {
+	cmd := ansibleCommand(context.Background(), "/tmp/dir/inventory", "/tmp/dir/vars.json", "/tmp/dir/playbook.yml")
+	assert.Equal(t, []string{ansiblePlaybookCommand, "-i", "/tmp/dir/inventory", "-e", "@/tmp/dir/vars.json", "/tmp/dir/playbook.yml"}, cmd.Args)
+	assert.Contains(t, cmd.Env, "ANSIBLE_STDOUT_CALLBACK=json")
+	assert.Contains(t, cmd.Env, "ANSIBLE_HOST_KEY_CHECKING=True")
+	assert.NotContains(t, cmd.Env, "ANSIBLE_HOST_KEY_CHECKING=False")
+}
+
+func TestAnsiblePlaybook(t *testing.T) {
+	tasks := "- name: install\n  package:\n    name: \"{{ Package }}\"\n\n- name: start\n  service: name=foo state=started\n"
+	playbook := ansiblePlaybook("add foo: install", []string{"node-1", "node-2"}, true, tasks)
+	assert.Equal(t, `---
+- name: "add foo: install"
+  hosts: "node-1:node-2"
+  become: yes
+  serial: 1
+  tasks:
+    - name: install
+      package:
+        name: "{{ Package }}"
+
+    - name: start
+      service: name=foo state=started
+`, playbook)
+
+	playbook = ansiblePlaybook("check foo: check", []string{"host-1"}, false, "- command: foo --version")
+	assert.NotContains(t, playbook, "serial")
+	assert.Contains(t, playbook, "    - command: foo --version\n")
+}
+
+func TestAnsibleOutputResults(t *testing.T) {
+	output := ansibleOutput{}
+	err := json.Unmarshal([]byte(`{
+		"plays": [{"tasks": [
+			{"task": {"name": "install"}, "hosts": {
+				"node-1": {"failed": false},
+				"node-2": {"failed": true, "msg": "no package foo", "stderr": ""}
+			}},
+			{"task": {"name": "start"}, "hosts": {
+				"node-1": {"failed": true, "stderr": "unit foo not found"}
+			}}
+		]}],
+		"stats": {
+			"node-1": {"ok": 1, "failures": 1},
+			"node-2": {"ok": 0, "failures": 1},
+			"node-3": {"ok": 2}
+		}
+	}`), &output)
+	require.Nil(t, err)
+
+	hosts := []*pb.Host{{Name: "node-1"}, {Name: "node-2"}, {Name: "node-3"}, {Name: "node-4"}}
+	results := output.results("install", hosts)
+	require.Len(t, results, 4)
+	assert.EqualError(t, results["node-1"].err, "step 'install' failed on task 'start': unit foo not found")
+	assert.EqualError(t, results["node-2"].err, "step 'install' failed on task 'install': no package foo")
+	assert.True(t, results["node-3"].success)
+	assert.False(t, results["node-4"].success)
+}
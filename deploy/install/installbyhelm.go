@@ -0,0 +1,380 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/CS-SI/SafeScale/broker"
+	brokerclient "github.com/CS-SI/SafeScale/broker/client"
+
+	"github.com/CS-SI/SafeScale/deploy/cluster/enums/Flavor"
+
+	"github.com/CS-SI/SafeScale/deploy/install/enums/Action"
+)
+
+const (
+	yamlRepositoriesKeyword = "repositories"
+	yamlReleasesKeyword     = "releases"
+	yamlChartKeyword        = "chart"
+	yamlVersionKeyword      = "version"
+	yamlNamespaceKeyword    = "namespace"
+	yamlValuesKeyword       = "values"
+)
+
+// helmInstaller is an installer deploying the charts declared in specification file as helm releases
+// on a Kubernetes cluster:
+//
+//	install:
+//	    helm:
+//	        repositories:
+//	            incubator: https://kubernetes-charts-incubator.storage.googleapis.com
+//	        pace: release1,release2
+//	        releases:
+//	            release1:
+//	                chart: incubator/chart
+//	                version: 1.0.0
+//	                namespace: ns
+//	                wallTime: 10
+//	                values: |
+//	                    key: {{.Variable}}
+//
+// Results are reported per release, for the master used to run helm.
+type helmInstaller struct{}
+
+// helmRelease contains the definition of a release in specification file
+type helmRelease struct {
+	Name      string
+	Chart     string
+	Version   string
+	Namespace string
+	Values    string
+	WallTime  time.Duration
+}
+
+// Check checks if the releases of the feature are deployed
+func (i *helmInstaller) Check(c *Feature, t Target, v Variables, s Settings) (Results, error) {
+	return i.proceed(c, t, Action.Check, v, s)
+}
+
+// Add installs the releases of the feature
+func (i *helmInstaller) Add(c *Feature, t Target, v Variables, s Settings) (Results, error) {
+	return i.proceed(c, t, Action.Add, v, s)
+}
+
+// Remove deletes the releases of the feature
+func (i *helmInstaller) Remove(c *Feature, t Target, v Variables, s Settings) (Results, error) {
+	return i.proceed(c, t, Action.Remove, v, s)
+}
+
+// Upgrade upgrades the releases of the feature to the charts and values of specification file
+func (i *helmInstaller) Upgrade(c *Feature, t Target, v Variables, s Settings) (Results, error) {
+	return i.proceed(c, t, Action.Upgrade, v, s)
+}
+
+// Rollback rolls back the releases of the feature to the revision in variable 'Revision', or to
+// their previous revision if not set
+func (i *helmInstaller) Rollback(c *Feature, t Target, v Variables, s Settings) (Results, error) {
+	return i.proceed(c, t, Action.Rollback, v, s)
+}
+
+// NewHelmInstaller creates a new instance of Installer using helm
+func NewHelmInstaller() Installer {
+	return &helmInstaller{}
+}
+
+// proceed executes the action on each release of the feature, using helm on an available master
+func (i *helmInstaller) proceed(c *Feature, t Target, a Action.Enum, v Variables, s Settings) (Results, error) {
+	_, clusterTarget, _ := determineContext(t)
+	if clusterTarget == nil {
+		return nil, fmt.Errorf("feature '%s' can only be installed with helm on a cluster", c.DisplayName())
+	}
+	w := &worker{
+		feature:   c,
+		target:    t,
+		action:    a,
+		cluster:   clusterTarget.cluster,
+		variables: v,
+		settings:  s,
+	}
+	err := w.CanProceed(s)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+	err = validateContextForHelm(w, v, s)
+	if err != nil {
+		return nil, err
+	}
+
+	releases, err := helmReleases(c)
+	if err != nil {
+		return nil, err
+	}
+	master, err := w.identifyAvailableMaster()
+	if err != nil {
+		return nil, err
+	}
+
+	if a == Action.Add || a == Action.Upgrade {
+		err = helmAddRepositories(c, master, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if a == Action.Add && !s.SkipProxy {
+		err = w.setReverseProxy()
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Releases are removed in reverse order of installation
+	if a == Action.Remove {
+		for l, r := 0, len(releases)-1; l < r; l, r = l+1, r-1 {
+			releases[l], releases[r] = releases[r], releases[l]
+		}
+	}
+
+	results := Results{}
+	for _, release := range releases {
+		result := helmRun(c, release, a, master, v)
+		results[release.Name] = stepResults{master.Name: result}
+		// Releases may depend on previous ones, don't deploy the next ones if one failed
+		if !result.Successful() && (a == Action.Add || a == Action.Upgrade) {
+			break
+		}
+	}
+	return results, nil
+}
+
+// validateContextForHelm checks helm is useable on the cluster: a K8S cluster, or a DCOS cluster
+// with Kubernetes
+func validateContextForHelm(w *worker, v Variables, s Settings) error {
+	flavor := w.cluster.GetConfig().Flavor
+	switch flavor {
+	case Flavor.K8S:
+		return nil
+	case Flavor.DCOS:
+		kubernetes, err := NewFeature("kubernetes")
+		if err != nil {
+			return err
+		}
+		results, err := kubernetes.Check(w.target, v, s)
+		if err != nil {
+			return fmt.Errorf("failed to check if Kubernetes is installed: %s", err.Error())
+		}
+		if !results.Successful() {
+			return fmt.Errorf("feature '%s' needs Kubernetes to be installed on the DCOS cluster to use helm", w.feature.DisplayName())
+		}
+		return nil
+	}
+	return fmt.Errorf("helm can't be used on cluster of flavor '%s'", flavor.String())
+}
+
+// helmReleases returns the releases declared in specification file, in the order of key 'pace' if
+// set, by name otherwise
+func helmReleases(c *Feature) ([]helmRelease, error) {
+	specs := c.Specs()
+	rootKey := "feature.install.helm"
+	releasesKey := rootKey + "." + yamlReleasesKeyword
+	anons := specs.GetStringMap(releasesKey)
+	if len(anons) <= 0 {
+		msg := `syntax error in feature '%s' specification file (%s): no key '%s' found`
+		return nil, fmt.Errorf(msg, c.DisplayName(), c.DisplayFilename(), releasesKey)
+	}
+
+	order := []string{}
+	pace := specs.GetString(rootKey + "." + yamlPaceKeyword)
+	if pace != "" {
+		order = strings.Split(pace, ",")
+	} else {
+		for k := range anons {
+			order = append(order, k)
+		}
+		sort.Strings(order)
+	}
+
+	releases := []helmRelease{}
+	for _, k := range order {
+		k = strings.TrimSpace(k)
+		releaseKey := releasesKey + "." + k
+		anon, ok := anons[strings.ToLower(k)]
+		if !ok {
+			msg := `syntax error in feature '%s' specification file (%s): no key '%s' found`
+			return nil, fmt.Errorf(msg, c.DisplayName(), c.DisplayFilename(), releaseKey)
+		}
+		releaseMap, ok := anon.(map[string]interface{})
+		if !ok {
+			msg := `syntax error in feature '%s' specification file (%s): invalid content for key '%s'`
+			return nil, fmt.Errorf(msg, c.DisplayName(), c.DisplayFilename(), releaseKey)
+		}
+		release := helmRelease{
+			Name:     k,
+			WallTime: 5 * time.Minute,
+		}
+		if anon, ok = releaseMap[yamlChartKeyword]; ok {
+			release.Chart, _ = anon.(string)
+		}
+		if release.Chart == "" {
+			msg := `syntax error in feature '%s' specification file (%s): no key '%s.%s' found`
+			return nil, fmt.Errorf(msg, c.DisplayName(), c.DisplayFilename(), releaseKey, yamlChartKeyword)
+		}
+		if anon, ok = releaseMap[yamlVersionKeyword]; ok {
+			release.Version = fmt.Sprintf("%v", anon)
+		}
+		if anon, ok = releaseMap[yamlNamespaceKeyword]; ok {
+			release.Namespace, _ = anon.(string)
+		}
+		if anon, ok = releaseMap[yamlValuesKeyword]; ok {
+			release.Values, _ = anon.(string)
+		}
+		if anon, ok = releaseMap[strings.ToLower(yamlWallTimeKeyword)]; ok {
+			wallTime, err := strconv.Atoi(fmt.Sprintf("%v", anon))
+			if err != nil || wallTime <= 0 {
+				log.Printf("Invalid value '%v' for '%s.%s', ignored.", anon, releaseKey, yamlWallTimeKeyword)
+			} else {
+				release.WallTime = time.Duration(wallTime) * time.Minute
+			}
+		}
+		releases = append(releases, release)
+	}
+	return releases, nil
+}
+
+// helmAddRepositories declares on the master the chart repositories of specification file
+func helmAddRepositories(c *Feature, master *pb.Host, v Variables) error {
+	specs := c.Specs()
+	repositories := specs.GetStringMapString("feature.install.helm." + yamlRepositoriesKeyword)
+	if len(repositories) <= 0 {
+		return nil
+	}
+	names := []string{}
+	for name := range repositories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	cmd := ""
+	for _, name := range names {
+		cmd += fmt.Sprintf("helm repo add %s %s && ", name, repositories[name])
+	}
+	cmd += "helm repo update"
+	retcode, _, stderr, err := ExecuteScriptOnHost(master.Name, helmCommand(v, cmd), brokerclient.DefaultExecutionTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to add helm repositories: %s", err.Error())
+	}
+	if retcode != 0 {
+		return fmt.Errorf("failed to add helm repositories (retcode=%d): %s", retcode, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// helmRun executes the action on the release with helm on the master
+func helmRun(c *Feature, release helmRelease, a Action.Enum, master *pb.Host, v Variables) stepResult {
+	valuesFile := ""
+	if release.Values != "" && (a == Action.Add || a == Action.Upgrade) {
+		values, err := replaceVariablesInString(release.Values, v)
+		if err != nil {
+			return stepResult{success: false, err: fmt.Errorf("failed to finalize values of release '%s': %s", release.Name, err.Error())}
+		}
+		valuesFile = fmt.Sprintf("/var/tmp/%s.feature.%s.values.yaml", c.BaseFilename(), release.Name)
+		owner := ""
+		if username, ok := v["Username"].(string); ok {
+			owner = username
+		}
+		err = UploadStringToRemoteFile(values, master, valuesFile, owner, "", "u+rw-x,go-rwx")
+		if err != nil {
+			return stepResult{success: false, err: err}
+		}
+	}
+
+	args, err := helmArguments(release, a, valuesFile, v)
+	if err != nil {
+		return stepResult{success: false, err: err}
+	}
+	cmd := helmCommand(v, args)
+	if valuesFile != "" {
+		cmd = fmt.Sprintf("%s; rc=$?; sudo rm -f %s; exit $rc", cmd, valuesFile)
+	}
+	retcode, _, stderr, err := ExecuteScriptOnHost(master.Name, cmd, release.WallTime)
+	if err != nil {
+		return stepResult{success: false, err: err}
+	}
+	if retcode != 0 {
+		if a == Action.Check {
+			return stepResult{success: false, err: fmt.Errorf("release '%s' is not deployed", release.Name)}
+		}
+		msg := strings.TrimSpace(stderr)
+		return stepResult{success: false, err: fmt.Errorf("%s of release '%s' failed (retcode=%d): %s", strings.ToLower(a.String()), release.Name, retcode, msg)}
+	}
+	return stepResult{success: true}
+}
+
+// helmArguments returns the helm command line corresponding to the action on the release
+func helmArguments(release helmRelease, a Action.Enum, valuesFile string, v Variables) (string, error) {
+	options := ""
+	if a == Action.Add || a == Action.Upgrade {
+		if release.Namespace != "" {
+			options += " --namespace " + release.Namespace
+		}
+		if release.Version != "" {
+			options += " --version " + release.Version
+		}
+		if valuesFile != "" {
+			options += " -f " + valuesFile
+		}
+		options += fmt.Sprintf(" --wait --timeout %d", int(release.WallTime.Seconds()))
+	}
+
+	switch a {
+	case Action.Check:
+		return fmt.Sprintf("helm status %s | grep -q '^STATUS: DEPLOYED'", release.Name), nil
+	case Action.Add:
+		return fmt.Sprintf("helm install %s --name %s%s", release.Chart, release.Name, options), nil
+	case Action.Upgrade:
+		return fmt.Sprintf("helm upgrade %s %s%s", release.Name, release.Chart, options), nil
+	case Action.Rollback:
+		revision, err := revisionOf(v)
+		if err != nil {
+			return "", err
+		}
+		if revision > 0 {
+			return fmt.Sprintf("helm rollback --wait %s %d", release.Name, revision), nil
+		}
+		// Without revision, rolls back to the one preceding the current one, which must exist
+		return fmt.Sprintf("revs=$(helm history %[1]s | awk 'NR>1 {print $1}') || exit 1; "+
+			"if [ $(echo \"$revs\" | grep -c .) -lt 2 ]; then echo \"release '%[1]s' has no previous revision to roll back to\" >&2; exit 1; fi; "+
+			"helm rollback --wait %[1]s $(echo \"$revs\" | tail -n 2 | head -n 1)", release.Name), nil
+	case Action.Remove:
+		return fmt.Sprintf("helm delete --purge %s", release.Name), nil
+	}
+	return "", fmt.Errorf("action '%s' is not supported by helm", a.String())
+}
+
+// helmCommand returns the command running helm as the user of the cluster
+func helmCommand(v Variables, args string) string {
+	quoted := "'" + strings.Replace(args, "'", `'"'"'`, -1) + "'"
+	username, ok := v["Username"].(string)
+	if !ok || username == "" {
+		return "bash -c " + quoted
+	}
+	return fmt.Sprintf("sudo -u %s -i bash -c %s", username, quoted)
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/deploy/install/enums/Action"
+)
+
+// runWithFakeHelm runs the script with a helm function listing the revisions of the release and
+// printing its other invocations
+func runWithFakeHelm(script string, revisions ...int) (string, error) {
+	history := "REVISION\tSTATUS"
+	for _, r := range revisions {
+		history += fmt.Sprintf("\\n%d\tDEPLOYED", r)
+	}
+	fake := fmt.Sprintf(`helm() { if [ "$1" = history ]; then printf '%s\n'; else echo "helm $*"; fi; }`, history)
+	out, err := exec.Command("bash", "-c", fake+"\n"+script).CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+func TestHelmArguments(t *testing.T) {
+	release := helmRelease{Name: "foo", Chart: "stable/foo", Namespace: "bar", Version: "1.2.3", WallTime: 5 * time.Minute}
+
+	args := func(a Action.Enum, valuesFile string, v Variables) string {
+		out, err := helmArguments(release, a, valuesFile, v)
+		require.Nil(t, err)
+		return out
+	}
+	assert.Equal(t, "helm install stable/foo --name foo --namespace bar --version 1.2.3 -f /var/tmp/values.yaml --wait --timeout 300",
+		args(Action.Add, "/var/tmp/values.yaml", Variables{}))
+	assert.Equal(t, "helm upgrade foo stable/foo --namespace bar --version 1.2.3 --wait --timeout 300",
+		args(Action.Upgrade, "", Variables{}))
+	assert.Equal(t, "helm rollback --wait foo 2", args(Action.Rollback, "", Variables{"Revision": 2}))
+	assert.Equal(t, "helm rollback --wait foo 3", args(Action.Rollback, "", Variables{"Revision": "3"}))
+	assert.Equal(t, "helm delete --purge foo", args(Action.Remove, "", Variables{}))
+
+	// The revision given by the user must be a positive number
+	for _, revision := range []interface{}{"1; reboot", "$(reboot)", "0", "-1", "", 0} {
+		_, err := helmArguments(release, Action.Rollback, "", Variables{"Revision": revision})
+		assert.NotNil(t, err, revision)
+	}
+}
+
+func TestHelmArguments_RollbackToPrevious(t *testing.T) {
+	script, err := helmArguments(helmRelease{Name: "foo"}, Action.Rollback, "", Variables{})
+	require.Nil(t, err)
+
+	out, err := runWithFakeHelm(script, 1, 2, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, "helm rollback --wait foo 2", out)
+
+	// With a single revision, there is nothing to roll back to
+	out, err = runWithFakeHelm(script, 1)
+	assert.NotNil(t, err)
+	assert.Equal(t, "release 'foo' has no previous revision to roll back to", out)
+
+	out, err = runWithFakeHelm(script)
+	assert.NotNil(t, err)
+	assert.NotContains(t, out, "helm rollback")
+}
+
+func TestHelmCommand(t *testing.T) {
+	assert.Equal(t, `bash -c 'helm status foo | grep -q '"'"'^STATUS: DEPLOYED'"'"''`,
+		helmCommand(Variables{}, "helm status foo | grep -q '^STATUS: DEPLOYED'"))
+	assert.Equal(t, "sudo -u cladm -i bash -c 'helm delete --purge foo'",
+		helmCommand(Variables{"Username": "cladm"}, "helm delete --purge foo"))
+}
@@ -0,0 +1,336 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	providerapi "github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/enums/HostExtension"
+	"github.com/CS-SI/SafeScale/providers/metadata"
+
+	"github.com/CS-SI/SafeScale/utils/provideruse"
+
+	"github.com/CS-SI/SafeScale/deploy/cluster/enums/Extension"
+	clustermetadata "github.com/CS-SI/SafeScale/deploy/cluster/metadata"
+)
+
+// implicitSecrets lists the implicit variables which are secrets, never kept in the records of installation
+var implicitSecrets = []string{"Password"}
+
+// ListInstalled returns the features recorded as installed on the target.
+// If refresh is true, the features are checked again and the records updated with the results
+func ListInstalled(t Target, refresh bool) ([]*providerapi.InstalledFeature, error) {
+	features, err := loadInstalled(t)
+	if err != nil {
+		return nil, err
+	}
+	if refresh {
+		// Checks are done without holding the lock on metadata, the outcome is applied afterwards
+		refreshed := providerapi.InstalledFeatures{}
+		for name, record := range features {
+			refreshed[name] = refreshInstalled(t, record)
+		}
+		features, err = updateInstalled(t, func(features providerapi.InstalledFeatures) bool {
+			for name, record := range refreshed {
+				if record == nil {
+					delete(features, name)
+				} else {
+					features[name] = record
+				}
+			}
+			return len(refreshed) > 0
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	list := []*providerapi.InstalledFeature{}
+	for _, record := range features {
+		list = append(list, record)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// installedNames returns the names of the features recorded as successfully installed on the target
+func installedNames(t Target) []string {
+	list := []string{}
+	features, err := loadInstalled(t)
+	if err != nil {
+		log.Printf("failed to read features installed on %s '%s': %s", t.Type(), t.Name(), err.Error())
+		return list
+	}
+	for name, record := range features {
+		if record.Successful() {
+			list = append(list, name)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// refreshInstalled checks again the feature recorded, and returns its updated record, or nil if the feature
+// isn't installed anymore. If the check can't be done, the record is returned unchanged
+func refreshInstalled(t Target, record *providerapi.InstalledFeature) *providerapi.InstalledFeature {
+	f, err := NewFeature(record.Name)
+	if err != nil || f == nil {
+		log.Printf("failed to refresh feature '%s': feature not found, record kept", record.Name)
+		return record
+	}
+	v := Variables{}
+	for key, value := range record.Variables {
+		v[key] = value
+	}
+	results, err := f.Check(t, v, Settings{})
+	if err != nil {
+		log.Printf("failed to refresh feature '%s': %s", record.Name, err.Error())
+		return record
+	}
+	if !results.Successful() {
+		return nil
+	}
+	updated := *record
+	updated.Hosts = hostsOfResults(results)
+	updated.Date = time.Now()
+	return &updated
+}
+
+// recordInstalled saves the outcome of the installation of the feature in the metadata of the target
+// The metadata are left untouched if the feature is already recorded the same way, as when an installed
+// feature is added again
+func (f *Feature) recordInstalled(t Target, v Variables, results Results) {
+	record := f.installedRecord(v, results)
+	_, err := updateInstalled(t, func(features providerapi.InstalledFeatures) bool {
+		if sameInstalled(features[record.Name], record) {
+			return false
+		}
+		features[record.Name] = record
+		return true
+	})
+	if err != nil {
+		log.Printf("failed to record installation of feature '%s' on %s '%s': %s", f.DisplayName(), t.Type(), t.Name(), err.Error())
+	}
+}
+
+// installedRecord returns the record of the installation of the feature with the variables, without the
+// secrets: the variables declared in key 'feature.secrets' of the specification file and the implicit ones
+func (f *Feature) installedRecord(v Variables, results Results) *providerapi.InstalledFeature {
+	secrets := map[string]bool{}
+	for _, key := range implicitSecrets {
+		secrets[key] = true
+	}
+	for _, key := range f.Specs().GetStringSlice("feature.secrets") {
+		secrets[key] = true
+	}
+
+	record := providerapi.InstalledFeature{
+		Name:      featureKey(f),
+		Version:   f.Specs().GetString("feature.version"),
+		Variables: map[string]string{},
+		Date:      time.Now(),
+		Hosts:     hostsOfResults(results),
+	}
+	for key, value := range v {
+		if secrets[key] {
+			continue
+		}
+		record.Variables[key] = fmt.Sprintf("%v", value)
+	}
+	return &record
+}
+
+// sameInstalled tells if the records describe the same installation, whatever their dates
+func sameInstalled(a, b *providerapi.InstalledFeature) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Name != b.Name || a.Version != b.Version || len(a.Variables) != len(b.Variables) || len(a.Hosts) != len(b.Hosts) {
+		return false
+	}
+	for key, value := range a.Variables {
+		if other, ok := b.Variables[key]; !ok || other != value {
+			return false
+		}
+	}
+	for host, ok := range a.Hosts {
+		if other, found := b.Hosts[host]; !found || other != ok {
+			return false
+		}
+	}
+	return true
+}
+
+// forgetInstalled removes the feature from the features recorded as installed on the target
+func (f *Feature) forgetInstalled(t Target) {
+	_, err := updateInstalled(t, func(features providerapi.InstalledFeatures) bool {
+		if _, ok := features[featureKey(f)]; !ok {
+			return false
+		}
+		delete(features, featureKey(f))
+		return true
+	})
+	if err != nil {
+		log.Printf("failed to record removal of feature '%s' from %s '%s': %s", f.DisplayName(), t.Type(), t.Name(), err.Error())
+	}
+}
+
+// hostsOfResults returns for each host of results if all the steps succeeded on it
+func hostsOfResults(results Results) map[string]bool {
+	hosts := map[string]bool{}
+	for _, step := range results {
+		for h, sr := range step {
+			ok, found := hosts[h]
+			hosts[h] = sr.Successful() && (ok || !found)
+		}
+	}
+	return hosts
+}
+
+// loadInstalled returns the features recorded as installed on the target
+func loadInstalled(t Target) (providerapi.InstalledFeatures, error) {
+	hT, cT, nT := determineContext(t)
+	if cT != nil {
+		return clusterInstalled(cT, nil)
+	}
+	if nT != nil {
+		hT = nT.HostTarget
+	}
+	return hostInstalled(hT, nil)
+}
+
+// installedLock serializes the updates of the features recorded as installed, made concurrently by the features
+// of a level of dependencies
+var installedLock sync.Mutex
+
+// updateInstalled applies 'fn' on the features recorded as installed on the target and saves them if 'fn'
+// tells it changed them
+func updateInstalled(t Target, fn func(providerapi.InstalledFeatures) bool) (providerapi.InstalledFeatures, error) {
+	installedLock.Lock()
+	defer installedLock.Unlock()
+
+	hT, cT, nT := determineContext(t)
+	if cT != nil {
+		return clusterInstalled(cT, fn)
+	}
+	if nT != nil {
+		hT = nT.HostTarget
+	}
+	return hostInstalled(hT, fn)
+}
+
+// hostInstalled reads the features recorded in the metadata of the host and, if 'fn' isn't nil, updates
+// them with 'fn' and saves them if they changed
+func hostInstalled(t *HostTarget, fn func(providerapi.InstalledFeatures) bool) (providerapi.InstalledFeatures, error) {
+	svc, err := provideruse.GetProviderService()
+	if err != nil {
+		return nil, err
+	}
+	m, err := metadata.LoadHostByID(svc, t.host.ID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, fmt.Errorf("failed to find metadata of host '%s'", t.host.Name)
+	}
+	if fn == nil {
+		return installedOf(m.Get().Extension[HostExtension.Features]), nil
+	}
+
+	err = m.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer m.Release()
+	// Reads again the metadata, they may have changed before the lock
+	found, err := m.ReadByID(t.host.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("metadata of host '%s' vanished", t.host.Name)
+	}
+	host := m.Get()
+	features := installedOf(host.Extension[HostExtension.Features])
+	if !fn(features) {
+		return features, nil
+	}
+	if host.Extension == nil {
+		host.Extension = providerapi.HostExtensionMap{}
+	}
+	host.Extension[HostExtension.Features] = features
+	return features, m.Write()
+}
+
+// clusterInstalled reads the features recorded in the metadata of the cluster and, if 'fn' isn't nil,
+// updates them with 'fn' and saves them if they changed
+func clusterInstalled(t *ClusterTarget, fn func(providerapi.InstalledFeatures) bool) (providerapi.InstalledFeatures, error) {
+	if fn == nil {
+		return installedOf(t.cluster.GetExtension(Extension.Features)), nil
+	}
+
+	name := t.cluster.GetName()
+	m, err := clustermetadata.NewCluster()
+	if err != nil {
+		return nil, err
+	}
+	found, err := m.Read(name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("failed to find metadata of cluster '%s'", name)
+	}
+	err = m.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer m.Release()
+	// Reads again the metadata, they may have changed before the lock
+	err = m.Reload()
+	if err != nil {
+		return nil, err
+	}
+	core := m.Get()
+	features := installedOf(core.GetExtension(Extension.Features))
+	if !fn(features) {
+		return features, nil
+	}
+	core.SetExtension(Extension.Features, features)
+	err = m.Write()
+	if err != nil {
+		return nil, err
+	}
+	// Keeps the cluster instance in sync with its metadata
+	t.cluster.SetExtension(Extension.Features, features)
+	return features, nil
+}
+
+// installedOf returns the content of the extension Features, initialized if empty
+func installedOf(anon interface{}) providerapi.InstalledFeatures {
+	if features, ok := anon.(providerapi.InstalledFeatures); ok && features != nil {
+		return features
+	}
+	return providerapi.InstalledFeatures{}
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	providerapi "github.com/CS-SI/SafeScale/providers/api"
+)
+
+const testSecretSpecs = `
+feature:
+  version: "1.0"
+  parameters:
+    - Username
+    - AdminToken
+    - DBPassword
+  secrets:
+    - AdminToken
+  install:
+    bash:
+      check: {}
+`
+
+func TestFeature_installedRecord(t *testing.T) {
+	f := testFeature(t, "secret", testSecretSpecs, nil)
+	results := Results{
+		"install": stepResults{"host-1": stepResult{success: true}, "host-2": stepResult{success: false}},
+	}
+
+	record := f.installedRecord(Variables{
+		"Username":   "cladm",
+		"AdminToken": "s3cr3t",
+		"Password":   "implicit",
+		"DBPassword": "declared as parameter only",
+		"Port":       8080,
+	}, results)
+	assert.Equal(t, "secret", record.Name)
+	assert.Equal(t, "1.0", record.Version)
+	// Only the secrets declared by the specification, and the implicit ones, are left out
+	assert.Equal(t, map[string]string{"Username": "cladm", "DBPassword": "declared as parameter only", "Port": "8080"}, record.Variables)
+	assert.Equal(t, map[string]bool{"host-1": true, "host-2": false}, record.Hosts)
+}
+
+func TestSameInstalled(t *testing.T) {
+	record := func() *providerapi.InstalledFeature {
+		return &providerapi.InstalledFeature{
+			Name:      "docker",
+			Version:   "18.06",
+			Variables: map[string]string{"Username": "cladm"},
+			Date:      time.Now(),
+			Hosts:     map[string]bool{"host-1": true},
+		}
+	}
+	a := record()
+	b := record()
+	b.Date = a.Date.Add(time.Hour)
+	assert.True(t, sameInstalled(a, b))
+	assert.True(t, sameInstalled(nil, nil))
+	assert.False(t, sameInstalled(a, nil))
+	assert.False(t, sameInstalled(nil, a))
+
+	b = record()
+	b.Version = "18.09"
+	assert.False(t, sameInstalled(a, b))
+	b = record()
+	b.Variables["Username"] = "gpac"
+	assert.False(t, sameInstalled(a, b))
+	b = record()
+	b.Variables["Port"] = "80"
+	assert.False(t, sameInstalled(a, b))
+	b = record()
+	b.Hosts["host-1"] = false
+	assert.False(t, sameInstalled(a, b))
+	b = record()
+	b.Hosts = map[string]bool{"host-2": true}
+	assert.False(t, sameInstalled(a, b))
+
+	// Empty maps are lost when metadata are serialized
+	a.Variables, b = map[string]string{}, record()
+	b.Variables = nil
+	b.Hosts = a.Hosts
+	assert.True(t, sameInstalled(a, b))
+}
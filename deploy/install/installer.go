@@ -30,5 +30,14 @@ type Installer interface {
 	Remove(*Feature, Target, Variables, Settings) (Results, error)
 }
 
+// Upgrader defines the API of an Installer able to upgrade a feature already installed, and to
+// roll it back
+type Upgrader interface {
+	// Upgrade executes upgrade of feature
+	Upgrade(*Feature, Target, Variables, Settings) (Results, error)
+	// Rollback executes rollback of feature to a previous revision
+	Rollback(*Feature, Target, Variables, Settings) (Results, error)
+}
+
 // installerMap keeps a map of available installers sorted by Method
 type installerMap map[Method.Enum]Installer
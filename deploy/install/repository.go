@@ -0,0 +1,493 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// RepositoryKindGit is the kind of a repository cloned from git
+	RepositoryKindGit = "git"
+	// RepositoryKindHTTP is the kind of a repository described by an index file served over HTTP
+	RepositoryKindHTTP = "http"
+
+	// repositoryChecksumsFile is the name of the file of a git repository containing the checksums of
+	// all the specification files, in the format of sha256sum
+	repositoryChecksumsFile = "SHA256SUMS"
+	// repositoryHTTPTimeout is the maximum time to download a file of an HTTP repository
+	repositoryHTTPTimeout = 1 * time.Minute
+)
+
+var (
+	repositoryNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+)
+
+// Repository is a remote repository of feature specification files, cached locally.
+// A git repository is cloned in cache; an HTTP repository is an index file in JSON listing the
+// specification files and their checksums:
+//
+//	{
+//	    "revision": "2018.10",
+//	    "features": [
+//	        {"name": "myfeature", "file": "myfeature.yml", "sha256": "<checksum of myfeature.yml>"}
+//	    ]
+//	}
+type Repository struct {
+	// Name is the name of the repository
+	Name string `json:"name"`
+	// Kind is the kind of repository (git or http)
+	Kind string `json:"kind"`
+	// URL is the URL of the git repository, or of the index file of the HTTP repository
+	URL string `json:"url"`
+	// Revision is the revision pinned (git commit, tag or branch, or revision of the HTTP index); empty
+	// to follow the latest one
+	Revision string `json:"revision,omitempty"`
+	// Path is the folder of the git repository containing the specification files
+	Path string `json:"path,omitempty"`
+	// Current is the revision in cache
+	Current string `json:"current,omitempty"`
+	// UpdatedAt is the date of the last update of the cache
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// repositoryIndex is the content of the index file of an HTTP repository
+type repositoryIndex struct {
+	Revision string `json:"revision"`
+	Features []struct {
+		Name   string `json:"name"`
+		File   string `json:"file"`
+		SHA256 string `json:"sha256"`
+	} `json:"features"`
+}
+
+// repositoriesFile returns the path of the file containing the repositories configured
+func repositoriesFile() string {
+	return os.ExpandEnv("$HOME/.safescale/repositories.json")
+}
+
+// repositoriesCache returns the path of the folder containing the caches of the repositories
+func repositoriesCache() string {
+	return os.ExpandEnv("$HOME/.safescale/cache/features")
+}
+
+// ListRepositories returns the feature repositories configured, in the order they are searched
+func ListRepositories() ([]*Repository, error) {
+	list := []*Repository{}
+	content, err := ioutil.ReadFile(repositoriesFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return list, nil
+		}
+		return nil, fmt.Errorf("failed to read feature repositories: %s", err.Error())
+	}
+	err = json.Unmarshal(content, &list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feature repositories from '%s': %s", repositoriesFile(), err.Error())
+	}
+	return list, nil
+}
+
+// saveRepositories writes the repositories configured
+func saveRepositories(list []*Repository) error {
+	content, err := json.MarshalIndent(list, "", "    ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(repositoriesFile()), 0700)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(repositoriesFile(), content, 0600)
+}
+
+// AddRepository declares a new feature repository and fills its cache.
+// The repository is an HTTP one if 'rawURL' designates a JSON index file, a git one otherwise
+func AddRepository(name, rawURL, revision, path string) (*Repository, error) {
+	if !repositoryNameRegexp.MatchString(name) {
+		return nil, fmt.Errorf("invalid repository name '%s'", name)
+	}
+	list, err := ListRepositories()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range list {
+		if r.Name == name {
+			return nil, fmt.Errorf("a feature repository named '%s' already exists", name)
+		}
+	}
+
+	r := &Repository{
+		Name:     name,
+		Kind:     RepositoryKindGit,
+		URL:      rawURL,
+		Revision: revision,
+		Path:     path,
+	}
+	if strings.HasSuffix(strings.ToLower(rawURL), ".json") {
+		if path != "" {
+			return nil, fmt.Errorf("a path can't be used with an HTTP repository")
+		}
+		r.Kind = RepositoryKindHTTP
+	}
+	os.RemoveAll(r.dir())
+	err = r.update()
+	if err != nil {
+		os.RemoveAll(r.dir())
+		return nil, err
+	}
+	return r, saveRepositories(append(list, r))
+}
+
+// UpdateRepositories refreshes the cache of the repositories named, or of all the repositories if no
+// name is given
+func UpdateRepositories(names ...string) ([]*Repository, error) {
+	list, err := ListRepositories()
+	if err != nil {
+		return nil, err
+	}
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	updated := []*Repository{}
+	errors := []string{}
+	for _, r := range list {
+		if len(wanted) > 0 && !wanted[r.Name] {
+			continue
+		}
+		delete(wanted, r.Name)
+		err := r.update()
+		if err != nil {
+			errors = append(errors, err.Error())
+			continue
+		}
+		updated = append(updated, r)
+	}
+	for name := range wanted {
+		errors = append(errors, fmt.Sprintf("failed to find a feature repository named '%s'", name))
+	}
+
+	err = saveRepositories(list)
+	if err != nil {
+		return updated, err
+	}
+	if len(errors) > 0 {
+		return updated, fmt.Errorf("%s", strings.Join(errors, "\n"))
+	}
+	return updated, nil
+}
+
+// repositorySearchPaths returns the folders of the caches of the repositories containing specification
+// files, in the order of the repositories
+func repositorySearchPaths() []string {
+	paths := []string{}
+	list, err := ListRepositories()
+	if err != nil {
+		log.Println(err.Error())
+		return paths
+	}
+	for _, r := range list {
+		paths = append(paths, r.featuresDir())
+	}
+	return paths
+}
+
+// repositoryOf returns the name of the repository whose cache contains the file, or "" if none does
+func repositoryOf(file string) string {
+	list, err := ListRepositories()
+	if err != nil {
+		return ""
+	}
+	for _, r := range list {
+		if strings.HasPrefix(file, r.dir()+string(filepath.Separator)) {
+			return r.Name
+		}
+	}
+	return ""
+}
+
+// dir returns the folder of the cache of the repository
+func (r *Repository) dir() string {
+	return filepath.Join(repositoriesCache(), r.Name)
+}
+
+// featuresDir returns the folder of the cache containing the specification files
+func (r *Repository) featuresDir() string {
+	if r.Path == "" {
+		return r.dir()
+	}
+	return filepath.Join(r.dir(), filepath.Clean("/"+r.Path))
+}
+
+// update refreshes the cache of the repository
+func (r *Repository) update() error {
+	var err error
+	switch r.Kind {
+	case RepositoryKindGit:
+		err = r.updateGit()
+	case RepositoryKindHTTP:
+		err = r.updateHTTP()
+	default:
+		err = fmt.Errorf("unknown kind '%s'", r.Kind)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update feature repository '%s': %s", r.Name, err.Error())
+	}
+	r.UpdatedAt = time.Now()
+	return nil
+}
+
+// updateGit clones or fetches the git repository, then checks out the revision pinned (or the latest
+// one of the default branch) and verifies the checksums of the specification files.
+// A new clone whose content can't be verified is removed
+func (r *Repository) updateGit() error {
+	dir := r.dir()
+	previous := ""
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		previous, err = git(dir, "rev-parse", "HEAD")
+		if err != nil {
+			return err
+		}
+		_, err = git(dir, "fetch", "--quiet", "--tags", "origin")
+		if err != nil {
+			return err
+		}
+	} else {
+		err := os.MkdirAll(filepath.Dir(dir), 0700)
+		if err != nil {
+			return err
+		}
+		// '--' prevents an URL starting with '-' from being taken as option
+		_, err = git("", "clone", "--quiet", "--", r.URL, dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	commit, err := r.resolveGitRevision()
+	if err != nil {
+		return err
+	}
+	_, err = git(dir, "checkout", "--quiet", "--detach", commit)
+	if err != nil {
+		return err
+	}
+	err = verifyChecksums(r.featuresDir())
+	if err != nil {
+		// Goes back to the content verified before
+		if previous != "" {
+			git(dir, "checkout", "--quiet", "--detach", previous)
+		} else {
+			os.RemoveAll(dir)
+		}
+		return err
+	}
+	r.Current = commit
+	return nil
+}
+
+// resolveGitRevision returns the commit corresponding to the revision pinned, searched as remote branch
+// first, then as tag or commit
+func (r *Repository) resolveGitRevision() (string, error) {
+	if r.Revision == "" {
+		return git(r.dir(), "rev-parse", "--verify", "--quiet", "origin/HEAD^{commit}")
+	}
+	commit, err := git(r.dir(), "rev-parse", "--verify", "--quiet", "origin/"+r.Revision+"^{commit}")
+	if err == nil {
+		return commit, nil
+	}
+	commit, err = git(r.dir(), "rev-parse", "--verify", "--quiet", r.Revision+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("revision '%s' not found", r.Revision)
+	}
+	return commit, nil
+}
+
+// git runs a git command in the folder 'dir' and returns its output
+func git(dir string, args ...string) (string, error) {
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s failed: %s", args[len(args)-1], msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// verifyChecksums checks the files listed in the file SHA256SUMS of the folder, which is required and
+// must list all the specification files of the folder
+func verifyChecksums(dir string) error {
+	f, err := os.Open(filepath.Join(dir, repositoryChecksumsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no file %s to verify the specification files", repositoryChecksumsFile)
+		}
+		return err
+	}
+	defer f.Close()
+
+	listed := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		// sha256sum marks files read in binary mode with '*'
+		file := strings.TrimPrefix(fields[1], "*")
+		path := filepath.Join(dir, filepath.Clean("/"+file))
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if checksum(content) != strings.ToLower(fields[0]) {
+			return fmt.Errorf("checksum mismatch for file '%s'", file)
+		}
+		listed[path] = true
+	}
+	err = scanner.Err()
+	if err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.yml"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if !listed[file] {
+			return fmt.Errorf("file '%s' isn't listed in %s", filepath.Base(file), repositoryChecksumsFile)
+		}
+	}
+	return nil
+}
+
+// updateHTTP downloads the index of the HTTP repository and the specification files it lists, verifying
+// their checksums, then replaces the cache with them
+func (r *Repository) updateHTTP() error {
+	base, err := url.Parse(r.URL)
+	if err != nil {
+		return err
+	}
+	content, err := download(r.URL)
+	if err != nil {
+		return err
+	}
+	index := repositoryIndex{}
+	err = json.Unmarshal(content, &index)
+	if err != nil {
+		return fmt.Errorf("invalid index '%s': %s", r.URL, err.Error())
+	}
+	if r.Revision != "" && index.Revision != r.Revision {
+		return fmt.Errorf("revision of index is '%s' instead of '%s'", index.Revision, r.Revision)
+	}
+
+	// Files are downloaded in a new folder, which replaces the cache only if everything went well
+	err = os.MkdirAll(repositoriesCache(), 0700)
+	if err != nil {
+		return err
+	}
+	tmpDir, err := ioutil.TempDir(repositoriesCache(), "."+r.Name)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	for _, entry := range index.Features {
+		if !repositoryNameRegexp.MatchString(entry.Name) {
+			return fmt.Errorf("invalid feature name '%s' in index", entry.Name)
+		}
+		if entry.SHA256 == "" {
+			return fmt.Errorf("no checksum for feature '%s' in index", entry.Name)
+		}
+		file := entry.File
+		if file == "" {
+			file = entry.Name + ".yml"
+		}
+		ref, err := url.Parse(file)
+		if err != nil {
+			return err
+		}
+		content, err := download(base.ResolveReference(ref).String())
+		if err != nil {
+			return err
+		}
+		if checksum(content) != strings.ToLower(entry.SHA256) {
+			return fmt.Errorf("checksum mismatch for feature '%s'", entry.Name)
+		}
+		err = ioutil.WriteFile(filepath.Join(tmpDir, entry.Name+".yml"), content, 0600)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.RemoveAll(r.dir())
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmpDir, r.dir())
+	if err != nil {
+		return err
+	}
+	r.Current = index.Revision
+	return nil
+}
+
+// download returns the content of the file at the URL
+func download(rawURL string) ([]byte, error) {
+	client := http.Client{Timeout: repositoryHTTPTimeout}
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download '%s': %s", rawURL, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// checksum returns the SHA256 checksum of the content, in hexadecimal
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
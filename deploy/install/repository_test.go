@@ -0,0 +1,127 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package install
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSpecContent = "---\nfeature:\n  name: Test\n"
+
+// writeTestFiles writes the files in dir, by name
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		require.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safescale-repository-test-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	sum := checksum([]byte(testSpecContent))
+
+	// SHA256SUMS is required
+	writeTestFiles(t, dir, map[string]string{"test.yml": testSpecContent})
+	assert.EqualError(t, verifyChecksums(dir), "no file SHA256SUMS to verify the specification files")
+
+	writeTestFiles(t, dir, map[string]string{repositoryChecksumsFile: sum + " *test.yml\n"})
+	assert.Nil(t, verifyChecksums(dir))
+
+	// A specification file not listed is refused
+	writeTestFiles(t, dir, map[string]string{"other.yml": testSpecContent})
+	assert.EqualError(t, verifyChecksums(dir), "file 'other.yml' isn't listed in SHA256SUMS")
+
+	writeTestFiles(t, dir, map[string]string{repositoryChecksumsFile: sum + "  test.yml\n" + sum + "  other.yml\n"})
+	assert.Nil(t, verifyChecksums(dir))
+
+	writeTestFiles(t, dir, map[string]string{"other.yml": testSpecContent + "  version: 2\n"})
+	assert.EqualError(t, verifyChecksums(dir), "checksum mismatch for file 'other.yml'")
+
+	// Files listed are searched in the folder only
+	writeTestFiles(t, dir, map[string]string{repositoryChecksumsFile: sum + "  ../test.yml\n"})
+	assert.EqualError(t, verifyChecksums(dir), "file 'other.yml' isn't listed in SHA256SUMS")
+}
+
+// newTestGitRepository creates a git repository in dir with the files committed
+func newTestGitRepository(t *testing.T, dir string, files map[string]string) {
+	run := func(args ...string) {
+		args = append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		out, err := exec.Command("git", args...).CombinedOutput()
+		require.Nil(t, err, string(out))
+	}
+	run("init", "--quiet")
+	writeTestFiles(t, dir, files)
+	run("add", ".")
+	run("commit", "--quiet", "-m", "features")
+}
+
+func TestRepository_updateGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	home, err := ioutil.TempDir("", "safescale-repository-test-")
+	require.Nil(t, err)
+	defer os.RemoveAll(home)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	sum := checksum([]byte(testSpecContent))
+	origin := filepath.Join(home, "origin")
+	require.Nil(t, os.Mkdir(origin, 0700))
+	newTestGitRepository(t, origin, map[string]string{
+		"test.yml":              testSpecContent,
+		repositoryChecksumsFile: fmt.Sprintf("%s  test.yml\n", sum),
+	})
+	r := &Repository{Name: "verified", Kind: RepositoryKindGit, URL: origin}
+	require.Nil(t, r.updateGit())
+	assert.NotEmpty(t, r.Current)
+	_, err = os.Stat(filepath.Join(r.dir(), "test.yml"))
+	assert.Nil(t, err)
+
+	// A clone with a specification file not listed is removed
+	unverified := filepath.Join(home, "unverified")
+	require.Nil(t, os.Mkdir(unverified, 0700))
+	newTestGitRepository(t, unverified, map[string]string{
+		"test.yml":              testSpecContent,
+		"other.yml":             testSpecContent,
+		repositoryChecksumsFile: fmt.Sprintf("%s  test.yml\n", sum),
+	})
+	r = &Repository{Name: "unverified", Kind: RepositoryKindGit, URL: unverified}
+	assert.NotNil(t, r.updateGit())
+	_, err = os.Stat(r.dir())
+	assert.True(t, os.IsNotExist(err))
+
+	// An URL can't be taken as option of git: git would clone the folder of the cache, made a bare
+	// repository, running the command given as upload-pack
+	marker := filepath.Join(home, "marker")
+	r = &Repository{Name: "option", Kind: RepositoryKindGit, URL: "--upload-pack=touch " + marker + ";"}
+	out, err := exec.Command("git", "init", "--quiet", "--bare", r.dir()).CombinedOutput()
+	require.Nil(t, err, string(out))
+	assert.NotNil(t, r.updateGit())
+	_, err = os.Stat(marker)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -23,9 +23,9 @@ import (
 	"time"
 
 	pb "github.com/CS-SI/SafeScale/broker"
-	brokerclient "github.com/CS-SI/SafeScale/broker/client"
 
 	"github.com/CS-SI/SafeScale/deploy/install/enums/Action"
+	"github.com/CS-SI/SafeScale/deploy/install/enums/Method"
 )
 
 const (
@@ -229,6 +229,11 @@ func (is *step) Run(hosts []*pb.Host, v Variables, s Settings) (stepResults, err
 		log.Printf("running step '%s' on %d hosts...", is.Name, len(hosts))
 	}
 
+	// Ansible runs the step on all the hosts with a single playbook
+	if is.Worker.method == Method.Ansible {
+		return is.runWithAnsible(hosts, v, s)
+	}
+
 	results := stepResults{}
 
 	if is.Serial || s.Serialize {
@@ -308,7 +313,7 @@ func (is *step) runOnHost(host *pb.Host, v Variables) stepResult {
 	}
 
 	// Executes the script on the remote host
-	retcode, _, _, err := brokerclient.New().Ssh.Run(host.Name, command, brokerclient.DefaultConnectionTimeout, is.WallTime)
+	retcode, _, _, err := ExecuteScriptOnHost(host.Name, command, is.WallTime)
 	if err != nil {
 		return stepResult{success: false, err: err}
 	}
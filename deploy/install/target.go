@@ -78,6 +78,8 @@ func createHostTarget(host *pb.Host) *HostTarget {
 	//}
 	index++
 	methods[index] = Method.Bash
+	index++
+	methods[index] = Method.Ansible
 	return &HostTarget{
 		host:    host,
 		methods: methods,
@@ -101,8 +103,7 @@ func (t *HostTarget) Methods() map[uint8]Method.Enum {
 
 // Installed returns a list of installed features
 func (t *HostTarget) Installed() []string {
-	var list []string
-	return list
+	return installedNames(t)
 }
 
 // ClusterTarget defines a target of type Host, satisfying TargetAPI
@@ -120,12 +121,20 @@ func NewClusterTarget(cluster clusterapi.Cluster) Target {
 		index   uint8
 		methods = map[uint8]Method.Enum{}
 	)
-	if cluster.GetConfig().Flavor == Flavor.DCOS {
+	switch cluster.GetConfig().Flavor {
+	case Flavor.DCOS:
 		index++
 		methods[index] = Method.DCOS
+		index++
+		methods[index] = Method.Helm
+	case Flavor.K8S:
+		index++
+		methods[index] = Method.Helm
 	}
 	index++
 	methods[index] = Method.Bash
+	index++
+	methods[index] = Method.Ansible
 	return &ClusterTarget{
 		cluster: cluster,
 		methods: methods,
@@ -149,8 +158,7 @@ func (t *ClusterTarget) Methods() map[uint8]Method.Enum {
 
 // Installed returns a list of installed feature
 func (t *ClusterTarget) Installed() []string {
-	var list []string
-	return list
+	return installedNames(t)
 }
 
 // NodeTarget defines a target of type Node of cluster, including a master
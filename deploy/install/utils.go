@@ -19,8 +19,10 @@ package install
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
@@ -52,6 +54,12 @@ exec 2>&1
 
 var (
 	featureScriptTemplate *template.Template
+
+	// ScriptOutput receives the outputs of the scripts executed on remote hosts as they are produced, each line
+	// prefixed by the name of the host; nil discards them
+	ScriptOutput io.Writer
+	// scriptOutputLock serializes the lines written to ScriptOutput by scripts running in parallel
+	scriptOutputLock sync.Mutex
 )
 
 // parseTargets validates targets on the cluster from the feature specification
@@ -236,6 +244,59 @@ func UploadStringToRemoteFile(content string, host *pb.Host, filename string, ow
 	return nil
 }
 
+// ExecuteScriptOnHost executes the command on the remote host through broker, copying its outputs to ScriptOutput
+// as they are produced
+// Returns the exit status, the standard output and the error output of the command
+func ExecuteScriptOnHost(hostName, command string, timeout time.Duration) (int, string, string, error) {
+	var stdout, stderr bytes.Buffer
+	outWriter := &prefixWriter{prefix: hostName, buffer: &stdout}
+	errWriter := &prefixWriter{prefix: hostName, buffer: &stderr}
+	retcode, err := brokerclient.New().Ssh.Execute(hostName, command, nil, outWriter, errWriter, timeout)
+	outWriter.flush()
+	errWriter.flush()
+	return retcode, stdout.String(), stderr.String(), err
+}
+
+// prefixWriter collects what is written in buffer, and copies each complete line to ScriptOutput prefixed by
+// the name of the host
+type prefixWriter struct {
+	prefix string
+	buffer *bytes.Buffer
+	line   []byte
+}
+
+// Write implements io.Writer
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buffer.Write(p)
+	if ScriptOutput == nil {
+		return len(p), nil
+	}
+	w.line = append(w.line, p...)
+	for {
+		i := bytes.IndexByte(w.line, '\n')
+		if i < 0 {
+			break
+		}
+		w.output(string(w.line[:i]))
+		w.line = w.line[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush outputs the last line if it doesn't end with a newline
+func (w *prefixWriter) flush() {
+	if len(w.line) > 0 {
+		w.output(string(w.line))
+		w.line = nil
+	}
+}
+
+func (w *prefixWriter) output(line string) {
+	scriptOutputLock.Lock()
+	defer scriptOutputLock.Unlock()
+	fmt.Fprintf(ScriptOutput, "[%s] %s\n", w.prefix, line)
+}
+
 // normalizeScript envelops the script with log redirection to /var/tmp/feature.<name>.<action>.log
 // and ensures BashLibrary are there
 func normalizeScript(params map[string]interface{}) (string, error) {
@@ -40,6 +40,7 @@ const (
 	yamlTargetsKeyword  = "targets"
 	yamlRunKeyword      = "run"
 	yamlPackageKeyword  = "package"
+	yamlTasksKeyword    = "tasks"
 	yamlOptionsKeyword  = "options"
 	yamlWallTimeKeyword = "wallTime"
 	yamlSerialKeyword   = "serialized"
@@ -421,6 +422,8 @@ func (w *worker) Proceed(v Variables, s Settings) (Results, error) {
 			fallthrough
 		case Method.Dnf:
 			keyword = yamlPackageKeyword
+		case Method.Ansible:
+			keyword = yamlTasksKeyword
 		}
 		anon, ok = stepMap[keyword]
 		if ok {
@@ -431,7 +434,7 @@ func (w *worker) Proceed(v Variables, s Settings) (Results, error) {
 			}
 		} else {
 			msg := `syntax error in feature '%s' specification file (%s): no key '%s.%s' found`
-			return nil, fmt.Errorf(msg, w.feature.DisplayName(), w.feature.DisplayFilename(), stepKey, yamlRunKeyword)
+			return nil, fmt.Errorf(msg, w.feature.DisplayName(), w.feature.DisplayFilename(), stepKey, keyword)
 		}
 
 		// If there is an options file (for now specific to DCOS), upload it to the remote host
@@ -480,14 +483,18 @@ func (w *worker) Proceed(v Variables, s Settings) (Results, error) {
 			wallTime = 5
 		}
 
-		templateCommand, err := normalizeScript(Variables{
-			"reserved_Name":    w.feature.BaseFilename(),
-			"reserved_Content": runContent,
-			"reserved_Action":  strings.ToLower(w.action.String()),
-			"reserved_Step":    k,
-		})
-		if err != nil {
-			return nil, err
+		// Ansible tasks are put in a playbook as is, scripts are enveloped
+		templateCommand := runContent
+		if w.method != Method.Ansible {
+			templateCommand, err = normalizeScript(Variables{
+				"reserved_Name":    w.feature.BaseFilename(),
+				"reserved_Content": runContent,
+				"reserved_Action":  strings.ToLower(w.action.String()),
+				"reserved_Step":    k,
+			})
+			if err != nil {
+				return nil, err
+			}
 		}
 
 		// Checks if step can be performed in parallel on selected hosts
@@ -17,6 +17,8 @@
 package api
 
 import (
+	"context"
+	"encoding/gob"
 	"fmt"
 	"io"
 	"os"
@@ -26,6 +28,7 @@ import (
 	"github.com/CS-SI/SafeScale/providers/enums/HostExtension"
 	"github.com/CS-SI/SafeScale/providers/enums/HostState"
 	"github.com/CS-SI/SafeScale/providers/enums/IPVersion"
+	"github.com/CS-SI/SafeScale/providers/enums/RuleDirection"
 	"github.com/CS-SI/SafeScale/providers/enums/VolumeSpeed"
 	"github.com/CS-SI/SafeScale/providers/enums/VolumeState"
 
@@ -106,12 +109,48 @@ type Host struct {
 	Size         HostSize       `json:"size,omitempty"`
 	State        HostState.Enum `json:"state,omitempty"`
 	PrivateKey   string         `json:"private_key,omitempty"`
-	GatewayID    string         `json:"gateway_id,omitempty"`
+	// HostKey is the public key of the SSH server of the host, pinned at its first connection
+	HostKey   string `json:"host_key,omitempty"`
+	GatewayID string `json:"gateway_id,omitempty"`
+	// Tags are key/value pairs set by the user to sort the hosts (project, owner, ...)
+	Tags map[string]string `json:"tags,omitempty"`
 	// This field can contain any kind of supplemental information that will be stored as-is
 	// functions using it should know how to exploit this
 	Extension HostExtensionMap `json:"extensions,omitempty"`
 }
 
+// InstalledFeature records the outcome of the installation of a feature on a host or a cluster
+type InstalledFeature struct {
+	// Name is the name of the feature
+	Name string `json:"name"`
+	// Version is the version of the feature, if its specification file declares one
+	Version string `json:"version,omitempty"`
+	// Variables contains the parameters given to the installation, except the secrets
+	Variables map[string]string `json:"variables,omitempty"`
+	// Date is the date of the installation, or of its last check
+	Date time.Time `json:"date"`
+	// Hosts contains the result of the installation on each host concerned, by host name
+	Hosts map[string]bool `json:"hosts,omitempty"`
+}
+
+// Successful tells if the feature is installed on all the hosts concerned
+func (f *InstalledFeature) Successful() bool {
+	for _, ok := range f.Hosts {
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// InstalledFeatures contains the features installed on a host or a cluster, by name; it is
+// stored in extension Features of hosts and clusters
+type InstalledFeatures map[string]*InstalledFeature
+
+func init() {
+	gob.Register(InstalledFeatures{})
+}
+
 // GetAccessIP computes access IP of the host
 func (host *Host) GetAccessIP() string {
 	ip := host.AccessIPv4
@@ -162,6 +201,9 @@ type HostRequest struct {
 	//ImageID  is the UUID of the image that contains the server's OS and initial state.
 	ImageID string   `json:"image_id,omitempty"`
 	KeyPair *KeyPair `json:"key_pair,omitempty"`
+	//SecurityGroups contains the IDs of the security groups bound to the host; if empty, the default security group
+	//of the driver (opening all ports) is used
+	SecurityGroups []string `json:"security_groups,omitempty"`
 }
 
 // GWRequest to create a Gateway into a network
@@ -174,15 +216,19 @@ type GWRequest struct {
 	KeyPair *KeyPair `json:"key_pair,omitempty"`
 	//GWName is the name to give to the gateway
 	GWName string `json:"gw_name,omitempty"`
+	//SecurityGroups contains the IDs of the security groups bound to the gateway; if empty, the default security group
+	//of the driver (opening all ports) is used
+	SecurityGroups []string `json:"security_groups,omitempty"`
 }
 
 // Volume represents a block volume
 type Volume struct {
-	ID    string           `json:"id,omitempty"`
-	Name  string           `json:"name,omitempty"`
-	Size  int              `json:"size,omitempty"`
-	Speed VolumeSpeed.Enum `json:"speed,omitempty"`
-	State VolumeState.Enum `json:"state,omitempty"`
+	ID    string            `json:"id,omitempty"`
+	Name  string            `json:"name,omitempty"`
+	Size  int               `json:"size,omitempty"`
+	Speed VolumeSpeed.Enum  `json:"speed,omitempty"`
+	State VolumeState.Enum  `json:"state,omitempty"`
+	Tags  map[string]string `json:"tags,omitempty"`
 }
 
 // VolumeRequest represents a volume request
@@ -192,6 +238,22 @@ type VolumeRequest struct {
 	Speed VolumeSpeed.Enum `json:"speed,omitempty"`
 }
 
+// Snapshot represents a point-in-time copy of a volume, from which new volumes can be created
+type Snapshot struct {
+	ID        string           `json:"id,omitempty"`
+	Name      string           `json:"name,omitempty"`
+	VolumeID  string           `json:"volume_id,omitempty"`
+	Size      int              `json:"size,omitempty"`
+	State     VolumeState.Enum `json:"state,omitempty"`
+	CreatedAt time.Time        `json:"created_at,omitempty"`
+}
+
+// SnapshotRequest represents a snapshot request
+type SnapshotRequest struct {
+	Name     string `json:"name,omitempty"`
+	VolumeID string `json:"volume_id,omitempty"`
+}
+
 //VolumeAttachment represents a volume attachment
 type VolumeAttachment struct {
 	ID         string `json:"id,omitempty"`
@@ -217,6 +279,37 @@ type Nas struct {
 	Host     string `json:"host,omitempty"`
 	Path     string `json:"path,omitempty"`
 	IsServer bool   `json:"isServer,omitempty"`
+	// Tags are only set on the server side of the nas
+	Tags map[string]string `json:"tags,omitempty"`
+	// ACLs restrict the access to the exported directory, they are only set on the server side of the nas
+	// Without ACL, every host may mount the directory read-write
+	ACLs []NasACL `json:"acls,omitempty"`
+	// ReadOnly and SecurityMode are the options of the mount, they are only set on the client side of the nas
+	ReadOnly     bool   `json:"readOnly,omitempty"`
+	SecurityMode string `json:"securityMode,omitempty"`
+	// Secondary is the host replicating the storage of the server side of a highly available nas
+	Secondary string `json:"secondary,omitempty"`
+	// VIP is the virtual IP address held by the active server of a highly available nas, clients mount through it
+	VIP *VIP `json:"vip,omitempty"`
+	// Volumes are the names of the volumes replicated between the servers of a highly available nas
+	Volumes []string `json:"volumes,omitempty"`
+	// HAID identifies a highly available nas among the others, from 1 to 255 (cf. nfs.HAConfig.ID)
+	HAID int `json:"haID,omitempty"`
+}
+
+//NasACL is a rule of access to the directory exported by a nas
+type NasACL struct {
+	// Host is the pattern of the hosts concerned: address, CIDR, name or wildcard (cf. exports man page)
+	Host string `json:"host,omitempty"`
+	// SecurityModes are the security flavors accepted ('sys', 'krb5', 'krb5i', 'krb5p'), 'sys' if empty
+	SecurityModes  []string `json:"securityModes,omitempty"`
+	ReadOnly       bool     `json:"readOnly,omitempty"`
+	NoRootSquash   bool     `json:"noRootSquash,omitempty"`
+	Secure         bool     `json:"secure,omitempty"`
+	Async          bool     `json:"async,omitempty"`
+	NoSubtreeCheck bool     `json:"noSubtreeCheck,omitempty"`
+	AnonUID        int      `json:"anonUID,omitempty"`
+	AnonGID        int      `json:"anonGID,omitempty"`
 }
 
 //Image representes an OS image
@@ -259,7 +352,51 @@ type Network struct {
 	//Mask mask in CIDR notation
 	CIDR string `json:"mask,omitempty"`
 	// //Gateway network gateway
-	GatewayID string `json:"gwid,omitempty"`
+	GatewayID string            `json:"gwid,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+// SecurityGroupRule represents a rule of a security group, allowing a kind of traffic
+type SecurityGroupRule struct {
+	ID string `json:"id,omitempty"`
+	//Direction tells if the rule concerns incoming or outgoing traffic (see RuleDirection)
+	Direction RuleDirection.Enum `json:"direction,omitempty"`
+	//IPVersion is IPv4 or IPv6 (see IPVersion)
+	IPVersion IPVersion.Enum `json:"ip_version,omitempty"`
+	//Protocol is tcp, udp or icmp; empty means all protocols
+	Protocol string `json:"protocol,omitempty"`
+	//PortFrom and PortTo define the range of ports allowed, 0 means all ports
+	PortFrom int `json:"port_from,omitempty"`
+	PortTo   int `json:"port_to,omitempty"`
+	//CIDR is the range of remote addresses allowed, in CIDR notation
+	CIDR string `json:"cidr,omitempty"`
+}
+
+// SecurityGroup represents a set of rules filtering the traffic of the hosts bound to it
+type SecurityGroup struct {
+	ID          string              `json:"id,omitempty"`
+	Name        string              `json:"name,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Rules       []SecurityGroupRule `json:"rules,omitempty"`
+}
+
+// SecurityGroupRequest represents the requirements to create a security group
+type SecurityGroupRequest struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	//NetworkID is the network in which the group is created, for the drivers binding security groups to networks
+	NetworkID string `json:"network_id,omitempty"`
+}
+
+// VIP represents a virtual IP address reserved in a network, which may be held by any of the hosts bound to it
+// (by keepalived for example)
+type VIP struct {
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	NetworkID string `json:"network_id,omitempty"`
+	PrivateIP string `json:"private_ip,omitempty"`
+	//Hosts contains the IDs of the hosts bound to the VIP
+	Hosts []string `json:"hosts,omitempty"`
 }
 
 /*
@@ -368,6 +505,28 @@ type ClientAPI interface {
 	// DeleteGateway delete the public gateway of a private network
 	DeleteGateway(networkID string) error
 
+	// CreateSecurityGroup creates a security group without rule
+	CreateSecurityGroup(request SecurityGroupRequest) (*SecurityGroup, error)
+	// GetSecurityGroup returns the security group identified by ref (id or name)
+	GetSecurityGroup(ref string) (*SecurityGroup, error)
+	// ListSecurityGroups lists available security groups
+	ListSecurityGroups() ([]SecurityGroup, error)
+	// DeleteSecurityGroup deletes the security group identified by id
+	DeleteSecurityGroup(id string) error
+	// AddSecurityGroupRule adds a rule to the security group identified by groupID
+	AddSecurityGroupRule(groupID string, rule SecurityGroupRule) (*SecurityGroupRule, error)
+	// DeleteSecurityGroupRule deletes the rule identified by ruleID from the security group identified by groupID
+	DeleteSecurityGroupRule(groupID string, ruleID string) error
+
+	// CreateVIP reserves a virtual IP address in the network identified by networkID
+	CreateVIP(networkID string, name string) (*VIP, error)
+	// BindHostToVIP allows the host identified by hostID to hold the virtual IP address
+	BindHostToVIP(vip *VIP, hostID string) error
+	// UnbindHostFromVIP stops allowing the host identified by hostID to hold the virtual IP address
+	UnbindHostFromVIP(vip *VIP, hostID string) error
+	// DeleteVIP releases the virtual IP address
+	DeleteVIP(vip *VIP) error
+
 	// CreateHost creates an host that fulfils the request
 	CreateHost(request HostRequest) (*Host, error)
 	// GetHost returns the host identified by id
@@ -397,6 +556,18 @@ type ClientAPI interface {
 	// DeleteVolume deletes the volume identified by id
 	DeleteVolume(id string) error
 
+	// CreateVolumeSnapshot creates a snapshot of the volume request.VolumeID, even if the volume is attached
+	CreateVolumeSnapshot(request SnapshotRequest) (*Snapshot, error)
+	// GetVolumeSnapshot returns the snapshot identified by id
+	GetVolumeSnapshot(id string) (*Snapshot, error)
+	// ListVolumeSnapshots lists the snapshots of the volume identified by volumeID, or all the snapshots if volumeID is empty
+	ListVolumeSnapshots(volumeID string) ([]Snapshot, error)
+	// DeleteVolumeSnapshot deletes the snapshot identified by id
+	DeleteVolumeSnapshot(id string) error
+	// CreateVolumeFromSnapshot creates a new volume with the content of the snapshot identified by snapshotID
+	// request.Size may be 0 to use the size of the snapshot
+	CreateVolumeFromSnapshot(snapshotID string, request VolumeRequest) (*Volume, error)
+
 	// CreateVolumeAttachment attaches a volume to an host
 	//- name of the volume attachment
 	//- volume to attach
@@ -439,6 +610,26 @@ type ClientAPI interface {
 	GetCfgOpts() (Config, error)
 }
 
+// ContextClientAPI is implemented by the drivers able to abort their long-running operations
+// when a context is done (cancelled or deadline exceeded).
+// A resource being created when the context is done is deleted before returning ctx.Err()
+type ContextClientAPI interface {
+	// CreateGatewayWithContext is like CreateGateway but aborts and rolls back when ctx is done
+	CreateGatewayWithContext(ctx context.Context, req GWRequest) (*Host, error)
+	// CreateHostWithContext is like CreateHost but aborts and rolls back when ctx is done
+	CreateHostWithContext(ctx context.Context, request HostRequest) (*Host, error)
+}
+
+// TagClientAPI is implemented by the drivers able to store the tags of the resources as provider metadata
+type TagClientAPI interface {
+	// SetHostTags adds or replaces the tags 'tags' and removes the tags 'removed' of the host identified by id
+	// Provider metadata not being one of these tags is left untouched
+	SetHostTags(id string, tags map[string]string, removed []string) error
+	// SetVolumeTags adds or replaces the tags 'tags' and removes the tags 'removed' of the volume identified by id
+	// Provider metadata not being one of these tags is left untouched
+	SetVolumeTags(id string, tags map[string]string, removed []string) error
+}
+
 //go:generate mockgen -destination=../mocks/mock_config.go -package=mocks github.com/CS-SI/SafeScale/providers/api Config
 
 // Config represents key/value configuration.
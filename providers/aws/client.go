@@ -19,6 +19,7 @@ package aws
 //go:generate rice embed-go
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/gob"
 	"encoding/json"
@@ -31,14 +32,17 @@ import (
 
 	"github.com/CS-SI/SafeScale/providers"
 	"github.com/CS-SI/SafeScale/providers/enums/HostState"
+	"github.com/CS-SI/SafeScale/providers/enums/IPVersion"
+	"github.com/CS-SI/SafeScale/providers/enums/RuleDirection"
 	"github.com/CS-SI/SafeScale/providers/enums/VolumeSpeed"
 	"github.com/CS-SI/SafeScale/providers/enums/VolumeState"
-	metadata "github.com/CS-SI/SafeScale/providers/metadata"
+	providermetadata "github.com/CS-SI/SafeScale/providers/metadata"
 	rice "github.com/GeertJohan/go.rice"
 
 	"github.com/CS-SI/SafeScale/providers/api"
 	"github.com/CS-SI/SafeScale/providers/aws/s3"
 	"github.com/CS-SI/SafeScale/system"
+	"github.com/CS-SI/SafeScale/utils/metadata"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -73,6 +77,20 @@ type AuthOpts struct {
 	//Config *Config
 }
 
+//CfgOpts AWS configuration options
+type CfgOpts struct {
+	// DNSList list of DNS servers
+	DNSList []string
+	// S3Protocol protocol used to mount the object storage
+	S3Protocol string
+	// AutoHostNetworkInterfaces tells if the network interfaces of the hosts are configured automatically
+	AutoHostNetworkInterfaces bool
+	// UseLayer3Networking tells if layer 3 networking is used
+	UseLayer3Networking bool
+	// MetadataBucketName name of the bucket storing the metadata
+	MetadataBucketName string
+}
+
 // Retrieve returns nil if it successfully retrieved the value.
 // Error is returned if the value were not obtainable, or empty.
 func (o AuthOpts) Retrieve() (credentials.Value, error) {
@@ -122,13 +140,21 @@ func AuthenticatedClient(opts AuthOpts) (*Client, error) {
 		EC2:         ec2.New(s),
 		Pricing:     pricing.New(sPricing),
 		AuthOpts:    opts,
+		Cfg: CfgOpts{
+			S3Protocol:          "s3",
+			UseLayer3Networking: true,
+			MetadataBucketName:  api.BuildMetadataBucketName(opts.AccessKeyID),
+		},
 		UserDataTpl: tpl,
 	}
-	metadata.InitializeContainer(&c)
 	//c.CreateContainer("gpac.aws.networks")
 	//c.CreateContainer("gpac.aws.wms")
 	//c.CreateContainer("gpac.aws.volumes")
 
+	err = metadata.InitializeBucket(&c)
+	if err != nil {
+		return nil, err
+	}
 	return &c, nil
 }
 
@@ -161,6 +187,7 @@ type Client struct {
 	EC2         *ec2.EC2
 	Pricing     *pricing.Pricing
 	AuthOpts    AuthOpts
+	Cfg         CfgOpts
 	UserDataTpl *template.Template
 	//ImageOwners []string
 }
@@ -556,6 +583,13 @@ func pStr(s *string) string {
 	return *s
 }
 
+func pInt64(p *int64) int64 {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
 //GetKeyPair returns the key pair identified by id
 func (c *Client) GetKeyPair(id string) (*api.KeyPair, error) {
 	out, err := c.EC2.DescribeKeyPairs(&ec2.DescribeKeyPairsInput{
@@ -632,7 +666,7 @@ func (c *Client) removeNetwork(netID string) error {
 
 //CreateNetwork creates a network
 func (c *Client) CreateNetwork(req api.NetworkRequest) (*api.Network, error) {
-	m, err := metadata.LoadNetwork(providers.FromClient(c), req.Name)
+	m, err := providermetadata.LoadNetwork(providers.FromClient(c), req.Name)
 	if err != nil {
 		return nil, err
 	}
@@ -702,20 +736,11 @@ func (c *Client) CreateNetwork(req api.NetworkRequest) (*api.Network, error) {
 		return nil, err
 	}
 
-	req.GWRequest.PublicIP = true
-	req.GWRequest.IsGateway = true
-	req.GWRequest.NetworkIDs = append(req.GWRequest.NetworkIDs, *vpcOut.Vpc.VpcId)
-	host, err := c.CreateHost(req.GWRequest)
-	if err != nil {
-		c.DeleteNetwork(*vpcOut.Vpc.VpcId)
-		return nil, wrapError("Error creating network", err)
-	}
 	net := api.Network{
 		CIDR:      pStr(vpcOut.Vpc.CidrBlock),
 		ID:        pStr(vpcOut.Vpc.VpcId),
 		Name:      req.Name,
 		IPVersion: req.IPVersion,
-		GatewayID: host.ID,
 	}
 	err = c.saveNetwork(net)
 	if err != nil {
@@ -742,8 +767,8 @@ func (c *Client) GetNetwork(id string) (*api.Network, error) {
 	return net, nil
 }
 
-//ListNetworks lists available networks
-func (c *Client) ListNetworks() ([]api.Network, error) {
+//ListNetworks lists the networks created by the driver, or all the VPCs if all is true
+func (c *Client) ListNetworks(all bool) ([]api.Network, error) {
 	out, err := c.EC2.DescribeVpcs(&ec2.DescribeVpcsInput{})
 	if err != nil {
 		return nil, err
@@ -752,10 +777,13 @@ func (c *Client) ListNetworks() ([]api.Network, error) {
 	for _, vpc := range out.Vpcs {
 		net, err := c.getNetwork(*vpc.VpcId)
 		if err != nil {
-			return nil, err
+			if !all {
+				continue
+			}
+			net = &api.Network{}
 		}
 		net.CIDR = *vpc.CidrBlock
-		net.CIDR = *vpc.VpcId
+		net.ID = *vpc.VpcId
 		nets = append(nets, *net)
 	}
 	return nets, nil
@@ -764,25 +792,87 @@ func (c *Client) ListNetworks() ([]api.Network, error) {
 
 //DeleteNetwork deletes the network identified by id
 func (c *Client) DeleteNetwork(id string) error {
-	net, err := c.getNetwork(id)
-	if err == nil {
-		c.DeleteHost(net.GatewayID)
-		addrs, _ := c.EC2.DescribeAddresses(&ec2.DescribeAddressesInput{
-			Filters: []*ec2.Filter{
-				{
-					Name: aws.String("domain"),
-					Values: []*string{
-						aws.String("vpc"),
-					},
+	err := c.DeleteGateway(id)
+	if err != nil {
+		return err
+	}
+	_, err = c.EC2.DeleteVpc(&ec2.DeleteVpcInput{
+		VpcId: aws.String(id),
+	})
+	if err != nil {
+		return err
+	}
+	c.removeNetwork(id)
+	return nil
+}
+
+//CreateGateway creates the gateway of the network req.NetworkID, the host through which the other hosts of the
+//network reach the outside
+func (c *Client) CreateGateway(req api.GWRequest) (*api.Host, error) {
+	return c.CreateGatewayWithContext(context.Background(), req)
+}
+
+//CreateGatewayWithContext creates the gateway of the network req.NetworkID, terminating the instance if ctx is done
+//before the gateway is started
+func (c *Client) CreateGatewayWithContext(ctx context.Context, req api.GWRequest) (*api.Host, error) {
+	net, err := c.getNetwork(req.NetworkID)
+	if err != nil {
+		return nil, wrapError("Error creating gateway", err)
+	}
+	if net.GatewayID != "" {
+		return nil, fmt.Errorf("Error creating gateway: network '%s' already has a gateway", net.Name)
+	}
+	name := req.GWName
+	if name == "" {
+		name = "gw-" + net.Name
+	}
+	host, err := c.createHost(ctx, api.HostRequest{
+		Name:           name,
+		NetworkIDs:     []string{req.NetworkID},
+		PublicIP:       true,
+		TemplateID:     req.TemplateID,
+		ImageID:        req.ImageID,
+		KeyPair:        req.KeyPair,
+		SecurityGroups: req.SecurityGroups,
+	}, true)
+	if err != nil {
+		if err == ctx.Err() {
+			return nil, err
+		}
+		return nil, wrapError("Error creating gateway", err)
+	}
+	net.GatewayID = host.ID
+	err = c.saveNetwork(*net)
+	if err != nil {
+		c.DeleteHost(host.ID)
+		return nil, err
+	}
+	return host, nil
+}
+
+//DeleteGateway deletes the gateway of the network identified by networkID and releases its public address
+func (c *Client) DeleteGateway(networkID string) error {
+	net, err := c.getNetwork(networkID)
+	if err != nil || net.GatewayID == "" {
+		return nil
+	}
+	addrs, err := c.EC2.DescribeAddresses(&ec2.DescribeAddressesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name: aws.String("domain"),
+				Values: []*string{
+					aws.String("vpc"),
 				},
-				{
-					Name: aws.String("instance-id"),
-					Values: []*string{
-						aws.String(net.GatewayID),
-					},
+			},
+			{
+				Name: aws.String("instance-id"),
+				Values: []*string{
+					aws.String(net.GatewayID),
 				},
 			},
-		})
+		},
+	})
+	if err == nil {
 		for _, addr := range addrs.Addresses {
 			c.EC2.DisassociateAddress(&ec2.DisassociateAddressInput{
 				AssociationId: addr.AssociationId,
@@ -792,21 +882,12 @@ func (c *Client) DeleteNetwork(id string) error {
 			})
 		}
 	}
-
-	_, err = c.EC2.DeleteVpc(&ec2.DeleteVpcInput{
-		VpcId: aws.String(id),
-	})
-	return err
-}
-
-//CreateGateway exists only to comply with api.ClientAPI interface
-func (c *Client) CreateGateway(req api.GWRequest) (*api.Host, error) {
-	return nil, fmt.Errorf("aws.CreateGateway() isn't available by design")
-}
-
-//DeleteGateway exists only to comply with api.ClientAPI interface
-func (c *Client) DeleteGateway(networkID string) error {
-	return fmt.Errorf("aws.DeleteGateway() isn't available by design")
+	err = c.DeleteHost(net.GatewayID)
+	if err != nil {
+		return wrapError("Error deleting gateway", err)
+	}
+	net.GatewayID = ""
+	return c.saveNetwork(*net)
 }
 
 func (c *Client) getSubnets(vpcIDs []string) ([]*ec2.Subnet, error) {
@@ -883,7 +964,7 @@ type userData struct {
 	GatewayIP string
 }
 
-func (c *Client) prepareUserData(request api.HostRequest, kp *api.KeyPair, gw *api.Host) (string, error) {
+func (c *Client) prepareUserData(request api.HostRequest, isGateway bool, kp *api.KeyPair, gw *api.Host) (string, error) {
 	dataBuffer := bytes.NewBufferString("")
 	var ResolvConf string
 	var err error
@@ -905,7 +986,7 @@ func (c *Client) prepareUserData(request api.HostRequest, kp *api.KeyPair, gw *a
 	data := userData{
 		User:       api.DefaultUser,
 		Key:        strings.Trim(kp.PublicKey, "\n"),
-		IsGateway:  request.IsGateway,
+		IsGateway:  isGateway,
 		AddGateway: !request.PublicIP,
 		ResolvConf: ResolvConf,
 		GatewayIP:  ip,
@@ -951,40 +1032,251 @@ func (c *Client) readHost(hostID string) (*api.Host, error) {
 	return &host, nil
 }
 
-func (c *Client) createSecurityGroup(vpcID string, name string) (string, error) {
+//CreateSecurityGroup creates a security group without rule in the VPC of request.NetworkID
+//As any EC2 security group, the new group allows all outgoing traffic until this rule is removed
+func (c *Client) CreateSecurityGroup(request api.SecurityGroupRequest) (*api.SecurityGroup, error) {
+	if request.NetworkID == "" {
+		return nil, fmt.Errorf("Error creating security group '%s': a network is required", request.Name)
+	}
+	description := request.Description
+	if description == "" {
+		// Description is mandatory for EC2
+		description = request.Name
+	}
 	out, err := c.EC2.CreateSecurityGroup(&ec2.CreateSecurityGroupInput{
-		GroupName: aws.String(name),
-		VpcId:     aws.String(vpcID),
+		GroupName:   aws.String(request.Name),
+		Description: aws.String(description),
+		VpcId:       aws.String(request.NetworkID),
 	})
 	if err != nil {
-		return "", err
+		return nil, wrapError("Error creating security group", err)
 	}
-	_, err = c.EC2.AuthorizeSecurityGroupEgress(&ec2.AuthorizeSecurityGroupEgressInput{
-		IpPermissions: []*ec2.IpPermission{
-			&ec2.IpPermission{
-				IpProtocol: aws.String("-1"),
+	return c.GetSecurityGroup(pStr(out.GroupId))
+}
+
+//GetSecurityGroup returns the security group identified by ref (id or name), nil if it doesn't exist
+func (c *Client) GetSecurityGroup(ref string) (*api.SecurityGroup, error) {
+	input := &ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{
+			&ec2.Filter{
+				Name:   aws.String("group-id"),
+				Values: []*string{aws.String(ref)},
 			},
 		},
-	})
+	}
+	if !strings.HasPrefix(ref, "sg-") {
+		input.Filters[0].Name = aws.String("group-name")
+	}
+	out, err := c.EC2.DescribeSecurityGroups(input)
 	if err != nil {
-		return "", err
+		return nil, wrapError("Error getting security group", err)
 	}
+	switch len(out.SecurityGroups) {
+	case 0:
+		return nil, nil
+	case 1:
+		return toAPISecurityGroup(out.SecurityGroups[0]), nil
+	default:
+		return nil, fmt.Errorf("Several security groups are named '%s', use the ID instead", ref)
+	}
+}
 
-	_, err = c.EC2.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
-		IpPermissions: []*ec2.IpPermission{
-			&ec2.IpPermission{
-				IpProtocol: aws.String("-1"),
-			},
-		},
+//ListSecurityGroups lists available security groups
+func (c *Client) ListSecurityGroups() ([]api.SecurityGroup, error) {
+	out, err := c.EC2.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{})
+	if err != nil {
+		return nil, wrapError("Error listing security groups", err)
+	}
+	list := []api.SecurityGroup{}
+	for _, g := range out.SecurityGroups {
+		list = append(list, *toAPISecurityGroup(g))
+	}
+	return list, nil
+}
+
+//DeleteSecurityGroup deletes the security group identified by id
+func (c *Client) DeleteSecurityGroup(id string) error {
+	_, err := c.EC2.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{
+		GroupId: aws.String(id),
 	})
+	return wrapError("Error deleting security group", err)
+}
+
+//AddSecurityGroupRule adds a rule to the security group identified by groupID
+//EC2 rules have no ID, the ID of the rule is built from its content
+func (c *Client) AddSecurityGroupRule(groupID string, rule api.SecurityGroupRule) (*api.SecurityGroupRule, error) {
+	//EC2 needs explicit port ranges, and returns them when the group is read
+	if rule.Protocol != "" && rule.PortFrom == 0 && rule.PortTo == 0 {
+		if rule.Protocol == "icmp" {
+			rule.PortFrom, rule.PortTo = -1, -1
+		} else {
+			rule.PortTo = 65535
+		}
+	}
+	if rule.CIDR == "" {
+		rule.CIDR = "0.0.0.0/0"
+		if rule.IPVersion == IPVersion.IPv6 {
+			rule.CIDR = "::/0"
+		}
+	}
+	perm := toIPPermission(rule)
+	var err error
+	if rule.Direction == RuleDirection.EGRESS {
+		_, err = c.EC2.AuthorizeSecurityGroupEgress(&ec2.AuthorizeSecurityGroupEgressInput{
+			GroupId:       aws.String(groupID),
+			IpPermissions: []*ec2.IpPermission{perm},
+		})
+	} else {
+		_, err = c.EC2.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
+			GroupId:       aws.String(groupID),
+			IpPermissions: []*ec2.IpPermission{perm},
+		})
+	}
 	if err != nil {
-		return "", err
+		return nil, wrapError("Error adding rule to security group", err)
+	}
+	rule.ID = securityGroupRuleID(rule)
+	return &rule, nil
+}
+
+//DeleteSecurityGroupRule deletes the rule identified by ruleID from the security group identified by groupID
+func (c *Client) DeleteSecurityGroupRule(groupID string, ruleID string) error {
+	group, err := c.GetSecurityGroup(groupID)
+	if err != nil {
+		return err
+	}
+	if group == nil {
+		return providers.ResourceNotFoundError("security group", groupID)
+	}
+	for _, rule := range group.Rules {
+		if rule.ID != ruleID {
+			continue
+		}
+		perm := toIPPermission(rule)
+		if rule.Direction == RuleDirection.EGRESS {
+			_, err = c.EC2.RevokeSecurityGroupEgress(&ec2.RevokeSecurityGroupEgressInput{
+				GroupId:       aws.String(groupID),
+				IpPermissions: []*ec2.IpPermission{perm},
+			})
+		} else {
+			_, err = c.EC2.RevokeSecurityGroupIngress(&ec2.RevokeSecurityGroupIngressInput{
+				GroupId:       aws.String(groupID),
+				IpPermissions: []*ec2.IpPermission{perm},
+			})
+		}
+		return wrapError("Error deleting rule of security group", err)
+	}
+	return providers.ResourceNotFoundError("security group rule", ruleID)
+}
+
+//securityGroupRuleID builds the ID of a rule from its content
+func securityGroupRuleID(rule api.SecurityGroupRule) string {
+	direction := "ingress"
+	if rule.Direction == RuleDirection.EGRESS {
+		direction = "egress"
 	}
-	return *out.GroupId, nil
+	protocol := rule.Protocol
+	if protocol == "" {
+		protocol = "all"
+	}
+	return fmt.Sprintf("%s-%s-%d-%d-%s", direction, protocol, rule.PortFrom, rule.PortTo, rule.CIDR)
+}
+
+//errVIPNotSupported is returned by the VIP methods: an EC2 private address can only be moved between hosts by an
+//API call, which keepalived can't do
+var errVIPNotSupported = fmt.Errorf("virtual IP addresses are not supported by AWS driver")
+
+//CreateVIP is not supported by AWS driver
+func (c *Client) CreateVIP(networkID string, name string) (*api.VIP, error) {
+	return nil, errVIPNotSupported
+}
+
+//BindHostToVIP is not supported by AWS driver
+func (c *Client) BindHostToVIP(vip *api.VIP, hostID string) error {
+	return errVIPNotSupported
+}
+
+//UnbindHostFromVIP is not supported by AWS driver
+func (c *Client) UnbindHostFromVIP(vip *api.VIP, hostID string) error {
+	return errVIPNotSupported
+}
+
+//DeleteVIP is not supported by AWS driver
+func (c *Client) DeleteVIP(vip *api.VIP) error {
+	return errVIPNotSupported
+}
+
+//toIPPermission converts a rule to an EC2 IpPermission
+func toIPPermission(rule api.SecurityGroupRule) *ec2.IpPermission {
+	perm := &ec2.IpPermission{
+		IpProtocol: aws.String("-1"),
+	}
+	if rule.Protocol != "" {
+		perm.IpProtocol = aws.String(rule.Protocol)
+		perm.FromPort = aws.Int64(int64(rule.PortFrom))
+		perm.ToPort = aws.Int64(int64(rule.PortTo))
+	}
+	if rule.IPVersion == IPVersion.IPv6 {
+		perm.Ipv6Ranges = []*ec2.Ipv6Range{&ec2.Ipv6Range{CidrIpv6: aws.String(rule.CIDR)}}
+	} else {
+		perm.IpRanges = []*ec2.IpRange{&ec2.IpRange{CidrIp: aws.String(rule.CIDR)}}
+	}
+	return perm
+}
+
+//toAPISecurityGroup converts an EC2 security group to an api.SecurityGroup, with one rule per address range
+func toAPISecurityGroup(g *ec2.SecurityGroup) *api.SecurityGroup {
+	group := api.SecurityGroup{
+		ID:          pStr(g.GroupId),
+		Name:        pStr(g.GroupName),
+		Description: pStr(g.Description),
+	}
+	add := func(direction RuleDirection.Enum, perms []*ec2.IpPermission) {
+		for _, p := range perms {
+			rule := api.SecurityGroupRule{
+				Direction: direction,
+			}
+			if protocol := pStr(p.IpProtocol); protocol != "-1" {
+				rule.Protocol = protocol
+			}
+			if p.FromPort != nil {
+				rule.PortFrom = int(*p.FromPort)
+			}
+			if p.ToPort != nil {
+				rule.PortTo = int(*p.ToPort)
+			}
+			for _, r := range p.IpRanges {
+				rule.IPVersion = IPVersion.IPv4
+				rule.CIDR = pStr(r.CidrIp)
+				rule.ID = securityGroupRuleID(rule)
+				group.Rules = append(group.Rules, rule)
+			}
+			for _, r := range p.Ipv6Ranges {
+				rule.IPVersion = IPVersion.IPv6
+				rule.CIDR = pStr(r.CidrIpv6)
+				rule.ID = securityGroupRuleID(rule)
+				group.Rules = append(group.Rules, rule)
+			}
+		}
+	}
+	add(RuleDirection.INGRESS, g.IpPermissions)
+	add(RuleDirection.EGRESS, g.IpPermissionsEgress)
+	return &group
 }
 
 //CreateHost creates an host that fulfils the request
 func (c *Client) CreateHost(request api.HostRequest) (*api.Host, error) {
+	return c.CreateHostWithContext(context.Background(), request)
+}
+
+//CreateHostWithContext creates an host that fulfils the request, terminating the instance if ctx is done
+//before the host is started
+func (c *Client) CreateHostWithContext(ctx context.Context, request api.HostRequest) (*api.Host, error) {
+	return c.createHost(ctx, request, false)
+}
+
+//createHost creates an host that fulfils the request, the gateway of its network if isGateway is true
+func (c *Client) createHost(ctx context.Context, request api.HostRequest, isGateway bool) (*api.Host, error) {
 
 	// If no KeyPair is supplied a temporay one is created
 	kp := request.KeyPair
@@ -998,23 +1290,24 @@ func (c *Client) CreateHost(request api.HostRequest) (*api.Host, error) {
 	// If the host is not a Gateway, get gateway of the first network
 	gwID := ""
 	var gw *api.Host
-	if !request.IsGateway {
+	if !isGateway {
 		net, err := c.getNetwork(request.NetworkIDs[0])
 		if err != nil {
 			return nil, err
 		}
 		gwID = net.GatewayID
-		gw, err = c.GetHost(gwID)
-		if err != nil {
-			return nil, err
+		if gwID != "" {
+			gw, err = c.GetHost(gwID)
+			if err != nil {
+				return nil, err
+			}
 		}
-
 	}
 	//get subnet of each network
 	sns, err := c.getSubnets(request.NetworkIDs)
 
 	//Prepare user data
-	userData, err := c.prepareUserData(request, kp, gw)
+	userData, err := c.prepareUserData(request, isGateway, kp, gw)
 	if err != nil {
 		return nil, err
 	}
@@ -1034,17 +1327,22 @@ func (c *Client) CreateHost(request api.HostRequest) (*api.Host, error) {
 			continue
 		}
 		sn := vpcs[netID][0]
-		networkInterfaces = append(networkInterfaces, &ec2.InstanceNetworkInterfaceSpecification{
+		nif := &ec2.InstanceNetworkInterfaceSpecification{
 			SubnetId:                 sn.SubnetId,
 			AssociatePublicIpAddress: aws.Bool(false),
 			DeleteOnTermination:      aws.Bool(true),
 			DeviceIndex:              aws.Int64(int64(i)),
-		})
+		}
+		//Without security group, the default security group of the VPC is used
+		if len(request.SecurityGroups) > 0 {
+			nif.Groups = aws.StringSlice(request.SecurityGroups)
+		}
+		networkInterfaces = append(networkInterfaces, nif)
 		i++
 	}
 
 	//Run instance
-	out, err := c.EC2.RunInstances(&ec2.RunInstancesInput{
+	out, err := c.EC2.RunInstancesWithContext(ctx, &ec2.RunInstancesInput{
 		ImageId:           aws.String(request.ImageID),
 		KeyName:           aws.String(kp.Name),
 		InstanceType:      aws.String(request.TemplateID),
@@ -1096,8 +1394,11 @@ func (c *Client) CreateHost(request api.HostRequest) (*api.Host, error) {
 	service := providers.Service{
 		ClientAPI: c,
 	}
-	_, err = service.WaitHostState(*instance.InstanceId, HostState.STARTED, 120*time.Second)
+	_, err = service.WaitHostStateWithContext(ctx, *instance.InstanceId, HostState.STARTED, 120*time.Second)
 	if err != nil {
+		if err == ctx.Err() {
+			c.DeleteHost(*instance.InstanceId)
+		}
 		return nil, err
 	}
 	_, err = c.EC2.AssociateAddress(&ec2.AssociateAddressInput{
@@ -1183,9 +1484,29 @@ func (c *Client) GetHost(id string) (*api.Host, error) {
 	return host, nil
 }
 
-// ListHosts lists available hosts
-func (c *Client) ListHosts() ([]api.Host, error) {
-	panic("Not Implemented")
+// ListHosts lists the hosts created by the driver, or all the instances if all is true
+func (c *Client) ListHosts(all bool) ([]api.Host, error) {
+	out, err := c.EC2.DescribeInstances(&ec2.DescribeInstancesInput{})
+	if err != nil {
+		return nil, wrapError("Error listing hosts", err)
+	}
+	hosts := []api.Host{}
+	for _, r := range out.Reservations {
+		for _, instance := range r.Instances {
+			id := pStr(instance.InstanceId)
+			if !all {
+				if _, err := c.readHost(id); err != nil {
+					continue
+				}
+			}
+			host, err := c.GetHost(id)
+			if err != nil {
+				return nil, err
+			}
+			hosts = append(hosts, *host)
+		}
+	}
+	return hosts, nil
 }
 
 // DeleteHost deletes the host identified by id
@@ -1222,6 +1543,14 @@ func (c *Client) StopHost(id string) error {
 	return err
 }
 
+//RebootHost reboots the host identified by id
+func (c *Client) RebootHost(id string) error {
+	_, err := c.EC2.RebootInstances(&ec2.RebootInstancesInput{
+		InstanceIds: []*string{aws.String(id)},
+	})
+	return err
+}
+
 //StartHost starts the host identified by id
 func (c *Client) StartHost(id string) error {
 	_, err := c.EC2.StartInstances(&ec2.StartInstancesInput{
@@ -1239,6 +1568,7 @@ func (c *Client) GetSSHConfig(hostID string) (*system.SSHConfig, error) {
 	ip := host.GetAccessIP()
 	sshConfig := system.SSHConfig{
 		PrivateKey: host.PrivateKey,
+		HostKey:    host.HostKey,
 		Port:       22,
 		Host:       ip,
 		User:       api.DefaultUser,
@@ -1251,6 +1581,7 @@ func (c *Client) GetSSHConfig(hostID string) (*system.SSHConfig, error) {
 		ip := gw.GetAccessIP()
 		GatewayConfig := system.SSHConfig{
 			PrivateKey: gw.PrivateKey,
+			HostKey:    gw.HostKey,
 			Port:       22,
 			User:       api.DefaultUser,
 			Host:       ip,
@@ -1347,7 +1678,7 @@ func (c *Client) removeVolumeName(id string) error {
 //- volumeType is the type of volume to create, if volumeType is empty the driver use a default type
 func (c *Client) CreateVolume(request api.VolumeRequest) (*api.Volume, error) {
 	// Check if a volume already exist with the same name
-	_volume, err := metadata.LoadVolume(providers.FromClient(client), request.Name)
+	_volume, err := providermetadata.LoadVolume(providers.FromClient(c), request.Name)
 	if err != nil {
 		return nil, err
 	}
@@ -1399,8 +1730,8 @@ func (c *Client) GetVolume(id string) (*api.Volume, error) {
 	return &volume, nil
 }
 
-//ListVolumes list available volumes
-func (c *Client) ListVolumes() ([]api.Volume, error) {
+//ListVolumes lists the volumes created by the driver, or all the volumes if all is true
+func (c *Client) ListVolumes(all bool) ([]api.Volume, error) {
 	out, err := c.EC2.DescribeVolumes(&ec2.DescribeVolumesInput{})
 	if err != nil {
 		return nil, err
@@ -1408,8 +1739,8 @@ func (c *Client) ListVolumes() ([]api.Volume, error) {
 	volumes := []api.Volume{}
 	for _, v := range out.Volumes {
 		name, err := c.getVolumeName(*v.VolumeId)
-		if err != nil {
-			return nil, err
+		if err != nil && !all {
+			continue
 		}
 		volume := api.Volume{
 			ID:    pStr(v.VolumeId),
@@ -1535,9 +1866,135 @@ func (c *Client) DeleteVolumeAttachment(serverID, id string) error {
 	return err
 }
 
+func toSnapshotState(s *string) VolumeState.Enum {
+	switch aws.StringValue(s) {
+	case "pending":
+		return VolumeState.CREATING
+	case "completed":
+		return VolumeState.AVAILABLE
+	case "error":
+		return VolumeState.ERROR
+	}
+	return VolumeState.OTHER
+}
+
+//toAPISnapshot converts an EBS snapshot, its name being kept in its description
+func toAPISnapshot(s *ec2.Snapshot) api.Snapshot {
+	return api.Snapshot{
+		ID:        pStr(s.SnapshotId),
+		Name:      pStr(s.Description),
+		VolumeID:  pStr(s.VolumeId),
+		Size:      int(aws.Int64Value(s.VolumeSize)),
+		State:     toSnapshotState(s.State),
+		CreatedAt: aws.TimeValue(s.StartTime),
+	}
+}
+
+//CreateVolumeSnapshot creates an EBS snapshot of a volume
+func (c *Client) CreateVolumeSnapshot(request api.SnapshotRequest) (*api.Snapshot, error) {
+	s, err := c.EC2.CreateSnapshot(&ec2.CreateSnapshotInput{
+		VolumeId:    aws.String(request.VolumeID),
+		Description: aws.String(request.Name),
+	})
+	if err != nil {
+		return nil, wrapError("Error creating snapshot", err)
+	}
+	snapshot := toAPISnapshot(s)
+	return &snapshot, nil
+}
+
+//GetVolumeSnapshot returns the snapshot identified by id
+func (c *Client) GetVolumeSnapshot(id string) (*api.Snapshot, error) {
+	out, err := c.EC2.DescribeSnapshots(&ec2.DescribeSnapshotsInput{
+		SnapshotIds: []*string{aws.String(id)},
+	})
+	if err != nil {
+		return nil, wrapError("Error getting snapshot", err)
+	}
+	if len(out.Snapshots) == 0 {
+		return nil, providers.ResourceNotFoundError("snapshot", id)
+	}
+	snapshot := toAPISnapshot(out.Snapshots[0])
+	return &snapshot, nil
+}
+
+//ListVolumeSnapshots lists the snapshots owned by the account of the volume identified by volumeID, or all of
+//them if volumeID is empty
+func (c *Client) ListVolumeSnapshots(volumeID string) ([]api.Snapshot, error) {
+	input := &ec2.DescribeSnapshotsInput{
+		OwnerIds: []*string{aws.String("self")},
+	}
+	if volumeID != "" {
+		input.Filters = []*ec2.Filter{
+			&ec2.Filter{
+				Name:   aws.String("volume-id"),
+				Values: []*string{aws.String(volumeID)},
+			},
+		}
+	}
+	out, err := c.EC2.DescribeSnapshots(input)
+	if err != nil {
+		return nil, wrapError("Error listing snapshots", err)
+	}
+	snapshots := []api.Snapshot{}
+	for _, s := range out.Snapshots {
+		snapshots = append(snapshots, toAPISnapshot(s))
+	}
+	return snapshots, nil
+}
+
+//DeleteVolumeSnapshot deletes the snapshot identified by id
+func (c *Client) DeleteVolumeSnapshot(id string) error {
+	_, err := c.EC2.DeleteSnapshot(&ec2.DeleteSnapshotInput{
+		SnapshotId: aws.String(id),
+	})
+	return wrapError("Error deleting snapshot", err)
+}
+
+//CreateVolumeFromSnapshot creates a volume initialized with the content of the snapshot identified by snapshotID
+func (c *Client) CreateVolumeFromSnapshot(snapshotID string, request api.VolumeRequest) (*api.Volume, error) {
+	input := &ec2.CreateVolumeInput{
+		SnapshotId: aws.String(snapshotID),
+		VolumeType: aws.String(toVolumeType(request.Speed)),
+	}
+	if request.Size > 0 {
+		input.Size = aws.Int64(int64(request.Size))
+	}
+	v, err := c.EC2.CreateVolume(input)
+	if err != nil {
+		return nil, wrapError("Error creating volume from snapshot", err)
+	}
+	err = c.saveVolumeName(*v.VolumeId, request.Name)
+	if err != nil {
+		c.DeleteVolume(*v.VolumeId)
+		return nil, err
+	}
+	return &api.Volume{
+		ID:    pStr(v.VolumeId),
+		Name:  request.Name,
+		Size:  int(aws.Int64Value(v.Size)),
+		Speed: toVolumeSpeed(v.VolumeType),
+		State: toVolumeState(v.State),
+	}, nil
+}
+
 //CreateContainer creates an object container
 func (c *Client) CreateContainer(name string) error {
-	return s3.CreateContainer(awss3.New(c.Session), name)
+	return s3.CreateContainer(awss3.New(c.Session), name, c.AuthOpts.Region)
+}
+
+//GetContainer returns the object container named name
+func (c *Client) GetContainer(name string) (*api.ContainerInfo, error) {
+	_, err := awss3.New(c.Session).HeadBucket(&awss3.HeadBucketInput{
+		Bucket: aws.String(name),
+	})
+	if err != nil {
+		return nil, wrapError(fmt.Sprintf("Error getting container %s", name), err)
+	}
+	return &api.ContainerInfo{
+		Name:    name,
+		NbItems: -1,
+	}, nil
 }
 
 //DeleteContainer deletes an object container
@@ -1585,8 +2042,14 @@ func (c *Client) DeleteObject(container, object string) error {
 	return s3.DeleteObject(awss3.New(c.Session), container, object)
 }
 
-//GetAuthOpts
-func (c *Client) GetAuthOpts() {
+//GetAuthOpts returns the auth options
+func (c *Client) GetAuthOpts() (api.Config, error) {
+	cfg := api.ConfigMap{}
+
+	cfg.Set("Login", c.AuthOpts.AccessKeyID)
+	cfg.Set("Password", c.AuthOpts.SecretAccessKey)
+	cfg.Set("Region", c.AuthOpts.Region)
+	return cfg, nil
 }
 
 //GetCfgOpts return configuration parameters
@@ -1597,6 +2060,7 @@ func (c *Client) GetCfgOpts() (api.Config, error) {
 	cfg.Set("S3Protocol", c.Cfg.S3Protocol)
 	cfg.Set("AutoHostNetworkInterfaces", c.Cfg.AutoHostNetworkInterfaces)
 	cfg.Set("UseLayer3Networking", c.Cfg.UseLayer3Networking)
+	cfg.Set("MetadataBucket", c.Cfg.MetadataBucketName)
 
 	return cfg, nil
 }
@@ -0,0 +1,30 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+//Package RuleDirection defines an enum to represent the direction of the traffic filtered by a security group rule
+package RuleDirection
+
+//go:generate stringer -type=Enum
+
+//Enum represents the direction of the traffic concerned by a security group rule
+type Enum int
+
+const (
+	//INGRESS is the traffic coming to the host
+	INGRESS Enum = iota
+	//EGRESS is the traffic going out of the host
+	EGRESS
+)
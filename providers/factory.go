@@ -68,9 +68,22 @@ func GetService(tenantName string) (*Service, error) {
 						if err != nil {
 							return nil, fmt.Errorf("Error creating tenant %s on provider %s: %s", tenantName, provider, err.Error())
 						}
-						return &Service{
+						svc := &Service{
 							ClientAPI: service,
-						}, nil
+						}
+						bucket, err := svc.metadataBucket()
+						if err != nil {
+							return nil, fmt.Errorf("Error creating tenant %s on provider %s: %s", tenantName, provider, err.Error())
+						}
+						keys, err := loadMetadataKeys(tenant, bucket)
+						if err != nil {
+							return nil, fmt.Errorf("Error loading metadata keys of tenant %s: %s", tenantName, err.Error())
+						}
+						err = svc.SetMetadataKeys(keys)
+						if err != nil {
+							return nil, fmt.Errorf("Error loading metadata keys of tenant %s: %s", tenantName, err.Error())
+						}
+						return svc, nil
 					}
 				}
 			}
@@ -0,0 +1,165 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tags
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//requirement is a condition on a single tag
+type requirement struct {
+	key string
+	//value is only used if hasValue is true
+	value    string
+	hasValue bool
+	negated  bool
+}
+
+//matches tells if tags satisfy the requirement
+func (r requirement) matches(tags map[string]string) bool {
+	value, ok := tags[r.key]
+	if r.hasValue {
+		ok = ok && value == r.value
+	}
+	return ok != r.negated
+}
+
+//Selector is a list of conditions on the tags of a resource, all of them must be satisfied
+type Selector []requirement
+
+//ParseSelector parses a selector made of comma separated conditions:
+// - 'key=value' (or 'key==value'): the tag 'key' is set to 'value'
+// - 'key!=value': the tag 'key' is not set to 'value' (or not set at all)
+// - 'key': the tag 'key' is set
+// - '!key': the tag 'key' is not set
+//An empty selector matches everything
+func ParseSelector(s string) (Selector, error) {
+	var selector Selector
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var r requirement
+		switch {
+		case strings.Contains(item, "!="):
+			parts := strings.SplitN(item, "!=", 2)
+			r = requirement{key: parts[0], value: parts[1], hasValue: true, negated: true}
+		case strings.Contains(item, "=="):
+			parts := strings.SplitN(item, "==", 2)
+			r = requirement{key: parts[0], value: parts[1], hasValue: true}
+		case strings.Contains(item, "="):
+			parts := strings.SplitN(item, "=", 2)
+			r = requirement{key: parts[0], value: parts[1], hasValue: true}
+		case strings.HasPrefix(item, "!"):
+			r = requirement{key: item[1:], negated: true}
+		default:
+			r = requirement{key: item}
+		}
+		r.key = strings.TrimSpace(r.key)
+		r.value = strings.TrimSpace(r.value)
+		if err := validateKey(r.key); err != nil {
+			return nil, fmt.Errorf("invalid selector '%s': %s", item, err.Error())
+		}
+		selector = append(selector, r)
+	}
+	return selector, nil
+}
+
+//Matches tells if tags satisfy all the conditions of the selector
+func (s Selector) Matches(tags map[string]string) bool {
+	for _, r := range s {
+		if !r.matches(tags) {
+			return false
+		}
+	}
+	return true
+}
+
+//Parse converts a list of 'key=value' into tags
+func Parse(list []string) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, item := range list {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid tag '%s': must be 'key=value'", item)
+		}
+		key := strings.TrimSpace(parts[0])
+		if err := validateKey(key); err != nil {
+			return nil, fmt.Errorf("invalid tag '%s': %s", item, err.Error())
+		}
+		tags[key] = parts[1]
+	}
+	return tags, nil
+}
+
+//Update returns tags with the tags of set added or replaced, and the keys of remove deleted
+func Update(tags map[string]string, set map[string]string, remove []string) map[string]string {
+	updated := map[string]string{}
+	for k, v := range tags {
+		updated[k] = v
+	}
+	for k, v := range set {
+		updated[k] = v
+	}
+	for _, k := range remove {
+		delete(updated, k)
+	}
+	if len(updated) == 0 {
+		return nil
+	}
+	return updated
+}
+
+//Removed returns the keys of previous missing in tags, sorted
+func Removed(previous map[string]string, tags map[string]string) []string {
+	var removed []string
+	for k := range previous {
+		if _, ok := tags[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+//String returns the tags in the 'key=value' form, sorted by key
+func String(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	items := make([]string, 0, len(keys))
+	for _, k := range keys {
+		items = append(items, k+"="+tags[k])
+	}
+	return strings.Join(items, ",")
+}
+
+//validateKey checks a tag key is usable in a selector
+func validateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("empty key")
+	}
+	if strings.ContainsAny(key, "=!, ") {
+		return fmt.Errorf("key '%s' contains one of the forbidden characters '=', '!', ',' or space", key)
+	}
+	return nil
+}
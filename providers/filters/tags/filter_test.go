@@ -0,0 +1,73 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tags_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/providers/filters/tags"
+)
+
+func TestSelector(t *testing.T) {
+	resource := map[string]string{"project": "demo", "owner": "alice"}
+
+	for selector, expected := range map[string]bool{
+		"":                          true,
+		"project=demo":              true,
+		"project==demo":             true,
+		"project=other":             false,
+		"project!=other":            true,
+		"project!=demo":             false,
+		"owner":                     true,
+		"!owner":                    false,
+		"cluster":                   false,
+		"!cluster":                  true,
+		"project=demo, owner=alice": true,
+		"project=demo,owner=bob":    false,
+		"cluster!=prod":             true,
+	} {
+		s, err := tags.ParseSelector(selector)
+		require.Nil(t, err, selector)
+		assert.Equal(t, expected, s.Matches(resource), selector)
+	}
+
+	_, err := tags.ParseSelector("=demo")
+	assert.NotNil(t, err)
+	_, err = tags.ParseSelector("!")
+	assert.NotNil(t, err)
+}
+
+func TestUpdate(t *testing.T) {
+	set, err := tags.Parse([]string{"project=demo", "owner=alice", "empty="})
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{"project": "demo", "owner": "alice", "empty": ""}, set)
+	_, err = tags.Parse([]string{"project"})
+	assert.NotNil(t, err)
+
+	updated := tags.Update(set, map[string]string{"owner": "bob"}, []string{"empty"})
+	assert.Equal(t, "owner=bob,project=demo", tags.String(updated))
+	assert.Equal(t, "alice", set["owner"])
+	assert.Nil(t, tags.Update(updated, nil, []string{"owner", "project"}))
+
+	// Only the keys actually present before are reported as removed
+	assert.Equal(t, []string{"empty"}, tags.Removed(set, updated))
+	assert.Equal(t, []string{"owner", "project"}, tags.Removed(updated, nil))
+	assert.Nil(t, tags.Removed(updated, tags.Update(updated, nil, []string{"missing"})))
+}
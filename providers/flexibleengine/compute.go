@@ -17,6 +17,7 @@
 package flexibleengine
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"github.com/pkg/errors"
@@ -272,7 +273,13 @@ func (opts serverCreateOpts) ToServerCreateMap() (map[string]interface{}, error)
 
 // CreateHost creates a new host
 func (client *Client) CreateHost(request api.HostRequest) (*api.Host, error) {
-	host, err := client.createHost(request, false)
+	return client.CreateHostWithContext(context.Background(), request)
+}
+
+// CreateHostWithContext creates a new host, aborting the creation and deleting the host if ctx
+// is done before the host is ready
+func (client *Client) CreateHostWithContext(ctx context.Context, request api.HostRequest) (*api.Host, error) {
+	host, err := client.createHost(ctx, request, false)
 	if err != nil {
 		return nil, err
 	}
@@ -281,11 +288,16 @@ func (client *Client) CreateHost(request api.HostRequest) (*api.Host, error) {
 		client.DeleteHost(host.ID)
 		return nil, fmt.Errorf("failed to create Host: %s", openstack.ProviderErrorToString(err))
 	}
+	// Context may have been done while saving metadata
+	if err := ctx.Err(); err != nil {
+		client.DeleteHost(host.ID)
+		return nil, err
+	}
 	return host, nil
 }
 
 // CreateHost creates a new host and configure it as gateway for the network if isGateway is true
-func (client *Client) createHost(request api.HostRequest, isGateway bool) (*api.Host, error) {
+func (client *Client) createHost(ctx context.Context, request api.HostRequest, isGateway bool) (*api.Host, error) {
 	if isGateway && !request.PublicIP {
 		return nil, fmt.Errorf("can't create a gateway without public IP")
 	}
@@ -385,7 +397,7 @@ func (client *Client) createHost(request api.HostRequest, isGateway bool) (*api.
 	// Defines server
 	srvOpts := serverCreateOpts{
 		Name:           request.Name,
-		SecurityGroups: []string{client.SecurityGroup.Name},
+		SecurityGroups: client.hostSecurityGroups(request),
 		Networks:       nets,
 		FlavorRef:      request.TemplateID,
 		UserData:       userData,
@@ -399,6 +411,10 @@ func (client *Client) createHost(request api.HostRequest, isGateway bool) (*api.
 	if err != nil {
 		return nil, fmt.Errorf("failed to build query to create host '%s': %s", request.Name, openstack.ProviderErrorToString(err))
 	}
+	// Last chance to abort before creating something on the provider side
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	r := servers.CreateResult{}
 	var httpResp *http.Response
 	httpResp, r.Err = client.osclt.Compute.Post(client.osclt.Compute.ServiceURL("servers"), b, &r.Body, &gc.RequestOpts{
@@ -413,9 +429,12 @@ func (client *Client) createHost(request api.HostRequest, isGateway bool) (*api.
 	}
 
 	// Wait that host is ready, not just that the build is started
-	host, err := client.WaitHostReady(server.ID, time.Minute*5)
+	host, err := client.WaitHostReadyWithContext(ctx, server.ID, time.Minute*5)
 	if err != nil {
 		client.DeleteHost(server.ID)
+		if err == ctx.Err() {
+			return nil, err
+		}
 		return nil, fmt.Errorf("timeout waiting host '%s' ready: %s", request.Name, openstack.ProviderErrorToString(err))
 	}
 
@@ -685,6 +704,7 @@ func (client *Client) getSSHConfig(host *api.Host) (*system.SSHConfig, error) {
 	ip := host.GetAccessIP()
 	sshConfig := system.SSHConfig{
 		PrivateKey: host.PrivateKey,
+		HostKey:    host.HostKey,
 		Port:       22,
 		Host:       ip,
 		User:       api.DefaultUser,
@@ -697,6 +717,7 @@ func (client *Client) getSSHConfig(host *api.Host) (*system.SSHConfig, error) {
 		ip := gw.GetAccessIP()
 		GatewayConfig := system.SSHConfig{
 			PrivateKey: gw.PrivateKey,
+			HostKey:    gw.HostKey,
 			Port:       22,
 			User:       api.DefaultUser,
 			Host:       ip,
@@ -912,6 +933,7 @@ func (client *Client) toHost(server *servers.Server) *api.Host {
 		hostDef := m.Get()
 		host.GatewayID = hostDef.GatewayID
 		host.PrivateKey = hostDef.PrivateKey
+		host.HostKey = hostDef.HostKey
 		//Floating IP management
 		if host.AccessIPv4 == "" {
 			host.AccessIPv4 = hostDef.AccessIPv4
@@ -1019,13 +1041,19 @@ func (client *Client) RebootHost(id string) error {
 
 // WaitHostReady waits an host achieve ready state
 func (client *Client) WaitHostReady(hostID string, timeout time.Duration) (*api.Host, error) {
+	return client.WaitHostReadyWithContext(context.Background(), hostID, timeout)
+}
+
+// WaitHostReadyWithContext waits an host achieve ready state, giving up when ctx is done
+func (client *Client) WaitHostReadyWithContext(ctx context.Context, hostID string, timeout time.Duration) (*api.Host, error) {
 	var (
 		server *servers.Server
 		err    error
 		broken bool
 	)
 
-	retryErr := retry.WhileUnsuccessfulDelay5Seconds(
+	retryErr := retry.WhileUnsuccessfulDelay5SecondsWithContext(
+		ctx,
 		func() error {
 			server, err = servers.Get(client.osclt.Compute, hostID).Extract()
 			if err != nil {
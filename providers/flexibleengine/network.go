@@ -17,6 +17,7 @@
 package flexibleengine
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
@@ -644,6 +645,12 @@ func fromIntIPVersion(v int) IPVersion.Enum {
 // By current implementation, only one gateway can exist by Network because the object is intended
 // to contain only one hostID
 func (client *Client) CreateGateway(req api.GWRequest) (*api.Host, error) {
+	return client.CreateGatewayWithContext(context.Background(), req)
+}
+
+// CreateGatewayWithContext creates a gateway for a network, aborting the creation and deleting
+// the gateway if ctx is done before the gateway is ready
+func (client *Client) CreateGatewayWithContext(ctx context.Context, req api.GWRequest) (*api.Host, error) {
 	net, err := client.GetNetwork(req.NetworkID)
 	if err != nil {
 		return nil, fmt.Errorf("Network %s not found: %s", req.NetworkID, openstack.ProviderErrorToString(err))
@@ -653,18 +660,27 @@ func (client *Client) CreateGateway(req api.GWRequest) (*api.Host, error) {
 		gwname = "gw-" + net.Name
 	}
 	hostReq := api.HostRequest{
-		ImageID:    req.ImageID,
-		KeyPair:    req.KeyPair,
-		Name:       gwname,
-		TemplateID: req.TemplateID,
-		NetworkIDs: []string{req.NetworkID},
-		PublicIP:   true,
-	}
-	host, err := client.createHost(hostReq, true)
-	if err != nil {
+		ImageID:        req.ImageID,
+		KeyPair:        req.KeyPair,
+		Name:           gwname,
+		TemplateID:     req.TemplateID,
+		NetworkIDs:     []string{req.NetworkID},
+		PublicIP:       true,
+		SecurityGroups: req.SecurityGroups,
+	}
+	host, err := client.createHost(ctx, hostReq, true)
+	if err != nil {
+		if err == ctx.Err() {
+			return nil, err
+		}
 		return nil, fmt.Errorf("Error creating gateway : %s", openstack.ProviderErrorToString(err))
 	}
 	err = metadata.SaveGateway(providers.FromClient(client), host, req.NetworkID)
+	if err == nil && ctx.Err() != nil {
+		// Context done while saving metadata, the gateway is not wanted anymore
+		client.DeleteGateway(req.NetworkID)
+		return nil, ctx.Err()
+	}
 	return host, err
 }
 
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flexibleengine
+
+import (
+	"fmt"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/metadata"
+	"github.com/CS-SI/SafeScale/providers/openstack"
+
+	v2_vol "github.com/gophercloud/gophercloud/openstack/blockstorage/v2/volumes"
+)
+
+// CreateVolumeSnapshot creates a snapshot of a volume, forced if the volume is attached
+func (client *Client) CreateVolumeSnapshot(request api.SnapshotRequest) (*api.Snapshot, error) {
+	return client.osclt.CreateVolumeSnapshot(request)
+}
+
+// GetVolumeSnapshot returns the snapshot identified by id
+func (client *Client) GetVolumeSnapshot(id string) (*api.Snapshot, error) {
+	return client.osclt.GetVolumeSnapshot(id)
+}
+
+// ListVolumeSnapshots lists the snapshots of the volume identified by volumeID, or all the snapshots if volumeID
+// is empty
+func (client *Client) ListVolumeSnapshots(volumeID string) ([]api.Snapshot, error) {
+	return client.osclt.ListVolumeSnapshots(volumeID)
+}
+
+// DeleteVolumeSnapshot deletes the snapshot identified by id
+func (client *Client) DeleteVolumeSnapshot(id string) error {
+	return client.osclt.DeleteVolumeSnapshot(id)
+}
+
+// CreateVolumeFromSnapshot creates a volume initialized with the content of the snapshot identified by snapshotID
+// The volume is created here rather than by the openstack client, its metadata being stored in S3
+func (client *Client) CreateVolumeFromSnapshot(snapshotID string, request api.VolumeRequest) (*api.Volume, error) {
+	volume, err := metadata.LoadVolume(providers.FromClient(client), request.Name)
+	if err != nil {
+		return nil, err
+	}
+	if volume != nil {
+		return nil, providers.ResourceAlreadyExistsError("Volume", request.Name)
+	}
+
+	size := request.Size
+	if size == 0 {
+		snapshot, err := client.GetVolumeSnapshot(snapshotID)
+		if err != nil {
+			return nil, err
+		}
+		size = snapshot.Size
+	}
+
+	vol, err := v2_vol.Create(client.osclt.Volume, v2_vol.CreateOpts{
+		Name:       request.Name,
+		Size:       size,
+		VolumeType: client.getVolumeType(request.Speed),
+		SnapshotID: snapshotID,
+	}).Extract()
+	if err != nil {
+		return nil, fmt.Errorf("Error creating volume from snapshot '%s': %s", snapshotID, openstack.ProviderErrorToString(err))
+	}
+	v := &api.Volume{
+		ID:    vol.ID,
+		Name:  vol.Name,
+		Size:  vol.Size,
+		Speed: client.getVolumeSpeed(vol.VolumeType),
+		State: toVolumeState(vol.Status),
+	}
+	err = metadata.SaveVolume(providers.FromClient(client), v)
+	if err != nil {
+		client.DeleteVolume(v.ID)
+		return nil, fmt.Errorf("failed to create Volume: %s", openstack.ProviderErrorToString(err))
+	}
+	return v, nil
+}
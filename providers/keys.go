@@ -0,0 +1,152 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package providers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// MetadataKeySize is the size in bytes of the keys encrypting metadata (AES-256)
+	MetadataKeySize = 32
+	// metadataKeyIterations is the number of PBKDF2 iterations used to derive a key from a passphrase
+	metadataKeyIterations = 100000
+)
+
+var (
+	// metadataKeys contains the keys of the tenants, indexed by metadata bucket name
+	metadataKeys     = map[string]*MetadataKeys{}
+	metadataKeysLock sync.RWMutex
+)
+
+// MetadataKeys contains the keys used to encrypt the metadata of a tenant
+type MetadataKeys struct {
+	// Current is the key used to encrypt metadata; if nil, metadata are written in clear
+	Current []byte
+	// Previous is the key in use before the last key rotation; only used to decrypt metadata not migrated yet
+	Previous []byte
+}
+
+// loadMetadataKeys builds the metadata keys from the tenant parameters:
+// - MetadataKey: base64-encoded 32 bytes key
+// - MetadataKeyFile: path of a file containing the base64-encoded key
+// - MetadataPassphrase: passphrase from which the key is derived (PBKDF2-SHA256, salted with the bucket name)
+// The previous key, if any, is given the same way by MetadataPreviousKey, MetadataPreviousKeyFile or MetadataPreviousPassphrase
+func loadMetadataKeys(params map[string]interface{}, bucket string) (*MetadataKeys, error) {
+	current, err := loadMetadataKey(params, "Metadata", bucket)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := loadMetadataKey(params, "MetadataPrevious", bucket)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil && previous == nil {
+		return nil, nil
+	}
+	return &MetadataKeys{Current: current, Previous: previous}, nil
+}
+
+// loadMetadataKey reads the key defined by one of the parameters <prefix>Key, <prefix>KeyFile or <prefix>Passphrase
+// Returns nil, nil if none of them is set
+func loadMetadataKey(params map[string]interface{}, prefix string, bucket string) ([]byte, error) {
+	key, _ := params[prefix+"Key"].(string)
+	keyFile, _ := params[prefix+"KeyFile"].(string)
+	passphrase, _ := params[prefix+"Passphrase"].(string)
+
+	count := 0
+	for _, p := range []string{key, keyFile, passphrase} {
+		if p != "" {
+			count++
+		}
+	}
+	if count > 1 {
+		return nil, fmt.Errorf("only one of '%sKey', '%sKeyFile' and '%sPassphrase' can be set", prefix, prefix, prefix)
+	}
+
+	switch {
+	case passphrase != "":
+		return pbkdf2.Key([]byte(passphrase), []byte(bucket), metadataKeyIterations, MetadataKeySize, sha256.New), nil
+	case keyFile != "":
+		content, err := ioutil.ReadFile(os.ExpandEnv(keyFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read '%sKeyFile': %s", prefix, err.Error())
+		}
+		key = string(content)
+	case key == "":
+		return nil, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata key '%sKey': %s", prefix, err.Error())
+	}
+	if len(decoded) != MetadataKeySize {
+		return nil, fmt.Errorf("invalid metadata key '%sKey': must be %d bytes long, not %d", prefix, MetadataKeySize, len(decoded))
+	}
+	return decoded, nil
+}
+
+// metadataBucket returns the name of the metadata bucket of the service
+func (svc *Service) metadataBucket() (string, error) {
+	cfg, err := svc.GetCfgOpts()
+	if err != nil {
+		return "", err
+	}
+	name, found := cfg.Get("MetadataBucket")
+	if !found || name.(string) == "" {
+		return "", fmt.Errorf("config option 'MetadataBucket' is not set")
+	}
+	return name.(string), nil
+}
+
+// SetMetadataKeys defines the keys used to encrypt the metadata of the tenant; nil disables the encryption
+func (svc *Service) SetMetadataKeys(keys *MetadataKeys) error {
+	bucket, err := svc.metadataBucket()
+	if err != nil {
+		return err
+	}
+
+	metadataKeysLock.Lock()
+	defer metadataKeysLock.Unlock()
+	if keys == nil {
+		delete(metadataKeys, bucket)
+	} else {
+		metadataKeys[bucket] = keys
+	}
+	return nil
+}
+
+// GetMetadataKeys returns the keys used to encrypt the metadata of the tenant, nil if metadata are not encrypted
+func (svc *Service) GetMetadataKeys() *MetadataKeys {
+	bucket, err := svc.metadataBucket()
+	if err != nil {
+		return nil
+	}
+
+	metadataKeysLock.RLock()
+	defer metadataKeysLock.RUnlock()
+	return metadataKeys[bucket]
+}
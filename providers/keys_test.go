@@ -0,0 +1,62 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package providers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_loadMetadataKeys(t *testing.T) {
+	keys, err := loadMetadataKeys(map[string]interface{}{}, "bucket")
+	require.Nil(t, err)
+	assert.Nil(t, keys)
+
+	key := bytes.Repeat([]byte{42}, MetadataKeySize)
+	encoded := base64.StdEncoding.EncodeToString(key)
+	keys, err = loadMetadataKeys(map[string]interface{}{"MetadataKey": encoded}, "bucket")
+	require.Nil(t, err)
+	assert.Equal(t, key, keys.Current)
+	assert.Nil(t, keys.Previous)
+
+	f, err := ioutil.TempFile("", "metadata_key")
+	require.Nil(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(encoded + "\n")
+	require.Nil(t, err)
+	f.Close()
+	keys, err = loadMetadataKeys(map[string]interface{}{"MetadataKeyFile": f.Name(), "MetadataPreviousPassphrase": "secret"}, "bucket")
+	require.Nil(t, err)
+	assert.Equal(t, key, keys.Current)
+	assert.Equal(t, MetadataKeySize, len(keys.Previous))
+
+	// The key derived from a passphrase depends on the bucket
+	other, err := loadMetadataKeys(map[string]interface{}{"MetadataPassphrase": "secret"}, "other")
+	require.Nil(t, err)
+	assert.NotEqual(t, keys.Previous, other.Current)
+
+	_, err = loadMetadataKeys(map[string]interface{}{"MetadataKey": encoded, "MetadataPassphrase": "secret"}, "bucket")
+	assert.NotNil(t, err)
+	_, err = loadMetadataKeys(map[string]interface{}{"MetadataKey": base64.StdEncoding.EncodeToString([]byte("short"))}, "bucket")
+	assert.NotNil(t, err)
+}
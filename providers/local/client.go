@@ -0,0 +1,306 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package local implements an api.ClientAPI working without any cloud: resources are
+// kept in memory (or in a file on local disk if 'StoragePath' is set) and follow the
+// same state transitions than the ones of a real provider.
+// It is intended for offline tests of brokerd and deploy.
+package local
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+
+	"github.com/CS-SI/SafeScale/utils/metadata"
+)
+
+const (
+	// defaultTransitionDelay is the time taken by a resource to go from a transient state to a stable one
+	defaultTransitionDelay = 2 * time.Second
+	// defaultSSHPort is the port used by GetSSHConfig when no SSHPort is configured
+	defaultSSHPort = 22
+)
+
+// AuthOptions contains the options identifying the local tenant
+type AuthOptions struct {
+	// TenantName is the name of the tenant; resources are shared between all the clients built with the same name
+	// and the same StoragePath
+	TenantName string
+}
+
+// CfgOptions configuration options
+type CfgOptions struct {
+	// StoragePath, if set, is the folder where the state of the tenant is persisted;
+	// if empty, state is kept in memory and lost when the process ends
+	StoragePath string
+
+	// TransitionDelay is the time a resource stays in a transient state (STARTING, CREATING, ATTACHING, ...)
+	TransitionDelay time.Duration
+
+	// SSHHost, if set, is the host used by GetSSHConfig to reach every host (typically a local container running sshd)
+	SSHHost string
+	// SSHPort is the port of SSHHost
+	SSHPort int
+	// SSHUser is the user to use to connect to SSHHost
+	SSHUser string
+	// SSHPrivateKey is the private key to use to connect to SSHHost
+	SSHPrivateKey string
+
+	// MetadataBucketName contains the name of the bucket storing metadata
+	MetadataBucketName string
+}
+
+// Client is the implementation of the local driver regarding to the api.ClientAPI
+type Client struct {
+	Opts *AuthOptions
+	Cfg  *CfgOptions
+
+	tenant *tenant
+}
+
+// tenant contains the resources of a local tenant, shared by all the clients using the same tenant name and storage
+type tenant struct {
+	lock  sync.Mutex
+	state *state
+	file  string
+}
+
+// tenantKey identifies a tenant: tenants with the same name stored in different folders are distinct
+type tenantKey struct {
+	name string
+	file string
+}
+
+var (
+	tenantsLock sync.Mutex
+	tenants     = map[tenantKey]*tenant{}
+)
+
+// getTenant returns the tenant named 'name' stored in storagePath, creating it if needed
+func getTenant(name string, storagePath string) *tenant {
+	tenantsLock.Lock()
+	defer tenantsLock.Unlock()
+
+	key := tenantKey{name: name}
+	if storagePath != "" {
+		key.file = filepath.Join(storagePath, name+".json")
+	}
+	if t, ok := tenants[key]; ok {
+		return t
+	}
+	t := &tenant{file: key.file}
+	if t.file == "" {
+		t.state = newState()
+	}
+	tenants[key] = t
+	return t
+}
+
+// AuthenticatedClient returns a client working on the local tenant described by opts and cfg
+func AuthenticatedClient(opts AuthOptions, cfg CfgOptions) (*Client, error) {
+	if opts.TenantName == "" {
+		return nil, fmt.Errorf("tenant name can't be empty")
+	}
+	if cfg.StoragePath != "" {
+		err := os.MkdirAll(cfg.StoragePath, 0700)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create storage folder '%s': %s", cfg.StoragePath, err.Error())
+		}
+	}
+	if cfg.TransitionDelay < 0 {
+		cfg.TransitionDelay = 0
+	}
+	if cfg.SSHPort == 0 {
+		cfg.SSHPort = defaultSSHPort
+	}
+	if cfg.SSHUser == "" {
+		cfg.SSHUser = api.DefaultUser
+	}
+	if cfg.MetadataBucketName == "" {
+		cfg.MetadataBucketName = api.BuildMetadataBucketName("local-" + opts.TenantName)
+	}
+
+	client := &Client{
+		Opts:   &opts,
+		Cfg:    &cfg,
+		tenant: getTenant(opts.TenantName, cfg.StoragePath),
+	}
+
+	// Creates metadata Object Storage bucket/container
+	err := metadata.InitializeBucket(client)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Build build a new Client from configuration parameter
+func (client *Client) Build(params map[string]interface{}) (api.ClientAPI, error) {
+	tenantName, _ := params["name"].(string)
+	storagePath, _ := params["StoragePath"].(string)
+	sshHost, _ := params["SSHHost"].(string)
+	sshUser, _ := params["SSHUser"].(string)
+	metadataBucket, _ := params["MetadataBucketName"].(string)
+
+	delay := defaultTransitionDelay
+	if anon, ok := params["TransitionDelay"]; ok {
+		s := fmt.Sprintf("%v", anon)
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value '%s' for 'TransitionDelay': %s", s, err.Error())
+		}
+		delay = d
+	}
+
+	sshPort := 0
+	if anon, ok := params["SSHPort"]; ok {
+		s := fmt.Sprintf("%v", anon)
+		p, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value '%s' for 'SSHPort': %s", s, err.Error())
+		}
+		sshPort = p
+	}
+
+	sshKey := ""
+	if keyFile, ok := params["SSHPrivateKeyFile"].(string); ok && keyFile != "" {
+		b, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SSH private key file '%s': %s", keyFile, err.Error())
+		}
+		sshKey = string(b)
+	}
+
+	return AuthenticatedClient(
+		AuthOptions{
+			TenantName: tenantName,
+		},
+		CfgOptions{
+			StoragePath:        storagePath,
+			TransitionDelay:    delay,
+			SSHHost:            sshHost,
+			SSHPort:            sshPort,
+			SSHUser:            sshUser,
+			SSHPrivateKey:      sshKey,
+			MetadataBucketName: metadataBucket,
+		},
+	)
+}
+
+// GetAuthOpts returns the auth options
+func (client *Client) GetAuthOpts() (api.Config, error) {
+	cfg := api.ConfigMap{}
+
+	cfg.Set("TenantName", client.Opts.TenantName)
+	return cfg, nil
+}
+
+// GetCfgOpts return configuration parameters
+func (client *Client) GetCfgOpts() (api.Config, error) {
+	cfg := api.ConfigMap{}
+
+	cfg.Set("DNSList", []string{})
+	cfg.Set("S3Protocol", "")
+	cfg.Set("AutoHostNetworkInterfaces", true)
+	cfg.Set("UseLayer3Networking", false)
+	cfg.Set("MetadataBucket", client.Cfg.MetadataBucketName)
+	cfg.Set("StoragePath", client.Cfg.StoragePath)
+	if client.Cfg.StoragePath == "" {
+		// The state is only shared inside the process, where metadata leases are shared too, so the
+		// leases are never written concurrently
+		cfg.Set("MetadataLockSettleDelay", time.Duration(0))
+		cfg.Set("MetadataLockPollDelay", time.Duration(0))
+	}
+
+	return cfg, nil
+}
+
+// view executes 'task' with a read-only access to the state of the tenant
+func (client *Client) view(task func(s *state) error) error {
+	return client.access(task, false)
+}
+
+// update executes 'task' with a read-write access to the state of the tenant;
+// if the state is persisted on disk, it's saved after 'task' succeeded
+func (client *Client) update(task func(s *state) error) error {
+	return client.access(task, true)
+}
+
+func (client *Client) access(task func(s *state) error, write bool) error {
+	t := client.tenant
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.file == "" {
+		return task(t.state)
+	}
+
+	// State persisted on disk; other processes may use it, so lock the file during the access
+	f, err := os.OpenFile(t.file, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open state file '%s': %s", t.file, err.Error())
+	}
+	defer f.Close()
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+	if err != nil {
+		return fmt.Errorf("failed to lock state file '%s': %s", t.file, err.Error())
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return fmt.Errorf("failed to read state file '%s': %s", t.file, err.Error())
+	}
+	s := newState()
+	if len(content) > 0 {
+		err = json.Unmarshal(content, s)
+		if err != nil {
+			return fmt.Errorf("failed to decode state file '%s': %s", t.file, err.Error())
+		}
+	}
+
+	err = task(s)
+	if err != nil || !write {
+		return err
+	}
+
+	content, err = json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %s", err.Error())
+	}
+	err = f.Truncate(0)
+	if err == nil {
+		_, err = f.WriteAt(content, 0)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write state file '%s': %s", t.file, err.Error())
+	}
+	return nil
+}
+
+func init() {
+	providers.Register("local", &Client{})
+}
@@ -0,0 +1,476 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/enums/HostState"
+	"github.com/CS-SI/SafeScale/providers/enums/IPVersion"
+	"github.com/CS-SI/SafeScale/providers/enums/RuleDirection"
+	"github.com/CS-SI/SafeScale/providers/enums/VolumeSpeed"
+	"github.com/CS-SI/SafeScale/providers/enums/VolumeState"
+	"github.com/CS-SI/SafeScale/providers/local"
+)
+
+// getService returns a service on a new local tenant
+func getService(t *testing.T, params map[string]interface{}) *providers.Service {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	if _, ok := params["name"]; !ok {
+		params["name"] = t.Name()
+	}
+	if _, ok := params["TransitionDelay"]; !ok {
+		params["TransitionDelay"] = "50ms"
+	}
+	clt, err := (&local.Client{}).Build(params)
+	require.Nil(t, err)
+	return providers.FromClient(clt)
+}
+
+// createNetwork creates a network with a gateway
+func createNetwork(t *testing.T, svc *providers.Service, name string) *api.Network {
+	network, err := svc.CreateNetwork(api.NetworkRequest{
+		Name:      name,
+		IPVersion: IPVersion.IPv4,
+		CIDR:      "192.168.1.0/24",
+	})
+	require.Nil(t, err)
+	img, err := svc.SearchImage("Ubuntu 16.04")
+	require.Nil(t, err)
+	_, err = svc.CreateGateway(api.GWRequest{
+		ImageID:    img.ID,
+		NetworkID:  network.ID,
+		TemplateID: "local-tiny",
+	})
+	require.Nil(t, err)
+	return network
+}
+
+func Test_NetworksAndHosts(t *testing.T) {
+	svc := getService(t, nil)
+
+	network := createNetwork(t, svc, "net1")
+	assert.Equal(t, "192.168.1.0/24", network.CIDR)
+	_, err := svc.CreateNetwork(api.NetworkRequest{Name: "net1", CIDR: "192.168.2.0/24"})
+	assert.NotNil(t, err)
+
+	nets, err := svc.ListNetworks(false)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(nets))
+	assert.NotEmpty(t, nets[0].GatewayID)
+
+	gw, err := svc.GetHost("gw-net1")
+	require.Nil(t, err)
+	assert.Equal(t, HostState.STARTED, gw.State)
+	assert.NotEmpty(t, gw.AccessIPv4)
+	assert.Equal(t, "192.168.1.2", gw.PrivateIPsV4[0])
+
+	host, err := svc.CreateHost(api.HostRequest{
+		Name:       "host1",
+		ImageID:    "local-ubuntu-1604",
+		TemplateID: "local-small",
+		NetworkIDs: []string{network.ID},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, HostState.STARTED, host.State)
+	assert.Equal(t, gw.ID, host.GatewayID)
+	assert.Empty(t, host.AccessIPv4)
+	assert.Equal(t, "192.168.1.3", host.PrivateIPsV4[0])
+
+	// The gateway is listed with the hosts
+	hosts, err := svc.ListHosts(false)
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(hosts))
+
+	// A network can't be deleted while hosts are connected
+	err = svc.DeleteNetwork(network.ID)
+	assert.NotNil(t, err)
+
+	err = svc.DeleteHost(host.ID)
+	require.Nil(t, err)
+	err = svc.DeleteNetwork(network.ID)
+	require.Nil(t, err)
+
+	nets, err = svc.ListNetworks(true)
+	require.Nil(t, err)
+	assert.Empty(t, nets)
+	hosts, err = svc.ListHosts(true)
+	require.Nil(t, err)
+	assert.Empty(t, hosts)
+}
+
+func Test_PrivateHostNeedsGateway(t *testing.T) {
+	svc := getService(t, nil)
+
+	network, err := svc.CreateNetwork(api.NetworkRequest{Name: "net", CIDR: "10.0.0.0/16"})
+	require.Nil(t, err)
+	_, err = svc.CreateHost(api.HostRequest{
+		Name:       "host",
+		ImageID:    "local-ubuntu-1604",
+		TemplateID: "local-small",
+		NetworkIDs: []string{network.ID},
+	})
+	assert.NotNil(t, err)
+}
+
+func Test_CanceledHostCreation(t *testing.T) {
+	svc := getService(t, map[string]interface{}{"TransitionDelay": "500ms"})
+
+	network := createNetwork(t, svc, "net")
+	keyPairs, err := svc.ListKeyPairs()
+	require.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = svc.CreateHostWithContext(ctx, api.HostRequest{
+		Name:       "host",
+		ImageID:    "local-ubuntu-1604",
+		TemplateID: "local-small",
+		NetworkIDs: []string{network.ID},
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// The host being created has been removed
+	hosts, err := svc.ListHosts(true)
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(hosts))
+	_, err = svc.GetHost("host")
+	assert.NotNil(t, err)
+	// So has the key pair created for it
+	left, err := svc.ListKeyPairs()
+	require.Nil(t, err)
+	assert.Equal(t, len(keyPairs), len(left))
+}
+
+func Test_WaitVolumeStateGivesUp(t *testing.T) {
+	svc := getService(t, map[string]interface{}{"TransitionDelay": "5s"})
+
+	v, err := svc.CreateVolume(api.VolumeRequest{Name: "vol1", Size: 10})
+	require.Nil(t, err)
+
+	begin := time.Now()
+	_, err = svc.WaitVolumeState(v.ID, VolumeState.AVAILABLE, 200*time.Millisecond)
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(begin) < 2*time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	begin = time.Now()
+	_, err = svc.WaitVolumeStateWithContext(ctx, v.ID, VolumeState.AVAILABLE, time.Minute)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(begin) < 2*time.Second)
+}
+
+func Test_StartStopHost(t *testing.T) {
+	svc := getService(t, nil)
+
+	createNetwork(t, svc, "net")
+	host, err := svc.GetHost("gw-net")
+	require.Nil(t, err)
+
+	err = svc.StopHost(host.ID)
+	require.Nil(t, err)
+	h, err := svc.GetHost(host.ID)
+	require.Nil(t, err)
+	assert.Equal(t, HostState.STOPPING, h.State)
+	// Starting a stopping host is not allowed
+	assert.NotNil(t, svc.StartHost(host.ID))
+
+	time.Sleep(100 * time.Millisecond)
+	h, err = svc.GetHost(host.ID)
+	require.Nil(t, err)
+	assert.Equal(t, HostState.STOPPED, h.State)
+
+	err = svc.StartHost(host.ID)
+	require.Nil(t, err)
+	h, err = svc.WaitHostState(host.ID, HostState.STARTED, 5*time.Second)
+	require.Nil(t, err)
+	assert.Equal(t, HostState.STARTED, h.State)
+}
+
+func Test_VolumeAttachment(t *testing.T) {
+	svc := getService(t, nil)
+
+	createNetwork(t, svc, "net")
+	host, err := svc.GetHost("gw-net")
+	require.Nil(t, err)
+
+	v, err := svc.CreateVolume(api.VolumeRequest{
+		Name:  "vol1",
+		Size:  100,
+		Speed: VolumeSpeed.HDD,
+	})
+	require.Nil(t, err)
+	assert.Equal(t, VolumeState.CREATING, v.State)
+
+	// Volume is not available yet
+	_, err = svc.CreateVolumeAttachment(api.VolumeAttachmentRequest{Name: "att", ServerID: host.ID, VolumeID: v.ID})
+	assert.NotNil(t, err)
+
+	_, err = svc.WaitVolumeState(v.ID, VolumeState.AVAILABLE, 5*time.Second)
+	require.Nil(t, err)
+	va, err := svc.CreateVolumeAttachment(api.VolumeAttachmentRequest{Name: "att", ServerID: host.ID, VolumeID: v.ID})
+	require.Nil(t, err)
+	assert.Equal(t, "/dev/vdb", va.Device)
+
+	_, err = svc.WaitVolumeState(v.ID, VolumeState.USED, 5*time.Second)
+	require.Nil(t, err)
+	lst, err := svc.ListVolumeAttachments(host.ID)
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(lst))
+
+	// Attached volumes can't be deleted, nor the host they are attached to
+	assert.NotNil(t, svc.DeleteVolume(v.ID))
+	assert.NotNil(t, svc.DeleteHost(host.ID))
+
+	err = svc.DeleteVolumeAttachment(host.ID, va.ID)
+	require.Nil(t, err)
+	_, err = svc.WaitVolumeState(v.ID, VolumeState.AVAILABLE, 5*time.Second)
+	require.Nil(t, err)
+	err = svc.DeleteVolume(v.ID)
+	require.Nil(t, err)
+
+	vols, err := svc.ListVolumes(false)
+	require.Nil(t, err)
+	assert.Empty(t, vols)
+}
+
+func Test_VolumeSnapshots(t *testing.T) {
+	svc := getService(t, nil)
+
+	v, err := svc.CreateVolume(api.VolumeRequest{Name: "vol1", Size: 10})
+	require.Nil(t, err)
+	// Volume is not available yet
+	_, err = svc.CreateVolumeSnapshot(api.SnapshotRequest{Name: "snap1", VolumeID: v.ID})
+	assert.NotNil(t, err)
+	_, err = svc.WaitVolumeState(v.ID, VolumeState.AVAILABLE, 5*time.Second)
+	require.Nil(t, err)
+
+	s1, err := svc.CreateVolumeSnapshot(api.SnapshotRequest{Name: "snap1", VolumeID: v.ID})
+	require.Nil(t, err)
+	assert.Equal(t, 10, s1.Size)
+	assert.Equal(t, VolumeState.AVAILABLE, s1.State)
+	s2, err := svc.CreateVolumeSnapshot(api.SnapshotRequest{Name: "snap2", VolumeID: "vol1"})
+	require.Nil(t, err)
+	assert.Equal(t, v.ID, s2.VolumeID)
+	_, err = svc.CreateVolumeSnapshot(api.SnapshotRequest{Name: "snap3", VolumeID: "unknown"})
+	assert.NotNil(t, err)
+
+	lst, err := svc.ListVolumeSnapshots(v.ID)
+	require.Nil(t, err)
+	require.Len(t, lst, 2)
+	assert.Equal(t, "snap1", lst[0].Name)
+	lst, err = svc.ListVolumeSnapshots("other")
+	require.Nil(t, err)
+	assert.Empty(t, lst)
+
+	_, err = svc.CreateVolumeFromSnapshot(s1.ID, api.VolumeRequest{Name: "vol2", Size: 5})
+	assert.NotNil(t, err)
+	v2, err := svc.CreateVolumeFromSnapshot(s1.ID, api.VolumeRequest{Name: "vol2"})
+	require.Nil(t, err)
+	assert.Equal(t, 10, v2.Size)
+
+	require.Nil(t, svc.DeleteVolumeSnapshot(s1.ID))
+	assert.NotNil(t, svc.DeleteVolumeSnapshot(s1.ID))
+	_, err = svc.GetVolumeSnapshot(s1.ID)
+	assert.NotNil(t, err)
+	s, err := svc.GetVolumeSnapshot(s2.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "snap2", s.Name)
+}
+
+func Test_SecurityGroups(t *testing.T) {
+	svc := getService(t, nil)
+
+	sg, err := svc.CreateSecurityGroup(api.SecurityGroupRequest{Name: "web", Description: "web servers"})
+	require.Nil(t, err)
+	// New groups allow outgoing traffic
+	assert.Equal(t, 2, len(sg.Rules))
+	_, err = svc.CreateSecurityGroup(api.SecurityGroupRequest{Name: "web"})
+	assert.NotNil(t, err)
+
+	rule, err := svc.AddSecurityGroupRule(sg.ID, api.SecurityGroupRule{
+		Direction: RuleDirection.INGRESS,
+		IPVersion: IPVersion.IPv4,
+		Protocol:  "tcp",
+		PortFrom:  443,
+		PortTo:    443,
+		CIDR:      "0.0.0.0/0",
+	})
+	require.Nil(t, err)
+	assert.NotEmpty(t, rule.ID)
+	_, err = svc.AddSecurityGroupRule(sg.ID, *rule)
+	assert.NotNil(t, err)
+
+	found, err := svc.GetSecurityGroup("web")
+	require.Nil(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, 3, len(found.Rules))
+
+	network := createNetwork(t, svc, "net1")
+	host, err := svc.CreateHost(api.HostRequest{
+		Name:           "host1",
+		ImageID:        "local-ubuntu-1604",
+		TemplateID:     "local-small",
+		NetworkIDs:     []string{network.ID},
+		SecurityGroups: []string{sg.ID},
+	})
+	require.Nil(t, err)
+
+	// A group bound to a host can't be deleted
+	err = svc.DeleteSecurityGroup(sg.ID)
+	assert.NotNil(t, err)
+	err = svc.DeleteHost(host.ID)
+	require.Nil(t, err)
+
+	err = svc.DeleteSecurityGroupRule(sg.ID, rule.ID)
+	require.Nil(t, err)
+	found, err = svc.GetSecurityGroup(sg.ID)
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(found.Rules))
+
+	err = svc.DeleteSecurityGroup(sg.ID)
+	require.Nil(t, err)
+	found, err = svc.GetSecurityGroup("web")
+	require.Nil(t, err)
+	assert.Nil(t, found)
+}
+
+func Test_VIPs(t *testing.T) {
+	svc := getService(t, nil)
+	network := createNetwork(t, svc, "net1")
+
+	vip, err := svc.CreateVIP(network.ID, "nas-vip")
+	require.Nil(t, err)
+	assert.True(t, strings.HasPrefix(vip.PrivateIP, "192.168.1."))
+
+	host, err := svc.CreateHost(api.HostRequest{
+		Name:       "host1",
+		ImageID:    "local-ubuntu-1604",
+		TemplateID: "local-small",
+		NetworkIDs: []string{network.ID},
+	})
+	require.Nil(t, err)
+	assert.NotEqual(t, vip.PrivateIP, host.PrivateIPsV4[0])
+
+	err = svc.BindHostToVIP(vip, host.ID)
+	require.Nil(t, err)
+	// Binding twice doesn't duplicate the host
+	err = svc.BindHostToVIP(vip, host.ID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{host.ID}, vip.Hosts)
+
+	err = svc.BindHostToVIP(vip, "unknown")
+	assert.NotNil(t, err)
+
+	err = svc.UnbindHostFromVIP(vip, host.ID)
+	require.Nil(t, err)
+	assert.Empty(t, vip.Hosts)
+
+	err = svc.DeleteVIP(vip)
+	require.Nil(t, err)
+	err = svc.DeleteVIP(vip)
+	assert.NotNil(t, err)
+}
+
+func Test_Objects(t *testing.T) {
+	svc := getService(t, nil)
+
+	err := svc.CreateContainer("testC")
+	require.Nil(t, err)
+	err = svc.PutObject("testC", api.Object{
+		Content:  strings.NewReader("123456789"),
+		DeleteAt: time.Now().Add(200 * time.Millisecond),
+		Metadata: map[string]string{"A": "B"},
+		Name:     "dir/object1",
+	})
+	require.Nil(t, err)
+	err = svc.PutObject("testC", api.Object{
+		Content: strings.NewReader("abc"),
+		Name:    "dir/sub/object2",
+	})
+	require.Nil(t, err)
+
+	o, err := svc.GetObject("testC", "dir/object1", []api.Range{
+		api.NewRange(0, 2),
+		api.NewRange(4, 7),
+	})
+	require.Nil(t, err)
+	var buff bytes.Buffer
+	_, err = buff.ReadFrom(o.Content)
+	require.Nil(t, err)
+	assert.Equal(t, "1235678", buff.String())
+	assert.Equal(t, "B", o.Metadata["A"])
+
+	list, err := svc.ListObjects("testC", api.ObjectFilter{Path: "dir"})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"dir/object1"}, list)
+	list, err = svc.ListObjects("testC", api.ObjectFilter{Prefix: "dir/"})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"dir/object1", "dir/sub/object2"}, list)
+
+	// Not empty
+	assert.NotNil(t, svc.DeleteContainer("testC"))
+
+	time.Sleep(300 * time.Millisecond)
+	_, err = svc.GetObject("testC", "dir/object1", nil)
+	assert.NotNil(t, err)
+	require.Nil(t, svc.DeleteObject("testC", "dir/sub/object2"))
+	require.Nil(t, svc.DeleteContainer("testC"))
+}
+
+func Test_SharedState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safescale-local")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	params := map[string]interface{}{"name": "shared", "StoragePath": dir}
+	svc1 := getService(t, params)
+	_, err = svc1.CreateVolume(api.VolumeRequest{Name: "vol", Size: 10})
+	require.Nil(t, err)
+
+	// Another client on the same tenant sees the same resources
+	svc2 := getService(t, map[string]interface{}{"name": "shared", "StoragePath": dir})
+	v, err := svc2.GetVolume("vol")
+	require.Nil(t, err)
+	assert.Equal(t, 10, v.Size)
+	_, err = os.Stat(dir + "/shared.json")
+	assert.Nil(t, err)
+
+	// A tenant with the same name stored elsewhere is distinct
+	other, err := ioutil.TempDir("", "safescale-local")
+	require.Nil(t, err)
+	defer os.RemoveAll(other)
+	svc3 := getService(t, map[string]interface{}{"name": "shared", "StoragePath": other})
+	_, err = svc3.GetVolume("vol")
+	assert.NotNil(t, err)
+	svc4 := getService(t, map[string]interface{}{"name": "shared"})
+	_, err = svc4.GetVolume("vol")
+	assert.NotNil(t, err)
+}
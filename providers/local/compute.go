@@ -0,0 +1,560 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/enums/HostState"
+	"github.com/CS-SI/SafeScale/providers/metadata"
+
+	"github.com/CS-SI/SafeScale/system"
+
+	"github.com/CS-SI/SafeScale/utils/retry"
+)
+
+var (
+	// images are the OS images proposed by the local provider
+	images = []api.Image{
+		{ID: "local-ubuntu-1604", Name: "Ubuntu 16.04"},
+		{ID: "local-ubuntu-1804", Name: "Ubuntu 18.04"},
+		{ID: "local-centos-7", Name: "CentOS 7.3"},
+		{ID: "local-debian-9", Name: "Debian 9"},
+	}
+
+	// templates are the host templates proposed by the local provider
+	templates = []api.HostTemplate{
+		{ID: "local-tiny", Name: "tiny", HostSize: api.HostSize{Cores: 1, RAMSize: 1, DiskSize: 20}},
+		{ID: "local-small", Name: "small", HostSize: api.HostSize{Cores: 2, RAMSize: 4, DiskSize: 50}},
+		{ID: "local-medium", Name: "medium", HostSize: api.HostSize{Cores: 4, RAMSize: 8, DiskSize: 100}},
+		{ID: "local-large", Name: "large", HostSize: api.HostSize{Cores: 8, RAMSize: 32, DiskSize: 200}},
+		{ID: "local-gpu", Name: "gpu", HostSize: api.HostSize{Cores: 8, RAMSize: 64, DiskSize: 200, GPUNumber: 1, GPUType: "Local GPU"}},
+	}
+)
+
+const (
+	// publicCIDR is the range used to allocate public IP addresses (TEST-NET-3, RFC 5737)
+	publicCIDR = "203.0.113.0/24"
+)
+
+// ListImages lists available OS images
+func (client *Client) ListImages(all bool) ([]api.Image, error) {
+	list := make([]api.Image, len(images))
+	copy(list, images)
+	return list, nil
+}
+
+// GetImage returns the Image referenced by id
+func (client *Client) GetImage(id string) (*api.Image, error) {
+	for _, img := range images {
+		if img.ID == id {
+			i := img
+			return &i, nil
+		}
+	}
+	return nil, providers.ResourceNotFoundError("image", id)
+}
+
+// GetTemplate returns the Template referenced by id
+func (client *Client) GetTemplate(id string) (*api.HostTemplate, error) {
+	for _, tpl := range templates {
+		if tpl.ID == id {
+			t := tpl
+			return &t, nil
+		}
+	}
+	return nil, providers.ResourceNotFoundError("template", id)
+}
+
+// ListTemplates lists available host templates
+func (client *Client) ListTemplates(all bool) ([]api.HostTemplate, error) {
+	list := make([]api.HostTemplate, len(templates))
+	copy(list, templates)
+	return list, nil
+}
+
+// CreateKeyPair creates and import a key pair
+func (client *Client) CreateKeyPair(name string) (*api.KeyPair, error) {
+	pub, priv, err := system.CreateKeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("Error creating key pair: %s", err.Error())
+	}
+	kp := api.KeyPair{
+		ID:         name,
+		Name:       name,
+		PublicKey:  string(pub),
+		PrivateKey: string(priv),
+	}
+	err = client.update(func(s *state) error {
+		if _, ok := s.KeyPairs[name]; ok {
+			return providers.ResourceAlreadyExistsError("key pair", name)
+		}
+		// As with real providers, the private key isn't kept
+		s.KeyPairs[name] = api.KeyPair{
+			ID:        kp.ID,
+			Name:      kp.Name,
+			PublicKey: kp.PublicKey,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &kp, nil
+}
+
+// GetKeyPair returns the key pair identified by id
+func (client *Client) GetKeyPair(id string) (*api.KeyPair, error) {
+	var kp *api.KeyPair
+	err := client.view(func(s *state) error {
+		if k, ok := s.KeyPairs[id]; ok {
+			kp = &k
+			return nil
+		}
+		return providers.ResourceNotFoundError("key pair", id)
+	})
+	return kp, err
+}
+
+// ListKeyPairs lists available key pairs
+func (client *Client) ListKeyPairs() ([]api.KeyPair, error) {
+	var list []api.KeyPair
+	err := client.view(func(s *state) error {
+		for _, kp := range s.KeyPairs {
+			list = append(list, kp)
+		}
+		return nil
+	})
+	return list, err
+}
+
+// DeleteKeyPair deletes the key pair identified by id
+func (client *Client) DeleteKeyPair(id string) error {
+	return client.update(func(s *state) error {
+		if _, ok := s.KeyPairs[id]; !ok {
+			return providers.ResourceNotFoundError("key pair", id)
+		}
+		delete(s.KeyPairs, id)
+		return nil
+	})
+}
+
+// allocateIP returns the next free IP address of cidr, 'last' being the last host part allocated
+func allocateIP(cidr string, last *uint32) (string, error) {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", fmt.Errorf("invalid CIDR '%s': %s", cidr, err.Error())
+	}
+	ip4 := ipnet.IP.To4()
+	if ip4 == nil {
+		return "", fmt.Errorf("only IPv4 CIDR are supported by local provider")
+	}
+	ones, bits := ipnet.Mask.Size()
+	size := uint32(1) << uint(bits-ones)
+	// .0 is the network address, .1 is kept for the (virtual) router, last address is broadcast
+	next := *last + 1
+	if next < 2 {
+		next = 2
+	}
+	if next >= size-1 {
+		return "", fmt.Errorf("no more IP address available in '%s'", cidr)
+	}
+	*last = next
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, binary.BigEndian.Uint32(ip4)+next)
+	return ip.String(), nil
+}
+
+// readGateway returns the gateway of the network identified by networkID
+func (client *Client) readGateway(networkID string) (*api.Host, error) {
+	m, err := metadata.LoadGateway(providers.FromClient(client), networkID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, fmt.Errorf("unable to find gateway of network '%s'", networkID)
+	}
+	return m.Get(), nil
+}
+
+// CreateHost creates an host satisfying request
+func (client *Client) CreateHost(request api.HostRequest) (*api.Host, error) {
+	return client.CreateHostWithContext(context.Background(), request)
+}
+
+// CreateHostWithContext creates an host satisfying request, removing it if ctx is done before it is ready
+func (client *Client) CreateHostWithContext(ctx context.Context, request api.HostRequest) (*api.Host, error) {
+	host, err := client.createHost(ctx, request, false)
+	if err != nil {
+		return nil, err
+	}
+
+	err = metadata.SaveHost(providers.FromClient(client), host, request.NetworkIDs[0])
+	if err != nil {
+		client.DeleteHost(host.ID)
+		return nil, fmt.Errorf("error creating host: %s", err.Error())
+	}
+	if ctx.Err() != nil {
+		client.DeleteHost(host.ID)
+		return nil, ctx.Err()
+	}
+	return host, nil
+}
+
+// createHost ...
+func (client *Client) createHost(ctx context.Context, request api.HostRequest, isGateway bool) (*api.Host, error) {
+	if len(request.NetworkIDs) == 0 {
+		return nil, fmt.Errorf("Error creating Host: no network given")
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	// We 1st check if name is not already used
+	m, err := metadata.LoadHost(providers.FromClient(client), request.Name)
+	if err != nil {
+		return nil, err
+	}
+	if m != nil {
+		return nil, fmt.Errorf("A host already exists with name '%s'", request.Name)
+	}
+
+	// If the host is not public it has to be created on a network owning a Gateway
+	var gw *api.Host
+	if !request.PublicIP {
+		gw, err = client.readGateway(request.NetworkIDs[0])
+		if err != nil {
+			return nil, fmt.Errorf("no private host can be created on a network without gateway")
+		}
+	}
+
+	tpl, err := client.GetTemplate(request.TemplateID)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating Host: %s", err.Error())
+	}
+	_, err = client.GetImage(request.ImageID)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating Host: %s", err.Error())
+	}
+
+	// Prepare key pair; the one created for the host is deleted if the host can't be created
+	kp := request.KeyPair
+	deleteKeyPair := func() {}
+	if kp == nil {
+		id, _ := uuid.NewV4()
+		name := fmt.Sprintf("%s_%s", request.Name, id)
+		kp, err = client.CreateKeyPair(name)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating Host: %s", err.Error())
+		}
+		deleteKeyPair = func() { client.DeleteKeyPair(kp.ID) }
+	}
+
+	id, _ := uuid.NewV4()
+	host := api.Host{
+		ID:         id.String(),
+		Name:       request.Name,
+		Size:       tpl.HostSize,
+		PrivateKey: kp.PrivateKey,
+	}
+	if gw != nil {
+		host.GatewayID = gw.ID
+	}
+
+	err = client.update(func(s *state) error {
+		if s.findHost(request.Name) != nil {
+			return providers.ResourceAlreadyExistsError("host", request.Name)
+		}
+		for _, netID := range request.NetworkIDs {
+			n, ok := s.Networks[netID]
+			if !ok {
+				return providers.ResourceNotFoundError("network", netID)
+			}
+			ip, err := allocateIP(n.Network.CIDR, &n.LastIP)
+			if err != nil {
+				return err
+			}
+			host.PrivateIPsV4 = append(host.PrivateIPsV4, ip)
+		}
+		for _, id := range request.SecurityGroups {
+			if _, ok := s.SecurityGroups[id]; !ok {
+				return providers.ResourceNotFoundError("security group", id)
+			}
+		}
+		if request.PublicIP {
+			ip, err := allocateIP(publicCIDR, &s.LastPublicIP)
+			if err != nil {
+				return err
+			}
+			host.AccessIPv4 = ip
+		}
+		e := &hostEntry{
+			Host:           host,
+			Devices:        map[string]string{},
+			SecurityGroups: request.SecurityGroups,
+		}
+		e.transition(HostState.STARTING, HostState.STARTED, client.Cfg.TransitionDelay)
+		s.Hosts[host.ID] = e
+		return nil
+	})
+	if err != nil {
+		deleteKeyPair()
+		return nil, fmt.Errorf("Error creating Host: %s", err.Error())
+	}
+
+	// As real providers, returns the host only when it is started
+	started, err := client.WaitHostReadyWithContext(ctx, host.ID, client.Cfg.TransitionDelay+time.Minute)
+	if err != nil {
+		client.deleteHostEntry(host.ID)
+		deleteKeyPair()
+		if err == ctx.Err() {
+			return nil, err
+		}
+		return nil, fmt.Errorf("Error creating Host: %s", err.Error())
+	}
+	return started, nil
+}
+
+// WaitHostReady waits an host achieve ready state
+func (client *Client) WaitHostReady(hostID string, timeout time.Duration) (*api.Host, error) {
+	return client.WaitHostReadyWithContext(context.Background(), hostID, timeout)
+}
+
+// WaitHostReadyWithContext waits an host achieve ready state, giving up if ctx is done
+func (client *Client) WaitHostReadyWithContext(ctx context.Context, hostID string, timeout time.Duration) (*api.Host, error) {
+	var (
+		host   *api.Host
+		broken bool
+	)
+	retryErr := retry.WhileUnsuccessfulWithContext(
+		ctx,
+		func() error {
+			var err error
+			host, err = client.getHostEntry(hostID)
+			if err != nil {
+				return err
+			}
+			if host.State == HostState.ERROR {
+				broken = true
+				return nil
+			}
+			if host.State != HostState.STARTED {
+				return fmt.Errorf("host '%s' is in state '%s'", host.Name, host.State.String())
+			}
+			return nil
+		},
+		pollDelay(client.Cfg.TransitionDelay),
+		timeout,
+	)
+	if retryErr != nil {
+		return nil, retryErr
+	}
+	if broken {
+		return nil, fmt.Errorf("host '%s' is in '%s' state", host.Name, host.State.String())
+	}
+	return host, nil
+}
+
+// pollDelay returns the delay to use between 2 polls of a resource in transient state
+func pollDelay(transition time.Duration) time.Duration {
+	delay := transition / 4
+	if delay < 10*time.Millisecond {
+		delay = 10 * time.Millisecond
+	}
+	if delay > time.Second {
+		delay = time.Second
+	}
+	return delay
+}
+
+// getHostEntry returns a copy of the host identified by ref, with its current state
+func (client *Client) getHostEntry(ref string) (*api.Host, error) {
+	var host *api.Host
+	err := client.view(func(s *state) error {
+		e := s.findHost(ref)
+		if e == nil {
+			return providers.ResourceNotFoundError("host", ref)
+		}
+		e.settle(time.Now())
+		h := e.Host
+		host = &h
+		return nil
+	})
+	return host, err
+}
+
+// GetHost returns the host identified by ref (id or name)
+func (client *Client) GetHost(ref string) (*api.Host, error) {
+	host, err := client.getHostEntry(ref)
+	if err != nil {
+		return nil, err
+	}
+	m, err := metadata.LoadHost(providers.FromClient(client), host.ID)
+	if err == nil && m != nil {
+		hostDef := m.Get()
+		host.GatewayID = hostDef.GatewayID
+		host.PrivateKey = hostDef.PrivateKey
+		host.HostKey = hostDef.HostKey
+		host.Extension = hostDef.Extension
+	}
+	return host, nil
+}
+
+// ListHosts lists available hosts
+func (client *Client) ListHosts(all bool) ([]api.Host, error) {
+	if all {
+		var hosts []api.Host
+		err := client.view(func(s *state) error {
+			now := time.Now()
+			for _, e := range s.Hosts {
+				e.settle(now)
+				hosts = append(hosts, e.Host)
+			}
+			return nil
+		})
+		return hosts, err
+	}
+
+	// Only hosts created by SafeScale (ie registered in object storage)
+	var hosts []api.Host
+	m := metadata.NewHost(providers.FromClient(client))
+	err := m.Browse(func(host *api.Host) error {
+		hosts = append(hosts, *host)
+		return nil
+	})
+	return hosts, err
+}
+
+// deleteHostEntry removes the host from the local tenant
+func (client *Client) deleteHostEntry(id string) error {
+	return client.update(func(s *state) error {
+		e := s.findHost(id)
+		if e == nil {
+			return providers.ResourceNotFoundError("host", id)
+		}
+		if len(e.Devices) > 0 {
+			return fmt.Errorf("host '%s' has %d volume(s) attached", e.Host.Name, len(e.Devices))
+		}
+		delete(s.Hosts, e.Host.ID)
+		return nil
+	})
+}
+
+// DeleteHost deletes the host identified by ref (id or name)
+func (client *Client) DeleteHost(ref string) error {
+	m, err := metadata.LoadHost(providers.FromClient(client), ref)
+	if err != nil {
+		return err
+	}
+
+	id := ref
+	if m != nil {
+		id = m.Get().ID
+	}
+	err = client.deleteHostEntry(id)
+	if err != nil {
+		return fmt.Errorf("error deleting host '%s': %s", ref, err.Error())
+	}
+	if m == nil {
+		return nil
+	}
+	return metadata.RemoveHost(providers.FromClient(client), m.Get())
+}
+
+// changeHostState starts a transition of the host identified by ref
+func (client *Client) changeHostState(ref string, action string, allowed []HostState.Enum, from, to HostState.Enum) error {
+	return client.update(func(s *state) error {
+		e := s.findHost(ref)
+		if e == nil {
+			return providers.ResourceNotFoundError("host", ref)
+		}
+		e.settle(time.Now())
+		for _, st := range allowed {
+			if e.Host.State == st {
+				e.transition(from, to, client.Cfg.TransitionDelay)
+				return nil
+			}
+		}
+		return fmt.Errorf("error %s host '%s': host is in state '%s'", action, e.Host.Name, e.Host.State.String())
+	})
+}
+
+// StopHost stops the host identified by ref
+func (client *Client) StopHost(ref string) error {
+	return client.changeHostState(ref, "stopping", []HostState.Enum{HostState.STARTED}, HostState.STOPPING, HostState.STOPPED)
+}
+
+// StartHost starts the host identified by ref
+func (client *Client) StartHost(ref string) error {
+	return client.changeHostState(ref, "starting", []HostState.Enum{HostState.STOPPED}, HostState.STARTING, HostState.STARTED)
+}
+
+// RebootHost reboots the host identified by ref
+func (client *Client) RebootHost(ref string) error {
+	return client.changeHostState(ref, "rebooting", []HostState.Enum{HostState.STARTED, HostState.STOPPED}, HostState.STARTING, HostState.STARTED)
+}
+
+func (client *Client) getSSHConfig(host *api.Host) (*system.SSHConfig, error) {
+	// If a SSH endpoint is configured, every host is reached through it
+	if client.Cfg.SSHHost != "" {
+		return &system.SSHConfig{
+			PrivateKey: client.Cfg.SSHPrivateKey,
+			Port:       client.Cfg.SSHPort,
+			Host:       client.Cfg.SSHHost,
+			User:       client.Cfg.SSHUser,
+		}, nil
+	}
+
+	sshConfig := system.SSHConfig{
+		PrivateKey: host.PrivateKey,
+		HostKey:    host.HostKey,
+		Port:       22,
+		Host:       host.GetAccessIP(),
+		User:       api.DefaultUser,
+	}
+	if host.GatewayID != "" {
+		gw, err := client.GetHost(host.GatewayID)
+		if err != nil {
+			return nil, err
+		}
+		sshConfig.GatewayConfig = &system.SSHConfig{
+			PrivateKey: gw.PrivateKey,
+			HostKey:    gw.HostKey,
+			Port:       22,
+			User:       api.DefaultUser,
+			Host:       gw.GetAccessIP(),
+		}
+	}
+	return &sshConfig, nil
+}
+
+// GetSSHConfig creates SSHConfig to connect an host
+func (client *Client) GetSSHConfig(id string) (*system.SSHConfig, error) {
+	host, err := client.GetHost(id)
+	if err != nil {
+		return nil, err
+	}
+	return client.getSSHConfig(host)
+}
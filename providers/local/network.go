@@ -0,0 +1,267 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/enums/IPVersion"
+	"github.com/CS-SI/SafeScale/providers/metadata"
+)
+
+// CreateNetwork creates a network named name
+func (client *Client) CreateNetwork(req api.NetworkRequest) (*api.Network, error) {
+	// We 1st check if name is not already used
+	m, err := metadata.LoadNetwork(providers.FromClient(client), req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if m != nil {
+		return nil, fmt.Errorf("A network already exist with name '%s'", req.Name)
+	}
+
+	if req.IPVersion == IPVersion.IPv6 {
+		return nil, fmt.Errorf("Error creating network %s: IPv6 is not supported by local provider", req.Name)
+	}
+	_, ipnet, err := net.ParseCIDR(req.CIDR)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating network %s: invalid CIDR '%s'", req.Name, req.CIDR)
+	}
+
+	id, _ := uuid.NewV4()
+	network := api.Network{
+		ID:        id.String(),
+		Name:      req.Name,
+		CIDR:      ipnet.String(),
+		IPVersion: IPVersion.IPv4,
+	}
+	err = client.update(func(s *state) error {
+		if s.findNetwork(req.Name) != nil {
+			return providers.ResourceAlreadyExistsError("network", req.Name)
+		}
+		s.Networks[network.ID] = &networkEntry{Network: network}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error creating network %s: %s", req.Name, err.Error())
+	}
+
+	err = metadata.SaveNetwork(providers.FromClient(client), &network)
+	if err != nil {
+		client.deleteNetworkEntry(network.ID)
+		return nil, err
+	}
+	return &network, nil
+}
+
+// GetNetwork returns the network identified by ref (id or name)
+func (client *Client) GetNetwork(ref string) (*api.Network, error) {
+	// We first try looking for network from metadata
+	m, err := metadata.LoadNetwork(providers.FromClient(client), ref)
+	if err != nil {
+		return nil, err
+	}
+	if m != nil {
+		return m.Get(), nil
+	}
+
+	// If not found, we look for any network of the tenant
+	var network *api.Network
+	err = client.view(func(s *state) error {
+		if e := s.findNetwork(ref); e != nil {
+			n := e.Network
+			network = &n
+		}
+		return nil
+	})
+	return network, err
+}
+
+// ListNetworks lists available networks
+func (client *Client) ListNetworks(all bool) ([]api.Network, error) {
+	if all {
+		var list []api.Network
+		err := client.view(func(s *state) error {
+			for _, e := range s.Networks {
+				list = append(list, e.Network)
+			}
+			return nil
+		})
+		return list, err
+	}
+
+	// Only networks created by SafeScale (ie registered in object storage)
+	var list []api.Network
+	m := metadata.NewNetwork(providers.FromClient(client))
+	err := m.Browse(func(network *api.Network) error {
+		mgw, err := metadata.LoadGateway(providers.FromClient(client), network.ID)
+		if err == nil && mgw != nil {
+			network.GatewayID = mgw.Get().ID
+		}
+		list = append(list, *network)
+		return nil
+	})
+	return list, err
+}
+
+// deleteNetworkEntry removes the network from the local tenant
+func (client *Client) deleteNetworkEntry(id string) error {
+	return client.update(func(s *state) error {
+		if _, ok := s.Networks[id]; !ok {
+			return providers.ResourceNotFoundError("network", id)
+		}
+		for _, e := range s.Hosts {
+			for _, ip := range e.Host.PrivateIPsV4 {
+				if belongsTo(ip, s.Networks[id].Network.CIDR) {
+					return fmt.Errorf("host '%s' is still connected to the network", e.Host.Name)
+				}
+			}
+		}
+		delete(s.Networks, id)
+		return nil
+	})
+}
+
+// belongsTo tells if ip is inside cidr
+func belongsTo(ip string, cidr string) bool {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false
+	}
+	return ipnet.Contains(net.ParseIP(ip))
+}
+
+// DeleteNetwork deletes the network identified by ref (id or name)
+func (client *Client) DeleteNetwork(ref string) error {
+	m, err := metadata.LoadNetwork(providers.FromClient(client), ref)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return fmt.Errorf("Failed to find network '%s' in metadata", ref)
+	}
+	network := m.Get()
+
+	hosts, err := m.ListHosts()
+	if err != nil {
+		return err
+	}
+	var allhosts []string
+	for _, h := range hosts {
+		if h.ID != network.GatewayID {
+			allhosts = append(allhosts, h.Name)
+		}
+	}
+	if len(allhosts) > 0 {
+		var lenS string
+		if len(allhosts) > 1 {
+			lenS = "s"
+		}
+		return fmt.Errorf("network '%s' has %d host%s attached (%s)", ref, len(allhosts), lenS, strings.Join(allhosts, ","))
+	}
+
+	err = client.DeleteGateway(network.ID)
+	if err != nil {
+		log.Warnf("Error deleting gateway: %s", err.Error())
+	}
+
+	err = client.deleteNetworkEntry(network.ID)
+	if err != nil {
+		return fmt.Errorf("Error deleting network: %s", err.Error())
+	}
+	err = m.Delete()
+	if err != nil {
+		return fmt.Errorf("Error deleting network: %s", err.Error())
+	}
+	return nil
+}
+
+// CreateGateway creates a public Gateway for a private network
+func (client *Client) CreateGateway(req api.GWRequest) (*api.Host, error) {
+	return client.CreateGatewayWithContext(context.Background(), req)
+}
+
+// CreateGatewayWithContext creates a public Gateway for a private network, removing it if ctx is done before it is ready
+func (client *Client) CreateGatewayWithContext(ctx context.Context, req api.GWRequest) (*api.Host, error) {
+	// Ensure network exists
+	network, err := client.GetNetwork(req.NetworkID)
+	if err != nil {
+		return nil, fmt.Errorf("Network %s not found %s", req.NetworkID, err.Error())
+	}
+	if network == nil {
+		return nil, fmt.Errorf("Network %s not found", req.NetworkID)
+	}
+	gwname := req.GWName
+	if gwname == "" {
+		gwname = "gw-" + network.Name
+	}
+	hostReq := api.HostRequest{
+		ImageID:        req.ImageID,
+		KeyPair:        req.KeyPair,
+		Name:           gwname,
+		TemplateID:     req.TemplateID,
+		NetworkIDs:     []string{req.NetworkID},
+		PublicIP:       true,
+		SecurityGroups: req.SecurityGroups,
+	}
+	host, err := client.createHost(ctx, hostReq, true)
+	if err != nil {
+		if err == ctx.Err() {
+			return nil, err
+		}
+		return nil, fmt.Errorf("Error creating gateway : %s", err.Error())
+	}
+	err = metadata.SaveGateway(providers.FromClient(client), host, req.NetworkID)
+	if err != nil {
+		derr := client.deleteHostEntry(host.ID)
+		if derr != nil {
+			log.Warnf("Problem cleaning up after failure saving metadata : trying to delete host: %v", derr)
+		}
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		client.DeleteGateway(req.NetworkID)
+		return nil, ctx.Err()
+	}
+	return host, nil
+}
+
+// DeleteGateway delete the public gateway of a private network
+func (client *Client) DeleteGateway(networkID string) error {
+	m, err := metadata.LoadGateway(providers.FromClient(client), networkID)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return nil
+	}
+
+	host := m.Get()
+	err = client.deleteHostEntry(host.ID)
+	if err != nil {
+		log.Warnf("Error deleting gateway host '%s': %s", host.Name, err.Error())
+	}
+	return m.Delete()
+}
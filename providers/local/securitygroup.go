@@ -0,0 +1,151 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"fmt"
+	"sort"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/enums/IPVersion"
+	"github.com/CS-SI/SafeScale/providers/enums/RuleDirection"
+)
+
+// CreateSecurityGroup creates a security group without rule
+// As the groups of the real providers, the new group allows all outgoing traffic until these rules are removed
+func (client *Client) CreateSecurityGroup(request api.SecurityGroupRequest) (*api.SecurityGroup, error) {
+	id, _ := uuid.NewV4()
+	group := api.SecurityGroup{
+		ID:          id.String(),
+		Name:        request.Name,
+		Description: request.Description,
+	}
+	for _, v := range []IPVersion.Enum{IPVersion.IPv4, IPVersion.IPv6} {
+		rid, _ := uuid.NewV4()
+		group.Rules = append(group.Rules, api.SecurityGroupRule{
+			ID:        rid.String(),
+			Direction: RuleDirection.EGRESS,
+			IPVersion: v,
+		})
+	}
+	err := client.update(func(s *state) error {
+		if s.findSecurityGroup(request.Name) != nil {
+			return providers.ResourceAlreadyExistsError("security group", request.Name)
+		}
+		s.SecurityGroups[group.ID] = &group
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error creating security group '%s': %s", request.Name, err.Error())
+	}
+	return &group, nil
+}
+
+// GetSecurityGroup returns the security group identified by ref (id or name), nil if it doesn't exist
+func (client *Client) GetSecurityGroup(ref string) (*api.SecurityGroup, error) {
+	var group *api.SecurityGroup
+	err := client.view(func(s *state) error {
+		if g := s.findSecurityGroup(ref); g != nil {
+			group = cloneSecurityGroup(g)
+		}
+		return nil
+	})
+	return group, err
+}
+
+// ListSecurityGroups lists available security groups, sorted by name
+func (client *Client) ListSecurityGroups() ([]api.SecurityGroup, error) {
+	var list []api.SecurityGroup
+	err := client.view(func(s *state) error {
+		for _, g := range s.SecurityGroups {
+			list = append(list, *cloneSecurityGroup(g))
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, err
+}
+
+// cloneSecurityGroup returns a copy of g which doesn't share its rules with the state
+func cloneSecurityGroup(g *api.SecurityGroup) *api.SecurityGroup {
+	clone := *g
+	clone.Rules = append([]api.SecurityGroupRule(nil), g.Rules...)
+	return &clone
+}
+
+// DeleteSecurityGroup deletes the security group identified by id
+// A group still bound to hosts cannot be deleted
+func (client *Client) DeleteSecurityGroup(id string) error {
+	return client.update(func(s *state) error {
+		if _, ok := s.SecurityGroups[id]; !ok {
+			return providers.ResourceNotFoundError("security group", id)
+		}
+		for _, e := range s.Hosts {
+			for _, g := range e.SecurityGroups {
+				if g == id {
+					return fmt.Errorf("security group '%s' is in use by host '%s'", id, e.Host.Name)
+				}
+			}
+		}
+		delete(s.SecurityGroups, id)
+		return nil
+	})
+}
+
+// AddSecurityGroupRule adds a rule to the security group identified by groupID
+func (client *Client) AddSecurityGroupRule(groupID string, rule api.SecurityGroupRule) (*api.SecurityGroupRule, error) {
+	id, _ := uuid.NewV4()
+	rule.ID = id.String()
+	err := client.update(func(s *state) error {
+		g, ok := s.SecurityGroups[groupID]
+		if !ok {
+			return providers.ResourceNotFoundError("security group", groupID)
+		}
+		for _, r := range g.Rules {
+			r.ID = rule.ID
+			if r == rule {
+				return fmt.Errorf("security group '%s' already has this rule", g.Name)
+			}
+		}
+		g.Rules = append(g.Rules, rule)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// DeleteSecurityGroupRule deletes the rule identified by ruleID from the security group identified by groupID
+func (client *Client) DeleteSecurityGroupRule(groupID string, ruleID string) error {
+	return client.update(func(s *state) error {
+		g, ok := s.SecurityGroups[groupID]
+		if !ok {
+			return providers.ResourceNotFoundError("security group", groupID)
+		}
+		for i, r := range g.Rules {
+			if r.ID == ruleID {
+				g.Rules = append(g.Rules[:i], g.Rules[i+1:]...)
+				return nil
+			}
+		}
+		return providers.ResourceNotFoundError("security group rule", ruleID)
+	})
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/enums/VolumeState"
+)
+
+// CreateVolumeSnapshot records a snapshot of the volume request.VolumeID, which must be available or attached
+func (client *Client) CreateVolumeSnapshot(request api.SnapshotRequest) (*api.Snapshot, error) {
+	id, _ := uuid.NewV4()
+	var snapshot api.Snapshot
+	err := client.update(func(s *state) error {
+		e := s.findVolume(request.VolumeID)
+		if e == nil {
+			return providers.ResourceNotFoundError("volume", request.VolumeID)
+		}
+		e.settle(time.Now())
+		if e.Volume.State != VolumeState.AVAILABLE && e.Volume.State != VolumeState.USED {
+			return fmt.Errorf("volume '%s' is in state '%s'", e.Volume.Name, e.Volume.State.String())
+		}
+		snapshot = api.Snapshot{
+			ID:        id.String(),
+			Name:      request.Name,
+			VolumeID:  e.Volume.ID,
+			Size:      e.Volume.Size,
+			State:     VolumeState.AVAILABLE,
+			CreatedAt: time.Now(),
+		}
+		s.Snapshots[snapshot.ID] = &snapshot
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error creating snapshot '%s': %s", request.Name, err.Error())
+	}
+	return &snapshot, nil
+}
+
+// GetVolumeSnapshot returns the snapshot identified by id
+func (client *Client) GetVolumeSnapshot(id string) (*api.Snapshot, error) {
+	var snapshot api.Snapshot
+	err := client.view(func(s *state) error {
+		sn, ok := s.Snapshots[id]
+		if !ok {
+			return providers.ResourceNotFoundError("snapshot", id)
+		}
+		snapshot = *sn
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// ListVolumeSnapshots lists the snapshots of the volume identified by volumeID, or all the snapshots if volumeID
+// is empty, from the oldest to the newest
+func (client *Client) ListVolumeSnapshots(volumeID string) ([]api.Snapshot, error) {
+	var list []api.Snapshot
+	err := client.view(func(s *state) error {
+		for _, sn := range s.Snapshots {
+			if volumeID == "" || sn.VolumeID == volumeID {
+				list = append(list, *sn)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].ID < list[j].ID
+		}
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list, nil
+}
+
+// DeleteVolumeSnapshot deletes the snapshot identified by id
+func (client *Client) DeleteVolumeSnapshot(id string) error {
+	return client.update(func(s *state) error {
+		if _, ok := s.Snapshots[id]; !ok {
+			return providers.ResourceNotFoundError("snapshot", id)
+		}
+		delete(s.Snapshots, id)
+		return nil
+	})
+}
+
+// CreateVolumeFromSnapshot creates a volume at least as large as the snapshot identified by snapshotID
+func (client *Client) CreateVolumeFromSnapshot(snapshotID string, request api.VolumeRequest) (*api.Volume, error) {
+	snapshot, err := client.GetVolumeSnapshot(snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Size == 0 {
+		request.Size = snapshot.Size
+	}
+	if request.Size < snapshot.Size {
+		return nil, fmt.Errorf("Error creating volume %s: size %d is smaller than the %d GB of the snapshot", request.Name, request.Size, snapshot.Size)
+	}
+	return client.CreateVolume(request)
+}
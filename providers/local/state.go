@@ -0,0 +1,175 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"time"
+
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/enums/HostState"
+	"github.com/CS-SI/SafeScale/providers/enums/VolumeState"
+)
+
+// hostEntry is an host known by the local tenant
+// State of the host is Host.State until Until is reached, then becomes Target
+type hostEntry struct {
+	Host   api.Host       `json:"host"`
+	Target HostState.Enum `json:"target"`
+	Until  time.Time      `json:"until"`
+	// Devices contains the devices used by the attached volumes, indexed by volume ID
+	Devices map[string]string `json:"devices,omitempty"`
+	// SecurityGroups contains the IDs of the security groups bound to the host
+	SecurityGroups []string `json:"security_groups,omitempty"`
+}
+
+// settle updates the state of the host if the transition is over
+func (e *hostEntry) settle(now time.Time) {
+	if e.Host.State != e.Target && !now.Before(e.Until) {
+		e.Host.State = e.Target
+	}
+}
+
+// transition makes the host go to state 'from' and then reach state 'to' after 'delay'
+func (e *hostEntry) transition(from, to HostState.Enum, delay time.Duration) {
+	e.Host.State = from
+	e.Target = to
+	e.Until = time.Now().Add(delay)
+	e.settle(time.Now())
+}
+
+// volumeEntry is a volume known by the local tenant
+// State of the volume is Volume.State until Until is reached, then becomes Target
+type volumeEntry struct {
+	Volume     api.Volume            `json:"volume"`
+	Target     VolumeState.Enum      `json:"target"`
+	Until      time.Time             `json:"until"`
+	Attachment *api.VolumeAttachment `json:"attachment,omitempty"`
+}
+
+// settle updates the state of the volume if the transition is over
+func (e *volumeEntry) settle(now time.Time) {
+	if e.Volume.State != e.Target && !now.Before(e.Until) {
+		e.Volume.State = e.Target
+	}
+}
+
+// transition makes the volume go to state 'from' and then reach state 'to' after 'delay'
+func (e *volumeEntry) transition(from, to VolumeState.Enum, delay time.Duration) {
+	e.Volume.State = from
+	e.Target = to
+	e.Until = time.Now().Add(delay)
+	e.settle(time.Now())
+}
+
+// networkEntry is a network known by the local tenant
+type networkEntry struct {
+	Network api.Network `json:"network"`
+	// LastIP is the last host part allocated in the network CIDR
+	LastIP uint32 `json:"last_ip"`
+}
+
+// objectEntry is an object stored in a container
+type objectEntry struct {
+	Content      []byte            `json:"content"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	ContentType  string            `json:"content_type,omitempty"`
+	DeleteAt     time.Time         `json:"delete_at,omitempty"`
+	Date         time.Time         `json:"date,omitempty"`
+	LastModified time.Time         `json:"last_modified,omitempty"`
+}
+
+// state contains all the resources of a local tenant
+type state struct {
+	KeyPairs   map[string]api.KeyPair             `json:"keypairs"`
+	Networks   map[string]*networkEntry           `json:"networks"`
+	Hosts      map[string]*hostEntry              `json:"hosts"`
+	Volumes    map[string]*volumeEntry            `json:"volumes"`
+	Containers map[string]map[string]*objectEntry `json:"containers"`
+	// SecurityGroups contains the security groups, indexed by ID
+	SecurityGroups map[string]*api.SecurityGroup `json:"security_groups"`
+	// VIPs contains the virtual IP addresses, indexed by ID
+	VIPs map[string]*api.VIP `json:"vips"`
+	// Snapshots contains the snapshots of the volumes, indexed by ID
+	Snapshots map[string]*api.Snapshot `json:"snapshots"`
+	// LastPublicIP is the last host part allocated in the public range
+	LastPublicIP uint32 `json:"last_public_ip"`
+}
+
+func newState() *state {
+	return &state{
+		KeyPairs:       map[string]api.KeyPair{},
+		Networks:       map[string]*networkEntry{},
+		Hosts:          map[string]*hostEntry{},
+		Volumes:        map[string]*volumeEntry{},
+		Containers:     map[string]map[string]*objectEntry{},
+		SecurityGroups: map[string]*api.SecurityGroup{},
+		VIPs:           map[string]*api.VIP{},
+		Snapshots:      map[string]*api.Snapshot{},
+	}
+}
+
+// findHost returns the host entry corresponding to ref (id or name)
+func (s *state) findHost(ref string) *hostEntry {
+	if e, ok := s.Hosts[ref]; ok {
+		return e
+	}
+	for _, e := range s.Hosts {
+		if e.Host.Name == ref {
+			return e
+		}
+	}
+	return nil
+}
+
+// findNetwork returns the network entry corresponding to ref (id or name)
+func (s *state) findNetwork(ref string) *networkEntry {
+	if e, ok := s.Networks[ref]; ok {
+		return e
+	}
+	for _, e := range s.Networks {
+		if e.Network.Name == ref {
+			return e
+		}
+	}
+	return nil
+}
+
+// findVolume returns the volume entry corresponding to ref (id or name)
+func (s *state) findVolume(ref string) *volumeEntry {
+	if e, ok := s.Volumes[ref]; ok {
+		return e
+	}
+	for _, e := range s.Volumes {
+		if e.Volume.Name == ref {
+			return e
+		}
+	}
+	return nil
+}
+
+// findSecurityGroup returns the security group corresponding to ref (id or name)
+func (s *state) findSecurityGroup(ref string) *api.SecurityGroup {
+	if g, ok := s.SecurityGroups[ref]; ok {
+		return g
+	}
+	for _, g := range s.SecurityGroups {
+		if g.Name == ref {
+			return g
+		}
+	}
+	return nil
+}
@@ -0,0 +1,606 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/enums/VolumeState"
+	"github.com/CS-SI/SafeScale/providers/metadata"
+)
+
+// CreateVolume creates a block volume
+// - name is the name of the volume
+// - size is the size of the volume in GB
+// - volumeType is the type of volume to create, if volumeType is empty the driver use a default type
+func (client *Client) CreateVolume(request api.VolumeRequest) (*api.Volume, error) {
+	// We 1st check if name is not already used
+	m, err := metadata.LoadVolume(providers.FromClient(client), request.Name)
+	if err != nil {
+		return nil, err
+	}
+	if m != nil {
+		return nil, fmt.Errorf("Volume '%s' already exists", request.Name)
+	}
+	if request.Size <= 0 {
+		return nil, fmt.Errorf("Error creating volume %s: invalid size %d", request.Name, request.Size)
+	}
+
+	id, _ := uuid.NewV4()
+	volume := api.Volume{
+		ID:    id.String(),
+		Name:  request.Name,
+		Size:  request.Size,
+		Speed: request.Speed,
+	}
+	err = client.update(func(s *state) error {
+		if s.findVolume(request.Name) != nil {
+			return providers.ResourceAlreadyExistsError("volume", request.Name)
+		}
+		e := &volumeEntry{Volume: volume}
+		e.transition(VolumeState.CREATING, VolumeState.AVAILABLE, client.Cfg.TransitionDelay)
+		s.Volumes[volume.ID] = e
+		volume = e.Volume
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error creating volume %s: %s", request.Name, err.Error())
+	}
+
+	err = metadata.SaveVolume(providers.FromClient(client), &volume)
+	if err != nil {
+		client.deleteVolumeEntry(volume.ID)
+		return nil, fmt.Errorf("Error creating volume : %s", err.Error())
+	}
+	return &volume, nil
+}
+
+// GetVolume returns the volume identified by ref (id or name)
+func (client *Client) GetVolume(ref string) (*api.Volume, error) {
+	var volume *api.Volume
+	err := client.view(func(s *state) error {
+		e := s.findVolume(ref)
+		if e == nil {
+			return providers.ResourceNotFoundError("volume", ref)
+		}
+		e.settle(time.Now())
+		v := e.Volume
+		volume = &v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return volume, nil
+}
+
+// ListVolumes return the list of all volume known on the current tenant (all=true)
+// or 'only' thode monitored by safescale (all=false) ie those monitored by metadata
+func (client *Client) ListVolumes(all bool) ([]api.Volume, error) {
+	if !all {
+		var vols []api.Volume
+		m := metadata.NewVolume(providers.FromClient(client))
+		err := m.Browse(func(vol *api.Volume) error {
+			vols = append(vols, *vol)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Error listing volumes : %s", err.Error())
+		}
+		return vols, nil
+	}
+
+	var vols []api.Volume
+	err := client.view(func(s *state) error {
+		now := time.Now()
+		for _, e := range s.Volumes {
+			e.settle(now)
+			vols = append(vols, e.Volume)
+		}
+		return nil
+	})
+	return vols, err
+}
+
+// deleteVolumeEntry removes the volume from the local tenant
+func (client *Client) deleteVolumeEntry(id string) error {
+	return client.update(func(s *state) error {
+		e := s.findVolume(id)
+		if e == nil {
+			return providers.ResourceNotFoundError("volume", id)
+		}
+		e.settle(time.Now())
+		if e.Attachment != nil {
+			return fmt.Errorf("volume '%s' is attached to host '%s'", e.Volume.Name, e.Attachment.ServerID)
+		}
+		if e.Volume.State != VolumeState.AVAILABLE {
+			return fmt.Errorf("volume '%s' is in state '%s'", e.Volume.Name, e.Volume.State.String())
+		}
+		delete(s.Volumes, e.Volume.ID)
+		return nil
+	})
+}
+
+// DeleteVolume deletes the volume identified by id
+func (client *Client) DeleteVolume(id string) error {
+	volume, err := metadata.LoadVolume(providers.FromClient(client), id)
+	if err != nil {
+		return err
+	}
+	if volume == nil {
+		return providers.ResourceNotFoundError("volume", id)
+	}
+	id = volume.Get().ID
+
+	err = client.deleteVolumeEntry(id)
+	if err != nil {
+		return fmt.Errorf("Error deleting volume: %s", err.Error())
+	}
+	err = metadata.RemoveVolume(providers.FromClient(client), id)
+	if err != nil {
+		return fmt.Errorf("Error deleting volume: %s", err.Error())
+	}
+	return nil
+}
+
+// nextDevice returns the first device name not used by the host
+func nextDevice(e *hostEntry) (string, error) {
+	used := map[string]bool{}
+	for _, d := range e.Devices {
+		used[d] = true
+	}
+	// vda is the system disk
+	for c := 'b'; c <= 'z'; c++ {
+		d := "/dev/vd" + string(c)
+		if !used[d] {
+			return d, nil
+		}
+	}
+	return "", fmt.Errorf("no more device available on host '%s'", e.Host.Name)
+}
+
+// CreateVolumeAttachment attaches a volume to an host
+// - 'name' of the volume attachment
+// - 'volume' to attach
+// - 'host' on which the volume is attached
+func (client *Client) CreateVolumeAttachment(request api.VolumeAttachmentRequest) (*api.VolumeAttachment, error) {
+	var va *api.VolumeAttachment
+	err := client.update(func(s *state) error {
+		now := time.Now()
+		v := s.findVolume(request.VolumeID)
+		if v == nil {
+			return providers.ResourceNotFoundError("volume", request.VolumeID)
+		}
+		h := s.findHost(request.ServerID)
+		if h == nil {
+			return providers.ResourceNotFoundError("host", request.ServerID)
+		}
+		v.settle(now)
+		if v.Volume.State != VolumeState.AVAILABLE {
+			return fmt.Errorf("volume '%s' is in state '%s'", v.Volume.Name, v.Volume.State.String())
+		}
+		device, err := nextDevice(h)
+		if err != nil {
+			return err
+		}
+		va = &api.VolumeAttachment{
+			ID:       v.Volume.ID,
+			Name:     request.Name,
+			ServerID: h.Host.ID,
+			VolumeID: v.Volume.ID,
+			Device:   device,
+		}
+		v.Attachment = va
+		v.transition(VolumeState.ATTACHING, VolumeState.USED, client.Cfg.TransitionDelay)
+		h.Devices[v.Volume.ID] = device
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error creating volume attachment between server %s and volume %s: %s", request.ServerID, request.VolumeID, err.Error())
+	}
+
+	// Update the metadata
+	mtdVol, err := metadata.LoadVolume(providers.FromClient(client), request.VolumeID)
+	if err == nil && mtdVol == nil {
+		err = providers.ResourceNotFoundError("volume", request.VolumeID)
+	}
+	if err == nil {
+		err = mtdVol.Attach(va)
+	}
+	if err != nil {
+		// Detach volume
+		detachErr := client.detachVolume(va.ServerID, va.ID, 0)
+		if detachErr != nil {
+			return nil, fmt.Errorf("Error deleting volume attachment %s: %s", va.ID, detachErr.Error())
+		}
+		return nil, err
+	}
+	return va, nil
+}
+
+// GetVolumeAttachment returns the volume attachment identified by id
+func (client *Client) GetVolumeAttachment(serverID, id string) (*api.VolumeAttachment, error) {
+	var va *api.VolumeAttachment
+	err := client.view(func(s *state) error {
+		v := s.findVolume(id)
+		if v == nil || v.Attachment == nil || v.Attachment.ServerID != serverID {
+			return providers.ResourceNotFoundError("volume attachment", id)
+		}
+		a := *v.Attachment
+		va = &a
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error getting volume attachment %s: %s", id, err.Error())
+	}
+	return va, nil
+}
+
+// ListVolumeAttachments lists available volume attachment
+func (client *Client) ListVolumeAttachments(serverID string) ([]api.VolumeAttachment, error) {
+	var vs []api.VolumeAttachment
+	err := client.view(func(s *state) error {
+		for _, v := range s.Volumes {
+			if v.Attachment != nil && v.Attachment.ServerID == serverID {
+				vs = append(vs, *v.Attachment)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error listing volume attachments: %s", err.Error())
+	}
+	return vs, nil
+}
+
+// detachVolume removes the attachment 'id' from host 'serverID', the volume becoming available after 'delay'
+func (client *Client) detachVolume(serverID, id string, delay time.Duration) error {
+	return client.update(func(s *state) error {
+		v := s.findVolume(id)
+		if v == nil || v.Attachment == nil || v.Attachment.ServerID != serverID {
+			return providers.ResourceNotFoundError("volume attachment", id)
+		}
+		if h := s.findHost(serverID); h != nil {
+			delete(h.Devices, v.Volume.ID)
+		}
+		v.Attachment = nil
+		v.transition(VolumeState.DETACHING, VolumeState.AVAILABLE, delay)
+		return nil
+	})
+}
+
+// DeleteVolumeAttachment deletes the volume attachment identifed by id
+func (client *Client) DeleteVolumeAttachment(serverID, id string) error {
+	va, err := client.GetVolumeAttachment(serverID, id)
+	if err != nil {
+		return fmt.Errorf("Error deleting volume attachment %s: %s", id, err.Error())
+	}
+
+	err = client.detachVolume(serverID, id, client.Cfg.TransitionDelay)
+	if err != nil {
+		return fmt.Errorf("Error deleting volume attachment %s: %s", id, err.Error())
+	}
+
+	mtdVol, err := metadata.LoadVolume(providers.FromClient(client), id)
+	if err != nil {
+		return fmt.Errorf("Error deleting volume attachment %s: %s", id, err.Error())
+	}
+	if mtdVol == nil {
+		return nil
+	}
+	err = mtdVol.Detach(va)
+	if err != nil {
+		return fmt.Errorf("Error deleting volume attachment %s: %s", id, err.Error())
+	}
+	return nil
+}
+
+// CreateContainer creates an object container
+func (client *Client) CreateContainer(name string) error {
+	return client.update(func(s *state) error {
+		if _, ok := s.Containers[name]; !ok {
+			s.Containers[name] = map[string]*objectEntry{}
+		}
+		return nil
+	})
+}
+
+// DeleteContainer deletes an object container
+func (client *Client) DeleteContainer(name string) error {
+	err := client.update(func(s *state) error {
+		c, ok := s.Containers[name]
+		if !ok {
+			return providers.ResourceNotFoundError("container", name)
+		}
+		if len(c) > 0 {
+			return fmt.Errorf("container is not empty")
+		}
+		delete(s.Containers, name)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Error deleting container %s: %s", name, err.Error())
+	}
+	return nil
+}
+
+// ListContainers list object containers
+func (client *Client) ListContainers() ([]string, error) {
+	var list []string
+	err := client.view(func(s *state) error {
+		for name := range s.Containers {
+			list = append(list, name)
+		}
+		return nil
+	})
+	sort.Strings(list)
+	return list, err
+}
+
+// GetContainer get container info
+func (client *Client) GetContainer(name string) (*api.ContainerInfo, error) {
+	var info *api.ContainerInfo
+	err := client.view(func(s *state) error {
+		c, ok := s.Containers[name]
+		if !ok {
+			return providers.ResourceNotFoundError("container", name)
+		}
+		info = &api.ContainerInfo{
+			Name:    name,
+			NbItems: len(c),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error getting container %s: %s", name, err.Error())
+	}
+	return info, nil
+}
+
+// container returns the objects of container 'name'
+func (s *state) container(name string) (map[string]*objectEntry, error) {
+	c, ok := s.Containers[name]
+	if !ok {
+		return nil, providers.ResourceNotFoundError("container", name)
+	}
+	return c, nil
+}
+
+// PutObject put an object into an object container
+func (client *Client) PutObject(container string, obj api.Object) error {
+	var content []byte
+	if obj.Content != nil {
+		b, err := ioutil.ReadAll(obj.Content)
+		if err != nil {
+			return fmt.Errorf("Error creating object %s in container %s : %s", obj.Name, container, err.Error())
+		}
+		content = b
+	}
+	now := time.Now()
+	err := client.update(func(s *state) error {
+		c, err := s.container(container)
+		if err != nil {
+			return err
+		}
+		c[obj.Name] = &objectEntry{
+			Content:      content,
+			Metadata:     obj.Metadata,
+			ContentType:  obj.ContentType,
+			DeleteAt:     obj.DeleteAt,
+			Date:         now,
+			LastModified: now,
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Error creating object %s in container %s : %s", obj.Name, container, err.Error())
+	}
+	return nil
+}
+
+// UpdateObjectMetadata update an object into an object container
+func (client *Client) UpdateObjectMetadata(container string, obj api.Object) error {
+	err := client.update(func(s *state) error {
+		c, err := s.container(container)
+		if err != nil {
+			return err
+		}
+		o, ok := c[obj.Name]
+		if !ok {
+			return providers.ResourceNotFoundError("object", obj.Name)
+		}
+		o.Metadata = obj.Metadata
+		o.LastModified = time.Now()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Error updating object %s in container %s : %s", obj.Name, container, err.Error())
+	}
+	return nil
+}
+
+// readObject returns the object 'name' of 'container', deleting it if it has expired
+func (client *Client) readObject(container string, name string) (*objectEntry, error) {
+	var o *objectEntry
+	err := client.update(func(s *state) error {
+		c, err := s.container(container)
+		if err != nil {
+			return err
+		}
+		e, ok := c[name]
+		if ok && !e.DeleteAt.IsZero() && time.Now().After(e.DeleteAt) {
+			delete(c, name)
+			ok = false
+		}
+		if !ok {
+			return providers.ResourceNotFoundError("object", name)
+		}
+		copied := *e
+		o = &copied
+		return nil
+	})
+	return o, err
+}
+
+// extractRanges returns the part of content corresponding to ranges, concatenated
+func extractRanges(content []byte, ranges []api.Range) []byte {
+	if len(ranges) == 0 {
+		return content
+	}
+	size := len(content)
+	var buff bytes.Buffer
+	for _, r := range ranges {
+		from, to := 0, size-1
+		if r.From != nil {
+			from = *r.From
+		}
+		if r.To != nil && *r.To < to {
+			to = *r.To
+		}
+		if from < 0 || from > to {
+			continue
+		}
+		buff.Write(content[from : to+1])
+	}
+	return buff.Bytes()
+}
+
+// GetObject get object content from an object container
+func (client *Client) GetObject(container string, name string, ranges []api.Range) (*api.Object, error) {
+	o, err := client.readObject(container, name)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting object %s from %s : %s", name, container, err.Error())
+	}
+	content := extractRanges(o.Content, ranges)
+	return &api.Object{
+		Name:          name,
+		Content:       bytes.NewReader(content),
+		DeleteAt:      o.DeleteAt,
+		Metadata:      o.Metadata,
+		Date:          o.Date,
+		LastModified:  o.LastModified,
+		ContentType:   o.ContentType,
+		ContentLength: int64(len(content)),
+	}, nil
+}
+
+// GetObjectMetadata get object metadata from an object container
+func (client *Client) GetObjectMetadata(container string, name string) (*api.Object, error) {
+	o, err := client.readObject(container, name)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting object content: %s", err.Error())
+	}
+	return &api.Object{
+		Name:          name,
+		DeleteAt:      o.DeleteAt,
+		Metadata:      o.Metadata,
+		Date:          o.Date,
+		LastModified:  o.LastModified,
+		ContentType:   o.ContentType,
+		ContentLength: int64(len(o.Content)),
+	}, nil
+}
+
+// matchFilter tells if the object named 'name' satisfies filter
+// As with Swift, Path selects the objects directly inside the pseudo-folder Path, and Prefix
+// the objects whose name starts with Prefix
+func matchFilter(name string, filter api.ObjectFilter) bool {
+	if filter.Path != "" {
+		path := strings.Trim(filter.Path, "/") + "/"
+		if !strings.HasPrefix(name, path) || strings.Contains(strings.TrimPrefix(name, path), "/") {
+			return false
+		}
+	}
+	return strings.HasPrefix(name, filter.Prefix)
+}
+
+// ListObjects list objects of a container
+func (client *Client) ListObjects(container string, filter api.ObjectFilter) ([]string, error) {
+	var list []string
+	err := client.view(func(s *state) error {
+		c, err := s.container(container)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for name, o := range c {
+			if !o.DeleteAt.IsZero() && now.After(o.DeleteAt) {
+				continue
+			}
+			if matchFilter(name, filter) {
+				list = append(list, name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error listing objects of container '%s': %s", container, err.Error())
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// CopyObject copies an object
+func (client *Client) CopyObject(containerSrc, objectSrc, objectDst string) error {
+	err := client.update(func(s *state) error {
+		c, err := s.container(containerSrc)
+		if err != nil {
+			return err
+		}
+		o, ok := c[objectSrc]
+		if !ok {
+			return providers.ResourceNotFoundError("object", objectSrc)
+		}
+		copied := *o
+		copied.Content = append([]byte(nil), o.Content...)
+		copied.LastModified = time.Now()
+		c[objectDst] = &copied
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Error copying object %s into %s from container %s : %s", objectSrc, objectDst, containerSrc, err.Error())
+	}
+	return nil
+}
+
+// DeleteObject deleta an object from a container
+func (client *Client) DeleteObject(container, object string) error {
+	err := client.update(func(s *state) error {
+		c, err := s.container(container)
+		if err != nil {
+			return err
+		}
+		if _, ok := c[object]; !ok {
+			return providers.ResourceNotFoundError("object", object)
+		}
+		delete(c, object)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Error deleting object %s of container %s : %s", object, container, err.Error())
+	}
+	return nil
+}
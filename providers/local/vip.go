@@ -0,0 +1,143 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"fmt"
+	"net"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+)
+
+// CreateVIP reserves a virtual IP address in the network identified by networkID
+func (client *Client) CreateVIP(networkID string, name string) (*api.VIP, error) {
+	id, _ := uuid.NewV4()
+	vip := api.VIP{
+		ID:        id.String(),
+		Name:      name,
+		NetworkID: networkID,
+	}
+	err := client.update(func(s *state) error {
+		n, ok := s.Networks[networkID]
+		if !ok {
+			return providers.ResourceNotFoundError("network", networkID)
+		}
+		ip, err := allocateIP(n.Network.CIDR, &n.LastIP)
+		if err != nil {
+			return err
+		}
+		vip.PrivateIP = ip
+		s.VIPs[vip.ID] = &vip
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error creating VIP '%s': %s", name, err.Error())
+	}
+	return &vip, nil
+}
+
+// BindHostToVIP allows the host identified by hostID to hold the virtual IP address
+// The host must have an address in the network of the VIP
+func (client *Client) BindHostToVIP(vip *api.VIP, hostID string) error {
+	var hosts []string
+	err := client.update(func(s *state) error {
+		v, ok := s.VIPs[vip.ID]
+		if !ok {
+			return providers.ResourceNotFoundError("VIP", vip.ID)
+		}
+		h, ok := s.Hosts[hostID]
+		if !ok {
+			return providers.ResourceNotFoundError("host", hostID)
+		}
+		n, ok := s.Networks[v.NetworkID]
+		if !ok {
+			return providers.ResourceNotFoundError("network", v.NetworkID)
+		}
+		if !hostInNetwork(&h.Host, &n.Network) {
+			return fmt.Errorf("the host isn't attached to the network '%s'", n.Network.Name)
+		}
+		for _, id := range v.Hosts {
+			if id == hostID {
+				hosts = v.Hosts
+				return nil
+			}
+		}
+		v.Hosts = append(v.Hosts, hostID)
+		hosts = v.Hosts
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Error binding host '%s' to VIP '%s': %s", hostID, vip.Name, err.Error())
+	}
+	vip.Hosts = append([]string(nil), hosts...)
+	return nil
+}
+
+// UnbindHostFromVIP stops allowing the host identified by hostID to hold the virtual IP address
+func (client *Client) UnbindHostFromVIP(vip *api.VIP, hostID string) error {
+	var hosts []string
+	err := client.update(func(s *state) error {
+		v, ok := s.VIPs[vip.ID]
+		if !ok {
+			return providers.ResourceNotFoundError("VIP", vip.ID)
+		}
+		for _, id := range v.Hosts {
+			if id != hostID {
+				hosts = append(hosts, id)
+			}
+		}
+		v.Hosts = hosts
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Error unbinding host '%s' from VIP '%s': %s", hostID, vip.Name, err.Error())
+	}
+	vip.Hosts = append([]string(nil), hosts...)
+	return nil
+}
+
+// DeleteVIP releases the virtual IP address
+func (client *Client) DeleteVIP(vip *api.VIP) error {
+	err := client.update(func(s *state) error {
+		if _, ok := s.VIPs[vip.ID]; !ok {
+			return providers.ResourceNotFoundError("VIP", vip.ID)
+		}
+		delete(s.VIPs, vip.ID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Error deleting VIP '%s': %s", vip.Name, err.Error())
+	}
+	return nil
+}
+
+// hostInNetwork tells if one of the private addresses of the host belongs to the network
+func hostInNetwork(host *api.Host, network *api.Network) bool {
+	_, ipnet, err := net.ParseCIDR(network.CIDR)
+	if err != nil {
+		return false
+	}
+	for _, ip := range host.PrivateIPsV4 {
+		if ipnet.Contains(net.ParseIP(ip)) {
+			return true
+		}
+	}
+	return false
+}
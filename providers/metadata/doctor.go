@@ -0,0 +1,458 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/utils/metadata"
+)
+
+const (
+	// OrphanMetadata is the problem of a metadata describing a resource that doesn't exist anymore
+	OrphanMetadata = "orphan metadata"
+	// UntrackedResource is the problem of a resource existing on the provider without metadata
+	UntrackedResource = "untracked resource"
+	// DanglingReference is the problem of a metadata referencing a resource that doesn't exist anymore
+	DanglingReference = "dangling reference"
+
+	// clusterFolderName is the folder of the cluster metadata, written by the cluster package
+	clusterFolderName = "clusters"
+)
+
+// clusterReferences contains the fields of the cluster metadata (deploy/cluster/api.ClusterCore) referencing resources
+// of the provider; they are decoded here as brokerd doesn't import the cluster package
+type clusterReferences struct {
+	Name           string
+	NetworkID      string
+	PublicNodeIDs  []string
+	PrivateNodeIDs []string
+}
+
+// Finding describes an inconsistency between the metadata and the resources of the provider
+type Finding struct {
+	// ResourceType is the type of the resource concerned (host, network, volume, volume attachment, nas)
+	ResourceType string
+	ID           string
+	Name         string
+	// Problem is one of OrphanMetadata, UntrackedResource and DanglingReference
+	Problem string
+	// Details gives information on the inconsistency
+	Details string
+	// Fixed tells if the inconsistency has been repaired
+	Fixed bool
+	// Error contains the reason of the failure of the repair, if any
+	Error string
+}
+
+// Doctor cross-checks the metadata against the resources of the provider
+type Doctor struct {
+	svc *providers.Service
+	// Repair removes metadata of resources which don't exist anymore
+	Repair bool
+	// Import creates metadata of the resources not tracked by SafeScale
+	Import bool
+
+	findings []Finding
+}
+
+// NewDoctor creates a Doctor for the tenant of svc
+func NewDoctor(svc *providers.Service) *Doctor {
+	return &Doctor{svc: svc}
+}
+
+// Check lists the inconsistencies between the metadata and the resources of the provider, and fixes them if asked to
+// The nas and the clusters are only checked for references to hosts and networks which don't exist anymore
+func (d *Doctor) Check() ([]Finding, error) {
+	d.findings = nil
+
+	hosts, err := d.checkHosts()
+	if err != nil {
+		return nil, err
+	}
+	networks, err := d.checkNetworks(hosts)
+	if err != nil {
+		return nil, err
+	}
+	err = d.checkVolumes(hosts)
+	if err != nil {
+		return nil, err
+	}
+	err = d.checkNas(hosts)
+	if err != nil {
+		return nil, err
+	}
+	err = d.checkClusters(hosts, networks)
+	if err != nil {
+		return nil, err
+	}
+	return d.findings, nil
+}
+
+// report records a finding, and the result of its fix if fix is not nil
+func (d *Doctor) report(f Finding, fix func() error) {
+	if fix != nil {
+		err := fix()
+		if err != nil {
+			f.Error = err.Error()
+		} else {
+			f.Fixed = true
+		}
+	}
+	d.findings = append(d.findings, f)
+}
+
+// checkHosts cross-checks host metadata and returns the hosts existing on the provider, indexed by ID
+func (d *Doctor) checkHosts() (map[string]api.Host, error) {
+	list, err := d.svc.ListHosts(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hosts: %s", err.Error())
+	}
+	hosts := map[string]api.Host{}
+	for _, h := range list {
+		hosts[h.ID] = h
+	}
+
+	tracked := map[string]bool{}
+	var orphans []api.Host
+	err = NewHost(d.svc).Browse(func(host *api.Host) error {
+		tracked[host.ID] = true
+		if _, ok := hosts[host.ID]; !ok {
+			orphans = append(orphans, *host)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to browse host metadata: %s", err.Error())
+	}
+
+	for _, h := range orphans {
+		host := h
+		var fix func() error
+		if d.Repair {
+			fix = func() error { return RemoveHost(d.svc, &host) }
+		}
+		d.report(Finding{ResourceType: "host", ID: host.ID, Name: host.Name, Problem: OrphanMetadata}, fix)
+	}
+	for _, h := range list {
+		if tracked[h.ID] {
+			continue
+		}
+		host := h
+		var fix func() error
+		if d.Import {
+			fix = func() error { return NewHost(d.svc).Carry(&host).Write() }
+		}
+		d.report(Finding{ResourceType: "host", ID: host.ID, Name: host.Name, Problem: UntrackedResource}, fix)
+	}
+	return hosts, nil
+}
+
+// checkNetworks cross-checks network metadata, and the hosts and gateway referenced by each network
+// It returns the IDs of the networks existing on the provider
+func (d *Doctor) checkNetworks(hosts map[string]api.Host) (map[string]bool, error) {
+	list, err := d.svc.ListNetworks(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %s", err.Error())
+	}
+	networks := map[string]bool{}
+	for _, n := range list {
+		networks[n.ID] = true
+	}
+
+	tracked := map[string]bool{}
+	var (
+		orphans  []api.Network
+		existing []api.Network
+	)
+	err = NewNetwork(d.svc).Browse(func(network *api.Network) error {
+		tracked[network.ID] = true
+		if networks[network.ID] {
+			existing = append(existing, *network)
+		} else {
+			orphans = append(orphans, *network)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to browse network metadata: %s", err.Error())
+	}
+
+	for _, n := range orphans {
+		network := n
+		var fix func() error
+		if d.Repair {
+			fix = func() error { return RemoveNetwork(d.svc, &network) }
+		}
+		d.report(Finding{ResourceType: "network", ID: network.ID, Name: network.Name, Problem: OrphanMetadata}, fix)
+	}
+	for _, n := range existing {
+		network := n
+		mn := NewNetwork(d.svc).Carry(&network)
+		attached, err := mn.ListHosts()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list hosts of network '%s': %s", network.Name, err.Error())
+		}
+		for _, h := range attached {
+			if _, ok := hosts[h.ID]; ok {
+				continue
+			}
+			hostID := h.ID
+			var fix func() error
+			if d.Repair {
+				fix = func() error { return mn.DetachHost(hostID) }
+			}
+			d.report(Finding{
+				ResourceType: "host",
+				ID:           h.ID,
+				Name:         h.Name,
+				Problem:      DanglingReference,
+				Details:      fmt.Sprintf("attached to network '%s'", network.Name),
+			}, fix)
+		}
+		if network.GatewayID != "" {
+			if _, ok := hosts[network.GatewayID]; !ok {
+				d.report(Finding{
+					ResourceType: "host",
+					ID:           network.GatewayID,
+					Problem:      DanglingReference,
+					Details:      fmt.Sprintf("gateway of network '%s'", network.Name),
+				}, nil)
+			}
+		}
+	}
+	for _, n := range list {
+		if tracked[n.ID] {
+			continue
+		}
+		network := n
+		var fix func() error
+		if d.Import {
+			fix = func() error { return SaveNetwork(d.svc, &network) }
+		}
+		d.report(Finding{ResourceType: "network", ID: network.ID, Name: network.Name, Problem: UntrackedResource}, fix)
+	}
+	return networks, nil
+}
+
+// checkVolumes cross-checks volume metadata and their attachments
+func (d *Doctor) checkVolumes(hosts map[string]api.Host) error {
+	list, err := d.svc.ListVolumes(true)
+	if err != nil {
+		return fmt.Errorf("failed to list volumes: %s", err.Error())
+	}
+	volumes := map[string]bool{}
+	for _, v := range list {
+		volumes[v.ID] = true
+	}
+
+	// Attachments known by the provider, indexed by volume ID then host ID
+	attachments := map[string]map[string]api.VolumeAttachment{}
+	for id := range hosts {
+		vas, err := d.svc.ListVolumeAttachments(id)
+		if err != nil {
+			return fmt.Errorf("failed to list volume attachments of host '%s': %s", hosts[id].Name, err.Error())
+		}
+		for _, va := range vas {
+			if attachments[va.VolumeID] == nil {
+				attachments[va.VolumeID] = map[string]api.VolumeAttachment{}
+			}
+			attachments[va.VolumeID][va.ServerID] = va
+		}
+	}
+
+	tracked := map[string]bool{}
+	var (
+		orphans  []api.Volume
+		existing []api.Volume
+	)
+	err = NewVolume(d.svc).Browse(func(volume *api.Volume) error {
+		tracked[volume.ID] = true
+		if volumes[volume.ID] {
+			existing = append(existing, *volume)
+		} else {
+			orphans = append(orphans, *volume)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to browse volume metadata: %s", err.Error())
+	}
+
+	for _, v := range orphans {
+		volume := v
+		var fix func() error
+		if d.Repair {
+			fix = func() error { return RemoveVolume(d.svc, volume.ID) }
+		}
+		d.report(Finding{ResourceType: "volume", ID: volume.ID, Name: volume.Name, Problem: OrphanMetadata}, fix)
+	}
+	for _, v := range existing {
+		volume := v
+		trackedAttachments := map[string]bool{}
+		var dangling []api.VolumeAttachment
+		err := NewVolumeAttachment(d.svc, volume.ID).Browse(func(va *api.VolumeAttachment) error {
+			trackedAttachments[va.ServerID] = true
+			if _, ok := attachments[volume.ID][va.ServerID]; !ok {
+				dangling = append(dangling, *va)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to browse attachment metadata of volume '%s': %s", volume.Name, err.Error())
+		}
+		for _, a := range dangling {
+			va := a
+			var fix func() error
+			if d.Repair {
+				fix = func() error { return RemoveVolumeAttachment(d.svc, va.ServerID, va.VolumeID) }
+			}
+			d.report(Finding{
+				ResourceType: "volume attachment",
+				ID:           va.ID,
+				Name:         va.Name,
+				Problem:      OrphanMetadata,
+				Details:      fmt.Sprintf("volume '%s' on host '%s'", volume.Name, va.ServerID),
+			}, fix)
+		}
+		for hostID, a := range attachments[volume.ID] {
+			if trackedAttachments[hostID] {
+				continue
+			}
+			va := a
+			var fix func() error
+			if d.Import {
+				fix = func() error { return SaveVolumeAttachment(d.svc, &va) }
+			}
+			d.report(Finding{
+				ResourceType: "volume attachment",
+				ID:           va.ID,
+				Name:         va.Name,
+				Problem:      UntrackedResource,
+				Details:      fmt.Sprintf("volume '%s' on host '%s'", volume.Name, hosts[hostID].Name),
+			}, fix)
+		}
+	}
+	for _, v := range list {
+		if tracked[v.ID] {
+			continue
+		}
+		volume := v
+		var fix func() error
+		if d.Import {
+			fix = func() error { return SaveVolume(d.svc, &volume) }
+		}
+		d.report(Finding{ResourceType: "volume", ID: volume.ID, Name: volume.Name, Problem: UntrackedResource}, fix)
+	}
+	return nil
+}
+
+// checkNas checks the hosts referenced by the nas, by name: the server, its secondary and the clients
+// A client on an host which doesn't exist anymore is removed on repair, a nas without server is only reported
+func (d *Doctor) checkNas(hosts map[string]api.Host) error {
+	names := map[string]bool{}
+	for _, h := range hosts {
+		names[h.Name] = true
+	}
+
+	var servers []api.Nas
+	err := NewNas(d.svc).Browse(func(nas *api.Nas) error {
+		servers = append(servers, *nas)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to browse nas metadata: %s", err.Error())
+	}
+
+	for _, n := range servers {
+		nas := n
+		for _, ref := range []struct{ host, role string }{{nas.Host, "server"}, {nas.Secondary, "secondary server"}} {
+			if ref.host == "" || names[ref.host] {
+				continue
+			}
+			d.report(Finding{
+				ResourceType: "host",
+				Name:         ref.host,
+				Problem:      DanglingReference,
+				Details:      fmt.Sprintf("%s of nas '%s'", ref.role, nas.Name),
+			}, nil)
+		}
+
+		mn := NewNas(d.svc).Carry(&nas)
+		clients, err := mn.Listclients()
+		if err != nil {
+			return fmt.Errorf("failed to list clients of nas '%s': %s", nas.Name, err.Error())
+		}
+		for _, c := range clients {
+			if names[c.Host] {
+				continue
+			}
+			client := c
+			var fix func() error
+			if d.Repair {
+				fix = func() error { return mn.RemoveClient(client) }
+			}
+			d.report(Finding{
+				ResourceType: "host",
+				Name:         client.Host,
+				Problem:      DanglingReference,
+				Details:      fmt.Sprintf("client of nas '%s'", nas.Name),
+			}, fix)
+		}
+	}
+	return nil
+}
+
+// checkClusters checks the network and the nodes referenced by the clusters
+// The cluster metadata belong to the cluster package, the dangling references are only reported
+func (d *Doctor) checkClusters(hosts map[string]api.Host, networks map[string]bool) error {
+	err := metadata.NewItem(d.svc, clusterFolderName).Browse(func(buf *bytes.Buffer) error {
+		var cluster clusterReferences
+		err := gob.NewDecoder(buf).Decode(&cluster)
+		if err != nil {
+			return err
+		}
+		if cluster.NetworkID != "" && !networks[cluster.NetworkID] {
+			d.report(Finding{
+				ResourceType: "network",
+				ID:           cluster.NetworkID,
+				Problem:      DanglingReference,
+				Details:      fmt.Sprintf("network of cluster '%s'", cluster.Name),
+			}, nil)
+		}
+		for _, id := range append(cluster.PublicNodeIDs, cluster.PrivateNodeIDs...) {
+			if _, ok := hosts[id]; ok {
+				continue
+			}
+			d.report(Finding{
+				ResourceType: "host",
+				ID:           id,
+				Problem:      DanglingReference,
+				Details:      fmt.Sprintf("node of cluster '%s'", cluster.Name),
+			}, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to browse cluster metadata: %s", err.Error())
+	}
+	return nil
+}
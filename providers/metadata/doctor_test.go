@@ -0,0 +1,127 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	clusterapi "github.com/CS-SI/SafeScale/deploy/cluster/api"
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/enums/IPVersion"
+	"github.com/CS-SI/SafeScale/providers/enums/VolumeSpeed"
+	"github.com/CS-SI/SafeScale/providers/local"
+	"github.com/CS-SI/SafeScale/providers/metadata"
+	utilsmetadata "github.com/CS-SI/SafeScale/utils/metadata"
+)
+
+// problems returns the findings indexed by resource type and name
+func problems(findings []metadata.Finding) map[string]string {
+	m := map[string]string{}
+	for _, f := range findings {
+		m[f.ResourceType+":"+f.Name] = f.Problem
+	}
+	return m
+}
+
+func TestDoctor(t *testing.T) {
+	clt, err := (&local.Client{}).Build(map[string]interface{}{"name": t.Name(), "TransitionDelay": "50ms"})
+	require.Nil(t, err)
+	svc := providers.FromClient(clt)
+
+	volume, err := svc.CreateVolume(api.VolumeRequest{Name: "vol1", Size: 10, Speed: VolumeSpeed.HDD})
+	require.Nil(t, err)
+
+	// Volume deleted behind SafeScale's back, volume created without metadata, metadata without volume
+	require.Nil(t, metadata.RemoveVolume(svc, volume.ID))
+	require.Nil(t, metadata.SaveVolume(svc, &api.Volume{ID: "ghost", Name: "ghost"}))
+
+	doctor := metadata.NewDoctor(svc)
+	findings, err := doctor.Check()
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"volume:vol1":  metadata.UntrackedResource,
+		"volume:ghost": metadata.OrphanMetadata,
+	}, problems(findings))
+	for _, f := range findings {
+		assert.False(t, f.Fixed)
+	}
+
+	doctor.Repair = true
+	doctor.Import = true
+	findings, err = doctor.Check()
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(findings))
+	for _, f := range findings {
+		assert.True(t, f.Fixed)
+	}
+
+	findings, err = doctor.Check()
+	require.Nil(t, err)
+	assert.Empty(t, findings)
+	m, err := metadata.LoadVolume(svc, "vol1")
+	require.Nil(t, err)
+	assert.NotNil(t, m)
+}
+
+func TestDoctor_References(t *testing.T) {
+	clt, err := (&local.Client{}).Build(map[string]interface{}{"name": t.Name(), "TransitionDelay": "50ms"})
+	require.Nil(t, err)
+	svc := providers.FromClient(clt)
+
+	network, err := svc.CreateNetwork(api.NetworkRequest{Name: "net1", IPVersion: IPVersion.IPv4, CIDR: "192.168.1.0/24"})
+	require.Nil(t, err)
+	require.Nil(t, metadata.SaveNetwork(svc, network))
+	host, err := svc.CreateHost(api.HostRequest{Name: "host1", ImageID: "local-ubuntu-1604", TemplateID: "local-tiny", NetworkIDs: []string{network.ID}, PublicIP: true})
+	require.Nil(t, err)
+	require.Nil(t, metadata.NewHost(svc).Carry(host).Write())
+
+	// Nas served by an host which doesn't exist anymore, with a client on host1 and another on a deleted host
+	nas := api.Nas{ID: "nas1", Name: "nas1", Host: "gone", IsServer: true}
+	require.Nil(t, metadata.SaveNas(svc, &nas))
+	require.Nil(t, metadata.MountNas(svc, &api.Nas{ID: "client1", Name: "nas1", Host: "host1"}, &nas))
+	require.Nil(t, metadata.MountNas(svc, &api.Nas{ID: "client2", Name: "nas1", Host: "deleted"}, &nas))
+
+	// Cluster with a node deleted, in a network deleted
+	cluster := clusterapi.ClusterCore{Name: "cluster1", NetworkID: "net-gone", PrivateNodeIDs: []string{host.ID, "node-gone"}}
+	require.Nil(t, utilsmetadata.NewItem(svc, "clusters").Carry(&cluster).Write(cluster.Name))
+
+	doctor := metadata.NewDoctor(svc)
+	doctor.Repair = true
+	findings, err := doctor.Check()
+	require.Nil(t, err)
+	details := map[string]bool{}
+	for _, f := range findings {
+		assert.Equal(t, metadata.DanglingReference, f.Problem)
+		assert.Equal(t, f.Details == "client of nas 'nas1'", f.Fixed, f.Details)
+		details[f.ResourceType+":"+f.ID+f.Name+":"+f.Details] = true
+	}
+	assert.Equal(t, map[string]bool{
+		"host:gone:server of nas 'nas1'":                 true,
+		"host:deleted:client of nas 'nas1'":              true,
+		"network:net-gone:network of cluster 'cluster1'": true,
+		"host:node-gone:node of cluster 'cluster1'":      true,
+	}, details)
+
+	clients, err := metadata.NewNas(svc).Carry(&nas).Listclients()
+	require.Nil(t, err)
+	require.Len(t, clients, 1)
+	assert.Equal(t, "host1", clients[0].Host)
+}
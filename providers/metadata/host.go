@@ -242,8 +242,8 @@ func LoadHost(svc *providers.Service, ref string) (*Host, error) {
 }
 
 // Acquire waits until the write lock is available, then locks the metadata
-func (m *Host) Acquire() {
-	m.item.Acquire()
+func (m *Host) Acquire() error {
+	return m.item.Acquire()
 }
 
 // Release unlocks the metadata
@@ -203,8 +203,8 @@ func (m *Nas) FindClient(hostName string) (*api.Nas, error) {
 }
 
 // Acquire waits until the write lock is available, then locks the metadata
-func (m *Nas) Acquire() {
-	m.item.Acquire()
+func (m *Nas) Acquire() error {
+	return m.item.Acquire()
 }
 
 // Release unlocks the metadata
@@ -274,8 +274,8 @@ func (m *Network) ListHosts() ([]*api.Host, error) {
 }
 
 // Acquire waits until the write lock is available, then locks the metadata
-func (m *Network) Acquire() {
-	m.item.Acquire()
+func (m *Network) Acquire() error {
+	return m.item.Acquire()
 }
 
 // Release unlocks the metadata
@@ -438,8 +438,8 @@ func (m *Gateway) Delete() error {
 }
 
 // Acquire waits until the write lock is available, then locks the metadata
-func (m *Gateway) Acquire() {
-	m.host.Acquire()
+func (m *Gateway) Acquire() error {
+	return m.host.Acquire()
 }
 
 // Release unlocks the metadata
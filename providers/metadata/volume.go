@@ -195,6 +195,16 @@ func (m *Volume) GetAttachment() (*api.VolumeAttachment, error) {
 	return &data, err
 }
 
+// Acquire waits until the write lock is available, then locks the metadata
+func (m *Volume) Acquire() error {
+	return m.item.Acquire()
+}
+
+// Release unlocks the metadata
+func (m *Volume) Release() {
+	m.item.Release()
+}
+
 // SaveVolume saves the Volume definition in Object Storage
 func SaveVolume(svc *providers.Service, volume *api.Volume) error {
 	return NewVolume(svc).Carry(volume).Write()
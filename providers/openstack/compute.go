@@ -17,6 +17,7 @@
 package openstack
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -323,12 +324,15 @@ func (client *Client) toHost(server *servers.Server) *api.Host {
 		AccessIPv6:   server.AccessIPv6,
 		Size:         client.toHostSize(server.Flavor),
 		State:        toHostState(server.Status),
+		Tags:         server.Metadata,
 	}
 	m, err := metadata.LoadHost(providers.FromClient(client), server.ID)
 	if err == nil && m != nil {
 		hostDef := m.Get()
 		host.GatewayID = hostDef.GatewayID
 		host.PrivateKey = hostDef.PrivateKey
+		host.HostKey = hostDef.HostKey
+		host.Tags = hostDef.Tags
 		//Floating IP management
 		if host.AccessIPv4 == "" {
 			host.AccessIPv4 = hostDef.AccessIPv4
@@ -380,7 +384,13 @@ func (client *Client) readGateway(networkID string) (*servers.Server, error) {
 
 // CreateHost creates an host satisfying request
 func (client *Client) CreateHost(request api.HostRequest) (*api.Host, error) {
-	host, err := client.createHost(request, false)
+	return client.CreateHostWithContext(context.Background(), request)
+}
+
+// CreateHostWithContext creates an host satisfying request, aborting the creation and deleting
+// the host if ctx is done before the host is ready
+func (client *Client) CreateHostWithContext(ctx context.Context, request api.HostRequest) (*api.Host, error) {
+	host, err := client.createHost(ctx, request, false)
 	if err != nil {
 		return nil, err
 	}
@@ -391,11 +401,16 @@ func (client *Client) CreateHost(request api.HostRequest) (*api.Host, error) {
 		return nil, fmt.Errorf("error creating host: %s", ProviderErrorToString(err))
 	}
 
+	// Context may have been done while saving metadata
+	if err := ctx.Err(); err != nil {
+		client.DeleteHost(host.ID)
+		return nil, err
+	}
 	return host, nil
 }
 
 // createHost ...
-func (client *Client) createHost(request api.HostRequest, isGateway bool) (*api.Host, error) {
+func (client *Client) createHost(ctx context.Context, request api.HostRequest, isGateway bool) (*api.Host, error) {
 	// We 1st check if name is not aleready used
 	m, err := metadata.LoadHost(providers.FromClient(client), request.Name)
 	if err != nil {
@@ -469,11 +484,16 @@ func (client *Client) createHost(request api.HostRequest, isGateway bool) (*api.
 		return nil, err
 	}
 
+	// Last chance to abort before creating something on the provider side
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	//fmt.Println(string(userData))
 	// Create host
 	srvOpts := servers.CreateOpts{
 		Name:           request.Name,
-		SecurityGroups: []string{client.SecurityGroup.Name},
+		SecurityGroups: client.hostSecurityGroups(request),
 		Networks:       nets,
 		FlavorRef:      request.TemplateID,
 		ImageRef:       request.ImageID,
@@ -489,9 +509,12 @@ func (client *Client) createHost(request api.HostRequest, isGateway bool) (*api.
 		return nil, fmt.Errorf("Error creating Host: %s", ProviderErrorToString(err))
 	}
 	// Wait that Host is ready
-	host, err := client.WaitHostReady(server.ID, 5*time.Minute)
+	host, err := client.WaitHostReadyWithContext(ctx, server.ID, 5*time.Minute)
 	if err != nil {
 		servers.Delete(client.Compute, server.ID)
+		if err == ctx.Err() {
+			return nil, err
+		}
 		return nil, fmt.Errorf("Error creating Host: %s", ProviderErrorToString(err))
 	}
 	// Add gateway ID to Host definition
@@ -532,15 +555,42 @@ func (client *Client) createHost(request api.HostRequest, isGateway bool) (*api.
 	return host, nil
 }
 
+// SetHostTags updates the tags of the host in the metadata of the server
+// The other metadata of the server, set by other tools, are kept
+func (client *Client) SetHostTags(id string, tags map[string]string, removed []string) error {
+	if len(tags) > 0 {
+		_, err := servers.UpdateMetadata(client.Compute, id, servers.MetadataOpts(tags)).Extract()
+		if err != nil {
+			return fmt.Errorf("Error setting tags of host '%s': %s", id, ProviderErrorToString(err))
+		}
+	}
+	for _, key := range removed {
+		err := servers.DeleteMetadatum(client.Compute, id, key).ExtractErr()
+		if err != nil {
+			if _, ok := err.(gc.ErrDefault404); ok {
+				continue
+			}
+			return fmt.Errorf("Error removing tag '%s' of host '%s': %s", key, id, ProviderErrorToString(err))
+		}
+	}
+	return nil
+}
+
 // WaitHostReady waits an host achieve ready state
 func (client *Client) WaitHostReady(hostID string, timeout time.Duration) (*api.Host, error) {
+	return client.WaitHostReadyWithContext(context.Background(), hostID, timeout)
+}
+
+// WaitHostReadyWithContext waits an host achieve ready state, giving up when ctx is done
+func (client *Client) WaitHostReadyWithContext(ctx context.Context, hostID string, timeout time.Duration) (*api.Host, error) {
 	var (
 		server *servers.Server
 		err    error
 		broken bool
 	)
 
-	retryErr := retry.WhileUnsuccessfulDelay5Seconds(
+	retryErr := retry.WhileUnsuccessfulDelay5SecondsWithContext(
+		ctx,
 		func() error {
 			server, err = servers.Get(client.Compute, hostID).Extract()
 			if err != nil {
@@ -579,7 +629,8 @@ func (client *Client) GetHost(ref string) (*api.Host, error) {
 		return nil, err
 	}
 	if m == nil {
-		return nil, providers.ResourceNotFoundError("host", ref)
+		// The host is not managed by SafeScale, when imported for instance
+		return client.getUnmanagedHost(ref)
 	}
 	id = m.Get().ID
 
@@ -618,6 +669,28 @@ func (client *Client) GetHost(ref string) (*api.Host, error) {
 	return client.toHost(server), nil
 }
 
+// getUnmanagedHost returns the host identified by ref (id or name) among all the hosts of the tenant
+func (client *Client) getUnmanagedHost(ref string) (*api.Host, error) {
+	server, err := servers.Get(client.Compute, ref).Extract()
+	if err == nil {
+		return client.toHost(server), nil
+	}
+	if _, ok := err.(gc.ErrDefault404); !ok {
+		return nil, fmt.Errorf("Error getting host '%s': %s", ref, ProviderErrorToString(err))
+	}
+	hosts, err := client.listAllHosts()
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range hosts {
+		if h.Name == ref {
+			host := h
+			return &host, nil
+		}
+	}
+	return nil, providers.ResourceNotFoundError("host", ref)
+}
+
 //ListHosts lists available hosts
 func (client *Client) ListHosts(all bool) ([]api.Host, error) {
 	if all {
@@ -853,6 +926,7 @@ func (client *Client) getSSHConfig(host *api.Host) (*system.SSHConfig, error) {
 	ip := host.GetAccessIP()
 	sshConfig := system.SSHConfig{
 		PrivateKey: host.PrivateKey,
+		HostKey:    host.HostKey,
 		Port:       22,
 		Host:       ip,
 		User:       api.DefaultUser,
@@ -865,6 +939,7 @@ func (client *Client) getSSHConfig(host *api.Host) (*system.SSHConfig, error) {
 		ip := gw.GetAccessIP()
 		GatewayConfig := system.SSHConfig{
 			PrivateKey: gw.PrivateKey,
+			HostKey:    gw.HostKey,
 			Port:       22,
 			User:       api.DefaultUser,
 			Host:       ip,
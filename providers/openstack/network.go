@@ -17,6 +17,7 @@
 package openstack
 
 import (
+	"context"
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"strings"
@@ -323,6 +324,12 @@ func (client *Client) DeleteNetwork(networkRef string) error {
 
 // CreateGateway creates a public Gateway for a private network
 func (client *Client) CreateGateway(req api.GWRequest) (*api.Host, error) {
+	return client.CreateGatewayWithContext(context.Background(), req)
+}
+
+// CreateGatewayWithContext creates a public Gateway for a private network, aborting the creation
+// and deleting the gateway if ctx is done before the gateway is ready
+func (client *Client) CreateGatewayWithContext(ctx context.Context, req api.GWRequest) (*api.Host, error) {
 	// Ensure network exists
 	net, err := client.GetNetwork(req.NetworkID)
 	if err != nil {
@@ -336,18 +343,27 @@ func (client *Client) CreateGateway(req api.GWRequest) (*api.Host, error) {
 		gwname = "gw-" + net.Name
 	}
 	hostReq := api.HostRequest{
-		ImageID:    req.ImageID,
-		KeyPair:    req.KeyPair,
-		Name:       gwname,
-		TemplateID: req.TemplateID,
-		NetworkIDs: []string{req.NetworkID},
-		PublicIP:   true,
-	}
-	host, err := client.createHost(hostReq, true)
+		ImageID:        req.ImageID,
+		KeyPair:        req.KeyPair,
+		Name:           gwname,
+		TemplateID:     req.TemplateID,
+		NetworkIDs:     []string{req.NetworkID},
+		PublicIP:       true,
+		SecurityGroups: req.SecurityGroups,
+	}
+	host, err := client.createHost(ctx, hostReq, true)
 	if err != nil {
+		if err == ctx.Err() {
+			return nil, err
+		}
 		return nil, fmt.Errorf("Error creating gateway : %s", ProviderErrorToString(err))
 	}
 	err = metadata.SaveGateway(providers.FromClient(client), host, req.NetworkID)
+	if err == nil && ctx.Err() != nil {
+		// Context done while saving metadata, the gateway is not wanted anymore
+		client.DeleteGateway(req.NetworkID)
+		return nil, ctx.Err()
+	}
 
 	// delete the host when found problem saving metadata
 	defer func(err error) {
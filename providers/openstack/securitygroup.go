@@ -0,0 +1,178 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"fmt"
+
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/enums/IPVersion"
+	"github.com/CS-SI/SafeScale/providers/enums/RuleDirection"
+
+	gc "github.com/gophercloud/gophercloud"
+	secgroups "github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/groups"
+	secrules "github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/security/rules"
+	"github.com/gophercloud/gophercloud/pagination"
+)
+
+// CreateSecurityGroup creates a security group without rule
+// Like any Neutron security group, the new group allows all outgoing traffic until these rules are removed
+func (client *Client) CreateSecurityGroup(request api.SecurityGroupRequest) (*api.SecurityGroup, error) {
+	group, err := secgroups.Create(client.Network, secgroups.CreateOpts{
+		Name:        request.Name,
+		Description: request.Description,
+	}).Extract()
+	if err != nil {
+		return nil, fmt.Errorf("Error creating security group '%s': %s", request.Name, ProviderErrorToString(err))
+	}
+	return toAPISecurityGroup(group), nil
+}
+
+// GetSecurityGroup returns the security group identified by ref (id or name), nil if it doesn't exist
+func (client *Client) GetSecurityGroup(ref string) (*api.SecurityGroup, error) {
+	group, err := secgroups.Get(client.Network, ref).Extract()
+	if err == nil {
+		return toAPISecurityGroup(group), nil
+	}
+	if _, ok := err.(gc.ErrDefault404); !ok {
+		return nil, fmt.Errorf("Error getting security group: %s", ProviderErrorToString(err))
+	}
+
+	list, err := client.listSecurityGroups(secgroups.ListOpts{Name: ref})
+	if err != nil {
+		return nil, err
+	}
+	switch len(list) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &list[0], nil
+	default:
+		return nil, fmt.Errorf("Several security groups are named '%s', use the ID instead", ref)
+	}
+}
+
+// ListSecurityGroups lists available security groups
+func (client *Client) ListSecurityGroups() ([]api.SecurityGroup, error) {
+	return client.listSecurityGroups(secgroups.ListOpts{})
+}
+
+// listSecurityGroups lists the security groups matching opts
+func (client *Client) listSecurityGroups(opts secgroups.ListOpts) ([]api.SecurityGroup, error) {
+	var list []api.SecurityGroup
+	err := secgroups.List(client.Network, opts).EachPage(func(page pagination.Page) (bool, error) {
+		groups, err := secgroups.ExtractGroups(page)
+		if err != nil {
+			return false, err
+		}
+		for _, g := range groups {
+			group := g
+			list = append(list, *toAPISecurityGroup(&group))
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error listing security groups: %s", ProviderErrorToString(err))
+	}
+	return list, nil
+}
+
+// DeleteSecurityGroup deletes the security group identified by id
+func (client *Client) DeleteSecurityGroup(id string) error {
+	err := secgroups.Delete(client.Network, id).ExtractErr()
+	if err != nil {
+		return fmt.Errorf("Error deleting security group: %s", ProviderErrorToString(err))
+	}
+	return nil
+}
+
+// AddSecurityGroupRule adds a rule to the security group identified by groupID
+func (client *Client) AddSecurityGroupRule(groupID string, rule api.SecurityGroupRule) (*api.SecurityGroupRule, error) {
+	opts := secrules.CreateOpts{
+		Direction:      secrules.DirIngress,
+		EtherType:      secrules.EtherType4,
+		SecGroupID:     groupID,
+		Protocol:       secrules.RuleProtocol(rule.Protocol),
+		PortRangeMin:   rule.PortFrom,
+		PortRangeMax:   rule.PortTo,
+		RemoteIPPrefix: rule.CIDR,
+	}
+	if rule.Direction == RuleDirection.EGRESS {
+		opts.Direction = secrules.DirEgress
+	}
+	if rule.IPVersion == IPVersion.IPv6 {
+		opts.EtherType = secrules.EtherType6
+	}
+	r, err := secrules.Create(client.Network, opts).Extract()
+	if err != nil {
+		return nil, fmt.Errorf("Error adding rule to security group: %s", ProviderErrorToString(err))
+	}
+	out := toAPISecurityGroupRule(*r)
+	return &out, nil
+}
+
+// DeleteSecurityGroupRule deletes the rule identified by ruleID from the security group identified by groupID
+func (client *Client) DeleteSecurityGroupRule(groupID string, ruleID string) error {
+	err := secrules.Delete(client.Network, ruleID).ExtractErr()
+	if err != nil {
+		return fmt.Errorf("Error deleting rule of security group: %s", ProviderErrorToString(err))
+	}
+	return nil
+}
+
+// hostSecurityGroups returns the security groups to bind to a new host: the ones of the request, or the default
+// security group if the request doesn't give any
+// Nova accepts the IDs of the groups as well as their names
+func (client *Client) hostSecurityGroups(request api.HostRequest) []string {
+	if len(request.SecurityGroups) > 0 {
+		return request.SecurityGroups
+	}
+	return []string{client.SecurityGroup.Name}
+}
+
+// toAPISecurityGroup converts a Neutron security group to an api.SecurityGroup
+func toAPISecurityGroup(g *secgroups.SecGroup) *api.SecurityGroup {
+	group := api.SecurityGroup{
+		ID:          g.ID,
+		Name:        g.Name,
+		Description: g.Description,
+	}
+	for _, r := range g.Rules {
+		group.Rules = append(group.Rules, toAPISecurityGroupRule(r))
+	}
+	return &group
+}
+
+// toAPISecurityGroupRule converts a Neutron security group rule to an api.SecurityGroupRule
+func toAPISecurityGroupRule(r secrules.SecGroupRule) api.SecurityGroupRule {
+	rule := api.SecurityGroupRule{
+		ID:        r.ID,
+		Direction: RuleDirection.INGRESS,
+		IPVersion: IPVersion.IPv4,
+		Protocol:  r.Protocol,
+		PortFrom:  r.PortRangeMin,
+		PortTo:    r.PortRangeMax,
+		CIDR:      r.RemoteIPPrefix,
+	}
+	if r.Direction == string(secrules.DirEgress) {
+		rule.Direction = RuleDirection.EGRESS
+	}
+	if r.EtherType == string(secrules.EtherType6) {
+		rule.IPVersion = IPVersion.IPv6
+	}
+	return rule
+}
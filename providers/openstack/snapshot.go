@@ -0,0 +1,134 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"fmt"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/metadata"
+
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v1/snapshots"
+	"github.com/gophercloud/gophercloud/openstack/blockstorage/v1/volumes"
+	"github.com/gophercloud/gophercloud/pagination"
+)
+
+func toAPISnapshot(s *snapshots.Snapshot) *api.Snapshot {
+	return &api.Snapshot{
+		ID:        s.ID,
+		Name:      s.Name,
+		VolumeID:  s.VolumeID,
+		Size:      s.Size,
+		State:     toVolumeState(s.Status),
+		CreatedAt: s.CreatedAt,
+	}
+}
+
+// CreateVolumeSnapshot creates a cinder snapshot of a volume, forced if the volume is attached
+func (client *Client) CreateVolumeSnapshot(request api.SnapshotRequest) (*api.Snapshot, error) {
+	snapshot, err := snapshots.Create(client.Volume, snapshots.CreateOpts{
+		VolumeID: request.VolumeID,
+		Name:     request.Name,
+		Force:    true,
+	}).Extract()
+	if err != nil {
+		return nil, fmt.Errorf("Error creating snapshot of volume '%s': %s", request.VolumeID, ProviderErrorToString(err))
+	}
+	return toAPISnapshot(snapshot), nil
+}
+
+// GetVolumeSnapshot returns the snapshot identified by id
+func (client *Client) GetVolumeSnapshot(id string) (*api.Snapshot, error) {
+	snapshot, err := snapshots.Get(client.Volume, id).Extract()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting snapshot: %s", ProviderErrorToString(err))
+	}
+	return toAPISnapshot(snapshot), nil
+}
+
+// ListVolumeSnapshots lists the snapshots of the volume identified by volumeID, or all the snapshots if volumeID
+// is empty
+func (client *Client) ListVolumeSnapshots(volumeID string) ([]api.Snapshot, error) {
+	var list []api.Snapshot
+	err := snapshots.List(client.Volume, snapshots.ListOpts{VolumeID: volumeID}).EachPage(func(page pagination.Page) (bool, error) {
+		ss, err := snapshots.ExtractSnapshots(page)
+		if err != nil {
+			return false, err
+		}
+		for i := range ss {
+			list = append(list, *toAPISnapshot(&ss[i]))
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error listing snapshots: %s", ProviderErrorToString(err))
+	}
+	return list, nil
+}
+
+// DeleteVolumeSnapshot deletes the snapshot identified by id
+func (client *Client) DeleteVolumeSnapshot(id string) error {
+	err := snapshots.Delete(client.Volume, id).ExtractErr()
+	if err != nil {
+		return fmt.Errorf("Error deleting snapshot: %s", ProviderErrorToString(err))
+	}
+	return nil
+}
+
+// CreateVolumeFromSnapshot creates a volume initialized with the content of the snapshot identified by snapshotID
+func (client *Client) CreateVolumeFromSnapshot(snapshotID string, request api.VolumeRequest) (*api.Volume, error) {
+	volume, err := metadata.LoadVolume(providers.FromClient(client), request.Name)
+	if err != nil {
+		return nil, err
+	}
+	if volume != nil {
+		return nil, fmt.Errorf("Volume '%s' already exists", request.Name)
+	}
+
+	size := request.Size
+	if size == 0 {
+		snapshot, err := client.GetVolumeSnapshot(snapshotID)
+		if err != nil {
+			return nil, err
+		}
+		size = snapshot.Size
+	}
+
+	vol, err := volumes.Create(client.Volume, volumes.CreateOpts{
+		Name:       request.Name,
+		Size:       size,
+		VolumeType: client.getVolumeType(request.Speed),
+		SnapshotID: snapshotID,
+	}).Extract()
+	if err != nil {
+		return nil, fmt.Errorf("Error creating volume from snapshot '%s': %s", snapshotID, ProviderErrorToString(err))
+	}
+	v := api.Volume{
+		ID:    vol.ID,
+		Name:  vol.Name,
+		Size:  vol.Size,
+		Speed: client.getVolumeSpeed(vol.VolumeType),
+		State: toVolumeState(vol.Status),
+	}
+	err = metadata.SaveVolume(providers.FromClient(client), &v)
+	if err != nil {
+		client.DeleteVolume(v.ID)
+		return nil, fmt.Errorf("Error creating volume : %s", ProviderErrorToString(err))
+	}
+	return &v, nil
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/CS-SI/SafeScale/providers/enums/VolumeState"
 	"github.com/CS-SI/SafeScale/providers/metadata"
 
+	gc "github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack/blockstorage/v1/volumes"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/volumeattach"
 	"github.com/gophercloud/gophercloud/openstack/objectstorage/v1/containers"
@@ -131,10 +132,36 @@ func (client *Client) GetVolume(id string) (*api.Volume, error) {
 		Size:  vol.Size,
 		Speed: client.getVolumeSpeed(vol.VolumeType),
 		State: toVolumeState(vol.Status),
+		Tags:  vol.Metadata,
 	}
 	return &av, nil
 }
 
+// SetVolumeTags updates the tags of the volume in its metadata
+// The other metadata of the volume, set by other tools, are kept
+func (client *Client) SetVolumeTags(id string, tags map[string]string, removed []string) error {
+	if len(tags) > 0 {
+		// POST on the metadata of a volume merges them with the existing ones (PUT would replace them)
+		b := map[string]interface{}{"metadata": tags}
+		_, err := client.Volume.Post(client.Volume.ServiceURL("volumes", id, "metadata"), b, nil, &gc.RequestOpts{
+			OkCodes: []int{200},
+		})
+		if err != nil {
+			return fmt.Errorf("Error setting tags of volume '%s': %s", id, ProviderErrorToString(err))
+		}
+	}
+	for _, key := range removed {
+		_, err := client.Volume.Delete(client.Volume.ServiceURL("volumes", id, "metadata", key), nil)
+		if err != nil {
+			if _, ok := err.(gc.ErrDefault404); ok {
+				continue
+			}
+			return fmt.Errorf("Error removing tag '%s' of volume '%s': %s", key, id, ProviderErrorToString(err))
+		}
+	}
+	return nil
+}
+
 //ListVolumes return the list of all volume known on the current tenant (all=ture)
 //or 'only' thode monitored by safescale (all=false) ie those monitored by metadata
 func (client *Client) ListVolumes(all bool) ([]api.Volume, error) {
@@ -160,6 +187,7 @@ func (client *Client) listAllVolumes() ([]api.Volume, error) {
 				Size:  vol.Size,
 				Speed: client.getVolumeSpeed(vol.VolumeType),
 				State: toVolumeState(vol.Status),
+				Tags:  vol.Metadata,
 			}
 			vs = append(vs, av)
 		}
@@ -0,0 +1,133 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package openstack
+
+import (
+	"fmt"
+
+	"github.com/CS-SI/SafeScale/providers/api"
+
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+)
+
+// CreateVIP reserves a virtual IP address in the network identified by networkID, with a port bound to no host
+func (client *Client) CreateVIP(networkID string, name string) (*api.VIP, error) {
+	port, err := ports.Create(client.Network, ports.CreateOpts{
+		NetworkID: networkID,
+		Name:      name,
+	}).Extract()
+	if err != nil {
+		return nil, fmt.Errorf("Error creating VIP '%s': %s", name, ProviderErrorToString(err))
+	}
+	if len(port.FixedIPs) == 0 {
+		ports.Delete(client.Network, port.ID)
+		return nil, fmt.Errorf("Error creating VIP '%s': no address allocated", name)
+	}
+	return &api.VIP{
+		ID:        port.ID,
+		Name:      name,
+		NetworkID: networkID,
+		PrivateIP: port.FixedIPs[0].IPAddress,
+	}, nil
+}
+
+// BindHostToVIP adds the address of the VIP to the allowed address pairs of the port of the host in the network of
+// the VIP
+func (client *Client) BindHostToVIP(vip *api.VIP, hostID string) error {
+	port, err := client.hostPort(hostID, vip.NetworkID)
+	if err != nil {
+		return fmt.Errorf("Error binding host '%s' to VIP '%s': %s", hostID, vip.Name, err.Error())
+	}
+	pairs := port.AllowedAddressPairs
+	found := false
+	for _, pair := range pairs {
+		if pair.IPAddress == vip.PrivateIP {
+			found = true
+			break
+		}
+	}
+	if !found {
+		pairs = append(pairs, ports.AddressPair{IPAddress: vip.PrivateIP})
+		_, err = ports.Update(client.Network, port.ID, ports.UpdateOpts{AllowedAddressPairs: &pairs}).Extract()
+		if err != nil {
+			return fmt.Errorf("Error binding host '%s' to VIP '%s': %s", hostID, vip.Name, ProviderErrorToString(err))
+		}
+	}
+	for _, id := range vip.Hosts {
+		if id == hostID {
+			return nil
+		}
+	}
+	vip.Hosts = append(vip.Hosts, hostID)
+	return nil
+}
+
+// UnbindHostFromVIP removes the address of the VIP from the allowed address pairs of the port of the host
+func (client *Client) UnbindHostFromVIP(vip *api.VIP, hostID string) error {
+	port, err := client.hostPort(hostID, vip.NetworkID)
+	if err != nil {
+		return fmt.Errorf("Error unbinding host '%s' from VIP '%s': %s", hostID, vip.Name, err.Error())
+	}
+	pairs := []ports.AddressPair{}
+	for _, pair := range port.AllowedAddressPairs {
+		if pair.IPAddress != vip.PrivateIP {
+			pairs = append(pairs, pair)
+		}
+	}
+	if len(pairs) != len(port.AllowedAddressPairs) {
+		_, err = ports.Update(client.Network, port.ID, ports.UpdateOpts{AllowedAddressPairs: &pairs}).Extract()
+		if err != nil {
+			return fmt.Errorf("Error unbinding host '%s' from VIP '%s': %s", hostID, vip.Name, ProviderErrorToString(err))
+		}
+	}
+	var hosts []string
+	for _, id := range vip.Hosts {
+		if id != hostID {
+			hosts = append(hosts, id)
+		}
+	}
+	vip.Hosts = hosts
+	return nil
+}
+
+// DeleteVIP releases the virtual IP address by deleting its port
+func (client *Client) DeleteVIP(vip *api.VIP) error {
+	err := ports.Delete(client.Network, vip.ID).ExtractErr()
+	if err != nil {
+		return fmt.Errorf("Error deleting VIP '%s': %s", vip.Name, ProviderErrorToString(err))
+	}
+	return nil
+}
+
+// hostPort returns the port of the host identified by hostID in the network identified by networkID
+func (client *Client) hostPort(hostID string, networkID string) (*ports.Port, error) {
+	page, err := ports.List(client.Network, ports.ListOpts{
+		DeviceID:  hostID,
+		NetworkID: networkID,
+	}).AllPages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the ports of the host: %s", ProviderErrorToString(err))
+	}
+	list, err := ports.ExtractPorts(page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the ports of the host: %s", ProviderErrorToString(err))
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("the host isn't attached to the network '%s'", networkID)
+	}
+	return &list[0], nil
+}
@@ -17,6 +17,8 @@
 package opentelekom
 
 import (
+	"context"
+
 	"github.com/CS-SI/SafeScale/providers/api"
 
 	"github.com/CS-SI/SafeScale/system"
@@ -27,6 +29,11 @@ func (client *Client) CreateHost(request api.HostRequest) (*api.Host, error) {
 	return client.feclt.CreateHost(request)
 }
 
+// CreateHostWithContext creates a new host, aborting when ctx is done
+func (client *Client) CreateHostWithContext(ctx context.Context, request api.HostRequest) (*api.Host, error) {
+	return client.feclt.CreateHostWithContext(ctx, request)
+}
+
 // GetHost returns the host identified by id
 func (client *Client) GetHost(id string) (*api.Host, error) {
 	return client.feclt.GetHost(id)
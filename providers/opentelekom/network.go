@@ -17,6 +17,8 @@
 package opentelekom
 
 import (
+	"context"
+
 	"github.com/CS-SI/SafeScale/providers/api"
 )
 
@@ -47,6 +49,11 @@ func (client *Client) CreateGateway(req api.GWRequest) (*api.Host, error) {
 	return client.feclt.CreateGateway(req)
 }
 
+// CreateGatewayWithContext creates a gateway for a network, aborting when ctx is done
+func (client *Client) CreateGatewayWithContext(ctx context.Context, req api.GWRequest) (*api.Host, error) {
+	return client.feclt.CreateGatewayWithContext(ctx, req)
+}
+
 // GetGateway returns the name of the gateway of a network
 func (client *Client) GetGateway(networkID string) (*api.Host, error) {
 	return client.feclt.GetGateway(networkID)
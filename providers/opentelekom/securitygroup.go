@@ -0,0 +1,51 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package opentelekom
+
+import (
+	"github.com/CS-SI/SafeScale/providers/api"
+)
+
+// CreateSecurityGroup creates a security group without rule
+func (client *Client) CreateSecurityGroup(request api.SecurityGroupRequest) (*api.SecurityGroup, error) {
+	return client.feclt.CreateSecurityGroup(request)
+}
+
+// GetSecurityGroup returns the security group identified by ref (id or name)
+func (client *Client) GetSecurityGroup(ref string) (*api.SecurityGroup, error) {
+	return client.feclt.GetSecurityGroup(ref)
+}
+
+// ListSecurityGroups lists available security groups
+func (client *Client) ListSecurityGroups() ([]api.SecurityGroup, error) {
+	return client.feclt.ListSecurityGroups()
+}
+
+// DeleteSecurityGroup deletes the security group identified by id
+func (client *Client) DeleteSecurityGroup(id string) error {
+	return client.feclt.DeleteSecurityGroup(id)
+}
+
+// AddSecurityGroupRule adds a rule to the security group identified by groupID
+func (client *Client) AddSecurityGroupRule(groupID string, rule api.SecurityGroupRule) (*api.SecurityGroupRule, error) {
+	return client.feclt.AddSecurityGroupRule(groupID, rule)
+}
+
+// DeleteSecurityGroupRule deletes the rule identified by ruleID from the security group identified by groupID
+func (client *Client) DeleteSecurityGroupRule(groupID string, ruleID string) error {
+	return client.feclt.DeleteSecurityGroupRule(groupID, ruleID)
+}
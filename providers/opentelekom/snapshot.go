@@ -0,0 +1,47 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package opentelekom
+
+import (
+	"github.com/CS-SI/SafeScale/providers/api"
+)
+
+// CreateVolumeSnapshot creates a snapshot of a volume, even if the volume is attached
+func (client *Client) CreateVolumeSnapshot(request api.SnapshotRequest) (*api.Snapshot, error) {
+	return client.feclt.CreateVolumeSnapshot(request)
+}
+
+// GetVolumeSnapshot returns the snapshot identified by id
+func (client *Client) GetVolumeSnapshot(id string) (*api.Snapshot, error) {
+	return client.feclt.GetVolumeSnapshot(id)
+}
+
+// ListVolumeSnapshots lists the snapshots of the volume identified by volumeID, or all the snapshots if volumeID
+// is empty
+func (client *Client) ListVolumeSnapshots(volumeID string) ([]api.Snapshot, error) {
+	return client.feclt.ListVolumeSnapshots(volumeID)
+}
+
+// DeleteVolumeSnapshot deletes the snapshot identified by id
+func (client *Client) DeleteVolumeSnapshot(id string) error {
+	return client.feclt.DeleteVolumeSnapshot(id)
+}
+
+// CreateVolumeFromSnapshot creates a volume initialized with the content of the snapshot identified by snapshotID
+func (client *Client) CreateVolumeFromSnapshot(snapshotID string, request api.VolumeRequest) (*api.Volume, error) {
+	return client.feclt.CreateVolumeFromSnapshot(snapshotID, request)
+}
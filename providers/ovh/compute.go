@@ -17,6 +17,7 @@
 package ovh
 
 import (
+	"context"
 	"log"
 	"strings"
 	"time"
@@ -99,6 +100,11 @@ func (client *Client) CreateHost(request api.HostRequest) (*api.Host, error) {
 	return client.osclt.CreateHost(request)
 }
 
+// CreateHostWithContext creates an host satisfying request, aborting when ctx is done
+func (client *Client) CreateHostWithContext(ctx context.Context, request api.HostRequest) (*api.Host, error) {
+	return client.osclt.CreateHostWithContext(ctx, request)
+}
+
 // WaitHostReady waits an host achieve ready state
 func (client *Client) WaitHostReady(hostID string, timeout time.Duration) (*api.Host, error) {
 	return client.osclt.WaitHostReady(hostID, timeout)
@@ -129,6 +135,11 @@ func (client *Client) RebootHost(id string) error {
 	return client.osclt.RebootHost(id)
 }
 
+// SetHostTags updates the tags of the host in the metadata of the server
+func (client *Client) SetHostTags(id string, tags map[string]string, removed []string) error {
+	return client.osclt.SetHostTags(id, tags, removed)
+}
+
 // StartHost starts the host identified by id
 func (client *Client) StartHost(id string) error {
 	return client.osclt.StartHost(id)
@@ -17,6 +17,7 @@
 package ovh
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/CS-SI/SafeScale/providers/api"
@@ -51,6 +52,11 @@ func (client *Client) CreateGateway(req api.GWRequest) (*api.Host, error) {
 	return client.osclt.CreateGateway(req)
 }
 
+// CreateGatewayWithContext creates a public Gateway for a private network, aborting when ctx is done
+func (client *Client) CreateGatewayWithContext(ctx context.Context, req api.GWRequest) (*api.Host, error) {
+	return client.osclt.CreateGatewayWithContext(ctx, req)
+}
+
 // DeleteGateway delete the public gateway of a private network
 func (client *Client) DeleteGateway(networkID string) error {
 	return client.osclt.DeleteGateway(networkID)
@@ -44,6 +44,11 @@ func (client *Client) DeleteVolume(id string) error {
 	return client.osclt.DeleteVolume(id)
 }
 
+// SetVolumeTags updates the tags of the volume in its metadata
+func (client *Client) SetVolumeTags(id string, tags map[string]string, removed []string) error {
+	return client.osclt.SetVolumeTags(id, tags, removed)
+}
+
 // CreateVolumeAttachment attaches a volume to an host
 // - 'name' of the volume attachment
 // - 'volume' to attach
@@ -17,7 +17,9 @@
 package providers
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"math"
 	"sort"
 	"strings"
@@ -32,6 +34,9 @@ import (
 	uuid "github.com/satori/go.uuid"
 )
 
+// StatePollDelay is the delay between 2 reads of the state of a resource waited to reach a state
+var StatePollDelay = 1 * time.Second
+
 // ResourceError resource error
 type ResourceError struct {
 	Name         string
@@ -146,12 +151,17 @@ type ServerRequest struct {
 
 //WaitHostState waits an host achieve state
 func (srv *Service) WaitHostState(hostID string, state HostState.Enum, timeout time.Duration) (*api.Host, error) {
-	var host *api.Host
-	var err error
-	timer := time.After(timeout)
-	next := true
-	for next {
-		host, err = srv.GetHost(hostID)
+	return srv.WaitHostStateWithContext(context.Background(), hostID, state, timeout)
+}
+
+//WaitHostStateWithContext waits an host achieve state, giving up when ctx is done
+func (srv *Service) WaitHostStateWithContext(ctx context.Context, hostID string, state HostState.Enum, timeout time.Duration) (*api.Host, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(StatePollDelay)
+	defer ticker.Stop()
+	for {
+		host, err := srv.GetHost(hostID)
 		if host == nil {
 			return nil, err
 		} else if host.State == state {
@@ -160,13 +170,13 @@ func (srv *Service) WaitHostState(hostID string, state HostState.Enum, timeout t
 			return host, fmt.Errorf("host in error state")
 		}
 		select {
-		case <-timer:
+		case <-ctx.Done():
+			return host, ctx.Err()
+		case <-timer.C:
 			return host, fmt.Errorf("timeout waiting host '%s' to reach state '%s'", host.Name, state.String())
-		default:
-			time.Sleep(1)
+		case <-ticker.C:
 		}
 	}
-	return host, err
 }
 
 //WaitHostState waits an host achieve state
@@ -252,49 +262,29 @@ func (srv *Service) WaitHostState(hostID string, state HostState.Enum, timeout t
 
 //WaitVolumeState waits an host achieve state
 func (srv *Service) WaitVolumeState(volumeID string, state VolumeState.Enum, timeout time.Duration) (*api.Volume, error) {
-	cout := make(chan int)
-	next := make(chan bool)
-	vc := make(chan *api.Volume)
-
-	go pollVolume(srv, volumeID, state, cout, next, vc)
-	for {
-		select {
-		case res := <-cout:
-			if res == 0 {
-				//next <- false
-				return nil, fmt.Errorf("Error getting host state")
-			}
-			if res == 1 {
-				//next <- false
-				return <-vc, nil
-			}
-			if res == 2 {
-				next <- true
-			}
-		case <-time.After(timeout):
-			next <- false
-			return nil, &api.ErrTimeout{Message: "Wait host state timeout"}
-		}
-	}
+	return srv.WaitVolumeStateWithContext(context.Background(), volumeID, state, timeout)
 }
 
-func pollVolume(client api.ClientAPI, volumeID string, state VolumeState.Enum, cout chan int, next chan bool, hostc chan *api.Volume) {
+//WaitVolumeStateWithContext waits a volume achieve state, giving up when ctx is done
+func (srv *Service) WaitVolumeStateWithContext(ctx context.Context, volumeID string, state VolumeState.Enum, timeout time.Duration) (*api.Volume, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(StatePollDelay)
+	defer ticker.Stop()
 	for {
-
-		v, err := client.GetVolume(volumeID)
+		v, err := srv.GetVolume(volumeID)
 		if err != nil {
-
-			cout <- 0
-			return
+			return nil, fmt.Errorf("Error getting volume state: %s", err.Error())
 		}
 		if v.State == state {
-			cout <- 1
-			hostc <- v
-			return
+			return v, nil
 		}
-		cout <- 2
-		if !<-next {
-			return
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			return nil, &api.ErrTimeout{Message: "Wait volume state timeout"}
+		case <-ticker.C:
 		}
 	}
 }
@@ -373,6 +363,11 @@ func (srv *Service) SearchImage(osname string) (*api.Image, error) {
 
 //CreateHostWithKeyPair creates an host
 func (srv *Service) CreateHostWithKeyPair(request api.HostRequest) (*api.Host, *api.KeyPair, error) {
+	return srv.CreateHostWithKeyPairWithContext(context.Background(), request)
+}
+
+//CreateHostWithKeyPairWithContext creates an host, aborting and rolling back the creation when ctx is done
+func (srv *Service) CreateHostWithKeyPairWithContext(ctx context.Context, request api.HostRequest) (*api.Host, *api.KeyPair, error) {
 	_, err := srv.GetHostByName(request.Name)
 	if err == nil {
 		return nil, nil, ResourceAlreadyExistsError("Host", request.Name)
@@ -389,14 +384,15 @@ func (srv *Service) CreateHostWithKeyPair(request api.HostRequest) (*api.Host, *
 
 	//Create host
 	hostReq := api.HostRequest{
-		Name:       request.Name,
-		ImageID:    request.ImageID,
-		KeyPair:    kp,
-		PublicIP:   request.PublicIP,
-		NetworkIDs: request.NetworkIDs,
-		TemplateID: request.TemplateID,
-	}
-	host, err := srv.CreateHost(hostReq)
+		Name:           request.Name,
+		ImageID:        request.ImageID,
+		KeyPair:        kp,
+		PublicIP:       request.PublicIP,
+		NetworkIDs:     request.NetworkIDs,
+		TemplateID:     request.TemplateID,
+		SecurityGroups: request.SecurityGroups,
+	}
+	host, err := srv.CreateHostWithContext(ctx, hostReq)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -467,6 +463,142 @@ func (srv *Service) CreateHost(request api.HostRequest) (*api.Host, error) {
 	return srv.ClientAPI.CreateHost(request)
 }
 
+// rollback deletes a resource created while the context was done
+func rollback(resource string, name string, remove func() error) {
+	log.Printf("Context done during creation of %s '%s', deleting it", resource, name)
+	err := remove()
+	if err != nil {
+		log.Printf("Failed to delete %s '%s' after context was done: %s", resource, name, err.Error())
+	}
+}
+
+// runWithContext runs create, which can't be interrupted, in a goroutine and returns as soon as it's over or ctx is done.
+// In the latter case, ctx.Err() is returned and rollback is called once create succeeds, to delete what it created
+func runWithContext(ctx context.Context, create func() error, rollback func()) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- create()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		go func() {
+			if err := <-done; err == nil {
+				rollback()
+			}
+		}()
+		return ctx.Err()
+	}
+}
+
+// CreateHostWithContext creates an host that fulfils the request, aborting when ctx is done.
+// If the host has been created when ctx is done, it's deleted and ctx.Err() is returned
+func (srv *Service) CreateHostWithContext(ctx context.Context, request api.HostRequest) (*api.Host, error) {
+	if len(request.NetworkIDs) == 0 {
+		net, err := srv.getOrCreateDefaultNetwork()
+		if err != nil {
+			return nil, err
+		}
+		request.NetworkIDs = append(request.NetworkIDs, net.ID)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if clt, ok := srv.ClientAPI.(api.ContextClientAPI); ok {
+		return clt.CreateHostWithContext(ctx, request)
+	}
+	// The driver can't be interrupted, so the host is removed once created
+	var host *api.Host
+	err := runWithContext(ctx, func() (err error) {
+		host, err = srv.ClientAPI.CreateHost(request)
+		return err
+	}, func() {
+		rollback("host", host.Name, func() error { return srv.ClientAPI.DeleteHost(host.ID) })
+	})
+	if err != nil {
+		return nil, err
+	}
+	return host, nil
+}
+
+// CreateGatewayWithContext creates the gateway of a network, aborting when ctx is done.
+// If the gateway has been created when ctx is done, it's deleted and ctx.Err() is returned
+func (srv *Service) CreateGatewayWithContext(ctx context.Context, req api.GWRequest) (*api.Host, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if clt, ok := srv.ClientAPI.(api.ContextClientAPI); ok {
+		return clt.CreateGatewayWithContext(ctx, req)
+	}
+	var host *api.Host
+	err := runWithContext(ctx, func() (err error) {
+		host, err = srv.ClientAPI.CreateGateway(req)
+		return err
+	}, func() {
+		rollback("gateway", host.Name, func() error { return srv.ClientAPI.DeleteGateway(req.NetworkID) })
+	})
+	if err != nil {
+		return nil, err
+	}
+	return host, nil
+}
+
+// CreateNetworkWithContext creates a network, returning as soon as ctx is done.
+// If the network gets created after ctx is done, it's deleted
+func (srv *Service) CreateNetworkWithContext(ctx context.Context, req api.NetworkRequest) (*api.Network, error) {
+	var network *api.Network
+	err := runWithContext(ctx, func() (err error) {
+		network, err = srv.ClientAPI.CreateNetwork(req)
+		return err
+	}, func() {
+		rollback("network", network.Name, func() error { return srv.ClientAPI.DeleteNetwork(network.ID) })
+	})
+	if err != nil {
+		return nil, err
+	}
+	return network, nil
+}
+
+// CreateVolumeWithContext creates a volume, returning as soon as ctx is done.
+// If the volume gets created after ctx is done, it's deleted
+func (srv *Service) CreateVolumeWithContext(ctx context.Context, request api.VolumeRequest) (*api.Volume, error) {
+	var volume *api.Volume
+	err := runWithContext(ctx, func() (err error) {
+		volume, err = srv.ClientAPI.CreateVolume(request)
+		return err
+	}, func() {
+		rollback("volume", volume.Name, func() error { return srv.ClientAPI.DeleteVolume(volume.ID) })
+	})
+	if err != nil {
+		return nil, err
+	}
+	return volume, nil
+}
+
+// SetHostTags pushes the tags of a host, and the removal of the tags 'removed', to the provider, if the driver
+// supports it
+func (srv *Service) SetHostTags(id string, tags map[string]string, removed []string) error {
+	if clt, ok := srv.ClientAPI.(api.TagClientAPI); ok {
+		return clt.SetHostTags(id, tags, removed)
+	}
+	return nil
+}
+
+// SetVolumeTags pushes the tags of a volume, and the removal of the tags 'removed', to the provider, if the driver
+// supports it
+func (srv *Service) SetVolumeTags(id string, tags map[string]string, removed []string) error {
+	if clt, ok := srv.ClientAPI.(api.TagClientAPI); ok {
+		return clt.SetVolumeTags(id, tags, removed)
+	}
+	return nil
+}
+
 func runeIndexes(s string, r rune) []int {
 	positions := []int{}
 	for i, l := range s {
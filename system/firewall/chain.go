@@ -48,10 +48,38 @@ func (c *Chain) Insert(rule Rule) *Chain {
 
 // Remove a rule from the chain
 func (c *Chain) Remove(rule Rule) *Chain {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	spec := rule.String()
+	rules := c.Rules[:0]
+	for _, r := range c.Rules {
+		if r.String() != spec {
+			rules = append(rules, r)
+		}
+	}
+	c.Rules = rules
 	return c
 }
 
 // RemoveIndex removes a rule by its index in the chain
 func (c *Chain) RemoveIndex(idx uint) *Chain {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if int(idx) < len(c.Rules) {
+		c.Rules = append(c.Rules[:idx], c.Rules[idx+1:]...)
+	}
 	return c
 }
+
+// Index returns the index of the first occurrence of rule in the chain, -1 if the chain doesn't contain it
+func (c *Chain) Index(rule Rule) int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	spec := rule.String()
+	for i, r := range c.Rules {
+		if r.String() == spec {
+			return i
+		}
+	}
+	return -1
+}
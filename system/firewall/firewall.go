@@ -17,16 +17,43 @@
 package firewall
 
 import (
+	"bytes"
 	"fmt"
+	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/CS-SI/SafeScale/system"
+	"github.com/CS-SI/SafeScale/system/firewall/Policy"
+	"github.com/CS-SI/SafeScale/utils/retry"
 )
 
+// RollbackDelay is the time after which the previous rules are restored on the host if the application of the new
+// ones cannot be confirmed through a new SSH connection
+var RollbackDelay = 60 * time.Second
+
+// tableNames lists the tables of iptables, in the order used by iptables-save
+var tableNames = []string{"raw", "mangle", "nat", "filter", "security"}
+
+// applyScript checks and saves the current rules, schedules their restoration, then applies the new rules atomically
+// Parameters are the new rules file, the backup file, the file containing the PID of the rollback and its delay
+const applyScript = `set -e
+iptables-restore --test < %[1]s
+iptables-save > %[2]s
+setsid nohup sh -c 'sleep %[4]d; iptables-restore < %[2]s; rm -f %[1]s %[2]s %[3]s' </dev/null >/dev/null 2>&1 &
+echo $! > %[3]s
+iptables-restore < %[1]s`
+
+// confirmScript cancels the rollback scheduled by applyScript
+const confirmScript = `kill $(cat %[3]s) && rm -f %[1]s %[2]s %[3]s`
+
 // Firewall implements a Firewall based on iptables
 type Firewall struct {
 	// Tables contains all the tables of the Firewall
 	Tables map[string]*Table
+
+	ssh *system.SSHConfig
 }
 
 // NewFirewall creates a new instance of Firewall and returns a pointer to it
@@ -56,13 +83,122 @@ func NewFirewall(ssh *system.SSHConfig) *Firewall {
 				Chains: nil,
 			},
 		},
+		ssh: ssh,
 	}
 	return &fw
 }
 
 // FromSystem loads the current Firewall configuration from system
 func (fw *Firewall) FromSystem() error {
-	return fmt.Errorf("not yet implemented")
+	if fw.ssh == nil {
+		return fmt.Errorf("no SSH configuration to reach the host")
+	}
+	cmd, err := fw.ssh.SudoCommand("iptables-save")
+	if err != nil {
+		return err
+	}
+	retcode, stdout, stderr, err := cmd.Run()
+	if err != nil {
+		return err
+	}
+	if retcode != 0 {
+		return fmt.Errorf("failed to read firewall rules: %s", stderr)
+	}
+	return fw.Parse(stdout)
+}
+
+// Parse replaces the Firewall configuration by the one described by content, in the format of iptables-save
+func (fw *Firewall) Parse(content string) error {
+	tables := map[string]*Table{}
+	var table *Table
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "*"):
+			table = &Table{Name: line[1:], Chains: map[string]*Chain{}}
+			tables[table.Name] = table
+		case table == nil:
+			return fmt.Errorf("line %d: '%s' outside of a table", i+1, line)
+		case line == "COMMIT":
+			table = nil
+		case strings.HasPrefix(line, ":"):
+			fields := strings.Fields(line[1:])
+			if len(fields) < 2 {
+				return fmt.Errorf("line %d: invalid chain declaration '%s'", i+1, line)
+			}
+			policy, err := ParsePolicy(fields[1])
+			if err != nil {
+				return fmt.Errorf("line %d: %s", i+1, err.Error())
+			}
+			table.NewChain(fields[0], policy)
+		case strings.HasPrefix(line, "-A "):
+			fields := strings.SplitN(line, " ", 3)
+			if len(fields) < 3 {
+				return fmt.Errorf("line %d: invalid rule '%s'", i+1, line)
+			}
+			chain, err := table.Chain(fields[1])
+			if err != nil {
+				return fmt.Errorf("line %d: %s", i+1, err.Error())
+			}
+			rule, err := ParseRule(fields[2])
+			if err != nil {
+				return fmt.Errorf("line %d: %s", i+1, err.Error())
+			}
+			chain.Add(rule)
+		default:
+			return fmt.Errorf("line %d: unexpected content '%s'", i+1, line)
+		}
+	}
+	if table != nil {
+		return fmt.Errorf("table '%s' is not committed", table.Name)
+	}
+	fw.Tables = tables
+	return nil
+}
+
+// String returns the Firewall configuration in the format of iptables-save
+// Tables without chains are omitted, iptables-restore would flush them
+func (fw *Firewall) String() string {
+	var buffer bytes.Buffer
+	for _, name := range fw.TableNames() {
+		table := fw.Tables[name]
+		if len(table.Chains) == 0 {
+			continue
+		}
+		chains := table.ChainNames()
+		fmt.Fprintf(&buffer, "*%s\n", table.Name)
+		for _, c := range chains {
+			fmt.Fprintf(&buffer, ":%s %s [0:0]\n", c, PolicyString(table.Chains[c].Policy))
+		}
+		for _, c := range chains {
+			for _, rule := range table.Chains[c].Rules {
+				fmt.Fprintf(&buffer, "-A %s %s\n", c, rule.String())
+			}
+		}
+		buffer.WriteString("COMMIT\n")
+	}
+	return buffer.String()
+}
+
+// TableNames returns the names of the tables of the firewall, in the order used by iptables-save
+func (fw *Firewall) TableNames() []string {
+	var names, others []string
+	known := map[string]bool{}
+	for _, name := range tableNames {
+		known[name] = true
+		if _, ok := fw.Tables[name]; ok {
+			names = append(names, name)
+		}
+	}
+	for name := range fw.Tables {
+		if !known[name] {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...)
 }
 
 // Table returns the Table instance corresponding to table 'name'
@@ -77,6 +213,87 @@ func (fw *Firewall) Table(name string) (*Table, error) {
 }
 
 // ToSystem applies the firewall rules to the host
+// The rules are applied atomically with iptables-restore; if the host can't be reached with SSH afterwards,
+// the previous rules are restored after RollbackDelay
 func (fw *Firewall) ToSystem() error {
-	return fmt.Errorf("not yet implemented")
+	if fw.ssh == nil {
+		return fmt.Errorf("no SSH configuration to reach the host")
+	}
+	f, err := system.CreateTempFileFromString(fw.String(), 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %s", err.Error())
+	}
+	defer os.Remove(f.Name())
+
+	prefix := fmt.Sprintf("/var/tmp/safescale-firewall-%d", time.Now().UnixNano())
+	rules, backup, rollback := prefix+".rules", prefix+".backup", prefix+".rollback"
+	retcode, _, stderr, err := fw.ssh.Copy(rules, f.Name(), true)
+	if err != nil {
+		return err
+	}
+	if retcode != 0 {
+		return fmt.Errorf("failed to copy firewall rules to remote host: %s", stderr)
+	}
+
+	cmd, err := fw.ssh.SudoCommand(fmt.Sprintf(applyScript, rules, backup, rollback, int(RollbackDelay/time.Second)))
+	if err != nil {
+		return err
+	}
+	retcode, _, stderr, err = cmd.Run()
+	if err != nil {
+		return err
+	}
+	if retcode == 255 {
+		return fmt.Errorf("connection lost while applying firewall rules, previous rules will be restored in %s", RollbackDelay)
+	}
+	if retcode != 0 {
+		return fmt.Errorf("failed to apply firewall rules: %s", stderr)
+	}
+
+	// Confirms through a new connection that the host is still reachable, which cancels the rollback
+	err = retry.WhileUnsuccessfulDelay1Second(
+		func() error {
+			cmd, err := fw.ssh.SudoCommandOnNewConnection(fmt.Sprintf(confirmScript, rules, backup, rollback))
+			if err != nil {
+				return err
+			}
+			retcode, _, stderr, err := cmd.Run()
+			if err != nil {
+				return err
+			}
+			if retcode != 0 {
+				return fmt.Errorf("%s", stderr)
+			}
+			return nil
+		},
+		RollbackDelay/2,
+	)
+	if err != nil {
+		return fmt.Errorf("host unreachable after applying firewall rules, previous rules will be restored in %s: %s", RollbackDelay, err.Error())
+	}
+	return nil
+}
+
+// ParsePolicy returns the policy corresponding to its iptables-save representation
+func ParsePolicy(policy string) (Policy.Enum, error) {
+	switch strings.ToUpper(policy) {
+	case "ACCEPT":
+		return Policy.Accept, nil
+	case "DROP":
+		return Policy.Drop, nil
+	case "-":
+		return 0, nil
+	}
+	return 0, fmt.Errorf("unknown policy '%s'", policy)
+}
+
+// PolicyString returns the iptables-save representation of policy; '-' stands for a chain without policy
+func PolicyString(policy Policy.Enum) string {
+	switch policy {
+	case Policy.Accept:
+		return "ACCEPT"
+	case Policy.Drop:
+		return "DROP"
+	}
+	return "-"
 }
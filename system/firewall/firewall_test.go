@@ -0,0 +1,169 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package firewall_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/CS-SI/SafeScale/system/firewall"
+	"github.com/CS-SI/SafeScale/system/firewall/Action"
+	"github.com/CS-SI/SafeScale/system/firewall/Policy"
+)
+
+const iptablesSave = `# Generated by iptables-save v1.6.1 on Mon Oct  1 10:00:00 2018
+*nat
+:PREROUTING ACCEPT [0:0]
+:INPUT ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:POSTROUTING ACCEPT [0:0]
+-A POSTROUTING -s 192.168.0.0/24 -o ens3 -j MASQUERADE
+COMMIT
+# Completed on Mon Oct  1 10:00:00 2018
+*filter
+:INPUT DROP [0:0]
+:FORWARD ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:SAFESCALE - [0:0]
+-A INPUT -i lo -j ACCEPT
+-A INPUT -p tcp -m tcp --dport 22 -j ACCEPT
+-A INPUT -s 10.0.0.1/32 -p udp -m udp --sport 53 -j ACCEPT
+-A INPUT -m comment --comment "allow \"web\" traffic" -p tcp -m tcp --dport 80 -j ACCEPT
+-A INPUT -j SAFESCALE
+COMMIT
+`
+
+func TestParseRule(t *testing.T) {
+	rule, err := firewall.ParseRule("-p tcp -m tcp --dport 22 -j ACCEPT")
+	assert.Nil(t, err)
+	assert.Equal(t, firewall.Rule{Action: Action.Accept, Protocol: "tcp", ToPort: "22"}, rule)
+
+	rule, err = firewall.ParseRule("-i lo -j ACCEPT")
+	assert.Nil(t, err)
+	assert.Equal(t, "-i lo -j ACCEPT", rule.Spec)
+
+	rule, err = firewall.ParseRule("! -s 10.0.0.0/8 -j DROP")
+	assert.Nil(t, err)
+	assert.Equal(t, "! -s 10.0.0.0/8 -j DROP", rule.Spec)
+
+	_, err = firewall.ParseRule(`-m comment --comment "unterminated -j ACCEPT`)
+	assert.NotNil(t, err)
+
+	rule = firewall.Rule{Action: Action.Drop, Protocol: "tcp", From: "10.0.0.1", ToPort: "8080"}
+	assert.Equal(t, "-s 10.0.0.1/32 -p tcp -m tcp --dport 8080 -j DROP", rule.String())
+	assert.Nil(t, rule.Validate())
+	assert.NotNil(t, firewall.Rule{Action: Action.Accept, ToPort: "22"}.Validate())
+}
+
+func TestRule_Validate(t *testing.T) {
+	valid := []firewall.Rule{
+		{Action: Action.Accept, Protocol: "tcp", From: "10.0.0.0/8", To: "192.168.1.5", ToPort: "22"},
+		{Action: Action.Accept, Protocol: "udp", From: "fe80::1", FromPort: "1024:65535"},
+		{Action: Action.Drop, Protocol: "icmp"},
+		{Spec: "-i lo -j ACCEPT"},
+		{Spec: `-m comment --comment "allow \"web\" traffic" -p tcp -m tcp --dport 80 -j ACCEPT`},
+	}
+	for _, rule := range valid {
+		assert.Nil(t, rule.Validate(), rule.String())
+	}
+
+	invalid := []firewall.Rule{
+		{Action: Action.Accept, Protocol: "tcp\nCOMMIT"},
+		{Action: Action.Accept, Protocol: "foo"},
+		{Action: Action.Accept, From: "10.0.0.1 -j DROP"},
+		{Action: Action.Accept, To: "example.com"},
+		{Action: Action.Accept, Protocol: "tcp", ToPort: "22 -j DROP"},
+		{Action: Action.Accept, Protocol: "tcp", ToPort: "70000"},
+		{Action: Action.Accept, Protocol: "tcp", ToPort: "90:80"},
+		{Action: Action.Accept, Protocol: "tcp", FromPort: "+80"},
+		{Action: Action.Accept, Protocol: "tcp", FromPort: "1:2:3"},
+		{Spec: "-j ACCEPT\nCOMMIT\n*nat"},
+		{Spec: "-j ACCEPT\r"},
+		{Spec: "-j ACCEPT -A OUTPUT -j DROP"},
+		{Spec: "-t nat -j MASQUERADE"},
+		{Spec: "--new-chain OTHER"},
+		{Spec: `-m comment --comment "unterminated`},
+	}
+	for _, rule := range invalid {
+		assert.NotNil(t, rule.Validate(), rule.String())
+	}
+}
+
+func TestParse(t *testing.T) {
+	fw := firewall.NewFirewall(nil)
+	err := fw.Parse(iptablesSave)
+	assert.Nil(t, err)
+
+	filter, err := fw.Table("filter")
+	assert.Nil(t, err)
+	input, err := filter.Chain("INPUT")
+	assert.Nil(t, err)
+	assert.Equal(t, Policy.Drop, input.Policy)
+	assert.Equal(t, 5, len(input.Rules))
+	assert.Equal(t, firewall.Rule{Action: Action.Accept, Protocol: "udp", From: "10.0.0.1/32", FromPort: "53"}, input.Rules[2])
+	_, err = filter.Chain("SAFESCALE")
+	assert.Nil(t, err)
+	_, err = fw.Table("nat")
+	assert.Nil(t, err)
+
+	// Rendering the parsed configuration gives back the content of iptables-save, without comments
+	expected := `*nat
+:PREROUTING ACCEPT [0:0]
+:INPUT ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:POSTROUTING ACCEPT [0:0]
+-A POSTROUTING -s 192.168.0.0/24 -o ens3 -j MASQUERADE
+COMMIT
+*filter
+:INPUT DROP [0:0]
+:FORWARD ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:SAFESCALE - [0:0]
+-A INPUT -i lo -j ACCEPT
+-A INPUT -p tcp -m tcp --dport 22 -j ACCEPT
+-A INPUT -s 10.0.0.1/32 -p udp -m udp --sport 53 -j ACCEPT
+-A INPUT -m comment --comment "allow \"web\" traffic" -p tcp -m tcp --dport 80 -j ACCEPT
+-A INPUT -j SAFESCALE
+COMMIT
+`
+	assert.Equal(t, expected, fw.String())
+
+	assert.NotNil(t, fw.Parse("-A INPUT -j ACCEPT\n"))
+	assert.NotNil(t, fw.Parse("*filter\n-A INPUT -j ACCEPT\nCOMMIT\n"))
+	assert.NotNil(t, fw.Parse("*filter\n:INPUT ACCEPT [0:0]\n"))
+}
+
+func TestChain(t *testing.T) {
+	ssh := firewall.Rule{Action: Action.Accept, Protocol: "tcp", ToPort: "22"}
+	web := firewall.Rule{Action: Action.Accept, Protocol: "tcp", ToPort: "80"}
+	parsed, err := firewall.ParseRule("-p tcp -m tcp --dport 80 -j ACCEPT")
+	assert.Nil(t, err)
+
+	chain := &firewall.Chain{Name: "INPUT"}
+	chain.Add(web).Insert(ssh)
+	assert.Equal(t, 0, chain.Index(ssh))
+	assert.Equal(t, 1, chain.Index(parsed))
+	assert.Equal(t, -1, chain.Index(firewall.Rule{Action: Action.Drop}))
+
+	chain.Remove(parsed)
+	assert.Equal(t, []firewall.Rule{ssh}, chain.Rules)
+	chain.RemoveIndex(5)
+	assert.Equal(t, 1, len(chain.Rules))
+	chain.RemoveIndex(0)
+	assert.Equal(t, 0, len(chain.Rules))
+}
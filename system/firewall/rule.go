@@ -17,9 +17,47 @@
 package firewall
 
 import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"unicode"
+
 	"github.com/CS-SI/SafeScale/system/firewall/Action"
 )
 
+// targets contains the iptables targets corresponding to the actions of a rule
+var targets = map[Action.Enum]string{
+	Action.Accept: "ACCEPT",
+	Action.Drop:   "DROP",
+	Action.Reject: "REJECT",
+	Action.Queue:  "QUEUE",
+	Action.Return: "RETURN",
+}
+
+// protocols contains the protocols a rule can match
+var protocols = map[string]bool{
+	"all":       true,
+	"tcp":       true,
+	"udp":       true,
+	"udplite":   true,
+	"icmp":      true,
+	"icmpv6":    true,
+	"ipv6-icmp": true,
+	"esp":       true,
+	"ah":        true,
+	"sctp":      true,
+	"gre":       true,
+}
+
+// commands contains the iptables options which are not options of a rule but commands, or which change the table
+var commands = map[string]bool{
+	"-t": true, "--table": true,
+	"--append": true, "--check": true, "--delete": true, "--insert": true, "--replace": true, "--list": true,
+	"--list-rules": true, "--flush": true, "--zero": true, "--new-chain": true, "--delete-chain": true,
+	"--policy": true, "--rename-chain": true,
+}
+
 // Rule defines a firewall rule
 type Rule struct {
 	Action   Action.Enum
@@ -28,4 +66,233 @@ type Rule struct {
 	FromPort string
 	To       string
 	ToPort   string
+	// Spec contains the iptables options of a rule that cannot be described by the fields above
+	// (interfaces, states, NAT targets, ...); if set, the other fields are ignored
+	Spec string
+}
+
+// ParseAction returns the action corresponding to an iptables target (ACCEPT, DROP, ...)
+func ParseAction(target string) (Action.Enum, error) {
+	for action, t := range targets {
+		if strings.EqualFold(t, target) {
+			return action, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown action '%s'", target)
+}
+
+// ActionString returns the iptables target corresponding to action
+func ActionString(action Action.Enum) string {
+	return targets[action]
+}
+
+// ParseRule converts the iptables options of a rule (as written by iptables-save after '-A <chain>') to a Rule
+// Rules using options not handled by Rule are kept verbatim in Spec
+func ParseRule(spec string) (Rule, error) {
+	spec = strings.TrimSpace(spec)
+	tokens, err := tokenize(spec)
+	if err != nil {
+		return Rule{}, err
+	}
+	rule := Rule{}
+	module := ""
+	target := ""
+	for i := 0; i < len(tokens); i++ {
+		option := tokens[i]
+		if i+1 >= len(tokens) {
+			return Rule{Spec: spec}, nil
+		}
+		value := tokens[i+1]
+		if value == "!" {
+			return Rule{Spec: spec}, nil
+		}
+		switch option {
+		case "-p", "--protocol":
+			rule.Protocol = value
+		case "-s", "--source":
+			rule.From = value
+		case "-d", "--destination":
+			rule.To = value
+		case "-m", "--match":
+			module = value
+		case "--sport", "--source-port":
+			rule.FromPort = value
+		case "--dport", "--destination-port":
+			rule.ToPort = value
+		case "-j", "--jump":
+			target = value
+		default:
+			return Rule{Spec: spec}, nil
+		}
+		i++
+	}
+	if module != "" && module != rule.Protocol {
+		return Rule{Spec: spec}, nil
+	}
+	action, err := ParseAction(target)
+	if err != nil {
+		return Rule{Spec: spec}, nil
+	}
+	rule.Action = action
+	return rule, nil
+}
+
+// Validate checks the rule can be applied
+// As rules are written in the file given to iptables-restore, they must stay on their line and in their chain
+func (r Rule) Validate() error {
+	if r.Spec != "" {
+		return validateSpec(r.Spec)
+	}
+	if _, ok := targets[r.Action]; !ok {
+		return fmt.Errorf("invalid action")
+	}
+	if r.Protocol != "" && !protocols[r.Protocol] {
+		return fmt.Errorf("invalid protocol '%s'", r.Protocol)
+	}
+	for _, address := range []string{r.From, r.To} {
+		if address != "" && !validAddress(address) {
+			return fmt.Errorf("invalid address '%s'", address)
+		}
+	}
+	for _, port := range []string{r.FromPort, r.ToPort} {
+		if port != "" && !validPort(port) {
+			return fmt.Errorf("invalid port '%s'", port)
+		}
+	}
+	if (r.FromPort != "" || r.ToPort != "") && r.Protocol != "tcp" && r.Protocol != "udp" {
+		return fmt.Errorf("ports can only be used with protocol tcp or udp")
+	}
+	return nil
+}
+
+// validateSpec checks the iptables options of a rule contain neither control characters nor commands
+func validateSpec(spec string) error {
+	for _, c := range spec {
+		if unicode.IsControl(c) {
+			return fmt.Errorf("invalid character %q", c)
+		}
+	}
+	tokens, err := tokenize(spec)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		// The short commands of iptables are the uppercase options (-A, -I, -N, ...)
+		short := len(token) == 2 && token[0] == '-' && token[1] >= 'A' && token[1] <= 'Z'
+		if short || commands[token] {
+			return fmt.Errorf("option '%s' is not allowed in a rule", token)
+		}
+	}
+	return nil
+}
+
+// validAddress tells if address is an IP address or a network in CIDR notation
+func validAddress(address string) bool {
+	if net.ParseIP(address) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(address)
+	return err == nil
+}
+
+// validPort tells if port is a port number or a range of ports 'first:last'
+func validPort(port string) bool {
+	bounds := strings.Split(port, ":")
+	if len(bounds) > 2 {
+		return false
+	}
+	var numbers []int
+	for _, bound := range bounds {
+		if bound == "" || strings.TrimLeft(bound, "0123456789") != "" {
+			return false
+		}
+		n, err := strconv.Atoi(bound)
+		if err != nil || n > 65535 {
+			return false
+		}
+		numbers = append(numbers, n)
+	}
+	return len(numbers) == 1 || numbers[0] <= numbers[1]
+}
+
+// String returns the iptables options corresponding to the rule, in the form used by iptables-save
+func (r Rule) String() string {
+	if r.Spec != "" {
+		return r.Spec
+	}
+	var parts []string
+	if r.From != "" {
+		parts = append(parts, "-s", normalizeAddress(r.From))
+	}
+	if r.To != "" {
+		parts = append(parts, "-d", normalizeAddress(r.To))
+	}
+	if r.Protocol != "" {
+		parts = append(parts, "-p", r.Protocol)
+		if r.FromPort != "" || r.ToPort != "" {
+			parts = append(parts, "-m", r.Protocol)
+		}
+	}
+	if r.FromPort != "" {
+		parts = append(parts, "--sport", r.FromPort)
+	}
+	if r.ToPort != "" {
+		parts = append(parts, "--dport", r.ToPort)
+	}
+	parts = append(parts, "-j", targets[r.Action])
+	return strings.Join(parts, " ")
+}
+
+// normalizeAddress adds the netmask iptables adds to a single IP address
+func normalizeAddress(address string) string {
+	if strings.Contains(address, "/") {
+		return address
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return address
+	}
+	if ip.To4() != nil {
+		return address + "/32"
+	}
+	return address + "/128"
+}
+
+// tokenize splits the options of a rule, honoring the double quotes used by iptables-save around values containing spaces
+func tokenize(spec string) ([]string, error) {
+	var (
+		tokens  []string
+		current strings.Builder
+		quoted  bool
+		escaped bool
+		pending bool
+	)
+	for _, c := range spec {
+		switch {
+		case escaped:
+			current.WriteRune(c)
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+			pending = true
+		case c == ' ' && !quoted:
+			if pending {
+				tokens = append(tokens, current.String())
+				current.Reset()
+				pending = false
+			}
+		default:
+			current.WriteRune(c)
+			pending = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in rule '%s'", spec)
+	}
+	if pending {
+		tokens = append(tokens, current.String())
+	}
+	return tokens, nil
 }
@@ -18,6 +18,7 @@ package firewall
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/CS-SI/SafeScale/system/firewall/Policy"
@@ -36,6 +37,9 @@ func (t *Table) NewChain(name string, policy Policy.Enum) *Chain {
 		Name:   name,
 		Policy: policy,
 	}
+	if t.Chains == nil {
+		t.Chains = map[string]*Chain{}
+	}
 	t.Chains[name] = &chain
 	return &chain
 }
@@ -52,3 +56,25 @@ func (t *Table) Chain(name string) (*Chain, error) {
 	}
 	return chain, nil
 }
+
+// builtinChains lists the chains created by iptables, in the order used by iptables-save
+var builtinChains = []string{"PREROUTING", "INPUT", "FORWARD", "OUTPUT", "POSTROUTING"}
+
+// ChainNames returns the names of the chains of the table, built-in chains first
+func (t *Table) ChainNames() []string {
+	var names, others []string
+	builtin := map[string]bool{}
+	for _, name := range builtinChains {
+		builtin[name] = true
+		if _, ok := t.Chains[name]; ok {
+			names = append(names, name)
+		}
+	}
+	for name := range t.Chains {
+		if !builtin[name] {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...)
+}
@@ -0,0 +1,203 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package system
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	// KnownHostsFile is the file pinning the host keys of the hosts whose key is not known by SafeScale
+	// (SSHConfig.HostKey not set)
+	// The key presented by a host the first time is recorded; a different key presented later is rejected
+	// If empty (HOME not set), the connections to these hosts are refused
+	KnownHostsFile = defaultKnownHostsFile()
+
+	knownHostsLock sync.Mutex
+)
+
+// defaultKnownHostsFile returns $HOME/.safescale/known_hosts
+func defaultKnownHostsFile() string {
+	home := os.Getenv("HOME")
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".safescale", "known_hosts")
+}
+
+// HostKeyMismatchError is returned when a host presents another key than the one pinned for it
+type HostKeyMismatchError struct {
+	HostID string
+	Key    ssh.PublicKey
+	// PinnedIn tells where the expected key is pinned
+	PinnedIn string
+}
+
+// Error returns the message of the error
+func (e *HostKeyMismatchError) Error() string {
+	return fmt.Sprintf("host key of '%s' has changed (%s %s), possible man-in-the-middle attack; the expected key is pinned in %s",
+		e.HostID, e.Key.Type(), ssh.FingerprintSHA256(e.Key), e.PinnedIn)
+}
+
+// hostKeyCallback returns the callback checking the key presented by the host described by cfg: the key must be
+// cfg.HostKey if set, else the one pinned in KnownHostsFile
+func hostKeyCallback(cfg *SSHConfig) (ssh.HostKeyCallback, error) {
+	hostID := cfg.hostID()
+	if cfg.HostKey == "" {
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return checkHostKey(hostID, key)
+		}, nil
+	}
+	pinned, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cfg.HostKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid host key of '%s': %s", cfg.Host, err.Error())
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if !bytes.Equal(pinned.Marshal(), key.Marshal()) {
+			return &HostKeyMismatchError{HostID: hostID, Key: key, PinnedIn: "the metadata of the host"}
+		}
+		return nil
+	}, nil
+}
+
+// HostKeyFingerprint returns the SHA256 fingerprint of a key in authorized_keys format, as displayed by ssh
+func HostKeyFingerprint(key string) (string, error) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		return "", fmt.Errorf("invalid host key: %s", err.Error())
+	}
+	return ssh.FingerprintSHA256(pub), nil
+}
+
+// checkHostKey checks key is the one pinned for hostID, pinning it if hostID is not known yet
+func checkHostKey(hostID string, key ssh.PublicKey) error {
+	if KnownHostsFile == "" {
+		return fmt.Errorf("no known hosts file to check the host key of '%s' against, HOME is not set", hostID)
+	}
+
+	knownHostsLock.Lock()
+	defer knownHostsLock.Unlock()
+
+	known, err := readKnownHosts()
+	if err != nil {
+		return err
+	}
+	if keys, ok := known[hostID]; ok {
+		for _, k := range keys {
+			if bytes.Equal(k.Marshal(), key.Marshal()) {
+				return nil
+			}
+		}
+		return &HostKeyMismatchError{HostID: hostID, Key: key, PinnedIn: fmt.Sprintf("'%s'", KnownHostsFile)}
+	}
+	return appendKnownHost(hostID, key)
+}
+
+// readKnownHosts returns the keys pinned in KnownHostsFile, indexed by host ID
+func readKnownHosts() (map[string][]ssh.PublicKey, error) {
+	known := map[string][]ssh.PublicKey{}
+	f, err := os.Open(KnownHostsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return known, nil
+		}
+		return nil, fmt.Errorf("failed to read known hosts: %s", err.Error())
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		_, hosts, key, _, _, err := ssh.ParseKnownHosts([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("invalid line %d of '%s': %s", lineNum, KnownHostsFile, err.Error())
+		}
+		for _, h := range hosts {
+			known[h] = append(known[h], key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read known hosts: %s", err.Error())
+	}
+	return known, nil
+}
+
+// appendKnownHost pins key for hostID in KnownHostsFile
+func appendKnownHost(hostID string, key ssh.PublicKey) error {
+	err := os.MkdirAll(filepath.Dir(KnownHostsFile), 0700)
+	if err != nil {
+		return fmt.Errorf("failed to create known hosts: %s", err.Error())
+	}
+	f, err := os.OpenFile(KnownHostsFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create known hosts: %s", err.Error())
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%s %s", hostID, ssh.MarshalAuthorizedKey(key))
+	if err != nil {
+		return fmt.Errorf("failed to write known hosts: %s", err.Error())
+	}
+	return nil
+}
+
+// ForgetHostKey removes from KnownHostsFile the key pinned for the host described by cfg, and the ones of the hosts
+// reached through it; to be called when the host is deleted, as a new host may get the same address
+func ForgetHostKey(cfg *SSHConfig) error {
+	if KnownHostsFile == "" || cfg == nil {
+		return nil
+	}
+
+	knownHostsLock.Lock()
+	defer knownHostsLock.Unlock()
+
+	content, err := ioutil.ReadFile(KnownHostsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read known hosts: %s", err.Error())
+	}
+	hostID := cfg.hostID()
+	var kept []string
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		id := strings.SplitN(strings.TrimSpace(line), " ", 2)[0]
+		if id == hostID || strings.HasPrefix(id, hostID+"/") {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	err = ioutil.WriteFile(KnownHostsFile, []byte(strings.Join(kept, "")), 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write known hosts: %s", err.Error())
+	}
+	return nil
+}
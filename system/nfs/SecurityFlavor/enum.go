@@ -16,6 +16,11 @@
 
 package SecurityFlavor
 
+import (
+	"fmt"
+	"strings"
+)
+
 //go:generate stringer -type=Enum
 
 //Enum represents the state of a node
@@ -31,3 +36,19 @@ const (
 	//Krb5p indicates Kerberos5 with privacy protection
 	Krb5p
 )
+
+var stringMap = map[string]Enum{
+	"sys":   Sys,
+	"krb5":  Krb5,
+	"krb5i": Krb5i,
+	"krb5p": Krb5p,
+}
+
+//Parse returns the Enum corresponding to the name of a security flavor used by NFS ('sys', 'krb5', 'krb5i', 'krb5p')
+func Parse(v string) (Enum, error) {
+	e, ok := stringMap[strings.ToLower(strings.TrimSpace(v))]
+	if !ok {
+		return e, fmt.Errorf("failed to find a SecurityFlavor.Enum corresponding to '%s'", v)
+	}
+	return e, nil
+}
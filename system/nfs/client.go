@@ -18,8 +18,10 @@ package nfs
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/CS-SI/SafeScale/system"
+	"github.com/CS-SI/SafeScale/system/nfs/SecurityFlavor"
 )
 
 //Client defines the structure of a Client object
@@ -45,12 +47,25 @@ func (c *Client) Install() error {
 	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to install NFS client")
 }
 
+//MountOptions contains the options of the mount of a remote share
+type MountOptions struct {
+	//ReadOnly mounts the share read-only
+	ReadOnly bool
+	//SecurityMode is the security flavor used to access the share
+	SecurityMode SecurityFlavor.Enum
+}
+
 //Mount defines a mount of a remote share and mount it
-func (c *Client) Mount(host string, share string, mountPoint string) error {
+func (c *Client) Mount(host string, share string, mountPoint string, options MountOptions) error {
+	mountOptions := "noac,sec=" + strings.ToLower(options.SecurityMode.String())
+	if options.ReadOnly {
+		mountOptions += ",ro"
+	}
 	data := map[string]interface{}{
 		"Host":       host,
 		"Share":      share,
 		"MountPoint": mountPoint,
+		"Options":    mountOptions,
 	}
 	retcode, stdout, stderr, err := executeScript(*c.SshConfig, "nfs_client_share_mount.sh", data)
 	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to mount remote NFS share")
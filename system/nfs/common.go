@@ -20,8 +20,6 @@ import (
 	"bytes"
 	"fmt"
 	"os"
-	"os/exec"
-	"syscall"
 	"text/template"
 	"time"
 
@@ -112,10 +110,8 @@ func executeScript(sshconfig system.SSHConfig, name string, data map[string]inte
 			stderr = ""
 			retcode = 0
 			if err != nil {
-				if ee, ok := err.(*exec.ExitError); ok {
-					if status, ok := ee.Sys().(syscall.WaitStatus); ok {
-						retcode = status.ExitStatus()
-					}
+				if ee, ok := err.(*system.SSHExitError); ok {
+					retcode = ee.ExitStatus()
 					stderr = string(ee.Stderr)
 				}
 			}
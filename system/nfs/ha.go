@@ -0,0 +1,239 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nfs
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/CS-SI/SafeScale/system"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HAConfig describes how a NFS server replicates its storage with a peer, and the virtual IP address held
+// by the server currently active
+type HAConfig struct {
+	// Resource names the DRBD resource and the keepalived instance
+	Resource string
+	// ID identifies the replicated storage among the others of the network, from 1 to 255: it is the VRRP router ID
+	// of the keepalived instance, the minor of the DRBD device and the offset of the port of the replication
+	ID int
+	// LocalIP and PeerIP are the private IP addresses of the server and of its peer
+	LocalIP, PeerIP string
+	// LocalDevice and PeerDevice are the block devices replicated on the server and on its peer
+	LocalDevice, PeerDevice string
+	// VirtualIP is the address held by the active server, in CIDR notation (ex: 192.168.0.250/24)
+	VirtualIP string
+	// Path is where the replicated storage is mounted on the active server
+	Path string
+	// Primary tells if the server is the active one at start
+	Primary bool
+	// Fencing describes how the server outdates the storage of its peer; required to install the server
+	Fencing *HAFencing
+}
+
+// HAFencing describes how a server outdates the storage of its peer when they lose each other: through SSH, from the
+// gateway of the network, with a key pair shared by both servers and only allowed to run the outdate script
+type HAFencing struct {
+	// PrivateKey and PublicKey are the key pair of the servers, PublicKey in authorized_keys format
+	PrivateKey, PublicKey string
+	// Peer is the user, port and host key of the SSH server of the peer, reached by its private IP address
+	Peer system.SSHConfig
+	// Gateway is the user, private IP address, port and host key of the SSH server of the gateway
+	Gateway system.SSHConfig
+}
+
+// data returns the variables of the fencing used by the installation script
+func (f *HAFencing) data() (map[string]interface{}, error) {
+	knownHosts := []string{}
+	for _, cfg := range []system.SSHConfig{f.Peer, f.Gateway} {
+		if cfg.HostKey == "" {
+			return nil, fmt.Errorf("host key of '%s' is unknown, the fencing couldn't check its identity", cfg.Host)
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cfg.HostKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid host key of '%s': %s", cfg.Host, err.Error())
+		}
+		knownHosts = append(knownHosts, knownhosts.Line([]string{knownhosts.Normalize(fmt.Sprintf("%s:%d", cfg.Host, port(cfg)))}, key))
+	}
+	return map[string]interface{}{
+		"FencingPrivateKey":  strings.TrimSpace(f.PrivateKey),
+		"FencingPublicKey":   strings.TrimSpace(f.PublicKey),
+		"FencingKnownHosts":  strings.Join(knownHosts, "\n"),
+		"FencingPeerUser":    f.Peer.User,
+		"FencingPeerPort":    port(f.Peer),
+		"FencingGatewayUser": f.Gateway.User,
+		"FencingGatewayIP":   f.Gateway.Host,
+		"FencingGatewayPort": port(f.Gateway),
+	}, nil
+}
+
+// port returns the port of the SSH server, 22 by default
+func port(cfg system.SSHConfig) int {
+	if cfg.Port == 0 {
+		return 22
+	}
+	return cfg.Port
+}
+
+func (c HAConfig) data() (map[string]interface{}, error) {
+	ip, _, err := net.ParseCIDR(c.VirtualIP)
+	if err != nil {
+		return nil, fmt.Errorf("invalid virtual IP '%s': %s", c.VirtualIP, err.Error())
+	}
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return nil, fmt.Errorf("invalid virtual IP '%s': not an IPv4 address", c.VirtualIP)
+	}
+	if c.ID < 1 || c.ID > 255 {
+		return nil, fmt.Errorf("invalid ID %d of replicated storage '%s': VRRP router IDs range from 1 to 255", c.ID, c.Resource)
+	}
+	priority := 100
+	if c.Primary {
+		priority = 150
+	}
+	return map[string]interface{}{
+		"Resource":    c.Resource,
+		"LocalIP":     c.LocalIP,
+		"PeerIP":      c.PeerIP,
+		"LocalDevice": c.LocalDevice,
+		"PeerDevice":  c.PeerDevice,
+		"VirtualIP":   c.VirtualIP,
+		"VirtualIPv4": ip4.String(),
+		"RouterID":    c.ID,
+		// DRBD replicates on a port of its own for each resource
+		"Port":     7700 + c.ID,
+		"Priority": priority,
+		"Path":     c.Path,
+		"Primary":  c.Primary,
+	}, nil
+}
+
+// InstallHA installs DRBD and keepalived, and configures the replication of the storage and the failover with
+// the peer
+// InstallHA has to be called on both servers before StartHA, once the gateway allows the fencing (AllowHAFencing)
+func (s *Server) InstallHA(config HAConfig) error {
+	if config.Fencing == nil {
+		return fmt.Errorf("no fencing configured for replicated storage '%s'", config.Resource)
+	}
+	data, err := config.data()
+	if err != nil {
+		return err
+	}
+	fencing, err := config.Fencing.data()
+	if err != nil {
+		return err
+	}
+	for k, v := range fencing {
+		data[k] = v
+	}
+	data["User"] = s.SshConfig.User
+	retcode, stdout, stderr, err := executeScript(*s.SshConfig, "nfs_ha_install.sh", data)
+	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to install NFS high availability")
+}
+
+// StartHA starts the failover service; the primary server also formats and mounts the replicated storage
+func (s *Server) StartHA(config HAConfig) error {
+	data, err := config.data()
+	if err != nil {
+		return err
+	}
+	retcode, stdout, stderr, err := executeScript(*s.SshConfig, "nfs_ha_start.sh", data)
+	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to start NFS high availability")
+}
+
+// AddHAShare exports the replicated storage with the ACLs given, under the same FSID on both servers
+func (s *Server) AddHAShare(config HAConfig, acls []ExportAcl) error {
+	err := validateAcls(acls)
+	if err != nil {
+		return err
+	}
+	data, err := config.data()
+	if err != nil {
+		return err
+	}
+	data["AccessRights"] = exportsLine(acls)
+	data["FSID"] = 1000 + data["RouterID"].(int)
+	retcode, stdout, stderr, err := executeScript(*s.SshConfig, "nfs_server_path_export.sh", data)
+	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to export a shared directory")
+}
+
+// IsActive tells if the server holds the virtual IP address of the HA configuration
+func (s *Server) IsActive(config HAConfig) (bool, error) {
+	data, err := config.data()
+	if err != nil {
+		return false, err
+	}
+	retcode, stdout, stderr, err := executeScript(*s.SshConfig, "nfs_ha_status.sh", data)
+	err = handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to get NFS high availability status")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(stdout) == "active", nil
+}
+
+// Failover makes the active server release the virtual IP address and the replicated storage to its peer,
+// then stay as backup
+func (s *Server) Failover(config HAConfig) error {
+	data, err := config.data()
+	if err != nil {
+		return err
+	}
+	retcode, stdout, stderr, err := executeScript(*s.SshConfig, "nfs_ha_failover.sh", data)
+	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to fail over NFS server")
+}
+
+// UninstallHA stops the failover service and the replication, and removes their configuration
+func (s *Server) UninstallHA(config HAConfig) error {
+	data, err := config.data()
+	if err != nil {
+		return err
+	}
+	data["User"] = s.SshConfig.User
+	retcode, stdout, stderr, err := executeScript(*s.SshConfig, "nfs_ha_uninstall.sh", data)
+	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to uninstall NFS high availability")
+}
+
+// AllowHAFencing allows the fencing key of the servers of a replicated storage to go through the gateway to the SSH
+// servers of the servers, and nowhere else
+func AllowHAFencing(gateway *system.SSHConfig, resource, publicKey string, servers []system.SSHConfig) error {
+	permitOpen := []string{}
+	for _, cfg := range servers {
+		permitOpen = append(permitOpen, fmt.Sprintf("permitopen=\"%s:%d\"", cfg.Host, port(cfg)))
+	}
+	data := map[string]interface{}{
+		"User":       gateway.User,
+		"Resource":   resource,
+		"PublicKey":  strings.TrimSpace(publicKey),
+		"PermitOpen": strings.Join(permitOpen, ","),
+	}
+	retcode, stdout, stderr, err := executeScript(*gateway, "nfs_ha_gateway_allow.sh", data)
+	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to allow fencing through the gateway")
+}
+
+// RevokeHAFencing removes from the gateway the fencing key of the servers of a replicated storage
+func RevokeHAFencing(gateway *system.SSHConfig, resource string) error {
+	data := map[string]interface{}{
+		"User":     gateway.User,
+		"Resource": resource,
+	}
+	retcode, stdout, stderr, err := executeScript(*gateway, "nfs_ha_gateway_revoke.sh", data)
+	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to revoke fencing through the gateway")
+}
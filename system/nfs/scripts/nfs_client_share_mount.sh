@@ -19,5 +19,5 @@
 # Declares a remote share mount and mount it
 
 mkdir -p "{{.MountPoint}}" && \
-mount -o noac "{{.Host}}:{{.Share}}" "{{.MountPoint}}" && \
-echo "{{.Host}}:{{.Share}} {{.MountPoint}}   nfs defaults,user,auto,noatime,intr,noac 0   0" >>/etc/fstab
+mount -o {{.Options}} "{{.Host}}:{{.Share}}" "{{.MountPoint}}" && \
+echo "{{.Host}}:{{.Share}} {{.MountPoint}}   nfs defaults,user,auto,noatime,intr,{{.Options}} 0   0" >>/etc/fstab
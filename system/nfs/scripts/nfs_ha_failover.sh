@@ -0,0 +1,34 @@
+#!/usr/bin/env bash
+#
+# Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+# nfs_ha_failover.sh
+#
+# Makes the active server hand the virtual IP address and the replicated storage over to its peer.
+# nopreempt keeps the server as backup once keepalived is restarted
+
+systemctl stop keepalived
+# keepalived doesn't notify when stopped, so releases the storage itself
+/etc/keepalived/safescale/{{.Resource}}-notify.sh INSTANCE {{.Resource}} BACKUP
+sleep 5
+systemctl start keepalived || exit 1
+
+# Waits for the peer to take over
+for i in $(seq 30); do
+    drbdadm role {{.Resource}} | grep -q "^Secondary/Primary" && exit 0
+    sleep 2
+done
+echo "Peer {{.PeerIP}} didn't take over"
+exit 1
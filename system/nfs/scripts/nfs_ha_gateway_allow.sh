@@ -0,0 +1,29 @@
+#!/usr/bin/env bash
+#
+# Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+# nfs_ha_gateway_allow.sh
+#
+# Allows the fencing key of the servers of a highly available NFS server to go through the gateway, to the SSH
+# servers of the servers only
+
+HOMEDIR=$(getent passwd {{.User}} | cut -d: -f6)
+[ -z "$HOMEDIR" ] && echo "Failed to find the home directory of user '{{.User}}'" && exit 1
+mkdir -p $HOMEDIR/.ssh
+sed -i '/ safescale-fence-{{.Resource}}$/d' $HOMEDIR/.ssh/authorized_keys 2>/dev/null
+echo 'command="/bin/false",no-pty,no-agent-forwarding,no-X11-forwarding,{{.PermitOpen}} {{.PublicKey}} safescale-fence-{{.Resource}}' >>$HOMEDIR/.ssh/authorized_keys
+chown {{.User}} $HOMEDIR/.ssh/authorized_keys
+chmod 0600 $HOMEDIR/.ssh/authorized_keys
+exit 0
@@ -0,0 +1,23 @@
+#!/usr/bin/env bash
+#
+# Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+# nfs_ha_gateway_revoke.sh
+#
+# Removes from the gateway the fencing key of the servers of a highly available NFS server
+
+HOMEDIR=$(getent passwd {{.User}} | cut -d: -f6)
+[ -n "$HOMEDIR" ] && sed -i '/ safescale-fence-{{.Resource}}$/d' $HOMEDIR/.ssh/authorized_keys 2>/dev/null
+exit 0
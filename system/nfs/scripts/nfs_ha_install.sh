@@ -0,0 +1,204 @@
+#!/usr/bin/env bash
+#
+# Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+# nfs_ha_install.sh
+#
+# Installs DRBD and keepalived, then configures the replication of a block device with a peer
+# and the failover of a virtual IP address between both servers
+
+{{.reserved_BashLibrary}}
+
+echo "Install NFS high availability"
+
+case $LINUX_KIND in
+    debian|ubuntu)
+        export DEBIAN_FRONTEND=noninteractive
+        sfWaitForApt && apt-get update && sfWaitForApt && apt-get install -qqy drbd-utils keepalived || exit 1
+        ;;
+
+    rhel|centos)
+        yum install -y https://www.elrepo.org/elrepo-release-7.el7.elrepo.noarch.rpm && \
+        yum install -y drbd84-utils kmod-drbd84 keepalived || exit 1
+        ;;
+
+    *)
+        echo "Unsupported operating system '$LINUX_KIND'"
+        exit 1
+        ;;
+esac
+modprobe drbd || exit 1
+
+INTERFACE=$(ip -o route get {{.PeerIP}} | sed -n 's/.* dev \([^ ]*\).*/\1/p')
+[ -z "$INTERFACE" ] && echo "Failed to find the interface reaching {{.PeerIP}}" && exit 1
+# The gateway of the network arbitrates between the servers when they lose each other: only a server reaching it
+# may hold the storage
+GATEWAY=$(ip route show default | awk '/^default/ { print $3; exit }')
+[ -z "$GATEWAY" ] && echo "Failed to find the default gateway" && exit 1
+
+mkdir -p /etc/keepalived/safescale
+cat >/etc/keepalived/safescale/{{.Resource}}-check.sh <<-EOF2
+#!/bin/bash
+# Fails while the server refuses to hold the storage after a failed promotion, until the replication is restored
+if [ -f /etc/keepalived/safescale/{{.Resource}}.refused ]; then
+    [ "\$(drbdadm cstate {{.Resource}})" = "Connected" ] || exit 1
+    rm -f /etc/keepalived/safescale/{{.Resource}}.refused
+fi
+# Succeeds if the server reaches the gateway of the network
+ping -c 1 -W 1 $GATEWAY >/dev/null 2>&1
+EOF2
+chmod u+rx /etc/keepalived/safescale/{{.Resource}}-check.sh
+
+# Fencing: each server may outdate the storage of the other through SSH, along the gateway of the network, with a key
+# only allowed to run the outdate script
+cat >/etc/keepalived/safescale/{{.Resource}}-fence.key <<-'EOF2'
+{{.FencingPrivateKey}}
+EOF2
+chmod 0600 /etc/keepalived/safescale/{{.Resource}}-fence.key
+cat >/etc/keepalived/safescale/{{.Resource}}-fence.known_hosts <<-'EOF2'
+{{.FencingKnownHosts}}
+EOF2
+
+# Run by the peer to fence the server: outdates its storage, unless it holds it
+cat >/etc/keepalived/safescale/{{.Resource}}-outdate.sh <<-'EOF2'
+#!/bin/bash
+drbdadm role {{.Resource}} | grep -q "^Primary" && exit 6
+drbdadm outdate {{.Resource}} && exit 4
+exit 1
+EOF2
+chmod u+rx /etc/keepalived/safescale/{{.Resource}}-outdate.sh
+HOMEDIR=$(getent passwd {{.User}} | cut -d: -f6)
+[ -z "$HOMEDIR" ] && echo "Failed to find the home directory of user '{{.User}}'" && exit 1
+mkdir -p $HOMEDIR/.ssh
+sed -i '/ safescale-fence-{{.Resource}}$/d' $HOMEDIR/.ssh/authorized_keys 2>/dev/null
+echo 'command="sudo -n /etc/keepalived/safescale/{{.Resource}}-outdate.sh",no-pty,no-port-forwarding,no-agent-forwarding,no-X11-forwarding {{.FencingPublicKey}} safescale-fence-{{.Resource}}' >>$HOMEDIR/.ssh/authorized_keys
+chown {{.User}} $HOMEDIR/.ssh/authorized_keys
+chmod 0600 $HOMEDIR/.ssh/authorized_keys
+
+# DRBD fence-peer handler, called when the server loses its peer, as primary or to become primary. The storage of the
+# peer is outdated (exit 4); a peer holding the storage makes the server outdate its own (exit 6). A peer unreachable
+# from the gateway is considered dead (exit 5): alive, it would lose the gateway too and release the storage. A server
+# not reaching the gateway refuses to hold the storage (exit 1)
+cat >/etc/keepalived/safescale/{{.Resource}}-fence-peer.sh <<-'EOF2'
+#!/bin/bash
+DIR=/etc/keepalived/safescale
+SSH="ssh -F /dev/null -i $DIR/{{.Resource}}-fence.key -o BatchMode=yes -o ConnectTimeout=5 -o StrictHostKeyChecking=yes -o UserKnownHostsFile=$DIR/{{.Resource}}-fence.known_hosts"
+$SSH -o ProxyCommand="$SSH -W %h:%p -p {{.FencingGatewayPort}} {{.FencingGatewayUser}}@{{.FencingGatewayIP}}" -p {{.FencingPeerPort}} {{.FencingPeerUser}}@{{.PeerIP}}
+rc=$?
+case $rc in
+    4|6)
+        exit $rc
+        ;;
+    255)
+        $DIR/{{.Resource}}-check.sh && exit 5
+        ;;
+esac
+exit 1
+EOF2
+chmod u+rx /etc/keepalived/safescale/{{.Resource}}-fence-peer.sh
+
+# DRBD resource, addressed by IP so the peer name doesn't have to be resolved
+cat >/etc/drbd.d/{{.Resource}}.res <<-EOF2
+resource {{.Resource}} {
+    protocol C;
+    device /dev/drbd{{.RouterID}};
+    meta-disk internal;
+    disk {
+        fencing resource-only;
+    }
+    handlers {
+        fence-peer "/etc/keepalived/safescale/{{.Resource}}-fence-peer.sh";
+    }
+    net {
+        after-sb-0pri discard-zero-changes;
+        after-sb-1pri discard-secondary;
+        after-sb-2pri disconnect;
+    }
+    floating {{.LocalIP}}:{{.Port}} {
+        disk {{.LocalDevice}};
+    }
+    floating {{.PeerIP}}:{{.Port}} {
+        disk {{.PeerDevice}};
+    }
+}
+EOF2
+# Metadata are only created on a device without them, a reinstallation keeps the replicated data
+if ! drbdadm dstate {{.Resource}} >/dev/null 2>&1; then
+    drbdadm dump-md {{.Resource}} >/dev/null 2>&1 || drbdadm create-md --force {{.Resource}} || exit 1
+    drbdadm up {{.Resource}} || exit 1
+fi
+
+# keepalived instance; nopreempt keeps the active server active after its peer restarts, and a server losing the
+# gateway goes in fault, releasing the virtual IP and the storage
+grep -q "^include /etc/keepalived/safescale/" /etc/keepalived/keepalived.conf 2>/dev/null || \
+    echo "include /etc/keepalived/safescale/*.conf" >>/etc/keepalived/keepalived.conf
+
+cat >/etc/keepalived/safescale/{{.Resource}}-notify.sh <<-'EOF2'
+#!/bin/bash
+# Called by keepalived with the type and the name of the instance, then its new state
+case "$3" in
+    MASTER)
+        promoted=no
+        for i in $(seq 30); do
+            drbdadm primary {{.Resource}} && promoted=yes && break
+            sleep 2
+        done
+        # A server which can't hold the storage goes in fault, releasing the virtual IP
+        if [ "$promoted" != "yes" ]; then
+            touch /etc/keepalived/safescale/{{.Resource}}.refused
+            exit 1
+        fi
+        mount /dev/drbd{{.RouterID}} {{.Path}} || exit 1
+        exportfs -ra
+        systemctl restart nfs-kernel-server 2>/dev/null || systemctl restart nfs-server
+        ;;
+    BACKUP|FAULT)
+        exportfs -ua
+        umount {{.Path}} 2>/dev/null
+        drbdadm secondary {{.Resource}}
+        ;;
+esac
+exit 0
+EOF2
+chmod u+rx /etc/keepalived/safescale/{{.Resource}}-notify.sh
+
+cat >/etc/keepalived/safescale/{{.Resource}}.conf <<-EOF2
+vrrp_script {{.Resource}}_gateway {
+    script "/etc/keepalived/safescale/{{.Resource}}-check.sh"
+    interval 2
+    fall 3
+    rise 2
+}
+vrrp_instance {{.Resource}} {
+    state BACKUP
+    nopreempt
+    interface $INTERFACE
+    virtual_router_id {{.RouterID}}
+    priority {{.Priority}}
+    advert_int 1
+    unicast_src_ip {{.LocalIP}}
+    unicast_peer {
+        {{.PeerIP}}
+    }
+    virtual_ipaddress {
+        {{.VirtualIP}} dev $INTERFACE
+    }
+    track_script {
+        {{.Resource}}_gateway
+    }
+    notify /etc/keepalived/safescale/{{.Resource}}-notify.sh
+}
+EOF2
+mkdir -p {{.Path}}
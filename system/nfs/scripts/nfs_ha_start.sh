@@ -0,0 +1,42 @@
+#!/usr/bin/env bash
+#
+# Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+# nfs_ha_start.sh
+#
+# Starts the failover service of a NFS server; the first time, the primary server formats and mounts the replicated
+# storage
+
+{{.reserved_BashLibrary}}
+
+{{ if .Primary }}
+# The state of the storage of the peer is known once connected to it
+for i in $(seq 30); do
+    [ "$(drbdadm cstate {{.Resource}})" = "Connected" ] && break
+    sleep 2
+done
+# Only a new resource, never synchronized, is forced primary and formatted; afterwards the storage holds data, and
+# keepalived promotes the server holding the virtual IP
+if [ "$(drbdadm dstate {{.Resource}})" = "Inconsistent/Inconsistent" ]; then
+    drbdadm primary --force {{.Resource}} || exit 1
+    # Waits for the initial synchronization to begin before formatting
+    sleep 5
+    if ! blkid /dev/drbd{{.RouterID}} >/dev/null 2>&1; then
+        mkfs.ext4 /dev/drbd{{.RouterID}} || exit 1
+    fi
+    mount /dev/drbd{{.RouterID}} {{.Path}} && chmod a+rwx {{.Path}} || exit 1
+fi
+{{ end }}
+systemctl enable keepalived && systemctl restart keepalived
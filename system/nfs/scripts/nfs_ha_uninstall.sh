@@ -0,0 +1,34 @@
+#!/usr/bin/env bash
+#
+# Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+# nfs_ha_uninstall.sh
+#
+# Stops the failover service and the replication of a NFS server, and removes their configuration
+
+systemctl stop keepalived
+rm -f /etc/keepalived/safescale/{{.Resource}}.conf /etc/keepalived/safescale/{{.Resource}}-*.sh \
+      /etc/keepalived/safescale/{{.Resource}}-fence.* /etc/keepalived/safescale/{{.Resource}}.refused
+HOMEDIR=$(getent passwd {{.User}} | cut -d: -f6)
+[ -n "$HOMEDIR" ] && sed -i '/ safescale-fence-{{.Resource}}$/d' $HOMEDIR/.ssh/authorized_keys 2>/dev/null
+exportfs -ua
+umount {{.Path}} 2>/dev/null
+drbdadm down {{.Resource}}
+rm -f /etc/drbd.d/{{.Resource}}.res
+sed -i '\#^{{.Path}} #d' /etc/exports
+exportfs -ar
+# Restarts keepalived for the other resources, if any
+ls /etc/keepalived/safescale/*.conf >/dev/null 2>&1 && systemctl start keepalived
+exit 0
@@ -18,45 +18,36 @@
 #
 # Configures the NFS export of a local path
 
+{{ if .FSID }}
+# The FSID is imposed, replicated storages must have the same one on each server for the clients to fail over
+FSID={{.FSID}}
+{{ else }}
+# Keeps the FSID of the path if it is already exported, so the file handles of the clients stay valid
+FSID=$(grep "^{{.Path}} " /etc/exports | sed -r 's/ /\n/g' | grep fsid= | sed -r 's/.*fsid=([[:digit:]]+).*/\1/' | head -n 1)
+{{ end }}
+# Removes the previous export of the path
+sed -i '\#^{{.Path}} #d' /etc/exports
+
 # Determines the FSID value to use
-FSIDs=$(cat /etc/exports | sed -r 's/ /\n/g' | grep fsid= | sed -r 's/.+fsid=([[:alnum:]]+),.*/\1/g' | uniq | sort -n)
-LAST_FSID=$(echo "$FSIDs" | tail -n 1)
-if [ -z "$LAST_FSID" ]; then
-    FSID=1
-else
-    FSID=$((LAST_FSID + 1))
+if [ -z "$FSID" ]; then
+    FSIDs=$(cat /etc/exports | sed -r 's/ /\n/g' | grep fsid= | sed -r 's/.*fsid=([[:digit:]]+).*/\1/' | sort -n | uniq)
+    LAST_FSID=$(echo "$FSIDs" | tail -n 1)
+    if [ -z "$LAST_FSID" ]; then
+        FSID=1
+    else
+        FSID=$((LAST_FSID + 1))
+    fi
 fi
 
 # Adapts ACL
 ACCESS_RIGHTS="{{.AccessRights}}"
-FILTERED_ACCESS_RIGHTS=
 if [ -z "$ACCESS_RIGHTS" ]; then
     # No access rights, using default ones
     FILTERED_ACCESS_RIGHTS="*(rw,fsid=$FSID,sync,no_root_squash,no_subtree_check)"
 else
-    # Wants to ensure FSID is valid otherwise updates it
-    ACL=$(echo $ACCESS_RIGHTS | sed -r 's/\((.*)\)')
-    if [ ! -z "$ACL" ]; then
-        # If there is something between parenthesis, checks if there is some fsid directive, and check the values
-        # are not already used for other shares
-        ACL_FSIDs=$(echo $ACL | sed -r 's/ /\n/g' | grep fsid= | sed -r 's/.+fsid=([[:alnum:]]+),.*/\1/g' | uniq | sort -n)
-        for f in $ACL_FSIDs; do
-            echo $FSIDs | grep "^${f}$" && {
-                # FSID value is already used, updating the Access Rights to use the calculated new FSID
-                FILTERED_ACCESS_RIGHTS=$(echo $ACCESS_RIGHTS | sed -r 's/fsid=[[:numeric:]]*/fsid=$FSID/g')
-            }
-            break
-        done
-        if [ -z $FILTERED_ACCESS_RIGHTS ]; then
-            # No updated access rights, with something between parenthesis, adding fsid= directive
-            FILTERED_ACCESS_RIGHTS=$(echo $ACCESS_RIGHTS | sed -r 's/\)/,fsid=$FSID\)/g')
-        fi
-    else
-        # No updated access rights without anything between parenthesis, adding fsid= directive
-        FILTERED_ACCESS_RIGHTS=$(echo $ACCESS_RIGHTS | sed -r 's/\)/fsid=$FSID/g')
-    fi
+    # Sets the fsid directive of each ACL to the FSID of the path
+    FILTERED_ACCESS_RIGHTS=$(echo "$ACCESS_RIGHTS" | sed -r 's/,?fsid=[[:alnum:]]+//g' | sed -r "s/\)/,fsid=$FSID)/g")
 fi
-#VPL: case not managed: nothing between braces...
 
 # Create exported dir if necessary
 mkdir -p "{{.Path}}"
@@ -66,4 +57,4 @@ chmod a+rwx "{{.Path}}"
 echo "{{.Path}} $FILTERED_ACCESS_RIGHTS" >>/etc/exports
 
 # Updates exports
-exportfs -a
+exportfs -ar
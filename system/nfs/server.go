@@ -70,11 +70,17 @@ func (s *Server) UnmountBlockDevice(device string) error {
 	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to umount block device")
 }
 
-// AddShare configures a local path to be exported by NFS
-func (s *Server) AddShare(path string, acl string) error {
+// AddShare configures a local path to be exported by NFS with the ACLs given, replacing its previous export if any
+// Without ACL, every host may mount the path read-write
+func (s *Server) AddShare(path string, acls []ExportAcl) error {
+	err := validateAcls(acls)
+	if err != nil {
+		return err
+	}
 	data := map[string]interface{}{
 		"Path":         path,
-		"AccessRights": acl,
+		"AccessRights": exportsLine(acls),
+		"FSID":         0,
 	}
 	retcode, stdout, stderr, err := executeScript(*s.SshConfig, "nfs_server_path_export.sh", data)
 	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to export a shared directory")
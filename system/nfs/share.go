@@ -19,6 +19,7 @@ package nfs
 import (
 	"fmt"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -78,14 +79,51 @@ func (s *Share) AddAcl(acl ExportAcl) {
 
 //Add configures and exports the share
 func (s *Share) Add() error {
-	var acls string
-	for _, a := range s.ACLs {
+	return s.Server.AddShare(s.Path, s.ACLs)
+}
+
+//hostPattern matches the host patterns allowed in ACLs: a name or an address, possibly with wildcards, optionally
+//followed by a network mask
+//They are written in scripts run as root, so no other character is allowed
+var hostPattern = regexp.MustCompile(`^[A-Za-z0-9*?:][A-Za-z0-9.*?:-]*(/[0-9.]+)?$`)
+
+//ValidateHostPattern checks the pattern of the hosts of an ACL: a host name or an address, possibly with wildcards
+//('*', '?'), or a network in CIDR notation
+func ValidateHostPattern(host string) error {
+	if !hostPattern.MatchString(host) {
+		return fmt.Errorf("invalid host pattern '%s': only letters, digits, '.', '-', ':', '*', '?' and a network mask are allowed", host)
+	}
+	return nil
+}
+
+//validateAcls checks the ACLs only produce known keywords in the syntax of /etc/exports
+func validateAcls(acls []ExportAcl) error {
+	for _, a := range acls {
+		err := ValidateHostPattern(a.Host)
+		if err != nil {
+			return err
+		}
+		for _, mode := range a.SecurityModes {
+			_, err := SecurityFlavor.Parse(mode.String())
+			if err != nil {
+				return fmt.Errorf("invalid security mode in ACL of '%s': %s", a.Host, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+//exportsLine returns the access rights of the export of a path in the syntax of /etc/exports
+func exportsLine(acls []ExportAcl) string {
+	var entries []string
+	for _, a := range acls {
 		acl := a.Host + "("
 		if len(a.SecurityModes) > 0 {
-			acl += "sec="
+			var modes []string
 			for _, item := range a.SecurityModes {
-				acl += item.String()
+				modes = append(modes, strings.ToLower(item.String()))
 			}
+			acl += "sec=" + strings.Join(modes, ":")
 		} else {
 			acl += "sec=sys"
 		}
@@ -129,12 +167,7 @@ func (s *Share) Add() error {
 		}
 		acl += ")"
 
-		acls += acl + " "
-	}
-	data := map[string]interface{}{
-		"MountPoint":   s.Path,
-		"AccessRights": strings.TrimSpace(acls),
+		entries = append(entries, acl)
 	}
-	retcode, stdout, stderr, err := executeScript(*s.Server.SshConfig, "nfs_server_path_export.sh", data)
-	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to export a shared directory")
+	return strings.Join(entries, " ")
 }
@@ -17,7 +17,6 @@
 package system
 
 import (
-	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
@@ -26,17 +25,18 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net"
 	"os"
-	"os/exec"
-	"strconv"
+	"os/signal"
+	"path"
 	"strings"
+	"sync"
 	"syscall"
-	"text/template"
 	"time"
 
 	"github.com/CS-SI/SafeScale/utils/retry"
+	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 var (
@@ -86,6 +86,13 @@ var (
 	}
 )
 
+const (
+	// sshConnectionFailed is the exit status reported when the SSH connection fails, as the ssh binary does
+	sshConnectionFailed = 255
+	// scpCopyFailed is the exit status reported when a file transfer fails once connected, as the scp binary does
+	scpCopyFailed = 1
+)
+
 // IsSSHRetryable tells if the retcode of a ssh command may be retried
 func IsSSHRetryable(code int) bool {
 	if code == 2 || code == 4 || code == 5 || code == 66 || code == 67 || code == 70 || code == 74 || code == 75 || code == 76 {
@@ -105,20 +112,14 @@ func IsSCPRetryable(code int) bool {
 
 // SSHConfig helper to manage ssh session
 type SSHConfig struct {
-	User          string
-	Host          string
-	PrivateKey    string
+	User       string
+	Host       string
+	PrivateKey string
+	// HostKey is the public key of the SSH server of the host, in authorized_keys format
+	// If set, the host must present this key; if not, the key is checked against KnownHostsFile
+	HostKey       string
 	Port          int
 	GatewayConfig *SSHConfig
-	cmdTpl        string
-}
-
-// SSHTunnel a SSH tunnel
-type sshTunnel struct {
-	port      int
-	cmd       *exec.Cmd
-	cmdString string
-	keyFile   *os.File
 }
 
 // SSHErrorString returns if possible the string corresponding to SSH execution
@@ -137,39 +138,44 @@ func SCPErrorString(retcode int) string {
 	return "Unqualified error"
 }
 
-// Close close ssh tunnel
-func (tunnel *sshTunnel) Close() error {
-	defer os.Remove(tunnel.keyFile.Name())
+// SSHExitError is the error returned by SSHCommand when the remote command exits with a non-zero status,
+// or when the connection to the host fails (status 255, like the ssh binary)
+type SSHExitError struct {
+	// Status is the exit status of the remote command
+	Status int
+	// Stderr contains the error output of the command, if collected by Output
+	Stderr []byte
+	err    error
+}
 
-	err := tunnel.cmd.Process.Kill()
-	if err != nil {
-		return fmt.Errorf("Unable to close tunnel :%s", err.Error())
-	}
-	bytes, err := exec.Command("pgrep", "-f", tunnel.cmdString).Output()
-	if err != nil {
-		return fmt.Errorf("Unable to close tunnel :%s", err.Error())
-	}
-	portStr := strings.Trim(string(bytes), "\n")
-	_, err = strconv.Atoi(portStr)
-	if err != nil {
-		return fmt.Errorf("Unable to close tunnel :%s", err.Error())
-	}
-	err = exec.Command("kill", "-9", portStr).Run()
-	if err != nil {
-		return fmt.Errorf("Unable to close tunnel :%s", err.Error())
-	}
-	return nil
+// Error returns the message of the error
+func (e *SSHExitError) Error() string {
+	return e.err.Error()
 }
 
-// GetFreePort get a frre port
-func getFreePort() (int, error) {
-	listener, err := net.Listen("tcp", ":0")
-	defer listener.Close()
-	if err != nil {
-		return 0, err
+// ExitStatus returns the exit status of the remote command
+func (e *SSHExitError) ExitStatus() int {
+	return e.Status
+}
+
+// connectionError wraps an error occurring before or during the connection to the remote host
+func connectionError(err error) *SSHExitError {
+	return &SSHExitError{Status: sshConnectionFailed, err: err}
+}
+
+// exitError converts an error returned by a SSH session into a SSHExitError, nil stays nil
+func exitError(err error) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *SSHExitError:
+		return e
+	case *ssh.ExitError:
+		return &SSHExitError{Status: e.ExitStatus(), err: e}
+	default:
+		// The session ended without exit status: the connection has been lost
+		return connectionError(err)
 	}
-	port := listener.Addr().(*net.TCPAddr).Port
-	return port, nil
 }
 
 // CreateTempFileFromString creates a tempory file containing 'content'
@@ -184,103 +190,123 @@ func CreateTempFileFromString(content string, filemode os.FileMode) (*os.File, e
 	return f, nil
 }
 
-func isTunnelReady(port int) bool {
-	// Try to create a server with the port
-	server, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
-	if err != nil {
-		return true
-	}
-	server.Close()
-	return false
-
-}
-
-// createTunnel create SSH from local host to remote host throw gateway
-func createTunnel(cfg *SSHConfig) (*sshTunnel, error) {
-	f, err := CreateTempFileFromString(cfg.GatewayConfig.PrivateKey, 0400)
-	if err != nil {
-		return nil, err
+// SSHCommand defines a SSH command
+type SSHCommand struct {
+	config  *SSHConfig
+	command string
+	ctx     context.Context
+
+	client  *pooledClient
+	session *ssh.Session
+	// fresh tells the command runs on a connection of its own, outside of the pool, closed when the command ends
+	fresh bool
+	// cancelled is closed when the command ends, to stop watching the context
+	cancelled chan struct{}
+	endOnce   sync.Once
+}
+
+// prepare connects to the host and opens the session running the command, if not already done
+func (c *SSHCommand) prepare() error {
+	if c.session != nil {
+		return nil
+	}
+	var (
+		client *pooledClient
+		err    error
+	)
+	if c.fresh {
+		client, err = dial(c.config)
+	} else {
+		client, err = acquireClient(c.config)
 	}
-	freePort, err := getFreePort()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	options := "-q -oServerAliveInterval=60 -oStrictHostKeyChecking=no -oUserKnownHostsFile=/dev/null -oPubkeyAuthentication=yes -oPasswordAuthentication=no"
-	cmdString := fmt.Sprintf("ssh -i %s -NL %d:%s:%d %s@%s %s -p %d",
-		f.Name(),
-		freePort,
-		cfg.Host,
-		cfg.Port,
-		cfg.GatewayConfig.User,
-		cfg.GatewayConfig.Host,
-		options,
-		cfg.GatewayConfig.Port,
-	)
-	cmd := exec.Command("sh", "-c", cmdString)
-	err = cmd.Start()
-	//	err = cmd.Wait()
+	session, err := client.client.NewSession()
 	if err != nil {
-		return nil, err
-	}
-
-	for nbiter := 0; !isTunnelReady(freePort) && nbiter < 100; nbiter++ {
-		time.Sleep(10 * time.Millisecond)
+		c.giveBack(client)
+		return connectionError(fmt.Errorf("failed to open session on '%s': %s", c.config.Host, err.Error()))
 	}
-	return &sshTunnel{
-		port:      freePort,
-		cmd:       cmd,
-		cmdString: cmdString,
-		keyFile:   f,
-	}, nil
+	c.client = client
+	c.session = session
+	return nil
 }
 
-// SSHCommand defines a SSH command
-type SSHCommand struct {
-	cmd     *exec.Cmd
-	tunnels []*sshTunnel
-	keyFile *os.File
+// Connect connects to the host and opens the session of the command, without starting it
+// The failures to connect can be retried safely, unlike the ones of the command once started, which may have had
+// effects and produced outputs; the exit status of the returned error is then 255
+func (c *SSHCommand) Connect() error {
+	return c.prepare()
 }
 
-func (c *SSHCommand) closeTunnels() error {
-	var err error
-	for _, t := range c.tunnels {
-		err = t.Close()
+// watch kills the command if its context becomes done before the command completes
+func (c *SSHCommand) watch() {
+	if c.ctx == nil {
+		return
 	}
-	//Tunnels are imbricated only last error is significant
-	return err
+	c.cancelled = make(chan struct{})
+	go func() {
+		select {
+		case <-c.ctx.Done():
+			c.Kill()
+		case <-c.cancelled:
+		}
+	}()
 }
 
 // Wait waits for the command to exit and waits for any copying to stdin or copying from stdout or stderr to complete.
 // The command must have been started by Start.
 // The returned error is nil if the command runs, has no problems copying stdin, stdout, and stderr, and exits with a zero exit status.
-// If the command fails to run or doesn't complete successfully, the error is of type *ExitError. Other error types may be returned for I/O problems.
-// Wait also waits for the I/O loop copying from c.Stdin into the process's standard input to complete.
-// Wait releases any resources associated with the Cmd.
+// If the command fails to run or doesn't complete successfully, the error is of type *SSHExitError. Other error types may be returned for I/O problems.
+// Wait releases any resources associated with the SSHCommand.
 func (c *SSHCommand) Wait() error {
-	err := c.cmd.Wait()
+	if c.session == nil {
+		return fmt.Errorf("ssh: command not started")
+	}
+	err := c.session.Wait()
 	c.end()
-	return err
+	return exitError(err)
 
 }
 
 // Kill kills SSHCommand process and releases any resources associated with the SSHCommand.
 func (c *SSHCommand) Kill() error {
-	err := c.cmd.Process.Kill()
+	if c.session == nil {
+		return nil
+	}
+	// Most SSH servers ignore signals, closing the session ends the remote command anyway
+	_ = c.session.Signal(ssh.SIGKILL)
 	c.end()
-	return err
+	return nil
 }
 
 // StdoutPipe returns a pipe that will be connected to the command's standard output when the command starts.
 // Wait will close the pipe after seeing the command exit, so most callers need not close the pipe themselves; however, an implication is that it is incorrect to call Wait before all reads from the pipe have completed.
 // For the same reason, it is incorrect to call Run when using StdoutPipe.
 func (c *SSHCommand) StdoutPipe() (io.ReadCloser, error) {
-	return c.cmd.StdoutPipe()
+	err := c.prepare()
+	if err != nil {
+		return nil, err
+	}
+	pipe, err := c.session.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(pipe), nil
 }
 
 // StderrPipe returns a pipe that will be connected to the command's standard error when the command starts.
 // Wait will close the pipe after seeing the command exit, so most callers need not close the pipe themselves; however, an implication is that it is incorrect to call Wait before all reads from the pipe have completed. For the same reason, it is incorrect to use Run when using StderrPipe.
 func (c *SSHCommand) StderrPipe() (io.ReadCloser, error) {
-	return c.cmd.StderrPipe()
+	err := c.prepare()
+	if err != nil {
+		return nil, err
+	}
+	pipe, err := c.session.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(pipe), nil
 }
 
 // StdinPipe returns a pipe that will be connected to the command's standard input when the command starts.
@@ -288,24 +314,43 @@ func (c *SSHCommand) StderrPipe() (io.ReadCloser, error) {
 // A caller need only call Close to force the pipe to close sooner.
 // For example, if the command being run will not exit until standard input is closed, the caller must close the pipe.
 func (c *SSHCommand) StdinPipe() (io.WriteCloser, error) {
-	return c.cmd.StdinPipe()
+	err := c.prepare()
+	if err != nil {
+		return nil, err
+	}
+	return c.session.StdinPipe()
 }
 
 // Output runs the command and returns its standard output.
-// Any returned error will usually be of type *ExitError.
-// If c.Stderr was nil, Output populates ExitError.Stderr.
+// Any returned error will usually be of type *SSHExitError, whose Stderr field contains the error output of the command.
 func (c *SSHCommand) Output() ([]byte, error) {
-	content, err := c.cmd.Output()
+	err := c.prepare()
+	if err != nil {
+		return nil, err
+	}
+	var stderr strings.Builder
+	c.session.Stderr = &stderr
+	c.watch()
+	content, err := c.session.Output(c.command)
 	c.end()
+	err = exitError(err)
+	if ee, ok := err.(*SSHExitError); ok {
+		ee.Stderr = []byte(stderr.String())
+	}
 	return content, err
 }
 
 // CombinedOutput runs the command and returns its combined standard
 // output and standard error.
 func (c *SSHCommand) CombinedOutput() ([]byte, error) {
-	content, err := c.cmd.CombinedOutput()
+	err := c.prepare()
+	if err != nil {
+		return nil, err
+	}
+	c.watch()
+	content, err := c.session.CombinedOutput(c.command)
 	c.end()
-	return content, err
+	return content, exitError(err)
 }
 
 // Start starts the specified command but does not wait for it to complete.
@@ -313,170 +358,163 @@ func (c *SSHCommand) CombinedOutput() ([]byte, error) {
 // The Wait method will return the exit code and release associated resources
 // once the command exits.
 func (c *SSHCommand) Start() error {
-	return c.cmd.Start()
+	err := c.prepare()
+	if err != nil {
+		return err
+	}
+	err = c.session.Start(c.command)
+	if err != nil {
+		c.end()
+		return connectionError(err)
+	}
+	c.watch()
+	return nil
 }
 
 // Run starts the specified command and waits for it to complete.
 //
-// The returned error is nil if the command runs, has no problems
-// copying stdin, stdout, and stderr, and exits with a zero exit
-// status.
-//
-// If the command starts but does not complete successfully, the error is of
-// type *ExitError. Other error types may be returned for other situations.
+// Returns the exit status, the standard output and the error output of the command.
+// If the connection to the host fails, the exit status is 255 and the error output contains the reason, as
+// with the ssh binary; the returned error is reserved to the other failures.
 func (c *SSHCommand) Run() (int, string, string, error) {
-	//	err := c.cmd.Run()
-	//	c.end()
-	//	return err
-
-	// Set up the outputs (std and err)
-	stdOut, err := c.StdoutPipe()
-	if err != nil {
-		return 0, "", "", err
-	}
-	stderr, err := c.StderrPipe()
+	err := c.prepare()
 	if err != nil {
-		return 0, "", "", err
-	}
-
-	// Launch the command and wait for its execution
-	if err = c.Start(); err != nil {
-		return 0, "", "", err
+		return sshConnectionFailed, "", err.Error(), nil
 	}
-
-	msgOut, _ := ioutil.ReadAll(stdOut)
-	msgErr, _ := ioutil.ReadAll(stderr)
-
-	err = c.Wait()
+	var stdout, stderr strings.Builder
+	c.session.Stdout = &stdout
+	c.session.Stderr = &stderr
+	c.watch()
+	err = c.session.Run(c.command)
 	c.end()
 	if err != nil {
-		msgError, retCode, erro := ExtractRetCode(err)
-		if erro != nil {
-			return 0, "", "", err
+		ee := exitError(err).(*SSHExitError)
+		msgErr := stderr.String()
+		if ee.Status == sshConnectionFailed {
+			msgErr = fmt.Sprint(msgErr, ee.Error())
 		}
-		return retCode, string(msgOut[:]), fmt.Sprint(string(msgErr[:]), msgError), nil
+		return ee.Status, stdout.String(), msgErr, nil
 	}
 
-	return 0, string(msgOut[:]), string(msgErr[:]), nil
+	return 0, stdout.String(), stderr.String(), nil
 
 }
 
-func (c *SSHCommand) end() error {
-	err1 := c.closeTunnels()
-	err2 := os.Remove(c.keyFile.Name())
-	if err1 != nil {
-		return fmt.Errorf("Unable to close ssh tunnels : %s", err1.Error())
-	}
-	if err2 != nil {
-		return fmt.Errorf("Unable to close ssh tunnels : %s", err2.Error())
+// Stream runs the command like Run, writing its outputs to stdout and stderr as they are produced
+// If stdin is not nil, it is copied to the standard input of the command, which is closed at the end of stdin
+//
+// Returns the exit status of the command. If the connection to the host fails, the exit status is 255 and the
+// returned error gives the reason.
+func (c *SSHCommand) Stream(stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	err := c.prepare()
+	if err != nil {
+		return sshConnectionFailed, err
 	}
-	return nil
-}
-
-func recCreateTunnels(ssh *SSHConfig, tunnels *[]*sshTunnel) (*sshTunnel, error) {
-	if ssh != nil {
-		tunnel, err := recCreateTunnels(ssh.GatewayConfig, tunnels)
+	if stdin != nil {
+		// session.Stdin would make the end of the command wait for the end of stdin
+		pipe, err := c.session.StdinPipe()
 		if err != nil {
-			return nil, err
-		}
-		cfg := ssh
-		if tunnel != nil {
-			gateway := *ssh.GatewayConfig
-			gateway.Port = tunnel.port
-			gateway.Host = "127.0.0.1"
-			cfg.GatewayConfig = &gateway
+			c.end()
+			return sshConnectionFailed, connectionError(err)
 		}
-		if cfg.GatewayConfig != nil {
-			tunnel, err = createTunnel(cfg)
-			if err != nil {
-				return nil, err
-			}
-			*tunnels = append(*tunnels, tunnel)
-			return tunnel, err
+		go func() {
+			io.Copy(pipe, stdin)
+			pipe.Close()
+		}()
+	}
+	c.session.Stdout = stdout
+	c.session.Stderr = stderr
+	c.watch()
+	err = c.session.Run(c.command)
+	c.end()
+	if err != nil {
+		if ee, ok := err.(*ssh.ExitError); ok {
+			// The remote command may exit with status 255 too
+			return ee.ExitStatus(), nil
 		}
-		return nil, nil
+		return sshConnectionFailed, exitError(err)
 	}
-	return nil, nil
-
+	return 0, nil
 }
 
-func (ssh *SSHConfig) createTunnels() ([]*sshTunnel, *SSHConfig, error) {
-	var tunnels []*sshTunnel
-	tunnel, err := recCreateTunnels(ssh, &tunnels)
-	if err != nil {
-		if err != nil {
-			return nil, nil, fmt.Errorf("Unable to create SSH Tunnels : %s", err.Error())
+// end closes the session and gives the connection back to the pool
+func (c *SSHCommand) end() {
+	c.endOnce.Do(func() {
+		if c.cancelled != nil {
+			close(c.cancelled)
 		}
-	}
-	sshConfig := *ssh
-	if tunnel == nil {
-		return nil, &sshConfig, nil
-	}
-
-	if ssh.GatewayConfig != nil {
-		if err != nil {
-			return nil, nil, fmt.Errorf("Unable to create SSH Tunnel : %s", err.Error())
+		if c.session != nil {
+			c.session.Close()
 		}
-		sshConfig.Port = tunnel.port
-		sshConfig.Host = "127.0.0.1"
-		tunnels = append(tunnels, tunnel)
-	}
-	return tunnels, &sshConfig, nil
+		if c.client != nil {
+			c.giveBack(c.client)
+		}
+	})
 }
 
-func createSSHCmd(sshConfig *SSHConfig, cmdString string, withSudo bool) (string, *os.File, error) {
-	f, err := CreateTempFileFromString(sshConfig.PrivateKey, 0400)
-	if err != nil {
-		return "", nil, fmt.Errorf("Unable to create temporary key file: %s", err.Error())
+// giveBack gives the connection of the command back to the pool, or closes it if it's not shared
+func (c *SSHCommand) giveBack(client *pooledClient) {
+	if !c.fresh {
+		releaseClient(client)
+		return
 	}
-	//defer os.Remove(f.Name())
-	options := "-q -oLogLevel=error -oStrictHostKeyChecking=no -oUserKnownHostsFile=/dev/null -oPubkeyAuthentication=yes -oPasswordAuthentication=no"
+	poolLock.Lock()
+	defer poolLock.Unlock()
+	closeClientLocked(client)
+}
 
-	sshCmdString := fmt.Sprintf("ssh -i %s %s -p %d %s@%s",
-		f.Name(),
-		options,
-		sshConfig.Port,
-		sshConfig.User,
-		sshConfig.Host,
-	)
+// shellQuote quotes s to be given as a single argument to a shell
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
 
-	sudoOpt := ""
+// remoteCommand returns the command line running cmdString with bash on the remote host
+func remoteCommand(cmdString string, withSudo bool) string {
+	command := "bash -c " + shellQuote(cmdString)
 	if withSudo {
-		// tty option is required for some command like ls
-		sudoOpt = " -t sudo"
+		command = "sudo " + command
 	}
-
-	if cmdString != "" {
-		sshCmdString = sshCmdString + fmt.Sprintf("%s bash <<'ENDSSH'\n%s\nENDSSH", sudoOpt, cmdString)
-	}
-	return sshCmdString, f, nil
-
+	return command
 }
 
 // Command returns the Cmd struct to execute cmdString remotely
 func (ssh *SSHConfig) Command(cmdString string) (*SSHCommand, error) {
-	return ssh.command(cmdString, false)
+	return ssh.command(nil, cmdString, false)
 }
 
 // SudoCommand returns the Cmd struct to execute cmdString remotely. Command is executed with sudo
 func (ssh *SSHConfig) SudoCommand(cmdString string) (*SSHCommand, error) {
-	return ssh.command(cmdString, true)
+	return ssh.command(nil, cmdString, true)
 }
 
-func (ssh *SSHConfig) command(cmdString string, withSudo bool) (*SSHCommand, error) {
-	tunnels, sshConfig, err := ssh.createTunnels()
+// SudoCommandOnNewConnection is like SudoCommand, but the command runs on a new connection instead of one shared
+// with other commands; it tells if the host still accepts SSH connections
+// The connections to the gateways are shared as usual
+func (ssh *SSHConfig) SudoCommandOnNewConnection(cmdString string) (*SSHCommand, error) {
+	cmd, err := ssh.command(nil, cmdString, true)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to create command : %s", err.Error())
+		return nil, err
 	}
-	sshCmdString, keyFile, err := createSSHCmd(sshConfig, cmdString, withSudo)
-	if err != nil {
-		return nil, fmt.Errorf("Unable to create command : %s", err.Error())
+	cmd.fresh = true
+	return cmd, nil
+}
+
+// CommandContext is like Command but includes a context.
+//
+// The provided context is used to kill the remote command (by closing its
+// session) if the context becomes done before the command completes on its own.
+func (ssh *SSHConfig) CommandContext(ctx context.Context, cmdString string) (*SSHCommand, error) {
+	return ssh.command(ctx, cmdString, false)
+}
+
+func (ssh *SSHConfig) command(ctx context.Context, cmdString string, withSudo bool) (*SSHCommand, error) {
+	if strings.TrimSpace(cmdString) == "" {
+		return nil, fmt.Errorf("Unable to create command : command is empty")
 	}
-	cmd := exec.Command("bash", "-c", sshCmdString)
 	sshCommand := SSHCommand{
-		cmd:     cmd,
-		tunnels: tunnels,
-		keyFile: keyFile,
+		config:  ssh,
+		command: remoteCommand(cmdString, withSudo),
+		ctx:     ctx,
 	}
 	return &sshCommand, nil
 }
@@ -484,9 +522,18 @@ func (ssh *SSHConfig) command(cmdString string, withSudo bool) (*SSHCommand, err
 // WaitServerReady waits until the SSH server is ready
 // the 'timeout' parameter is in minutes
 func (ssh *SSHConfig) WaitServerReady(timeout time.Duration) error {
-	err := retry.WhileUnsuccessfulDelay5Seconds(
+	return ssh.WaitServerReadyWithContext(context.Background(), timeout)
+}
+
+// WaitServerReadyWithContext waits until the SSH server is ready, giving up if ctx is done
+func (ssh *SSHConfig) WaitServerReadyWithContext(ctx context.Context, timeout time.Duration) error {
+	err := retry.WhileUnsuccessfulDelay5SecondsWithContext(
+		ctx,
 		func() error {
-			cmd, _ := ssh.Command("sudo cat /var/tmp/user_data.done")
+			cmd, err := ssh.CommandContext(ctx, "sudo cat /var/tmp/user_data.done")
+			if err != nil {
+				return err
+			}
 
 			retcode, _, stderr, err := cmd.Run()
 			if err != nil {
@@ -502,6 +549,9 @@ func (ssh *SSHConfig) WaitServerReady(timeout time.Duration) error {
 		},
 		timeout,
 	)
+	if err != nil && err == ctx.Err() {
+		return err
+	}
 	if err != nil {
 		logCmd, _ := ssh.Command("sudo cat /var/tmp/user_data.log")
 
@@ -519,161 +569,195 @@ func (ssh *SSHConfig) WaitServerReady(timeout time.Duration) error {
 	return nil
 }
 
-// Copy copy a file/directory from/to local to/from remote
+// Copy copy a file from/to local to/from remote, using SFTP
+// Returns the exit status scp would have returned: 255 if the connection failed, 1 if the transfer failed
 func (ssh *SSHConfig) Copy(remotePath, localPath string, isUpload bool) (int, string, string, error) {
-	tunnels, sshConfig, err := ssh.createTunnels()
+	client, err := acquireClient(ssh)
 	if err != nil {
-		return 0, "", "", fmt.Errorf("Unable to create tunnels : %s", err.Error())
+		return sshConnectionFailed, "", err.Error(), nil
 	}
+	defer releaseClient(client)
 
-	identityfile, err := CreateTempFileFromString(sshConfig.PrivateKey, 0400)
+	sftpClient, err := sftp.NewClient(client.client)
 	if err != nil {
-		return 0, "", "", fmt.Errorf("Unable to create temporary key file: %s", err.Error())
+		return sshConnectionFailed, "", fmt.Sprintf("failed to start sftp session on '%s': %s", ssh.Host, err.Error()), nil
 	}
+	defer sftpClient.Close()
 
-	cmdTemplate, err := template.New("Command").Parse("scp -i {{.IdentityFile}} -P {{.Port}} {{.Options}} {{if .IsUpload}}{{.LocalPath}} {{.User}}@{{.Host}}:{{.RemotePath}}{{else}}{{.User}}@{{.Host}}:{{.RemotePath}} {{.LocalPath}}{{end}}")
+	if isUpload {
+		err = upload(sftpClient, localPath, remotePath)
+	} else {
+		err = download(sftpClient, remotePath, localPath)
+	}
 	if err != nil {
-		return 0, "", "", fmt.Errorf("Error parsing command template: %s", err.Error())
+		return scpCopyFailed, "", err.Error(), nil
 	}
+	return 0, "", "", nil
+}
 
-	var copyCommand bytes.Buffer
-	if err := cmdTemplate.Execute(&copyCommand, struct {
-		IdentityFile string
-		Port         int
-		Options      string
-		User         string
-		Host         string
-		RemotePath   string
-		LocalPath    string
-		IsUpload     bool
-	}{
-		IdentityFile: identityfile.Name(),
-		Port:         sshConfig.Port,
-		Options:      "-q -oLogLevel=error -oStrictHostKeyChecking=no -oUserKnownHostsFile=/dev/null -oPubkeyAuthentication=yes -oPasswordAuthentication=no",
-		User:         sshConfig.User,
-		Host:         sshConfig.Host,
-		RemotePath:   remotePath,
-		LocalPath:    localPath,
-		IsUpload:     isUpload,
-	}); err != nil {
-		return 0, "", "", fmt.Errorf("Error executing template: %s", err.Error())
+// upload copies the local file localPath to remotePath, keeping its permissions
+// If remotePath is an existing directory, the file is copied inside it
+func upload(client *sftp.Client, localPath, remotePath string) error {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
 	}
-
-	sshCmdString := copyCommand.String()
-
-	cmd := exec.Command("bash", "-c", sshCmdString)
-	sshCommand := SSHCommand{
-		cmd:     cmd,
-		tunnels: tunnels,
-		keyFile: identityfile,
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s: not a regular file", localPath)
+	}
+	if remoteInfo, err := client.Stat(remotePath); err == nil && remoteInfo.IsDir() {
+		remotePath = path.Join(remotePath, path.Base(localPath))
 	}
 
-	return sshCommand.Run()
+	dst, err := client.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("%s: %s", remotePath, err.Error())
+	}
+	_, err = io.Copy(dst, src)
+	if err != nil {
+		dst.Close()
+		return fmt.Errorf("%s: %s", remotePath, err.Error())
+	}
+	err = dst.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %s", remotePath, err.Error())
+	}
+	return client.Chmod(remotePath, info.Mode().Perm())
 }
 
-// Exec executes the cmd using ssh
-func (ssh *SSHConfig) Exec(cmdString string) error {
-	tunnels, sshConfig, err := ssh.createTunnels()
+// download copies the remote file remotePath to localPath, keeping its permissions
+// If localPath is an existing directory, the file is copied inside it
+func download(client *sftp.Client, remotePath, localPath string) error {
+	src, err := client.Open(remotePath)
 	if err != nil {
-		for _, t := range tunnels {
-			t.Close()
-		}
-		return fmt.Errorf("Unable to create command : %s", err.Error())
+		return fmt.Errorf("%s: %s", remotePath, err.Error())
 	}
-	sshCmdString, keyFile, err := createSSHCmd(sshConfig, cmdString, false)
+	defer src.Close()
+	info, err := src.Stat()
 	if err != nil {
-		for _, t := range tunnels {
-			t.Close()
-		}
-		if keyFile != nil {
-			os.Remove(keyFile.Name())
-		}
-		return fmt.Errorf("Unable to create command : %s", err.Error())
+		return fmt.Errorf("%s: %s", remotePath, err.Error())
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s: not a regular file", remotePath)
 	}
-	bash, err := exec.LookPath("bash")
+	if localInfo, err := os.Stat(localPath); err == nil && localInfo.IsDir() {
+		localPath = path.Join(localPath, path.Base(remotePath))
+	}
+
+	dst, err := os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
 	if err != nil {
-		for _, t := range tunnels {
-			t.Close()
-		}
-		if keyFile != nil {
-			os.Remove(keyFile.Name())
-		}
-		return fmt.Errorf("Unable to create command : %s", err.Error())
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if err != nil {
+		dst.Close()
+		return err
 	}
-	var args []string
+	return dst.Close()
+}
+
+// Exec executes the cmd using ssh, attached to the standard input and outputs of the current process
+// If cmdString is empty, an interactive shell is opened
+func (ssh *SSHConfig) Exec(cmdString string) error {
 	if cmdString == "" {
-		args = []string{sshCmdString}
-	} else {
-		args = []string{"-c", sshCmdString}
+		return ssh.Enter()
+	}
+	cmd, err := ssh.Command(cmdString)
+	if err != nil {
+		return err
+	}
+	err = cmd.prepare()
+	if err != nil {
+		return err
 	}
-	err = syscall.Exec(bash, args, nil)
-	os.Remove(keyFile.Name())
-	return err
+	cmd.session.Stdin = os.Stdin
+	cmd.session.Stdout = os.Stdout
+	cmd.session.Stderr = os.Stderr
+	err = cmd.session.Run(cmd.command)
+	cmd.end()
+	return exitError(err)
 }
 
 // Enter Enter to interactive shell
 func (ssh *SSHConfig) Enter() error {
-	tunnels, sshConfig, err := ssh.createTunnels()
+	client, err := acquireClient(ssh)
 	if err != nil {
-		for _, t := range tunnels {
-			t.Close()
-		}
-		return fmt.Errorf("Unable to create command : %s", err.Error())
+		return err
 	}
+	defer releaseClient(client)
 
-	sshCmdString, keyFile, err := createSSHCmd(sshConfig, "", false)
+	session, err := client.client.NewSession()
 	if err != nil {
-		for _, t := range tunnels {
-			t.Close()
-		}
-		if keyFile != nil {
-			os.Remove(keyFile.Name())
-		}
-		return fmt.Errorf("Unable to create command : %s", err.Error())
+		return connectionError(fmt.Errorf("failed to open session on '%s': %s", ssh.Host, err.Error()))
 	}
+	defer session.Close()
+	session.Stdin = os.Stdin
+	session.Stdout = os.Stdout
+	session.Stderr = os.Stderr
 
-	bash, err := exec.LookPath("bash")
-	if err != nil {
-		for _, t := range tunnels {
-			t.Close()
-		}
-		if keyFile != nil {
-			os.Remove(keyFile.Name())
+	fd := int(os.Stdin.Fd())
+	if terminal.IsTerminal(fd) {
+		restore, err := requestTerminal(session, fd)
+		if err != nil {
+			return err
 		}
-		return fmt.Errorf("Unable to create command : %s", err.Error())
+		defer restore()
 	}
 
-	proc := exec.Command(bash, "-c", sshCmdString)
-	proc.Stdin = os.Stdin
-	proc.Stdout = os.Stdout
-	proc.Stderr = os.Stderr
-	err = proc.Run()
-	os.Remove(keyFile.Name())
-	return err
+	err = session.Shell()
+	if err != nil {
+		return connectionError(err)
+	}
+	return exitError(session.Wait())
 }
 
-// CommandContext is like Command but includes a context.
-//
-// The provided context is used to kill the process (by calling
-// os.Process.Kill) if the context becomes done before the command
-// completes on its own.
-func (ssh *SSHConfig) CommandContext(ctx context.Context, cmdString string) (*SSHCommand, error) {
-	tunnels, sshConfig, err := ssh.createTunnels()
+// requestTerminal puts the local terminal fd in raw mode and requests a remote terminal of the same size for session
+// The changes of the size of the local terminal are forwarded until the returned function, restoring the local
+// terminal, is called
+func requestTerminal(session *ssh.Session, fd int) (func(), error) {
+	state, err := terminal.MakeRaw(fd)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to create command : %s", err.Error())
+		return nil, fmt.Errorf("Unable to set terminal in raw mode : %s", err.Error())
 	}
-	sshCmdString, keyFile, err := createSSHCmd(sshConfig, cmdString, false)
+
+	width, height, err := terminal.GetSize(fd)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to create command : %s", err.Error())
+		width, height = 80, 24
 	}
-
-	cmd := exec.CommandContext(ctx, "bash", "-c", sshCmdString)
-	sshCommand := SSHCommand{
-		cmd:     cmd,
-		tunnels: tunnels,
-		keyFile: keyFile,
+	term := os.Getenv("TERM")
+	if term == "" {
+		term = "xterm"
 	}
-	return &sshCommand, nil
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+	err = session.RequestPty(term, height, width, modes)
+	if err != nil {
+		terminal.Restore(fd, state)
+		return nil, connectionError(fmt.Errorf("failed to request terminal: %s", err.Error()))
+	}
+
+	resized := make(chan os.Signal, 1)
+	signal.Notify(resized, syscall.SIGWINCH)
+	go func() {
+		for range resized {
+			if width, height, err := terminal.GetSize(fd); err == nil {
+				session.WindowChange(height, width)
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(resized)
+		close(resized)
+		terminal.Restore(fd, state)
+	}, nil
 }
 
 // CreateKeyPair creates a key pair
@@ -0,0 +1,371 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package system
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func newPublicKey(t *testing.T) ssh.PublicKey {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.Nil(t, err)
+	key, err := ssh.NewPublicKey(&privateKey.PublicKey)
+	require.Nil(t, err)
+	return key
+}
+
+func Test_checkHostKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "knownhosts")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	defer func(previous string) { KnownHostsFile = previous }(KnownHostsFile)
+	KnownHostsFile = filepath.Join(dir, "safescale", "known_hosts")
+
+	key1 := newPublicKey(t)
+	key2 := newPublicKey(t)
+
+	// First connection pins the key
+	assert.Nil(t, checkHostKey("1.2.3.4:22", key1))
+	assert.Nil(t, checkHostKey("1.2.3.4:22", key1))
+	err = checkHostKey("1.2.3.4:22", key2)
+	require.NotNil(t, err)
+	_, ok := err.(*HostKeyMismatchError)
+	assert.True(t, ok)
+
+	// The same private address behind another gateway is another host
+	assert.Nil(t, checkHostKey("1.2.3.4:22/192.168.0.5:22", key1))
+	assert.Nil(t, checkHostKey("5.6.7.8:22/192.168.0.5:22", key2))
+	assert.NotNil(t, checkHostKey("5.6.7.8:22/192.168.0.5:22", key1))
+
+	known, err := readKnownHosts()
+	require.Nil(t, err)
+	assert.Len(t, known, 3)
+
+	// Forgetting a gateway forgets the hosts behind it
+	require.Nil(t, ForgetHostKey(&SSHConfig{Host: "1.2.3.4", Port: 22}))
+	known, err = readKnownHosts()
+	require.Nil(t, err)
+	assert.Len(t, known, 1)
+	assert.Nil(t, checkHostKey("1.2.3.4:22", key2))
+}
+
+func Test_hostID(t *testing.T) {
+	gw := SSHConfig{User: "gpac", Host: "1.2.3.4", Port: 22}
+	host := SSHConfig{User: "gpac", Host: "192.168.0.5", Port: 22, GatewayConfig: &gw}
+	assert.Equal(t, "1.2.3.4:22", gw.hostID())
+	assert.Equal(t, "1.2.3.4:22/192.168.0.5:22", host.hostID())
+
+	other := host
+	other.PrivateKey = "another key"
+	assert.NotEqual(t, host.poolKey(), other.poolKey())
+}
+
+func Test_remoteCommand(t *testing.T) {
+	assert.Equal(t, `bash -c 'echo '\''hello'\'''`, remoteCommand("echo 'hello'", false))
+	assert.Equal(t, `sudo bash -c 'ls /root'`, remoteCommand("ls /root", true))
+}
+
+func Test_hostKeyCallback(t *testing.T) {
+	defer func(previous string) { KnownHostsFile = previous }(KnownHostsFile)
+	KnownHostsFile = ""
+
+	key1 := newPublicKey(t)
+	key2 := newPublicKey(t)
+	cfg := SSHConfig{Host: "1.2.3.4", Port: 22, HostKey: string(ssh.MarshalAuthorizedKey(key1))}
+	callback, err := hostKeyCallback(&cfg)
+	require.Nil(t, err)
+	assert.Nil(t, callback("1.2.3.4:22", nil, key1))
+	err = callback("1.2.3.4:22", nil, key2)
+	require.NotNil(t, err)
+	_, ok := err.(*HostKeyMismatchError)
+	assert.True(t, ok)
+
+	fingerprint, err := HostKeyFingerprint(cfg.HostKey)
+	require.Nil(t, err)
+	assert.Equal(t, ssh.FingerprintSHA256(key1), fingerprint)
+
+	cfg.HostKey = "invalid"
+	_, err = hostKeyCallback(&cfg)
+	assert.NotNil(t, err)
+
+	// Without known hosts file, a key not pinned can't be checked
+	cfg.HostKey = ""
+	callback, err = hostKeyCallback(&cfg)
+	require.Nil(t, err)
+	assert.NotNil(t, callback("1.2.3.4:22", nil, key1))
+}
+
+// socksConn replays the bytes sent by a SOCKS client and records the replies
+type socksConn struct {
+	*bytes.Reader
+	replies bytes.Buffer
+}
+
+func (c *socksConn) Write(p []byte) (int, error) {
+	return c.replies.Write(p)
+}
+
+func Test_socksRequest(t *testing.T) {
+	conn := &socksConn{Reader: bytes.NewReader([]byte{5, 2, 2, 0, 5, 1, 0, 1, 10, 0, 0, 3, 0, 80})}
+	address, err := socksRequest(conn)
+	require.Nil(t, err)
+	assert.Equal(t, "10.0.0.3:80", address)
+	assert.Equal(t, []byte{5, 0}, conn.replies.Bytes())
+
+	conn = &socksConn{Reader: bytes.NewReader(append([]byte{5, 1, 0, 5, 1, 0, 3, 7}, append([]byte("gateway"), 0x1f, 0x90)...))}
+	address, err = socksRequest(conn)
+	require.Nil(t, err)
+	assert.Equal(t, "gateway:8080", address)
+
+	// Authentication required
+	conn = &socksConn{Reader: bytes.NewReader([]byte{5, 1, 2})}
+	_, err = socksRequest(conn)
+	assert.NotNil(t, err)
+	assert.Equal(t, []byte{5, 0xff}, conn.replies.Bytes())
+
+	// BIND command
+	conn = &socksConn{Reader: bytes.NewReader([]byte{5, 1, 0, 5, 2, 0, 1, 10, 0, 0, 3, 0, 80})}
+	_, err = socksRequest(conn)
+	assert.NotNil(t, err)
+	assert.Equal(t, socksCommandNotSupported, conn.replies.Bytes()[3])
+
+	// SOCKS4
+	conn = &socksConn{Reader: bytes.NewReader([]byte{4, 1, 0, 80, 10, 0, 0, 3, 0})}
+	_, err = socksRequest(conn)
+	assert.NotNil(t, err)
+}
+
+// testSSHServer is a SSH server listening on localhost, on which every command succeeds without doing anything
+type testSSHServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+	// accepted and open count the connections accepted and still open
+	accepted int32
+	open     int32
+}
+
+// newTestSSHServer starts a testSSHServer and returns the configuration to connect to it
+func newTestSSHServer(t *testing.T) (*testSSHServer, *SSHConfig) {
+	hostKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.Nil(t, err)
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	require.Nil(t, err)
+	userKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.Nil(t, err)
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(userKey)})
+
+	s := &testSSHServer{config: &ssh.ServerConfig{NoClientAuth: true}}
+	s.config.AddHostKey(signer)
+	s.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	go func() {
+		for {
+			conn, err := s.listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&s.accepted, 1)
+			atomic.AddInt32(&s.open, 1)
+			go s.serve(conn)
+		}
+	}()
+
+	return s, &SSHConfig{
+		User:       "safescale",
+		Host:       "127.0.0.1",
+		Port:       s.listener.Addr().(*net.TCPAddr).Port,
+		PrivateKey: string(privateKey),
+		HostKey:    string(ssh.MarshalAuthorizedKey(signer.PublicKey())),
+	}
+}
+
+// serve handles a connection, replying to each command with the exit status 0
+func (s *testSSHServer) serve(conn net.Conn) {
+	defer atomic.AddInt32(&s.open, -1)
+	sc, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	defer sc.Close()
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				req.Reply(true, nil)
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+				channel.Close()
+			}
+		}()
+	}
+}
+
+func Test_SudoCommandOnNewConnection(t *testing.T) {
+	server, cfg := newTestSSHServer(t)
+	defer server.listener.Close()
+	defer CloseSSHConnections()
+
+	run := func(cmd *SSHCommand, err error) {
+		require.Nil(t, err)
+		retcode, _, _, err := cmd.Run()
+		require.Nil(t, err)
+		require.Equal(t, 0, retcode)
+	}
+
+	// Commands share the same connection
+	run(cfg.SudoCommand("true"))
+	run(cfg.SudoCommand("true"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&server.accepted))
+
+	// A command on a new connection doesn't reuse the shared one, nor keeps its own open
+	run(cfg.SudoCommandOnNewConnection("true"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&server.accepted))
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&server.open) > 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&server.open))
+
+	// The shared connection is still used by the other commands
+	run(cfg.SudoCommand("true"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&server.accepted))
+}
+
+func Test_AcceptedHostKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "knownhosts")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	defer func(previous string) { KnownHostsFile = previous }(KnownHostsFile)
+	KnownHostsFile = filepath.Join(dir, "known_hosts")
+
+	server, cfg := newTestSSHServer(t)
+	defer server.listener.Close()
+	defer CloseSSHConnections()
+	hostKey := strings.TrimSpace(cfg.HostKey)
+	cfg.HostKey = ""
+
+	cmd, err := cfg.SudoCommand("true")
+	require.Nil(t, err)
+	_, _, _, err = cmd.Run()
+	require.Nil(t, err)
+
+	// The key is the one accepted by the connection already open
+	key, err := cfg.AcceptedHostKey()
+	require.Nil(t, err)
+	assert.Equal(t, hostKey, key)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&server.accepted))
+	known, err := readKnownHosts()
+	require.Nil(t, err)
+	assert.Len(t, known, 1)
+
+	// A host presenting another key than the one known is rejected
+	CloseSSHConnections()
+	other := SSHConfig{User: cfg.User, Host: cfg.Host, Port: cfg.Port, PrivateKey: cfg.PrivateKey}
+	KnownHostsFile = filepath.Join(dir, "other_known_hosts")
+	require.Nil(t, checkHostKey(other.hostID(), newPublicKey(t)))
+	_, err = other.AcceptedHostKey()
+	assert.NotNil(t, err)
+}
+
+func Test_Connect(t *testing.T) {
+	server, cfg := newTestSSHServer(t)
+	defer CloseSSHConnections()
+
+	cmd, err := cfg.SudoCommand("true")
+	require.Nil(t, err)
+	require.Nil(t, cmd.Connect())
+	retcode, err := cmd.Stream(nil, ioutil.Discard, ioutil.Discard)
+	require.Nil(t, err)
+	assert.Equal(t, 0, retcode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&server.accepted))
+
+	// Failures to connect are reported with the exit status 255
+	CloseSSHConnections()
+	server.listener.Close()
+	cmd, err = cfg.SudoCommand("true")
+	require.Nil(t, err)
+	err = cmd.Connect()
+	require.NotNil(t, err)
+	ee, ok := err.(*SSHExitError)
+	require.True(t, ok)
+	assert.Equal(t, 255, ee.ExitStatus())
+}
+
+func Test_sweep(t *testing.T) {
+	server, cfg := newTestSSHServer(t)
+	defer server.listener.Close()
+	defer CloseSSHConnections()
+
+	run := func() {
+		cmd, err := cfg.SudoCommand("true")
+		require.Nil(t, err)
+		_, _, _, err = cmd.Run()
+		require.Nil(t, err)
+	}
+
+	// A connection recently used is kept
+	run()
+	sweep()
+	run()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&server.accepted))
+
+	// An idle connection is closed, even while other commands run
+	defer func(previous time.Duration) { SSHIdleTimeout = previous }(SSHIdleTimeout)
+	SSHIdleTimeout = 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			sweep()
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		run()
+	}
+	<-done
+	sweep()
+	poolLock.Lock()
+	assert.Empty(t, pool)
+	poolLock.Unlock()
+}
@@ -0,0 +1,358 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package system
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	// SSHDialTimeout is the maximum time to establish a SSH connection, including the handshake
+	SSHDialTimeout = 30 * time.Second
+	// SSHIdleTimeout is the time an unused SSH connection is kept open, to be reused by the next commands
+	SSHIdleTimeout = 2 * time.Minute
+	// SSHKeepAliveInterval is the interval between the checks of the connections kept open
+	SSHKeepAliveInterval = 30 * time.Second
+
+	// pool contains the open connections, indexed by poolKey()
+	pool        = map[string]*pooledClient{}
+	poolLock    sync.Mutex
+	janitorOnce sync.Once
+)
+
+// pooledClient is a SSH connection shared by the commands run on the same host
+type pooledClient struct {
+	key    string
+	client *ssh.Client
+	// hostKey is the key presented by the host and accepted when connecting, in authorized_keys format
+	hostKey string
+	// parent is the connection to the gateway the connection goes through, if any
+	parent  *pooledClient
+	refs    int
+	lastUse time.Time
+	closed  bool
+}
+
+// address returns the address of the SSH server
+func (ssh *SSHConfig) address() string {
+	return net.JoinHostPort(ssh.Host, fmt.Sprintf("%d", ssh.Port))
+}
+
+// hostID identifies the host in the known hosts: its address, prefixed by the address of the gateways it is reached
+// through, as private addresses may be reused behind different gateways
+func (ssh *SSHConfig) hostID() string {
+	if ssh.GatewayConfig == nil {
+		return ssh.address()
+	}
+	return ssh.GatewayConfig.hostID() + "/" + ssh.address()
+}
+
+// poolKey identifies the connections which can be shared: same user, same key, same host key, same host reached
+// through the same gateways
+func (ssh *SSHConfig) poolKey() string {
+	sum := sha256.Sum256([]byte(ssh.PrivateKey + "\n" + ssh.HostKey))
+	return ssh.User + "@" + ssh.hostID() + "#" + hex.EncodeToString(sum[:8])
+}
+
+// clientConfig returns the configuration of the SSH connection to the host described by cfg, authenticated by
+// the private key kept in memory
+func clientConfig(cfg *SSHConfig) (*ssh.ClientConfig, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(cfg.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %s", err.Error())
+	}
+	callback, err := hostKeyCallback(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: callback,
+		Timeout:         SSHDialTimeout,
+	}, nil
+}
+
+// acquireClient returns a connection to the host described by cfg, opening it if no connection can be reused
+// The connection must be given back with releaseClient
+func acquireClient(cfg *SSHConfig) (*pooledClient, error) {
+	janitorOnce.Do(func() { go janitor() })
+
+	key := cfg.poolKey()
+	poolLock.Lock()
+	pc, ok := pool[key]
+	if ok {
+		pc.refs++
+	}
+	poolLock.Unlock()
+	if ok {
+		if alive(pc) {
+			return pc, nil
+		}
+		poolLock.Lock()
+		closeClientLocked(pc)
+		releaseClientLocked(pc)
+		poolLock.Unlock()
+	}
+
+	pc, err := dial(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	poolLock.Lock()
+	defer poolLock.Unlock()
+	if existing, ok := pool[key]; ok {
+		// Another command connected meanwhile, its connection is shared
+		pc.refs = 0
+		closeClientLocked(pc)
+		existing.refs++
+		return existing, nil
+	}
+	pool[key] = pc
+	return pc, nil
+}
+
+// releaseClient gives back a connection obtained with acquireClient
+func releaseClient(pc *pooledClient) {
+	poolLock.Lock()
+	defer poolLock.Unlock()
+	releaseClientLocked(pc)
+}
+
+func releaseClientLocked(pc *pooledClient) {
+	pc.refs--
+	pc.lastUse = time.Now()
+}
+
+// closeClientLocked closes the connection and removes it from the pool
+// The connection to the gateway is released, and closed later if not used anymore
+func closeClientLocked(pc *pooledClient) {
+	if pc.closed {
+		return
+	}
+	pc.closed = true
+	pc.client.Close()
+	if pool[pc.key] == pc {
+		delete(pool, pc.key)
+	}
+	if pc.parent != nil {
+		releaseClientLocked(pc.parent)
+	}
+}
+
+// alive tells if the connection still works
+func alive(pc *pooledClient) bool {
+	_, _, err := pc.client.SendRequest("keepalive@openssh.com", true, nil)
+	return err == nil
+}
+
+// dial opens a connection to the host described by cfg, through its gateways if any
+func dial(cfg *SSHConfig) (*pooledClient, error) {
+	config, err := clientConfig(cfg)
+	if err != nil {
+		return nil, connectionError(fmt.Errorf("failed to connect to '%s': %s", cfg.Host, err.Error()))
+	}
+	var accepted string
+	check := config.HostKeyCallback
+	config.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := check(hostname, remote, key)
+		if err == nil {
+			accepted = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+		}
+		return err
+	}
+
+	if cfg.GatewayConfig == nil {
+		client, err := dialDirect(cfg.address(), config)
+		if err != nil {
+			return nil, connectionError(fmt.Errorf("failed to connect to '%s': %s", cfg.Host, err.Error()))
+		}
+		return &pooledClient{key: cfg.poolKey(), client: client, hostKey: accepted, refs: 1}, nil
+	}
+
+	parent, err := acquireClient(cfg.GatewayConfig)
+	if err != nil {
+		return nil, err
+	}
+	client, err := dialThrough(parent.client, cfg.address(), config)
+	if err != nil {
+		releaseClient(parent)
+		return nil, connectionError(fmt.Errorf("failed to connect to '%s' through gateway '%s': %s", cfg.Host, cfg.GatewayConfig.Host, err.Error()))
+	}
+	return &pooledClient{key: cfg.poolKey(), client: client, hostKey: accepted, parent: parent, refs: 1}, nil
+}
+
+// dialDirect opens a SSH connection to address
+func dialDirect(address string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := net.DialTimeout("tcp", address, SSHDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	// The deadline also bounds the handshake, that ssh.Dial leaves unbounded
+	conn.SetDeadline(time.Now().Add(SSHDialTimeout))
+	c, chans, reqs, err := ssh.NewClientConn(conn, address, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// dialThrough opens a SSH connection to address, tunnelled in the connection gateway
+func dialThrough(gateway *ssh.Client, address string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	type result struct {
+		client *ssh.Client
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		conn, err := gateway.Dial("tcp", address)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		c, chans, reqs, err := ssh.NewClientConn(conn, address, config)
+		if err != nil {
+			conn.Close()
+			done <- result{err: err}
+			return
+		}
+		done <- result{client: ssh.NewClient(c, chans, reqs)}
+	}()
+
+	select {
+	case r := <-done:
+		return r.client, r.err
+	case <-time.After(SSHDialTimeout):
+		go func() {
+			if r := <-done; r.client != nil {
+				r.client.Close()
+			}
+		}()
+		return nil, fmt.Errorf("timeout after %s", SSHDialTimeout)
+	}
+}
+
+// janitor sweeps the pool every SSHKeepAliveInterval
+func janitor() {
+	for range time.Tick(SSHKeepAliveInterval) {
+		sweep()
+	}
+}
+
+// sweep closes the connections unused for more than SSHIdleTimeout, and the ones which don't work anymore
+func sweep() {
+	poolLock.Lock()
+	var idle []*pooledClient
+	for _, pc := range pool {
+		if pc.refs > 0 {
+			continue
+		}
+		if time.Since(pc.lastUse) > SSHIdleTimeout {
+			closeClientLocked(pc)
+		} else {
+			idle = append(idle, pc)
+		}
+	}
+	poolLock.Unlock()
+
+	// The keepalive requests are sent without the lock, a connection taken meanwhile is kept
+	for _, pc := range idle {
+		if alive(pc) {
+			continue
+		}
+		poolLock.Lock()
+		if pc.refs <= 0 {
+			closeClientLocked(pc)
+		}
+		poolLock.Unlock()
+	}
+}
+
+// CloseSSHConnections closes all the SSH connections kept open
+// Commands still running are interrupted
+func CloseSSHConnections() {
+	poolLock.Lock()
+	defer poolLock.Unlock()
+	for _, pc := range pool {
+		closeClientLocked(pc)
+	}
+}
+
+// AcceptedHostKey returns the key presented by the host, in authorized_keys format, as accepted when connecting to it
+// (checked against SSHConfig.HostKey or the known hosts); the connection already open is used if any, else it's
+// opened and kept open for the next commands
+func (ssh *SSHConfig) AcceptedHostKey() (string, error) {
+	pc, err := acquireClient(ssh)
+	if err != nil {
+		return "", err
+	}
+	defer releaseClient(pc)
+	return pc.hostKey, nil
+}
+
+// errHostKeyScanned interrupts the handshake once the host key has been received
+var errHostKeyScanned = errors.New("host key scanned")
+
+// ScanHostKey returns the key presented by the host, in authorized_keys format, like ssh-keyscan
+// The key is neither checked nor pinned, and no authentication is done; the gateways are checked as usual
+func (ssh *SSHConfig) ScanHostKey() (string, error) {
+	var scanned string
+	config := scanConfig(&scanned)
+
+	var err error
+	if ssh.GatewayConfig == nil {
+		_, err = dialDirect(ssh.address(), config)
+	} else {
+		var parent *pooledClient
+		parent, err = acquireClient(ssh.GatewayConfig)
+		if err != nil {
+			return "", err
+		}
+		_, err = dialThrough(parent.client, ssh.address(), config)
+		releaseClient(parent)
+	}
+	if scanned == "" {
+		if err == nil {
+			err = errors.New("no host key received")
+		}
+		return "", connectionError(fmt.Errorf("failed to scan host key of '%s': %s", ssh.Host, err.Error()))
+	}
+	return scanned, nil
+}
+
+// scanConfig returns a client configuration storing the host key in scanned and interrupting the handshake
+func scanConfig(scanned *string) *ssh.ClientConfig {
+	return &ssh.ClientConfig{
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			*scanned = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+			return errHostKeyScanned
+		},
+		Timeout: SSHDialTimeout,
+	}
+}
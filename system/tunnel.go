@@ -0,0 +1,291 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package system
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// SSHTunnel forwards the connections accepted on a local address through the SSH connection to an host
+type SSHTunnel struct {
+	config   *SSHConfig
+	listener net.Listener
+	// open opens the remote side of a connection accepted on the local address
+	open func(local net.Conn, dial dialFunc) (net.Conn, error)
+
+	lock      sync.Mutex
+	conns     map[net.Conn]bool
+	lastError error
+	closed    bool
+	done      chan struct{}
+}
+
+// dialFunc opens a connection to address from the host at the other end of the tunnel
+type dialFunc func(address string) (net.Conn, error)
+
+// Forward forwards the connections accepted on the local address to the remote address, like 'ssh -L'
+// The remote address is resolved by the host, so 'localhost:80' is the port 80 of the host itself
+func (ssh *SSHConfig) Forward(local string, remote string) (*SSHTunnel, error) {
+	return ssh.listen(local, func(conn net.Conn, dial dialFunc) (net.Conn, error) {
+		return dial(remote)
+	})
+}
+
+// SOCKS runs on the local address a SOCKS5 proxy opening its connections from the host, like 'ssh -D'
+func (ssh *SSHConfig) SOCKS(local string) (*SSHTunnel, error) {
+	return ssh.listen(local, socksOpen)
+}
+
+// listen accepts the connections on the local address, and opens their remote side with open
+func (ssh *SSHConfig) listen(local string, open func(net.Conn, dialFunc) (net.Conn, error)) (*SSHTunnel, error) {
+	// Checks the host is reachable before accepting connections
+	pc, err := acquireClient(ssh)
+	if err != nil {
+		return nil, err
+	}
+	releaseClient(pc)
+
+	listener, err := net.Listen("tcp", local)
+	if err != nil {
+		return nil, err
+	}
+	t := &SSHTunnel{
+		config:   ssh,
+		listener: listener,
+		open:     open,
+		conns:    map[net.Conn]bool{},
+		done:     make(chan struct{}),
+	}
+	go t.serve()
+	return t, nil
+}
+
+// Addr returns the local address the tunnel listens on
+func (t *SSHTunnel) Addr() string {
+	return t.listener.Addr().String()
+}
+
+// Connections returns the number of connections currently forwarded
+func (t *SSHTunnel) Connections() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return len(t.conns)
+}
+
+// LastError returns the error of the last connection which couldn't be forwarded, if any
+func (t *SSHTunnel) LastError() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.lastError
+}
+
+// Done returns a channel closed when the tunnel is closed
+func (t *SSHTunnel) Done() <-chan struct{} {
+	return t.done
+}
+
+// Close stops listening and closes the connections forwarded
+func (t *SSHTunnel) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	close(t.done)
+	err := t.listener.Close()
+	for conn := range t.conns {
+		conn.Close()
+	}
+	return err
+}
+
+func (t *SSHTunnel) serve() {
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			t.Close()
+			return
+		}
+		go t.handle(conn)
+	}
+}
+
+// handle forwards a connection accepted on the local address
+func (t *SSHTunnel) handle(local net.Conn) {
+	defer local.Close()
+	t.lock.Lock()
+	if t.closed {
+		t.lock.Unlock()
+		return
+	}
+	t.conns[local] = true
+	t.lock.Unlock()
+	defer func() {
+		t.lock.Lock()
+		delete(t.conns, local)
+		t.lock.Unlock()
+	}()
+
+	var pc *pooledClient
+	remote, err := t.open(local, func(address string) (net.Conn, error) {
+		var err error
+		pc, err = acquireClient(t.config)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := pc.client.Dial("tcp", address)
+		if err != nil {
+			releaseClient(pc)
+			pc = nil
+			return nil, fmt.Errorf("failed to connect to '%s' from '%s': %s", address, t.config.Host, err.Error())
+		}
+		return conn, nil
+	})
+	if pc != nil {
+		defer releaseClient(pc)
+	}
+	if err != nil {
+		t.lock.Lock()
+		t.lastError = err
+		t.lock.Unlock()
+		return
+	}
+	defer remote.Close()
+	pipe(local, remote)
+}
+
+// pipe copies the data between a and b until one of them is closed
+func pipe(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	forward := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		done <- struct{}{}
+	}
+	go forward(a, b)
+	go forward(b, a)
+	<-done
+	a.Close()
+	b.Close()
+	<-done
+}
+
+// SOCKS5 reply codes (RFC 1928)
+const (
+	socksSucceeded               byte = 0
+	socksGeneralFailure          byte = 1
+	socksCommandNotSupported     byte = 7
+	socksAddressTypeNotSupported byte = 8
+)
+
+// socksOpen opens the remote side of a SOCKS5 connection
+func socksOpen(conn net.Conn, dial dialFunc) (net.Conn, error) {
+	address, err := socksRequest(conn)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := dial(address)
+	if err != nil {
+		socksReply(conn, socksGeneralFailure)
+		return nil, err
+	}
+	err = socksReply(conn, socksSucceeded)
+	if err != nil {
+		remote.Close()
+		return nil, err
+	}
+	return remote, nil
+}
+
+// socksRequest reads the greeting and the request of a SOCKS5 client, and returns the address it asks to connect to
+// Only the CONNECT command is supported, without authentication
+func socksRequest(conn io.ReadWriter) (string, error) {
+	buf := make([]byte, 257)
+
+	// Greeting: version, number of methods, methods
+	_, err := io.ReadFull(conn, buf[:2])
+	if err != nil {
+		return "", err
+	}
+	if buf[0] != 5 {
+		return "", fmt.Errorf("unsupported SOCKS version %d", buf[0])
+	}
+	methods := buf[2 : 2+int(buf[1])]
+	_, err = io.ReadFull(conn, methods)
+	if err != nil {
+		return "", err
+	}
+	if bytes.IndexByte(methods, 0) < 0 {
+		conn.Write([]byte{5, 0xff})
+		return "", errors.New("SOCKS client requires an authentication")
+	}
+	_, err = conn.Write([]byte{5, 0})
+	if err != nil {
+		return "", err
+	}
+
+	// Request: version, command, reserved, address type, address, port
+	_, err = io.ReadFull(conn, buf[:4])
+	if err != nil {
+		return "", err
+	}
+	if buf[1] != 1 {
+		socksReply(conn, socksCommandNotSupported)
+		return "", fmt.Errorf("unsupported SOCKS command %d", buf[1])
+	}
+	var host string
+	switch buf[3] {
+	case 1, 4:
+		ip := make(net.IP, 4)
+		if buf[3] == 4 {
+			ip = make(net.IP, 16)
+		}
+		_, err = io.ReadFull(conn, ip)
+		host = ip.String()
+	case 3:
+		_, err = io.ReadFull(conn, buf[:1])
+		if err == nil {
+			_, err = io.ReadFull(conn, buf[1:1+int(buf[0])])
+			host = string(buf[1 : 1+int(buf[0])])
+		}
+	default:
+		socksReply(conn, socksAddressTypeNotSupported)
+		return "", fmt.Errorf("unsupported SOCKS address type %d", buf[3])
+	}
+	if err != nil {
+		return "", err
+	}
+	_, err = io.ReadFull(conn, buf[:2])
+	if err != nil {
+		return "", err
+	}
+	port := binary.BigEndian.Uint16(buf[:2])
+	return net.JoinHostPort(host, strconv.Itoa(int(port))), nil
+}
+
+// socksReply sends the reply to a SOCKS5 request; the bound address isn't given
+func socksReply(w io.Writer, code byte) error {
+	_, err := w.Write([]byte{5, code, 0, 1, 0, 0, 0, 0, 0, 0})
+	return err
+}
@@ -1,63 +1,123 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
 package metadata
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
-	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
-)
+	"strings"
 
-var (
-	doEncrypt = false
+	"github.com/CS-SI/SafeScale/providers"
 )
 
-// See alternate IV creation from ciphertext below
-//var iv = []byte{35, 46, 57, 24, 85, 35, 24, 74, 87, 35, 88, 98, 66, 32, 14, 05}
+// encryptedHeader prefixes the content of the encrypted metadata objects
+// Objects without this header are stored in clear (metadata written before the encryption was enabled)
+var encryptedHeader = []byte("\x00SafeScale/aes-256-gcm\x00")
 
-func encrypt(key, text []byte) ([]byte, error) {
-	if doEncrypt {
-		block, err := aes.NewCipher(key)
-		if err != nil {
-			return nil, err
-		}
-		b := base64.StdEncoding.EncodeToString(text)
-		ciphertext := make([]byte, aes.BlockSize+len(b))
-		iv := ciphertext[:aes.BlockSize]
-		if _, err := io.ReadFull(rand.Reader, iv); err != nil {
-			return nil, err
-		}
-		cfb := cipher.NewCFBEncrypter(block, iv)
-		cfb.XORKeyStream(ciphertext[aes.BlockSize:], []byte(b))
-		return ciphertext, nil
+// newGCM returns an AES-GCM cipher using key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// isEncrypted tells if text has been encrypted by encrypt()
+func isEncrypted(text []byte) bool {
+	return bytes.HasPrefix(text, encryptedHeader)
+}
+
+// encrypt encrypts text with AES-256-GCM; if key is nil, text is returned as is
+// The name of the object is authenticated with the content, so an encrypted object cannot be moved under another name
+func encrypt(key []byte, name string, text []byte) ([]byte, error) {
+	if key == nil {
+		return text, nil
 	}
-	return text, nil
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	ciphertext := make([]byte, 0, len(encryptedHeader)+len(nonce)+len(text)+gcm.Overhead())
+	ciphertext = append(ciphertext, encryptedHeader...)
+	ciphertext = append(ciphertext, nonce...)
+	return gcm.Seal(ciphertext, nonce, text, additionalData(name)), nil
+}
+
+// additionalData returns the data authenticated with the content of the object 'name'
+func additionalData(name string) []byte {
+	return []byte(strings.Trim(name, "/"))
 }
 
-//decrypt() decrypts a byte slice previously encrypted with encrypt()
-func decrypt(key, text []byte) ([]byte, error) {
-	if doEncrypt {
-		block, err := aes.NewCipher(key)
+//decrypt() decrypts a byte slice previously encrypted with encrypt(), using the current or the previous key
+// Content stored in clear is returned as is
+func decrypt(keys *providers.MetadataKeys, name string, text []byte) ([]byte, error) {
+	if !isEncrypted(text) {
+		return text, nil
+	}
+	if keys == nil {
+		return nil, fmt.Errorf("metadata '%s' is encrypted but no metadata key is configured for the tenant", name)
+	}
+	text = text[len(encryptedHeader):]
+	for _, key := range [][]byte{keys.Current, keys.Previous} {
+		if key == nil {
+			continue
+		}
+		gcm, err := newGCM(key)
 		if err != nil {
 			return nil, err
 		}
-		if len(text) < aes.BlockSize {
+		if len(text) < gcm.NonceSize() {
 			return nil, errors.New("ciphertext too short")
 		}
-		iv := text[:aes.BlockSize]
-		text = text[aes.BlockSize:]
-		cfb := cipher.NewCFBDecrypter(block, iv)
-		cfb.XORKeyStream(text, text)
-		data, err := base64.StdEncoding.DecodeString(string(text))
-		if err != nil {
-			return nil, err
+		data, err := gcm.Open(nil, text[:gcm.NonceSize()], text[gcm.NonceSize():], additionalData(name))
+		if err == nil {
+			return data, nil
 		}
-		return data, nil
 	}
-	return text, nil
+	return nil, fmt.Errorf("failed to decrypt metadata '%s': wrong metadata key or corrupted content", name)
+}
+
+// currentKey returns the key to use to encrypt metadata of the tenant, nil if encryption is disabled
+func currentKey(keys *providers.MetadataKeys) []byte {
+	if keys == nil {
+		return nil
+	}
+	return keys.Current
 }
 
-func init() {
-	doEncrypt = false
+// Reencrypt rewrites all the metadata of the tenant with its current key
+// Metadata stored in clear or encrypted with the previous key are migrated; if no current key is configured, metadata
+// are decrypted and stored in clear
+// Returns the number of objects rewritten
+func Reencrypt(svc *providers.Service) (int, error) {
+	count := 0
+	err := rewriteBucket(svc, func(name string, content []byte) ([]byte, bool, error) {
+		count++
+		return content, true, nil
+	})
+	return count, err
 }
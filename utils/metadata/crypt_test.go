@@ -0,0 +1,99 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/local"
+	"github.com/CS-SI/SafeScale/utils/metadata"
+)
+
+// readContent reads the content of the object 'name' of the folder
+func readContent(t *testing.T, folder *metadata.Folder, name string) (string, error) {
+	var content string
+	found, err := folder.Read(".", name, func(buf *bytes.Buffer) error {
+		return gob.NewDecoder(buf).Decode(&content)
+	})
+	if err == nil {
+		require.True(t, found)
+	}
+	return content, err
+}
+
+// rawContent reads the object 'name' of the metadata bucket as stored
+func rawContent(t *testing.T, svc *providers.Service, name string) []byte {
+	cfg, err := svc.GetCfgOpts()
+	require.Nil(t, err)
+	bucket, _ := cfg.Get("MetadataBucket")
+	o, err := svc.GetObject(bucket.(string), name, nil)
+	require.Nil(t, err)
+	var buffer bytes.Buffer
+	_, err = buffer.ReadFrom(o.Content)
+	require.Nil(t, err)
+	return buffer.Bytes()
+}
+
+func TestFolder_Encryption(t *testing.T) {
+	clt, err := (&local.Client{}).Build(map[string]interface{}{"name": t.Name()})
+	require.Nil(t, err)
+	svc := providers.FromClient(clt)
+	defer svc.SetMetadataKeys(nil)
+	folder := metadata.NewFolder(svc, "hosts/byName")
+
+	// Metadata written in clear before the encryption was enabled
+	require.Nil(t, folder.Write(".", "host1", "secret1"))
+	assert.True(t, bytes.Contains(rawContent(t, svc, "hosts/byName/host1"), []byte("secret1")))
+
+	key1 := bytes.Repeat([]byte{1}, providers.MetadataKeySize)
+	require.Nil(t, svc.SetMetadataKeys(&providers.MetadataKeys{Current: key1}))
+	require.Nil(t, folder.Write(".", "host2", "secret2"))
+	assert.False(t, bytes.Contains(rawContent(t, svc, "hosts/byName/host2"), []byte("secret2")))
+	content, err := readContent(t, folder, "host1")
+	require.Nil(t, err)
+	assert.Equal(t, "secret1", content)
+	content, err = readContent(t, folder, "host2")
+	require.Nil(t, err)
+	assert.Equal(t, "secret2", content)
+
+	// Key rotation
+	key2 := bytes.Repeat([]byte{2}, providers.MetadataKeySize)
+	require.Nil(t, svc.SetMetadataKeys(&providers.MetadataKeys{Current: key2}))
+	_, err = readContent(t, folder, "host2")
+	assert.NotNil(t, err)
+	require.Nil(t, svc.SetMetadataKeys(&providers.MetadataKeys{Current: key2, Previous: key1}))
+	count, err := metadata.Reencrypt(svc)
+	require.Nil(t, err)
+	assert.Equal(t, 2, count)
+	assert.False(t, bytes.Contains(rawContent(t, svc, "hosts/byName/host1"), []byte("secret1")))
+
+	require.Nil(t, svc.SetMetadataKeys(&providers.MetadataKeys{Current: key2}))
+	content, err = readContent(t, folder, "host2")
+	require.Nil(t, err)
+	assert.Equal(t, "secret2", content)
+
+	// Without key, encrypted metadata cannot be read
+	require.Nil(t, svc.SetMetadataKeys(nil))
+	_, err = readContent(t, folder, "host1")
+	assert.NotNil(t, err)
+}
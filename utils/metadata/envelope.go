@@ -0,0 +1,187 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// envelopeHeader prefixes the content of the metadata objects wrapped in an envelope
+// Objects without this header have been written before the introduction of the envelope, and are considered as version 0
+var envelopeHeader = []byte("\x00SafeScale/envelope\x00")
+
+// Envelope wraps the content of a metadata object with its kind and the version of its format
+type Envelope struct {
+	Kind    string
+	Version int
+	Payload []byte
+}
+
+// UpgradeFunc converts the payload of an object from a version to the next one
+type UpgradeFunc func(payload []byte) ([]byte, error)
+
+// kind describes a kind of metadata object
+type kind struct {
+	name     string
+	version  int
+	paths    []string
+	upgrades map[int]UpgradeFunc
+}
+
+var (
+	// kinds contains the registered kinds of metadata objects, indexed by name
+	kinds     = map[string]*kind{}
+	kindsLock sync.RWMutex
+)
+
+// RegisterKind declares a kind of metadata object, the current version of its format and the paths (as understood by
+// path.Match) of the objects of this kind
+// Version 0 designates the objects written before the introduction of the envelope; their payload is considered
+// identical to the one of version 1
+func RegisterKind(name string, version int, paths ...string) {
+	kindsLock.Lock()
+	defer kindsLock.Unlock()
+
+	k, ok := kinds[name]
+	if !ok {
+		k = &kind{name: name, upgrades: map[int]UpgradeFunc{}}
+		kinds[name] = k
+	}
+	k.version = version
+	k.paths = append(k.paths, paths...)
+}
+
+// RegisterUpgrade declares the function converting the payload of the objects of kind 'name' from version 'from'
+// to version 'from'+1
+func RegisterUpgrade(name string, from int, fn UpgradeFunc) {
+	kindsLock.Lock()
+	defer kindsLock.Unlock()
+
+	k, ok := kinds[name]
+	if !ok {
+		k = &kind{name: name, upgrades: map[int]UpgradeFunc{}}
+		kinds[name] = k
+	}
+	k.upgrades[from] = fn
+}
+
+// kindOf returns the kind of the object stored at 'name'; if several paths match, the most specific one wins
+// Returns nil if no kind matches
+func kindOf(name string) *kind {
+	kindsLock.RLock()
+	defer kindsLock.RUnlock()
+
+	name = strings.Trim(name, "/")
+	var (
+		found    *kind
+		wildcard = -1
+	)
+	for _, k := range kinds {
+		for _, p := range k.paths {
+			if ok, _ := path.Match(p, name); !ok {
+				continue
+			}
+			count := strings.Count(p, "*")
+			if found == nil || count < wildcard {
+				found = k
+				wildcard = count
+			}
+		}
+	}
+	return found
+}
+
+// wrap puts payload in an envelope carrying the kind of the object 'name' and the current version of this kind
+func wrap(name string, payload []byte) ([]byte, error) {
+	e := Envelope{Payload: payload}
+	if k := kindOf(name); k != nil {
+		e.Kind = k.name
+		e.Version = k.version
+	}
+	return e.encode()
+}
+
+// encode serializes the envelope
+func (e *Envelope) encode() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.Write(envelopeHeader)
+	err := gob.NewEncoder(&buffer).Encode(e)
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// unwrap extracts the envelope from the content of the object 'name'
+// Content without envelope is returned in an envelope of version 0, whose kind is deduced from the name
+func unwrap(name string, content []byte) (*Envelope, error) {
+	if !bytes.HasPrefix(content, envelopeHeader) {
+		e := Envelope{Payload: content}
+		if k := kindOf(name); k != nil {
+			e.Kind = k.name
+		}
+		return &e, nil
+	}
+	var e Envelope
+	err := gob.NewDecoder(bytes.NewReader(content[len(envelopeHeader):])).Decode(&e)
+	if err != nil {
+		return nil, fmt.Errorf("invalid envelope of metadata '%s': %s", name, err.Error())
+	}
+	return &e, nil
+}
+
+// upgrade converts the payload of the envelope to the current version of its kind
+// Returns true if the payload has been modified
+func upgrade(name string, e *Envelope) (bool, error) {
+	if e.Kind == "" {
+		return false, nil
+	}
+
+	kindsLock.RLock()
+	defer kindsLock.RUnlock()
+
+	k, ok := kinds[e.Kind]
+	if !ok {
+		return false, fmt.Errorf("metadata '%s' is of unknown kind '%s'", name, e.Kind)
+	}
+	if e.Version > k.version {
+		return false, fmt.Errorf("metadata '%s' has been written by a more recent version of SafeScale (%s version %d, supported up to %d)", name, e.Kind, e.Version, k.version)
+	}
+	if e.Version == k.version {
+		return false, nil
+	}
+	for e.Version < k.version {
+		if e.Version > 0 {
+			fn, ok := k.upgrades[e.Version]
+			if !ok {
+				return false, fmt.Errorf("no upgrade of %s from version %d to %d", e.Kind, e.Version, e.Version+1)
+			}
+			payload, err := fn(e.Payload)
+			if err != nil {
+				return false, fmt.Errorf("failed to upgrade metadata '%s' from version %d to %d: %s", name, e.Version, e.Version+1, err.Error())
+			}
+			e.Payload = payload
+		}
+		e.Version++
+	}
+	return true, nil
+}
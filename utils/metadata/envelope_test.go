@@ -0,0 +1,139 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+	"github.com/CS-SI/SafeScale/providers/local"
+	"github.com/CS-SI/SafeScale/utils/metadata"
+)
+
+func TestMigrate(t *testing.T) {
+	clt, err := (&local.Client{}).Build(map[string]interface{}{"name": t.Name()})
+	require.Nil(t, err)
+	svc := providers.FromClient(clt)
+	cfg, err := svc.GetCfgOpts()
+	require.Nil(t, err)
+	bucket, _ := cfg.Get("MetadataBucket")
+
+	// Object written before the introduction of the envelope
+	var buffer bytes.Buffer
+	require.Nil(t, gob.NewEncoder(&buffer).Encode("legacy"))
+	require.Nil(t, svc.PutObject(bucket.(string), api.Object{Name: "tests/old", Content: bytes.NewReader(buffer.Bytes())}))
+
+	metadata.RegisterKind("test", 1, "tests/*")
+	folder := metadata.NewFolder(svc, "tests")
+	require.Nil(t, folder.Write(".", "new", "current"))
+	content, err := readContent(t, folder, "old")
+	require.Nil(t, err)
+	assert.Equal(t, "legacy", content)
+
+	// The format of the kind changes
+	metadata.RegisterKind("test", 2)
+	metadata.RegisterUpgrade("test", 1, func(payload []byte) ([]byte, error) {
+		var old string
+		err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&old)
+		if err != nil {
+			return nil, err
+		}
+		var buffer bytes.Buffer
+		err = gob.NewEncoder(&buffer).Encode(strings.ToUpper(old))
+		return buffer.Bytes(), err
+	})
+	content, err = readContent(t, folder, "new")
+	require.Nil(t, err)
+	assert.Equal(t, "CURRENT", content)
+
+	migrations, err := metadata.Migrate(svc, true)
+	require.Nil(t, err)
+	assert.Equal(t, []metadata.Migration{
+		{Name: "tests/new", Kind: "test", From: 1, To: 2},
+		{Name: "tests/old", Kind: "test", From: 0, To: 2},
+	}, migrations)
+
+	migrations, err = metadata.Migrate(svc, false)
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(migrations))
+	migrations, err = metadata.Migrate(svc, false)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(migrations))
+
+	// Upgrades are not applied twice
+	content, err = readContent(t, folder, "new")
+	require.Nil(t, err)
+	assert.Equal(t, "CURRENT", content)
+	content, err = readContent(t, folder, "old")
+	require.Nil(t, err)
+	assert.Equal(t, "LEGACY", content)
+}
+
+func TestMigrate_Cluster(t *testing.T) {
+	clt, err := (&local.Client{}).Build(map[string]interface{}{"name": t.Name()})
+	require.Nil(t, err)
+	svc := providers.FromClient(clt)
+	cfg, err := svc.GetCfgOpts()
+	require.Nil(t, err)
+	bucket, _ := cfg.Get("MetadataBucket")
+
+	// Cluster written before the introduction of the envelope
+	var buffer bytes.Buffer
+	require.Nil(t, gob.NewEncoder(&buffer).Encode("legacy"))
+	payload := append([]byte{}, buffer.Bytes()...)
+	require.Nil(t, svc.PutObject(bucket.(string), api.Object{Name: "clusters/old", Content: bytes.NewReader(payload)}))
+
+	// Cluster wrapped by a binary unaware of the kind of clusters
+	buffer.Reset()
+	buffer.WriteString("\x00SafeScale/envelope\x00")
+	require.Nil(t, gob.NewEncoder(&buffer).Encode(metadata.Envelope{Payload: payload}))
+	require.Nil(t, svc.PutObject(bucket.(string), api.Object{Name: "clusters/wrapped", Content: bytes.NewReader(buffer.Bytes())}))
+
+	migrations, err := metadata.Migrate(svc, false)
+	require.Nil(t, err)
+	assert.Equal(t, []metadata.Migration{
+		{Name: "clusters/old", Kind: "cluster", From: 0, To: 1},
+		{Name: "clusters/wrapped", Kind: "cluster", From: 0, To: 1},
+	}, migrations)
+	migrations, err = metadata.Migrate(svc, false)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(migrations))
+
+	content, err := readContent(t, metadata.NewFolder(svc, "clusters"), "wrapped")
+	require.Nil(t, err)
+	assert.Equal(t, "legacy", content)
+}
+
+func TestMigrate_UnknownKind(t *testing.T) {
+	clt, err := (&local.Client{}).Build(map[string]interface{}{"name": t.Name()})
+	require.Nil(t, err)
+	svc := providers.FromClient(clt)
+	cfg, err := svc.GetCfgOpts()
+	require.Nil(t, err)
+	bucket, _ := cfg.Get("MetadataBucket")
+
+	require.Nil(t, svc.PutObject(bucket.(string), api.Object{Name: "unknown/object", Content: strings.NewReader("content")}))
+	_, err = metadata.Migrate(svc, true)
+	assert.NotNil(t, err)
+}
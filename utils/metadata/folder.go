@@ -120,9 +120,26 @@ func (f *Folder) Search(path string, name string) (bool, error) {
 	return found, nil
 }
 
+// Lock waits until the lease protecting the folder is available, then takes it
+// The lease is renewed until Unlock is called; it is shared by all the users of the folder inside the current process
+func (f *Folder) Lock() error {
+	return acquireLease(f.svc, f.bucketName, lockKey(f.path))
+}
+
+// Unlock releases the lease taken by Lock
+func (f *Folder) Unlock() error {
+	return releaseLease(f.svc, f.bucketName, lockKey(f.path))
+}
+
 // Delete removes metadata passed as parameter
 func (f *Folder) Delete(path string, name string) error {
-	err := f.svc.DeleteObject(f.bucketName, f.absolutePath(path, name))
+	err := f.Lock()
+	if err != nil {
+		return err
+	}
+	defer f.Unlock()
+
+	err = f.svc.DeleteObject(f.bucketName, f.absolutePath(path, name))
 	if err != nil {
 		return fmt.Errorf("failed to remove metadata in Object Storage: %s", err.Error())
 	}
@@ -140,16 +157,11 @@ func (f *Folder) Read(path string, name string, callback FolderDecoderCallback)
 		return false, err
 	}
 	if found {
-		o, err := f.svc.GetObject(f.bucketName, f.absolutePath(path, name), nil)
-		if err != nil {
-			return false, err
-		}
-		var buffer bytes.Buffer
-		buffer.ReadFrom(o.Content)
+		buffer, err := f.readObject(f.absolutePath(path, name))
 		if err != nil {
 			return true, err
 		}
-		return true, callback(&buffer)
+		return true, callback(buffer)
 	}
 	return false, nil
 }
@@ -162,9 +174,25 @@ func (f *Folder) Write(path string, name string, content interface{}) error {
 		return err
 	}
 
+	absPath := f.absolutePath(path, name)
+	data, err := wrap(absPath, buffer.Bytes())
+	if err != nil {
+		return err
+	}
+	data, err = encrypt(currentKey(f.svc.GetMetadataKeys()), absPath, data)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt metadata: %s", err.Error())
+	}
+
+	err = f.Lock()
+	if err != nil {
+		return err
+	}
+	defer f.Unlock()
+
 	return f.svc.PutObject(f.bucketName, api.Object{
-		Name:    f.absolutePath(path, name),
-		Content: bytes.NewReader(buffer.Bytes()),
+		Name:    absPath,
+		Content: bytes.NewReader(data),
 	})
 }
 
@@ -185,16 +213,40 @@ func (f *Folder) Browse(path string, callback FolderDecoderCallback) error {
 	}
 
 	for _, i := range list {
-		o, err := f.svc.GetObject(f.bucketName, i, nil)
+		buffer, err := f.readObject(i)
 		if err != nil {
 			return err
 		}
-		var buffer bytes.Buffer
-		buffer.ReadFrom(o.Content)
-		err = callback(&buffer)
+		err = callback(buffer)
 		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+// readObject reads the object 'name' of the metadata bucket, decrypts it and upgrades its content to the current version
+func (f *Folder) readObject(name string) (*bytes.Buffer, error) {
+	o, err := f.svc.GetObject(f.bucketName, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	_, err = buffer.ReadFrom(o.Content)
+	if err != nil {
+		return nil, err
+	}
+	data, err := decrypt(f.svc.GetMetadataKeys(), name, buffer.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	e, err := unwrap(name, data)
+	if err != nil {
+		return nil, err
+	}
+	_, err = upgrade(name, e)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(e.Payload), nil
+}
@@ -20,6 +20,8 @@ import (
 	"bytes"
 	"sync"
 
+	log "github.com/sirupsen/logrus"
+
 	"github.com/CS-SI/SafeScale/providers"
 )
 
@@ -27,7 +29,7 @@ import (
 type Item struct {
 	payload interface{}
 	folder  *Folder
-	lock    sync.Mutex
+	lock    *sync.Mutex
 }
 
 // ItemDecoderCallback ...
@@ -134,11 +136,27 @@ func (i *Item) Browse(callback func(*bytes.Buffer) error) error {
 }
 
 // Acquire waits until the write lock is available, then locks the metadata
-func (i *Item) Acquire() {
-	i.lock.Lock()
+// The lock is shared with the other processes using the same metadata bucket, and with the other items of the
+// process in the same folder: a goroutine acquiring any of them waits until the holder releases it
+func (i *Item) Acquire() error {
+	lock := writer(i.folder.bucketName, lockKey(i.folder.path))
+	lock.Lock()
+	err := i.folder.Lock()
+	if err != nil {
+		lock.Unlock()
+		return err
+	}
+	i.lock = lock
+	return nil
 }
 
 // Release unlocks the metadata
 func (i *Item) Release() {
-	i.lock.Unlock()
+	err := i.folder.Unlock()
+	if err != nil {
+		log.Warnf("Failed to release metadata lock of '%s': %v", i.folder.GetPath(), err)
+	}
+	lock := i.lock
+	i.lock = nil
+	lock.Unlock()
 }
@@ -0,0 +1,57 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+// The kinds of the metadata objects written by SafeScale are all declared here, and not by the packages handling them,
+// so that every binary knows all of them; brokerd, which migrates the metadata, doesn't import the cluster package
+const (
+	// hostKind is the kind of the metadata objects describing an host
+	hostKind = "host"
+	// hostVersion is the current version of the format of api.Host in metadata
+	hostVersion = 1
+	// networkKind is the kind of the metadata objects describing a network
+	networkKind = "network"
+	// networkVersion is the current version of the format of api.Network in metadata
+	networkVersion = 1
+	// volumeKind is the kind of the metadata objects describing a volume
+	volumeKind = "volume"
+	// volumeVersion is the current version of the format of api.Volume in metadata
+	volumeVersion = 1
+	// volumeAttachmentKind is the kind of the metadata objects describing the attachment of a volume to an host
+	volumeAttachmentKind = "volume_attachment"
+	// volumeAttachmentVersion is the current version of the format of api.VolumeAttachment in metadata
+	volumeAttachmentVersion = 1
+	// nasKind is the kind of the metadata objects describing a nas or one of its clients
+	nasKind = "nas"
+	// nasVersion is the current version of the format of api.Nas in metadata
+	nasVersion = 1
+	// clusterKind is the kind of the metadata objects describing a cluster
+	clusterKind = "cluster"
+	// clusterVersion is the current version of the format of api.ClusterCore in metadata
+	clusterVersion = 1
+)
+
+func init() {
+	RegisterKind(hostKind, hostVersion, "hosts/byID/*", "hosts/byName/*")
+	// The gateway and the hosts attached to a network are stored inside the folder of the network
+	RegisterKind(hostKind, hostVersion, "networks/*/gw", "networks/*/hosts/*")
+	RegisterKind(networkKind, networkVersion, "networks/byID/*", "networks/byName/*")
+	RegisterKind(volumeKind, volumeVersion, "volumes/byID/*", "volumes/byName/*")
+	RegisterKind(volumeAttachmentKind, volumeAttachmentVersion, "volumes/*/*")
+	RegisterKind(nasKind, nasVersion, "nas/*/*")
+	RegisterKind(clusterKind, clusterVersion, "clusters/*")
+}
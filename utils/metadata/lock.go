@@ -0,0 +1,377 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+)
+
+const (
+	// locksFolderName is the folder of the metadata bucket containing the leases
+	locksFolderName = "locks"
+	// defaultLockSettleDelay is the time to wait before checking a lease has not been stolen by a concurrent writer
+	defaultLockSettleDelay = 200 * time.Millisecond
+	// defaultLockPollDelay is the delay between 2 attempts to acquire a lease
+	defaultLockPollDelay = 500 * time.Millisecond
+)
+
+var (
+	// LeaseDuration is the time a lease stays valid if not renewed
+	LeaseDuration = 30 * time.Second
+	// LockTimeout is the maximum time to wait for a lease held by someone else
+	LockTimeout = 2 * time.Minute
+
+	// owner identifies the current process as lease owner
+	owner string
+
+	// held contains the leases held by the current process, indexed by bucket and key
+	held     = map[string]*heldLease{}
+	heldLock sync.Mutex
+
+	// writers serializes the goroutines of the process writing under the same lease, indexed by bucket and key
+	writers     = map[string]*sync.Mutex{}
+	writersLock sync.Mutex
+)
+
+func init() {
+	hostname, _ := os.Hostname()
+	id, _ := uuid.NewV4()
+	owner = fmt.Sprintf("%s:%d:%s", hostname, os.Getpid(), id.String()[:8])
+}
+
+// Lease is a lock on a metadata folder, stored in the metadata bucket itself
+// As Object Storage doesn't provide atomic operations, a lease is considered as acquired
+// only if it is still owned by its writer after a short settle delay
+type Lease struct {
+	Key        string    `json:"key"`
+	Owner      string    `json:"owner"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Expired tells if the lease is no longer valid
+func (l *Lease) Expired() bool {
+	return time.Now().After(l.ExpiresAt)
+}
+
+// heldLease is a lease held by the current process
+type heldLease struct {
+	lease Lease
+	count int
+	stop  chan struct{}
+}
+
+// lockKey returns the key of the lease protecting path; a lease protects a whole metadata folder (hosts, networks, ...)
+func lockKey(path string) string {
+	path = strings.Trim(path, "/")
+	if i := strings.Index(path, "/"); i != -1 {
+		path = path[:i]
+	}
+	return path
+}
+
+// leaseObjectName returns the name of the object storing the lease 'key'
+func leaseObjectName(key string) string {
+	return locksFolderName + "/" + key
+}
+
+// readLease reads the lease 'key' from the bucket, returns nil, nil if there is no lease
+func readLease(svc *providers.Service, bucket string, key string) (*Lease, error) {
+	list, err := svc.ListObjects(bucket, api.ObjectFilter{Path: locksFolderName})
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, item := range list {
+		if item == leaseObjectName(key) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	o, err := svc.GetObject(bucket, leaseObjectName(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	_, err = buffer.ReadFrom(o.Content)
+	if err != nil {
+		return nil, err
+	}
+	var lease Lease
+	err = json.Unmarshal(buffer.Bytes(), &lease)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lease '%s': %s", key, err.Error())
+	}
+	return &lease, nil
+}
+
+// writeLease stores lease in the bucket
+func writeLease(svc *providers.Service, bucket string, lease *Lease) error {
+	content, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+	return svc.PutObject(bucket, api.Object{
+		Name:        leaseObjectName(lease.Key),
+		Content:     bytes.NewReader(content),
+		ContentType: "application/json",
+	})
+}
+
+// lockDelays returns the settle and poll delays to use with the leases of the provider, which can be set with
+// the config options 'MetadataLockSettleDelay' and 'MetadataLockPollDelay'
+// A provider whose leases can't be written concurrently (like the local one kept in memory) sets them to 0
+func lockDelays(svc *providers.Service) (settle time.Duration, poll time.Duration) {
+	settle, poll = defaultLockSettleDelay, defaultLockPollDelay
+	cfg, err := svc.GetCfgOpts()
+	if err != nil {
+		return settle, poll
+	}
+	if anon, ok := cfg.Get("MetadataLockSettleDelay"); ok {
+		if d, ok := anon.(time.Duration); ok {
+			settle = d
+		}
+	}
+	if anon, ok := cfg.Get("MetadataLockPollDelay"); ok {
+		if d, ok := anon.(time.Duration); ok {
+			poll = d
+		}
+	}
+	return settle, poll
+}
+
+// acquireLease waits until the lease 'key' is available, then takes it
+// If the lease is already held by the current process, it is shared: the lease excludes the other processes only,
+// the goroutines of the process are excluded from each other by writer
+func acquireLease(svc *providers.Service, bucket string, key string) error {
+	if shareLease(bucket, key) {
+		return nil
+	}
+
+	settleDelay, pollDelay := lockDelays(svc)
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		current, err := readLease(svc, bucket, key)
+		if err != nil {
+			return fmt.Errorf("failed to read lease '%s': %s", key, err.Error())
+		}
+		if current == nil || current.Expired() || current.Owner == owner {
+			now := time.Now()
+			lease := Lease{
+				Key:        key,
+				Owner:      owner,
+				AcquiredAt: now,
+				ExpiresAt:  now.Add(LeaseDuration),
+			}
+			err = writeLease(svc, bucket, &lease)
+			if err != nil {
+				return fmt.Errorf("failed to write lease '%s': %s", key, err.Error())
+			}
+			// Checks that no concurrent writer took the lease meanwhile
+			if settleDelay > 0 {
+				time.Sleep(settleDelay)
+			}
+			current, err = readLease(svc, bucket, key)
+			if err != nil {
+				return fmt.Errorf("failed to read lease '%s': %s", key, err.Error())
+			}
+			if current != nil && current.Owner == owner {
+				heldLock.Lock()
+				defer heldLock.Unlock()
+				if h, ok := held[bucket+"/"+key]; ok {
+					// Another goroutine of the process took the lease meanwhile
+					h.count++
+					return nil
+				}
+				h := &heldLease{lease: lease, count: 1, stop: make(chan struct{})}
+				held[bucket+"/"+key] = h
+				go renewLease(svc, bucket, h)
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			msg := fmt.Sprintf("timeout waiting for metadata lock '%s'", key)
+			if current != nil {
+				msg += fmt.Sprintf(" held by '%s' until %s", current.Owner, current.ExpiresAt.Format(time.RFC3339))
+			}
+			return fmt.Errorf("%s", msg)
+		}
+		time.Sleep(pollDelay)
+	}
+}
+
+// shareLease increments the use count of the lease 'key' if it is already held by the current process
+func shareLease(bucket string, key string) bool {
+	heldLock.Lock()
+	defer heldLock.Unlock()
+
+	if h, ok := held[bucket+"/"+key]; ok {
+		h.count++
+		return true
+	}
+	return false
+}
+
+// writer returns the mutex serializing the goroutines of the process holding the lease 'key'
+func writer(bucket string, key string) *sync.Mutex {
+	writersLock.Lock()
+	defer writersLock.Unlock()
+
+	mu, ok := writers[bucket+"/"+key]
+	if !ok {
+		mu = &sync.Mutex{}
+		writers[bucket+"/"+key] = mu
+	}
+	return mu
+}
+
+// renewLease extends the lease until it is released
+func renewLease(svc *providers.Service, bucket string, h *heldLease) {
+	ticker := time.NewTicker(LeaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+			heldLock.Lock()
+			lease := h.lease
+			heldLock.Unlock()
+			current, err := readLease(svc, bucket, lease.Key)
+			if err == nil && current != nil && current.Owner != owner {
+				log.Warnf("Metadata lock '%s' has been taken by '%s'", lease.Key, current.Owner)
+				return
+			}
+			lease.ExpiresAt = time.Now().Add(LeaseDuration)
+			heldLock.Lock()
+			select {
+			case <-h.stop:
+				// Released meanwhile, the lease must not be written again
+				heldLock.Unlock()
+				return
+			default:
+			}
+			err = writeLease(svc, bucket, &lease)
+			if err == nil {
+				h.lease = lease
+			}
+			heldLock.Unlock()
+			if err != nil {
+				log.Warnf("Failed to renew metadata lock '%s': %v", lease.Key, err)
+			}
+		}
+	}
+}
+
+// releaseLease releases the lease 'key' held by the current process
+func releaseLease(svc *providers.Service, bucket string, key string) error {
+	heldLock.Lock()
+	h, ok := held[bucket+"/"+key]
+	if !ok {
+		heldLock.Unlock()
+		return fmt.Errorf("metadata lock '%s' is not held", key)
+	}
+	h.count--
+	if h.count > 0 {
+		heldLock.Unlock()
+		return nil
+	}
+	close(h.stop)
+	delete(held, bucket+"/"+key)
+	heldLock.Unlock()
+
+	current, err := readLease(svc, bucket, key)
+	if err != nil {
+		return err
+	}
+	if current == nil || current.Owner != owner {
+		return nil
+	}
+	return svc.DeleteObject(bucket, leaseObjectName(key))
+}
+
+// ListLocks returns the leases stored in the metadata bucket of the tenant
+func ListLocks(svc *providers.Service) ([]Lease, error) {
+	bucket, err := bucketName(svc)
+	if err != nil {
+		return nil, err
+	}
+	list, err := svc.ListObjects(bucket, api.ObjectFilter{Path: locksFolderName})
+	if err != nil {
+		return nil, err
+	}
+	var leases []Lease
+	for _, item := range list {
+		lease, err := readLease(svc, bucket, strings.TrimPrefix(item, locksFolderName+"/"))
+		if err != nil {
+			return nil, err
+		}
+		if lease != nil {
+			leases = append(leases, *lease)
+		}
+	}
+	return leases, nil
+}
+
+// BreakLock removes the lease 'key' from the metadata bucket of the tenant, whoever owns it
+// Should only be used on stale locks, left by a process that died before releasing them;
+// a lease not expired yet is removed only if force is true
+func BreakLock(svc *providers.Service, key string, force bool) error {
+	bucket, err := bucketName(svc)
+	if err != nil {
+		return err
+	}
+	lease, err := readLease(svc, bucket, key)
+	if err != nil {
+		return err
+	}
+	if lease == nil {
+		return fmt.Errorf("no metadata lock '%s' found", key)
+	}
+	if !lease.Expired() && !force {
+		return fmt.Errorf("metadata lock '%s' is held by '%s' until %s", key, lease.Owner, lease.ExpiresAt.Format(time.RFC3339))
+	}
+	return svc.DeleteObject(bucket, leaseObjectName(key))
+}
+
+// bucketName returns the name of the metadata bucket of the tenant
+func bucketName(svc *providers.Service) (string, error) {
+	cfg, err := svc.GetCfgOpts()
+	if err != nil {
+		return "", fmt.Errorf("config options are not available! %s", err.Error())
+	}
+	name, found := cfg.Get("MetadataBucket")
+	if !found {
+		return "", fmt.Errorf("config option 'MetadataBucket' is not set")
+	}
+	return name.(string), nil
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/local"
+	"github.com/CS-SI/SafeScale/utils/metadata"
+)
+
+func TestFolder_Lock(t *testing.T) {
+	clt, err := (&local.Client{}).Build(map[string]interface{}{"name": t.Name()})
+	require.Nil(t, err)
+	svc := providers.FromClient(clt)
+
+	folder := metadata.NewFolder(svc, "hosts/byName")
+	require.Nil(t, folder.Lock())
+	// The lease is shared by the folders of the process
+	require.Nil(t, metadata.NewFolder(svc, "hosts/byID").Lock())
+
+	locks, err := metadata.ListLocks(svc)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(locks))
+	assert.Equal(t, "hosts", locks[0].Key)
+	assert.False(t, locks[0].Expired())
+	assert.NotNil(t, metadata.BreakLock(svc, "hosts", false))
+
+	assert.Nil(t, folder.Unlock())
+	locks, err = metadata.ListLocks(svc)
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(locks))
+
+	assert.Nil(t, folder.Unlock())
+	locks, err = metadata.ListLocks(svc)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(locks))
+	assert.NotNil(t, folder.Unlock())
+	assert.NotNil(t, metadata.BreakLock(svc, "hosts", true))
+
+	// Writes take the lease and release it
+	assert.Nil(t, folder.Write(".", "host1", "content"))
+	locks, err = metadata.ListLocks(svc)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(locks))
+}
+
+func TestFolder_LockDelays(t *testing.T) {
+	clt, err := (&local.Client{}).Build(map[string]interface{}{"name": t.Name()})
+	require.Nil(t, err)
+	svc := providers.FromClient(clt)
+
+	// The local provider kept in memory disables the settle delay of the leases
+	folder := metadata.NewFolder(svc, "hosts/byName")
+	begin := time.Now()
+	for i := 0; i < 10; i++ {
+		require.Nil(t, folder.Write(".", "host1", "content"))
+	}
+	assert.True(t, time.Since(begin) < time.Second)
+}
+
+func TestItem_Acquire(t *testing.T) {
+	clt, err := (&local.Client{}).Build(map[string]interface{}{"name": t.Name()})
+	require.Nil(t, err)
+	svc := providers.FromClient(clt)
+
+	// The items of a folder exclude each other inside the process too
+	first := metadata.NewItem(svc, "hosts/byName")
+	require.Nil(t, first.Acquire())
+	acquired := make(chan struct{})
+	go func() {
+		second := metadata.NewItem(svc, "hosts/byID")
+		require.Nil(t, second.Acquire())
+		close(acquired)
+		second.Release()
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("the lock has been acquired while held by another item")
+	case <-time.After(100 * time.Millisecond):
+	}
+	// Writes of the holder still share its lease
+	require.Nil(t, first.Carry("content").Write("host1"))
+	first.Release()
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the lock has not been acquired once released")
+	}
+
+	// Another folder is not excluded
+	other := metadata.NewItem(svc, "networks/byName")
+	require.Nil(t, other.Acquire())
+	other.Release()
+}
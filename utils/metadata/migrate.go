@@ -0,0 +1,160 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/CS-SI/SafeScale/providers"
+	"github.com/CS-SI/SafeScale/providers/api"
+)
+
+// Migration describes the upgrade of a metadata object
+type Migration struct {
+	Name string
+	Kind string
+	From int
+	To   int
+}
+
+// RewriteFunc receives the decrypted content of a metadata object and returns the new content, and true if the object
+// has to be rewritten
+type RewriteFunc func(name string, content []byte) ([]byte, bool, error)
+
+// Migrate upgrades all the metadata objects of the tenant to the current version of their kind, wrapping in an
+// envelope those written before its introduction
+// Fails on objects whose kind is unknown, rather than wrapping them in an envelope that could never be upgraded
+// If dryRun is true, nothing is written
+// Returns the objects upgraded (or to be upgraded)
+func Migrate(svc *providers.Service, dryRun bool) ([]Migration, error) {
+	var migrations []Migration
+	err := rewriteBucket(svc, func(name string, content []byte) ([]byte, bool, error) {
+		wrapped := bytes.HasPrefix(content, envelopeHeader)
+		e, err := unwrap(name, content)
+		if err != nil {
+			return nil, false, err
+		}
+		if e.Kind == "" {
+			// Objects may have been wrapped by a binary unaware of their kind; it's deduced from their name, their
+			// version is then 0
+			k := kindOf(name)
+			if k == nil {
+				return nil, false, fmt.Errorf("metadata '%s' is of unknown kind", name)
+			}
+			e.Kind = k.name
+		}
+		from := e.Version
+		upgraded, err := upgrade(name, e)
+		if err != nil {
+			return nil, false, err
+		}
+		if wrapped && !upgraded {
+			return nil, false, nil
+		}
+		migrations = append(migrations, Migration{Name: name, Kind: e.Kind, From: from, To: e.Version})
+		if dryRun {
+			return nil, false, nil
+		}
+		content, err = e.encode()
+		return content, err == nil, err
+	})
+	return migrations, err
+}
+
+// rewriteBucket calls fn on the decrypted content of each metadata object of the tenant, and rewrites the object
+// (encrypted with the current key) if asked to
+// The lock of each metadata folder is held while its objects are processed
+func rewriteBucket(svc *providers.Service, fn RewriteFunc) error {
+	bucket, err := bucketName(svc)
+	if err != nil {
+		return err
+	}
+	list, err := svc.ListObjects(bucket, api.ObjectFilter{})
+	if err != nil {
+		return err
+	}
+
+	// Groups objects by lock, to hold each lock only once
+	byLock := map[string][]string{}
+	for _, name := range list {
+		if strings.HasPrefix(name, locksFolderName+"/") {
+			continue
+		}
+		byLock[lockKey(name)] = append(byLock[lockKey(name)], name)
+	}
+	var lockKeys []string
+	for k := range byLock {
+		lockKeys = append(lockKeys, k)
+	}
+	sort.Strings(lockKeys)
+
+	keys := svc.GetMetadataKeys()
+	for _, k := range lockKeys {
+		err := rewriteObjects(svc, bucket, k, byLock[k], keys, fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteObjects calls fn on the objects protected by the lock 'key'
+func rewriteObjects(svc *providers.Service, bucket string, key string, names []string, keys *providers.MetadataKeys, fn RewriteFunc) error {
+	err := acquireLease(svc, bucket, key)
+	if err != nil {
+		return err
+	}
+	defer releaseLease(svc, bucket, key)
+
+	for _, name := range names {
+		o, err := svc.GetObject(bucket, name, nil)
+		if err != nil {
+			return err
+		}
+		var buffer bytes.Buffer
+		_, err = buffer.ReadFrom(o.Content)
+		if err != nil {
+			return err
+		}
+		content, err := decrypt(keys, name, buffer.Bytes())
+		if err != nil {
+			return err
+		}
+		content, rewrite, err := fn(name, content)
+		if err != nil {
+			return err
+		}
+		if !rewrite {
+			continue
+		}
+		content, err = encrypt(currentKey(keys), name, content)
+		if err != nil {
+			return err
+		}
+		err = svc.PutObject(bucket, api.Object{
+			Name:    name,
+			Content: bytes.NewReader(content),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to rewrite metadata '%s': %s", name, err.Error())
+		}
+	}
+	return nil
+}
@@ -20,6 +20,7 @@ package retry
 // delays and stop conditions
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -108,6 +109,40 @@ func WhileUnsuccessfulDelay5Seconds(run func() error, timeout time.Duration) err
 	return WhileUnsuccessful(run, 5*time.Second, timeout)
 }
 
+// WhileUnsuccessfulWithContext retries every 'delay' while 'run' is unsuccessful with a 'timeout',
+// aborting as soon as 'ctx' is done (the error returned is then ctx.Err())
+func WhileUnsuccessfulWithContext(ctx context.Context, run func() error, delay time.Duration, timeout time.Duration) error {
+	if delay <= 0 {
+		delay = time.Second
+	}
+	var arbiter Arbiter
+	if timeout <= 0 {
+		arbiter = PrevailDone(Unsuccessful(), Canceled(ctx))
+	} else {
+		arbiter = PrevailDone(Unsuccessful(), Canceled(ctx), Timeout(timeout))
+	}
+	return action{
+		Arbiter: arbiter,
+		Officer: ConstantWithContext(ctx, delay),
+		Run:     run,
+		First:   ctx.Err,
+		Last:    nil,
+		Notify:  nil,
+	}.loop()
+}
+
+// WhileUnsuccessfulDelay1SecondWithContext retries while 'run' is unsuccessful (ie 'run' returns an error != nil),
+// waiting 1 second after each try, expiring after 'timeout' or when 'ctx' is done
+func WhileUnsuccessfulDelay1SecondWithContext(ctx context.Context, run func() error, timeout time.Duration) error {
+	return WhileUnsuccessfulWithContext(ctx, run, time.Second, timeout)
+}
+
+// WhileUnsuccessfulDelay5SecondsWithContext retries while 'run' is unsuccessful (ie 'run' returns an error != nil),
+// waiting 5 seconds after each try, expiring after 'timeout' or when 'ctx' is done
+func WhileUnsuccessfulDelay5SecondsWithContext(ctx context.Context, run func() error, timeout time.Duration) error {
+	return WhileUnsuccessfulWithContext(ctx, run, 5*time.Second, timeout)
+}
+
 // WhileUnsuccessfulWithNotify retries while 'run' is unsuccessful (ie 'run' returns an error != nil),
 // waiting 'delay' after each try, expiting after 'timeout'
 func WhileUnsuccessfulWithNotify(run func() error, delay time.Duration, timeout time.Duration, notify Notify) error {
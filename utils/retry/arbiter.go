@@ -17,6 +17,7 @@
 package retry
 
 import (
+	"context"
 	"time"
 
 	"github.com/CS-SI/SafeScale/utils"
@@ -119,6 +120,19 @@ func Timeout(limit time.Duration) Arbiter {
 	}
 }
 
+// Canceled returns Abort with the error of the context as soon as 'ctx' is done.
+func Canceled(ctx context.Context) Arbiter {
+	return func(t Try) (Verdict.Enum, error) {
+		if t.Err != nil {
+			if err := ctx.Err(); err != nil {
+				return Verdict.Abort, err
+			}
+			return Verdict.Retry, nil
+		}
+		return Verdict.Done, nil
+	}
+}
+
 // Max errors after a limited number of tries
 func Max(limit uint) Arbiter {
 	return func(t Try) (Verdict.Enum, error) {
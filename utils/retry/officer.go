@@ -1,6 +1,7 @@
 package retry
 
 import (
+	"context"
 	"math"
 	"time"
 )
@@ -20,6 +21,19 @@ func Constant(duration time.Duration) *Officer {
 	return &o
 }
 
+// ConstantWithContext sleeps for duration duration, or less if 'ctx' is done meanwhile
+func ConstantWithContext(ctx context.Context, duration time.Duration) *Officer {
+	o := Officer{
+		Block: func(t Try) {
+			select {
+			case <-ctx.Done():
+			case <-time.After(duration):
+			}
+		},
+	}
+	return &o
+}
+
 // Incremental sleeps for duration + the number of tries
 func Incremental(duration time.Duration) *Officer {
 	o := Officer{
@@ -114,6 +114,10 @@ func UserConfirmed(msg string) bool {
 func ExtractRetCode(err error) (string, int, error) {
 	retCode := -1
 	msg := "__ NO MESSAGE __"
+	// Errors of remote commands (system.SSHExitError) give their exit status
+	if ee, ok := err.(interface{ ExitStatus() int }); ok {
+		return err.Error(), ee.ExitStatus(), nil
+	}
 	if ee, ok := err.(*exec.ExitError); ok {
 		//Try to get retCode
 		if status, ok := ee.Sys().(syscall.WaitStatus); ok {